@@ -1,14 +1,17 @@
 // Package main is the single binary that runs both the API service and the
-// per-router Agent service, selected by `--mode={api|agent}`.
+// per-router Agent service, selected by `--mode={controller|agent}` (api is
+// the controller's original name).
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
 	"time"
@@ -18,10 +21,13 @@ import (
 	"router-sync/internal/config"
 	"router-sync/internal/logging"
 	"router-sync/internal/metrics"
+	"router-sync/internal/models"
 	"router-sync/internal/nats"
+	"router-sync/internal/profiling"
 	"router-sync/internal/router"
+	"router-sync/internal/systemd"
 
-	_ "router-sync/docs" // register Swagger doc.json
+	_ "router-sync/docs" // register the embedded Swagger spec
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -35,22 +41,49 @@ var (
 
 // @title Router Sync API
 // @version 1.0
-// @description Router synchronization service for managing internet providers and routing policies
+// @description Router synchronization service for managing internet providers and routing policies.
+// @description /api/v1 and /api/v2 serve the same resources. /api/v2 errors use the ErrorResponse envelope (code, message, details, request_id); /api/v1 errors keep the original {"error", "details"} body.
 // @host localhost:18080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init-config" {
+		initConfig(os.Args[2:])
+		return
+	}
+
 	var (
-		configPath string
-		modeFlag   string
+		configPath  string
+		modeFlag    string
+		printConfig bool
+		showVersion bool
+		overrides   config.Overrides
 	)
-	flag.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
-	flag.StringVar(&modeFlag, "mode", "", "Runtime mode: api or agent (overrides config.mode)")
+	flag.StringVar(&configPath, "config", "config.yaml", "Path to configuration file, or a directory of *.yaml fragments merged in lexical order")
+	flag.StringVar(&modeFlag, "mode", "", "Runtime mode: controller (alias api) or agent (overrides config.mode)")
+	flag.StringVar(&overrides.APIAddress, "api-address", "", "API listen address, e.g. :18080 (overrides api.address)")
+	flag.StringVar(&overrides.NATSURL, "nats-url", "", "NATS server URLs, comma-separated (overrides nats.urls)")
+	flag.StringVar(&overrides.LogLevel, "log-level", "", "Log level: trace, debug, info, warn, error (overrides log_level)")
+	flag.DurationVar(&overrides.SyncInterval, "sync-interval", 0, "Full sync interval, e.g. 30s (overrides sync.interval)")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the resolved configuration (secrets redacted) and exit")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
 	flag.Parse()
 
+	if showVersion {
+		fmt.Printf("router-sync %s\nbuild time: %s\ngit commit: %s\ngo: %s %s/%s\n",
+			Version, BuildTime, GitCommit, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		return
+	}
+
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		logrus.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := overrides.Apply(cfg); err != nil {
+		logrus.Fatalf("Invalid command line: %v", err)
+	}
 
 	if modeFlag != "" {
 		cfg.Mode = config.Mode(strings.ToLower(strings.TrimSpace(modeFlag)))
@@ -59,15 +92,57 @@ func main() {
 		cfg.Mode = config.ModeAPI
 	}
 
-	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	if printConfig {
+		out, err := cfg.RedactedYAML()
+		if err != nil {
+			logrus.Fatalf("Failed to print configuration: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if err := logging.SetFormat(cfg.LogFormat); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+	logging.SetDedupWindow(cfg.LogDedupWindow)
+	if err := logging.SetComponentLevels(cfg.LogLevels); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+	if err := models.SetRanges(models.Ranges{
+		PriorityMin:   cfg.Router.PriorityMin,
+		PriorityMax:   cfg.Router.PriorityMax,
+		TableMin:      cfg.Router.TableMin,
+		TableMax:      cfg.Router.TableMax,
+		RouteProtocol: cfg.Router.RouteProtocol,
+	}); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.LogFile.Path != "" {
+		logFile, err := logging.OpenRotatingFile(cfg.LogFile.Path, cfg.LogFile.MaxSizeMB, cfg.LogFile.MaxBackups, cfg.LogFile.MaxAge)
+		if err != nil {
+			logrus.Fatalf("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		logrus.SetOutput(logFile)
+	}
+
+	if enabled := cfg.Features.Enabled(); len(enabled) > 0 {
+		logrus.Infof("Features enabled: %s", strings.Join(enabled, ", "))
+	}
+
+	stopProfiling, err := profiling.Start(cfg.Profiling)
+	if err != nil {
+		logrus.Fatalf("Failed to start profiling: %v", err)
+	}
+	defer stopProfiling(context.Background())
 
 	switch cfg.Mode {
-	case config.ModeAPI:
+	case config.ModeAPI, config.ModeController:
 		runAPI(cfg)
 	case config.ModeAgent:
 		runAgent(cfg)
 	default:
-		logrus.Fatalf("Unknown mode %q (expected: api or agent)", cfg.Mode)
+		logrus.Fatalf("Unknown mode %q (expected: controller, api or agent)", cfg.Mode)
 	}
 }
 
@@ -84,20 +159,37 @@ func runAPI(cfg *config.Config) {
 	if err := api.MigrateProviderInterfaces(natsClient); err != nil {
 		logrus.Warnf("Provider interface migration failed: %v", err)
 	}
+	if err := api.MigratePolicyIDs(natsClient); err != nil {
+		logrus.Warnf("Policy ID migration failed: %v", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	api.WatchOwnLogLevel(ctx, natsClient)
 
-	apiServer := api.NewServer(cfg.API, natsClient, Version, BuildTime, GitCommit)
+	apiServer, err := api.NewServer(cfg, natsClient, Version, BuildTime, GitCommit)
+	if err != nil {
+		logrus.Fatalf("Failed to create API server: %v", err)
+	}
 
+	// Bind first so systemd only hears READY once the API accepts
+	// connections
+	if err := apiServer.Listen(); err != nil {
+		logrus.Fatalf("Failed to start API server: %v", err)
+	}
 	go func() {
 		if err := apiServer.Start(); err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Failed to start API server: %v", err)
 		}
 	}()
 
+	if _, err := systemd.Notify(systemd.Ready); err != nil {
+		logrus.Warnf("sd_notify failed: %v", err)
+	}
+	go systemd.KeepAlive(ctx, nil)
+
 	awaitShutdown(func(ctx context.Context) {
+		_, _ = systemd.Notify(systemd.Stopping)
 		if err := apiServer.Shutdown(ctx); err != nil {
 			logrus.Errorf("Error during API server shutdown: %v", err)
 		}
@@ -123,13 +215,18 @@ func runAgent(cfg *config.Config) {
 	}
 	defer natsClient.Close()
 
-	routerManager, err := router.NewManager(hostname)
+	routerManager, err := router.NewManager(hostname, router.Options{
+		DisableConntrack: cfg.Router.DisableConntrack,
+		NFTables:         cfg.Features.NFTables,
+		SourceSets:       cfg.Router.SourceSets,
+		DryRun:           cfg.Router.DryRun,
+	})
 	if err != nil {
 		logrus.Fatalf("Failed to initialize router manager: %v", err)
 	}
 
 	reg := metrics.NewRegistry()
-	agentSvc := agent.NewService(natsClient, routerManager, *cfg, Version, reg)
+	agentSvc := agent.NewService(natsClient, routerManager, *cfg, Version, metrics.Registerer(reg, cfg.Metrics, hostname, Version))
 
 	go func() {
 		if err := agentSvc.Start(); err != nil {
@@ -137,7 +234,7 @@ func runAgent(cfg *config.Config) {
 		}
 	}()
 
-	httpServer := newAgentHTTPServer(cfg.Agent.MetricsAddress, reg, hostname)
+	httpServer := newAgentHTTPServer(cfg.Agent.MetricsAddress, reg, hostname, agentSvc)
 	go func() {
 		logrus.Infof("Starting agent HTTP listener on %s", cfg.Agent.MetricsAddress)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -152,21 +249,57 @@ func runAgent(cfg *config.Config) {
 		if err := agentSvc.Stop(); err != nil {
 			logrus.Errorf("Error during agent service shutdown: %v", err)
 		}
-		if err := routerManager.CleanupAllRules(); err != nil {
+		if agentSvc.InMaintenance() {
+			logrus.Warn("Maintenance mode active: leaving routing rules in place")
+			return
+		}
+		if cfg.Agent.OnShutdown == config.ShutdownKeep {
+			logrus.Info("Leaving routing rules in place (agent.on_shutdown: keep)")
+			return
+		}
+		if _, err := routerManager.CleanupAllRules(); err != nil {
 			logrus.Errorf("Error during routing rules cleanup: %v", err)
 		}
 		if err := routerManager.RemoveSuppressDefaultRule(); err != nil {
 			logrus.Errorf("Error during suppress-default rule cleanup: %v", err)
 		}
+		if err := routerManager.SyncProbeRules(nil); err != nil {
+			logrus.Errorf("Error during health probe rule cleanup: %v", err)
+		}
 	})
 }
 
-func newAgentHTTPServer(addr string, reg *prometheus.Registry, hostname string) *http.Server {
+// newAgentHTTPServer serves the agent's probes and metrics:
+// /livez (process up), /readyz (able to sync), /health (alias of /livez).
+func newAgentHTTPServer(addr string, reg *prometheus.Registry, hostname string, svc *agent.Service) *http.Server {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	live := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, `{"status":"healthy","service":"router-sync-agent","hostname":%q,"timestamp":%q}`,
 			hostname, time.Now().UTC().Format(time.RFC3339))
+	}
+	mux.HandleFunc("/health", live)
+	mux.HandleFunc("/livez", live)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready, checks := svc.Readiness()
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		maintenance := svc.Maintenance()
+		if ready && maintenance.Enabled {
+			status = "maintenance"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":      status,
+			"service":     "router-sync-agent",
+			"hostname":    hostname,
+			"checks":      checks,
+			"maintenance": maintenance.Enabled,
+			"timestamp":   time.Now().UTC(),
+		})
 	})
 	mux.Handle("/metrics", metrics.HandlerFor(reg))
 	return &http.Server{Addr: addr, Handler: mux}
@@ -183,3 +316,25 @@ func awaitShutdown(shutdown func(context.Context)) {
 	shutdown(ctx)
 	logrus.Info("Stopped")
 }
+
+// initConfig implements `router-sync init-config [-o path] [--force]`: it
+// writes the commented example configuration to stdout or a file.
+func initConfig(args []string) {
+	fs := flag.NewFlagSet("init-config", flag.ExitOnError)
+	output := fs.String("o", "-", "File to write, or - for stdout")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: router-sync init-config [-o path] [--force]\n\nWrites a commented example config.yaml with every setting and its default.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *output == "-" {
+		os.Stdout.Write(config.Example())
+		return
+	}
+	if err := config.WriteExample(*output, *force); err != nil {
+		logrus.Fatalf("init-config: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"router-sync/internal/models"
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newApplyCommand(opts *globalOptions) *cobra.Command {
+	var (
+		files  []string
+		dryRun bool
+		prune  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "apply -f PATH",
+		Short: "Create or update providers, policies and groups from manifests",
+		Long: `Read YAML or JSON manifests (the format of GET /export) and apply them.
+The API compares them with the store and writes only records that differ;
+unchanged ones are left alone. Providers and groups are identified by name.
+A policy without an id is matched to the existing policy of the same name,
+or gets a new ID.
+
+With --prune, providers, policies and groups missing from the manifests are
+deleted, so the store ends up exactly as described.`,
+		Example: "  routersync apply -f manifests/ --dry-run\n  routersync apply -f providers.yaml -f policies.yaml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			doc, err := loadManifests(files)
+			if err != nil {
+				return err
+			}
+			c := opts.client()
+			existing, err := c.ListPolicies(cmd.Context(), client.ListOptions{})
+			if err != nil {
+				return err
+			}
+			if err := resolvePolicyIDs(doc, existing, true); err != nil {
+				return err
+			}
+
+			mode := client.ImportMerge
+			if prune {
+				mode = client.ImportReplace
+			}
+			res, err := c.Import(cmd.Context(), doc, client.ImportOptions{Mode: mode, DryRun: dryRun})
+			if err != nil {
+				return err
+			}
+			if structured(opts.output) {
+				return printStructured(cmd.OutOrStdout(), opts.output, res)
+			}
+			printImportResult(cmd.OutOrStdout(), res)
+			if len(res.Errors) > 0 {
+				return fmt.Errorf("%d change(s) failed", len(res.Errors))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVarP(&files, "filename", "f", nil, "Manifest file or directory (repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show what would change")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Also delete records missing from the manifests")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+func newDeleteCommand(opts *globalOptions) *cobra.Command {
+	var files []string
+	cmd := &cobra.Command{
+		Use:   "delete -f PATH",
+		Short: "Delete the providers, policies and groups listed in manifests",
+		Long: `Delete every record in the manifests. Policies go first so no provider is
+removed while a policy still uses it. Records that no longer exist are
+skipped.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			doc, err := loadManifests(files)
+			if err != nil {
+				return err
+			}
+			c := opts.client()
+			existing, err := c.ListPolicies(cmd.Context(), client.ListOptions{})
+			if err != nil {
+				return err
+			}
+			if err := resolvePolicyIDs(doc, existing, false); err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			var failed int
+			report := func(kind, id string, err error) {
+				switch {
+				case err == nil:
+					fmt.Fprintf(w, "%s %s deleted\n", kind, id)
+				case client.IsNotFound(err):
+					fmt.Fprintf(w, "%s %s not found, skipped\n", kind, id)
+				default:
+					fmt.Fprintf(w, "%s %s: %v\n", kind, id, err)
+					failed++
+				}
+			}
+			for _, p := range doc.Policies {
+				if p.ID == "" {
+					fmt.Fprintf(w, "policy %q not found, skipped\n", p.Name)
+					continue
+				}
+				report("policy", p.ID, c.DeletePolicy(cmd.Context(), p.ID))
+			}
+			for _, g := range doc.Groups {
+				report("group", g.ID, c.DeleteGroup(cmd.Context(), g.ID))
+			}
+			for _, p := range doc.Providers {
+				report("provider", p.ID, c.DeleteProvider(cmd.Context(), p.ID))
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d deletion(s) failed", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVarP(&files, "filename", "f", nil, "Manifest file or directory (repeatable)")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// loadManifests merges the documents in paths into one. A path is a file or
+// a directory whose *.yaml, *.yml and *.json files are read in name order; a
+// file may hold several YAML documents separated by "---". Empty entries are
+// dropped, and providers and groups without an id take their name, as the
+// API does on create.
+func loadManifests(paths []string) (*client.Document, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+
+	merged := &client.Document{APIVersion: client.DocumentVersion}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		for {
+			var doc client.Document
+			if err := dec.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if doc.APIVersion != "" && doc.APIVersion != client.DocumentVersion {
+				return nil, fmt.Errorf("%s: unsupported api_version %q (expected %s)", file, doc.APIVersion, client.DocumentVersion)
+			}
+			for _, p := range doc.Providers {
+				if p != nil {
+					if p.ID == "" {
+						p.ID = p.Name
+					}
+					merged.Providers = append(merged.Providers, p)
+				}
+			}
+			for _, p := range doc.Policies {
+				if p != nil {
+					merged.Policies = append(merged.Policies, p)
+				}
+			}
+			for _, g := range doc.Groups {
+				if g != nil {
+					if g.ID == "" {
+						g.ID = g.Name
+					}
+					merged.Groups = append(merged.Groups, g)
+				}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// resolvePolicyIDs fills in the ID of policies that have none from the
+// stored policy with the same name. Without a match, assign gives the policy
+// a new ID (for apply) or leaves it empty (for delete). A name shared by
+// several stored policies, or by several policies without an id in the
+// manifests, is an error: the manifest must set the id.
+func resolvePolicyIDs(doc *client.Document, existing []*client.Policy, assign bool) error {
+	byName := make(map[string][]string, len(existing))
+	for _, p := range existing {
+		byName[p.Name] = append(byName[p.Name], p.ID)
+	}
+	seen := make(map[string]bool, len(doc.Policies))
+	for _, p := range doc.Policies {
+		if p.ID != "" {
+			continue
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("policy %q appears more than once without an id; set their ids in the manifests", p.Name)
+		}
+		seen[p.Name] = true
+		switch ids := byName[p.Name]; len(ids) {
+		case 0:
+			if assign {
+				p.ID = models.NewPolicyID()
+				if p.Tenant != "" {
+					p.ID = models.NewTenantPolicyID(p.Tenant)
+				}
+			}
+		case 1:
+			p.ID = ids[0]
+		default:
+			return fmt.Errorf("policy %q matches %d stored policies (%s); set its id in the manifest", p.Name, len(ids), strings.Join(ids, ", "))
+		}
+	}
+	return nil
+}
+
+// printImportResult lists the changes of an apply, one record per line.
+func printImportResult(w io.Writer, res *client.ImportResult) {
+	verb := map[bool]string{true: "would be ", false: ""}[res.DryRun]
+	for _, section := range []struct {
+		kind    string
+		changes client.ImportChanges
+	}{
+		{"provider", res.Providers},
+		{"group", res.Groups},
+		{"policy", res.Policies},
+	} {
+		for _, id := range section.changes.Created {
+			fmt.Fprintf(w, "%s %s %screated\n", section.kind, id, verb)
+		}
+		for _, id := range section.changes.Updated {
+			fmt.Fprintf(w, "%s %s %supdated\n", section.kind, id, verb)
+		}
+		for _, id := range section.changes.Deleted {
+			fmt.Fprintf(w, "%s %s %sdeleted\n", section.kind, id, verb)
+		}
+		for _, id := range section.changes.Unchanged {
+			fmt.Fprintf(w, "%s %s unchanged\n", section.kind, id)
+		}
+	}
+	for _, msg := range res.Errors {
+		fmt.Fprintf(w, "error: %s\n", msg)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"router-sync/pkg/client"
+)
+
+// writeManifest writes content to name under dir and returns its path.
+func writeManifest(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadManifests_DirectoryAndMultiDoc(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "b-policies.yaml", `
+policies:
+  - name: Office VoIP
+    source_ip: 10.0.20.0/24
+    provider_id: starlink
+---
+policies:
+  - id: p2
+    name: Cameras
+    source_ip: 10.0.30.0/24
+    provider_id: starlink
+`)
+	writeManifest(t, dir, "a-providers.json", `{"api_version": "router-sync/v1", "providers": [{"name": "starlink", "table_id": 200, "gateway": "192.168.100.1"}]}`)
+	writeManifest(t, dir, "c-groups.yml", "groups:\n  - name: voip\n")
+	writeManifest(t, dir, "README.md", "not a manifest: [")
+
+	doc, err := loadManifests([]string{dir})
+	if err != nil {
+		t.Fatalf("loadManifests() error = %v", err)
+	}
+	if doc.APIVersion != client.DocumentVersion {
+		t.Errorf("api_version = %q", doc.APIVersion)
+	}
+	if len(doc.Providers) != 1 || doc.Providers[0].ID != "starlink" || doc.Providers[0].TableID != 200 {
+		t.Errorf("providers = %+v, want starlink with its name as id", doc.Providers)
+	}
+	if len(doc.Policies) != 2 || doc.Policies[0].Name != "Office VoIP" || doc.Policies[1].ID != "p2" {
+		t.Errorf("policies = %+v, want both documents in file order", doc.Policies)
+	}
+	if doc.Policies[0].ID != "" {
+		t.Errorf("policy without id got %q; IDs are resolved later", doc.Policies[0].ID)
+	}
+	if len(doc.Groups) != 1 || doc.Groups[0].ID != "voip" {
+		t.Errorf("groups = %+v", doc.Groups)
+	}
+}
+
+func TestLoadManifests_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "providers:\n  - name: starlink\n    tabel_id: 200\n", "field tabel_id not found"},
+		{"unknown key in later document", "policies: []\n---\nprovider: []\n", "field provider not found"},
+		{"api_version mismatch", "api_version: router-sync/v9\nproviders: []\n", `unsupported api_version "router-sync/v9"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeManifest(t, t.TempDir(), "m.yaml", tt.content)
+			_, err := loadManifests([]string{path})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("loadManifests() error = %v, want %q", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("error %q does not name the file", err)
+			}
+		})
+	}
+
+	if _, err := loadManifests([]string{filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("loadManifests() of a missing path succeeded")
+	}
+}
+
+func TestResolvePolicyIDs(t *testing.T) {
+	existing := []*client.Policy{
+		{ID: "id-voip", Name: "Office VoIP"},
+		{ID: "id-tv1", Name: "TV"},
+		{ID: "id-tv2", Name: "TV"},
+	}
+
+	tests := []struct {
+		name     string
+		policies []*client.Policy
+		assign   bool
+		wantIDs  []string // "new" for a generated ID
+		wantErr  string
+	}{
+		{name: "matched by name", policies: []*client.Policy{{Name: "Office VoIP"}}, wantIDs: []string{"id-voip"}},
+		{name: "explicit id kept", policies: []*client.Policy{{ID: "mine", Name: "Office VoIP"}}, wantIDs: []string{"mine"}},
+		{name: "new policy on apply", policies: []*client.Policy{{Name: "Cameras"}}, assign: true, wantIDs: []string{"new"}},
+		{name: "new policy on delete", policies: []*client.Policy{{Name: "Cameras"}}, wantIDs: []string{""}},
+		{name: "ambiguous stored name", policies: []*client.Policy{{Name: "TV"}}, wantErr: "matches 2 stored policies"},
+		{name: "duplicate name in manifests", policies: []*client.Policy{{Name: "Cameras"}, {Name: "Cameras"}}, assign: true, wantErr: "more than once"},
+		{name: "duplicate name with ids", policies: []*client.Policy{{ID: "a", Name: "Cameras"}, {ID: "b", Name: "Cameras"}}, wantIDs: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &client.Document{Policies: tt.policies}
+			err := resolvePolicyIDs(doc, existing, tt.assign)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolvePolicyIDs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolvePolicyIDs() error = %v", err)
+			}
+			for i, want := range tt.wantIDs {
+				got := doc.Policies[i].ID
+				if want == "new" {
+					if got == "" || got == "id-voip" {
+						t.Errorf("policy %d id = %q, want a new ID", i, got)
+					}
+				} else if got != want {
+					t.Errorf("policy %d id = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyCommand_ImportMode(t *testing.T) {
+	manifest := writeManifest(t, t.TempDir(), "m.yaml", "providers:\n  - name: starlink\n    table_id: 200\n    gateway: 192.168.100.1\n")
+
+	tests := []struct {
+		args     []string
+		wantMode string
+		wantDry  string
+	}{
+		{nil, client.ImportMerge, ""},
+		{[]string{"--prune"}, client.ImportReplace, ""},
+		{[]string{"--prune", "--dry-run"}, client.ImportReplace, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(append([]string{"apply"}, tt.args...), " "), func(t *testing.T) {
+			var gotMode, gotDry string
+			var gotDoc client.Document
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v2/policies":
+					_, _ = w.Write([]byte("[]"))
+				case "/api/v2/import":
+					gotMode = r.URL.Query().Get("mode")
+					gotDry = r.URL.Query().Get("dry_run")
+					_ = json.NewDecoder(r.Body).Decode(&gotDoc)
+					_ = json.NewEncoder(w).Encode(client.ImportResult{Providers: client.ImportChanges{Created: []string{"starlink"}}})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			var out bytes.Buffer
+			root := newRootCommand()
+			root.SetOut(&out)
+			root.SetArgs(append([]string{"--server", srv.URL, "apply", "-f", manifest}, tt.args...))
+			if err := root.Execute(); err != nil {
+				t.Fatalf("apply error = %v", err)
+			}
+
+			if gotMode != tt.wantMode || gotDry != tt.wantDry {
+				t.Errorf("import mode=%q dry_run=%q, want %q %q", gotMode, gotDry, tt.wantMode, tt.wantDry)
+			}
+			if len(gotDoc.Providers) != 1 || gotDoc.Providers[0].ID != "starlink" {
+				t.Errorf("imported document = %+v", gotDoc)
+			}
+			if !strings.Contains(out.String(), "provider starlink created") {
+				t.Errorf("output = %q", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+// completionFunc is a cobra ValidArgsFunction or flag completion.
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeProviderIDs completes provider IDs from the API, described by
+// their name. For arguments it only completes the first one.
+func completeProviderIDs(opts *globalOptions, firstArgOnly bool) completionFunc {
+	return func(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if firstArgOnly && len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		providers, err := opts.client().ListProviders(cmd.Context(), client.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		out := make([]string, 0, len(providers))
+		for _, p := range providers {
+			out = append(out, p.ID+"\t"+p.Name)
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completePolicyIDs completes the first argument with policy IDs, described
+// by the policy name and source.
+func completePolicyIDs(opts *globalOptions) completionFunc {
+	return func(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		policies, err := opts.client().ListPolicies(cmd.Context(), client.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		out := make([]string, 0, len(policies))
+		for _, p := range policies {
+			out = append(out, p.ID+"\t"+p.Name+" ("+p.SourceIP+")")
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeRouters completes router hostnames known from their heartbeats.
+func completeRouters(opts *globalOptions) completionFunc {
+	return func(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		stats, err := opts.client().Stats(cmd.Context())
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		out := make([]string, 0, len(stats.Routers))
+		for _, r := range stats.Routers {
+			out = append(out, r.Hostname)
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFixed completes one of values.
+func completeFixed(values ...string) completionFunc {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
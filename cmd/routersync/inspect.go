@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/state"
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of inspect, for monitoring scripts: 0 in sync, 1 error (any
+// command failure), 2 drift between the kernel and the desired state.
+const exitDrift = 2
+
+// exitError ends the process with code without printing anything more; the
+// command has already reported the outcome.
+type exitError struct{ code int }
+
+func (e *exitError) Error() string { return "exit status " + strconv.Itoa(e.code) }
+
+// ANSI colors for diff output.
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+func newInspectCommand(opts *globalOptions) *cobra.Command {
+	var (
+		hostname string
+		noColor  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Compare this router's kernel rules and routes with the desired state",
+		Long: `Run on a router: read ip rules and routing tables directly from the kernel
+(netlink), fetch the providers and policies from the API and print what the
+agent would change, in color on a terminal.
+
+Exit status is 0 when the kernel matches, 2 when it differs and 1 on errors,
+so it can back a monitoring check. Unlike "diff", which uses the last
+heartbeat the agent published, this reads the kernel now.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if hostname == "" {
+				hn, err := os.Hostname()
+				if err != nil {
+					return fmt.Errorf("failed to get hostname: %w", err)
+				}
+				hostname = hn
+			}
+
+			c := opts.client()
+			providers, err := c.ListProviders(cmd.Context(), client.ListOptions{})
+			if err != nil {
+				return err
+			}
+			policies, err := c.ListPolicies(cmd.Context(), client.ListOptions{})
+			if err != nil {
+				return err
+			}
+
+			collector := state.NewCollector(hostname)
+			names := make(map[int]string, len(providers))
+			for _, p := range providers {
+				names[p.TableID] = p.Name
+			}
+			collector.SetTableNames(names)
+			st, err := collector.Collect()
+			if err != nil {
+				return fmt.Errorf("failed to read kernel state: %w", err)
+			}
+
+			d := diff.Compute(st, providers, policies)
+			color := !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+			return reportInspect(cmd.OutOrStdout(), opts.output, d, color)
+		},
+	}
+	cmd.Flags().StringVar(&hostname, "hostname", "", "Router hostname as used in provider interfaces (default: this host's name, like agent.hostname)")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colors (also NO_COLOR)")
+	return cmd
+}
+
+// reportInspect prints d in format and returns an exitError with exitDrift
+// when the kernel differs from the desired state.
+func reportInspect(w io.Writer, format string, d client.RouterDiff, color bool) error {
+	if structured(format) {
+		if err := printStructured(w, format, d); err != nil {
+			return err
+		}
+	} else {
+		printInspect(w, d, color)
+	}
+	if !d.InSync {
+		return &exitError{code: exitDrift}
+	}
+	return nil
+}
+
+// printInspect writes d as one line per change: + to add, - to remove, ~ to
+// change.
+func printInspect(w io.Writer, d client.RouterDiff, color bool) {
+	if d.InSync {
+		fmt.Fprintf(w, "%s: kernel matches the desired state\n", d.Hostname)
+		return
+	}
+	fmt.Fprintf(w, "%s: %d difference(s)\n", d.Hostname, len(d.Changes))
+	for _, ch := range d.Changes {
+		sign, col := "~", colorYellow
+		switch ch.Action {
+		case diff.ActionAdd:
+			sign, col = "+", colorGreen
+		case diff.ActionRemove:
+			sign, col = "-", colorRed
+		}
+		line := fmt.Sprintf("%s %s %s", sign, ch.Kind, ch.Message)
+		if ch.PolicyID != "" {
+			line += " (policy " + ch.PolicyID + ")"
+		}
+		if color {
+			line = col + line + colorReset
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// isTerminal reports whether f is a character device (a terminal).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
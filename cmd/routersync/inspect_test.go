@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"router-sync/internal/diff"
+	"router-sync/pkg/client"
+)
+
+func driftDiff() client.RouterDiff {
+	return client.RouterDiff{
+		Hostname: "r1",
+		Changes: []diff.Change{
+			{Action: diff.ActionAdd, Kind: diff.KindRule, Message: "from 10.0.20.0/24 lookup 200", PolicyID: "p1"},
+			{Action: diff.ActionRemove, Kind: diff.KindRule, Message: "from 10.0.30.0/24 lookup 201"},
+			{Action: diff.ActionChange, Kind: diff.KindRoute, Message: "default via 192.168.100.1 table 200"},
+		},
+	}
+}
+
+func TestPrintInspect_Drift(t *testing.T) {
+	var buf bytes.Buffer
+	printInspect(&buf, driftDiff(), false)
+
+	want := "r1: 3 difference(s)\n" +
+		"+ rule from 10.0.20.0/24 lookup 200 (policy p1)\n" +
+		"- rule from 10.0.30.0/24 lookup 201\n" +
+		"~ route default via 192.168.100.1 table 200\n"
+	if buf.String() != want {
+		t.Errorf("printInspect() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestPrintInspect_Color(t *testing.T) {
+	var buf bytes.Buffer
+	printInspect(&buf, driftDiff(), true)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, col := range []string{colorGreen, colorRed, colorYellow} {
+		line := lines[i+1]
+		if !strings.HasPrefix(line, col) || !strings.HasSuffix(line, colorReset) {
+			t.Errorf("line %d = %q, want wrapped in %q", i+1, line, col)
+		}
+	}
+}
+
+func TestPrintInspect_InSync(t *testing.T) {
+	var buf bytes.Buffer
+	printInspect(&buf, client.RouterDiff{Hostname: "r1", InSync: true}, true)
+
+	if got := buf.String(); got != "r1: kernel matches the desired state\n" {
+		t.Errorf("printInspect() = %q", got)
+	}
+}
+
+func TestReportInspect_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		diff     client.RouterDiff
+		wantExit int // 0: no error
+	}{
+		{"in sync", outputTable, client.RouterDiff{Hostname: "r1", InSync: true}, 0},
+		{"drift", outputTable, driftDiff(), exitDrift},
+		{"drift json", outputJSON, driftDiff(), exitDrift},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := reportInspect(&buf, tt.format, tt.diff, false)
+			if tt.wantExit == 0 {
+				if err != nil {
+					t.Fatalf("reportInspect() error = %v", err)
+				}
+				return
+			}
+			var exit *exitError
+			if !errors.As(err, &exit) || exit.code != tt.wantExit {
+				t.Fatalf("reportInspect() error = %v, want exit %d", err, tt.wantExit)
+			}
+			if buf.Len() == 0 {
+				t.Error("reportInspect() printed nothing")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Command routersync is a command-line client for the router-sync REST API:
+// list and edit providers and policies, check sync status and pending
+// changes without hand-written curl calls.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+// Version information, set at build time like the server's.
+var (
+	Version   = "dev"
+	BuildTime = "unknown"
+	GitCommit = "unknown"
+)
+
+// globalOptions are the flags shared by every command.
+type globalOptions struct {
+	server  string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+func (o *globalOptions) client() *client.Client {
+	c := client.New(o.server)
+	c.Token = o.token
+	c.UserAgent = "routersync/" + Version
+	c.HTTPClient = &http.Client{Timeout: o.timeout}
+	return c
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := newRootCommand().ExecuteContext(ctx)
+	stop()
+	var exit *exitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.code)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	opts := &globalOptions{}
+	root := &cobra.Command{
+		Use:   "routersync",
+		Short: "Command-line client for the router-sync API",
+		Long: `Command-line client for the router-sync API.
+
+Shell completion, including provider and policy IDs fetched from the API:
+  source <(routersync completion bash)
+  routersync completion zsh > "${fpath[1]}/_routersync"
+  routersync completion fish > ~/.config/fish/completions/routersync.fish`,
+		Version:       fmt.Sprintf("%s (built %s, commit %s)", Version, BuildTime, GitCommit),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return validOutput(opts.output)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.server, "server", envOr("ROUTERSYNC_SERVER", "http://localhost:18080"), "API base URL (env ROUTERSYNC_SERVER)")
+	flags.StringVar(&opts.token, "token", os.Getenv("ROUTERSYNC_TOKEN"), "Bearer token when API auth is enabled (env ROUTERSYNC_TOKEN)")
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "Output format: table, wide, json or yaml")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "HTTP request timeout")
+	_ = root.RegisterFlagCompletionFunc("output", completeFixed(outputFormats...))
+
+	root.AddCommand(
+		newProvidersCommand(opts),
+		newPoliciesCommand(opts),
+		newSyncCommand(opts),
+		newStatusCommand(opts),
+		newDiffCommand(opts),
+		newApplyCommand(opts),
+		newDeleteCommand(opts),
+		newTopCommand(opts),
+		newInspectCommand(opts),
+		newMonitoringCommand(),
+	)
+	return root
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"router-sync/internal/config"
+	"router-sync/internal/monitoring"
+
+	"github.com/spf13/cobra"
+)
+
+func newMonitoringCommand() *cobra.Command {
+	var namespace string
+	cmd := &cobra.Command{
+		Use:   "monitoring",
+		Short: "Print Prometheus rules and a Grafana dashboard for router-sync",
+		Long: `Print the recommended Prometheus recording and alerting rules, or a
+Grafana dashboard, built from the metric names of this version. Use the
+metrics.namespace of your deployment; the API serves the same files at
+/api/v1/monitoring/rules and /api/v1/monitoring/dashboard.
+
+  routersync monitoring rules > /etc/prometheus/rules/router-sync.yml
+  routersync monitoring dashboard > router-sync-dashboard.json`,
+	}
+	cmd.PersistentFlags().StringVar(&namespace, "namespace", config.DefaultMetricsNamespace, "metrics.namespace of the deployment")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "rules",
+			Short: "Print Prometheus recording and alerting rules",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				out, err := monitoring.RulesYAML(namespace)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.OutOrStdout().Write(out)
+				return err
+			},
+		},
+		&cobra.Command{
+			Use:   "dashboard",
+			Short: "Print a Grafana dashboard (JSON)",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				out, err := monitoring.DashboardJSON(namespace)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.OutOrStdout().Write(out)
+				return err
+			},
+		},
+	)
+	return cmd
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with --output.
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputYAML}
+
+// validOutput reports whether format is one of outputFormats.
+func validOutput(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (expected %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// tableFunc builds the table view of a result; wide adds the extra columns
+// of -o wide.
+type tableFunc func(wide bool) (header []string, rows [][]string)
+
+// printJSON writes v as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printYAML writes v as YAML with the same keys as its JSON form, so both
+// formats can be fed to the same scripts.
+func printYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(generic); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// printTable writes rows under header, aligned in columns.
+func printTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// structured reports whether format prints the data itself (JSON or YAML)
+// rather than a view of it.
+func structured(format string) bool {
+	return format == outputJSON || format == outputYAML
+}
+
+// printStructured prints v as JSON or YAML.
+func printStructured(w io.Writer, format string, v interface{}) error {
+	if format == outputYAML {
+		return printYAML(w, v)
+	}
+	return printJSON(w, v)
+}
+
+// render prints v as JSON or YAML, or as the table built by table.
+func render(w io.Writer, format string, v interface{}, table tableFunc) error {
+	if structured(format) {
+		return printStructured(w, format, v)
+	}
+	header, rows := table(format == outputWide)
+	return printTable(w, header, rows)
+}
+
+// orDash shows empty cells as "-".
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// nonZero shows n, or "-" for 0 (unset).
+func nonZero(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
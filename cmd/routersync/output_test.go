@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidOutput(t *testing.T) {
+	for _, f := range outputFormats {
+		if err := validOutput(f); err != nil {
+			t.Errorf("validOutput(%q) error = %v", f, err)
+		}
+	}
+	if err := validOutput("xml"); err == nil || !strings.Contains(err.Error(), "table, wide, json, yaml") {
+		t.Errorf("validOutput(xml) error = %v", err)
+	}
+}
+
+func TestPrintYAML_UsesJSONKeys(t *testing.T) {
+	v := struct {
+		TableID int    `json:"table_id"`
+		Gateway string `json:"gateway,omitempty"`
+		Skipped string `json:"-"`
+	}{TableID: 200, Skipped: "x"}
+
+	var buf bytes.Buffer
+	if err := printYAML(&buf, v); err != nil {
+		t.Fatalf("printYAML() error = %v", err)
+	}
+	var got map[string]interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not YAML: %v", err)
+	}
+	var want map[string]interface{}
+	data, _ := json.Marshal(v)
+	_ = yaml.Unmarshal(data, &want)
+	if len(got) != 1 || got["table_id"] != want["table_id"] {
+		t.Errorf("printYAML() = %v, want %v", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	table := func(wide bool) ([]string, [][]string) {
+		header := []string{"ID", "TABLE"}
+		row := []string{"starlink", "200"}
+		if wide {
+			header = append(header, "DESCRIPTION")
+			row = append(row, "dish")
+		}
+		return header, [][]string{row}
+	}
+	v := map[string]string{"id": "starlink"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{outputTable, "ID        TABLE\nstarlink  200\n"},
+		{outputWide, "ID        TABLE  DESCRIPTION\nstarlink  200    dish\n"},
+		{outputJSON, "{\n  \"id\": \"starlink\"\n}\n"},
+		{outputYAML, "id: starlink\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := render(&buf, tt.format, v, table); err != nil {
+			t.Fatalf("render(%s) error = %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("render(%s) =\n%q\nwant\n%q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
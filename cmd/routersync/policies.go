@@ -0,0 +1,236 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func newPoliciesCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "policies",
+		Aliases: []string{"policy"},
+		Short:   "Manage routing policies",
+	}
+	cmd.AddCommand(
+		newPoliciesListCommand(opts),
+		newPoliciesGetCommand(opts),
+		newPoliciesCreateCommand(opts),
+		newPoliciesUpdateCommand(opts),
+		newPoliciesDeleteCommand(opts),
+	)
+	return cmd
+}
+
+func newPoliciesListCommand(opts *globalOptions) *cobra.Command {
+	var labels, provider string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List policies",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var policies []*client.Policy
+			var err error
+			if provider != "" {
+				policies, err = opts.client().ListProviderPolicies(cmd.Context(), provider)
+			} else {
+				policies, err = opts.client().ListPolicies(cmd.Context(), client.ListOptions{Labels: labels})
+			}
+			if err != nil {
+				return err
+			}
+			sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+			return render(cmd.OutOrStdout(), opts.output, policies, policyTable(policies...))
+		},
+	}
+	cmd.Flags().StringVar(&labels, "labels", "", "Label selector, e.g. team=voip")
+	cmd.Flags().StringVar(&provider, "provider", "", "Only policies routed through this provider")
+	_ = cmd.RegisterFlagCompletionFunc("provider", completeProviderIDs(opts, false))
+	return cmd
+}
+
+func newPoliciesGetCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:               "get ID",
+		Short:             "Show one policy",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePolicyIDs(opts),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := opts.client().GetPolicy(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, p, policyTable(p))
+		},
+	}
+}
+
+// policyFlags are the editable policy fields as flags; like providerFlags,
+// only the ones given on the command line are applied.
+type policyFlags struct {
+	source      string
+	destination string
+	provider    string
+	backups     []string
+	mode        string
+	description string
+	enabled     bool
+	priority    int
+}
+
+func (f *policyFlags) register(cmd *cobra.Command, opts *globalOptions) {
+	flags := cmd.Flags()
+	flags.StringVar(&f.source, "source", "", "Source IP or CIDR")
+	flags.StringVar(&f.destination, "destination", "", "Destination IP or CIDR (empty matches every destination)")
+	flags.StringVar(&f.provider, "provider", "", "Provider ID")
+	flags.StringSliceVar(&f.backups, "backup", nil, "Backup provider IDs, in order")
+	flags.StringVar(&f.mode, "mode", "", "Enforcement mode: strict or best-effort")
+	flags.StringVar(&f.description, "description", "", "Description")
+	flags.BoolVar(&f.enabled, "enabled", true, "Whether the policy is applied")
+	flags.IntVar(&f.priority, "priority", 0, "Explicit ip rule priority (0 derives it from the prefix length)")
+	_ = cmd.RegisterFlagCompletionFunc("provider", completeProviderIDs(opts, false))
+	_ = cmd.RegisterFlagCompletionFunc("backup", completeProviderIDs(opts, false))
+	_ = cmd.RegisterFlagCompletionFunc("mode", completeFixed("strict", "best-effort"))
+}
+
+func (f *policyFlags) apply(cmd *cobra.Command, p *client.Policy) {
+	flags := cmd.Flags()
+	if flags.Changed("source") {
+		p.SourceIP = f.source
+	}
+	if flags.Changed("destination") {
+		p.Destination = f.destination
+	}
+	if flags.Changed("provider") {
+		p.ProviderID = f.provider
+	}
+	if flags.Changed("backup") {
+		p.BackupProviderIDs = f.backups
+	}
+	if flags.Changed("mode") {
+		p.Mode = f.mode
+	}
+	if flags.Changed("description") {
+		p.Description = f.description
+	}
+	if flags.Changed("enabled") {
+		p.Enabled = f.enabled
+	}
+	if flags.Changed("priority") {
+		p.Priority = f.priority
+	}
+}
+
+func newPoliciesCreateCommand(opts *globalOptions) *cobra.Command {
+	f := &policyFlags{}
+	cmd := &cobra.Command{
+		Use:     "create NAME",
+		Short:   "Create a policy",
+		Example: "  routersync policies create \"Office VoIP\" --source 10.0.20.0/24 --provider starlink --backup lte",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := &client.Policy{Name: args[0], Enabled: f.enabled}
+			f.apply(cmd, p)
+			created, err := opts.client().CreatePolicy(cmd.Context(), p)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, created, policyTable(created))
+		},
+	}
+	f.register(cmd, opts)
+	_ = cmd.MarkFlagRequired("source")
+	_ = cmd.MarkFlagRequired("provider")
+	return cmd
+}
+
+func newPoliciesUpdateCommand(opts *globalOptions) *cobra.Command {
+	f := &policyFlags{}
+	var name string
+	cmd := &cobra.Command{
+		Use:               "update ID",
+		Short:             "Change fields of a policy",
+		Long:              "Fetch the policy, apply the given flags and store it. Fields without a flag keep their value.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePolicyIDs(opts),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := opts.client()
+			p, err := c.GetPolicy(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			f.apply(cmd, p)
+			if cmd.Flags().Changed("name") {
+				p.Name = name
+			}
+			p.Status = nil
+			updated, err := c.UpdatePolicy(cmd.Context(), args[0], p)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, updated, policyTable(updated))
+		},
+	}
+	f.register(cmd, opts)
+	cmd.Flags().StringVar(&name, "name", "", "New name")
+	return cmd
+}
+
+func newPoliciesDeleteCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete ID",
+		Short:             "Delete a policy",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePolicyIDs(opts),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.client().DeletePolicy(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			cmd.Printf("Policy %s deleted\n", args[0])
+			return nil
+		},
+	}
+}
+
+// policyTable is the table view of policies; wide adds the match on
+// protocol and ports, backups, mode, priority, group and the provider the
+// routers currently use.
+func policyTable(policies ...*client.Policy) tableFunc {
+	return func(wide bool) ([]string, [][]string) {
+		header := []string{"ID", "NAME", "SOURCE", "DESTINATION", "PROVIDER", "ENABLED", "APPLIED"}
+		if wide {
+			header = append(header, "PROTOCOL", "PORTS", "BACKUPS", "MODE", "PRIORITY", "GROUP", "CURRENT")
+		}
+		rows := make([][]string, 0, len(policies))
+		for _, p := range policies {
+			applied, current := "-", ""
+			if p.Status != nil {
+				applied = strconv.FormatBool(p.Status.Applied)
+				current = p.Status.CurrentProviderID
+			}
+			row := []string{p.ID, p.Name, p.SourceIP, orDash(p.Destination), p.ProviderID, strconv.FormatBool(p.Enabled), applied}
+			if wide {
+				row = append(row, orDash(p.Protocol), orDash(formatPorts(p)), orDash(strings.Join(p.BackupProviderIDs, ",")),
+					orDash(p.Mode), nonZero(int64(p.Priority)), orDash(p.GroupID), orDash(current))
+			}
+			rows = append(rows, row)
+		}
+		return header, rows
+	}
+}
+
+// formatPorts shows the port match as "src:1024-65535 dst:443".
+func formatPorts(p *client.Policy) string {
+	var parts []string
+	if p.SourcePorts != "" {
+		parts = append(parts, "src:"+p.SourcePorts)
+	}
+	if p.DestinationPorts != "" {
+		parts = append(parts, "dst:"+p.DestinationPorts)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func newProvidersCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "providers",
+		Aliases: []string{"provider"},
+		Short:   "Manage internet providers",
+	}
+	cmd.AddCommand(
+		newProvidersListCommand(opts),
+		newProvidersGetCommand(opts),
+		newProvidersCreateCommand(opts),
+		newProvidersUpdateCommand(opts),
+		newProvidersDeleteCommand(opts),
+	)
+	return cmd
+}
+
+func newProvidersListCommand(opts *globalOptions) *cobra.Command {
+	var labels string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List providers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			providers, err := opts.client().ListProviders(cmd.Context(), client.ListOptions{Labels: labels})
+			if err != nil {
+				return err
+			}
+			sort.Slice(providers, func(i, j int) bool { return providers[i].ID < providers[j].ID })
+			return render(cmd.OutOrStdout(), opts.output, providers, providerTable(providers...))
+		},
+	}
+	cmd.Flags().StringVar(&labels, "labels", "", "Label selector, e.g. site=hq,tier!=backup")
+	return cmd
+}
+
+func newProvidersGetCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:               "get ID",
+		Short:             "Show one provider",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProviderIDs(opts, true),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := opts.client().GetProvider(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, p, providerTable(p))
+		},
+	}
+}
+
+// providerFlags are the editable provider fields as flags; update only
+// applies the ones given on the command line.
+type providerFlags struct {
+	interfaces  map[string]string
+	tableID     int
+	gateway     string
+	gatewayV6   string
+	description string
+}
+
+func (f *providerFlags) register(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringToStringVar(&f.interfaces, "interface", nil, "Interface per router, e.g. r1=enp1s0 (repeatable)")
+	flags.IntVar(&f.tableID, "table-id", 0, "Routing table ID")
+	flags.StringVar(&f.gateway, "gateway", "", "IPv4 gateway")
+	flags.StringVar(&f.gatewayV6, "gateway-v6", "", "IPv6 gateway")
+	flags.StringVar(&f.description, "description", "", "Description")
+}
+
+func (f *providerFlags) apply(cmd *cobra.Command, p *client.Provider) {
+	flags := cmd.Flags()
+	if flags.Changed("interface") {
+		p.Interfaces = f.interfaces
+	}
+	if flags.Changed("table-id") {
+		p.TableID = f.tableID
+	}
+	if flags.Changed("gateway") {
+		p.Gateway = f.gateway
+	}
+	if flags.Changed("gateway-v6") {
+		p.GatewayV6 = f.gatewayV6
+	}
+	if flags.Changed("description") {
+		p.Description = f.description
+	}
+}
+
+func newProvidersCreateCommand(opts *globalOptions) *cobra.Command {
+	f := &providerFlags{}
+	cmd := &cobra.Command{
+		Use:     "create NAME",
+		Short:   "Create a provider",
+		Example: "  routersync providers create Starlink --interface r1=enp2s0 --table-id 200 --gateway 192.168.100.1",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := &client.Provider{Name: args[0]}
+			f.apply(cmd, p)
+			created, err := opts.client().CreateProvider(cmd.Context(), p)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, created, providerTable(created))
+		},
+	}
+	f.register(cmd)
+	return cmd
+}
+
+func newProvidersUpdateCommand(opts *globalOptions) *cobra.Command {
+	f := &providerFlags{}
+	var name string
+	cmd := &cobra.Command{
+		Use:               "update ID",
+		Short:             "Change fields of a provider",
+		Long:              "Fetch the provider, apply the given flags and store it. Fields without a flag keep their value; --name renames the provider.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProviderIDs(opts, true),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := opts.client()
+			p, err := c.GetProvider(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			f.apply(cmd, p)
+			if cmd.Flags().Changed("name") {
+				p.Name = name
+			}
+			p.Status = nil
+			updated, err := c.UpdateProvider(cmd.Context(), args[0], p)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, updated, providerTable(updated))
+		},
+	}
+	f.register(cmd)
+	cmd.Flags().StringVar(&name, "name", "", "New name (also changes the ID)")
+	return cmd
+}
+
+func newProvidersDeleteCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete ID",
+		Short:             "Delete a provider",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProviderIDs(opts, true),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.client().DeleteProvider(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			cmd.Printf("Provider %s deleted\n", args[0])
+			return nil
+		},
+	}
+}
+
+// providerTable is the table view of providers; wide adds the failover
+// priority, monthly cap and description.
+func providerTable(providers ...*client.Provider) tableFunc {
+	return func(wide bool) ([]string, [][]string) {
+		header := []string{"ID", "TABLE", "GATEWAY", "INTERFACES", "APPLIED"}
+		if wide {
+			header = append(header, "FAILOVER", "MONTHLY-CAP", "DESCRIPTION")
+		}
+		rows := make([][]string, 0, len(providers))
+		for _, p := range providers {
+			applied := "-"
+			if p.Status != nil {
+				applied = strconv.FormatBool(p.Status.Applied)
+			}
+			row := []string{p.ID, strconv.Itoa(p.TableID), orDash(strings.Join(p.Gateways(), ",")), orDash(formatInterfaces(p)), applied}
+			if wide {
+				row = append(row, nonZero(int64(p.FailoverPriority)), nonZero(p.MonthlyCap), orDash(p.Description))
+			}
+			rows = append(rows, row)
+		}
+		return header, rows
+	}
+}
+
+// formatInterfaces lists the per-router interfaces as "r1=eth0,r2=eth1".
+func formatInterfaces(p *client.Provider) string {
+	if len(p.Interfaces) == 0 {
+		return p.Interface
+	}
+	hosts := make([]string, 0, len(p.Interfaces))
+	for host := range p.Interfaces {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	parts := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		parts = append(parts, host+"="+p.Interfaces[host])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"router-sync/pkg/client"
+)
+
+func TestProvidersUpdate_ChangesOnlyGivenFlags(t *testing.T) {
+	stored := client.Provider{
+		ID: "starlink", Name: "starlink", TableID: 200, Gateway: "192.168.100.1",
+		Interfaces: map[string]string{"r1": "enp2s0"}, Description: "dish", Generation: 4,
+	}
+	var put client.Provider
+	var ifMatch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/providers/starlink" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(stored)
+		case http.MethodPut:
+			ifMatch = r.Header.Get("If-Match")
+			_ = json.NewDecoder(r.Body).Decode(&put)
+			_ = json.NewEncoder(w).Encode(put)
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetArgs([]string{"--server", srv.URL, "providers", "update", "starlink", "--gateway", "192.168.100.254"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("providers update error = %v", err)
+	}
+
+	if put.Gateway != "192.168.100.254" {
+		t.Errorf("gateway = %q, want the flag value", put.Gateway)
+	}
+	if put.TableID != 200 || put.Description != "dish" || put.Interfaces["r1"] != "enp2s0" {
+		t.Errorf("fields without a flag changed: %+v", put)
+	}
+	if ifMatch != `"4"` {
+		t.Errorf("If-Match = %q, want the fetched generation", ifMatch)
+	}
+}
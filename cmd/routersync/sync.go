@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func newSyncCommand(opts *globalOptions) *cobra.Command {
+	var syncOpts client.SyncOptions
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Run a full sync on the routers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			results, err := opts.client().Sync(cmd.Context(), syncOpts)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, results, func(wide bool) ([]string, [][]string) {
+				header := []string{"ROUTER", "RESULT", "RULES +/-", "ROUTES +/-", "DURATION", "ERROR"}
+				if wide {
+					header = append(header, "SYNC ID")
+				}
+				rows := make([][]string, 0, len(results))
+				for _, r := range results {
+					row := syncRow(r)
+					if wide {
+						syncID := "-"
+						if r.Result != nil {
+							syncID = r.Result.SyncID
+						}
+						row = append(row, syncID)
+					}
+					rows = append(rows, row)
+				}
+				return header, rows
+			})
+		},
+	}
+	cmd.Flags().StringVar(&syncOpts.Router, "router", "", "Only sync this router")
+	cmd.Flags().BoolVar(&syncOpts.Async, "async", false, "Return once the syncs are queued")
+	_ = cmd.RegisterFlagCompletionFunc("router", completeRouters(opts))
+	cmd.AddCommand(newSyncStatusCommand(opts))
+	return cmd
+}
+
+func newSyncStatusCommand(opts *globalOptions) *cobra.Command {
+	var router string
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the last full sync of each router",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			statuses, err := opts.client().SyncStatus(cmd.Context(), router)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, statuses, func(wide bool) ([]string, [][]string) {
+				header := []string{"ROUTER", "LAST SYNC", "RESULT", "RULES +/-/SKIPPED", "DURATION", "POLICY ERRORS"}
+				if wide {
+					header = append(header, "TRIGGER", "SYNC ID")
+				}
+				rows := make([][]string, 0, len(statuses))
+				for _, st := range statuses {
+					row := syncStatusRow(st)
+					if wide {
+						if res := st.LastSync; res != nil {
+							row = append(row, res.Trigger, res.SyncID)
+						} else {
+							row = append(row, "-", "-")
+						}
+					}
+					rows = append(rows, row)
+				}
+				return header, rows
+			})
+		},
+	}
+	cmd.Flags().StringVar(&router, "router", "", "Only this router's status")
+	_ = cmd.RegisterFlagCompletionFunc("router", completeRouters(opts))
+	return cmd
+}
+
+// syncStatusRow summarizes one router's last sync for the table output.
+func syncStatusRow(st client.SyncStatus) []string {
+	res := st.LastSync
+	if res == nil {
+		return []string{st.Hostname, "never", "-", "-", "-", strconv.Itoa(len(st.PolicyErrors))}
+	}
+	status := "failed"
+	switch {
+	case res.Maintenance:
+		status = "maintenance"
+	case res.Success:
+		status = "ok"
+	}
+	age := time.Since(res.StartedAt).Round(time.Second)
+	return []string{
+		st.Hostname,
+		age.String() + " ago",
+		status,
+		fmt.Sprintf("+%d/-%d/%d", res.RulesAdded, res.RulesRemoved, res.RulesSkipped),
+		(time.Duration(res.DurationMs) * time.Millisecond).String(),
+		strconv.Itoa(len(st.PolicyErrors)),
+	}
+}
+
+// syncRow summarizes one router's sync for the table output.
+func syncRow(r client.RouterSync) []string {
+	res := r.Result
+	if res == nil {
+		return []string{r.Hostname, "unreachable", "-", "-", "-", orDash(r.Error)}
+	}
+	status := "failed"
+	switch {
+	case res.Running:
+		return []string{r.Hostname, "started", "-", "-", "-", "-"}
+	case res.Maintenance:
+		status = "maintenance"
+	case res.Success:
+		status = "ok"
+	}
+	errText := "-"
+	if len(res.Errors) > 0 {
+		errText = strings.Join(res.Errors, "; ")
+	}
+	return []string{
+		r.Hostname,
+		status,
+		fmt.Sprintf("+%d/-%d", res.RulesAdded, res.RulesRemoved),
+		fmt.Sprintf("+%d/-%d", res.RoutesAdded, res.RoutesRemoved),
+		(time.Duration(res.DurationMs) * time.Millisecond).String(),
+		errText,
+	}
+}
+
+func newStatusCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show configuration counts and router heartbeats",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			stats, err := opts.client().Stats(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if structured(opts.output) {
+				return printStructured(cmd.OutOrStdout(), opts.output, stats)
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "API version: %s\n", stats.Version)
+			fmt.Fprintf(w, "Providers:   %d\n", stats.Sync.ProvidersCount)
+			fmt.Fprintf(w, "Policies:    %d (%d enabled)\n\n", stats.Sync.PoliciesCount, stats.Sync.EnabledPoliciesCount)
+			header := []string{"ROUTER", "AGENT", "LAST SEEN", "RULES", "MAINTENANCE"}
+			wide := opts.output == outputWide
+			if wide {
+				header = append(header, "INTERFACES", "TABLES", "ROUTES", "LOG LEVEL")
+			}
+			rows := make([][]string, 0, len(stats.Routers))
+			for _, r := range stats.Routers {
+				age := time.Duration(r.AgeSeconds * float64(time.Second)).Round(time.Second)
+				row := []string{r.Hostname, orDash(r.AgentVersion), age.String(), strconv.Itoa(r.ManagedRules), strconv.FormatBool(r.Maintenance)}
+				if wide {
+					row = append(row, strconv.Itoa(r.Interfaces), strconv.Itoa(r.Tables), strconv.Itoa(r.Routes), orDash(r.LogLevel))
+				}
+				rows = append(rows, row)
+			}
+			return printTable(w, header, rows)
+		},
+	}
+}
+
+func newDiffCommand(opts *globalOptions) *cobra.Command {
+	var router string
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show changes the agents have not applied yet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			diffs, err := opts.client().Diff(cmd.Context(), router)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), opts.output, diffs, func(wide bool) ([]string, [][]string) {
+				header := []string{"ROUTER", "ACTION", "KIND", "SOURCE", "DESTINATION", "TABLE", "POLICY"}
+				if wide {
+					header = append(header, "PRIORITY", "EXPECTED TABLE", "EXPECTED PRIORITY", "RULE ACTION")
+				}
+				var rows [][]string
+				for _, d := range diffs {
+					if d.InSync {
+						row := []string{d.Hostname, "in-sync", "-", "-", "-", "-", "-"}
+						if wide {
+							row = append(row, "-", "-", "-", "-")
+						}
+						rows = append(rows, row)
+						continue
+					}
+					for _, ch := range d.Changes {
+						row := []string{d.Hostname, ch.Action, ch.Kind, orDash(ch.Source), orDash(ch.Destination), strconv.Itoa(ch.Table), orDash(ch.PolicyID)}
+						if wide {
+							row = append(row, nonZero(int64(ch.Priority)), nonZero(int64(ch.ExpectedTable)), nonZero(int64(ch.ExpectedPriority)), orDash(ch.RuleAction))
+						}
+						rows = append(rows, row)
+					}
+				}
+				return header, rows
+			})
+		},
+	}
+	cmd.Flags().StringVar(&router, "router", "", "Only this router's changes")
+	_ = cmd.RegisterFlagCompletionFunc("router", completeRouters(opts))
+	return cmd
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+	"router-sync/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+func newTopCommand(opts *globalOptions) *cobra.Command {
+	var (
+		interval time.Duration
+		events   int
+		once     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Live dashboard of providers, policies and events",
+		Long: `Redraw a dashboard every --interval: provider health on each router (link
+state from the heartbeats, plus latency once health probes report it),
+policies with their applied status and current provider, and the latest
+events from the API's event stream. Press Ctrl-C to quit.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			t := &topView{client: opts.client(), server: opts.server, maxEvents: events}
+			w := cmd.OutOrStdout()
+			if once {
+				return t.draw(cmd.Context(), w, false)
+			}
+
+			go t.follow(cmd.Context())
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := t.draw(cmd.Context(), w, true); err != nil {
+					return err
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "Refresh interval")
+	cmd.Flags().IntVar(&events, "events", 10, "Number of recent events to show")
+	cmd.Flags().BoolVar(&once, "once", false, "Print one snapshot without clearing the screen and exit")
+	return cmd
+}
+
+// topView holds the state of the dashboard between redraws: the recent
+// events and the latest latency each router reported per provider.
+type topView struct {
+	client    *client.Client
+	server    string
+	maxEvents int
+
+	mu        sync.Mutex
+	events    []*client.Event
+	latency   map[string]map[string]float64 // provider ID -> hostname -> ms
+	streamErr string
+}
+
+// follow keeps the event stream open until ctx is done, reconnecting after
+// errors.
+func (t *topView) follow(ctx context.Context) {
+	for {
+		err := t.client.StreamEvents(ctx, client.StreamOptions{}, t.record)
+		if ctx.Err() != nil {
+			return
+		}
+		t.mu.Lock()
+		if err != nil {
+			t.streamErr = err.Error()
+		} else {
+			t.streamErr = "event stream closed"
+		}
+		t.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (t *topView) record(ev *client.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streamErr = ""
+	t.events = append(t.events, ev)
+	if len(t.events) > t.maxEvents {
+		t.events = t.events[len(t.events)-t.maxEvents:]
+	}
+	if ev.Type != models.EventProviderHealth || ev.Resource == "" {
+		return
+	}
+	if ms, ok := ev.Data["latency_ms"].(float64); ok {
+		if t.latency == nil {
+			t.latency = make(map[string]map[string]float64)
+		}
+		if t.latency[ev.Resource] == nil {
+			t.latency[ev.Resource] = make(map[string]float64)
+		}
+		t.latency[ev.Resource][ev.Hostname] = ms
+	}
+}
+
+// draw fetches the current providers, policies and routers and writes one
+// frame. Fetch errors are shown in the frame rather than ending the command.
+func (t *topView) draw(ctx context.Context, w io.Writer, clear bool) error {
+	var buf bytes.Buffer
+	providers, perr := t.client.ListProviders(ctx, client.ListOptions{})
+	policies, polErr := t.client.ListPolicies(ctx, client.ListOptions{})
+	routers, rerr := t.client.ListRouters(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	online := 0
+	for _, r := range routers {
+		if r.Online {
+			online++
+		}
+	}
+	fmt.Fprintf(&buf, "routersync top - %s - %s - routers %d/%d online\n\n",
+		t.server, time.Now().Format("15:04:05"), online, len(routers))
+	for _, err := range []error{perr, polErr, rerr} {
+		if err != nil {
+			fmt.Fprintf(&buf, "error: %v\n", err)
+		}
+	}
+
+	t.mu.Lock()
+	sort.Slice(providers, func(i, j int) bool { return providers[i].ID < providers[j].ID })
+	rows := make([][]string, 0, len(providers))
+	for _, p := range providers {
+		applied := "-"
+		if p.Status != nil {
+			applied = strconv.FormatBool(p.Status.Applied)
+		}
+		rows = append(rows, []string{p.ID, strconv.Itoa(p.TableID), orDash(t.providerHealth(p, routers)), applied})
+	}
+	streamErr := t.streamErr
+	events := append([]*client.Event(nil), t.events...)
+	t.mu.Unlock()
+
+	buf.WriteString("PROVIDERS\n")
+	_ = printTable(&buf, []string{"ID", "TABLE", "HEALTH", "APPLIED"}, rows)
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	rows = rows[:0]
+	for _, p := range policies {
+		applied, current := "-", ""
+		if p.Status != nil {
+			applied = strconv.FormatBool(p.Status.Applied)
+			current = p.Status.CurrentProviderID
+		}
+		rows = append(rows, []string{p.Name, p.SourceIP, orDash(p.Destination), p.ProviderID, orDash(current), strconv.FormatBool(p.Enabled), applied})
+	}
+	buf.WriteString("\nPOLICIES\n")
+	_ = printTable(&buf, []string{"NAME", "SOURCE", "DESTINATION", "PROVIDER", "CURRENT", "ENABLED", "APPLIED"}, rows)
+
+	buf.WriteString("\nEVENTS\n")
+	if streamErr != "" {
+		fmt.Fprintf(&buf, "(%s; reconnecting)\n", streamErr)
+	}
+	rows = rows[:0]
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		rows = append(rows, []string{ev.Timestamp.Local().Format("15:04:05"), ev.Type, orDash(ev.Hostname), ev.Message})
+	}
+	_ = printTable(&buf, []string{"TIME", "TYPE", "ROUTER", "MESSAGE"}, rows)
+
+	if clear {
+		if _, err := io.WriteString(w, clearScreen); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// providerHealth summarizes p on each router with an interface for it as
+// "r1:up 12ms r2:down". Link state comes from the router's heartbeat (up
+// with carrier), latency from the latest provider.health event. Callers hold
+// t.mu.
+func (t *topView) providerHealth(p *client.Provider, routers []client.Router) string {
+	var parts []string
+	for _, r := range routers {
+		name := p.InterfaceForHost(r.Hostname)
+		if name == "" {
+			continue
+		}
+		state := "missing"
+		for _, iface := range r.Interfaces {
+			if iface.Name == name {
+				state = "down"
+				if iface.Up && iface.Carrier {
+					state = "up"
+				}
+				break
+			}
+		}
+		if !r.Online {
+			state = "stale"
+		}
+		part := r.Hostname + ":" + state
+		if ms, ok := t.latency[p.ID][r.Hostname]; ok && state == "up" {
+			part += fmt.Sprintf(" %.0fms", ms)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,29 @@
+package docs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReadDocAppliesSwaggerInfo(t *testing.T) {
+	saved := *SwaggerInfo
+	defer func() { *SwaggerInfo = saved }()
+
+	SwaggerInfo.Host = ""
+	SwaggerInfo.Version = "1.2.3"
+	SwaggerInfo.Schemes = []string{"https"}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(embeddedSpec{}.ReadDoc()), &doc); err != nil {
+		t.Fatalf("ReadDoc returned invalid JSON: %v", err)
+	}
+	if _, ok := doc["host"]; ok {
+		t.Errorf("host = %v, want it dropped when SwaggerInfo.Host is empty", doc["host"])
+	}
+	if got := doc["info"].(map[string]interface{})["version"]; got != "1.2.3" {
+		t.Errorf("info.version = %v, want 1.2.3", got)
+	}
+	if got := doc["schemes"].([]interface{}); len(got) != 1 || got[0] != "https" {
+		t.Errorf("schemes = %v, want [https]", got)
+	}
+}
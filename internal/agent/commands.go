@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+	"router-sync/internal/state"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errStopping answers commands that cannot finish because the agent is
+// shutting down.
+var errStopping = errors.New("agent is stopping")
+
+// serveCommands answers API requests on this agent's NATS command subject.
+func (s *Service) serveCommands() {
+	defer s.wg.Done()
+	s.setWatcherAlive(watcherCommands, true)
+	defer s.setWatcherAlive(watcherCommands, false)
+
+	if err := s.natsClient.ServeAgentCommands(s.ctx, s.hostname, s.handleCommand); err != nil {
+		logrus.Errorf("Agent command listener error: %v", err)
+	}
+}
+
+func (s *Service) handleCommand(cmd *models.AgentCommand) *models.AgentCommandResult {
+	logrus.Debugf("Received agent command %s %v (requested by %q)", cmd.Command, cmd.Args, cmd.RequestedBy)
+
+	var (
+		data interface{}
+		err  error
+	)
+	switch cmd.Command {
+	case models.CommandConntrackList:
+		data, err = s.conntrackList(cmd.Args["src"])
+	case models.CommandConntrackFlush:
+		if s.InMaintenance() {
+			err = errMaintenance
+			break
+		}
+		data, err = s.conntrackFlush(cmd.Args["src"])
+	case models.CommandGatewaySuggest:
+		data, err = state.SuggestGateway(cmd.Args["interface"])
+	case models.CommandRulesCleanup:
+		if s.InMaintenance() {
+			err = errMaintenance
+			break
+		}
+		data, err = s.cleanupRules(cmd.Args["tables"] == "true", cmd.Args["resync"] != "false")
+	case models.CommandStateCollect:
+		data, err = s.collectState()
+	case models.CommandSyncPlan:
+		data, err = s.planSync()
+	case models.CommandSyncRun:
+		data, err = s.runSync(cmd)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd.Command)
+	}
+
+	result := &models.AgentCommandResult{Command: cmd.Command}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to encode result: %v", err)
+		return result
+	}
+	result.OK = true
+	result.Data = raw
+	return result
+}
+
+func (s *Service) conntrackList(src string) ([]models.ConntrackFlow, error) {
+	srcNet, err := models.ParseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	flows, err := s.routerManager.ListConntrack(srcNet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attribute each flow to the uplink it leaves through: the reply
+	// destination is the post-NAT local address of the egress interface.
+	ifaceByAddr := localAddresses()
+	s.cacheMu.RLock()
+	providerByIface := make(map[string]string, len(s.providers))
+	for _, p := range s.providers {
+		if iface := p.InterfaceForHost(s.hostname); iface != "" {
+			providerByIface[iface] = p.ID
+		}
+	}
+	s.cacheMu.RUnlock()
+
+	for i := range flows {
+		if iface, ok := ifaceByAddr[flows[i].ReplyDst]; ok {
+			flows[i].Interface = iface
+			flows[i].ProviderID = providerByIface[iface]
+		}
+	}
+	return flows, nil
+}
+
+func (s *Service) conntrackFlush(src string) (*models.ConntrackFlushResult, error) {
+	srcNet, err := models.ParseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.routerManager.FlushConntrack(srcNet)
+	if err != nil {
+		return nil, err
+	}
+	s.observeRouterCounters()
+	return &models.ConntrackFlushResult{Source: srcNet.String(), Deleted: deleted}, nil
+}
+
+// cleanupRules removes every managed rule (and optionally orphaned tables),
+// then re-applies the current policies so clients are only briefly affected.
+func (s *Service) cleanupRules(tables, resync bool) (*models.CleanupResult, error) {
+	removed, err := s.routerManager.CleanupAllRules()
+	if err != nil {
+		return nil, fmt.Errorf("rule cleanup failed: %w", err)
+	}
+	result := &models.CleanupResult{RulesRemoved: removed, TablesFlushed: []int{}}
+
+	if tables {
+		s.cacheMu.RLock()
+		keep := make(map[int]bool, len(s.providers))
+		for _, p := range s.providers {
+			keep[p.TableID] = true
+		}
+		s.cacheMu.RUnlock()
+
+		flushed, err := s.routerManager.CleanupOrphanedTables(keep)
+		if err != nil {
+			return nil, fmt.Errorf("table cleanup failed: %w", err)
+		}
+		if flushed != nil {
+			result.TablesFlushed = flushed
+		}
+	}
+
+	if resync {
+		if err := s.performFullSync(syncTriggerCleanup); err != nil {
+			result.ResyncError = err.Error()
+		} else {
+			result.Resynced = true
+		}
+	}
+	logrus.Warnf("Rule cleanup on request: removed %d rules, flushed tables %v, resynced=%t", result.RulesRemoved, result.TablesFlushed, result.Resynced)
+	return result, nil
+}
+
+// planSync returns the changes a full sync of the current providers and
+// policies would make, see router.Manager.Plan. It runs in maintenance mode
+// too, since nothing is changed.
+func (s *Service) planSync() (*models.SyncPlan, error) {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	_, policies, _ = s.resolvePolicies(policies)
+
+	changes, err := s.routerManager.Plan(providers, policies, s.failoverCopy())
+	plan := &models.SyncPlan{Changes: changes}
+	if err != nil {
+		plan.Error = err.Error()
+	}
+	logrus.Infof("Sync plan on request: %d changes", len(plan.Changes))
+	return plan, nil
+}
+
+// pendingSync is a sync requested with sync.run that periodicSync has not
+// started yet. Requests made meanwhile join it rather than queue another
+// sync, and all of them get its result on their waiters.
+type pendingSync struct {
+	syncID  string
+	waiters []chan *models.SyncResult
+}
+
+// requestSync asks periodicSync for a full sync, joining the pending one if
+// any, and returns its sync ID. With wait the result is sent on the returned
+// channel once the sync finishes; otherwise the channel is nil.
+func (s *Service) requestSync(wait bool) (string, <-chan *models.SyncResult) {
+	s.syncReqMu.Lock()
+	defer s.syncReqMu.Unlock()
+	if s.pendingSync == nil {
+		s.pendingSync = &pendingSync{syncID: newSyncID()}
+		select {
+		case s.syncRequested <- struct{}{}:
+		default:
+		}
+	}
+	var done chan *models.SyncResult
+	if wait {
+		done = make(chan *models.SyncResult, 1)
+		s.pendingSync.waiters = append(s.pendingSync.waiters, done)
+	}
+	return s.pendingSync.syncID, done
+}
+
+// runRequestedSync runs the pending requested sync and hands its result to
+// whoever waits for it.
+func (s *Service) runRequestedSync() {
+	s.syncReqMu.Lock()
+	p := s.pendingSync
+	s.pendingSync = nil
+	s.syncReqMu.Unlock()
+	if p == nil {
+		return
+	}
+
+	result, _ := s.runFullSync(p.syncID, syncTriggerRequest)
+	for _, done := range p.waiters {
+		done <- result
+	}
+}
+
+// runSync has periodicSync run a full sync on request and answers with its
+// result, or at once when the request is async. Requests that come in
+// before the sync starts share it. A failed sync is still a result, not a
+// command error: its errors are in the result.
+func (s *Service) runSync(cmd *models.AgentCommand) (*models.SyncResult, error) {
+	if s.ctx.Err() != nil {
+		return nil, errStopping
+	}
+	async := cmd.Args["async"] == "true"
+	syncID, done := s.requestSync(!async)
+	logrus.WithFields(logrus.Fields{
+		logging.FieldSyncID:    syncID,
+		logging.FieldRequestID: cmd.Args["request_id"],
+	}).Infof("Full sync requested by %q", cmd.RequestedBy)
+
+	if async {
+		return &models.SyncResult{SyncID: syncID, Trigger: syncTriggerRequest, StartedAt: time.Now().UTC(), Running: true}, nil
+	}
+	select {
+	case result := <-done:
+		return result, nil
+	case <-s.ctx.Done():
+		return nil, errStopping
+	}
+}
+
+// localAddresses maps every local IP address to its interface name.
+func localAddresses() map[string]string {
+	out := make(map[string]string)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return out
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				out[ipnet.IP.String()] = iface.Name
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSyncMergesPendingRequests(t *testing.T) {
+	s := &Service{syncRequested: make(chan struct{}, 1)}
+
+	first, done := s.requestSync(true)
+	require.NotNil(t, done)
+	second, none := s.requestSync(false)
+	assert.Nil(t, none)
+	assert.Equal(t, first, second, "a request before the sync starts joins it")
+	require.Len(t, s.syncRequested, 1)
+	require.Len(t, s.pendingSync.waiters, 1)
+
+	<-s.syncRequested
+	s.pendingSync = nil
+	third, _ := s.requestSync(false)
+	assert.NotEqual(t, first, third, "a request after the sync started gets a new one")
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"fmt"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// emit publishes an event tagged with this router's hostname. Events are
+// best-effort: a failed publish is logged and otherwise ignored.
+func (s *Service) emit(ev *models.Event) {
+	ev.Hostname = s.hostname
+	if err := s.publishEvent(ev); err != nil {
+		logrus.Debugf("Failed to publish %s event: %v", ev.Type, err)
+	}
+}
+
+// checkProviderHealth compares each provider interface's link state with the
+// previous heartbeat and emits provider.health on transitions.
+func (s *Service) checkProviderHealth(st *models.RouterState) {
+	up := make(map[string]bool, len(st.Interfaces))
+	for _, iface := range st.Interfaces {
+		up[iface.Name] = iface.Up
+	}
+
+	s.cacheMu.RLock()
+	providers := make([]*models.InternetProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	s.cacheMu.RUnlock()
+
+	seen := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		iface := p.InterfaceForHost(s.hostname)
+		if iface == "" {
+			continue
+		}
+		seen[p.ID] = true
+		isUp, exists := up[iface]
+		healthy := exists && isUp
+
+		prev, known := s.providerHealthy[p.ID]
+		s.providerHealthy[p.ID] = healthy
+		if known && prev == healthy {
+			continue
+		}
+		if !known && healthy {
+			// Don't announce every provider as "up" on agent start.
+			continue
+		}
+
+		status := "down"
+		if healthy {
+			status = "up"
+		}
+		s.emit(&models.Event{
+			Type:     models.EventProviderHealth,
+			Resource: p.ID,
+			Message:  fmt.Sprintf("provider %s is %s on %s (interface %s)", p.Name, status, s.hostname, iface),
+			Data: map[string]interface{}{
+				"status":    status,
+				"interface": iface,
+				"present":   exists,
+			},
+		})
+	}
+	for id := range s.providerHealthy {
+		if !seen[id] {
+			delete(s.providerHealthy, id)
+		}
+	}
+}
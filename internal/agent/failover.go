@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// providersDown returns the providers whose health check is failing on this
+// router. Without features.failover nothing is down.
+func (s *Service) providersDown() map[string]bool {
+	if s.health == nil {
+		return nil
+	}
+	down := make(map[string]bool)
+	for id, st := range s.health.Statuses() {
+		if !st.Up {
+			down[id] = true
+		}
+	}
+	return down
+}
+
+// providerHealth returns the health check result of each checked provider,
+// for the router state.
+func (s *Service) providerHealth() map[string]bool {
+	if s.health == nil {
+		return nil
+	}
+	out := make(map[string]bool)
+	for id, st := range s.health.Statuses() {
+		out[id] = st.Up
+	}
+	return out
+}
+
+// planFailoverLocked plans the failover of the cached policies over the
+// providers with an interface here (see models.PlanFailover), stores it and
+// returns it. Delegated source policies without a prefix have no rule to
+// move. cacheMu must be held.
+func (s *Service) planFailoverLocked() map[string]string {
+	providers := make([]*models.InternetProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		if p.InterfaceForHost(s.hostname) != "" {
+			providers = append(providers, p)
+		}
+	}
+	policies := make([]*models.RoutingPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		if p.SourceIP != "" {
+			policies = append(policies, p)
+		}
+	}
+	old := s.failover
+	s.failover = models.PlanFailover(policies, providers, s.providersDown())
+	s.reportFailoverLocked(old, s.failover)
+	return s.failover
+}
+
+// reportFailoverLocked logs and announces the policies whose provider
+// changed between two plans and updates the failover metric. cacheMu must
+// be held.
+func (s *Service) reportFailoverLocked(old, plan map[string]string) {
+	ids := make([]string, 0, len(old)+len(plan))
+	for id := range old {
+		ids = append(ids, id)
+	}
+	for id := range plan {
+		if _, ok := old[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		before, wasMoved := old[id]
+		after, moved := plan[id]
+		if wasMoved == moved && before == after {
+			continue
+		}
+		policy, ok := s.policies[id]
+		if !ok {
+			continue
+		}
+		from, to := policy.ProviderID, policy.ProviderID
+		if wasMoved {
+			from = orNone(before)
+		}
+		if moved {
+			to = orNone(after)
+		}
+		logrus.Warnf("Failover: policy %s moves from %s to %s", policy.Name, from, to)
+		s.emit(&models.Event{
+			Type:     models.EventPolicyFailover,
+			Resource: id,
+			Message:  fmt.Sprintf("policy %s moved from %s to %s on %s", policy.Name, from, to, s.hostname),
+			Data: map[string]interface{}{
+				"provider_id": policy.ProviderID,
+				"from":        from,
+				"to":          to,
+				"strict":      policy.Strict(),
+			},
+		})
+	}
+
+	s.failoverPolicies.Reset()
+	for _, target := range plan {
+		s.failoverPolicies.WithLabelValues(orNone(target)).Inc()
+	}
+}
+
+// failoverCopy returns the current failover plan for the router state.
+func (s *Service) failoverCopy() map[string]string {
+	s.cacheMu.RLock()
+	defer s.cacheMu.RUnlock()
+	if len(s.failover) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(s.failover))
+	for id, target := range s.failover {
+		out[id] = target
+	}
+	return out
+}
+
+// wakeFailover asks watchFailover for a full sync; called on health
+// transitions, which must not wait for the sync.
+func (s *Service) wakeFailover() {
+	select {
+	case s.failoverWake <- struct{}{}:
+	default:
+	}
+}
+
+// watchFailover runs a full sync whenever a provider's health changes, so
+// the policies move to their backups, or back, within one probe round rather
+// than at the next periodic sync.
+func (s *Service) watchFailover() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.failoverWake:
+			if err := s.performFullSync(syncTriggerFailover); err != nil {
+				logrus.Errorf("Sync after provider health change failed: %v", err)
+			}
+		}
+	}
+}
+
+// failoverPoliciesLocked applies the current failover plan to policies:
+// moved ones are copied onto the provider carrying them, the ones no provider
+// can take are left out. cacheMu must be held.
+func (s *Service) failoverPoliciesLocked(policies []*models.RoutingPolicy) []*models.RoutingPolicy {
+	out := make([]*models.RoutingPolicy, 0, len(policies))
+	for _, p := range policies {
+		providerID, ok := p.FailoverProvider(s.failover)
+		if !ok {
+			continue
+		}
+		if providerID != p.ProviderID {
+			moved := *p
+			moved.ProviderID = providerID
+			p = &moved
+		}
+		out = append(out, p)
+	}
+	return out
+}
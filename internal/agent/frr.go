@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"router-sync/internal/frr"
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// signalFRR passes a provider's new health state to FRR. It is a no-op
+// without agent.frr, and in maintenance mode, where the next full sync after
+// it ends catches up (see reconcileFRR).
+func (s *Service) signalFRR(providerID string, up bool) {
+	if s.frr == nil || s.InMaintenance() {
+		return
+	}
+	s.cacheMu.RLock()
+	p, ok := s.providers[providerID]
+	s.cacheMu.RUnlock()
+	if !ok {
+		return
+	}
+	if ran, err := s.frr.Signal(p, up); ran {
+		s.observeFRR(p, up, err)
+	}
+}
+
+// reconcileFRR signals the current health of every provider FRR has not
+// seen in that state yet: after an agent restart, a failed vtysh run or
+// maintenance mode.
+func (s *Service) reconcileFRR() {
+	if s.frr == nil || s.health == nil {
+		return
+	}
+	s.cacheMu.RLock()
+	providers := make(map[string]*models.InternetProvider, len(s.providers))
+	for id, p := range s.providers {
+		providers[id] = p
+	}
+	s.cacheMu.RUnlock()
+
+	up := make(map[string]bool)
+	for id, st := range s.health.Statuses() {
+		up[id] = st.Up
+	}
+	s.frr.Forget(providers)
+	s.frr.Reconcile(providers, up, s.observeFRR)
+}
+
+// observeFRR logs and counts one vtysh run.
+func (s *Service) observeFRR(p *models.InternetProvider, up bool, err error) {
+	state := frr.State(up)
+	if err != nil {
+		s.frrUpdates.WithLabelValues(p.ID, state, "error").Inc()
+		logrus.Errorf("Failed to signal provider %s %s to FRR: %v", p.ID, state, err)
+		return
+	}
+	s.frrUpdates.WithLabelValues(p.ID, state, "ok").Inc()
+	logrus.Infof("Signalled provider %s %s to FRR", p.ID, state)
+}
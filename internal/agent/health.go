@@ -0,0 +1,64 @@
+package agent
+
+import "time"
+
+// Watcher names tracked for readiness.
+const (
+	watcherProviders = "providers_watcher"
+	watcherPolicies  = "policies_watcher"
+	watcherCommands  = "command_listener"
+)
+
+// setWatcherAlive records whether a long-running watcher goroutine is running.
+func (s *Service) setWatcherAlive(name string, alive bool) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.watchersAlive[name] = alive
+}
+
+// Readiness reports whether the agent can actually keep the router in sync:
+// NATS is connected, every watcher is running and the initial sync succeeded.
+// checks maps each condition to "ok" or a short reason; an active maintenance
+// window is reported under "maintenance" without affecting readiness.
+func (s *Service) Readiness() (bool, map[string]string) {
+	checks := make(map[string]string)
+	ready := true
+
+	if s.natsClient.Connected() {
+		checks["nats"] = "ok"
+	} else {
+		checks["nats"] = "disconnected"
+		ready = false
+	}
+
+	s.healthMu.Lock()
+	for _, name := range []string{watcherProviders, watcherPolicies, watcherCommands} {
+		if s.watchersAlive[name] {
+			checks[name] = "ok"
+		} else {
+			checks[name] = "not running"
+			ready = false
+		}
+	}
+	lastSync := s.lastSyncAt
+	s.healthMu.Unlock()
+
+	if lastSync.IsZero() {
+		checks["initial_sync"] = "pending"
+		ready = false
+	} else {
+		checks["initial_sync"] = "ok"
+		checks["last_sync"] = lastSync.UTC().Format(time.RFC3339)
+	}
+
+	if m := s.Maintenance(); m.Enabled {
+		checks["maintenance"] = "active since " + m.Since.UTC().Format(time.RFC3339)
+		if m.Reason != "" {
+			checks["maintenance"] += ": " + m.Reason
+		}
+	} else {
+		checks["maintenance"] = "off"
+	}
+
+	return ready, checks
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// publishHeartbeat publishes the fleet heartbeat. st is the router state
+// just collected for the same round, nil when it could not be read (the
+// agent then reports not ready). stopped marks the final heartbeat of a
+// clean shutdown.
+func (s *Service) publishHeartbeat(st *models.RouterState, stopped bool) {
+	hb := s.heartbeat(st, stopped)
+	if err := s.natsClient.PublishHeartbeat(hb); err != nil {
+		logrus.Warnf("Heartbeat publish failed: %v", err)
+	}
+}
+
+// heartbeat builds a heartbeat, see publishHeartbeat.
+func (s *Service) heartbeat(st *models.RouterState, stopped bool) *models.Heartbeat {
+	ready, checks := s.Readiness()
+	hb := &models.Heartbeat{
+		Hostname:        s.hostname,
+		Version:         s.agentVersion,
+		Timestamp:       time.Now().UTC(),
+		StartedAt:       s.startedAt,
+		IntervalSeconds: s.cfg.Agent.StatePublishInterval.Seconds(),
+		Stopped:         stopped,
+		Ready:           ready && st != nil && !stopped,
+		Maintenance:     s.InMaintenance(),
+		Features:        s.cfg.Features.Enabled(),
+		Checks:          checks,
+	}
+
+	s.healthMu.Lock()
+	hb.LastSyncAt = s.lastSyncAt
+	hb.ConsecutiveFailures = s.consecutiveFailures
+	s.healthMu.Unlock()
+
+	if st != nil {
+		hb.AppliedGeneration = st.AppliedGeneration
+		hb.Rules = len(st.Rules)
+		for _, r := range st.Rules {
+			if models.IsManagedPriority(r.Priority) {
+				hb.ManagedRules++
+			}
+		}
+		hb.PolicyErrors = len(st.PolicyErrors)
+	}
+
+	if s.health != nil {
+		for id, status := range s.health.Statuses() {
+			hb.Providers = append(hb.Providers, models.ProviderHealth{
+				ProviderID:      id,
+				Up:              status.Up,
+				Since:           status.Since,
+				Loss:            status.Loss,
+				Flaps:           status.Flaps,
+				DowntimeSeconds: status.Downtime.Seconds(),
+			})
+		}
+		sort.Slice(hb.Providers, func(i, j int) bool { return hb.Providers[i].ProviderID < hb.Providers[j].ProviderID })
+	}
+	return hb
+}
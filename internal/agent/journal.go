@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journalBuffer is how many kernel changes may wait to be published to the
+// journal before new ones are dropped.
+const journalBuffer = 1024
+
+// queueJournal hands a kernel change from the router manager to
+// publishJournal. It never blocks: the manager calls it with its lock held.
+func (s *Service) queueJournal(e models.JournalEntry) {
+	select {
+	case s.journal <- e:
+	default:
+		logrus.Warnf("Journal buffer full, dropping %s entry for %s", e.Action, e.Source)
+	}
+}
+
+// publishJournal records queued kernel changes in the NATS journal until the
+// service stops, then flushes what is left.
+func (s *Service) publishJournal() {
+	defer s.wg.Done()
+	for {
+		select {
+		case e := <-s.journal:
+			s.recordJournal(&e)
+		case <-s.ctx.Done():
+			for {
+				select {
+				case e := <-s.journal:
+					s.recordJournal(&e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Service) recordJournal(e *models.JournalEntry) {
+	if err := s.natsClient.RecordJournal(e); err != nil {
+		logrus.Warnf("Failed to record %s journal entry: %v", e.Action, err)
+	}
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"fmt"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errMaintenance is returned by commands that would modify the kernel while
+// maintenance mode is active.
+var errMaintenance = fmt.Errorf("maintenance mode is active: kernel changes are frozen")
+
+// InMaintenance reports whether kernel modifications are currently frozen.
+func (s *Service) InMaintenance() bool {
+	s.maintenanceMu.RLock()
+	defer s.maintenanceMu.RUnlock()
+	return s.maintenance.Enabled
+}
+
+// Maintenance returns a copy of the maintenance switch as last seen.
+func (s *Service) Maintenance() models.Maintenance {
+	s.maintenanceMu.RLock()
+	defer s.maintenanceMu.RUnlock()
+	return s.maintenance
+}
+
+// loadMaintenance reads the switch once so Start honors it before the
+// initial sync.
+func (s *Service) loadMaintenance() {
+	m, err := s.natsClient.GetMaintenance()
+	if err != nil {
+		logrus.Warnf("Failed to read maintenance state: %v", err)
+		return
+	}
+	s.setMaintenance(m)
+}
+
+// watchMaintenance follows the global maintenance switch. Lifting it runs a
+// full sync so changes accepted during maintenance are applied right away.
+func (s *Service) watchMaintenance() {
+	defer s.wg.Done()
+
+	err := s.natsClient.WatchMaintenance(s.ctx, func(m *models.Maintenance) {
+		if !s.setMaintenance(m) {
+			return
+		}
+		if m.Enabled {
+			return
+		}
+		if err := s.performFullSync(syncTriggerMaintenance); err != nil {
+			logrus.Errorf("Sync after maintenance failed: %v", err)
+		}
+	})
+	if err != nil {
+		logrus.Errorf("Maintenance watcher error: %v", err)
+	}
+}
+
+// setMaintenance stores m and reports whether the enabled state changed.
+func (s *Service) setMaintenance(m *models.Maintenance) bool {
+	s.maintenanceMu.Lock()
+	changed := s.maintenance.Enabled != m.Enabled
+	s.maintenance = *m
+	s.maintenanceMu.Unlock()
+
+	if !changed {
+		return false
+	}
+	status := "lifted"
+	if m.Enabled {
+		status = "active"
+		logrus.Warnf("Maintenance mode active (reason: %q, set by %q): kernel changes are frozen", m.Reason, m.SetBy)
+	} else {
+		logrus.Info("Maintenance mode lifted: resuming kernel changes")
+	}
+	s.emit(&models.Event{
+		Type:    models.EventMaintenance,
+		Message: "maintenance " + status + " on " + s.hostname,
+		Data: map[string]interface{}{
+			"enabled": m.Enabled,
+			"reason":  m.Reason,
+		},
+	})
+	return true
+}
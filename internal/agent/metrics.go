@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"time"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+	"router-sync/internal/nats"
+
+	natsio "github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// observeRouterCounters moves the router manager's counters into the
+// Prometheus counters.
+func (s *Service) observeRouterCounters() {
+	c := s.routerManager.TakeCounters()
+	s.rulesAdded.Add(float64(c.RulesAdded))
+	s.rulesRemoved.Add(float64(c.RulesRemoved))
+	s.ruleFailures.Add(float64(c.RuleFailures))
+	s.staleRulesRemoved.Add(float64(c.StaleRulesRemoved))
+	s.rulesSkipped.Add(float64(c.RulesSkipped))
+	s.routesAdded.Add(float64(c.RoutesAdded))
+	s.routesRemoved.Add(float64(c.RoutesRemoved))
+	s.routeFailures.Add(float64(c.RouteFailures))
+	s.conntrackClearedTot.Add(float64(c.ConntrackFlushes))
+	s.conntrackEntries.Add(float64(c.ConntrackEntriesFlushed))
+}
+
+// recordSyncOutcome updates the failure metrics after a full sync.
+func (s *Service) recordSyncOutcome(ok bool) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if ok {
+		s.consecutiveFailures = 0
+		s.lastSyncSuccess.Set(float64(time.Now().Unix()))
+	} else {
+		s.consecutiveFailures++
+		s.syncFailures.Inc()
+	}
+	s.syncConsecutiveFail.Set(float64(s.consecutiveFailures))
+}
+
+// updateKernelGauges reads the kernel state and compares it with the desired
+// providers and policies, so alerts can fire, and drift.detected is emitted,
+// when the two diverge (e.g. rules deleted by hand, or a sync that keeps
+// failing).
+func (s *Service) updateKernelGauges(providers []*models.InternetProvider, policies []*models.RoutingPolicy) {
+	st, err := s.collector.Collect()
+	if err != nil {
+		logrus.Warnf("Failed to read kernel state for drift metrics: %v", err)
+		return
+	}
+
+	managed := 0
+	for _, r := range st.Rules {
+		if models.IsManagedPriority(r.Priority) {
+			managed++
+		}
+	}
+	s.managedRules.Set(float64(managed))
+
+	d := diff.Compute(st, providers, policies)
+	s.orphanRules.Set(float64(d.Count(diff.ActionRemove, diff.KindRule)))
+	driftRules, driftRoutes := d.Count("", diff.KindRule), d.Count("", diff.KindRoute)
+	s.driftChanges.WithLabelValues(diff.KindRule).Set(float64(driftRules))
+	s.driftChanges.WithLabelValues(diff.KindRoute).Set(float64(driftRoutes))
+	s.recordDrift(driftRules, driftRoutes)
+
+	routes := make(map[int]int, len(st.Tables))
+	for _, t := range st.Tables {
+		routes[t.ID] = len(t.Routes)
+	}
+	s.providerRoutes.Reset()
+	for _, p := range providers {
+		if p.TableID > 0 && p.HasInterfaceForHost(s.hostname) {
+			s.providerRoutes.WithLabelValues(p.Name, tableIDLabel(p.TableID)).Set(float64(routes[p.TableID]))
+		}
+	}
+}
+
+// observeWatch records a KV update the provider or policy watcher applied.
+func (s *Service) observeWatch(u nats.WatchUpdate) {
+	s.watchEvents.WithLabelValues(u.Watcher, opLabel(u.Op)).Inc()
+	s.watchHandled.WithLabelValues(u.Watcher).Observe(u.Handled.Seconds())
+	if !u.Replay {
+		s.watchLag.WithLabelValues(u.Watcher).Observe(u.Lag(time.Now()).Seconds())
+	}
+}
+
+func opLabel(op natsio.KeyValueOp) string {
+	switch op {
+	case natsio.KeyValuePut:
+		return "put"
+	case natsio.KeyValueDelete:
+		return "delete"
+	case natsio.KeyValuePurge:
+		return "purge"
+	}
+	return "unknown"
+}
+
+var (
+	policyBytesDesc = prometheus.NewDesc("agent_policy_bytes_total",
+		"Bytes routed by a policy, by direction (tx from its clients, rx back to them). Needs features.nftables.",
+		[]string{"policy_id", "policy", "direction"}, nil)
+	policyPacketsDesc = prometheus.NewDesc("agent_policy_packets_total",
+		"Packets routed by a policy, by direction. Needs features.nftables.",
+		[]string{"policy_id", "policy", "direction"}, nil)
+)
+
+// trafficCollector exports the policy traffic counters read at the last
+// heartbeat, labelled with the policy's current name.
+type trafficCollector struct {
+	s *Service
+}
+
+func (c *trafficCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- policyBytesDesc
+	ch <- policyPacketsDesc
+}
+
+func (c *trafficCollector) Collect(ch chan<- prometheus.Metric) {
+	c.s.trafficMu.Lock()
+	traffic := c.s.policyTraffic
+	c.s.trafficMu.Unlock()
+
+	c.s.cacheMu.RLock()
+	defer c.s.cacheMu.RUnlock()
+	for id, t := range traffic {
+		name := id
+		if p, ok := c.s.policies[id]; ok {
+			name = p.Name
+		}
+		ch <- prometheus.MustNewConstMetric(policyBytesDesc, prometheus.CounterValue, float64(t.TxBytes), id, name, "tx")
+		ch <- prometheus.MustNewConstMetric(policyBytesDesc, prometheus.CounterValue, float64(t.RxBytes), id, name, "rx")
+		ch <- prometheus.MustNewConstMetric(policyPacketsDesc, prometheus.CounterValue, float64(t.TxPackets), id, name, "tx")
+		ch <- prometheus.MustNewConstMetric(policyPacketsDesc, prometheus.CounterValue, float64(t.RxPackets), id, name, "rx")
+	}
+}
+
+// setPolicyTraffic stores the latest accounting read.
+func (s *Service) setPolicyTraffic(traffic map[string]models.PolicyTraffic) {
+	s.trafficMu.Lock()
+	defer s.trafficMu.Unlock()
+	s.policyTraffic = traffic
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"router-sync/internal/systemd"
+
+	"github.com/sirupsen/logrus"
+)
+
+// notify passes a state to systemd when running as a Type=notify unit.
+func notify(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		logrus.Debugf("sd_notify %q failed: %v", state, err)
+	}
+}
+
+// notifySyncStatus reports the outcome of a full sync as the unit status.
+func notifySyncStatus(providers, policies int, syncErrors []string) {
+	msg := fmt.Sprintf("synced %d providers, %d policies at %s", providers, policies, time.Now().UTC().Format(time.RFC3339))
+	if len(syncErrors) > 0 {
+		msg = fmt.Sprintf("sync with %d errors at %s: %s", len(syncErrors), time.Now().UTC().Format(time.RFC3339), syncErrors[0])
+	}
+	notify(systemd.Status(msg))
+}
+
+// syncLoopAlive reports whether the sync loop finished a run recently
+// enough: within two sync intervals. A loop stuck in a run stops the
+// watchdog keepalives so systemd restarts the agent.
+func (s *Service) syncLoopAlive() bool {
+	s.healthMu.Lock()
+	last := s.syncLoopAt
+	s.healthMu.Unlock()
+	return time.Since(last) <= 2*s.cfg.Sync.Interval
+}
+
+// markSyncLoop notes that periodicSync is alive, see syncLoopAlive.
+func (s *Service) markSyncLoop() {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.syncLoopAt = time.Now()
+}
+
+// watchdog sends systemd keepalives while the sync loop is alive.
+func (s *Service) watchdog() {
+	defer s.wg.Done()
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		logrus.Infof("systemd watchdog enabled (every %s)", interval)
+	}
+	systemd.KeepAlive(s.ctx, s.syncLoopAlive)
+}
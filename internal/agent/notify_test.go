@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncLoopAlive(t *testing.T) {
+	s := &Service{cfg: config.Config{Sync: config.SyncConfig{Interval: time.Minute}}}
+	assert.False(t, s.syncLoopAlive(), "the loop has not run yet")
+
+	s.markSyncLoop()
+	assert.True(t, s.syncLoopAlive())
+
+	s.syncLoopAt = time.Now().Add(-3 * time.Minute)
+	assert.False(t, s.syncLoopAlive(), "stuck for more than two intervals")
+}
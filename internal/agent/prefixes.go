@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"sort"
+
+	"router-sync/internal/models"
+	"router-sync/internal/state"
+
+	"github.com/sirupsen/logrus"
+)
+
+// refreshDelegatedPrefixes looks up the IPv6 prefix each provider in
+// agent.delegated_prefixes currently delegates and reports whether any
+// changed. A provider whose prefix is not found has none until it shows up
+// again, so its delegated source policies lose their rules instead of
+// routing a prefix the router no longer owns.
+func (s *Service) refreshDelegatedPrefixes() bool {
+	found := make(map[string]string, len(s.cfg.Agent.DelegatedPrefixes))
+	for id, pc := range s.cfg.Agent.DelegatedPrefixes {
+		prefix, err := state.DelegatedPrefix(pc.Interface, pc.Length)
+		if err != nil {
+			logrus.Debugf("No delegated prefix for provider %s: %v", id, err)
+			continue
+		}
+		found[id] = prefix.String()
+	}
+
+	s.prefixMu.Lock()
+	old := s.delegatedPrefixes
+	s.delegatedPrefixes = found
+	s.prefixMu.Unlock()
+
+	changed := false
+	ids := make([]string, 0, len(s.cfg.Agent.DelegatedPrefixes))
+	for id := range s.cfg.Agent.DelegatedPrefixes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if old[id] == found[id] {
+			continue
+		}
+		changed = true
+		logrus.Infof("Delegated prefix of provider %s changed: %s -> %s", id, orNone(old[id]), orNone(found[id]))
+		s.emit(&models.Event{
+			Type:     models.EventPrefixChanged,
+			Resource: id,
+			Message:  "delegated prefix of " + id + " on " + s.hostname + " is now " + orNone(found[id]),
+			Data: map[string]interface{}{
+				"previous": old[id],
+				"prefix":   found[id],
+			},
+		})
+	}
+	return changed
+}
+
+// delegatedPrefixesCopy returns the prefixes last found, by provider ID.
+func (s *Service) delegatedPrefixesCopy() map[string]string {
+	s.prefixMu.RLock()
+	defer s.prefixMu.RUnlock()
+	if len(s.delegatedPrefixes) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(s.delegatedPrefixes))
+	for id, prefix := range s.delegatedPrefixes {
+		out[id] = prefix
+	}
+	return out
+}
+
+// resolvePolicies resolves the delegated source policies against the
+// current prefixes (see models.RoutingPolicy.Resolve). It returns every
+// policy for the cache, resolved where possible, the ones to apply, and the
+// resolution error of each one left out.
+func (s *Service) resolvePolicies(policies []*models.RoutingPolicy) (cached, apply []*models.RoutingPolicy, unresolved map[string]error) {
+	prefixes := s.delegatedPrefixesCopy()
+	cached = make([]*models.RoutingPolicy, 0, len(policies))
+	apply = make([]*models.RoutingPolicy, 0, len(policies))
+	for _, p := range policies {
+		resolved, err := p.Resolve(prefixes)
+		if err != nil {
+			if unresolved == nil {
+				unresolved = make(map[string]error)
+			}
+			unresolved[p.ID] = err
+			cached = append(cached, p)
+			continue
+		}
+		cached = append(cached, resolved)
+		apply = append(apply, resolved)
+	}
+	return cached, apply, unresolved
+}
+
+// watchDelegatedPrefixes re-checks the delegated prefixes whenever an IPv6
+// address changes and runs a full sync when one did, so the delegated source
+// policies follow a renumbering within seconds. Without address updates
+// (non-Linux, or the subscription failed) the periodic sync still picks the
+// change up.
+func (s *Service) watchDelegatedPrefixes() {
+	defer s.wg.Done()
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		err := state.WatchAddresses(s.ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			logrus.Warnf("Not watching delegated prefixes, periodic sync will pick up changes: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-changed:
+			if !s.refreshDelegatedPrefixes() {
+				continue
+			}
+			if err := s.performFullSync(syncTriggerPrefix); err != nil {
+				logrus.Errorf("Sync after delegated prefix change failed: %v", err)
+			}
+		}
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"router-sync/internal/health"
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// updateHealthChecks points the health monitor and the probe rules at the
+// cached providers. It is a no-op without features.failover.
+func (s *Service) updateHealthChecks() {
+	if s.health == nil {
+		return
+	}
+	s.cacheMu.RLock()
+	providers := make([]*models.InternetProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	s.cacheMu.RUnlock()
+
+	marks, rules := health.ProbeMarks(providers, s.hostname)
+	if s.InMaintenance() {
+		logrus.Debug("Maintenance mode active: keeping health probe rules")
+	} else if err := s.routerManager.SyncProbeRules(rules); err != nil {
+		logrus.Errorf("Failed to sync health probe rules: %v", err)
+	}
+	s.health.Update(providers, marks)
+}
+
+// onHealthChange announces a provider going up or down on this router,
+// counts it as a failover or failback when policies here use the provider,
+// signals it to FRR and wakes watchFailover to move the policies.
+func (s *Service) onHealthChange(providerID string, st health.Status) {
+	affected := s.activePoliciesOn(providerID)
+	status := "down"
+	if st.Up {
+		status = "up"
+	}
+	if affected > 0 {
+		if st.Up {
+			s.failbacks.WithLabelValues(providerID).Inc()
+		} else {
+			s.failovers.WithLabelValues(providerID).Inc()
+		}
+	}
+	s.emit(&models.Event{
+		Type:     models.EventProviderHealth,
+		Resource: providerID,
+		Message:  fmt.Sprintf("provider %s is %s on %s (health check)", providerID, status, s.hostname),
+		Data: map[string]interface{}{
+			"status":   status,
+			"source":   "health_check",
+			"loss":     st.Loss,
+			"error":    st.LastError,
+			"policies": affected,
+			"flaps":    st.Flaps,
+			"downtime": st.Downtime.Seconds(),
+		},
+	})
+	s.signalFRR(providerID, st.Up)
+	s.wakeFailover()
+}
+
+// activePoliciesOn counts the cached policies that are active now and use
+// providerID.
+func (s *Service) activePoliciesOn(providerID string) int {
+	now := time.Now()
+	s.cacheMu.RLock()
+	defer s.cacheMu.RUnlock()
+	n := 0
+	for _, p := range s.policies {
+		if p.ProviderID == providerID && p.Active(now) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nextScheduleChange returns the earliest time after now at which a
+// scheduled policy's window opens or closes; ok is false when no enabled
+// policy has a schedule.
+func (s *Service) nextScheduleChange(now time.Time) (next time.Time, ok bool) {
+	s.cacheMu.RLock()
+	defer s.cacheMu.RUnlock()
+	for _, p := range s.policies {
+		if p.Schedule == nil || !p.Enabled {
+			continue
+		}
+		if t, found := p.Schedule.NextChange(now); found && (!ok || t.Before(next)) {
+			next, ok = t, true
+		}
+	}
+	return next, ok
+}
+
+// wakeSchedules makes watchSchedules look at the policies again; called
+// whenever they change.
+func (s *Service) wakeSchedules() {
+	select {
+	case s.scheduleWake <- struct{}{}:
+	default:
+	}
+}
+
+// watchSchedules runs a full sync whenever a scheduled policy's window opens
+// or closes, so its rule comes and goes on time; see models.Schedule.
+func (s *Service) watchSchedules() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		next, ok := s.nextScheduleChange(time.Now())
+		if ok {
+			logrus.Debugf("Next policy schedule change at %s", next.Format(time.RFC3339))
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.scheduleWake:
+		case <-timer.C:
+			logrus.Info("Policy schedule window changed: running full sync")
+			if err := s.performFullSync(syncTriggerSchedule); err != nil {
+				logrus.Errorf("Sync after policy schedule change failed: %v", err)
+			}
+		}
+		if ok && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
@@ -5,15 +5,21 @@ package agent
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"sync"
 	"time"
 
 	"router-sync/internal/config"
+	"router-sync/internal/frr"
+	"router-sync/internal/health"
 	"router-sync/internal/logging"
 	"router-sync/internal/models"
 	"router-sync/internal/nats"
 	"router-sync/internal/router"
 	"router-sync/internal/state"
+	"router-sync/internal/systemd"
 
 	natsio "github.com/nats-io/nats.go"
 	"github.com/prometheus/client_golang/prometheus"
@@ -29,6 +35,11 @@ type Service struct {
 	cfg           config.Config
 	hostname      string
 	agentVersion  string
+	startedAt     time.Time
+
+	// publishEvent sends the events of emit: natsClient.PublishEvent,
+	// swapped out in tests.
+	publishEvent func(*models.Event) error
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -38,13 +49,98 @@ type Service struct {
 	policies  map[string]*models.RoutingPolicy
 	cacheMu   sync.RWMutex
 
+	// appliedGeneration is reported in heartbeats; guarded by cacheMu.
+	appliedGeneration string
+
+	// providerHealthy tracks interface link state per provider between
+	// heartbeats; only touched by the publishStateLoop goroutine.
+	providerHealthy map[string]bool
+
+	// health probes the providers' uplinks; nil without features.failover.
+	health *health.Monitor
+
+	// failover is the last failover plan (see models.PlanFailover);
+	// guarded by cacheMu. failoverWake asks watchFailover for a full sync
+	// after a health change.
+	failover     map[string]string
+	failoverWake chan struct{}
+
+	// scheduleWake asks watchSchedules to look at the policies' schedules
+	// again after they changed.
+	scheduleWake chan struct{}
+
+	// syncRequested asks periodicSync to run the sync.run requests merged
+	// in pendingSync (guarded by syncReqMu), see requestSync.
+	syncRequested chan struct{}
+	syncReqMu     sync.Mutex
+	pendingSync   *pendingSync
+
+	// frr signals provider health to FRR; nil without agent.frr.
+	frr *frr.Signaler
+
+	// maintenance mirrors the global maintenance switch; while enabled the
+	// caches keep following NATS but nothing is applied to the kernel.
+	maintenanceMu sync.RWMutex
+	maintenance   models.Maintenance
+
+	healthMu      sync.Mutex
+	watchersAlive map[string]bool
+	lastSyncAt    time.Time
+	// syncLoopAt is when periodicSync last started or finished a run,
+	// failed or not; the systemd watchdog uses it to notice a wedged sync
+	// loop. Syncs run elsewhere do not touch it.
+	syncLoopAt time.Time
+	// consecutiveFailures counts full syncs that failed in a row.
+	consecutiveFailures int
+
+	statusMu sync.Mutex
+	status   applyStatus
+
+	// journal queues the router manager's kernel changes for the NATS
+	// journal, see publishJournal.
+	journal chan models.JournalEntry
+
+	// policyTraffic is the last read of the accounting counters, exported
+	// by trafficCollector.
+	trafficMu     sync.Mutex
+	policyTraffic map[string]models.PolicyTraffic
+
+	// delegatedPrefixes is the IPv6 prefix each provider in
+	// agent.delegated_prefixes delegates, by provider ID; see
+	// refreshDelegatedPrefixes.
+	prefixMu          sync.RWMutex
+	delegatedPrefixes map[string]string
+
 	syncTotal           prometheus.Counter
 	syncDuration        prometheus.Histogram
+	syncFailures        prometheus.Counter
+	syncConsecutiveFail prometheus.Gauge
+	lastSyncSuccess     prometheus.Gauge
 	rulesTotal          prometheus.Gauge
 	routesTotal         *prometheus.GaugeVec
+	managedRules        prometheus.Gauge
+	orphanRules         prometheus.Gauge
+	providerRoutes      *prometheus.GaugeVec
+	driftChanges        *prometheus.GaugeVec
+	watchEvents         *prometheus.CounterVec
+	watchLag            *prometheus.HistogramVec
+	watchHandled        *prometheus.HistogramVec
+	failovers           *prometheus.CounterVec
+	failbacks           *prometheus.CounterVec
+	failoverPolicies    *prometheus.GaugeVec
+	frrUpdates          *prometheus.CounterVec
+	rulesAdded          prometheus.Counter
+	rulesRemoved        prometheus.Counter
+	ruleFailures        prometheus.Counter
+	staleRulesRemoved   prometheus.Counter
+	rulesSkipped        prometheus.Counter
+	routesAdded         prometheus.Counter
+	routesRemoved       prometheus.Counter
+	routeFailures       prometheus.Counter
 	statePublishTotal   prometheus.Counter
 	statePublishErrors  prometheus.Counter
 	conntrackClearedTot prometheus.Counter
+	conntrackEntries    prometheus.Counter
 }
 
 // NewService creates a new agent service. The Prometheus registry is owned by main;
@@ -54,6 +150,7 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 
 	s := &Service{
 		natsClient:    natsClient,
+		publishEvent:  natsClient.PublishEvent,
 		routerManager: routerManager,
 		collector:     state.NewCollector(cfg.Agent.Hostname),
 		cfg:           cfg,
@@ -63,6 +160,17 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 		cancel:        cancel,
 		providers:     make(map[string]*models.InternetProvider),
 		policies:      make(map[string]*models.RoutingPolicy),
+
+		providerHealthy: make(map[string]bool),
+		watchersAlive:   make(map[string]bool),
+		journal:         make(chan models.JournalEntry, journalBuffer),
+		failoverWake:    make(chan struct{}, 1),
+		scheduleWake:    make(chan struct{}, 1),
+		syncRequested:   make(chan struct{}, 1),
+		status: applyStatus{
+			policyErrors:   make(map[string]string),
+			providerErrors: make(map[string]string),
+		},
 	}
 
 	s.syncTotal = prometheus.NewCounter(prometheus.CounterOpts{
@@ -74,6 +182,18 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 		Help:    "Duration of a full sync run.",
 		Buckets: prometheus.DefBuckets,
 	})
+	s.syncFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_sync_failures_total",
+		Help: "Number of full sync runs that failed or could not apply every rule.",
+	})
+	s.syncConsecutiveFail = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_sync_consecutive_failures",
+		Help: "Number of full sync runs that failed in a row; 0 after a successful run.",
+	})
+	s.lastSyncSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_last_sync_success_timestamp_seconds",
+		Help: "Unix time of the last successful full sync.",
+	})
 	s.rulesTotal = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "agent_rules_total",
 		Help: "Number of ip rules currently installed by the agent.",
@@ -82,6 +202,87 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 		Name: "agent_routes_total",
 		Help: "Number of routes per routing table.",
 	}, []string{"table"})
+	s.managedRules = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_managed_rules",
+		Help: "Number of ip rules in the managed priority range after the last full sync.",
+	})
+	s.orphanRules = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "agent_orphan_rules",
+		Help: "Number of managed ip rules without an enabled policy after the last full sync.",
+	})
+	s.providerRoutes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_provider_routes",
+		Help: "Number of routes in each provider's routing table after the last full sync.",
+	}, []string{"provider", "table"})
+	s.driftChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_drift_changes",
+		Help: "Number of differences between the desired and the kernel state after the last full sync, by kind (rule, route).",
+	}, []string{"kind"})
+	s.watchEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_watch_events_total",
+		Help: "Number of KV updates handled by the config watchers, by watcher and operation (put, delete, purge).",
+	}, []string{"watcher", "op"})
+	s.watchLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agent_watch_lag_seconds",
+		Help:    "Time from a KV write to the end of applying it on this router, for live (not replayed) updates.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"watcher"})
+	s.watchHandled = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agent_watch_event_duration_seconds",
+		Help:    "Time spent applying a KV update from a config watcher.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"watcher"})
+	if natsClient != nil {
+		natsClient.SetWatchObserver(s.observeWatch)
+	}
+	s.failovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_failovers_total",
+		Help: "Number of times the provider went down while policies on this router used it.",
+	}, []string{"provider"})
+	s.failbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_failbacks_total",
+		Help: "Number of times the provider came back up for the policies on this router that use it.",
+	}, []string{"provider"})
+	s.failoverPolicies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_failover_policies",
+		Help: "Number of policies moved off their down provider, by the provider now carrying them (none when no provider can).",
+	}, []string{"provider"})
+	s.frrUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_frr_updates_total",
+		Help: "Number of vtysh runs signalling a provider state to FRR, by provider, state (up, down) and result (ok, error).",
+	}, []string{"provider", "state", "result"})
+	s.rulesAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_rules_added_total",
+		Help: "Number of policy ip rules added.",
+	})
+	s.rulesRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_rules_removed_total",
+		Help: "Number of policy ip rules removed, stale ones included.",
+	})
+	s.ruleFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_rule_failures_total",
+		Help: "Number of ip rule adds and deletes that failed.",
+	})
+	s.staleRulesRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_stale_rules_removed_total",
+		Help: "Number of ip rules removed because no policy wants them any more.",
+	})
+	s.rulesSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_rules_skipped_total",
+		Help: "Number of policies a full sync set up no rule for because their provider is unknown or down.",
+	})
+	s.routesAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_routes_added_total",
+		Help: "Number of default routes added to provider tables.",
+	})
+	s.routesRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_routes_removed_total",
+		Help: "Number of stale default routes removed from provider tables.",
+	})
+	s.routeFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_route_failures_total",
+		Help: "Number of provider route adds and deletes that failed.",
+	})
 	s.statePublishTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "agent_state_publish_total",
 		Help: "Number of router state heartbeats published.",
@@ -94,17 +295,63 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 		Name: "agent_conntrack_cleared_total",
 		Help: "Number of conntrack flush invocations issued by the agent.",
 	})
+	s.conntrackEntries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "agent_conntrack_entries_flushed_total",
+		Help: "Number of conntrack entries deleted by the agent's flushes.",
+	})
+
+	if routerManager != nil {
+		routerManager.SetJournal(s.queueJournal)
+	}
+
+	if cfg.Features.Failover {
+		s.health = health.NewMonitor(ctx, s.hostname, reg, s.onHealthChange)
+	}
+	if cfg.Agent.FRR.Enabled {
+		if s.health == nil {
+			logrus.Warn("agent.frr is enabled but features.failover is off: no provider health to signal")
+		} else {
+			s.frr = frr.New(cfg.Agent.FRR, s.hostname)
+		}
+	}
 
 	if reg != nil {
 		reg.MustRegister(
 			s.syncTotal,
 			s.syncDuration,
+			s.syncFailures,
+			s.syncConsecutiveFail,
+			s.lastSyncSuccess,
 			s.rulesTotal,
 			s.routesTotal,
+			s.managedRules,
+			s.orphanRules,
+			s.providerRoutes,
+			s.driftChanges,
+			s.watchEvents,
+			s.watchLag,
+			s.watchHandled,
+			s.failovers,
+			s.failbacks,
+			s.failoverPolicies,
+			s.frrUpdates,
+			s.rulesAdded,
+			s.rulesRemoved,
+			s.ruleFailures,
+			s.staleRulesRemoved,
+			s.rulesSkipped,
+			s.routesAdded,
+			s.routesRemoved,
+			s.routeFailures,
 			s.statePublishTotal,
 			s.statePublishErrors,
 			s.conntrackClearedTot,
+			s.conntrackEntries,
+			&trafficCollector{s: s},
 		)
+		if natsClient != nil {
+			reg.MustRegister(natsClient)
+		}
 	}
 
 	return s
@@ -113,15 +360,32 @@ func NewService(natsClient *nats.Client, routerManager *router.Manager, cfg conf
 // Start launches the watchers and the heartbeat loop.
 func (s *Service) Start() error {
 	logrus.Infof("Starting agent service on host %q (version %s)", s.hostname, s.agentVersion)
+	s.startedAt = time.Now().UTC()
+
+	s.wg.Add(1)
+	go s.publishJournal()
+
+	s.loadMaintenance()
 
 	// Install the priority-10 "lookup main + suppress_prefixlength 0" rule
 	// so local LAN traffic always resolves via the main table while only
 	// default-route traffic falls through to the per-source policy rules.
-	if err := s.routerManager.EnsureSuppressDefaultRule(); err != nil {
+	if s.InMaintenance() {
+		logrus.Warn("Maintenance mode active: skipping suppress-default rule installation")
+	} else if err := s.routerManager.EnsureSuppressDefaultRule(); err != nil {
 		logrus.Errorf("Failed to install suppress-default rule: %v", err)
 	}
 
-	if err := s.performFullSync(); err != nil {
+	if s.cfg.Agent.OnStart == config.StartPurge && !s.InMaintenance() {
+		removed, err := s.routerManager.CleanupAllRules()
+		if err != nil {
+			logrus.Errorf("Failed to purge managed rules on start: %v", err)
+		} else {
+			logrus.Infof("Purged %d managed rules on start (agent.on_start: purge)", removed)
+		}
+	}
+
+	if err := s.performFullSync(syncTriggerStart); err != nil {
 		logrus.Errorf("Initial sync failed: %v", err)
 	}
 
@@ -137,9 +401,32 @@ func (s *Service) Start() error {
 	s.wg.Add(1)
 	go s.publishStateLoop()
 
+	s.wg.Add(1)
+	go s.watchMaintenance()
+
 	s.wg.Add(1)
 	go s.watchLogLevel()
 
+	s.wg.Add(1)
+	go s.serveCommands()
+
+	s.wg.Add(1)
+	go s.watchdog()
+
+	s.wg.Add(1)
+	go s.watchSchedules()
+
+	if len(s.cfg.Agent.DelegatedPrefixes) > 0 {
+		s.wg.Add(1)
+		go s.watchDelegatedPrefixes()
+	}
+
+	if s.health != nil {
+		s.wg.Add(1)
+		go s.watchFailover()
+	}
+
+	notify(systemd.Ready)
 	logrus.Info("Agent service started")
 	return nil
 }
@@ -147,74 +434,194 @@ func (s *Service) Start() error {
 // Stop terminates all goroutines and waits for them to exit.
 func (s *Service) Stop() error {
 	logrus.Info("Stopping agent service")
+	notify(systemd.Stopping)
 	s.cancel()
+	if s.health != nil {
+		s.health.Stop()
+	}
 	s.wg.Wait()
 	logrus.Info("Agent service stopped")
 	return nil
 }
 
-// periodicSync re-applies provider+policy state every config.Sync.Interval.
+// periodicSync re-applies provider+policy state every config.Sync.Interval,
+// and runs the syncs requested with sync.run in between.
 func (s *Service) periodicSync() {
 	defer s.wg.Done()
 
 	ticker := time.NewTicker(s.cfg.Sync.Interval)
 	defer ticker.Stop()
 
+	s.markSyncLoop()
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := s.performFullSync(); err != nil {
+			if err := s.performFullSync(syncTriggerInterval); err != nil {
 				logrus.Errorf("Periodic sync failed: %v", err)
 			}
+		case <-s.syncRequested:
+			s.runRequestedSync()
 		}
+		s.markSyncLoop()
 	}
 }
 
-func (s *Service) performFullSync() error {
+// Sync triggers say what started a full sync; they are logged and sent with
+// the sync.completed event next to its sync ID.
+const (
+	syncTriggerStart       = "start"
+	syncTriggerInterval    = "interval"
+	syncTriggerCleanup     = "cleanup"
+	syncTriggerFailover    = "failover"
+	syncTriggerMaintenance = "maintenance"
+	syncTriggerPrefix      = "prefix"
+	syncTriggerSchedule    = "schedule"
+	syncTriggerRequest     = "request"
+)
+
+// newSyncID returns a random ID for a full sync; its log lines and journal
+// entries carry it as sync_id.
+func newSyncID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// performFullSync reconciles the kernel with every provider and policy in
+// NATS; trigger says why (see syncTriggerStart and the others).
+func (s *Service) performFullSync(trigger string) error {
+	_, err := s.runFullSync(newSyncID(), trigger)
+	return err
+}
+
+// runFullSync is performFullSync for the sync syncID, returning what it did.
+// The result is set even when err is.
+func (s *Service) runFullSync(syncID, trigger string) (result *models.SyncResult, err error) {
 	start := time.Now()
+	result = &models.SyncResult{SyncID: syncID, Trigger: trigger, StartedAt: start.UTC()}
+	log := logrus.WithField(logging.FieldSyncID, syncID)
+	var syncErrors []string
+	var counters router.Counters
+	synced := false
 	defer func() {
 		s.syncTotal.Inc()
 		s.syncDuration.Observe(time.Since(start).Seconds())
+		s.observeRouterCounters()
+		result.Success = err == nil && len(syncErrors) == 0 && counters.RuleFailures == 0 && counters.RouteFailures == 0
+		s.recordSyncOutcome(result.Success)
+		result.DurationMs = time.Since(start).Milliseconds()
+		result.RulesAdded, result.RulesRemoved, result.RuleFailures = counters.RulesAdded, counters.RulesRemoved, counters.RuleFailures
+		result.RulesSkipped = counters.RulesSkipped
+		result.RoutesAdded, result.RoutesRemoved, result.RouteFailures = counters.RoutesAdded, counters.RoutesRemoved, counters.RouteFailures
+		result.Errors = syncErrors
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		if synced {
+			// Steady-state runs stay at debug so a 30s interval does not
+			// flood the log
+			level := logrus.DebugLevel
+			if counters.RulesAdded+counters.RulesRemoved+counters.RuleFailures > 0 ||
+				counters.RoutesAdded+counters.RoutesRemoved+counters.RouteFailures > 0 || len(syncErrors) > 0 {
+				level = logrus.InfoLevel
+			}
+			log.Logf(level, "SYNC FINISHED: %d rules added, %d removed, %d failed; %d routes added, %d removed, %d failed",
+				counters.RulesAdded, counters.RulesRemoved, counters.RuleFailures,
+				counters.RoutesAdded, counters.RoutesRemoved, counters.RouteFailures)
+		}
+		s.recordSyncResult(result)
 	}()
 
-	logrus.Debug("Performing full synchronization")
+	log.Debugf("Performing full synchronization (trigger: %s)", trigger)
 
 	providers, err := s.natsClient.ListProviders()
 	if err != nil {
-		logrus.Errorf("Failed to list providers: %v", err)
-		return err
+		log.Errorf("Failed to list providers: %v", err)
+		notify(systemd.Status("sync failed: " + err.Error()))
+		return result, err
 	}
 
 	policies, err := s.natsClient.ListPolicies()
 	if err != nil {
-		logrus.Errorf("Failed to list policies: %v", err)
-		return err
+		log.Errorf("Failed to list policies: %v", err)
+		notify(systemd.Status("sync failed: " + err.Error()))
+		return result, err
 	}
 
+	s.refreshDelegatedPrefixes()
+	cached, policies, unresolved := s.resolvePolicies(policies)
+
 	s.cacheMu.Lock()
 	s.providers = make(map[string]*models.InternetProvider, len(providers))
 	for _, provider := range providers {
 		s.providers[provider.ID] = provider
 	}
-	s.policies = make(map[string]*models.RoutingPolicy, len(policies))
-	for _, policy := range policies {
+	s.policies = make(map[string]*models.RoutingPolicy, len(cached))
+	for _, policy := range cached {
 		s.policies[policy.ID] = policy
 	}
 	s.cacheMu.Unlock()
+	s.wakeSchedules()
 
 	s.refreshTableNames()
+	s.updateHealthChecks()
+
+	s.cacheMu.Lock()
+	failover := s.planFailoverLocked()
+	s.cacheMu.Unlock()
 
-	logrus.Info("SYNC START")
-	if err := s.routerManager.SyncProviders(providers); err != nil {
-		logrus.Errorf("Failed to sync providers: %v", err)
+	if s.InMaintenance() {
+		log.Debug("Maintenance mode active: skipping kernel sync")
+		result.Maintenance = true
+		notify(systemd.Status("maintenance mode: kernel sync paused"))
+		s.updateKernelGauges(providers, policies)
+		return result, nil
 	}
-	if err := s.routerManager.SyncPolicies(policies, providers); err != nil {
-		logrus.Errorf("Failed to sync policies: %v", err)
+
+	log.Debug("SYNC START")
+	counters, providersErr, policiesErr := s.routerManager.Sync(syncID, providers, policies, failover)
+	if providersErr != nil {
+		log.Errorf("Failed to sync providers: %v", providersErr)
+		syncErrors = append(syncErrors, providersErr.Error())
 	}
-	logrus.Info("SYNC FINISHED")
-	return nil
+	if policiesErr != nil {
+		log.Errorf("Failed to sync policies: %v", policiesErr)
+		syncErrors = append(syncErrors, policiesErr.Error())
+	}
+	var failedProviders router.ProviderErrors
+	errors.As(providersErr, &failedProviders)
+	for _, provider := range providers {
+		s.recordProviderApply(provider.ID, failedProviders[provider.ID])
+	}
+	policyErrors, untied := mergePolicyErrors(unresolved, policiesErr)
+	result.PolicyErrors = errorStrings(policyErrors)
+	s.recordFullSync(providersErr, untied, policyErrors)
+	s.reconcileFRR()
+	s.updateKernelGauges(providers, policies)
+	notifySyncStatus(len(providers), len(policies), syncErrors)
+	synced = true
+
+	s.healthMu.Lock()
+	s.lastSyncAt = time.Now()
+	s.healthMu.Unlock()
+
+	s.emit(&models.Event{
+		Type:    models.EventSyncCompleted,
+		Message: "full sync completed on " + s.hostname,
+		Data: map[string]interface{}{
+			"providers":   len(providers),
+			"policies":    len(policies),
+			"duration_ms": time.Since(start).Milliseconds(),
+			"errors":      syncErrors,
+			"sync_id":     syncID,
+			"trigger":     trigger,
+		},
+	})
+	return result, nil
 }
 
 func (s *Service) refreshTableNames() {
@@ -231,6 +638,8 @@ func (s *Service) refreshTableNames() {
 
 func (s *Service) watchProviders() {
 	defer s.wg.Done()
+	s.setWatcherAlive(watcherProviders, true)
+	defer s.setWatcherAlive(watcherProviders, false)
 
 	err := s.natsClient.WatchProviders(s.ctx, func(provider *models.InternetProvider, op natsio.KeyValueOp) {
 		s.cacheMu.Lock()
@@ -239,20 +648,37 @@ func (s *Service) watchProviders() {
 		case natsio.KeyValuePut:
 			if provider != nil {
 				s.providers[provider.ID] = provider
-				logrus.Infof("Provider updated: %s", provider.Name)
+				logging.Provider(provider.ID).Infof("Provider updated: %s", provider.Name)
 				s.cacheMu.Unlock()
-				if err := s.routerManager.SetupProvider(provider); err != nil {
-					logrus.Errorf("Failed to set up provider %s: %v", provider.Name, err)
+				s.updateHealthChecks()
+				if s.InMaintenance() {
+					logging.Provider(provider.ID).Infof("Maintenance mode active: provider %s will be applied when lifted", provider.Name)
+					return
+				}
+				err := s.routerManager.SetupProvider(provider)
+				s.recordProviderApply(provider.ID, err)
+				if err != nil {
+					logging.Provider(provider.ID).Errorf("Failed to set up provider %s: %v", provider.Name, err)
 				}
 				return
 			}
 		case natsio.KeyValueDelete:
-			if provider != nil {
+			if provider == nil {
+				break
+			}
+			if cached, ok := s.providers[provider.ID]; ok {
+				provider = cached
 				delete(s.providers, provider.ID)
-				logrus.Infof("Provider deleted: %s", provider.Name)
+				logging.Provider(provider.ID).Infof("Provider deleted: %s", provider.Name)
+				if s.InMaintenance() {
+					logging.Provider(provider.ID).Warnf("Maintenance mode active: leaving the routes of deleted provider %s in table %d", provider.Name, provider.TableID)
+				} else if err := s.routerManager.RemoveProvider(provider); err != nil {
+					logging.Provider(provider.ID).Errorf("Failed to remove routes of provider %s: %v", provider.Name, err)
+				}
 			}
 		}
 		s.cacheMu.Unlock()
+		s.updateHealthChecks()
 	})
 
 	if err != nil {
@@ -260,41 +686,195 @@ func (s *Service) watchProviders() {
 	}
 }
 
+// effectivePolicyLocked returns the cached policy that decides the rule for
+// policy's source and destination (see models.EffectivePolicies). cacheMu
+// must be held.
+func (s *Service) effectivePolicyLocked(policy *models.RoutingPolicy) *models.RoutingPolicy {
+	key, ok := policy.RuleKey()
+	if !ok {
+		return policy
+	}
+	var same []*models.RoutingPolicy
+	for _, p := range s.policies {
+		if k, ok := p.RuleKey(); ok && k == key {
+			same = append(same, p)
+		}
+	}
+	if effective := models.EffectivePolicies(same, time.Now()); len(effective) == 1 {
+		return effective[0]
+	}
+	return policy
+}
+
+// markRouted reports whether this router routes policy by an nftables mark
+// (see RoutingPolicy.MarkRouted).
+func (s *Service) markRouted(policy *models.RoutingPolicy) bool {
+	return policy.MarkRouted(s.cfg.Router.SourceSets)
+}
+
+// syncClassificationLocked rebuilds the nftables classification from the
+// cached policies and providers. cacheMu must be held.
+func (s *Service) syncClassificationLocked() error {
+	policies := make([]*models.RoutingPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policies = append(policies, p)
+	}
+	providers := make([]*models.InternetProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	return s.routerManager.SyncClassification(s.failoverPoliciesLocked(models.EffectivePolicies(policies, time.Now())), providers)
+}
+
+// syncAccountingLocked reloads the traffic accounting for the cached
+// policies after a watcher change. cacheMu must be held.
+func (s *Service) syncAccountingLocked() {
+	if !s.cfg.Features.NFTables || s.InMaintenance() {
+		return
+	}
+	policies := make([]*models.RoutingPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policies = append(policies, p)
+	}
+	if err := s.routerManager.SyncAccounting(models.EffectivePolicies(policies, time.Now())); err != nil {
+		logrus.Warnf("Failed to sync traffic accounting: %v", err)
+	}
+}
+
 func (s *Service) watchPolicies() {
 	defer s.wg.Done()
+	s.setWatcherAlive(watcherPolicies, true)
+	defer s.setWatcherAlive(watcherPolicies, false)
 
 	err := s.natsClient.WatchPolicies(s.ctx, func(policy *models.RoutingPolicy, op natsio.KeyValueOp) {
+		defer s.wakeSchedules()
 		s.cacheMu.Lock()
 		defer s.cacheMu.Unlock()
+		defer s.syncAccountingLocked()
 
 		switch op {
 		case natsio.KeyValuePut:
 			if policy != nil {
+				prev := s.policies[policy.ID]
+				resolved, resolveErr := policy.Resolve(s.delegatedPrefixesCopy())
+				if resolveErr != nil {
+					// Not routable here until the prefix shows up; drop the
+					// rule of the subnet it resolved to before
+					s.policies[policy.ID] = policy
+					s.recordPolicyApply(policy.ID, resolveErr)
+					logging.Policy(policy.ID, policy.ProviderID).Warnf("Policy %s not applied: %v", policy.Name, resolveErr)
+					if prev != nil && prev.SourceIP != "" && !s.InMaintenance() {
+						if provider, ok := s.providers[prev.ProviderID]; ok {
+							if err := s.routerManager.RemovePolicy(prev, provider); err != nil {
+								logging.Policy(policy.ID, policy.ProviderID).Warnf("Failed to remove previous rule for policy %s: %v", policy.Name, err)
+							}
+						}
+					}
+					return
+				}
+				policy = resolved
 				s.policies[policy.ID] = policy
-				logrus.Infof("Policy updated: %s", policy.Name)
+				plan := s.planFailoverLocked()
+				logging.Policy(policy.ID, policy.ProviderID).Infof("Policy updated: %s", policy.Name)
+				if s.InMaintenance() {
+					logging.Policy(policy.ID, policy.ProviderID).Infof("Maintenance mode active: policy %s will be applied when lifted", policy.Name)
+					return
+				}
 
-				provider, exists := s.providers[policy.ProviderID]
+				// Protocol/port and source set policies live in the nftables
+				// classification, which is rebuilt as a whole
+				if s.markRouted(policy) || (prev != nil && s.markRouted(prev)) {
+					err := s.syncClassificationLocked()
+					s.recordPolicyApply(policy.ID, err)
+					if err != nil {
+						logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to apply classification for policy %s: %v", policy.Name, err)
+					}
+					if s.markRouted(policy) {
+						// Drop the plain rule it used to have
+						if prev != nil && !s.markRouted(prev) {
+							if provider, ok := s.providers[prev.ProviderID]; ok {
+								if err := s.routerManager.RemovePolicy(prev, provider); err != nil {
+									logging.Policy(policy.ID, policy.ProviderID).Warnf("Failed to remove previous rule for policy %s: %v", policy.Name, err)
+								}
+							}
+						}
+						return
+					}
+				}
+
+				if effective := s.effectivePolicyLocked(policy); effective != policy {
+					logging.Policy(policy.ID, policy.ProviderID).Infof("Policy %s shares its rule with active policy %s; keeping that rule", policy.Name, effective.Name)
+					return
+				}
+
+				providerID, ok := policy.FailoverProvider(plan)
+				if !ok {
+					err := s.routerManager.ApplyProviderDown(policy)
+					s.recordPolicyApply(policy.ID, err)
+					if err != nil {
+						logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to take policy %s off its down provider: %v", policy.Name, err)
+					}
+					return
+				}
+				provider, exists := s.providers[providerID]
 				if !exists {
-					logrus.Warnf("Provider %s not found for policy %s", policy.ProviderID, policy.Name)
+					logging.Policy(policy.ID, policy.ProviderID).Warnf("Provider %s not found for policy %s", providerID, policy.Name)
 					return
 				}
-				if err := s.routerManager.SetupPolicy(policy, provider); err != nil {
-					logrus.Errorf("Failed to set up policy %s: %v", policy.Name, err)
+				if providerID != policy.ProviderID {
+					moved := *policy
+					moved.ProviderID = providerID
+					policy = &moved
+				}
+				err := s.routerManager.SetupPolicy(policy, provider)
+				s.recordPolicyApply(policy.ID, err)
+				if err != nil {
+					logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to set up policy %s: %v", policy.Name, err)
+					return
 				}
+				s.emit(&models.Event{
+					Type:     models.EventPolicyApplied,
+					Resource: policy.ID,
+					Message:  "policy " + policy.Name + " applied on " + s.hostname,
+					Data: map[string]interface{}{
+						"provider_id": provider.ID,
+						"table_id":    provider.TableID,
+						"enabled":     policy.Enabled,
+						"generation":  policy.Generation,
+					},
+				})
 			}
 		case natsio.KeyValueDelete:
 			if policy != nil {
 				delete(s.policies, policy.ID)
-				logrus.Infof("Policy deleted: %s", policy.Name)
+				s.planFailoverLocked()
+				logging.Policy(policy.ID, policy.ProviderID).Infof("Policy deleted: %s", policy.Name)
+				if s.InMaintenance() {
+					logging.Policy(policy.ID, policy.ProviderID).Infof("Maintenance mode active: policy %s will be removed when lifted", policy.Name)
+					return
+				}
+				if s.markRouted(policy) {
+					if err := s.syncClassificationLocked(); err != nil {
+						logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to remove policy %s from the classification: %v", policy.Name, err)
+					}
+				}
 
 				provider, exists := s.providers[policy.ProviderID]
 				if !exists {
-					logrus.Warnf("Provider %s not found for policy %s", policy.ProviderID, policy.Name)
+					logging.Policy(policy.ID, policy.ProviderID).Warnf("Provider %s not found for policy %s", policy.ProviderID, policy.Name)
 					return
 				}
-				if err := s.routerManager.RemovePolicy(policy, provider); err != nil {
-					logrus.Errorf("Failed to remove policy %s: %v", policy.Name, err)
+				err := s.routerManager.RemovePolicy(policy, provider)
+				s.recordPolicyApply(policy.ID, err)
+				if err != nil {
+					logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to remove policy %s: %v", policy.Name, err)
+					return
 				}
+				s.emit(&models.Event{
+					Type:     models.EventPolicyRemoved,
+					Resource: policy.ID,
+					Message:  "policy " + policy.Name + " removed on " + s.hostname,
+				})
 			}
 		}
 	})
@@ -319,6 +899,7 @@ func (s *Service) publishStateLoop() {
 		select {
 		case <-s.ctx.Done():
 			_ = s.natsClient.DeleteRouterState(s.hostname)
+			s.publishHeartbeat(nil, true)
 			return
 		case <-ticker.C:
 			if err := s.publishState(); err != nil {
@@ -332,12 +913,17 @@ func (s *Service) publishStateLoop() {
 }
 
 func (s *Service) publishState() error {
-	st, err := s.collector.Collect()
+	// Pick up the rule changes the watchers made since the last sync
+	s.observeRouterCounters()
+
+	st, err := s.collectState()
 	if err != nil {
+		s.publishHeartbeat(nil, false)
 		return err
 	}
-	st.AgentVersion = s.agentVersion
-	st.LogLevel = logging.GetLevelName()
+
+	s.checkProviderHealth(st)
+	s.publishHeartbeat(st, false)
 
 	s.rulesTotal.Set(float64(len(st.Rules)))
 	for _, t := range st.Tables {
@@ -347,6 +933,52 @@ func (s *Service) publishState() error {
 	return s.natsClient.StoreRouterState(st)
 }
 
+// collectState reads the kernel state and adds the agent's own fields.
+func (s *Service) collectState() (*models.RouterState, error) {
+	st, err := s.collector.Collect()
+	if err != nil {
+		return nil, err
+	}
+	st.AgentVersion = s.agentVersion
+	st.LogLevel = logging.GetLevelName()
+	st.Maintenance = s.InMaintenance()
+	st.AppliedGeneration = s.currentAppliedGeneration(st.Maintenance)
+	st.Features = s.cfg.Features.Enabled()
+	st.SourceSets = s.cfg.Router.SourceSets
+	st.DelegatedPrefixes = s.delegatedPrefixesCopy()
+	st.ProviderHealth = s.providerHealth()
+	st.Failover = s.failoverCopy()
+	s.fillApplyStatus(st)
+	traffic, err := s.routerManager.PolicyTraffic()
+	if err != nil {
+		logrus.Warnf("Failed to read policy traffic: %v", err)
+	}
+	st.PolicyTraffic = traffic
+	s.setPolicyTraffic(traffic)
+	return st, nil
+}
+
+// currentAppliedGeneration digests the cached configuration, which the
+// watchers and full syncs apply as it arrives. During maintenance the caches
+// run ahead of the kernel, so the last value from before it is kept.
+func (s *Service) currentAppliedGeneration(maintenance bool) string {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if maintenance {
+		return s.appliedGeneration
+	}
+	providers := make([]*models.InternetProvider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	policies := make([]*models.RoutingPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		policies = append(policies, p)
+	}
+	s.appliedGeneration = models.ConfigGeneration(providers, policies)
+	return s.appliedGeneration
+}
+
 func itoaTableLabel(t models.RoutingTable) string {
 	if t.Name != "" {
 		return t.Name
@@ -421,22 +1053,30 @@ func (s *Service) watchLogLevel() {
 	}
 }
 
+// Stats summarizes the configuration the agent currently enforces.
+type Stats struct {
+	Hostname            string         `json:"hostname"`
+	ProvidersCount      int            `json:"providers_count"`
+	PoliciesCount       int            `json:"policies_count"`
+	SyncInterval        string         `json:"sync_interval"`
+	PoliciesPerProvider map[string]int `json:"policies_per_provider"`
+}
+
 // GetStats returns synchronization statistics (used for agent /health endpoint).
-func (s *Service) GetStats() map[string]interface{} {
+func (s *Service) GetStats() Stats {
 	s.cacheMu.RLock()
 	defer s.cacheMu.RUnlock()
 
-	stats := make(map[string]interface{})
-	stats["providers_count"] = len(s.providers)
-	stats["policies_count"] = len(s.policies)
-	stats["sync_interval"] = s.cfg.Sync.Interval.String()
-	stats["hostname"] = s.hostname
-
-	policiesPerProvider := make(map[string]int)
+	stats := Stats{
+		Hostname:            s.hostname,
+		ProvidersCount:      len(s.providers),
+		PoliciesCount:       len(s.policies),
+		SyncInterval:        s.cfg.Sync.Interval.String(),
+		PoliciesPerProvider: make(map[string]int),
+	}
 	for _, policy := range s.policies {
-		policiesPerProvider[policy.ProviderID]++
+		stats.PoliciesPerProvider[policy.ProviderID]++
 	}
-	stats["policies_per_provider"] = policiesPerProvider
 
 	return stats
 }
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"errors"
+	"time"
+
+	"router-sync/internal/models"
+	"router-sync/internal/router"
+)
+
+// applyStatus is what the agent reports about applying configuration: when
+// it last did and the last error per provider and policy, whether the
+// kernel drifted from the configuration at the last check, and the result
+// of the last full sync. Guarded by statusMu.
+type applyStatus struct {
+	appliedAt      time.Time
+	policyErrors   map[string]string
+	providerErrors map[string]string
+	drifted        bool
+	lastSync       *models.SyncResult
+}
+
+// recordProviderApply notes the outcome of applying one provider.
+func (s *Service) recordProviderApply(id string, err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	recordApply(&s.status, s.status.providerErrors, id, err)
+}
+
+// recordPolicyApply notes the outcome of applying (or removing) one policy,
+// emitting policy.failed when it failed with an error not reported before.
+func (s *Service) recordPolicyApply(id string, err error) {
+	s.statusMu.Lock()
+	fresh := err != nil && s.status.policyErrors[id] != err.Error()
+	recordApply(&s.status, s.status.policyErrors, id, err)
+	s.statusMu.Unlock()
+
+	if fresh {
+		s.emitPolicyFailed(id, err.Error())
+	}
+}
+
+// emitPolicyFailed emits policy.failed for policy id failing with msg.
+func (s *Service) emitPolicyFailed(id, msg string) {
+	s.emit(&models.Event{
+		Type:     models.EventPolicyFailed,
+		Resource: id,
+		Message:  "policy " + id + " failed on " + s.hostname,
+		Data:     map[string]interface{}{"error": msg},
+	})
+}
+
+// recordDrift notes whether the kernel matched the configuration at the
+// last check, emitting drift.detected when it stops matching.
+func (s *Service) recordDrift(rules, routes int) {
+	drifted := rules+routes > 0
+	s.statusMu.Lock()
+	fresh := drifted && !s.status.drifted
+	s.status.drifted = drifted
+	s.statusMu.Unlock()
+
+	if fresh {
+		s.emit(&models.Event{
+			Type:    models.EventDriftDetected,
+			Message: "kernel state drifted from the configuration on " + s.hostname,
+			Data:    map[string]interface{}{"rules": rules, "routes": routes},
+		})
+	}
+}
+
+func recordApply(st *applyStatus, errs map[string]string, id string, err error) {
+	if err != nil {
+		errs[id] = err.Error()
+		return
+	}
+	delete(errs, id)
+	st.appliedAt = time.Now().UTC()
+}
+
+// recordFullSync notes a full sync. A kind that synced without error has no
+// failing records left but the policies in policyErrors; failures of a full
+// sync are not tied to a record, so the per-record errors of that kind are
+// kept. policy.failed is emitted for policy errors not reported before.
+func (s *Service) recordFullSync(providersErr, policiesErr error, policyErrors map[string]error) {
+	var fresh []string
+	s.statusMu.Lock()
+	if providersErr == nil {
+		s.status.providerErrors = make(map[string]string)
+	}
+	previous := s.status.policyErrors
+	if policiesErr == nil {
+		s.status.policyErrors = make(map[string]string, len(policyErrors))
+	}
+	for id, err := range policyErrors {
+		if previous[id] != err.Error() {
+			fresh = append(fresh, id)
+		}
+		s.status.policyErrors[id] = err.Error()
+	}
+	s.status.appliedAt = time.Now().UTC()
+	s.statusMu.Unlock()
+
+	for _, id := range fresh {
+		s.emitPolicyFailed(id, policyErrors[id].Error())
+	}
+}
+
+// mergePolicyErrors returns the errors of a full sync's policies by ID: the
+// unresolved ones and those in policiesErr when it is router.PolicyErrors.
+// untied is policiesErr when it is not tied to policies that way.
+func mergePolicyErrors(unresolved map[string]error, policiesErr error) (policyErrors map[string]error, untied error) {
+	policyErrors = make(map[string]error, len(unresolved))
+	for id, err := range unresolved {
+		policyErrors[id] = err
+	}
+	var failed router.PolicyErrors
+	if !errors.As(policiesErr, &failed) {
+		return policyErrors, policiesErr
+	}
+	for id, err := range failed {
+		policyErrors[id] = err
+	}
+	return policyErrors, nil
+}
+
+// errorStrings returns the messages of errs, or nil when there are none.
+func errorStrings(errs map[string]error) map[string]string {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(errs))
+	for id, err := range errs {
+		out[id] = err.Error()
+	}
+	return out
+}
+
+// recordSyncResult keeps a copy of the result of a finished full sync for
+// the heartbeat.
+func (s *Service) recordSyncResult(result *models.SyncResult) {
+	recorded := *result
+	recorded.PolicyErrors = copyErrors(result.PolicyErrors)
+	recorded.Errors = append([]string(nil), result.Errors...)
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.status.lastSync = &recorded
+}
+
+// fillApplyStatus copies the apply status into a heartbeat.
+func (s *Service) fillApplyStatus(st *models.RouterState) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	st.AppliedAt = s.status.appliedAt
+	st.PolicyErrors = copyErrors(s.status.policyErrors)
+	st.ProviderErrors = copyErrors(s.status.providerErrors)
+	st.LastSync = s.status.lastSync
+}
+
+func copyErrors(errs map[string]string) map[string]string {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(errs))
+	for id, msg := range errs {
+		out[id] = msg
+	}
+	return out
+}
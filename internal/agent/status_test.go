@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"router-sync/internal/models"
+	"router-sync/internal/router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusService returns a Service with an empty apply status whose
+// events are appended to the returned slice.
+func newStatusService() (*Service, *[]*models.Event) {
+	var events []*models.Event
+	s := &Service{
+		hostname: "r1",
+		status: applyStatus{
+			policyErrors:   make(map[string]string),
+			providerErrors: make(map[string]string),
+		},
+	}
+	s.publishEvent = func(ev *models.Event) error {
+		events = append(events, ev)
+		return nil
+	}
+	return s, &events
+}
+
+func TestRecordFullSyncEmitsPolicyFailedOnce(t *testing.T) {
+	s, events := newStatusService()
+	failing := map[string]error{"guests": errors.New("no delegated prefix")}
+
+	s.recordFullSync(nil, nil, failing)
+	s.recordFullSync(nil, nil, failing)
+	require.Len(t, *events, 1, "the same error on the next sync is not new")
+	assert.Equal(t, models.EventPolicyFailed, (*events)[0].Type)
+	assert.Equal(t, "guests", (*events)[0].Resource)
+	assert.Equal(t, "r1", (*events)[0].Hostname)
+
+	s.recordFullSync(nil, nil, map[string]error{"guests": errors.New("file exists")})
+	require.Len(t, *events, 2, "a changed error is new")
+	assert.Equal(t, "file exists", (*events)[1].Data["error"])
+
+	s.recordFullSync(nil, nil, nil)
+	assert.Empty(t, s.status.policyErrors)
+	s.recordFullSync(nil, nil, failing)
+	assert.Len(t, *events, 3, "an error that comes back after a clean sync is new")
+}
+
+func TestRecordFullSyncKeepsErrorsOnUntiedFailure(t *testing.T) {
+	s, events := newStatusService()
+	s.recordPolicyApply("guests", errors.New("file exists"))
+	s.recordProviderApply("lte", errors.New("no such interface"))
+	require.Len(t, *events, 1)
+
+	s.recordFullSync(errors.New("providers failed"), errors.New("policies failed"), nil)
+	assert.Equal(t, "file exists", s.status.policyErrors["guests"])
+	assert.Equal(t, "no such interface", s.status.providerErrors["lte"])
+
+	s.recordFullSync(nil, nil, nil)
+	assert.Empty(t, s.status.policyErrors)
+	assert.Empty(t, s.status.providerErrors)
+	assert.Len(t, *events, 1)
+}
+
+func TestRecordPolicyApplyEmitsPolicyFailedOnce(t *testing.T) {
+	s, events := newStatusService()
+	s.recordPolicyApply("guests", errors.New("file exists"))
+	s.recordPolicyApply("guests", errors.New("file exists"))
+	assert.Len(t, *events, 1)
+
+	s.recordPolicyApply("guests", nil)
+	assert.Empty(t, s.status.policyErrors)
+	assert.False(t, s.status.appliedAt.IsZero())
+	s.recordPolicyApply("guests", errors.New("file exists"))
+	assert.Len(t, *events, 2)
+}
+
+func TestRecordDriftEmitsOnFlip(t *testing.T) {
+	s, events := newStatusService()
+	s.recordDrift(0, 0)
+	assert.Empty(t, *events)
+
+	s.recordDrift(1, 0)
+	s.recordDrift(2, 1)
+	require.Len(t, *events, 1, "drift.detected only when the state flips")
+	assert.Equal(t, models.EventDriftDetected, (*events)[0].Type)
+	assert.Equal(t, 1, (*events)[0].Data["rules"])
+
+	s.recordDrift(0, 0)
+	s.recordDrift(0, 3)
+	assert.Len(t, *events, 2)
+}
+
+func TestMergePolicyErrors(t *testing.T) {
+	unresolved := map[string]error{"v6-guests": errors.New("no delegated prefix")}
+
+	errs, untied := mergePolicyErrors(unresolved, router.PolicyErrors{"guests": errors.New("file exists")})
+	assert.NoError(t, untied, "failed policies are tied to their records")
+	assert.Equal(t, map[string]string{"v6-guests": "no delegated prefix", "guests": "file exists"}, errorStrings(errs))
+
+	listErr := errors.New("netlink: permission denied")
+	errs, untied = mergePolicyErrors(unresolved, listErr)
+	assert.Equal(t, listErr, untied)
+	assert.Len(t, errs, 1)
+
+	errs, untied = mergePolicyErrors(nil, nil)
+	assert.NoError(t, untied)
+	assert.Nil(t, errorStrings(errs))
+}
+
+func TestRecordSyncResultKeepsCopy(t *testing.T) {
+	s, _ := newStatusService()
+	var st models.RouterState
+	s.fillApplyStatus(&st)
+	assert.Nil(t, st.LastSync, "no sync finished yet")
+
+	result := &models.SyncResult{
+		SyncID:       "5f2b8c1d9e0a4b7c",
+		RulesAdded:   1,
+		RulesSkipped: 2,
+		PolicyErrors: map[string]string{"guests": "file exists"},
+		Errors:       []string{"policy guests: file exists"},
+	}
+	s.recordSyncResult(result)
+	result.RulesAdded = 5
+	result.PolicyErrors["other"] = "changed later"
+	result.Errors[0] = "changed later"
+
+	s.fillApplyStatus(&st)
+	require.NotNil(t, st.LastSync)
+	assert.Equal(t, "5f2b8c1d9e0a4b7c", st.LastSync.SyncID)
+	assert.Equal(t, 1, st.LastSync.RulesAdded)
+	assert.Equal(t, 2, st.LastSync.RulesSkipped)
+	assert.Equal(t, map[string]string{"guests": "file exists"}, st.LastSync.PolicyErrors)
+	assert.Equal(t, []string{"policy guests: file exists"}, st.LastSync.Errors)
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// cleanupConfirmTTL is how long a cleanup confirmation token stays valid.
+	cleanupConfirmTTL = 2 * time.Minute
+	// cleanupCommandTimeout covers rule removal plus the full re-sync.
+	cleanupCommandTimeout = 60 * time.Second
+)
+
+// CleanupRequest asks agents to remove every managed ip rule (priority
+// 2000-2032 by default). Tables also flushes routing tables that no provider
+// owns and no rule references. Resync (default true) re-applies the current policies
+// right after. Confirm must carry the token returned by a previous call with
+// the same router and tables values.
+type CleanupRequest struct {
+	Router  string `json:"router" example:"r1"`
+	Tables  bool   `json:"tables" example:"false"`
+	Resync  *bool  `json:"resync" example:"true"`
+	Confirm string `json:"confirm" example:""`
+}
+
+// CleanupConfirmation is returned (HTTP 428) when a cleanup is requested
+// without a valid token. Repeat the request with confirm=ConfirmToken.
+type CleanupConfirmation struct {
+	ConfirmToken string    `json:"confirm_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Routers      []string  `json:"routers"`
+	Tables       bool      `json:"tables"`
+	Message      string    `json:"message"`
+}
+
+// CleanupRouterResult is one router's answer to a cleanup.
+type CleanupRouterResult struct {
+	Hostname string                `json:"hostname"`
+	Result   *models.CleanupResult `json:"result,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// cleanupScope is what a confirmation token authorizes.
+type cleanupScope struct {
+	router  string
+	tables  bool
+	expires time.Time
+}
+
+// confirmationStore holds single-use cleanup tokens. Tokens live in this API
+// instance's memory, so with several API replicas the confirming request must
+// reach the instance that issued the token (otherwise a new one is issued).
+type confirmationStore struct {
+	mu      sync.Mutex
+	pending map[string]cleanupScope
+}
+
+func (cs *confirmationStore) issue(scope cleanupScope) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.pending == nil {
+		cs.pending = make(map[string]cleanupScope)
+	}
+	now := time.Now()
+	for t, s := range cs.pending {
+		if now.After(s.expires) {
+			delete(cs.pending, t)
+		}
+	}
+	cs.pending[token] = scope
+	return token, nil
+}
+
+// consume reports whether token is valid for router/tables, invalidating it.
+func (cs *confirmationStore) consume(token, router string, tables bool) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	scope, ok := cs.pending[token]
+	if !ok {
+		return false
+	}
+	delete(cs.pending, token)
+	return time.Now().Before(scope.expires) && scope.router == router && scope.tables == tables
+}
+
+// cleanupRules removes managed ip rules on demand
+// @Summary Run full rule cleanup
+// @Description Remove every managed ip rule (priority 2000-2032 by default) on one router or every online router, optionally flush orphaned routing tables, then re-apply the current policies. Two steps: without confirm the API answers 428 with a confirm_token (valid 2 minutes, single use); repeat the same request with confirm set to run it.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body CleanupRequest true "Cleanup scope and confirmation token"
+// @Success 200 {array} CleanupRouterResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse "Invalid or expired confirmation token"
+// @Failure 428 {object} CleanupConfirmation "Confirmation required"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/cleanup [post]
+// @Router /api/v2/admin/cleanup [post]
+func (s *Server) cleanupRules(c *gin.Context) {
+	var req CleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	hosts, err := s.targetRouters(req.Router)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	if req.Confirm == "" {
+		expires := time.Now().Add(cleanupConfirmTTL)
+		token, err := s.confirmations.issue(cleanupScope{router: req.Router, tables: req.Tables, expires: expires})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to issue confirmation token", err.Error())
+			return
+		}
+		c.JSON(http.StatusPreconditionRequired, CleanupConfirmation{
+			ConfirmToken: token,
+			ExpiresAt:    expires.UTC(),
+			Routers:      hosts,
+			Tables:       req.Tables,
+			Message:      "This removes every managed ip rule on the listed routers. Repeat the request with confirm set to this token to proceed.",
+		})
+		return
+	}
+	if !s.confirmations.consume(req.Confirm, req.Router, req.Tables) {
+		respondError(c, http.StatusPreconditionFailed, "Invalid confirmation token", "the token is unknown, expired, already used or was issued for a different router/tables scope")
+		return
+	}
+
+	resync := req.Resync == nil || *req.Resync
+	cmd := &models.AgentCommand{
+		Command: models.CommandRulesCleanup,
+		Args: map[string]string{
+			"tables": strconv.FormatBool(req.Tables),
+			"resync": strconv.FormatBool(resync),
+		},
+	}
+	if identity := identityFrom(c); identity != nil {
+		cmd.RequestedBy = identity.Subject
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cleanupCommandTimeout)
+	defer cancel()
+
+	results := make([]CleanupRouterResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = s.cleanupOnRouter(ctx, host, cmd)
+		}(i, host)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.Result != nil {
+			s.recordAudit(c, models.AuditActionCleanup, models.AuditEntityRouter, r.Hostname, nil, auditSnapshot(r.Result))
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (s *Server) cleanupOnRouter(ctx context.Context, host string, cmd *models.AgentCommand) CleanupRouterResult {
+	out := CleanupRouterResult{Hostname: host}
+
+	reply, err := s.natsClient.SendAgentCommand(ctx, host, cmd)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	if !reply.OK {
+		out.Error = reply.Error
+		return out
+	}
+	var result models.CleanupResult
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Result = &result
+	return out
+}
+
+// getEffectiveConfig returns the resolved configuration of this API process
+// @Summary Get effective configuration
+// @Description Return the fully resolved configuration of the API process serving the request (defaults, config file or conf.d fragments, environment and command-line flags), keyed like the config file. Passwords, tokens and signing keys are replaced with REDACTED; empty ones stay empty.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/config [get]
+// @Router /api/v2/admin/config [get]
+func (s *Server) getEffectiveConfig(c *gin.Context) {
+	out, err := s.effective.RedactedMap()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to render configuration", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// MaintenanceRequest turns the global maintenance switch on or off.
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required" example:"true"`
+	Reason  string `json:"reason" example:"ISP cutover window"`
+}
+
+// getMaintenance returns the global maintenance switch
+// @Summary Get maintenance mode
+// @Description Return whether maintenance mode is active. While active, agents keep accepting configuration changes but apply nothing to the kernel.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.Maintenance
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/maintenance [get]
+// @Router /api/v2/admin/maintenance [get]
+func (s *Server) getMaintenance(c *gin.Context) {
+	m, err := s.natsClient.GetMaintenance()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to get maintenance state", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// setMaintenance enables or lifts maintenance mode
+// @Summary Set maintenance mode
+// @Description Freeze (enabled=true) or resume (enabled=false) all kernel modifications on every agent: syncs, rule cleanup, conntrack flushes and health-driven failovers. Providers and policies can still be changed; agents apply them with a full sync when maintenance is lifted.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body MaintenanceRequest true "Maintenance switch"
+// @Success 200 {object} models.Maintenance
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/maintenance [post]
+// @Router /api/v2/admin/maintenance [post]
+func (s *Server) setMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	before, err := s.natsClient.GetMaintenance()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to get maintenance state", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	m := &models.Maintenance{Enabled: *req.Enabled, UpdatedAt: now}
+	if m.Enabled {
+		m.Reason = req.Reason
+		m.Since = now
+		if before.Enabled {
+			// Re-enabling only updates the reason; keep the window's start.
+			m.Since = before.Since
+		}
+		m.SetBy = "anonymous"
+		if identity := identityFrom(c); identity != nil && identity.Subject != "" {
+			m.SetBy = identity.Subject
+		}
+	}
+
+	if err := s.natsClient.SetMaintenance(m); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to store maintenance state", err.Error())
+		return
+	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityMaintenance, "global", auditSnapshot(before), auditSnapshot(m))
+
+	// Reflect the change in /stats and /readyz without waiting for the loop.
+	if err := s.refreshStats(); err != nil {
+		requestLog(c).Warnf("Failed to refresh stats after maintenance change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, m)
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmationStore(t *testing.T) {
+	var cs confirmationStore
+	expires := time.Now().Add(time.Minute)
+
+	token, err := cs.issue(cleanupScope{router: "r1", expires: expires})
+	require.NoError(t, err)
+	assert.False(t, cs.consume(token, "r2", false), "scope mismatch must fail")
+	assert.False(t, cs.consume(token, "r1", false), "token is single use even after a mismatch")
+
+	token, err = cs.issue(cleanupScope{router: "r1", tables: true, expires: expires})
+	require.NoError(t, err)
+	assert.True(t, cs.consume(token, "r1", true))
+	assert.False(t, cs.consume(token, "r1", true))
+
+	token, err = cs.issue(cleanupScope{expires: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	assert.False(t, cs.consume(token, "", false), "expired token")
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// systemActor is the audit actor for changes the API makes by itself.
+const systemActor = "system"
+
+// auditSnapshot serializes a record for the before/after fields. It must be
+// taken before the handler mutates the record.
+func auditSnapshot(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// recordAudit stores an audit entry for a change made by the caller. Audit
+// failures are logged but never fail the request: the change already happened.
+func (s *Server) recordAudit(c *gin.Context, action, entityType, entityID string, before, after json.RawMessage) {
+	actor := "anonymous"
+	if identity := identityFrom(c); identity != nil && identity.Subject != "" {
+		actor = identity.Subject
+	}
+	s.storeAudit(&models.AuditEntry{
+		Actor:      actor,
+		RemoteAddr: c.ClientIP(),
+		RequestID:  c.GetString(requestIDKey),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// recordSystemAudit stores an audit entry for a change the API made on its
+// own (e.g. expiring a policy), with the actor "system".
+func (s *Server) recordSystemAudit(action, entityType, entityID string, before, after json.RawMessage) {
+	s.storeAudit(&models.AuditEntry{
+		Actor:      systemActor,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// storeAudit records entry and announces the change on the event bus for
+// stream clients and webhooks.
+func (s *Server) storeAudit(entry *models.AuditEntry) {
+	log := logrus.NewEntry(logrus.StandardLogger())
+	if entry.RequestID != "" {
+		log = log.WithField(logging.FieldRequestID, entry.RequestID)
+	}
+	if err := s.natsClient.RecordAudit(entry); err != nil {
+		log.Warnf("Failed to record audit entry (%s %s %s by %s): %v", entry.Action, entry.EntityType, entry.EntityID, entry.Actor, err)
+	}
+
+	data := map[string]interface{}{
+		"action":      entry.Action,
+		"entity_type": entry.EntityType,
+		"actor":       entry.Actor,
+	}
+	if entry.After != nil {
+		data["after"] = entry.After
+	}
+	ev := &models.Event{
+		Type:     models.EventConfigChanged,
+		Resource: entry.EntityID,
+		Message:  entry.Action + " " + entry.EntityType,
+		Data:     data,
+	}
+	if err := s.natsClient.PublishEvent(ev); err != nil {
+		log.Warnf("Failed to publish config change event (%s %s %s): %v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// listAudit returns configuration changes made through the API
+// @Summary Audit log
+// @Description List create/update/delete operations on providers, policies and log levels, newest first. Entries are kept for 90 days.
+// @Tags audit
+// @Produce json
+// @Param entity query string false "Entity type (provider, policy, log_level)"
+// @Param entity_id query string false "Entity ID"
+// @Param actor query string false "Token subject, or anonymous when auth is disabled"
+// @Param since query string false "RFC3339 lower bound"
+// @Param until query string false "RFC3339 upper bound"
+// @Param limit query int false "Maximum entries (default 100, max 1000)"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/audit [get]
+// @Router /api/v2/audit [get]
+func (s *Server) listAudit(c *gin.Context) {
+	filter := models.AuditFilter{
+		EntityType: c.Query("entity"),
+		EntityID:   c.Query("entity_id"),
+		Actor:      c.Query("actor"),
+		Limit:      defaultAuditLimit,
+	}
+	if filter.EntityType == models.AuditEntityPolicy {
+		// Policy IDs are CIDRs; accept the underscore form used in URLs.
+		filter.EntityID = strings.ReplaceAll(filter.EntityID, "_", "/")
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid since", err.Error())
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid until", err.Error())
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid limit", "limit must be a positive integer")
+			return
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.natsClient.ListAudit(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list audit entries", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"router-sync/internal/backup"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestoreResult is returned by POST /api/v1/restore. Config changes use
+// replace semantics: records missing from the archive are deleted.
+// RequiresOverwrite is true when the restore would update or delete existing
+// records, which needs overwrite=true.
+type RestoreResult struct {
+	ImportResult
+	Webhooks          ImportChanges   `json:"webhooks"`
+	Backup            backup.Manifest `json:"backup"`
+	Signed            bool            `json:"signed"`
+	RequiresOverwrite bool            `json:"requires_overwrite"`
+}
+
+// createBackup returns a downloadable archive of the full configuration
+// @Summary Create backup
+// @Description Download a tar.gz archive of every provider, policy, policy group and webhook subscription (including webhook secrets), with a manifest of SHA-256 checksums. With api.backup_signing_key set the manifest is HMAC-signed and only archives signed with the same key can be restored.
+// @Tags config
+// @Produce application/gzip
+// @Success 200 {file} binary
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/backup [post]
+// @Router /api/v2/backup [post]
+func (s *Server) createBackup(c *gin.Context) {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	groups, err := s.natsClient.ListGroups()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list groups", err.Error())
+		return
+	}
+	webhooks, err := s.natsClient.ListWebhooks()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list webhooks", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	contents := &backup.Contents{
+		Manifest: backup.Manifest{CreatedAt: now, CreatedBy: "anonymous", Version: s.version},
+		Config: &models.ConfigDocument{
+			APIVersion: models.DocumentVersion,
+			ExportedAt: now,
+			Providers:  providers,
+			Policies:   policies,
+			Groups:     groups,
+		},
+		Webhooks: webhooks,
+	}
+	if identity := identityFrom(c); identity != nil && identity.Subject != "" {
+		contents.Manifest.CreatedBy = identity.Subject
+	}
+
+	data, err := backup.Encode(contents, s.config.BackupSigningKey)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to build backup", err.Error())
+		return
+	}
+	requestLog(c).Infof("Backup created by %s: %d providers, %d policies, %d groups, %d webhooks (signed=%t)",
+		contents.Manifest.CreatedBy, len(providers), len(policies), len(groups), len(webhooks), s.config.BackupSigningKey != "")
+
+	filename := "router-sync-backup-" + now.Format("20060102-150405") + ".tar.gz"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/gzip", data)
+}
+
+// restoreBackup restores an archive produced by POST /backup
+// @Summary Restore backup
+// @Description Restore providers, policies, groups and webhooks from a backup archive, sent as the raw body (application/gzip) or as the multipart field "archive". Records missing from the archive are deleted. Checksums (and the signature, when api.backup_signing_key is set) are verified first. dry_run=true only validates and returns the plan. A restore that would update or delete existing records is refused with 409 unless overwrite=true.
+// @Tags config
+// @Accept application/gzip
+// @Accept multipart/form-data
+// @Produce json
+// @Param archive formData file false "Backup archive (multipart)"
+// @Param dry_run query bool false "Validate and plan only"
+// @Param overwrite query bool false "Allow updating and deleting existing records"
+// @Success 200 {object} RestoreResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} RestoreResult "Existing records would be overwritten"
+// @Failure 500 {object} RestoreResult
+// @Router /api/v1/restore [post]
+// @Router /api/v2/restore [post]
+func (s *Server) restoreBackup(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	overwrite, _ := strconv.ParseBool(c.DefaultQuery("overwrite", "false"))
+
+	data, err := readArchiveBody(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid backup archive", err.Error())
+		return
+	}
+	contents, err := backup.Read(bytes.NewReader(data), s.config.BackupSigningKey)
+	if err != nil {
+		if errors.Is(err, backup.ErrUnsigned) || errors.Is(err, backup.ErrBadSignature) {
+			respondError(c, http.StatusBadRequest, "Backup signature verification failed", err.Error())
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Invalid backup archive", err.Error())
+		return
+	}
+
+	doc := contents.Config
+	problems := doc.Validate(nil)
+	for i, hook := range contents.Webhooks {
+		if hook == nil {
+			problems = append(problems, fmt.Sprintf("webhooks[%d]: empty entry", i))
+			continue
+		}
+		if err := hook.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("webhooks[%d] (%s): %v", i, hook.ID, err))
+		}
+	}
+	if len(problems) > 0 {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", strings.Join(problems, "; "))
+		return
+	}
+
+	existingProviders, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	existingPolicies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	existingGroups, err := s.natsClient.ListGroups()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list groups", err.Error())
+		return
+	}
+	existingWebhooks, err := s.natsClient.ListWebhooks()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list webhooks", err.Error())
+		return
+	}
+
+	result := RestoreResult{
+		ImportResult: ImportResult{Mode: importModeReplace, DryRun: dryRun},
+		Backup:       contents.Manifest,
+		Signed:       contents.Manifest.Signature != "" && s.config.BackupSigningKey != "",
+	}
+	providerPlan := planProviders(doc.Providers, existingProviders, importModeReplace, &result.Providers)
+	policyPlan := planPolicies(doc.Policies, existingPolicies, importModeReplace, &result.Policies)
+	groupPlan := planGroups(doc.Groups, existingGroups, importModeReplace, &result.Groups)
+	webhookPlan := planWebhooks(contents.Webhooks, existingWebhooks, &result.Webhooks)
+	result.RequiresOverwrite = overwrites(result.Providers, result.Policies, result.Groups, result.Webhooks)
+
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if result.RequiresOverwrite && !overwrite {
+		c.JSON(http.StatusConflict, result)
+		return
+	}
+
+	s.applyImport(c, &result.ImportResult, providerPlan, policyPlan, groupPlan, existingProviders, existingPolicies, existingGroups)
+	s.applyWebhookRestore(c, &result, webhookPlan, existingWebhooks)
+	requestLog(c).Infof("Restored backup from %s (created by %s): providers %d created/%d updated/%d deleted, policies %d created/%d updated/%d deleted, %d error(s)",
+		contents.Manifest.CreatedAt.Format(time.RFC3339), contents.Manifest.CreatedBy,
+		len(result.Providers.Created), len(result.Providers.Updated), len(result.Providers.Deleted),
+		len(result.Policies.Created), len(result.Policies.Updated), len(result.Policies.Deleted),
+		len(result.Errors))
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, result)
+}
+
+// readArchiveBody returns the multipart "archive" file or, otherwise, the raw body.
+func readArchiveBody(c *gin.Context) ([]byte, error) {
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("archive")
+		if err != nil {
+			return nil, fmt.Errorf("multipart field \"archive\": %w", err)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxImportBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportBytes {
+		return nil, fmt.Errorf("archive exceeds %d bytes", maxImportBytes)
+	}
+	return data, nil
+}
+
+// overwrites reports whether any plan updates or deletes an existing record.
+func overwrites(changes ...ImportChanges) bool {
+	for _, ch := range changes {
+		if len(ch.Updated) > 0 || len(ch.Deleted) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// planWebhooks is planProviders for webhook subscriptions, always in replace mode.
+func planWebhooks(incoming, existing []*models.Webhook, changes *ImportChanges) []*models.Webhook {
+	current := make(map[string]*models.Webhook, len(existing))
+	for _, h := range existing {
+		current[h.ID] = h
+	}
+
+	var store []*models.Webhook
+	wanted := make(map[string]bool, len(incoming))
+	for _, h := range incoming {
+		wanted[h.ID] = true
+		prev, ok := current[h.ID]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, h.ID)
+			store = append(store, h)
+		case sameWebhook(prev, h):
+			changes.Unchanged = append(changes.Unchanged, h.ID)
+		default:
+			changes.Updated = append(changes.Updated, h.ID)
+			store = append(store, h)
+		}
+	}
+	for _, h := range existing {
+		if !wanted[h.ID] {
+			changes.Deleted = append(changes.Deleted, h.ID)
+		}
+	}
+	return store
+}
+
+// sameWebhook compares user-facing fields, ignoring timestamps.
+func sameWebhook(a, b *models.Webhook) bool {
+	return a.URL == b.URL &&
+		a.Secret == b.Secret &&
+		a.Enabled == b.Enabled &&
+		a.Description == b.Description &&
+		strings.Join(a.Events, ",") == strings.Join(b.Events, ",")
+}
+
+func (s *Server) applyWebhookRestore(c *gin.Context, result *RestoreResult, store, existing []*models.Webhook) {
+	prev := make(map[string]*models.Webhook, len(existing))
+	for _, h := range existing {
+		prev[h.ID] = h
+	}
+	snapshot := func(id string) json.RawMessage {
+		if h, ok := prev[id]; ok {
+			return auditSnapshot(webhookResponse(h))
+		}
+		return nil
+	}
+
+	for _, h := range store {
+		if err := s.natsClient.StoreWebhook(h); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("webhook %s: %v", h.ID, err))
+			continue
+		}
+		before := snapshot(h.ID)
+		s.recordAudit(c, auditStoreAction(before), models.AuditEntityWebhook, h.ID, before, auditSnapshot(webhookResponse(h)))
+	}
+	for _, id := range result.Webhooks.Deleted {
+		if err := s.natsClient.DeleteWebhook(id); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete webhook %s: %v", id, err))
+			continue
+		}
+		s.recordAudit(c, models.AuditActionDelete, models.AuditEntityWebhook, id, snapshot(id), nil)
+	}
+	s.webhooks.Invalidate()
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanWebhooks(t *testing.T) {
+	existing := []*models.Webhook{
+		{ID: "same", URL: "https://a.example.com", Enabled: true},
+		{ID: "changed", URL: "https://b.example.com", Secret: "old"},
+		{ID: "gone", URL: "https://c.example.com"},
+	}
+	incoming := []*models.Webhook{
+		{ID: "same", URL: "https://a.example.com", Enabled: true},
+		{ID: "changed", URL: "https://b.example.com", Secret: "new"},
+		{ID: "new", URL: "https://d.example.com"},
+	}
+
+	var changes ImportChanges
+	store := planWebhooks(incoming, existing, &changes)
+
+	assert.Equal(t, []string{"new"}, changes.Created)
+	assert.Equal(t, []string{"changed"}, changes.Updated)
+	assert.Equal(t, []string{"gone"}, changes.Deleted)
+	assert.Equal(t, []string{"same"}, changes.Unchanged)
+	assert.Len(t, store, 2)
+}
+
+func TestOverwrites(t *testing.T) {
+	assert.False(t, overwrites(ImportChanges{Created: []string{"a"}}, ImportChanges{Unchanged: []string{"b"}}))
+	assert.True(t, overwrites(ImportChanges{Created: []string{"a"}}, ImportChanges{Deleted: []string{"b"}}))
+}
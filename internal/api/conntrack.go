@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConntrackRouterResult is one router's answer to a conntrack query or flush.
+type ConntrackRouterResult struct {
+	Hostname string                 `json:"hostname"`
+	Flows    []models.ConntrackFlow `json:"flows,omitempty"`
+	Deleted  *int                   `json:"deleted,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// listConntrack lists tracked flows for a source on each router
+// @Summary List conntrack flows
+// @Description List connection tracking entries whose source is in src, on one router or every online router. Each flow is attributed to the egress interface/provider so you can confirm traffic moved after a policy change.
+// @Tags conntrack
+// @Produce json
+// @Param src query string true "Source IP or CIDR (underscore allowed instead of slash)"
+// @Param router query string false "Router hostname (default: all online routers)"
+// @Success 200 {array} ConntrackRouterResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/conntrack [get]
+// @Router /api/v2/conntrack [get]
+func (s *Server) listConntrack(c *gin.Context) {
+	s.runConntrackCommand(c, models.CommandConntrackList)
+}
+
+// flushConntrack deletes tracked flows for a source on each router
+// @Summary Flush conntrack flows
+// @Description Delete connection tracking entries whose source is in src, on one router or every online router, forcing new connections to follow the current policy.
+// @Tags conntrack
+// @Produce json
+// @Param src query string true "Source IP or CIDR (underscore allowed instead of slash)"
+// @Param router query string false "Router hostname (default: all online routers)"
+// @Success 200 {array} ConntrackRouterResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/conntrack [delete]
+// @Router /api/v2/conntrack [delete]
+func (s *Server) flushConntrack(c *gin.Context) {
+	s.runConntrackCommand(c, models.CommandConntrackFlush)
+}
+
+func (s *Server) runConntrackCommand(c *gin.Context, command string) {
+	// Same convention as sources in policy URLs: 192.168.2.0_25 means 192.168.2.0/25.
+	src := strings.ReplaceAll(c.Query("src"), "_", "/")
+	srcNet, err := models.ParseSource(src)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid source", err.Error())
+		return
+	}
+
+	hosts, err := s.targetRouters(c.Query("router"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	cmd := &models.AgentCommand{
+		Command: command,
+		Args:    map[string]string{"src": srcNet.String()},
+	}
+	if identity := identityFrom(c); identity != nil {
+		cmd.RequestedBy = identity.Subject
+	}
+
+	results := make([]ConntrackRouterResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = s.conntrackOnRouter(c, host, cmd)
+		}(i, host)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (s *Server) conntrackOnRouter(c *gin.Context, host string, cmd *models.AgentCommand) ConntrackRouterResult {
+	out := ConntrackRouterResult{Hostname: host}
+
+	reply, err := s.natsClient.SendAgentCommand(c.Request.Context(), host, cmd)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	if !reply.OK {
+		out.Error = reply.Error
+		return out
+	}
+
+	switch cmd.Command {
+	case models.CommandConntrackList:
+		if err := json.Unmarshal(reply.Data, &out.Flows); err != nil {
+			out.Error = err.Error()
+		}
+		if out.Flows == nil {
+			out.Flows = []models.ConntrackFlow{}
+		}
+	case models.CommandConntrackFlush:
+		var flushed models.ConntrackFlushResult
+		if err := json.Unmarshal(reply.Data, &flushed); err != nil {
+			out.Error = err.Error()
+		} else {
+			out.Deleted = &flushed.Deleted
+		}
+	}
+	return out
+}
+
+// targetRouters returns [hostname] when one is given, otherwise every router
+// with a fresh heartbeat.
+func (s *Server) targetRouters(hostname string) ([]string, error) {
+	if hostname != "" {
+		return []string{hostname}, nil
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	hosts := make([]string, 0, len(states))
+	for _, st := range states {
+		if now.Sub(st.LastSeen).Seconds() < 30 {
+			hosts = append(hosts, st.Hostname)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"router-sync/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsPolicy is the validated form of config.CORSConfig.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	suffixes    []originSuffix
+	methods     string
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// originSuffix matches "https://*.example.com" style entries.
+type originSuffix struct {
+	scheme string
+	suffix string
+}
+
+func newCORSPolicy(cfg config.CORSConfig) (*corsPolicy, error) {
+	p := &corsPolicy{
+		origins:     make(map[string]bool, len(cfg.AllowedOrigins)),
+		methods:     strings.Join(cfg.AllowedMethods, ", "),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		switch {
+		case o == "":
+		case o == "*":
+			p.anyOrigin = true
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(o, "://*.")
+			p.suffixes = append(p.suffixes, originSuffix{scheme: strings.ToLower(scheme), suffix: "." + strings.ToLower(host)})
+		case strings.Contains(o, "*"):
+			return nil, errors.New("wildcards are only supported as \"*\" or a leading subdomain (https://*.example.com): " + o)
+		default:
+			p.origins[strings.ToLower(o)] = true
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New("allow_credentials cannot be combined with allowed origin \"*\"")
+	}
+	return p, nil
+}
+
+// enabled reports whether any origin may be allowed at all.
+func (p *corsPolicy) enabled() bool {
+	return p.anyOrigin || len(p.origins) > 0 || len(p.suffixes) > 0
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	for _, s := range p.suffixes {
+		if s.scheme == scheme && strings.HasSuffix(host, s.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware lets a browser dashboard on another origin call the API.
+// Requests from origins outside the policy get no CORS headers, so the
+// browser blocks them; non-browser clients are unaffected.
+func corsMiddleware(p *corsPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin != "" && p.enabled() {
+			c.Header("Vary", "Origin")
+			if p.allows(origin) {
+				if p.anyOrigin {
+					c.Header("Access-Control-Allow-Origin", "*")
+				} else {
+					c.Header("Access-Control-Allow-Origin", origin)
+				}
+				if p.credentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+				if p.exposed != "" {
+					c.Header("Access-Control-Expose-Headers", p.exposed)
+				}
+				if preflight {
+					c.Header("Access-Control-Allow-Methods", p.methods)
+					c.Header("Access-Control-Allow-Headers", p.headers)
+					if p.maxAge != "" {
+						c.Header("Access-Control-Max-Age", p.maxAge)
+					}
+				}
+			}
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
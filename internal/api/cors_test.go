@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSPolicyAllows(t *testing.T) {
+	p, err := newCORSPolicy(config.CORSConfig{
+		AllowedOrigins: []string{"https://dash.example.com/", "https://*.lan.example.net"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, p.enabled())
+	assert.True(t, p.allows("https://dash.example.com"))
+	assert.True(t, p.allows("https://DASH.example.com"))
+	assert.True(t, p.allows("https://ui.lan.example.net"))
+	assert.False(t, p.allows("http://ui.lan.example.net"))
+	assert.False(t, p.allows("https://lan.example.net"))
+	assert.False(t, p.allows("https://evil.example.com"))
+}
+
+func TestCORSPolicyConfig(t *testing.T) {
+	p, err := newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+	assert.True(t, p.allows("http://anything:8080"))
+
+	p, err = newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{}})
+	require.NoError(t, err)
+	assert.False(t, p.enabled())
+
+	_, err = newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	assert.Error(t, err)
+
+	_, err = newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{"https://dash*.example.com"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"router-sync/internal/diff"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getDiff compares the desired state in NATS with what each agent reports
+// @Summary Pending changes
+// @Description Compare the providers and policies stored in NATS with the ip rules and routes each agent last reported. Each change is what the agent would add, remove or correct on its next sync; route changes flag provider tables missing a default route via the provider gateway.
+// @Tags routers
+// @Produce json
+// @Param router query string false "Limit to one router hostname"
+// @Success 200 {array} diff.RouterDiff
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/diff [get]
+// @Router /api/v2/diff [get]
+func (s *Server) getDiff(c *gin.Context) {
+	routerFilter := c.Query("router")
+
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	out := make([]diff.RouterDiff, 0, len(states))
+	for _, st := range states {
+		if routerFilter != "" && st.Hostname != routerFilter {
+			continue
+		}
+		out = append(out, diff.Compute(st, providers, policies))
+	}
+	if routerFilter != "" && len(out) == 0 {
+		respondError(c, http.StatusNotFound, "Router not found", "no state reported for "+routerFilter)
+		return
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DrainProviderRequest moves policies off a provider.
+// PolicyIDs limits the move to those policies; empty means all of them.
+type DrainProviderRequest struct {
+	TargetProviderID string   `json:"target_provider_id" binding:"required" example:"Starlink"`
+	PolicyIDs        []string `json:"policy_ids" example:"192.168.2.25,192.168.2.0/25"`
+	DryRun           bool     `json:"dry_run" example:"false"`
+}
+
+// DrainProviderResult lists the policies moved (or that would be moved on dry run).
+type DrainProviderResult struct {
+	SourceProviderID string   `json:"source_provider_id"`
+	TargetProviderID string   `json:"target_provider_id"`
+	DryRun           bool     `json:"dry_run"`
+	Moved            []string `json:"moved"`
+}
+
+// drainProvider reassigns policies from one provider to another
+// @Summary Drain provider
+// @Description Move all (or the selected) policies from this provider to target_provider_id. The move is all-or-nothing: each policy is written only if unchanged since it was read, and if any write fails (412 when a policy was edited concurrently) the ones already moved are reverted, except policies edited again in the meantime.
+// @Tags providers
+// @Accept json
+// @Produce json
+// @Param id path string true "Provider ID to drain"
+// @Param request body DrainProviderRequest true "Target provider and optional policy selection"
+// @Success 200 {object} DrainProviderResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/providers/{id}/drain [post]
+// @Router /api/v2/providers/{id}/drain [post]
+func (s *Server) drainProvider(c *gin.Context) {
+	id := c.Param("id")
+
+	var req DrainProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if _, err := s.natsClient.GetProvider(id); err != nil {
+		respondError(c, http.StatusNotFound, "Provider not found", err.Error())
+		return
+	}
+	if req.TargetProviderID == id {
+		respondError(c, http.StatusBadRequest, "Invalid target provider", "target_provider_id must differ from the drained provider")
+		return
+	}
+	if _, err := s.natsClient.GetProvider(req.TargetProviderID); err != nil {
+		respondError(c, http.StatusBadRequest, "Provider not found", fmt.Sprintf("Target provider '%s' does not exist", req.TargetProviderID))
+		return
+	}
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+
+	selected, err := selectDrainPolicies(policies, id, req.PolicyIDs)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid policy selection", err.Error())
+		return
+	}
+
+	result := DrainProviderResult{
+		SourceProviderID: id,
+		TargetProviderID: req.TargetProviderID,
+		DryRun:           req.DryRun,
+		Moved:            make([]string, 0, len(selected)),
+	}
+	for _, p := range selected {
+		result.Moved = append(result.Moved, p.ID)
+	}
+	if req.DryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// NATS KV has no multi-key transactions: apply one conditional write per
+	// policy, at the generation it was listed with, and compensate on the
+	// first failure. A policy edited since the listing fails the drain with
+	// 412 instead of losing that edit.
+	moved := make([]*models.RoutingPolicy, 0, len(selected))
+	for _, p := range selected {
+		updated := *p
+		updated.ProviderID = req.TargetProviderID
+		updated.UpdatedAt = time.Now()
+		if err := s.natsClient.StorePolicyIfMatch(&updated, p.Generation); err != nil {
+			s.rollbackDrain(moved, id)
+			writeStoreError(c, fmt.Sprintf("Failed to move policy %s; drain rolled back", p.ID), err)
+			return
+		}
+		moved = append(moved, &updated)
+	}
+
+	for i, p := range moved {
+		s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityPolicy, p.ID, auditSnapshot(selected[i]), auditSnapshot(p))
+	}
+	requestLog(c).Infof("Drained %d policies from provider %s to %s", len(moved), id, req.TargetProviderID)
+	c.JSON(http.StatusOK, result)
+}
+
+// rollbackDrain points already-moved policies back at the original provider.
+// Each policy is re-read and only restored while it is still at the
+// generation the drain wrote; one edited in the meantime keeps that edit.
+func (s *Server) rollbackDrain(moved []*models.RoutingPolicy, originalProviderID string) {
+	for _, p := range moved {
+		current, err := s.natsClient.GetPolicy(p.ID)
+		if err != nil {
+			logrus.Errorf("Drain rollback failed for policy %s (left on provider %s): %v", p.ID, p.ProviderID, err)
+			continue
+		}
+		if current.Generation != p.Generation {
+			logrus.Warnf("Drain rollback skipped policy %s: changed since the drain (generation %d, wrote %d)", p.ID, current.Generation, p.Generation)
+			continue
+		}
+		restore := *current
+		restore.ProviderID = originalProviderID
+		restore.UpdatedAt = time.Now()
+		if err := s.natsClient.StorePolicyIfMatch(&restore, p.Generation); err != nil {
+			logrus.Errorf("Drain rollback failed for policy %s (left on provider %s): %v", p.ID, p.ProviderID, err)
+		}
+	}
+}
+
+// selectDrainPolicies returns the policies on providerID, limited to ids when given.
+// Every requested ID must exist, belong to providerID and appear once.
+func selectDrainPolicies(policies []*models.RoutingPolicy, providerID string, ids []string) ([]*models.RoutingPolicy, error) {
+	onProvider := make(map[string]*models.RoutingPolicy)
+	var all []*models.RoutingPolicy
+	for _, p := range policies {
+		if p.ProviderID == providerID {
+			onProvider[p.ID] = p
+			all = append(all, p)
+		}
+	}
+	if len(ids) == 0 {
+		return all, nil
+	}
+
+	out := make([]*models.RoutingPolicy, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("policy '%s' is listed more than once", id)
+		}
+		seen[id] = true
+		p, ok := onProvider[id]
+		if !ok {
+			return nil, fmt.Errorf("policy '%s' is not assigned to provider '%s'", id, providerID)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
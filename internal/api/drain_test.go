@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"router-sync/internal/models"
+	natsclient "router-sync/internal/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectDrainPolicies(t *testing.T) {
+	policies := []*models.RoutingPolicy{
+		{ID: "p1", ProviderID: "Telecom"},
+		{ID: "p2", ProviderID: "Telecom"},
+		{ID: "p3", ProviderID: "Starlink"},
+	}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		want    []string
+		wantErr string
+	}{
+		{name: "empty list means all", want: []string{"p1", "p2"}},
+		{name: "selection", ids: []string{"p2"}, want: []string{"p2"}},
+		{name: "unknown ID", ids: []string{"p9"}, wantErr: "not assigned"},
+		{name: "ID on another provider", ids: []string{"p1", "p3"}, wantErr: "not assigned"},
+		{name: "duplicate ID", ids: []string{"p1", "p1"}, wantErr: "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectDrainPolicies(policies, "Telecom", tt.ids)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			ids := make([]string, 0, len(got))
+			for _, p := range got {
+				ids = append(ids, p.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestDrainProvider_RollsBackOnFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	mockNATS.On("GetProvider", "Telecom").Return(&models.InternetProvider{ID: "Telecom"}, nil)
+	mockNATS.On("GetProvider", "Starlink").Return(&models.InternetProvider{ID: "Starlink"}, nil)
+	mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{
+		{ID: "p1", ProviderID: "Telecom", Generation: 3},
+		{ID: "p2", ProviderID: "Telecom", Generation: 5},
+		{ID: "p3", ProviderID: "Telecom", Generation: 1},
+	}, nil)
+
+	var stored []string
+	bump := func(args mock.Arguments) {
+		// The real client assigns the next generation on write.
+		p := args.Get(0).(*models.RoutingPolicy)
+		p.Generation = args.Get(1).(uint64) + 1
+		stored = append(stored, p.ID+"="+p.ProviderID)
+	}
+	// p1 and p2 move; p3 was edited since the listing.
+	mockNATS.On("StorePolicyIfMatch", mock.MatchedBy(func(p *models.RoutingPolicy) bool { return p.ID == "p3" }), uint64(1)).
+		Return(natsclient.ErrPreconditionFailed)
+	mockNATS.On("StorePolicyIfMatch", mock.AnythingOfType("*models.RoutingPolicy"), mock.AnythingOfType("uint64")).
+		Run(bump).Return(nil)
+	// During the rollback p1 is still as written, p2 was edited again.
+	mockNATS.On("GetPolicy", "p1").Return(&models.RoutingPolicy{ID: "p1", ProviderID: "Starlink", Generation: 4}, nil)
+	mockNATS.On("GetPolicy", "p2").Return(&models.RoutingPolicy{ID: "p2", ProviderID: "Starlink", Name: "edited", Generation: 7}, nil)
+
+	requestBody, _ := json.Marshal(DrainProviderRequest{TargetProviderID: "Starlink"})
+	req, _ := http.NewRequest("POST", "/api/v1/providers/Telecom/drain", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "Telecom"}}
+
+	server.drainProvider(c)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	// p1 and p2 were moved, then only p1 was restored: p2 keeps the
+	// concurrent edit.
+	assert.Equal(t, []string{"p1=Starlink", "p2=Starlink", "p1=Telecom"}, stored)
+	mockNATS.AssertNotCalled(t, "StorePolicy", mock.Anything)
+}
+
+func TestDrainProvider_RejectsDuplicateIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	mockNATS.On("GetProvider", "Telecom").Return(&models.InternetProvider{ID: "Telecom"}, nil)
+	mockNATS.On("GetProvider", "Starlink").Return(&models.InternetProvider{ID: "Starlink"}, nil)
+	mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{{ID: "p1", ProviderID: "Telecom"}}, nil)
+
+	requestBody, _ := json.Marshal(DrainProviderRequest{TargetProviderID: "Starlink", PolicyIDs: []string{"p1", "p1"}})
+	req, _ := http.NewRequest("POST", "/api/v1/providers/Telecom/drain", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "Telecom"}}
+
+	server.drainProvider(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockNATS.AssertNotCalled(t, "StorePolicyIfMatch", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"router-sync/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Error codes returned in APIError.Code. Codes are stable across releases;
+// clients should switch on the code, not the message.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal"
+)
+
+// requestIDKey is the gin context key holding the request ID.
+const requestIDKey = "request_id"
+
+// requestIDHeader carries the request ID in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// APIError is the error model returned by /api/v2.
+type APIError struct {
+	Code      string           `json:"code" example:"not_found"`
+	Message   string           `json:"message" example:"Provider not found"`
+	Details   string           `json:"details,omitempty" example:"no provider with ID isp1"`
+	Fields    []FieldViolation `json:"fields,omitempty"`
+	RequestID string           `json:"request_id,omitempty" example:"5f2b8c1d9e0a4b7c"`
+}
+
+// ErrorResponse is the /api/v2 error envelope. /api/v1 keeps its original
+// {"error": message, "details": details} body for existing clients.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// respondError aborts the request with an error in the shape of the API
+// version being served. The code is derived from status.
+func respondError(c *gin.Context, status int, message, details string) {
+	respondErrorCode(c, status, codeForStatus(status), message, details)
+}
+
+// respondErrorCode is respondError with an explicit error code.
+func respondErrorCode(c *gin.Context, status int, code, message, details string) {
+	if !isV2(c) {
+		c.AbortWithStatusJSON(status, gin.H{
+			"error":   message,
+			"details": details,
+		})
+		return
+	}
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: c.GetString(requestIDKey),
+	}})
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusPreconditionRequired:
+		return CodeBadRequest
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return CodeConflict
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+func isV2(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/api/v2/")
+}
+
+// requestIDMiddleware tags every request with an ID, reusing a sane
+// X-Request-ID from the caller (e.g. a proxy) and echoing it in the response.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestLog returns a logger carrying the request's ID, so lines logged
+// while handling it can be matched to the response and the access log.
+func requestLog(c *gin.Context) *logrus.Entry {
+	return logrus.WithField(logging.FieldRequestID, c.GetString(requestIDKey))
+}
+
+// accessLogMiddleware logs every request with its ID, status and duration:
+// at debug, since clients poll, or as a warning for server errors.
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		status := c.Writer.Status()
+		level := logrus.DebugLevel
+		if status >= http.StatusInternalServerError {
+			level = logrus.WarnLevel
+		}
+		requestLog(c).WithFields(logrus.Fields{
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"status":      status,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Logf(level, "%s %s %d", c.Request.Method, c.Request.URL.Path, status)
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// notFound answers unmatched /api/v2 paths with the error envelope; other
+// paths keep gin's default 404.
+func notFound(c *gin.Context) {
+	if isV2(c) {
+		respondError(c, http.StatusNotFound, "Not found", "no route for "+c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
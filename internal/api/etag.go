@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etagFor formats an entity generation as a strong ETag. The generation is
+// bumped on every stored write, so it identifies the version a client read.
+func etagFor(generation uint64) string {
+	return `"` + strconv.FormatUint(generation, 10) + `"`
+}
+
+// setETag sets the ETag response header for an entity at generation.
+func setETag(c *gin.Context, generation uint64) {
+	c.Header("ETag", etagFor(generation))
+}
+
+// checkIfMatch validates the If-Match header against the entity's current
+// generation. It returns the generation the write must still find in NATS
+// (0 for no check: header absent or "*") and false after answering 412 (stale
+// ETag) or 428 (header missing while api.require_if_match is set).
+func (s *Server) checkIfMatch(c *gin.Context, current uint64) (uint64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if s.config.RequireIfMatch {
+			respondError(c, http.StatusPreconditionRequired, "If-Match required",
+				"send the ETag from a GET of this resource in If-Match")
+			return 0, false
+		}
+		return 0, true
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	// If-Match uses the strong comparison (RFC 9110 section 13.1.1): a weak
+	// tag never matches, since it does not pin the exact version.
+	want := etagFor(current)
+	weak := false
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			weak = true
+			continue
+		}
+		if tag == want {
+			return current, true
+		}
+	}
+	c.Header("ETag", want)
+	if weak {
+		respondError(c, http.StatusPreconditionFailed, "Weak ETag in If-Match",
+			"If-Match needs the strong ETag "+want+" from a GET of this resource; weak (W/) tags never match")
+		return 0, false
+	}
+	respondError(c, http.StatusPreconditionFailed, "Resource has changed",
+		"If-Match "+header+" does not match the current ETag "+want+"; fetch it again and retry")
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		header  string
+		require bool
+		wantGen uint64
+		wantOK  bool
+		status  int
+	}{
+		{name: "absent", wantOK: true},
+		{name: "absent but required", require: true, status: http.StatusPreconditionRequired},
+		{name: "any", header: "*", wantOK: true},
+		{name: "current", header: `"4"`, wantGen: 4, wantOK: true},
+		{name: "one of several", header: `"3", "4"`, wantGen: 4, wantOK: true},
+		{name: "stale", header: `"3"`, status: http.StatusPreconditionFailed},
+		{name: "weak", header: `W/"4"`, status: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{config: config.APIConfig{RequireIfMatch: tt.require}}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("PUT", "/api/v1/policies/p1", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("If-Match", tt.header)
+			}
+
+			gen, ok := server.checkIfMatch(c, 4)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantGen, gen)
+			if !ok {
+				assert.Equal(t, tt.status, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"router-sync/internal/config"
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+	"router-sync/internal/nats"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultPolicyExpiryInterval = 30 * time.Second
+
+// runExpiryLoop disables or deletes expired policies until ctx is done.
+// Agents already stop applying a policy once it expires; this makes the
+// store agree and leaves a trace in the audit log.
+func (s *Server) runExpiryLoop(ctx context.Context) {
+	interval := s.config.PolicyExpiryInterval
+	if interval <= 0 {
+		interval = defaultPolicyExpiryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.expirePolicies(time.Now()); err != nil {
+			logrus.Warnf("Failed to expire policies: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirePolicies applies api.policy_expiry_action to every enabled policy
+// that expired at or before now. Writes are conditional on the generation
+// read, so with several API replicas only one of them acts on a policy.
+func (s *Server) expirePolicies(now time.Time) error {
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return fmt.Errorf("failed to list policies: %w", err)
+	}
+
+	for _, p := range policies {
+		if !p.Enabled || !p.Expired(now) {
+			continue
+		}
+		log := logging.Policy(p.ID, p.ProviderID)
+		before := auditSnapshot(p)
+
+		if s.config.PolicyExpiryAction == config.ExpiryDelete {
+			current, err := s.natsClient.GetPolicy(p.ID)
+			if err != nil || current.Generation != p.Generation {
+				continue // deleted or changed meanwhile
+			}
+			if err := s.natsClient.DeletePolicy(p.ID); err != nil {
+				log.Warnf("Failed to delete expired policy %s: %v", p.Name, err)
+				continue
+			}
+			log.Infof("Deleted policy %s: expired at %s", p.Name, p.ExpiresAt.Format(time.RFC3339))
+			s.recordSystemAudit(models.AuditActionExpire, models.AuditEntityPolicy, p.ID, before, nil)
+			continue
+		}
+
+		generation := p.Generation
+		p.Enabled = false
+		p.UpdatedAt = now
+		if err := s.natsClient.StorePolicyIfMatch(p, generation); err != nil {
+			if !errors.Is(err, nats.ErrPreconditionFailed) {
+				log.Warnf("Failed to disable expired policy %s: %v", p.Name, err)
+			}
+			continue
+		}
+		log.Infof("Disabled policy %s: expired at %s", p.Name, p.ExpiresAt.Format(time.RFC3339))
+		s.recordSystemAudit(models.AuditActionExpire, models.AuditEntityPolicy, p.ID, before, auditSnapshot(p))
+	}
+	return nil
+}
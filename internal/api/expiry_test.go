@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"router-sync/internal/config"
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func expiryTestPolicies(now time.Time) []*models.RoutingPolicy {
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	return []*models.RoutingPolicy{
+		{ID: "10.0.0.1", Name: "expired", ProviderID: "isp", Enabled: true, ExpiresAt: &past, Generation: 3},
+		{ID: "10.0.0.2", Name: "later", ProviderID: "isp", Enabled: true, ExpiresAt: &future, Generation: 1},
+		{ID: "10.0.0.3", Name: "permanent", ProviderID: "isp", Enabled: true, Generation: 1},
+		{ID: "10.0.0.4", Name: "already off", ProviderID: "isp", ExpiresAt: &past, Generation: 1},
+	}
+}
+
+func TestExpirePolicies_Disable(t *testing.T) {
+	now := time.Now()
+	mockNATS := &MockNATSClient{}
+	mockNATS.On("ListPolicies").Return(expiryTestPolicies(now), nil)
+	mockNATS.On("StorePolicyIfMatch", mock.MatchedBy(func(p *models.RoutingPolicy) bool {
+		return p.ID == "10.0.0.1" && !p.Enabled
+	}), uint64(3)).Return(nil)
+
+	server := &Server{natsClient: mockNATS, config: config.APIConfig{PolicyExpiryAction: config.ExpiryDisable}}
+	require.NoError(t, server.expirePolicies(now))
+
+	mockNATS.AssertExpectations(t)
+	mockNATS.AssertNumberOfCalls(t, "StorePolicyIfMatch", 1)
+}
+
+func TestExpirePolicies_Delete(t *testing.T) {
+	now := time.Now()
+	policies := expiryTestPolicies(now)
+	mockNATS := &MockNATSClient{}
+	mockNATS.On("ListPolicies").Return(policies, nil)
+	mockNATS.On("GetPolicy", "10.0.0.1").Return(policies[0], nil)
+	mockNATS.On("DeletePolicy", "10.0.0.1").Return(nil)
+
+	server := &Server{natsClient: mockNATS, config: config.APIConfig{PolicyExpiryAction: config.ExpiryDelete}}
+	require.NoError(t, server.expirePolicies(now))
+
+	mockNATS.AssertExpectations(t)
+	mockNATS.AssertNumberOfCalls(t, "DeletePolicy", 1)
+	mockNATS.AssertNotCalled(t, "StorePolicyIfMatch", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// FleetNode is an agent's last heartbeat with its liveness: State is alive,
+// dead (models.HeartbeatMissedLimit intervals without a heartbeat) or stopped
+// (shut down cleanly).
+type FleetNode struct {
+	models.Heartbeat
+	State      string  `json:"state"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+// FleetResponse lists every agent that sent a heartbeat in the last 24
+// hours, with counts per state.
+type FleetResponse struct {
+	Nodes   []FleetNode `json:"nodes"`
+	Alive   int         `json:"alive"`
+	Dead    int         `json:"dead"`
+	Stopped int         `json:"stopped"`
+}
+
+// buildFleet classifies heartbeats at now, sorted by hostname.
+func buildFleet(heartbeats []*models.Heartbeat, now time.Time) FleetResponse {
+	out := FleetResponse{Nodes: make([]FleetNode, 0, len(heartbeats))}
+	for _, hb := range heartbeats {
+		node := FleetNode{Heartbeat: *hb, State: hb.State(now), AgeSeconds: now.Sub(hb.Timestamp).Seconds()}
+		switch node.State {
+		case models.AgentAlive:
+			out.Alive++
+		case models.AgentDead:
+			out.Dead++
+		case models.AgentStopped:
+			out.Stopped++
+		}
+		out.Nodes = append(out.Nodes, node)
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool { return out.Nodes[i].Hostname < out.Nodes[j].Hostname })
+	return out
+}
+
+// listFleet returns the agents' heartbeats
+// @Summary Fleet heartbeats
+// @Description List the last heartbeat of every agent seen in the last 24 hours (version, applied generation, readiness, rule counts, provider health) with its state: alive, dead after 3 missed heartbeats, or stopped after a clean shutdown. Agents also publish heartbeats on the NATS subjects router-sync.heartbeat.<hostname>.
+// @Tags nodes
+// @Produce json
+// @Success 200 {object} FleetResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/fleet [get]
+// @Router /api/v2/fleet [get]
+func (s *Server) listFleet(c *gin.Context) {
+	heartbeats, err := s.natsClient.ListHeartbeats()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list heartbeats", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, buildFleet(heartbeats, time.Now().UTC()))
+}
+
+// deleteFleetNode forgets an agent's heartbeat
+// @Summary Forget a fleet node
+// @Description Remove a decommissioned agent's last heartbeat so it no longer shows as dead. A running agent reappears with its next heartbeat.
+// @Tags nodes
+// @Param hostname path string true "Agent hostname"
+// @Success 204
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/fleet/{hostname} [delete]
+// @Router /api/v2/fleet/{hostname} [delete]
+func (s *Server) deleteFleetNode(c *gin.Context) {
+	if err := s.natsClient.DeleteHeartbeat(c.Param("hostname")); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to delete heartbeat", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// refreshFleetGauge sets fleet_agents from the stored heartbeats.
+func (s *Server) refreshFleetGauge() {
+	heartbeats, err := s.natsClient.ListHeartbeats()
+	if err != nil {
+		logrus.Warnf("Failed to list heartbeats: %v", err)
+		return
+	}
+	fleet := buildFleet(heartbeats, time.Now().UTC())
+	s.fleetAgents.WithLabelValues(models.AgentAlive).Set(float64(fleet.Alive))
+	s.fleetAgents.WithLabelValues(models.AgentDead).Set(float64(fleet.Dead))
+	s.fleetAgents.WithLabelValues(models.AgentStopped).Set(float64(fleet.Stopped))
+}
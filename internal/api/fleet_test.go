@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFleet(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	heartbeats := []*models.Heartbeat{
+		{Hostname: "r3", Timestamp: now.Add(-time.Hour), IntervalSeconds: 5, Stopped: true},
+		{Hostname: "r1", Timestamp: now.Add(-2 * time.Second), IntervalSeconds: 5, Ready: true},
+		{Hostname: "r2", Timestamp: now.Add(-time.Minute), IntervalSeconds: 5},
+	}
+
+	got := buildFleet(heartbeats, now)
+	require.Len(t, got.Nodes, 3)
+	assert.Equal(t, []string{"r1", "r2", "r3"}, []string{got.Nodes[0].Hostname, got.Nodes[1].Hostname, got.Nodes[2].Hostname})
+	assert.Equal(t, []string{models.AgentAlive, models.AgentDead, models.AgentStopped}, []string{got.Nodes[0].State, got.Nodes[1].State, got.Nodes[2].State})
+	assert.Equal(t, 60.0, got.Nodes[1].AgeSeconds)
+	assert.Equal(t, 1, got.Alive)
+	assert.Equal(t, 1, got.Dead)
+	assert.Equal(t, 1, got.Stopped)
+}
+
+func TestListFleet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	router := gin.New()
+	router.GET("/fleet", server.listFleet)
+	router.DELETE("/fleet/:hostname", server.deleteFleetNode)
+
+	mockNATS.On("ListHeartbeats").Return([]*models.Heartbeat{
+		{Hostname: "r1", Version: "1.2.0", Timestamp: time.Now().UTC(), IntervalSeconds: 5, Ready: true, Rules: 12, ManagedRules: 3,
+			Providers: []models.ProviderHealth{{ProviderID: "Telecom", Up: true}}},
+	}, nil)
+	mockNATS.On("DeleteHeartbeat", "r9").Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fleet", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var got FleetResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Len(t, got.Nodes, 1)
+	assert.Equal(t, models.AgentAlive, got.Nodes[0].State)
+	assert.Equal(t, "1.2.0", got.Nodes[0].Version)
+	assert.Equal(t, 3, got.Nodes[0].ManagedRules)
+	assert.Equal(t, "Telecom", got.Nodes[0].Providers[0].ProviderID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/fleet/r9", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockNATS.AssertExpectations(t)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GatewayRouterResult is one router's gateway suggestion for an interface.
+type GatewayRouterResult struct {
+	Hostname string `json:"hostname"`
+	*models.GatewaySuggestion
+	Error string `json:"error,omitempty"`
+}
+
+// suggestGateway asks agents for the likely gateway of an interface
+// @Summary Suggest interface gateway
+// @Description Ask each router that has the interface (or only the given router) for its likely gateway, from DHCP leases, kernel routes through the interface and the neighbor (ARP) table. Use the result to pre-fill the gateway when creating a provider.
+// @Tags routers
+// @Produce json
+// @Param name path string true "Interface name"
+// @Param router query string false "Router hostname (default: every online router reporting the interface)"
+// @Success 200 {array} GatewayRouterResult
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/interfaces/{name}/gateway [get]
+// @Router /api/v2/interfaces/{name}/gateway [get]
+func (s *Server) suggestGateway(c *gin.Context) {
+	name := c.Param("name")
+
+	hosts, err := s.targetRouters(c.Query("router"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+	if c.Query("router") == "" {
+		hosts, err = s.routersWithInterface(hosts, name)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+			return
+		}
+		if len(hosts) == 0 {
+			respondError(c, http.StatusNotFound, "Interface not found", "no online router reports interface "+name)
+			return
+		}
+	}
+
+	cmd := &models.AgentCommand{
+		Command: models.CommandGatewaySuggest,
+		Args:    map[string]string{"interface": name},
+	}
+	if identity := identityFrom(c); identity != nil {
+		cmd.RequestedBy = identity.Subject
+	}
+
+	results := make([]GatewayRouterResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = s.gatewayOnRouter(c, host, cmd)
+		}(i, host)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (s *Server) gatewayOnRouter(c *gin.Context, host string, cmd *models.AgentCommand) GatewayRouterResult {
+	out := GatewayRouterResult{Hostname: host}
+
+	reply, err := s.natsClient.SendAgentCommand(c.Request.Context(), host, cmd)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	if !reply.OK {
+		out.Error = reply.Error
+		return out
+	}
+	var suggestion models.GatewaySuggestion
+	if err := json.Unmarshal(reply.Data, &suggestion); err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.GatewaySuggestion = &suggestion
+	return out
+}
+
+// routersWithInterface keeps the hosts whose last heartbeat lists iface.
+func (s *Server) routersWithInterface(hosts []string, iface string) ([]string, error) {
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		return nil, err
+	}
+	online := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		online[h] = true
+	}
+	out := make([]string, 0, len(hosts))
+	for _, st := range states {
+		if !online[st.Hostname] {
+			continue
+		}
+		if routerHasInterface(st, iface) {
+			out = append(out, st.Hostname)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,527 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateGroupRequest represents a request to create a policy group.
+// The group ID will be set to the name field. PolicyIDs are existing policies
+// to add to the group; none of them may already belong to another group.
+type CreateGroupRequest struct {
+	Name        string   `json:"name" binding:"required" example:"IoT VLAN"`
+	Description string   `json:"description" example:"Cameras, plugs and sensors"`
+	PolicyIDs   []string `json:"policy_ids" example:"192.168.30.0/24,192.168.2.40"`
+}
+
+// UpdateGroupRequest updates a group's description and, when PolicyIDs is
+// present (even empty), replaces its membership.
+type UpdateGroupRequest struct {
+	Description string    `json:"description" example:"Cameras, plugs and sensors"`
+	PolicyIDs   *[]string `json:"policy_ids" example:"192.168.30.0/24"`
+}
+
+// GroupProviderRequest moves every policy of a group to another provider.
+type GroupProviderRequest struct {
+	ProviderID string `json:"provider_id" binding:"required" example:"Starlink"`
+	DryRun     bool   `json:"dry_run" example:"false"`
+}
+
+// PolicyGroupResponse is a group plus its membership, computed from the
+// policies' group_id.
+type PolicyGroupResponse struct {
+	models.PolicyGroup
+	Policies     []string `json:"policies"`
+	EnabledCount int      `json:"enabled_count"`
+	ProviderIDs  []string `json:"provider_ids"`
+}
+
+// GroupOperationResult lists the member policies changed by a group-level
+// operation (or that would change, on dry run).
+type GroupOperationResult struct {
+	GroupID string   `json:"group_id"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+	Changed []string `json:"changed"`
+}
+
+// listGroups lists all policy groups
+// @Summary List policy groups
+// @Description Get all policy groups with their member policies
+// @Tags groups
+// @Produce json
+// @Success 200 {array} PolicyGroupResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups [get]
+// @Router /api/v2/groups [get]
+func (s *Server) listGroups(c *gin.Context) {
+	groups, err := s.natsClient.ListGroups()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list groups", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	out := make([]PolicyGroupResponse, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, groupResponse(g, groupMembers(policies, g.ID)))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// createGroup creates a new policy group
+// @Summary Create policy group
+// @Description Create a policy group. The group ID will be set to the name field. Policies listed in policy_ids join the group; a policy can only belong to one group.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param group body CreateGroupRequest true "Group information"
+// @Success 201 {object} PolicyGroupResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Group with same name already exists"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups [post]
+// @Router /api/v2/groups [post]
+func (s *Server) createGroup(c *gin.Context) {
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if existing, err := s.natsClient.GetGroup(req.Name); err == nil && existing != nil {
+		respondError(c, http.StatusConflict, "Group already exists", fmt.Sprintf("A group with name '%s' already exists", req.Name))
+		return
+	}
+
+	now := time.Now()
+	group := &models.PolicyGroup{
+		ID:          req.Name,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := group.Validate(); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	joining, err := selectGroupPolicies(policies, group.ID, req.PolicyIDs)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid policy selection", err.Error())
+		return
+	}
+
+	if err := s.natsClient.StoreGroup(group); err != nil {
+		writeStoreError(c, "Failed to create group", err)
+		return
+	}
+	s.recordAudit(c, models.AuditActionCreate, models.AuditEntityGroup, group.ID, nil, auditSnapshot(group))
+
+	if _, err := s.updateGroupPolicies(c, joining, func(p *models.RoutingPolicy) { p.GroupID = group.ID }); err != nil {
+		// The group exists but is empty; the caller can retry the membership with PUT.
+		writeStoreError(c, "Group created but failed to add policies", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, groupResponse(group, joining))
+}
+
+// getGroup gets a specific policy group
+// @Summary Get policy group
+// @Description Get a policy group and its member policies
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Success 200 {object} PolicyGroupResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id} [get]
+// @Router /api/v2/groups/{id} [get]
+func (s *Server) getGroup(c *gin.Context) {
+	group, err := s.natsClient.GetGroup(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Group not found", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+
+	setETag(c, group.Generation)
+	c.JSON(http.StatusOK, groupResponse(group, groupMembers(policies, group.ID)))
+}
+
+// updateGroup updates a policy group
+// @Summary Update policy group
+// @Description Update the group's description. When policy_ids is present it replaces the membership: listed policies join, other current members leave (the policies themselves are kept). Send the ETag from GET in If-Match to reject the update (412) if the group changed in the meantime.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param If-Match header string false "ETag from a previous GET (required with api.require_if_match)"
+// @Param group body UpdateGroupRequest true "Group information"
+// @Success 200 {object} PolicyGroupResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse "If-Match does not match the current ETag"
+// @Failure 428 {object} ErrorResponse "If-Match required"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id} [put]
+// @Router /api/v2/groups/{id} [put]
+func (s *Server) updateGroup(c *gin.Context) {
+	var req UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	group, err := s.natsClient.GetGroup(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Group not found", err.Error())
+		return
+	}
+	ifGeneration, ok := s.checkIfMatch(c, group.Generation)
+	if !ok {
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+
+	members := groupMembers(policies, group.ID)
+	if req.PolicyIDs != nil {
+		joining, err := selectGroupPolicies(policies, group.ID, *req.PolicyIDs)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid policy selection", err.Error())
+			return
+		}
+		keep := make(map[string]bool, len(joining))
+		for _, p := range joining {
+			keep[p.ID] = true
+		}
+		var changed []*models.RoutingPolicy
+		for _, p := range members {
+			if !keep[p.ID] {
+				changed = append(changed, p)
+			}
+		}
+		for _, p := range joining {
+			if p.GroupID != group.ID {
+				changed = append(changed, p)
+			}
+		}
+		if _, err := s.updateGroupPolicies(c, changed, func(p *models.RoutingPolicy) {
+			if keep[p.ID] {
+				p.GroupID = group.ID
+			} else {
+				p.GroupID = ""
+			}
+		}); err != nil {
+			writeStoreError(c, "Failed to update group membership; changes rolled back", err)
+			return
+		}
+		members = joining
+	}
+
+	if group.Description != req.Description {
+		before := auditSnapshot(group)
+		group.Description = req.Description
+		group.UpdatedAt = time.Now()
+		if err := s.natsClient.StoreGroupIfMatch(group, ifGeneration); err != nil {
+			writeStoreError(c, "Failed to update group", err)
+			return
+		}
+		s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityGroup, group.ID, before, auditSnapshot(group))
+	}
+
+	setETag(c, group.Generation)
+	c.JSON(http.StatusOK, groupResponse(group, members))
+}
+
+// deleteGroup deletes a policy group
+// @Summary Delete policy group
+// @Description Delete a policy group. Member policies are kept and simply leave the group, unless delete_policies=true, in which case they are deleted too.
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param delete_policies query bool false "Also delete the member policies"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id} [delete]
+// @Router /api/v2/groups/{id} [delete]
+func (s *Server) deleteGroup(c *gin.Context) {
+	group, err := s.natsClient.GetGroup(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Group not found", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	members := groupMembers(policies, group.ID)
+
+	if c.Query("delete_policies") == "true" {
+		for _, p := range members {
+			if err := s.natsClient.DeletePolicy(p.ID); err != nil {
+				respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to delete policy %s", p.ID), err.Error())
+				return
+			}
+			s.recordAudit(c, models.AuditActionDelete, models.AuditEntityPolicy, p.ID, auditSnapshot(p), nil)
+		}
+	} else if _, err := s.updateGroupPolicies(c, members, func(p *models.RoutingPolicy) { p.GroupID = "" }); err != nil {
+		writeStoreError(c, "Failed to remove policies from group; changes rolled back", err)
+		return
+	}
+
+	if err := s.natsClient.DeleteGroup(group.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to delete group", err.Error())
+		return
+	}
+	s.recordAudit(c, models.AuditActionDelete, models.AuditEntityGroup, group.ID, auditSnapshot(group), nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// enableGroup enables every policy in a group
+// @Summary Enable policy group
+// @Description Set enabled=true on every member policy. All-or-nothing: on a failed write the policies already changed are reverted.
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Success 200 {object} GroupOperationResult
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id}/enable [post]
+// @Router /api/v2/groups/{id}/enable [post]
+func (s *Server) enableGroup(c *gin.Context) {
+	s.setGroupEnabled(c, true)
+}
+
+// disableGroup disables every policy in a group
+// @Summary Disable policy group
+// @Description Set enabled=false on every member policy. All-or-nothing: on a failed write the policies already changed are reverted.
+// @Tags groups
+// @Produce json
+// @Param id path string true "Group ID"
+// @Success 200 {object} GroupOperationResult
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id}/disable [post]
+// @Router /api/v2/groups/{id}/disable [post]
+func (s *Server) disableGroup(c *gin.Context) {
+	s.setGroupEnabled(c, false)
+}
+
+func (s *Server) setGroupEnabled(c *gin.Context, enabled bool) {
+	group, members, ok := s.loadGroupMembers(c)
+	if !ok {
+		return
+	}
+
+	// Members already in the requested state are left alone, as for single policies.
+	var pending []*models.RoutingPolicy
+	for _, p := range members {
+		if p.Enabled != enabled {
+			pending = append(pending, p)
+		}
+	}
+
+	changed, err := s.updateGroupPolicies(c, pending, func(p *models.RoutingPolicy) { p.Enabled = enabled })
+	if err != nil {
+		writeStoreError(c, "Failed to update group policies; changes rolled back", err)
+		return
+	}
+
+	requestLog(c).Infof("Set enabled=%t on %d policies of group %s", enabled, len(changed), group.ID)
+	c.JSON(http.StatusOK, GroupOperationResult{GroupID: group.ID, Changed: changed})
+}
+
+// setGroupProvider moves every policy in a group to another provider
+// @Summary Reassign policy group
+// @Description Point every member policy at provider_id. All-or-nothing: on a failed write the policies already moved are reverted.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param request body GroupProviderRequest true "Target provider"
+// @Success 200 {object} GroupOperationResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/groups/{id}/provider [post]
+// @Router /api/v2/groups/{id}/provider [post]
+func (s *Server) setGroupProvider(c *gin.Context) {
+	var req GroupProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	group, members, ok := s.loadGroupMembers(c)
+	if !ok {
+		return
+	}
+	if _, err := s.natsClient.GetProvider(req.ProviderID); err != nil {
+		respondError(c, http.StatusBadRequest, "Provider not found", fmt.Sprintf("Provider '%s' does not exist", req.ProviderID))
+		return
+	}
+
+	var pending []*models.RoutingPolicy
+	for _, p := range members {
+		if p.ProviderID != req.ProviderID {
+			pending = append(pending, p)
+		}
+	}
+
+	if req.DryRun {
+		changed := make([]string, 0, len(pending))
+		for _, p := range pending {
+			changed = append(changed, p.ID)
+		}
+		c.JSON(http.StatusOK, GroupOperationResult{GroupID: group.ID, DryRun: true, Changed: changed})
+		return
+	}
+
+	changed, err := s.updateGroupPolicies(c, pending, func(p *models.RoutingPolicy) { p.ProviderID = req.ProviderID })
+	if err != nil {
+		writeStoreError(c, "Failed to move group policies; changes rolled back", err)
+		return
+	}
+
+	requestLog(c).Infof("Moved %d policies of group %s to provider %s", len(changed), group.ID, req.ProviderID)
+	c.JSON(http.StatusOK, GroupOperationResult{GroupID: group.ID, Changed: changed})
+}
+
+// loadGroupMembers resolves the :id group and its member policies, writing
+// the error response itself when it returns false.
+func (s *Server) loadGroupMembers(c *gin.Context) (*models.PolicyGroup, []*models.RoutingPolicy, bool) {
+	group, err := s.natsClient.GetGroup(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Group not found", err.Error())
+		return nil, nil, false
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return nil, nil, false
+	}
+	return group, groupMembers(policies, group.ID), true
+}
+
+// updateGroupPolicies applies mutate to each policy and stores it. NATS KV has
+// no multi-key transactions, so on the first failed write the policies already
+// stored are written back with their previous values. Returns the changed IDs.
+func (s *Server) updateGroupPolicies(c *gin.Context, policies []*models.RoutingPolicy, mutate func(*models.RoutingPolicy)) ([]string, error) {
+	changed := make([]string, 0, len(policies))
+	var stored []*models.RoutingPolicy
+	for _, p := range policies {
+		updated := *p
+		mutate(&updated)
+		updated.UpdatedAt = time.Now()
+		if err := s.natsClient.StorePolicy(&updated); err != nil {
+			for _, prev := range stored {
+				restore := *prev
+				restore.UpdatedAt = time.Now()
+				if rerr := s.natsClient.StorePolicy(&restore); rerr != nil {
+					requestLog(c).Errorf("Group rollback failed for policy %s: %v", prev.ID, rerr)
+				}
+			}
+			return nil, fmt.Errorf("policy %s: %w", p.ID, err)
+		}
+		stored = append(stored, p)
+		changed = append(changed, p.ID)
+		s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityPolicy, p.ID, auditSnapshot(p), auditSnapshot(&updated))
+	}
+	return changed, nil
+}
+
+// selectGroupPolicies resolves ids to policies that may join groupID: each
+// must exist and not belong to a different group.
+func selectGroupPolicies(policies []*models.RoutingPolicy, groupID string, ids []string) ([]*models.RoutingPolicy, error) {
+	byID := make(map[string]*models.RoutingPolicy, len(policies))
+	for _, p := range policies {
+		byID[p.ID] = p
+	}
+
+	out := make([]*models.RoutingPolicy, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("policy '%s' does not exist", id)
+		}
+		if p.GroupID != "" && p.GroupID != groupID {
+			return nil, fmt.Errorf("policy '%s' already belongs to group '%s'", id, p.GroupID)
+		}
+		if p.Tenant != "" {
+			return nil, fmt.Errorf("policy '%s' belongs to tenant '%s' and cannot join groups", id, p.Tenant)
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// groupMembers returns the policies whose group_id is groupID, sorted by ID.
+func groupMembers(policies []*models.RoutingPolicy, groupID string) []*models.RoutingPolicy {
+	var out []*models.RoutingPolicy
+	for _, p := range policies {
+		if p.GroupID == groupID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func groupResponse(group *models.PolicyGroup, members []*models.RoutingPolicy) PolicyGroupResponse {
+	resp := PolicyGroupResponse{
+		PolicyGroup: *group,
+		Policies:    make([]string, 0, len(members)),
+		ProviderIDs: []string{},
+	}
+	providers := make(map[string]bool)
+	for _, p := range members {
+		resp.Policies = append(resp.Policies, p.ID)
+		if p.Enabled {
+			resp.EnabledCount++
+		}
+		if !providers[p.ProviderID] {
+			providers[p.ProviderID] = true
+			resp.ProviderIDs = append(resp.ProviderIDs, p.ProviderID)
+		}
+	}
+	sort.Strings(resp.ProviderIDs)
+	return resp
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"router-sync/internal/logging"
 	"router-sync/internal/models"
 	natsclient "router-sync/internal/nats"
 
@@ -18,45 +21,91 @@ import (
 // Either Interface (legacy) or Interfaces (map of hostname -> interface name)
 // can be provided. Interfaces takes precedence and is the preferred form.
 type CreateProviderRequest struct {
-	Name        string            `json:"name" binding:"required" example:"Telecom"`
-	Interface   string            `json:"interface" example:"eth0"`
-	Interfaces  map[string]string `json:"interfaces" example:"{\"r1\":\"eth1\",\"r2\":\"eth2\"}"`
-	TableID     int               `json:"table_id" binding:"required,min=1" example:"100"`
-	Gateway     string            `json:"gateway" binding:"required" example:"192.168.1.1"`
-	Description string            `json:"description" example:"Primary internet connection"`
+	Name             string              `json:"name" binding:"required" example:"Telecom"`
+	Interface        string              `json:"interface" binding:"omitempty,ifname" example:"eth0"`
+	Interfaces       map[string]string   `json:"interfaces" binding:"omitempty,dive,keys,required,endkeys,ifname" example:"{\"r1\":\"eth1\",\"r2\":\"eth2\"}"`
+	TableID          int                 `json:"table_id" binding:"required,table_id" example:"100"`
+	Gateway          string              `json:"gateway" binding:"required_without=GatewayV6,omitempty,ip" example:"192.168.1.1"`
+	GatewayV6        string              `json:"gateway_v6,omitempty" binding:"omitempty,ipv6" example:"fe80::1"`
+	InterfaceV6      string              `json:"interface_v6,omitempty" binding:"omitempty,ifname" example:"ppp0"` // IPv6 interface when it differs from the IPv4 one
+	Description      string              `json:"description" example:"Primary internet connection"`
+	Labels           map[string]string   `json:"labels" example:"{\"site\":\"hq\"}"`
+	HealthCheck      *models.HealthCheck `json:"health_check,omitempty"`                           // defaults are filled in on save
+	Weight           int                 `json:"weight,omitempty" example:"1"`                     // 1-100, share of traffic when load balancing
+	FailoverPriority int                 `json:"failover_priority,omitempty" example:"1"`          // failover order, lowest first; unique when set
+	MonthlyCap       int64               `json:"monthly_cap,omitempty" example:"1099511627776"`    // data allowance in bytes per billing period; 0 means unlimited
+	CapResetDay      int                 `json:"cap_reset_day,omitempty" example:"1"`              // day of month (1-28) the billing period starts
+	CapDrainPercent  int                 `json:"cap_drain_percent,omitempty" example:"90"`         // share of monthly_cap that triggers the drain (default 90)
+	CapDrainTargetID string              `json:"cap_drain_target_id,omitempty" example:"Starlink"` // provider the policies move to near the cap
 }
 
 // UpdateProviderRequest mirrors CreateProviderRequest.
 type UpdateProviderRequest struct {
-	Name        string            `json:"name" binding:"required" example:"Telecom"`
-	Interface   string            `json:"interface" example:"eth0"`
-	Interfaces  map[string]string `json:"interfaces"`
-	TableID     int               `json:"table_id" binding:"required,min=1" example:"100"`
-	Gateway     string            `json:"gateway" binding:"required" example:"192.168.1.1"`
-	Description string            `json:"description" example:"Primary internet connection"`
+	Name             string              `json:"name" binding:"required" example:"Telecom"`
+	Interface        string              `json:"interface" binding:"omitempty,ifname" example:"eth0"`
+	Interfaces       map[string]string   `json:"interfaces" binding:"omitempty,dive,keys,required,endkeys,ifname"`
+	TableID          int                 `json:"table_id" binding:"required,table_id" example:"100"`
+	Gateway          string              `json:"gateway" binding:"required_without=GatewayV6,omitempty,ip" example:"192.168.1.1"`
+	GatewayV6        string              `json:"gateway_v6,omitempty" binding:"omitempty,ipv6" example:"fe80::1"`
+	InterfaceV6      string              `json:"interface_v6,omitempty" binding:"omitempty,ifname" example:"ppp0"` // IPv6 interface when it differs from the IPv4 one
+	Description      string              `json:"description" example:"Primary internet connection"`
+	Labels           map[string]string   `json:"labels" example:"{\"site\":\"hq\"}"`
+	HealthCheck      *models.HealthCheck `json:"health_check,omitempty"`                           // defaults are filled in on save
+	Weight           int                 `json:"weight,omitempty" example:"1"`                     // 1-100, share of traffic when load balancing
+	FailoverPriority int                 `json:"failover_priority,omitempty" example:"1"`          // failover order, lowest first; unique when set
+	MonthlyCap       int64               `json:"monthly_cap,omitempty" example:"1099511627776"`    // data allowance in bytes per billing period; 0 means unlimited
+	CapResetDay      int                 `json:"cap_reset_day,omitempty" example:"1"`              // day of month (1-28) the billing period starts
+	CapDrainPercent  int                 `json:"cap_drain_percent,omitempty" example:"90"`         // share of monthly_cap that triggers the drain (default 90)
+	CapDrainTargetID string              `json:"cap_drain_target_id,omitempty" example:"Starlink"` // provider the policies move to near the cap
 }
 
-// CreatePolicyRequest represents a request to create a policy
-// The source_ip will be used as the policy ID for routing
+// CreatePolicyRequest represents a request to create a policy. The policy
+// gets a generated ID; several policies may share a source_ip as long as at
+// most one of them is enabled.
 type CreatePolicyRequest struct {
-	Name        string `json:"name" binding:"required" example:"Home Network"`
-	SourceIP    string `json:"source_ip" binding:"required" example:"192.168.1.100"`
-	ProviderID  string `json:"provider_id" binding:"required" example:"provider-123"`
-	Description string   `json:"description" example:"Route home network through primary provider"`
-	Tags        []string `json:"tags" example:"iot,kids"`
-	Enabled     bool     `json:"enabled" example:"true"`
-	Favorite    bool     `json:"favorite" example:"false"`
+	Name              string                  `json:"name" binding:"required" example:"Home Network"`
+	SourceIP          string                  `json:"source_ip" binding:"required_without=DelegatedSource,omitempty,ip_or_cidr" example:"192.168.1.100"`
+	DelegatedSource   *models.DelegatedSource `json:"delegated_source,omitempty"`                                                    // instead of source_ip: a subnet of a provider's delegated IPv6 prefix
+	Destination       string                  `json:"destination,omitempty" binding:"omitempty,ip_or_cidr" example:"203.0.113.0/24"` // only traffic to this IP or CIDR; empty matches every destination
+	Protocol          string                  `json:"protocol,omitempty" example:"tcp"`                                              // tcp, udp, sctp, icmp or icmpv6; needs features.nftables
+	SourcePorts       string                  `json:"source_ports,omitempty" example:"1024-65535"`                                   // tcp/udp/sctp only
+	DestinationPorts  string                  `json:"destination_ports,omitempty" example:"80,443"`                                  // tcp/udp/sctp only
+	ProviderID        string                  `json:"provider_id" binding:"required" example:"provider-123"`
+	BackupProviderIDs []string                `json:"backup_provider_ids,omitempty" example:"starlink,lte"`                              // used in order while the primary provider is down
+	Mode              string                  `json:"mode,omitempty" binding:"omitempty,oneof=strict best-effort" example:"best-effort"` // strict drops traffic while the provider is down instead of falling through
+	Description       string                  `json:"description" example:"Route home network through primary provider"`
+	Tags              []string                `json:"tags" example:"iot,kids"`
+	Labels            map[string]string       `json:"labels" example:"{\"team\":\"voip\"}"`
+	GroupID           string                  `json:"group_id" example:"IoT VLAN"`
+	Tenant            string                  `json:"tenant,omitempty" example:"acme"` // defaults to the caller's tenant; global callers may name one
+	Enabled           bool                    `json:"enabled" example:"true"`
+	Favorite          bool                    `json:"favorite" example:"false"`
+	Priority          int                     `json:"priority,omitempty" example:"2010"`                   // explicit ip rule priority; 0 derives it from the prefix length
+	ExpiresAt         *time.Time              `json:"expires_at,omitempty" example:"2025-01-31T18:00:00Z"` // the policy stops applying at this time
+	Schedule          *models.Schedule        `json:"schedule,omitempty"`                                  // the policy only applies inside these time windows
 }
 
 // UpdatePolicyRequest represents a request to update a policy
 type UpdatePolicyRequest struct {
-	Name        string `json:"name" binding:"required" example:"Home Network"`
-	SourceIP    string `json:"source_ip" binding:"required" example:"192.168.1.100"`
-	ProviderID  string `json:"provider_id" binding:"required" example:"provider-123"`
-	Description string   `json:"description" example:"Route home network through primary provider"`
-	Tags        []string `json:"tags" example:"iot,kids"`
-	Enabled     bool     `json:"enabled" example:"true"`
-	Favorite    bool     `json:"favorite" example:"false"`
+	Name              string                  `json:"name" binding:"required" example:"Home Network"`
+	SourceIP          string                  `json:"source_ip" binding:"required_without=DelegatedSource,omitempty,ip_or_cidr" example:"192.168.1.100"`
+	DelegatedSource   *models.DelegatedSource `json:"delegated_source,omitempty"`                                                    // instead of source_ip: a subnet of a provider's delegated IPv6 prefix
+	Destination       string                  `json:"destination,omitempty" binding:"omitempty,ip_or_cidr" example:"203.0.113.0/24"` // only traffic to this IP or CIDR; empty matches every destination
+	Protocol          string                  `json:"protocol,omitempty" example:"tcp"`                                              // tcp, udp, sctp, icmp or icmpv6; needs features.nftables
+	SourcePorts       string                  `json:"source_ports,omitempty" example:"1024-65535"`                                   // tcp/udp/sctp only
+	DestinationPorts  string                  `json:"destination_ports,omitempty" example:"80,443"`                                  // tcp/udp/sctp only
+	ProviderID        string                  `json:"provider_id" binding:"required" example:"provider-123"`
+	BackupProviderIDs []string                `json:"backup_provider_ids,omitempty" example:"starlink,lte"`                              // used in order while the primary provider is down
+	Mode              string                  `json:"mode,omitempty" binding:"omitempty,oneof=strict best-effort" example:"best-effort"` // strict drops traffic while the provider is down instead of falling through
+	Description       string                  `json:"description" example:"Route home network through primary provider"`
+	Tags              []string                `json:"tags" example:"iot,kids"`
+	Labels            map[string]string       `json:"labels" example:"{\"team\":\"voip\"}"`
+	GroupID           string                  `json:"group_id" example:"IoT VLAN"`
+	Enabled           bool                    `json:"enabled" example:"true"`
+	Favorite          bool                    `json:"favorite" example:"false"`
+	Priority          int                     `json:"priority,omitempty" example:"2010"`                   // explicit ip rule priority; 0 derives it from the prefix length
+	ExpiresAt         *time.Time              `json:"expires_at,omitempty" example:"2025-01-31T18:00:00Z"` // the policy stops applying at this time
+	Schedule          *models.Schedule        `json:"schedule,omitempty"`                                  // the policy only applies inside these time windows
 }
 
 // normalizeInterfaces returns a sanitized Interfaces map applying the migration rule:
@@ -74,22 +123,33 @@ func normalizeInterfaces(ifaces map[string]string, legacy string) map[string]str
 
 // listProviders lists all internet providers
 // @Summary List providers
-// @Description Get all internet providers
+// @Description Get all internet providers, each with its read-only status as reported by the routers
 // @Tags providers
 // @Accept json
 // @Produce json
+// @Param labels query string false "Label selector, e.g. site=hq,tier!=backup"
 // @Success 200 {array} models.InternetProvider
+// @Failure 400 {object} ErrorResponse
 // @Router /api/v1/providers [get]
+// @Router /api/v2/providers [get]
 func (s *Server) listProviders(c *gin.Context) {
+	sel, ok := labelSelector(c)
+	if !ok {
+		return
+	}
 	providers, err := s.natsClient.ListProviders()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list providers",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
 		return
 	}
 
+	providers = filterProviders(providers, sel)
+	if tenant, ok := s.scopedTenant(c); !ok {
+		return
+	} else if tenant != nil {
+		providers = tenantProviders(tenant, providers)
+	}
+	s.attachProviderStatus(providers...)
 	c.JSON(http.StatusOK, providers)
 }
 
@@ -101,26 +161,21 @@ func (s *Server) listProviders(c *gin.Context) {
 // @Produce json
 // @Param provider body CreateProviderRequest true "Provider information"
 // @Success 201 {object} models.InternetProvider
-// @Failure 400 {object} map[string]interface{}
-// @Failure 409 {object} map[string]interface{} "Provider with same name already exists"
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Provider with same name or failover priority already exists"
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/providers [post]
+// @Router /api/v2/providers [post]
 func (s *Server) createProvider(c *gin.Context) {
 	var req CreateProviderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	existingProvider, err := s.natsClient.GetProvider(req.Name)
 	if err == nil && existingProvider != nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":   "Provider already exists",
-			"details": fmt.Sprintf("A provider with name '%s' already exists", req.Name),
-		})
+		respondError(c, http.StatusConflict, "Provider already exists", fmt.Sprintf("A provider with name '%s' already exists", req.Name))
 		return
 	}
 
@@ -128,22 +183,35 @@ func (s *Server) createProvider(c *gin.Context) {
 
 	now := time.Now()
 	provider := &models.InternetProvider{
-		ID:          req.Name,
-		Name:        req.Name,
-		Interfaces:  ifaces,
-		Interface:   req.Interface,
-		TableID:     req.TableID,
-		Gateway:     req.Gateway,
-		Description: req.Description,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:               req.Name,
+		Name:             req.Name,
+		Interfaces:       ifaces,
+		Interface:        req.Interface,
+		TableID:          req.TableID,
+		Gateway:          req.Gateway,
+		GatewayV6:        req.GatewayV6,
+		InterfaceV6:      req.InterfaceV6,
+		Description:      req.Description,
+		Labels:           req.Labels,
+		HealthCheck:      req.HealthCheck,
+		Weight:           req.Weight,
+		FailoverPriority: req.FailoverPriority,
+		MonthlyCap:       req.MonthlyCap,
+		CapResetDay:      req.CapResetDay,
+		CapDrainPercent:  req.CapDrainPercent,
+		CapDrainTargetID: req.CapDrainTargetID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if provider.HealthCheck != nil {
+		provider.HealthCheck.ApplyDefaults()
 	}
 
 	if err := provider.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+	if !s.providerSlotsAvailable(c, provider) || !s.capDrainTargetExists(c, provider) {
 		return
 	}
 
@@ -151,93 +219,101 @@ func (s *Server) createProvider(c *gin.Context) {
 		writeStoreError(c, "Failed to create provider", err)
 		return
 	}
+	s.recordAudit(c, models.AuditActionCreate, models.AuditEntityProvider, provider.ID, nil, auditSnapshot(provider))
 
 	c.JSON(http.StatusCreated, provider)
 }
 
 // getProvider gets a specific internet provider
 // @Summary Get provider
-// @Description Get a specific internet provider by ID
+// @Description Get a specific internet provider by ID, with its read-only status as reported by the routers
 // @Tags providers
 // @Accept json
 // @Produce json
 // @Param id path string true "Provider ID"
 // @Success 200 {object} models.InternetProvider
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/providers/{id} [get]
+// @Router /api/v2/providers/{id} [get]
 func (s *Server) getProvider(c *gin.Context) {
 	id := c.Param("id")
 
 	provider, err := s.natsClient.GetProvider(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Provider not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Provider not found", err.Error())
+		return
+	}
+	tenant, ok := s.scopedTenant(c)
+	if !ok {
+		return
+	}
+	if tenant != nil && tenant.AllowsProvider(provider) != nil {
+		respondError(c, http.StatusNotFound, "Provider not found", fmt.Sprintf("provider '%s' does not exist", id))
 		return
 	}
 
+	setETag(c, provider.Generation)
+	s.attachProviderStatus(provider)
 	c.JSON(http.StatusOK, provider)
 }
 
 // updateProvider updates an existing internet provider
 // @Summary Update provider
-// @Description Update an existing internet provider. If the name is changed, the provider ID will also be updated to match the new name.
+// @Description Update an existing internet provider. If the name is changed, the provider ID will also be updated to match the new name. Send the ETag from GET in If-Match to reject the update (412) if someone else changed the provider in the meantime.
 // @Tags providers
 // @Accept json
 // @Produce json
 // @Param id path string true "Provider ID"
+// @Param If-Match header string false "ETag from a previous GET (required with api.require_if_match)"
 // @Param provider body UpdateProviderRequest true "Provider information"
 // @Success 200 {object} models.InternetProvider
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 409 {object} map[string]interface{} "Provider with new name already exists"
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Provider with new name or failover priority already exists"
+// @Failure 412 {object} ErrorResponse "If-Match does not match the current ETag"
+// @Failure 428 {object} ErrorResponse "If-Match required"
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/providers/{id} [put]
+// @Router /api/v2/providers/{id} [put]
 func (s *Server) updateProvider(c *gin.Context) {
 	id := c.Param("id")
 
 	var req UpdateProviderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	existing, err := s.natsClient.GetProvider(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Provider not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Provider not found", err.Error())
 		return
 	}
+	ifGeneration, ok := s.checkIfMatch(c, existing.Generation)
+	if !ok {
+		return
+	}
+	before := auditSnapshot(existing)
 
 	ifaces := normalizeInterfaces(req.Interfaces, req.Interface)
 
 	if existing.Name != req.Name {
 		conflictingProvider, err := s.natsClient.GetProvider(req.Name)
 		if err == nil && conflictingProvider != nil {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":   "Provider name conflict",
-				"details": fmt.Sprintf("A provider with name '%s' already exists", req.Name),
-			})
+			respondError(c, http.StatusConflict, "Provider name conflict", fmt.Sprintf("A provider with name '%s' already exists", req.Name))
 			return
 		}
 
 		if err := s.natsClient.DeleteProvider(existing.ID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to update provider",
-				"details": "Failed to delete old provider record",
-			})
+			respondError(c, http.StatusInternalServerError, "Failed to update provider", "Failed to delete old provider record")
 			return
 		}
 
 		existing.ID = req.Name
 		existing.Name = req.Name
+		// The renamed provider is a new key; If-Match was checked above.
+		ifGeneration = 0
 	} else {
 		existing.Name = req.Name
 	}
@@ -246,22 +322,37 @@ func (s *Server) updateProvider(c *gin.Context) {
 	existing.Interface = req.Interface
 	existing.TableID = req.TableID
 	existing.Gateway = req.Gateway
+	existing.GatewayV6 = req.GatewayV6
+	existing.InterfaceV6 = req.InterfaceV6
 	existing.Description = req.Description
+	existing.Labels = req.Labels
+	existing.HealthCheck = req.HealthCheck
+	existing.Weight = req.Weight
+	existing.FailoverPriority = req.FailoverPriority
+	existing.MonthlyCap = req.MonthlyCap
+	existing.CapResetDay = req.CapResetDay
+	existing.CapDrainPercent = req.CapDrainPercent
+	existing.CapDrainTargetID = req.CapDrainTargetID
+	if existing.HealthCheck != nil {
+		existing.HealthCheck.ApplyDefaults()
+	}
 	existing.UpdatedAt = time.Now()
 
 	if err := existing.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+	if !s.providerSlotsAvailable(c, existing) || !s.capDrainTargetExists(c, existing) {
 		return
 	}
 
-	if err := s.natsClient.StoreProvider(existing); err != nil {
+	if err := s.natsClient.StoreProviderIfMatch(existing, ifGeneration); err != nil {
 		writeStoreError(c, "Failed to update provider", err)
 		return
 	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityProvider, existing.ID, before, auditSnapshot(existing))
 
+	setETag(c, existing.Generation)
 	c.JSON(http.StatusOK, existing)
 }
 
@@ -273,41 +364,54 @@ func (s *Server) updateProvider(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Provider ID"
 // @Success 204 "No Content"
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/providers/{id} [delete]
+// @Router /api/v2/providers/{id} [delete]
 func (s *Server) deleteProvider(c *gin.Context) {
 	id := c.Param("id")
 
+	var before json.RawMessage
+	if existing, err := s.natsClient.GetProvider(id); err == nil {
+		before = auditSnapshot(existing)
+	}
+
 	if err := s.natsClient.DeleteProvider(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to delete provider",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to delete provider", err.Error())
 		return
 	}
+	if err := s.natsClient.DeleteUsage(id); err != nil {
+		logging.Provider(id).Warnf("Failed to delete usage record: %v", err)
+	}
+	s.recordAudit(c, models.AuditActionDelete, models.AuditEntityProvider, id, before, nil)
 
 	c.Status(http.StatusNoContent)
 }
 
 // listPolicies lists all routing policies
 // @Summary List policies
-// @Description Get all routing policies
+// @Description Get all routing policies, each with its read-only status as reported by the routers
 // @Tags policies
 // @Accept json
 // @Produce json
+// @Param labels query string false "Label selector, e.g. team=voip,env!=lab"
 // @Success 200 {array} models.RoutingPolicy
+// @Failure 400 {object} ErrorResponse
 // @Router /api/v1/policies [get]
+// @Router /api/v2/policies [get]
 func (s *Server) listPolicies(c *gin.Context) {
+	sel, ok := labelSelector(c)
+	if !ok {
+		return
+	}
 	policies, err := s.natsClient.ListPolicies()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list policies",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
 		return
 	}
 
+	policies = filterPolicies(visiblePolicies(c, policies), sel)
+	s.attachPolicyStatus(policies...)
 	c.JSON(http.StatusOK, policies)
 }
 
@@ -319,45 +423,74 @@ func (s *Server) listPolicies(c *gin.Context) {
 // @Produce json
 // @Param policy body CreatePolicyRequest true "Policy information"
 // @Success 201 {object} models.RoutingPolicy
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Another enabled policy uses the source"
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/policies [post]
+// @Router /api/v2/policies [post]
 func (s *Server) createPolicy(c *gin.Context) {
 	var req CreatePolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
+		return
+	}
+
+	tenant, ok := s.policyTenant(c, req.Tenant)
+	if !ok {
 		return
 	}
 
 	now := time.Now()
 	policy := &models.RoutingPolicy{
-		ID:          req.SourceIP,
-		Name:        req.Name,
-		ProviderID:  req.ProviderID,
-		Description: req.Description,
-		Tags:        models.NormalizeTags(req.Tags),
-		Enabled:     req.Enabled,
-		Favorite:    req.Favorite,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:                models.NewPolicyID(),
+		SourceIP:          req.SourceIP,
+		DelegatedSource:   req.DelegatedSource,
+		Destination:       req.Destination,
+		Protocol:          req.Protocol,
+		SourcePorts:       req.SourcePorts,
+		DestinationPorts:  req.DestinationPorts,
+		Name:              req.Name,
+		ProviderID:        req.ProviderID,
+		BackupProviderIDs: req.BackupProviderIDs,
+		Mode:              req.Mode,
+		Description:       req.Description,
+		Labels:            req.Labels,
+		Tags:              models.NormalizeTags(req.Tags),
+		GroupID:           req.GroupID,
+		Enabled:           req.Enabled,
+		Favorite:          req.Favorite,
+		Priority:          req.Priority,
+		ExpiresAt:         req.ExpiresAt,
+		Schedule:          req.Schedule,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if tenant != nil {
+		policy.ID = models.NewTenantPolicyID(tenant.ID)
+		policy.Tenant = tenant.ID
 	}
 
 	if err := policy.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
 		return
 	}
 
 	if _, err := s.natsClient.GetProvider(req.ProviderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Provider not found",
-			"details": "The specified provider ID does not exist",
-		})
+		respondError(c, http.StatusBadRequest, "Provider not found", "The specified provider ID does not exist")
+		return
+	}
+	if !s.backupProvidersExist(c, req.BackupProviderIDs) {
+		return
+	}
+	if !s.tenantAdmits(c, tenant, policy) {
+		return
+	}
+
+	if !s.groupExists(c, req.GroupID) {
+		return
+	}
+
+	if !s.sourceAvailable(c, policy) {
 		return
 	}
 
@@ -365,138 +498,387 @@ func (s *Server) createPolicy(c *gin.Context) {
 		writeStoreError(c, "Failed to create policy", err)
 		return
 	}
+	s.recordAudit(c, models.AuditActionCreate, models.AuditEntityPolicy, policy.ID, nil, auditSnapshot(policy))
 
 	c.JSON(http.StatusCreated, policy)
 }
 
 // getPolicy gets a specific routing policy
 // @Summary Get policy
-// @Description Get a specific routing policy by ID. For CIDR-based IDs, use underscore instead of slash (e.g., 192.168.2.0_25 for 192.168.2.0/25)
+// @Description Get a specific routing policy by ID, with its read-only status as reported by the routers. The source IP is also accepted when a single policy uses it; for a CIDR use underscore instead of slash (e.g., 192.168.2.0_25 for 192.168.2.0/25)
 // @Tags policies
 // @Accept json
 // @Produce json
-// @Param id path string true "Policy ID (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Param id path string true "Policy ID, or its source (use underscore for CIDR, e.g., 192.168.2.0_25)"
 // @Success 200 {object} models.RoutingPolicy
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/policies/{id} [get]
+// @Router /api/v2/policies/{id} [get]
 func (s *Server) getPolicy(c *gin.Context) {
-	id := c.Param("id")
-
-	policy, err := s.natsClient.GetPolicy(id)
+	policy, err := s.findPolicy(c, c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Policy not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Policy not found", err.Error())
 		return
 	}
 
+	setETag(c, policy.Generation)
+	s.attachPolicyStatus(policy)
 	c.JSON(http.StatusOK, policy)
 }
 
 // updatePolicy updates an existing routing policy
 // @Summary Update policy
-// @Description Update an existing routing policy. For CIDR-based IDs, use underscore instead of slash (e.g., 192.168.2.0_25 for 192.168.2.0/25)
+// @Description Update an existing routing policy. Changing source_ip keeps the policy ID. Send the ETag from GET in If-Match to reject the update (412) if someone else changed the policy in the meantime.
 // @Tags policies
 // @Accept json
 // @Produce json
-// @Param id path string true "Policy ID (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Param id path string true "Policy ID, or its source (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Param If-Match header string false "ETag from a previous GET (required with api.require_if_match)"
 // @Param policy body UpdatePolicyRequest true "Policy information"
 // @Success 200 {object} models.RoutingPolicy
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Another enabled policy uses the source"
+// @Failure 412 {object} ErrorResponse "If-Match does not match the current ETag"
+// @Failure 428 {object} ErrorResponse "If-Match required"
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/policies/{id} [put]
+// @Router /api/v2/policies/{id} [put]
 func (s *Server) updatePolicy(c *gin.Context) {
-	id := c.Param("id")
-
 	var req UpdatePolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
-	existing, err := s.natsClient.GetPolicy(id)
+	existing, err := s.findPolicy(c, c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Policy not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Policy not found", err.Error())
 		return
 	}
+	ifGeneration, ok := s.checkIfMatch(c, existing.Generation)
+	if !ok {
+		return
+	}
+	before := auditSnapshot(existing)
 
 	existing.Name = req.Name
-	existing.ID = req.SourceIP
+	existing.SourceIP = req.SourceIP
+	existing.DelegatedSource = req.DelegatedSource
+	existing.Destination = req.Destination
+	existing.Protocol = req.Protocol
+	existing.SourcePorts = req.SourcePorts
+	existing.DestinationPorts = req.DestinationPorts
 	existing.ProviderID = req.ProviderID
+	existing.BackupProviderIDs = req.BackupProviderIDs
+	existing.Mode = req.Mode
 	existing.Description = req.Description
+	existing.Labels = req.Labels
 	existing.Tags = models.NormalizeTags(req.Tags)
+	existing.GroupID = req.GroupID
 	existing.Enabled = req.Enabled
 	existing.Favorite = req.Favorite
+	existing.Priority = req.Priority
+	existing.ExpiresAt = req.ExpiresAt
+	existing.Schedule = req.Schedule
 	existing.UpdatedAt = time.Now()
 
 	if err := existing.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Validation failed",
-			"details": err.Error(),
-		})
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
 		return
 	}
 
 	if _, err := s.natsClient.GetProvider(req.ProviderID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Provider not found",
-			"details": "The specified provider ID does not exist",
-		})
+		respondError(c, http.StatusBadRequest, "Provider not found", "The specified provider ID does not exist")
+		return
+	}
+	if !s.backupProvidersExist(c, req.BackupProviderIDs) {
+		return
+	}
+	if existing.Tenant != "" {
+		tenant, err := s.natsClient.GetTenant(existing.Tenant)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Tenant not found", fmt.Sprintf("Tenant '%s' does not exist", existing.Tenant))
+			return
+		}
+		if !s.tenantAdmits(c, tenant, existing) {
+			return
+		}
+	}
+
+	if !s.groupExists(c, req.GroupID) {
 		return
 	}
 
-	if err := s.natsClient.StorePolicy(existing); err != nil {
+	if !s.sourceAvailable(c, existing) {
+		return
+	}
+
+	if err := s.natsClient.StorePolicyIfMatch(existing, ifGeneration); err != nil {
 		writeStoreError(c, "Failed to update policy", err)
 		return
 	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityPolicy, existing.ID, before, auditSnapshot(existing))
 
+	setETag(c, existing.Generation)
 	c.JSON(http.StatusOK, existing)
 }
 
+// enablePolicy enables a routing policy
+// @Summary Enable policy
+// @Description Set enabled=true on a policy without touching any other field. Agents apply the rule as soon as the change reaches them via NATS.
+// @Tags policies
+// @Produce json
+// @Param id path string true "Policy ID, or its source (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Success 200 {object} models.RoutingPolicy
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/policies/{id}/enable [post]
+// @Router /api/v2/policies/{id}/enable [post]
+func (s *Server) enablePolicy(c *gin.Context) {
+	s.setPolicyEnabled(c, true)
+}
+
+// disablePolicy disables a routing policy
+// @Summary Disable policy
+// @Description Set enabled=false on a policy without touching any other field. Agents remove the rule and flush conntrack for the source as soon as the change reaches them.
+// @Tags policies
+// @Produce json
+// @Param id path string true "Policy ID, or its source (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Success 200 {object} models.RoutingPolicy
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/policies/{id}/disable [post]
+// @Router /api/v2/policies/{id}/disable [post]
+func (s *Server) disablePolicy(c *gin.Context) {
+	s.setPolicyEnabled(c, false)
+}
+
+func (s *Server) setPolicyEnabled(c *gin.Context, enabled bool) {
+	policy, err := s.findPolicy(c, c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Policy not found", err.Error())
+		return
+	}
+
+	// Already in the requested state: don't bump the generation or make agents re-apply.
+	if policy.Enabled == enabled {
+		c.JSON(http.StatusOK, policy)
+		return
+	}
+
+	before := auditSnapshot(policy)
+	policy.Enabled = enabled
+	policy.UpdatedAt = time.Now()
+	if enabled && policy.Expired(policy.UpdatedAt) {
+		// Re-enabling an expired policy means keeping it: drop the expiry.
+		policy.ExpiresAt = nil
+	}
+	if enabled && !s.sourceAvailable(c, policy) {
+		return
+	}
+
+	if err := s.natsClient.StorePolicy(policy); err != nil {
+		writeStoreError(c, "Failed to update policy", err)
+		return
+	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityPolicy, policy.ID, before, auditSnapshot(policy))
+
+	c.JSON(http.StatusOK, policy)
+}
+
 // deletePolicy deletes a routing policy
 // @Summary Delete policy
-// @Description Delete a routing policy. For CIDR-based IDs, use underscore instead of slash (e.g., 192.168.2.0_25 for 192.168.2.0/25)
+// @Description Delete a routing policy by ID (or by its source when a single policy uses it)
 // @Tags policies
 // @Accept json
 // @Produce json
-// @Param id path string true "Policy ID (use underscore for CIDR, e.g., 192.168.2.0_25)"
+// @Param id path string true "Policy ID, or its source (use underscore for CIDR, e.g., 192.168.2.0_25)"
 // @Success 204 "No Content"
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
 // @Router /api/v1/policies/{id} [delete]
+// @Router /api/v2/policies/{id} [delete]
 func (s *Server) deletePolicy(c *gin.Context) {
 	id := c.Param("id")
 
+	var before json.RawMessage
+	if existing, err := s.findPolicy(c, id); err == nil {
+		before = auditSnapshot(existing)
+		id = existing.ID
+	} else if callerTenant(c) != "" {
+		// Tenants may only delete what they can see.
+		respondError(c, http.StatusNotFound, "Policy not found", err.Error())
+		return
+	}
+
 	if err := s.natsClient.DeletePolicy(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to delete policy",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to delete policy", err.Error())
 		return
 	}
+	s.recordAudit(c, models.AuditActionDelete, models.AuditEntityPolicy, id, before, nil)
 
 	c.Status(http.StatusNoContent)
 }
 
+// findPolicy resolves a policy path parameter. Besides the policy ID it
+// accepts a source (underscore for slash, e.g. 192.168.2.0_25), which is how
+// policies were addressed before IDs and sources were split, as long as
+// exactly one policy uses that source. Other tenants' policies are not found.
+func (s *Server) findPolicy(c *gin.Context, id string) (*models.RoutingPolicy, error) {
+	policy, err := s.natsClient.GetPolicy(id)
+	if err == nil && !policyVisible(c, policy) {
+		return nil, fmt.Errorf("policy %s not found", id)
+	}
+	if err == nil || models.IsPolicyID(id) {
+		return policy, err
+	}
+	srcNet, perr := models.ParseSource(strings.ReplaceAll(id, "_", "/"))
+	if perr != nil {
+		return nil, err
+	}
+	policies, lerr := s.natsClient.ListPolicies()
+	if lerr != nil {
+		return nil, lerr
+	}
+	var match *models.RoutingPolicy
+	for _, p := range visiblePolicies(c, policies) {
+		if n, err := p.SourceNet(); err != nil || n.String() != srcNet.String() {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("several policies use source %s; address the policy by ID", srcNet)
+		}
+		match = p
+	}
+	if match == nil {
+		return nil, err
+	}
+	return match, nil
+}
+
+// providerSlotsAvailable reports whether no provider other than p uses p's
+// routing table or failover priority. It writes a 409 response when one does.
+func (s *Server) providerSlotsAvailable(c *gin.Context, p *models.InternetProvider) bool {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return false
+	}
+	if other := models.TableIDOwner(p, providers); other != nil {
+		respondError(c, http.StatusConflict, "Table ID already in use",
+			fmt.Sprintf("provider '%s' already uses table %d", other.ID, p.TableID))
+		return false
+	}
+	if other := models.FailoverPriorityOwner(p, providers); other != nil {
+		respondError(c, http.StatusConflict, "Failover priority already in use",
+			fmt.Sprintf("provider '%s' already has failover_priority %d", other.ID, p.FailoverPriority))
+		return false
+	}
+	return true
+}
+
+// capDrainTargetExists reports whether p's cap drain target, if any, names
+// an existing provider, writing a 400 response when it does not.
+func (s *Server) capDrainTargetExists(c *gin.Context, p *models.InternetProvider) bool {
+	if p.CapDrainTargetID == "" {
+		return true
+	}
+	if _, err := s.natsClient.GetProvider(p.CapDrainTargetID); err != nil {
+		respondError(c, http.StatusBadRequest, "Provider not found", fmt.Sprintf("Cap drain target provider '%s' does not exist", p.CapDrainTargetID))
+		return false
+	}
+	return true
+}
+
+// sourceAvailable reports whether p may be stored: an enabled policy must be
+// the only enabled one for its source and destination, since the agent
+// installs one rule per pair. It writes a 409 response when another enabled
+// policy has it.
+func (s *Server) sourceAvailable(c *gin.Context, p *models.RoutingPolicy) bool {
+	if !p.Enabled {
+		return true
+	}
+	other, err := s.enabledPolicyForSource(p)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return false
+	}
+	if other != nil {
+		respondError(c, http.StatusConflict, "Source already in use",
+			fmt.Sprintf("policy '%s' (%s) is enabled for %s; disable it first", other.Name, other.ID, policyRule(p)))
+		return false
+	}
+	return true
+}
+
+// enabledPolicyForSource returns the enabled policy other than p with the
+// same source and destination whose schedule can overlap p's, or nil.
+func (s *Server) enabledPolicyForSource(p *models.RoutingPolicy) (*models.RoutingPolicy, error) {
+	key, ok := p.RuleKey()
+	if !ok {
+		return nil, nil
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, other := range policies {
+		if other.ID == p.ID || !other.Enabled {
+			continue
+		}
+		if k, ok := other.RuleKey(); ok && k == key && p.CanOverlap(other, now) {
+			return other, nil
+		}
+	}
+	return nil, nil
+}
+
+// policyRule describes the traffic a policy matches for messages.
+func policyRule(p *models.RoutingPolicy) string {
+	if key, ok := p.RuleKey(); ok {
+		return key
+	}
+	return p.Source()
+}
+
+// backupProvidersExist reports whether every backup provider ID names an
+// existing provider, writing a 400 response when one does not.
+func (s *Server) backupProvidersExist(c *gin.Context, ids []string) bool {
+	for _, id := range ids {
+		if _, err := s.natsClient.GetProvider(id); err != nil {
+			respondError(c, http.StatusBadRequest, "Provider not found", fmt.Sprintf("Backup provider '%s' does not exist", id))
+			return false
+		}
+	}
+	return true
+}
+
+// groupExists reports whether groupID is empty or names an existing group,
+// writing a 400 response when it does not.
+func (s *Server) groupExists(c *gin.Context, groupID string) bool {
+	if groupID == "" {
+		return true
+	}
+	if _, err := s.natsClient.GetGroup(groupID); err != nil {
+		respondError(c, http.StatusBadRequest, "Group not found", "The specified group ID does not exist")
+		return false
+	}
+	return true
+}
+
 func writeStoreError(c *gin.Context, message string, err error) {
 	if errors.Is(err, natsclient.ErrConflict) {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":   message,
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusConflict, message, err.Error())
+		return
+	}
+	if errors.Is(err, natsclient.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, message, err.Error())
 		return
 	}
-	c.JSON(http.StatusInternalServerError, gin.H{
-		"error":   message,
-		"details": err.Error(),
-	})
+	respondError(c, http.StatusInternalServerError, message, err.Error())
 }
@@ -2,16 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"router-sync/internal/auth"
 	"router-sync/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockNATSClient is a mock implementation of the NATS client
@@ -24,6 +27,11 @@ func (m *MockNATSClient) StoreProvider(provider *models.InternetProvider) error
 	return args.Error(0)
 }
 
+func (m *MockNATSClient) StoreProviderIfMatch(provider *models.InternetProvider, generation uint64) error {
+	args := m.Called(provider, generation)
+	return args.Error(0)
+}
+
 func (m *MockNATSClient) GetProvider(id string) (*models.InternetProvider, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -50,6 +58,11 @@ func (m *MockNATSClient) StorePolicy(policy *models.RoutingPolicy) error {
 	return args.Error(0)
 }
 
+func (m *MockNATSClient) StorePolicyIfMatch(policy *models.RoutingPolicy, generation uint64) error {
+	args := m.Called(policy, generation)
+	return args.Error(0)
+}
+
 func (m *MockNATSClient) GetPolicy(id string) (*models.RoutingPolicy, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -71,6 +84,146 @@ func (m *MockNATSClient) DeletePolicy(id string) error {
 	return args.Error(0)
 }
 
+func (m *MockNATSClient) StoreGroup(group *models.PolicyGroup) error {
+	args := m.Called(group)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) StoreGroupIfMatch(group *models.PolicyGroup, generation uint64) error {
+	args := m.Called(group, generation)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) GetGroup(id string) (*models.PolicyGroup, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PolicyGroup), args.Error(1)
+}
+
+func (m *MockNATSClient) ListGroups() ([]*models.PolicyGroup, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PolicyGroup), args.Error(1)
+}
+
+func (m *MockNATSClient) DeleteGroup(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) StoreTenant(tenant *models.Tenant) error {
+	args := m.Called(tenant)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) GetTenant(id string) (*models.Tenant, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tenant), args.Error(1)
+}
+
+func (m *MockNATSClient) ListTenants() ([]*models.Tenant, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Tenant), args.Error(1)
+}
+
+func (m *MockNATSClient) DeleteTenant(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) SetMaintenance(mt *models.Maintenance) error {
+	args := m.Called(mt)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) GetMaintenance() (*models.Maintenance, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Maintenance), args.Error(1)
+}
+
+func (m *MockNATSClient) StoreWebhook(hook *models.Webhook) error {
+	args := m.Called(hook)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) GetWebhook(id string) (*models.Webhook, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (m *MockNATSClient) ListWebhooks() ([]*models.Webhook, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Webhook), args.Error(1)
+}
+
+func (m *MockNATSClient) DeleteWebhook(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) StoreUsage(usage *models.ProviderUsage) error {
+	args := m.Called(usage)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) GetUsage(providerID string) (*models.ProviderUsage, error) {
+	args := m.Called(providerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ProviderUsage), args.Error(1)
+}
+
+func (m *MockNATSClient) ListUsage() ([]*models.ProviderUsage, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ProviderUsage), args.Error(1)
+}
+
+func (m *MockNATSClient) DeleteUsage(providerID string) error {
+	args := m.Called(providerID)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) ReserveIdempotencyKey(key string, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	args := m.Called(key, rec)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.IdempotencyRecord), args.Error(1)
+}
+
+func (m *MockNATSClient) CompleteIdempotencyKey(key string, rec *models.IdempotencyRecord) error {
+	args := m.Called(key, rec)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) ReleaseIdempotencyKey(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
 func (m *MockNATSClient) StoreRouterState(state *models.RouterState) error {
 	args := m.Called(state)
 	return args.Error(0)
@@ -115,6 +268,68 @@ func (m *MockNATSClient) ListServiceLogLevels() (map[string]string, error) {
 	return args.Get(0).(map[string]string), args.Error(1)
 }
 
+func (m *MockNATSClient) SendAgentCommand(ctx context.Context, hostname string, cmd *models.AgentCommand) (*models.AgentCommandResult, error) {
+	args := m.Called(ctx, hostname, cmd)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AgentCommandResult), args.Error(1)
+}
+
+// PublishEvent accepts every event so handler tests need not expect config.changed events.
+func (m *MockNATSClient) PublishEvent(ev *models.Event) error {
+	return nil
+}
+
+func (m *MockNATSClient) SubscribeEvents(ctx context.Context, callback func(*models.Event)) error {
+	args := m.Called(ctx, callback)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) SubscribeEventsQueue(ctx context.Context, queue string, callback func(*models.Event)) error {
+	args := m.Called(ctx, queue, callback)
+	return args.Error(0)
+}
+
+// RecordAudit accepts every entry so handler tests need not expect audit writes.
+func (m *MockNATSClient) RecordAudit(entry *models.AuditEntry) error {
+	return nil
+}
+
+func (m *MockNATSClient) ListAudit(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AuditEntry), args.Error(1)
+}
+
+func (m *MockNATSClient) ListJournal(filter models.JournalFilter) ([]*models.JournalEntry, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.JournalEntry), args.Error(1)
+}
+
+func (m *MockNATSClient) ListHeartbeats() ([]*models.Heartbeat, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Heartbeat), args.Error(1)
+}
+
+func (m *MockNATSClient) DeleteHeartbeat(hostname string) error {
+	args := m.Called(hostname)
+	return args.Error(0)
+}
+
+func (m *MockNATSClient) Connected() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
 func (m *MockNATSClient) Close() {
 	m.Called()
 }
@@ -143,6 +358,7 @@ func TestCreateProvider_WithNameAsID(t *testing.T) {
 
 	// Set up mock expectations
 	mockNATS.On("GetProvider", providerName).Return(nil, assert.AnError) // Provider doesn't exist
+	mockNATS.On("ListProviders").Return([]*models.InternetProvider{}, nil)
 	mockNATS.On("StoreProvider", mock.AnythingOfType("*models.InternetProvider")).Return(nil)
 
 	// Create request body
@@ -245,3 +461,313 @@ func TestCreateProvider_DuplicateName(t *testing.T) {
 	// Verify that the mock was called correctly
 	mockNATS.AssertExpectations(t)
 }
+
+func TestCreateProvider_DuplicateTableID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	mockNATS.On("GetProvider", "Starlink").Return(nil, assert.AnError)
+	mockNATS.On("ListProviders").Return([]*models.InternetProvider{
+		{ID: "Telecom", Name: "Telecom", Interface: "eth0", TableID: 100, Gateway: "192.168.1.1"},
+	}, nil)
+
+	requestBody, _ := json.Marshal(CreateProviderRequest{
+		Name:      "Starlink",
+		Interface: "eth1",
+		TableID:   100,
+		Gateway:   "192.168.100.1",
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/providers", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	server.createProvider(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Table ID already in use")
+	mockNATS.AssertNotCalled(t, "StoreProvider", mock.Anything)
+}
+
+func TestCreateProvider_DuplicateNameV2ErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	providerName := "ExistingProvider"
+	mockNATS.On("GetProvider", providerName).Return(&models.InternetProvider{ID: providerName, Name: providerName}, nil)
+
+	requestBody, _ := json.Marshal(CreateProviderRequest{
+		Name:      providerName,
+		Interface: "eth0",
+		TableID:   100,
+		Gateway:   "192.168.1.1",
+	})
+	req, _ := http.NewRequest("POST", "/api/v2/providers", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set(requestIDKey, "req-1")
+
+	server.createProvider(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, CodeConflict, response.Error.Code)
+	assert.Equal(t, "Provider already exists", response.Error.Message)
+	assert.Contains(t, response.Error.Details, providerName)
+	assert.Equal(t, "req-1", response.Error.RequestID)
+
+	mockNATS.AssertExpectations(t)
+}
+
+func TestSetGroupProvider_RollsBackOnFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	mockNATS.On("GetGroup", "IoT").Return(&models.PolicyGroup{ID: "IoT", Name: "IoT"}, nil)
+	mockNATS.On("GetProvider", "Starlink").Return(&models.InternetProvider{ID: "Starlink"}, nil)
+	mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{
+		{ID: "192.168.30.10", ProviderID: "Telecom", GroupID: "IoT"},
+		{ID: "192.168.30.11", ProviderID: "Telecom", GroupID: "IoT"},
+		{ID: "192.168.2.25", ProviderID: "Telecom"},
+	}, nil)
+
+	var stored []string
+	mockNATS.On("StorePolicy", mock.MatchedBy(func(p *models.RoutingPolicy) bool {
+		return p.ID == "192.168.30.11" && p.ProviderID == "Starlink"
+	})).Return(assert.AnError)
+	mockNATS.On("StorePolicy", mock.AnythingOfType("*models.RoutingPolicy")).Run(func(args mock.Arguments) {
+		p := args.Get(0).(*models.RoutingPolicy)
+		stored = append(stored, p.ID+"="+p.ProviderID)
+	}).Return(nil)
+
+	requestBody, _ := json.Marshal(GroupProviderRequest{ProviderID: "Starlink"})
+	req, _ := http.NewRequest("POST", "/api/v1/groups/IoT/provider", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "IoT"}}
+
+	server.setGroupProvider(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	// The first member was moved, then restored when the second write failed;
+	// the ungrouped policy was never touched.
+	assert.Equal(t, []string{"192.168.30.10=Starlink", "192.168.30.10=Telecom"}, stored)
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	calls := 0
+	router := gin.New()
+	router.POST("/api/v1/policies", server.idempotency(), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": "192.168.2.25"})
+	})
+
+	var stored *models.IdempotencyRecord
+	mockNATS.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	mockNATS.On("CompleteIdempotencyKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.IdempotencyRecord)
+	}).Return(nil).Once()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/policies", bytes.NewBufferString(body))
+		req.Header.Set(idempotencyHeader, "run-42")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send(`{"source":"192.168.2.25"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	require.NotNil(t, stored)
+	assert.Equal(t, http.StatusCreated, stored.Status)
+
+	mockNATS.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(stored, nil)
+
+	retry := send(`{"source":"192.168.2.25"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotencyReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	reused := send(`{"source":"192.168.2.26"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestUpdatePolicy_StaleIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	mockNATS.On("GetPolicy", "192.168.2.25").Return(&models.RoutingPolicy{
+		ID: "192.168.2.25", Name: "laptop", ProviderID: "Telecom", Generation: 4,
+	}, nil)
+
+	requestBody, _ := json.Marshal(UpdatePolicyRequest{Name: "laptop", SourceIP: "192.168.2.25", ProviderID: "Starlink"})
+	req, _ := http.NewRequest("PUT", "/api/v1/policies/192.168.2.25", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "192.168.2.25"}}
+
+	server.updatePolicy(c)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	mockNATS.AssertNotCalled(t, "StorePolicyIfMatch", mock.Anything, mock.Anything)
+}
+
+func TestEnablePolicy_SourceAlreadyEnabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{
+		natsClient: mockNATS,
+	}
+
+	night := &models.RoutingPolicy{ID: "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f", SourceIP: "192.168.2.0/25", Name: "night", ProviderID: "Starlink"}
+	day := &models.RoutingPolicy{ID: "7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", SourceIP: "192.168.2.0/25", Name: "day", ProviderID: "Telecom", Enabled: true}
+	mockNATS.On("GetPolicy", night.ID).Return(night, nil)
+	mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{night, day}, nil)
+
+	req, _ := http.NewRequest("POST", "/api/v1/policies/"+night.ID+"/enable", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: night.ID}}
+
+	server.enablePolicy(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "day")
+	mockNATS.AssertNotCalled(t, "StorePolicy", mock.Anything)
+}
+
+func TestUpgradeLegacyPolicy(t *testing.T) {
+	stored := []*models.RoutingPolicy{
+		{ID: "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f", SourceIP: "192.168.2.0/25"},
+	}
+
+	known := &models.RoutingPolicy{ID: "192.168.2.0/25"}
+	assert.True(t, upgradeLegacyPolicy(known, stored))
+	assert.Equal(t, "192.168.2.0/25", known.SourceIP)
+	assert.Equal(t, stored[0].ID, known.ID)
+
+	fresh := &models.RoutingPolicy{ID: "192.168.2.25"}
+	assert.True(t, upgradeLegacyPolicy(fresh, stored))
+	assert.Equal(t, "192.168.2.25", fresh.SourceIP)
+	assert.True(t, models.IsPolicyID(fresh.ID))
+
+	current := &models.RoutingPolicy{ID: "7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", SourceIP: "10.0.0.1"}
+	assert.False(t, upgradeLegacyPolicy(current, stored))
+	assert.False(t, upgradeLegacyPolicy(&models.RoutingPolicy{ID: "not-a-source"}, stored))
+}
+
+func TestIdempotency_ReleasesKeyOnFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  int
+	}{
+		{"forbidden", func(c *gin.Context) { respondError(c, http.StatusForbidden, "Forbidden", "operator role required") }, http.StatusForbidden},
+		{"bad request", func(c *gin.Context) { respondError(c, http.StatusBadRequest, "Invalid request", "bad body") }, http.StatusBadRequest},
+		{"server error", func(c *gin.Context) { respondError(c, http.StatusInternalServerError, "Failed", "boom") }, http.StatusInternalServerError},
+		{"panic", func(c *gin.Context) { panic("boom") }, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockNATS := &MockNATSClient{}
+			server := &Server{
+				natsClient: mockNATS,
+			}
+			mockNATS.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil).Once()
+			mockNATS.On("ReleaseIdempotencyKey", mock.Anything).Return(nil).Once()
+
+			router := gin.New()
+			router.Use(gin.Recovery())
+			router.POST("/api/v1/policies", server.idempotency(), tt.handler)
+
+			req, _ := http.NewRequest("POST", "/api/v1/policies", bytes.NewBufferString(`{}`))
+			req.Header.Set(idempotencyHeader, "run-42")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockNATS.AssertExpectations(t)
+			mockNATS.AssertNotCalled(t, "CompleteIdempotencyKey", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreatePolicy_DelegatedSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		contains string
+	}{
+		{"delegated", `{"name":"lan","delegated_source":{"subnet":5,"length":64},"provider_id":"isp1","enabled":true}`, http.StatusCreated, `"delegated_source":{"subnet":5,"length":64}`},
+		{"both", `{"name":"lan","source_ip":"2001:db8::/64","delegated_source":{"subnet":5,"length":64},"provider_id":"isp1"}`, http.StatusBadRequest, "mutually exclusive"},
+		{"neither", `{"name":"lan","provider_id":"isp1"}`, http.StatusBadRequest, "source_ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockNATS := &MockNATSClient{}
+			server := &Server{natsClient: mockNATS}
+			mockNATS.On("GetProvider", "isp1").Return(&models.InternetProvider{ID: "isp1", TableID: 100}, nil)
+			mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{}, nil)
+			mockNATS.On("StorePolicy", mock.AnythingOfType("*models.RoutingPolicy")).Return(nil)
+			mockNATS.On("RecordAudit", mock.Anything).Return(nil)
+			mockNATS.On("PublishEvent", mock.Anything).Return(nil)
+			router := tenantRouter(server, &auth.Identity{Subject: "alice", Role: auth.RoleOperator})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/policies", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyHeader lets clients make POST requests safe to retry.
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader marks a response replayed from a previous request.
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen bounds the client-supplied key.
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry: the first request runs and, if it succeeds (2xx), its response is
+// stored for 24h; later requests with the same key, method, path and body get
+// that response replayed with Idempotent-Replayed: true instead of running
+// again. Keys are scoped per caller. A retry while the first request is still
+// running gets 409; reusing a key for a different request gets 422. Failed
+// requests (4xx, 5xx or a panic) are not stored, so a retry runs again, e.g.
+// after the caller was granted a missing role or fixed the body.
+func (s *Server) idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			respondError(c, http.StatusBadRequest, "Invalid Idempotency-Key", "the key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to read request body", err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		rec := &models.IdempotencyRecord{
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			BodyHash:  hex.EncodeToString(sum[:]),
+			CreatedAt: time.Now().UTC(),
+		}
+		storeKey := idempotencyStoreKey(c, key)
+
+		existing, err := s.natsClient.ReserveIdempotencyKey(storeKey, rec)
+		if err != nil {
+			respondError(c, http.StatusServiceUnavailable, "Failed to check Idempotency-Key", err.Error())
+			return
+		}
+		if existing != nil {
+			replayIdempotent(c, existing, rec)
+			return
+		}
+
+		// Release the reservation unless a response is stored below, also
+		// when a handler panics (gin.Recovery answers after this returns):
+		// otherwise retries would get 409 until the record expires.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := s.natsClient.ReleaseIdempotencyKey(storeKey); err != nil {
+				requestLog(c).Warnf("Failed to release Idempotency-Key: %v", err)
+			}
+		}()
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		status := w.Status()
+		if status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+		rec.Status = status
+		rec.ContentType = w.Header().Get("Content-Type")
+		rec.Response = w.body.Bytes()
+		if err := s.natsClient.CompleteIdempotencyKey(storeKey, rec); err != nil {
+			requestLog(c).Warnf("Failed to store Idempotency-Key response: %v", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// replayIdempotent answers a request whose key is already known.
+func replayIdempotent(c *gin.Context, existing, rec *models.IdempotencyRecord) {
+	if existing.Method != rec.Method || existing.Path != rec.Path || existing.BodyHash != rec.BodyHash {
+		respondError(c, http.StatusUnprocessableEntity, "Idempotency-Key reused",
+			"the key was already used for a different request ("+existing.Method+" "+existing.Path+")")
+		return
+	}
+	if !existing.Completed() {
+		respondError(c, http.StatusConflict, "Request in progress",
+			"a request with this Idempotency-Key is still being processed; retry later")
+		return
+	}
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Data(existing.Status, existing.ContentType, existing.Response)
+	c.Abort()
+}
+
+// idempotencyStoreKey scopes key to the caller so different clients can pick
+// the same key. The result is hashed to be a valid NATS KV key.
+func idempotencyStoreKey(c *gin.Context, key string) string {
+	subject := ""
+	if identity := identityFrom(c); identity != nil {
+		subject = identity.Subject
+		if identity.Tenant != "" {
+			subject = identity.Tenant + "/" + subject
+		}
+	}
+	sum := sha256.Sum256([]byte(subject + "\n" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// capturingWriter copies the response body so it can be stored.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listJournal returns the kernel routing changes the agents made
+// @Summary Routing-change journal
+// @Description List the ip rule, route and conntrack changes the agents made to their routers, newest first. Failed changes carry an error. The journal keeps the last 100000 entries for up to 30 days.
+// @Tags journal
+// @Produce json
+// @Param host query string false "Router hostname"
+// @Param action query string false "Change (rule_add, rule_delete, route_add, route_delete, table_flush, conntrack_flush)"
+// @Param source query string false "Rule source, as printed by ip (e.g. 192.168.2.25 or 10.0.0.0/24)"
+// @Param sync_id query string false "Full sync that made the change (sync_id in agent logs)"
+// @Param since query string false "RFC3339 lower bound"
+// @Param until query string false "RFC3339 upper bound"
+// @Param limit query int false "Maximum entries (default 100, max 1000)"
+// @Success 200 {array} models.JournalEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/journal [get]
+// @Router /api/v2/journal [get]
+func (s *Server) listJournal(c *gin.Context) {
+	filter := models.JournalFilter{
+		Hostname: c.Query("host"),
+		Action:   c.Query("action"),
+		Source:   c.Query("source"),
+		SyncID:   c.Query("sync_id"),
+		Limit:    defaultAuditLimit,
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid since", err.Error())
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid until", err.Error())
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid limit", "limit must be a positive integer")
+			return
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.natsClient.ListJournal(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list journal entries", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
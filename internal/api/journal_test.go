@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListJournal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	router := gin.New()
+	router.GET("/journal", server.listJournal)
+
+	since := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 5, 1, 3, 30, 0, 0, time.UTC)
+	entries := []*models.JournalEntry{
+		{Seq: 42, Timestamp: since.Add(12 * time.Minute), Hostname: "router1", Action: models.JournalRuleDelete, Reason: models.JournalReasonStale, Source: "192.168.2.25", Priority: 2000, Table: 100},
+	}
+	mockNATS.On("ListJournal", models.JournalFilter{
+		Hostname: "router1",
+		Action:   models.JournalRuleDelete,
+		Since:    since,
+		Until:    until,
+		Limit:    maxAuditLimit,
+	}).Return(entries, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/journal?host=router1&action=rule_delete&since=2024-05-01T03:00:00Z&until=2024-05-01T03:30:00Z&limit=5000", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got []*models.JournalEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, entries, got)
+	mockNATS.AssertExpectations(t)
+}
+
+func TestListJournal_BadQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &Server{natsClient: &MockNATSClient{}}
+	router := gin.New()
+	router.GET("/journal", server.listJournal)
+
+	for _, query := range []string{"since=yesterday", "until=03:12", "limit=0", "limit=x"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/journal?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bulk policy actions.
+const (
+	BulkActionEnable   = "enable"
+	BulkActionDisable  = "disable"
+	BulkActionProvider = "provider"
+)
+
+// BulkPolicyRequest applies one action to every policy matching Selector
+// (label selector syntax, e.g. "team=voip,env!=lab").
+type BulkPolicyRequest struct {
+	Selector   string `json:"selector" binding:"required" example:"team=voip"`
+	Action     string `json:"action" binding:"required,oneof=enable disable provider" example:"enable"`
+	ProviderID string `json:"provider_id,omitempty" example:"Starlink"` // required for action=provider
+	DryRun     bool   `json:"dry_run" example:"false"`
+}
+
+// BulkOperationResult lists the policies a bulk operation matched and the
+// ones it changed (or would change, for a dry run).
+type BulkOperationResult struct {
+	Selector string   `json:"selector"`
+	Action   string   `json:"action"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Matched  []string `json:"matched"`
+	Changed  []string `json:"changed"`
+}
+
+// labelSelector parses the "labels" query parameter. On a malformed
+// selector it responds 400 and returns false.
+func labelSelector(c *gin.Context) (models.LabelSelector, bool) {
+	sel, err := models.ParseLabelSelector(c.Query("labels"))
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeBadRequest, "Invalid label selector", err.Error())
+		return nil, false
+	}
+	return sel, true
+}
+
+// bulkPolicies applies an action to the policies selected by labels
+// @Summary Bulk policy operation
+// @Description Enable, disable or move to another provider every policy whose labels match selector (e.g. team=voip,env!=lab). All-or-nothing: on a failed write the policies already changed are reverted.
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param request body BulkPolicyRequest true "Selector and action"
+// @Success 200 {object} BulkOperationResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/policies/bulk [post]
+// @Router /api/v2/policies/bulk [post]
+func (s *Server) bulkPolicies(c *gin.Context) {
+	var req BulkPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	sel, err := models.ParseLabelSelector(req.Selector)
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Invalid label selector", err.Error())
+		return
+	}
+	if len(sel) == 0 {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Invalid label selector", "selector must contain at least one requirement")
+		return
+	}
+
+	var mutate func(*models.RoutingPolicy)
+	var needsChange func(*models.RoutingPolicy) bool
+	switch req.Action {
+	case BulkActionEnable, BulkActionDisable:
+		enabled := req.Action == BulkActionEnable
+		mutate = func(p *models.RoutingPolicy) { p.Enabled = enabled }
+		needsChange = func(p *models.RoutingPolicy) bool { return p.Enabled != enabled }
+	case BulkActionProvider:
+		if req.ProviderID == "" {
+			respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", "provider_id is required for action=provider")
+			return
+		}
+		if _, err := s.natsClient.GetProvider(req.ProviderID); err != nil {
+			respondError(c, http.StatusBadRequest, "Provider not found", fmt.Sprintf("Provider '%s' does not exist", req.ProviderID))
+			return
+		}
+		mutate = func(p *models.RoutingPolicy) { p.ProviderID = req.ProviderID }
+		needsChange = func(p *models.RoutingPolicy) bool { return p.ProviderID != req.ProviderID }
+	}
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+
+	result := BulkOperationResult{Selector: sel.String(), Action: req.Action, DryRun: req.DryRun, Matched: []string{}, Changed: []string{}}
+	var pending []*models.RoutingPolicy
+	for _, p := range policies {
+		if !sel.Matches(p.Labels) {
+			continue
+		}
+		result.Matched = append(result.Matched, p.ID)
+		if needsChange(p) {
+			pending = append(pending, p)
+		}
+	}
+
+	if req.DryRun {
+		for _, p := range pending {
+			result.Changed = append(result.Changed, p.ID)
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	changed, err := s.updateGroupPolicies(c, pending, mutate)
+	if err != nil {
+		writeStoreError(c, "Failed to update policies; changes rolled back", err)
+		return
+	}
+	result.Changed = changed
+
+	requestLog(c).Infof("Bulk %s on policies matching %s: %d matched, %d changed", req.Action, result.Selector, len(result.Matched), len(changed))
+	c.JSON(http.StatusOK, result)
+}
+
+// filterProviders returns the providers whose labels match sel.
+func filterProviders(providers []*models.InternetProvider, sel models.LabelSelector) []*models.InternetProvider {
+	if len(sel) == 0 {
+		return providers
+	}
+	out := make([]*models.InternetProvider, 0, len(providers))
+	for _, p := range providers {
+		if sel.Matches(p.Labels) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// filterPolicies returns the policies whose labels match sel.
+func filterPolicies(policies []*models.RoutingPolicy, sel models.LabelSelector) []*models.RoutingPolicy {
+	if len(sel) == 0 {
+		return policies
+	}
+	out := make([]*models.RoutingPolicy, 0, len(policies))
+	for _, p := range policies {
+		if sel.Matches(p.Labels) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// setLabelGauges exports policy counts per value of each configured
+// api.metrics_labels key, split by enabled state. Policies without the label
+// are counted under the empty value.
+func (s *Server) setLabelGauges(policies []*models.RoutingPolicy) {
+	s.policiesByLabel.Reset()
+	for _, key := range s.config.MetricsLabels {
+		for _, p := range policies {
+			s.policiesByLabel.WithLabelValues(key, p.Labels[key], strconv.FormatBool(p.Enabled)).Inc()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterPolicies(t *testing.T) {
+	policies := []*models.RoutingPolicy{
+		{ID: "10.0.0.1", Labels: map[string]string{"team": "voip", "env": "prod"}},
+		{ID: "10.0.0.2", Labels: map[string]string{"team": "voip", "env": "lab"}},
+		{ID: "10.0.0.3", Labels: map[string]string{"team": "data"}},
+		{ID: "10.0.0.4"},
+	}
+
+	ids := func(sel string) []string {
+		parsed, err := models.ParseLabelSelector(sel)
+		require.NoError(t, err)
+		var out []string
+		for _, p := range filterPolicies(policies, parsed) {
+			out = append(out, p.ID)
+		}
+		return out
+	}
+
+	assert.Len(t, ids(""), 4)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, ids("team=voip"))
+	assert.Equal(t, []string{"10.0.0.1"}, ids("team=voip,env!=lab"))
+	assert.Equal(t, []string{"10.0.0.4"}, ids("!team"))
+}
@@ -1,14 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"sort"
 	"time"
 
 	"router-sync/internal/logging"
+	"router-sync/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 // LogLevelResponse describes the current runtime log level for a service.
@@ -42,6 +43,7 @@ type SetLogLevelRequest struct {
 // @Produce json
 // @Success 200 {object} LogLevelResponse
 // @Router /api/v1/logging/level [get]
+// @Router /api/v2/logging/level [get]
 func (s *Server) getOwnLogLevel(c *gin.Context) {
 	c.JSON(http.StatusOK, LogLevelResponse{
 		ServiceID: logging.ServiceID(),
@@ -57,18 +59,19 @@ func (s *Server) getOwnLogLevel(c *gin.Context) {
 // @Produce json
 // @Param body body SetLogLevelRequest true "Log level"
 // @Success 200 {object} LogLevelResponse
-// @Failure 400 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
 // @Router /api/v1/logging/level [put]
+// @Router /api/v2/logging/level [put]
 func (s *Server) setOwnLogLevel(c *gin.Context) {
 	var req SetLogLevelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	level, err := logging.ParseLevel(req.Level)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log level", "details": err.Error()})
+		respondError(c, http.StatusBadRequest, "Invalid log level", err.Error())
 		return
 	}
 
@@ -76,11 +79,12 @@ func (s *Server) setOwnLogLevel(c *gin.Context) {
 	logging.SetLevel(level)
 	if sid := logging.ServiceID(); sid != "" {
 		if err := s.natsClient.SetServiceLogLevel(sid, level.String()); err != nil {
-			logrus.Warnf("Failed to persist log level for %s: %v", sid, err)
+			requestLog(c).Warnf("Failed to persist log level for %s: %v", sid, err)
 		}
+		s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityLogLevel, sid, auditSnapshot(prev), auditSnapshot(level.String()))
 	}
 	s.logLevelSetTotal.Inc()
-	logrus.Infof("Log level changed from %s to %s via API", prev, level.String())
+	requestLog(c).Infof("Log level changed from %s to %s via API", prev, level.String())
 
 	c.JSON(http.StatusOK, LogLevelResponse{
 		ServiceID: logging.ServiceID(),
@@ -96,8 +100,9 @@ func (s *Server) setOwnLogLevel(c *gin.Context) {
 // @Produce json
 // @Param service_id path string true "Service ID (e.g. api, agent.r1)"
 // @Success 200 {object} LogLevelResponse
-// @Failure 404 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/logging/level/{service_id} [get]
+// @Router /api/v2/logging/level/{service_id} [get]
 func (s *Server) getLogLevelByService(c *gin.Context) {
 	serviceID := c.Param("service_id")
 
@@ -112,10 +117,7 @@ func (s *Server) getLogLevelByService(c *gin.Context) {
 
 	level, err := s.natsClient.GetServiceLogLevel(serviceID)
 	if err != nil || level == "" {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Service log level not found",
-			"details": "no persisted level for " + serviceID,
-		})
+		respondError(c, http.StatusNotFound, "Service log level not found", "no persisted level for " + serviceID)
 		return
 	}
 	c.JSON(http.StatusOK, LogLevelResponse{
@@ -135,31 +137,37 @@ func (s *Server) getLogLevelByService(c *gin.Context) {
 // @Param service_id path string true "Service ID (e.g. api, agent.r1)"
 // @Param body body SetLogLevelRequest true "Log level"
 // @Success 200 {object} LogLevelResponse
-// @Failure 400 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
 // @Router /api/v1/logging/level/{service_id} [put]
+// @Router /api/v2/logging/level/{service_id} [put]
 func (s *Server) setLogLevelByService(c *gin.Context) {
 	serviceID := c.Param("service_id")
 	var req SetLogLevelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	level, err := logging.ParseLevel(req.Level)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log level", "details": err.Error()})
+		respondError(c, http.StatusBadRequest, "Invalid log level", err.Error())
 		return
 	}
 
+	var before json.RawMessage
+	if prev, err := s.natsClient.GetServiceLogLevel(serviceID); err == nil {
+		before = auditSnapshot(prev)
+	}
 	if err := s.natsClient.SetServiceLogLevel(serviceID, level.String()); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to persist log level", "details": err.Error()})
+		respondError(c, http.StatusInternalServerError, "Failed to persist log level", err.Error())
 		return
 	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityLogLevel, serviceID, before, auditSnapshot(level.String()))
 
 	if serviceID == logging.ServiceID() {
 		prev := logging.GetLevelName()
 		logging.SetLevel(level)
-		logrus.Infof("Log level changed from %s to %s via API", prev, level.String())
+		requestLog(c).Infof("Log level changed from %s to %s via API", prev, level.String())
 	}
 	s.logLevelSetTotal.Inc()
 
@@ -177,13 +185,11 @@ func (s *Server) setLogLevelByService(c *gin.Context) {
 // @Produce json
 // @Success 200 {object} LogLevelsResponse
 // @Router /api/v1/logging/levels [get]
+// @Router /api/v2/logging/levels [get]
 func (s *Server) listLogLevels(c *gin.Context) {
 	persisted, err := s.natsClient.ListServiceLogLevels()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list log levels",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to list log levels", err.Error())
 		return
 	}
 
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sort"
+
+	"router-sync/internal/lookup"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lookupRoute answers which provider a client's traffic uses right now
+// @Summary Effective route lookup
+// @Description Replay the kernel decision (ip rule order, then longest-prefix match in the selected table, honouring suppress_prefixlength) for traffic from src to dst against each router's last reported rules and tables. Without dst only default routes are considered, i.e. "internet traffic". The response names the matched rule, table, gateway and provider, the trace of rules visited, and whether the result agrees with the enabled policy for src.
+// @Tags routers
+// @Produce json
+// @Param src query string true "Client source IP"
+// @Param dst query string false "Destination IP (default: any internet destination)"
+// @Param router query string false "Limit to one router hostname"
+// @Success 200 {array} lookup.Result
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/lookup [get]
+// @Router /api/v2/lookup [get]
+func (s *Server) lookupRoute(c *gin.Context) {
+	src := net.ParseIP(c.Query("src"))
+	if src == nil {
+		respondError(c, http.StatusBadRequest, "Invalid source", "src must be an IP address")
+		return
+	}
+	var dst net.IP
+	if v := c.Query("dst"); v != "" {
+		if dst = net.ParseIP(v); dst == nil {
+			respondError(c, http.StatusBadRequest, "Invalid destination", "dst must be an IP address")
+			return
+		}
+		if (dst.To4() != nil) != (src.To4() != nil) {
+			respondError(c, http.StatusBadRequest, "Invalid destination", "src and dst must be the same address family")
+			return
+		}
+	}
+	routerFilter := c.Query("router")
+
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	out := make([]lookup.Result, 0, len(states))
+	for _, st := range states {
+		if routerFilter != "" && st.Hostname != routerFilter {
+			continue
+		}
+		out = append(out, lookup.Evaluate(st, providers, policies, src, dst))
+	}
+	if routerFilter != "" && len(out) == 0 {
+		respondError(c, http.StatusNotFound, "Router not found", "no state reported for "+routerFilter)
+		return
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	c.JSON(http.StatusOK, out)
+}
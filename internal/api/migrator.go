@@ -1,6 +1,7 @@
 package api
 
 import (
+	"router-sync/internal/models"
 	"router-sync/internal/nats"
 
 	"github.com/sirupsen/logrus"
@@ -53,3 +54,65 @@ func MigrateProviderInterfaces(client *nats.Client) error {
 	}
 	return nil
 }
+
+// MigratePolicyIDs rewrites policies stored before IDs and sources were
+// split. Those records have no SourceIP and are keyed by their source; each
+// is stored again under a generated ID with SourceIP set, and the old key is
+// deleted afterwards so agents never see the source without a policy.
+func MigratePolicyIDs(client *nats.Client) error {
+	policies, err := client.ListPolicies()
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, p := range policies {
+		if p.SourceIP != "" {
+			continue
+		}
+		oldID := p.ID
+		upgraded := *p
+		upgraded.Generation = 0
+		if !upgradeLegacyPolicy(&upgraded, nil) {
+			logrus.Warnf("Policy %s has no SourceIP and its ID is not a valid source; leaving it as is", oldID)
+			continue
+		}
+		if err := client.StorePolicy(&upgraded); err != nil {
+			logrus.Warnf("Failed to migrate policy %s: %v", oldID, err)
+			continue
+		}
+		if err := client.DeletePolicy(oldID); err != nil {
+			logrus.Warnf("Migrated policy %s to %s but failed to delete the old record: %v", oldID, upgraded.ID, err)
+			continue
+		}
+		migrated++
+		logrus.Infof("Migrated policy %s (%s): ID=%s", p.Name, oldID, upgraded.ID)
+	}
+
+	if migrated > 0 {
+		logrus.Infof("Policy ID migration done: %d policies updated", migrated)
+	}
+	return nil
+}
+
+// upgradeLegacyPolicy moves the source of a policy without SourceIP from its
+// ID into SourceIP and gives it a new ID, or the ID of the policy in stored
+// with that source so re-importing an old export updates rather than
+// duplicates it. It reports whether p was changed.
+func upgradeLegacyPolicy(p *models.RoutingPolicy, stored []*models.RoutingPolicy) bool {
+	if p.SourceIP != "" {
+		return false
+	}
+	if _, err := models.ParseSource(p.ID); err != nil {
+		return false
+	}
+	p.SourceIP = p.ID
+	p.ID = models.NewPolicyID()
+	for _, other := range stored {
+		if other.SourceIP == p.SourceIP {
+			p.ID = other.ID
+			break
+		}
+	}
+	return true
+}
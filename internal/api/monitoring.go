@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"router-sync/internal/monitoring"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAlertRules returns the recommended Prometheus rules for this deployment
+// @Summary Prometheus rules
+// @Description Recommended recording and alerting rules (provider down, drift detected, sync failing, NATS unreachable, router stale) as a Prometheus rule file, using this API's metrics.namespace.
+// @Tags monitoring
+// @Produce application/yaml
+// @Success 200 {object} monitoring.RuleFile
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/monitoring/rules [get]
+// @Router /api/v2/monitoring/rules [get]
+func (s *Server) getAlertRules(c *gin.Context) {
+	data, err := monitoring.RulesYAML(s.metricsNamespace())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to encode rules", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// getDashboard returns a Grafana dashboard for this deployment
+// @Summary Grafana dashboard
+// @Description Grafana dashboard model (import it under Dashboards > New > Import) covering provider health, drift, syncs, watch lag, traffic, NATS and the API, using this API's metrics.namespace.
+// @Tags monitoring
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/monitoring/dashboard [get]
+// @Router /api/v2/monitoring/dashboard [get]
+func (s *Server) getDashboard(c *gin.Context) {
+	data, err := monitoring.DashboardJSON(s.metricsNamespace())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to encode dashboard", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+func (s *Server) metricsNamespace() string {
+	if s.effective == nil {
+		return ""
+	}
+	return s.effective.Metrics.Namespace
+}
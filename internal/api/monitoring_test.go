@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"router-sync/internal/config"
+	"router-sync/internal/monitoring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitoringMetricsRegistered(t *testing.T) {
+	server, err := NewServer(&config.Config{}, new(MockNATSClient), "test", "", "")
+	require.NoError(t, err)
+	defer server.stop()
+
+	// Vectors are only gathered once they have a series
+	server.httpRequestsTotal.WithLabelValues("GET", "/", "200")
+	server.httpRequestDuration.WithLabelValues("GET", "/")
+	server.stateAgeSeconds.WithLabelValues("r1")
+
+	families, err := server.reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, f := range families {
+		names[strings.TrimPrefix(f.GetName(), config.DefaultMetricsNamespace+"_")] = true
+	}
+	for _, m := range monitoring.Metrics {
+		if strings.HasPrefix(m, "http_") || strings.HasPrefix(m, "router") {
+			assert.True(t, names[m], "%s is not registered by the API", m)
+		}
+	}
+}
+
+func TestMonitoringEndpoints(t *testing.T) {
+	full := &config.Config{Metrics: config.MetricsConfig{Namespace: "edge"}}
+	server, err := NewServer(full, new(MockNATSClient), "test", "", "")
+	require.NoError(t, err)
+	defer server.stop()
+
+	w := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/monitoring/rules", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "edge_agent_provider_up == 0")
+
+	w = httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/monitoring/dashboard", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"uid": "router-sync"`)
+	assert.Contains(t, w.Body.String(), "edge_agent_drift_changes")
+}
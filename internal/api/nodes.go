@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+	natsclient "router-sync/internal/nats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Node is one agent registered in the router-sync-state bucket. InSync is
+// true when the agent's applied generation matches the store's.
+type Node struct {
+	Hostname          string    `json:"hostname"`
+	AgentVersion      string    `json:"agent_version"`
+	LogLevel          string    `json:"log_level"`
+	LastSeen          time.Time `json:"last_seen"`
+	AgeSeconds        float64   `json:"age_seconds"`
+	Online            bool      `json:"online"`
+	Maintenance       bool      `json:"maintenance"`
+	AppliedGeneration string    `json:"applied_generation"`
+	InSync            bool      `json:"in_sync"`
+	Rules             int       `json:"rules"`
+	Features          []string  `json:"features"`
+}
+
+// NodeListResponse lists the fleet against the store's current generation.
+type NodeListResponse struct {
+	DesiredGeneration string `json:"desired_generation"`
+	Nodes             []Node `json:"nodes"`
+	OutOfSync         int    `json:"out_of_sync"`
+}
+
+// NodeState is a live kernel snapshot taken on the node through the NATS
+// control channel (not the last heartbeat).
+type NodeState struct {
+	Hostname    string                `json:"hostname"`
+	CollectedAt time.Time             `json:"collected_at"`
+	Interfaces  []models.Interface    `json:"interfaces,omitempty"`
+	Tables      []models.RoutingTable `json:"tables,omitempty"`
+	Rules       []models.IPRule       `json:"rules,omitempty"`
+}
+
+// nodeFromState summarizes a heartbeat against the desired generation.
+func nodeFromState(st *models.RouterState, desired string, now time.Time) Node {
+	age := now.Sub(st.LastSeen).Seconds()
+	return Node{
+		Hostname:          st.Hostname,
+		AgentVersion:      st.AgentVersion,
+		LogLevel:          st.LogLevel,
+		LastSeen:          st.LastSeen,
+		AgeSeconds:        age,
+		Online:            age < 30,
+		Maintenance:       st.Maintenance,
+		AppliedGeneration: st.AppliedGeneration,
+		InSync:            st.AppliedGeneration != "" && st.AppliedGeneration == desired,
+		Rules:             len(st.Rules),
+		Features:          st.Features,
+	}
+}
+
+// desiredGeneration computes models.ConfigGeneration from the store.
+func (s *Server) desiredGeneration() (string, error) {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		return "", err
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return "", err
+	}
+	return models.ConfigGeneration(providers, policies), nil
+}
+
+// listNodes lists the agents sharing this store
+// @Summary List nodes
+// @Description List every agent with a heartbeat in the store: version, last heartbeat, maintenance flag and the configuration generation it has applied, compared with the store's current generation.
+// @Tags nodes
+// @Produce json
+// @Success 200 {object} NodeListResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/nodes [get]
+// @Router /api/v2/nodes [get]
+func (s *Server) listNodes(c *gin.Context) {
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+	desired, err := s.desiredGeneration()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to compute configuration generation", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	out := NodeListResponse{DesiredGeneration: desired, Nodes: make([]Node, 0, len(states))}
+	for _, st := range states {
+		node := nodeFromState(st, desired, now)
+		if !node.InSync {
+			out.OutOfSync++
+		}
+		out.Nodes = append(out.Nodes, node)
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool { return out.Nodes[i].Hostname < out.Nodes[j].Hostname })
+	c.JSON(http.StatusOK, out)
+}
+
+// getNode returns one node's summary
+// @Summary Get node
+// @Description Get one agent's heartbeat summary and sync status.
+// @Tags nodes
+// @Produce json
+// @Param id path string true "Node hostname"
+// @Success 200 {object} Node
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/nodes/{id} [get]
+// @Router /api/v2/nodes/{id} [get]
+func (s *Server) getNode(c *gin.Context) {
+	st, err := s.natsClient.GetRouterState(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Node not found", err.Error())
+		return
+	}
+	desired, err := s.desiredGeneration()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to compute configuration generation", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, nodeFromState(st, desired, time.Now().UTC()))
+}
+
+// getNodeRules returns the node's live ip rules
+// @Summary Live node rules
+// @Description Read the node's ip rules now, proxied over the NATS control channel, instead of from the last heartbeat.
+// @Tags nodes
+// @Produce json
+// @Param id path string true "Node hostname"
+// @Success 200 {object} NodeState
+// @Failure 502 {object} ErrorResponse "The agent answered with an error"
+// @Failure 504 {object} ErrorResponse "The agent did not respond"
+// @Router /api/v1/nodes/{id}/rules [get]
+// @Router /api/v2/nodes/{id}/rules [get]
+func (s *Server) getNodeRules(c *gin.Context) {
+	if st, ok := s.collectNodeState(c); ok {
+		c.JSON(http.StatusOK, NodeState{Hostname: st.Hostname, CollectedAt: st.LastSeen, Rules: st.Rules})
+	}
+}
+
+// getNodeRoutes returns the node's live routing tables
+// @Summary Live node routes
+// @Description Read the node's routing tables now, proxied over the NATS control channel.
+// @Tags nodes
+// @Produce json
+// @Param id path string true "Node hostname"
+// @Success 200 {object} NodeState
+// @Failure 502 {object} ErrorResponse "The agent answered with an error"
+// @Failure 504 {object} ErrorResponse "The agent did not respond"
+// @Router /api/v1/nodes/{id}/routes [get]
+// @Router /api/v2/nodes/{id}/routes [get]
+func (s *Server) getNodeRoutes(c *gin.Context) {
+	if st, ok := s.collectNodeState(c); ok {
+		c.JSON(http.StatusOK, NodeState{Hostname: st.Hostname, CollectedAt: st.LastSeen, Tables: st.Tables})
+	}
+}
+
+// getNodeInterfaces returns the node's live interfaces
+// @Summary Live node interfaces
+// @Description Read the node's network interfaces now, proxied over the NATS control channel.
+// @Tags nodes
+// @Produce json
+// @Param id path string true "Node hostname"
+// @Success 200 {object} NodeState
+// @Failure 502 {object} ErrorResponse "The agent answered with an error"
+// @Failure 504 {object} ErrorResponse "The agent did not respond"
+// @Router /api/v1/nodes/{id}/interfaces [get]
+// @Router /api/v2/nodes/{id}/interfaces [get]
+func (s *Server) getNodeInterfaces(c *gin.Context) {
+	if st, ok := s.collectNodeState(c); ok {
+		c.JSON(http.StatusOK, NodeState{Hostname: st.Hostname, CollectedAt: st.LastSeen, Interfaces: st.Interfaces})
+	}
+}
+
+// collectNodeState sends state.collect to the node, writing the error
+// response itself when it fails.
+func (s *Server) collectNodeState(c *gin.Context) (*models.RouterState, bool) {
+	host := c.Param("id")
+	cmd := &models.AgentCommand{Command: models.CommandStateCollect}
+	if identity := identityFrom(c); identity != nil {
+		cmd.RequestedBy = identity.Subject
+	}
+
+	reply, err := s.natsClient.SendAgentCommand(c.Request.Context(), host, cmd)
+	if err != nil {
+		if errors.Is(err, natsclient.ErrAgentUnavailable) {
+			respondError(c, http.StatusGatewayTimeout, "Node did not respond", err.Error())
+		} else {
+			respondError(c, http.StatusBadGateway, "Failed to query node", err.Error())
+		}
+		return nil, false
+	}
+	if !reply.OK {
+		respondError(c, http.StatusBadGateway, "Node returned an error", reply.Error)
+		return nil, false
+	}
+	var st models.RouterState
+	if err := json.Unmarshal(reply.Data, &st); err != nil {
+		respondError(c, http.StatusBadGateway, "Invalid node reply", err.Error())
+		return nil, false
+	}
+	return &st, true
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeFromState(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := &models.RouterState{
+		Hostname:          "r1",
+		AgentVersion:      "1.4.0",
+		LastSeen:          now.Add(-10 * time.Second),
+		AppliedGeneration: "abc",
+		Rules:             []models.IPRule{{Priority: 2000}, {Priority: 2001}},
+	}
+
+	node := nodeFromState(st, "abc", now)
+	assert.True(t, node.Online)
+	assert.True(t, node.InSync)
+	assert.Equal(t, 2, node.Rules)
+	assert.Equal(t, 10.0, node.AgeSeconds)
+
+	assert.False(t, nodeFromState(st, "def", now).InSync)
+
+	st.LastSeen = now.Add(-time.Minute)
+	st.AppliedGeneration = ""
+	stale := nodeFromState(st, "", now)
+	assert.False(t, stale.Online)
+	assert.False(t, stale.InSync, "agents that do not report a generation are never in sync")
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPprofOnAdminListener(t *testing.T) {
+	full := &config.Config{
+		API: config.APIConfig{
+			Address:      "127.0.0.1:0",
+			AdminAddress: "127.0.0.1:0",
+		},
+		Profiling: config.ProfilingConfig{AdminListener: true},
+	}
+	server, err := NewServer(full, new(MockNATSClient), "test", "", "")
+	require.NoError(t, err)
+	defer server.stop()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/heap", "/debug/pprof/block"} {
+		w := httptest.NewRecorder()
+		server.adminServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "pprof must not be served on the main listener")
+}
+
+func TestPprofAdminListenerRequiresAdminAddress(t *testing.T) {
+	full := &config.Config{
+		API:       config.APIConfig{Address: "127.0.0.1:0"},
+		Profiling: config.ProfilingConfig{AdminListener: true},
+	}
+	_, err := NewServer(full, new(MockNATSClient), "test", "", "")
+	assert.ErrorContains(t, err, "api.admin_address")
+}
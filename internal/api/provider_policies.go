@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProviderPolicy is a policy routed via the provider, with how each reporting
+// router currently applies it (applied, missing, mismatch, removed, stale,
+// failed_over, provider_down).
+type ProviderPolicy struct {
+	models.RoutingPolicy
+	Routers      map[string]string `json:"routers"`
+	AppliedCount int               `json:"applied_count"`
+}
+
+// ProviderPoliciesResponse is returned by GET /providers/{id}/policies.
+type ProviderPoliciesResponse struct {
+	ProviderID   string           `json:"provider_id"`
+	Policies     []ProviderPolicy `json:"policies"`
+	EnabledCount int              `json:"enabled_count"`
+	Routers      []string         `json:"routers"`
+}
+
+// listProviderPolicies lists the policies that use a provider
+// @Summary List policies of a provider
+// @Description List every policy whose provider_id is this provider, with its applied status on each router that reports state: applied, missing (enabled but no rule), mismatch (rule points at another table or priority), removed (disabled, no rule), stale (disabled but still installed), failed_over (its provider is down and the router moved it to another) or provider_down (its provider is down and no other can take it). Use it to see the blast radius before changing, draining or deleting a provider.
+// @Tags providers
+// @Produce json
+// @Param id path string true "Provider ID"
+// @Success 200 {object} ProviderPoliciesResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/providers/{id}/policies [get]
+// @Router /api/v2/providers/{id}/policies [get]
+func (s *Server) listProviderPolicies(c *gin.Context) {
+	provider, err := s.natsClient.GetProvider(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Provider not found", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, providerPolicies(provider, policies, states))
+}
+
+// providerPolicies builds the response for provider from all policies and
+// router states.
+func providerPolicies(provider *models.InternetProvider, policies []*models.RoutingPolicy, states []*models.RouterState) ProviderPoliciesResponse {
+	resp := ProviderPoliciesResponse{
+		ProviderID: provider.ID,
+		Policies:   []ProviderPolicy{},
+		Routers:    make([]string, 0, len(states)),
+	}
+	for _, st := range states {
+		resp.Routers = append(resp.Routers, st.Hostname)
+	}
+	sort.Strings(resp.Routers)
+
+	for _, p := range policies {
+		if p.ProviderID != provider.ID {
+			continue
+		}
+		item := ProviderPolicy{RoutingPolicy: *p, Routers: make(map[string]string, len(states))}
+		for _, st := range states {
+			status := diff.PolicyStatus(st, p, provider)
+			item.Routers[st.Hostname] = status
+			if status == diff.StatusApplied {
+				item.AppliedCount++
+			}
+		}
+		if p.Enabled {
+			resp.EnabledCount++
+		}
+		resp.Policies = append(resp.Policies, item)
+	}
+	sort.Slice(resp.Policies, func(i, j int) bool { return resp.Policies[i].ID < resp.Policies[j].ID })
+	return resp
+}
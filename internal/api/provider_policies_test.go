@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderPolicies(t *testing.T) {
+	provider := &models.InternetProvider{ID: "Telecom", TableID: 100}
+	policies := []*models.RoutingPolicy{
+		{ID: "192.168.2.26", ProviderID: "Telecom"},
+		{ID: "192.168.2.25", ProviderID: "Telecom", Enabled: true},
+		{ID: "192.168.3.0/24", ProviderID: "Starlink", Enabled: true},
+	}
+	states := []*models.RouterState{
+		{Hostname: "r2"},
+		{Hostname: "r1", Rules: []models.IPRule{{Priority: 2000, From: "192.168.2.25", Table: 100}}},
+	}
+
+	got := providerPolicies(provider, policies, states)
+
+	assert.Equal(t, "Telecom", got.ProviderID)
+	assert.Equal(t, []string{"r1", "r2"}, got.Routers)
+	assert.Equal(t, 1, got.EnabledCount)
+	require.Len(t, got.Policies, 2)
+	assert.Equal(t, "192.168.2.25", got.Policies[0].ID)
+	assert.Equal(t, map[string]string{"r1": diff.StatusApplied, "r2": diff.StatusMissing}, got.Policies[0].Routers)
+	assert.Equal(t, 1, got.Policies[0].AppliedCount)
+	assert.Equal(t, map[string]string{"r1": diff.StatusRemoved, "r2": diff.StatusRemoved}, got.Policies[1].Routers)
+}
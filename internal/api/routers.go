@@ -1,9 +1,14 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
+	"router-sync/internal/models"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,13 +19,11 @@ import (
 // @Produce json
 // @Success 200 {array} models.RouterState
 // @Router /api/v1/routers [get]
+// @Router /api/v2/routers [get]
 func (s *Server) listRouters(c *gin.Context) {
 	states, err := s.natsClient.ListRouterStates()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to list router states",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
 		return
 	}
 
@@ -52,16 +55,14 @@ func (s *Server) listRouters(c *gin.Context) {
 // @Produce json
 // @Param hostname path string true "Router hostname"
 // @Success 200 {object} models.RouterState
-// @Failure 404 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/routers/{hostname} [get]
+// @Router /api/v2/routers/{hostname} [get]
 func (s *Server) getRouter(c *gin.Context) {
 	hostname := c.Param("hostname")
 	state, err := s.natsClient.GetRouterState(hostname)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Router not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Router not found", err.Error())
 		return
 	}
 
@@ -86,16 +87,14 @@ func (s *Server) getRouter(c *gin.Context) {
 // @Produce json
 // @Param hostname path string true "Router hostname"
 // @Success 200 {array} models.Interface
-// @Failure 404 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/routers/{hostname}/interfaces [get]
+// @Router /api/v2/routers/{hostname}/interfaces [get]
 func (s *Server) getRouterInterfaces(c *gin.Context) {
 	hostname := c.Param("hostname")
 	state, err := s.natsClient.GetRouterState(hostname)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Router not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Router not found", err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, state.Interfaces)
@@ -107,16 +106,14 @@ func (s *Server) getRouterInterfaces(c *gin.Context) {
 // @Produce json
 // @Param hostname path string true "Router hostname"
 // @Success 200 {array} models.RoutingTable
-// @Failure 404 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/routers/{hostname}/routes [get]
+// @Router /api/v2/routers/{hostname}/routes [get]
 func (s *Server) getRouterRoutes(c *gin.Context) {
 	hostname := c.Param("hostname")
 	state, err := s.natsClient.GetRouterState(hostname)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Router not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Router not found", err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, state.Tables)
@@ -128,17 +125,347 @@ func (s *Server) getRouterRoutes(c *gin.Context) {
 // @Produce json
 // @Param hostname path string true "Router hostname"
 // @Success 200 {array} models.IPRule
-// @Failure 404 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
 // @Router /api/v1/routers/{hostname}/rules [get]
+// @Router /api/v2/routers/{hostname}/rules [get]
 func (s *Server) getRouterRules(c *gin.Context) {
 	hostname := c.Param("hostname")
 	state, err := s.natsClient.GetRouterState(hostname)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Router not found",
-			"details": err.Error(),
-		})
+		respondError(c, http.StatusNotFound, "Router not found", err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, state.Rules)
 }
+
+// TableRoutes is the kernel content of one routing table on one router, as
+// last collected by that router's agent via netlink.
+type TableRoutes struct {
+	Hostname   string         `json:"hostname"`
+	Online     bool           `json:"online"`
+	LastSeen   time.Time      `json:"last_seen"`
+	TableID    int            `json:"table_id"`
+	TableName  string         `json:"table_name,omitempty"`
+	ProviderID string         `json:"provider_id,omitempty"`
+	Routes     []models.Route `json:"routes"`
+}
+
+// listRoutes returns the routes actually installed in provider tables on every router.
+// @Summary Inspect kernel routing tables
+// @Description Return the routes each agent found in the kernel (netlink dump published with its heartbeat). Without `table` only provider tables are returned; a table that exists in no router yields an empty list.
+// @Tags routers
+// @Produce json
+// @Param table query int false "Routing table ID"
+// @Param router query string false "Limit to one router hostname"
+// @Success 200 {array} TableRoutes
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/routes [get]
+// @Router /api/v2/routes [get]
+func (s *Server) listRoutes(c *gin.Context) {
+	tableFilter := 0
+	if v := c.Query("table"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid table", fmt.Sprintf("table must be a positive integer, got %q", v))
+			return
+		}
+		tableFilter = id
+	}
+	routerFilter := c.Query("router")
+
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	providerByTable := make(map[int]string, len(providers))
+	for _, p := range providers {
+		providerByTable[p.TableID] = p.ID
+	}
+
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	out := make([]TableRoutes, 0)
+	for _, st := range states {
+		if routerFilter != "" && st.Hostname != routerFilter {
+			continue
+		}
+		for _, tbl := range st.Tables {
+			providerID, isProvider := providerByTable[tbl.ID]
+			if tableFilter != 0 && tbl.ID != tableFilter {
+				continue
+			}
+			if tableFilter == 0 && !isProvider {
+				continue
+			}
+			routes := tbl.Routes
+			if routes == nil {
+				routes = []models.Route{}
+			}
+			out = append(out, TableRoutes{
+				Hostname:   st.Hostname,
+				Online:     now.Sub(st.LastSeen).Seconds() < 30,
+				LastSeen:   st.LastSeen,
+				TableID:    tbl.ID,
+				TableName:  tbl.Name,
+				ProviderID: providerID,
+				Routes:     routes,
+			})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TableID != out[j].TableID {
+			return out[i].TableID < out[j].TableID
+		}
+		return out[i].Hostname < out[j].Hostname
+	})
+	c.JSON(http.StatusOK, out)
+}
+
+// ManagedRule is an ip rule in the managed priority range on one router,
+// matched against the policy that should own it. Mark rules (FwMark set) are
+// shared by the protocol/port policies of one provider, so they carry only
+// the provider. Rules with an Action are strict policies' blackhole rules.
+type ManagedRule struct {
+	Hostname      string `json:"hostname"`
+	Priority      int    `json:"priority"`
+	From          string `json:"from"`
+	To            string `json:"to,omitempty"`
+	FwMark        int    `json:"fwmark,omitempty"`
+	Action        string `json:"action,omitempty"`
+	Table         int    `json:"table"`
+	TableName     string `json:"table_name,omitempty"`
+	PolicyID      string `json:"policy_id,omitempty"`
+	PolicyName    string `json:"policy_name,omitempty"`
+	ProviderID    string `json:"provider_id,omitempty"`
+	ExpectedTable int    `json:"expected_table,omitempty"`
+	InSync        bool   `json:"in_sync"`
+	Orphan        bool   `json:"orphan"`
+}
+
+// listRules returns the managed ip rules on every router with their owning policy.
+// @Summary Inspect managed ip rules
+// @Description List ip rules in the managed priority range (2000-2032 by default) as reported by each agent, with the owning policy. Rules whose source matches no enabled policy are flagged orphan; in_sync is false when the rule points at a different table than the policy's provider.
+// @Tags routers
+// @Produce json
+// @Param router query string false "Limit to one router hostname"
+// @Param orphan query bool false "Only return orphan rules"
+// @Success 200 {array} ManagedRule
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/rules [get]
+// @Router /api/v2/rules [get]
+func (s *Server) listRules(c *gin.Context) {
+	routerFilter := c.Query("router")
+	onlyOrphans, _ := strconv.ParseBool(c.DefaultQuery("orphan", "false"))
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	tableByProvider := make(map[string]int, len(providers))
+	for _, p := range providers {
+		tableByProvider[p.ID] = p.TableID
+	}
+
+	now := time.Now()
+
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	out := make([]ManagedRule, 0)
+	for _, st := range states {
+		if routerFilter != "" && st.Hostname != routerFilter {
+			continue
+		}
+		idx := indexManagedRules(models.ResolvePolicies(policies, st.DelegatedPrefixes), tableByProvider, now)
+		for _, r := range st.Rules {
+			if !models.IsManagedPriority(r.Priority) {
+				continue
+			}
+			rule := ManagedRule{
+				Hostname:  st.Hostname,
+				Priority:  r.Priority,
+				From:      r.From,
+				To:        r.To,
+				FwMark:    r.FwMark,
+				Action:    r.Action,
+				Table:     r.Table,
+				TableName: r.TableName,
+				Orphan:    true,
+			}
+			if r.Action != "" {
+				// A strict policy's blackhole rule: in sync at the policy's priority
+				if key, ok := r.RuleKey(); ok {
+					if p, ok := idx.byRule[key]; ok && p.Strict() {
+						srcNet, _ := p.SourceNet()
+						rule.PolicyID = p.ID
+						rule.PolicyName = p.Name
+						rule.ProviderID = p.ProviderID
+						rule.InSync = r.Action == models.RuleActionBlackhole && p.RulePriority(srcNet) == r.Priority
+						rule.Orphan = false
+					}
+				}
+			} else if r.FwMark != 0 {
+				providerID, ok := idx.marks[[2]int{r.Priority, r.FwMark}]
+				if !ok && st.SourceSets {
+					providerID, ok = idx.setMarks[[2]int{r.Priority, r.FwMark}]
+				}
+				if ok {
+					rule.ProviderID = providerID
+					rule.ExpectedTable = r.FwMark
+					rule.InSync = r.Table == r.FwMark
+					rule.Orphan = false
+				}
+			} else if key, ok := r.RuleKey(); ok {
+				// A source set policy's own rule is left over from before
+				// source sets were enabled
+				if p, ok := idx.byRule[key]; ok && !p.InSourceSet(st.SourceSets) {
+					srcNet, _ := p.SourceNet()
+					rule.PolicyID = p.ID
+					rule.PolicyName = p.Name
+					rule.ProviderID = p.ProviderID
+					rule.ExpectedTable = tableByProvider[p.ProviderID]
+					rule.InSync = rule.ExpectedTable == r.Table && p.RulePriority(srcNet) == r.Priority
+					rule.Orphan = false
+				}
+			}
+			if onlyOrphans && !rule.Orphan {
+				continue
+			}
+			out = append(out, rule)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hostname != out[j].Hostname {
+			return out[i].Hostname < out[j].Hostname
+		}
+		return out[i].Priority < out[j].Priority
+	})
+	c.JSON(http.StatusOK, out)
+}
+
+// managedRuleIndex holds the active policies keyed by canonical source (and
+// destination); for protocol/port policies, the providers whose mark rule
+// they need at each priority, and likewise for plain source policies on
+// routers with source sets.
+type managedRuleIndex struct {
+	byRule   map[string]*models.RoutingPolicy
+	marks    map[[2]int]string
+	setMarks map[[2]int]string
+}
+
+// indexManagedRules indexes policies, resolved for one router, for listRules.
+func indexManagedRules(policies []*models.RoutingPolicy, tableByProvider map[string]int, now time.Time) managedRuleIndex {
+	idx := managedRuleIndex{
+		byRule:   make(map[string]*models.RoutingPolicy, len(policies)),
+		marks:    make(map[[2]int]string),
+		setMarks: make(map[[2]int]string),
+	}
+	for _, p := range policies {
+		if !p.Active(now) {
+			continue
+		}
+		if p.MarkRouted(true) {
+			if srcNet, err := p.SourceNet(); err == nil {
+				if table, ok := tableByProvider[p.ProviderID]; ok {
+					mark := [2]int{p.RulePriority(srcNet), table}
+					if p.Classified() {
+						idx.marks[mark] = p.ProviderID
+					} else {
+						idx.setMarks[mark] = p.ProviderID
+					}
+				}
+			}
+			if p.Classified() {
+				continue
+			}
+		}
+		if key, ok := p.RuleKey(); ok {
+			idx.byRule[key] = p
+		}
+	}
+	return idx
+}
+
+// RouterInterface is a NIC reported by an agent, with the providers bound to it.
+type RouterInterface struct {
+	Hostname string `json:"hostname"`
+	models.Interface
+	ProviderIDs []string `json:"provider_ids,omitempty"`
+}
+
+// listInterfaces returns the NICs of every router for provider forms.
+// @Summary List router interfaces
+// @Description List network interfaces reported by each agent (collected via netlink): type, admin/oper state, carrier, MTU and addresses, plus the providers already using each one. Loopback is omitted unless all=true.
+// @Tags routers
+// @Produce json
+// @Param router query string false "Limit to one router hostname"
+// @Param up query bool false "Only interfaces that are up with carrier"
+// @Param all query bool false "Include loopback"
+// @Success 200 {array} RouterInterface
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/interfaces [get]
+// @Router /api/v2/interfaces [get]
+func (s *Server) listInterfaces(c *gin.Context) {
+	routerFilter := c.Query("router")
+	onlyUp, _ := strconv.ParseBool(c.DefaultQuery("up", "false"))
+	includeAll, _ := strconv.ParseBool(c.DefaultQuery("all", "false"))
+
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	out := make([]RouterInterface, 0)
+	for _, st := range states {
+		if routerFilter != "" && st.Hostname != routerFilter {
+			continue
+		}
+		for _, iface := range st.Interfaces {
+			if !includeAll && (iface.Name == "lo" || iface.Type == "loopback") {
+				continue
+			}
+			if onlyUp && !(iface.Up && iface.Carrier) {
+				continue
+			}
+			ri := RouterInterface{Hostname: st.Hostname, Interface: iface}
+			for _, p := range providers {
+				if p.InterfaceForHost(st.Hostname) == iface.Name {
+					ri.ProviderIDs = append(ri.ProviderIDs, p.ID)
+				}
+			}
+			out = append(out, ri)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hostname != out[j].Hostname {
+			return out[i].Hostname < out[j].Hostname
+		}
+		return out[i].Name < out[j].Name
+	})
+	c.JSON(http.StatusOK, out)
+}
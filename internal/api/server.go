@@ -3,15 +3,19 @@ package api
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 
 	"router-sync/docs"
+	"router-sync/internal/auth"
 	"router-sync/internal/config"
-	"router-sync/internal/logging"
 	"router-sync/internal/metrics"
 	"router-sync/internal/nats"
+	"router-sync/internal/profiling"
+	"router-sync/internal/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +30,22 @@ type Server struct {
 	config     config.APIConfig
 	natsClient nats.NATSClient
 	server     *http.Server
+	verifier   *auth.Verifier
+	events     *eventHub
+	ctx        context.Context
+	stop       context.CancelFunc
+
+	// adminServer serves the admin-only routes when APIConfig.AdminAddress
+	// is set; nil otherwise.
+	adminServer *http.Server
+
+	// listener and adminListener are bound by Listen.
+	listener      net.Listener
+	adminListener net.Listener
+
+	// effective is the whole resolved process configuration, served
+	// redacted by GET /admin/config.
+	effective *config.Config
 
 	reg                 *prometheus.Registry
 	httpRequestsTotal   *prometheus.CounterVec
@@ -34,16 +54,38 @@ type Server struct {
 	policiesTotal       prometheus.Gauge
 	routersKnown        prometheus.Gauge
 	stateAgeSeconds     *prometheus.GaugeVec
+	fleetAgents         *prometheus.GaugeVec
+	policiesByLabel     *prometheus.GaugeVec
+	providerUsageBytes  *prometheus.GaugeVec
 	logLevelSetTotal    prometheus.Counter
 
+	stats         statsCache
+	confirmations confirmationStore
+	webhooks      *webhook.Dispatcher
+
 	version   string
 	buildTime string
 	gitCommit string
 }
 
-// NewServer creates a new API server.
-func NewServer(cfg config.APIConfig, natsClient nats.NATSClient, version, buildTime, gitCommit string) *Server {
+// NewServer creates a new API server from the api section of full. When
+// cfg.Auth is enabled every /api/v1 route requires a bearer token: viewers may
+// read, operators may also manage policies, admins may also manage providers,
+// sync and log levels.
+func NewServer(full *config.Config, natsClient nats.NATSClient, version, buildTime, gitCommit string) (*Server, error) {
+	cfg := full.API
+	switch cfg.GinMode {
+	case "", gin.ReleaseMode:
+		gin.SetMode(gin.ReleaseMode)
+	case gin.DebugMode, gin.TestMode:
+		gin.SetMode(cfg.GinMode)
+	default:
+		return nil, fmt.Errorf("invalid gin_mode %q (expected release, debug or test)", cfg.GinMode)
+	}
+
 	reg := metrics.NewRegistry()
+	node, _ := os.Hostname()
+	registerer := metrics.Registerer(reg, full.Metrics, node, version)
 
 	httpRequestsTotal := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -82,15 +124,43 @@ func NewServer(cfg config.APIConfig, natsClient nats.NATSClient, version, buildT
 		Help: "Age of the latest router state heartbeat in seconds.",
 	}, []string{"hostname"})
 
+	fleetAgents := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "fleet_agents",
+		Help: "Number of agents by heartbeat state (alive, dead, stopped).",
+	}, []string{"state"})
+
+	policiesByLabel := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "policies_by_label",
+		Help: "Routing policies per value of each label listed in api.metrics_labels.",
+	}, []string{"label", "value", "enabled"})
+
+	providerUsageBytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "provider_usage_bytes",
+		Help: "Bytes a provider carried in its current billing period, by direction (rx, tx).",
+	}, []string{"provider", "direction"})
+
 	logLevelSetTotal := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "log_level_set_total",
 		Help: "Number of log level changes applied via the API.",
 	})
 
-	reg.MustRegister(httpRequestsTotal, httpRequestDuration, providersTotal, policiesTotal, routersKnown, stateAgeSeconds, logLevelSetTotal)
+	registerer.MustRegister(httpRequestsTotal, httpRequestDuration, providersTotal, policiesTotal, routersKnown, stateAgeSeconds, fleetAgents, policiesByLabel, providerUsageBytes, logLevelSetTotal)
+	// The real client exports its connection health (nats_*)
+	if c, ok := natsClient.(prometheus.Collector); ok {
+		registerer.MustRegister(c)
+	}
 
+	configuredHooks, err := configuredWebhooks(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook configuration: %w", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
 	server := &Server{
+		ctx:                 ctx,
+		stop:                stop,
 		config:              cfg,
+		effective:           full,
 		natsClient:          natsClient,
 		reg:                 reg,
 		httpRequestsTotal:   httpRequestsTotal,
@@ -99,101 +169,355 @@ func NewServer(cfg config.APIConfig, natsClient nats.NATSClient, version, buildT
 		policiesTotal:       policiesTotal,
 		routersKnown:        routersKnown,
 		stateAgeSeconds:     stateAgeSeconds,
+		fleetAgents:         fleetAgents,
+		policiesByLabel:     policiesByLabel,
+		providerUsageBytes:  providerUsageBytes,
 		logLevelSetTotal:    logLevelSetTotal,
+		events:              newEventHub(),
+		webhooks:            webhook.NewDispatcher(withConfiguredWebhooks(configuredHooks, natsClient.ListWebhooks), "router-sync/"+version),
 		version:             version,
 		buildTime:           buildTime,
 		gitCommit:           gitCommit,
 	}
 
+	if cfg.Auth.Enabled {
+		verifier, err := auth.NewVerifier(cfg.Auth)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("invalid auth configuration: %w", err)
+		}
+		server.verifier = verifier
+		logrus.Infof("API authentication enabled (issuer=%q, hmac=%t)", cfg.Auth.Issuer, cfg.Auth.HMACSecret != "")
+	} else {
+		logrus.Warn("API authentication is disabled; all endpoints are open")
+	}
+
+	cors, err := newCORSPolicy(cfg.CORS)
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+
+	router := server.newEngine(cors)
+	// adminRouter gets /metrics, /swagger and the admin routes; without a
+	// separate admin address that is the main router.
+	adminRouter := router
+	if cfg.AdminAddress != "" {
+		adminRouter = server.newEngine(cors)
+		server.mountAPI(router, server.registerRoutes)
+		server.mountAPI(adminRouter, server.registerAdminRoutes)
+		adminRouter.GET("/health", server.healthCheck)
+		adminRouter.GET("/livez", server.healthCheck)
+		adminRouter.GET("/readyz", server.readinessCheck)
+		adminRouter.GET("/version", server.getVersion)
+	} else {
+		server.mountAPI(router, func(g *gin.RouterGroup) {
+			server.registerRoutes(g)
+			server.registerAdminRoutes(g)
+		})
+	}
+
+	if cfg.DisableSwagger {
+		logrus.Info("Swagger UI disabled (api.disable_swagger)")
+	} else {
+		docs.SwaggerInfo.Host = ""
+		docs.SwaggerInfo.BasePath = "/"
+		docs.SwaggerInfo.Version = version
+		docs.SwaggerInfo.Schemes = []string{"http"}
+		if cfg.TLS.Enabled() {
+			docs.SwaggerInfo.Schemes = []string{"https"}
+		}
+		adminRouter.GET("/swagger", func(c *gin.Context) {
+			c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
+		})
+		adminRouter.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	}
+
+	adminRouter.GET("/metrics", gin.WrapH(metrics.HandlerFor(reg)))
+	if full.Profiling.AdminListener {
+		if cfg.AdminAddress == "" {
+			stop()
+			return nil, fmt.Errorf("profiling.admin_listener requires api.admin_address")
+		}
+		pprof := adminRouter.Group("/debug/pprof", server.authenticate(), server.requireRole(auth.RoleAdmin))
+		pprof.Any("/*any", gin.WrapH(profiling.Handler()))
+		logrus.Infof("Serving pprof on the admin listener %s", cfg.AdminAddress)
+	}
+	router.GET("/health", server.healthCheck)
+	router.GET("/livez", server.healthCheck)
+	router.GET("/readyz", server.readinessCheck)
+	router.GET("/version", server.getVersion)
+
+	server.server = newHTTPServer(cfg, cfg.Address, router)
+
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+		server.server.TLSConfig = tlsConfig
+	}
+
+	if cfg.AdminAddress != "" {
+		server.adminServer = newHTTPServer(cfg, cfg.AdminAddress, adminRouter)
+		server.adminServer.TLSConfig = server.server.TLSConfig
+	}
+
+	return server, nil
+}
+
+// newHTTPServer applies the configured timeouts and header limit to a
+// listener for handler.
+func newHTTPServer(cfg config.APIConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// newEngine returns a gin engine with the middleware shared by the main and
+// admin listeners.
+func (s *Server) newEngine(cors *corsPolicy) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
-	router.Use(server.metricsMiddleware())
-	router.Use(server.urlDecodeMiddleware())
+	router.Use(requestIDMiddleware())
+	router.Use(accessLogMiddleware())
+	router.Use(corsMiddleware(cors))
+	router.Use(s.metricsMiddleware())
+	router.Use(s.urlDecodeMiddleware())
 
 	router.RedirectFixedPath = false
+	router.NoRoute(notFound)
+	return router
+}
 
+// mountAPI mounts register under /api/v1 and /api/v2 on router. v2 serves the
+// same resources; only the error body differs (ErrorResponse).
+func (s *Server) mountAPI(router *gin.Engine, register func(*gin.RouterGroup)) {
 	v1 := router.Group("/api/v1")
+	v1.Use(s.authenticate(), s.tenantScope(), s.idempotency())
+	register(v1)
+
+	v2 := router.Group("/api/v2")
+	v2.Use(s.authenticate(), s.tenantScope(), s.idempotency())
+	register(v2)
+}
+
+// registerRoutes mounts the versioned API resources on g. Viewers may read,
+// operators may also manage policies, admins may also manage providers,
+// import, log levels, webhooks and tenants. Tenant callers are further
+// limited by tenantScope.
+func (s *Server) registerRoutes(g *gin.RouterGroup) {
+	operator := s.requireRole(auth.RoleOperator)
+	admin := s.requireRole(auth.RoleAdmin)
+
+	providers := g.Group("/providers")
 	{
-		providers := v1.Group("/providers")
-		{
-			providers.GET("", server.listProviders)
-			providers.POST("", server.createProvider)
-			providers.GET("/:id", server.getProvider)
-			providers.PUT("/:id", server.updateProvider)
-			providers.DELETE("/:id", server.deleteProvider)
-		}
+		providers.GET("", s.listProviders)
+		providers.POST("", admin, s.createProvider)
+		providers.GET("/:id", s.getProvider)
+		providers.GET("/:id/policies", s.listProviderPolicies)
+		providers.PUT("/:id", admin, s.updateProvider)
+		providers.DELETE("/:id", admin, s.deleteProvider)
+		providers.POST("/:id/drain", admin, s.drainProvider)
+	}
 
-		policies := v1.Group("/policies")
-		{
-			policies.GET("", server.listPolicies)
-			policies.POST("", server.createPolicy)
-			policies.GET("/:id", server.getPolicy)
-			policies.PUT("/:id", server.updatePolicy)
-			policies.DELETE("/:id", server.deletePolicy)
-		}
+	policies := g.Group("/policies")
+	{
+		policies.GET("", s.listPolicies)
+		policies.POST("", operator, s.createPolicy)
+		policies.POST("/bulk", operator, s.bulkPolicies)
+		policies.GET("/:id", s.getPolicy)
+		policies.PUT("/:id", operator, s.updatePolicy)
+		policies.DELETE("/:id", operator, s.deletePolicy)
+		policies.POST("/:id/enable", operator, s.enablePolicy)
+		policies.POST("/:id/disable", operator, s.disablePolicy)
+	}
 
-		routers := v1.Group("/routers")
-		{
-			routers.GET("", server.listRouters)
-			routers.GET("/:hostname", server.getRouter)
-			routers.GET("/:hostname/interfaces", server.getRouterInterfaces)
-			routers.GET("/:hostname/routes", server.getRouterRoutes)
-			routers.GET("/:hostname/rules", server.getRouterRules)
-		}
+	groups := g.Group("/groups")
+	{
+		groups.GET("", s.listGroups)
+		groups.POST("", operator, s.createGroup)
+		groups.GET("/:id", s.getGroup)
+		groups.PUT("/:id", operator, s.updateGroup)
+		groups.DELETE("/:id", operator, s.deleteGroup)
+		groups.POST("/:id/enable", operator, s.enableGroup)
+		groups.POST("/:id/disable", operator, s.disableGroup)
+		groups.POST("/:id/provider", operator, s.setGroupProvider)
+	}
 
-		logs := v1.Group("/logging")
-		{
-			logs.GET("/levels", server.listLogLevels)
-			logs.GET("/level", server.getOwnLogLevel)
-			logs.PUT("/level", server.setOwnLogLevel)
-			logs.GET("/level/:service_id", server.getLogLevelByService)
-			logs.PUT("/level/:service_id", server.setLogLevelByService)
-		}
+	routers := g.Group("/routers")
+	{
+		routers.GET("", s.listRouters)
+		routers.GET("/:hostname", s.getRouter)
+		routers.GET("/:hostname/interfaces", s.getRouterInterfaces)
+		routers.GET("/:hostname/routes", s.getRouterRoutes)
+		routers.GET("/:hostname/rules", s.getRouterRules)
+	}
 
-		v1.POST("/sync", server.triggerSync)
-		v1.GET("/stats", server.getStats)
+	nodes := g.Group("/nodes")
+	{
+		nodes.GET("", s.listNodes)
+		nodes.GET("/:id", s.getNode)
+		nodes.GET("/:id/rules", s.getNodeRules)
+		nodes.GET("/:id/routes", s.getNodeRoutes)
+		nodes.GET("/:id/interfaces", s.getNodeInterfaces)
 	}
 
-	docs.SwaggerInfo.Host = ""
-	docs.SwaggerInfo.BasePath = "/"
-	docs.SwaggerInfo.Schemes = []string{"http"}
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	g.GET("/fleet", s.listFleet)
+	g.DELETE("/fleet/:hostname", admin, s.deleteFleetNode)
 
-	router.GET("/metrics", gin.WrapH(metrics.HandlerFor(reg)))
-	router.GET("/health", server.healthCheck)
+	g.GET("/interfaces", s.listInterfaces)
+	g.GET("/interfaces/:name/gateway", s.suggestGateway)
+	g.GET("/routes", s.listRoutes)
+	g.GET("/rules", s.listRules)
+	g.GET("/diff", s.getDiff)
+	g.GET("/monitoring/rules", s.getAlertRules)
+	g.GET("/monitoring/dashboard", s.getDashboard)
+	g.GET("/lookup", s.lookupRoute)
+
+	g.GET("/stream", s.streamEvents)
+
+	g.GET("/conntrack", s.listConntrack)
+	g.DELETE("/conntrack", operator, s.flushConntrack)
+
+	logs := g.Group("/logging")
+	{
+		logs.GET("/levels", s.listLogLevels)
+		logs.GET("/level", s.getOwnLogLevel)
+		logs.PUT("/level", admin, s.setOwnLogLevel)
+		logs.GET("/level/:service_id", s.getLogLevelByService)
+		logs.PUT("/level/:service_id", admin, s.setLogLevelByService)
+	}
 
-	server.server = &http.Server{
-		Addr:    cfg.Address,
-		Handler: router,
+	g.POST("/validate", s.validateDraft)
+
+	g.GET("/export", s.exportConfig)
+	g.POST("/import", admin, s.importConfig)
+	g.POST("/backup", admin, s.createBackup)
+	g.POST("/restore", admin, s.restoreBackup)
+
+	g.GET("/audit", admin, s.listAudit)
+	g.GET("/journal", s.listJournal)
+
+	webhooks := g.Group("/webhooks", admin)
+	{
+		webhooks.GET("", s.listWebhooks)
+		webhooks.POST("", s.createWebhook)
+		webhooks.GET("/:id", s.getWebhook)
+		webhooks.PUT("/:id", s.updateWebhook)
+		webhooks.DELETE("/:id", s.deleteWebhook)
+		webhooks.POST("/:id/test", s.testWebhook)
+	}
+
+	tenants := g.Group("/tenants")
+	{
+		tenants.GET("", admin, s.listTenants)
+		tenants.POST("", admin, s.createTenant)
+		tenants.GET("/:id", s.getTenant)
+		tenants.PUT("/:id", admin, s.updateTenant)
+		tenants.DELETE("/:id", admin, s.deleteTenant)
 	}
 
-	return server
+	g.GET("/stats", s.getStats)
+	g.GET("/whoami", s.whoami)
 }
 
-// Start starts the API server
+// registerAdminRoutes mounts sync and the /admin endpoints, which move to the
+// admin listener when APIConfig.AdminAddress is set.
+func (s *Server) registerAdminRoutes(g *gin.RouterGroup) {
+	admin := s.requireRole(auth.RoleAdmin)
+
+	g.POST("/sync", admin, s.triggerSync)
+	g.GET("/sync/status", s.getSyncStatus)
+	g.POST("/admin/cleanup", admin, s.cleanupRules)
+	g.GET("/admin/config", admin, s.getEffectiveConfig)
+	g.GET("/admin/maintenance", s.getMaintenance)
+	g.POST("/admin/maintenance", admin, s.setMaintenance)
+}
+
+// Listen binds the API's address and the admin address, if any, so a bad
+// or busy address fails startup before the API reports itself ready. Start
+// calls it when it was not called before.
+func (s *Server) Listen() error {
+	if s.listener != nil {
+		return nil
+	}
+	if s.adminServer != nil {
+		ln, err := net.Listen("tcp", s.adminServer.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on admin address %s: %w", s.adminServer.Addr, err)
+		}
+		s.adminListener = ln
+	}
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		if s.adminListener != nil {
+			_ = s.adminListener.Close()
+			s.adminListener = nil
+		}
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Start starts the API server, over HTTPS when TLS is configured.
 func (s *Server) Start() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+
+	go s.runEventHub(s.ctx)
+	go s.runWebhooks(s.ctx)
+	go s.runStatsLoop(s.ctx)
+	go s.runExpiryLoop(s.ctx)
+
+	if s.adminListener != nil {
+		go s.serveAdmin(s.adminListener)
+	}
+
+	if s.config.TLS.Enabled() {
+		logrus.Infof("Starting API server on %s (TLS, mTLS=%t)", s.config.Address, s.config.TLS.ClientCAFile != "")
+		return s.server.ServeTLS(s.listener, s.config.TLS.CertFile, s.config.TLS.KeyFile)
+	}
 	logrus.Infof("Starting API server on %s", s.config.Address)
-	return s.server.ListenAndServe()
+	return s.server.Serve(s.listener)
 }
 
-// Shutdown gracefully shuts down the API server
-func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+func (s *Server) serveAdmin(ln net.Listener) {
+	var err error
+	if s.config.TLS.Enabled() {
+		logrus.Infof("Starting admin listener on %s (TLS)", s.adminServer.Addr)
+		err = s.adminServer.ServeTLS(ln, s.config.TLS.CertFile, s.config.TLS.KeyFile)
+	} else {
+		logrus.Infof("Starting admin listener on %s", s.adminServer.Addr)
+		err = s.adminServer.Serve(ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logrus.Errorf("Admin listener error: %v", err)
+	}
 }
 
-// corsMiddleware allows the standalone web UI (separate origin/port) to call the API.
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+// Shutdown gracefully shuts down the API server and the admin listener
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stop()
+	if s.adminServer != nil {
+		if err := s.adminServer.Shutdown(ctx); err != nil {
+			logrus.Errorf("Error during admin listener shutdown: %v", err)
 		}
-		c.Next()
 	}
+	return s.server.Shutdown(ctx)
 }
 
 func (s *Server) metricsMiddleware() gin.HandlerFunc {
@@ -231,12 +555,13 @@ func (s *Server) urlDecodeMiddleware() gin.HandlerFunc {
 
 // healthCheck handles health check requests
 // @Summary Health check
-// @Description Check if the service is healthy
+// @Description Liveness: the process is up. Also served as /livez.
 // @Tags health
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Router /health [get]
+// @Router /livez [get]
 func (s *Server) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
@@ -245,73 +570,59 @@ func (s *Server) healthCheck(c *gin.Context) {
 	})
 }
 
-// triggerSync is kept as a no-op for compatibility; agents perform sync.
-// @Summary Trigger synchronization
-// @Description Manually trigger synchronization. Agents perform the actual sync; this endpoint is a no-op in the split architecture.
-// @Tags sync
-// @Accept json
+// readinessCheck reports whether the API can serve requests
+// @Summary Readiness probe
+// @Description Returns 200 when the API is connected to NATS, 503 otherwise. While maintenance mode is active the status is "maintenance" (still 200). Use /livez for liveness.
+// @Tags health
 // @Produce json
 // @Success 200 {object} map[string]interface{}
-// @Router /api/v1/sync [post]
-func (s *Server) triggerSync(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Agents continuously sync from NATS; this endpoint is a no-op.",
-		"timestamp": time.Now().UTC(),
+// @Failure 503 {object} map[string]interface{}
+// @Router /readyz [get]
+func (s *Server) readinessCheck(c *gin.Context) {
+	checks := gin.H{"nats": "ok"}
+	status, code := "ready", http.StatusOK
+	if !s.natsClient.Connected() {
+		checks["nats"] = "disconnected"
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	// Maintenance only freezes the agents; the API keeps serving writes.
+	maintenance := false
+	if snapshot := s.stats.get(); snapshot != nil && snapshot.Maintenance != nil {
+		maintenance = snapshot.Maintenance.Enabled
+	}
+	if maintenance && code == http.StatusOK {
+		status = "maintenance"
+	}
+	c.JSON(code, gin.H{
+		"status":      status,
+		"service":     "router-sync-api",
+		"checks":      checks,
+		"maintenance": maintenance,
+		"timestamp":   time.Now().UTC(),
 	})
 }
 
-// getStats returns aggregated service statistics
-// @Summary Get service statistics
-// @Description Get statistics about providers, policies, routers, and the API itself.
-// @Tags stats
-// @Accept json
+// whoami returns the authenticated caller
+// @Summary Current identity
+// @Description Return the subject and role of the bearer token used for this request. Reports auth_enabled=false when authentication is off.
+// @Tags auth
 // @Produce json
+// @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
-// @Router /api/v1/stats [get]
-func (s *Server) getStats(c *gin.Context) {
-	providers, _ := s.natsClient.ListProviders()
-	policies, _ := s.natsClient.ListPolicies()
-	states, _ := s.natsClient.ListRouterStates()
-
-	providersCount := len(providers)
-	policiesCount := len(policies)
-
-	policiesPerProvider := make(map[string]int)
-	for _, p := range policies {
-		policiesPerProvider[p.ProviderID]++
-	}
-
-	routerInfos := make([]gin.H, 0, len(states))
-	now := time.Now().UTC()
-	for _, st := range states {
-		age := now.Sub(st.LastSeen).Seconds()
-		s.stateAgeSeconds.WithLabelValues(st.Hostname).Set(age)
-		routerInfos = append(routerInfos, gin.H{
-			"hostname":      st.Hostname,
-			"agent_version": st.AgentVersion,
-			"log_level":     st.LogLevel,
-			"last_seen":     st.LastSeen,
-			"age_seconds":   age,
-		})
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/whoami [get]
+// @Router /api/v2/whoami [get]
+func (s *Server) whoami(c *gin.Context) {
+	identity := identityFrom(c)
+	if identity == nil {
+		c.JSON(http.StatusOK, gin.H{"auth_enabled": false})
+		return
 	}
-
-	s.providersTotal.Set(float64(providersCount))
-	s.policiesTotal.Set(float64(policiesCount))
-	s.routersKnown.Set(float64(len(states)))
-
-	stats := gin.H{
-		"sync": gin.H{
-			"providers_count":       providersCount,
-			"policies_count":        policiesCount,
-			"policies_per_provider": policiesPerProvider,
-		},
-		"routers":    routerInfos,
-		"log_level":  logging.GetLevelName(),
-		"timestamp":  time.Now().UTC(),
-		"version":    s.version,
-		"build_time": s.buildTime,
-		"git_commit": s.gitCommit,
-	}
-
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, gin.H{
+		"auth_enabled": true,
+		"subject":      identity.Subject,
+		"issuer":       identity.Issuer,
+		"role":         identity.Role,
+		"tenant":       identity.Tenant,
+	})
 }
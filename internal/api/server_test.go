@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net"
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenFailsOnBusyAddress(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	full := &config.Config{API: config.APIConfig{Address: busy.Addr().String(), AdminAddress: "127.0.0.1:0"}}
+	server, err := NewServer(full, new(MockNATSClient), "test", "", "")
+	require.NoError(t, err)
+	defer server.stop()
+
+	assert.Error(t, server.Listen())
+	assert.Nil(t, server.adminListener, "the admin address is released again")
+
+	full.API.Address = "127.0.0.1:0"
+	server, err = NewServer(full, new(MockNATSClient), "test", "", "")
+	require.NoError(t, err)
+	defer server.stop()
+	require.NoError(t, server.Listen())
+	defer server.listener.Close()
+	defer server.adminListener.Close()
+	require.NoError(t, server.Listen(), "listening twice keeps the bound addresses")
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultStatsInterval applies when the server was built without a config
+// default (tests construct Server literals).
+const defaultStatsInterval = 15 * time.Second
+
+// StatsResponse is returned by GET /api/v1/stats. Maintenance is the global
+// maintenance switch (enabled=false when off); Usage is each provider's
+// traffic in its current billing period.
+type StatsResponse struct {
+	Sync        SyncStats           `json:"sync"`
+	Routers     []RouterStats       `json:"routers"`
+	Usage       []UsageStats        `json:"usage"`
+	Maintenance *models.Maintenance `json:"maintenance"`
+	LogLevel    string              `json:"log_level"`
+	Timestamp   time.Time           `json:"timestamp"`
+	ComputedAt  time.Time           `json:"computed_at"`
+	Version     string              `json:"version"`
+	BuildTime   string              `json:"build_time"`
+	GitCommit   string              `json:"git_commit"`
+}
+
+// SyncStats summarizes the desired configuration stored in NATS.
+type SyncStats struct {
+	ProvidersCount       int            `json:"providers_count"`
+	PoliciesCount        int            `json:"policies_count"`
+	EnabledPoliciesCount int            `json:"enabled_policies_count"`
+	GroupsCount          int            `json:"groups_count"`
+	PoliciesPerProvider  map[string]int `json:"policies_per_provider"`
+}
+
+// RouterStats summarizes one router's latest heartbeat. The kernel counts
+// come from the state the agent collected, not from the API host.
+type RouterStats struct {
+	Hostname     string    `json:"hostname"`
+	AgentVersion string    `json:"agent_version"`
+	LogLevel     string    `json:"log_level"`
+	LastSeen     time.Time `json:"last_seen"`
+	AgeSeconds   float64   `json:"age_seconds"`
+	Interfaces   int       `json:"interfaces"`
+	Tables       int       `json:"tables"`
+	Routes       int       `json:"routes"`
+	ManagedRules int       `json:"managed_rules"`
+	Maintenance  bool      `json:"maintenance"`
+}
+
+// statsCache holds the last computed stats; handlers never hit NATS directly.
+type statsCache struct {
+	mu       sync.RWMutex
+	snapshot *StatsResponse
+}
+
+func (sc *statsCache) get() *StatsResponse {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.snapshot
+}
+
+func (sc *statsCache) set(snapshot *StatsResponse) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.snapshot = snapshot
+}
+
+// getStats returns aggregated service statistics
+// @Summary Get service statistics
+// @Description Statistics about providers, policies, groups, routers and provider data usage. Values are recomputed every api.stats_interval (see computed_at); age_seconds is relative to the request time.
+// @Tags stats
+// @Produce json
+// @Success 200 {object} StatsResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/stats [get]
+// @Router /api/v2/stats [get]
+func (s *Server) getStats(c *gin.Context) {
+	snapshot := s.stats.get()
+	if snapshot == nil {
+		// First request before the loop's initial refresh finished.
+		if err := s.refreshStats(); err != nil {
+			respondError(c, http.StatusServiceUnavailable, "Statistics not available yet", err.Error())
+			return
+		}
+		snapshot = s.stats.get()
+	}
+
+	now := time.Now().UTC()
+	resp := *snapshot
+	resp.Routers = make([]RouterStats, len(snapshot.Routers))
+	for i, r := range snapshot.Routers {
+		r.AgeSeconds = now.Sub(r.LastSeen).Seconds()
+		resp.Routers[i] = r
+	}
+	resp.LogLevel = logging.GetLevelName()
+	resp.Timestamp = now
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// runStatsLoop refreshes the stats cache and inventory gauges until ctx is
+// done, so /metrics is current even when nobody calls /stats.
+func (s *Server) runStatsLoop(ctx context.Context) {
+	interval := s.config.StatsInterval
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.refreshStats(); err != nil {
+			logrus.Warnf("Failed to refresh stats: %v", err)
+		}
+		s.refreshFleetGauge()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshStats recomputes the stats snapshot and gauges. On error the
+// previous snapshot is kept.
+func (s *Server) refreshStats() error {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		return fmt.Errorf("failed to list providers: %w", err)
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return fmt.Errorf("failed to list policies: %w", err)
+	}
+	groups, err := s.natsClient.ListGroups()
+	if err != nil {
+		return fmt.Errorf("failed to list groups: %w", err)
+	}
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		return fmt.Errorf("failed to list router states: %w", err)
+	}
+	maintenance, err := s.natsClient.GetMaintenance()
+	if err != nil {
+		return fmt.Errorf("failed to get maintenance state: %w", err)
+	}
+
+	now := time.Now().UTC()
+	snapshot := computeStats(providers, policies, groups, states, now)
+	snapshot.Usage = s.refreshUsage(providers, policies, states, now)
+	snapshot.Maintenance = maintenance
+	snapshot.Version = s.version
+	snapshot.BuildTime = s.buildTime
+	snapshot.GitCommit = s.gitCommit
+	s.stats.set(snapshot)
+
+	s.providersTotal.Set(float64(snapshot.Sync.ProvidersCount))
+	s.policiesTotal.Set(float64(snapshot.Sync.PoliciesCount))
+	s.routersKnown.Set(float64(len(snapshot.Routers)))
+	// Reset drops routers whose state expired from the bucket.
+	s.stateAgeSeconds.Reset()
+	for _, r := range snapshot.Routers {
+		s.stateAgeSeconds.WithLabelValues(r.Hostname).Set(r.AgeSeconds)
+	}
+	s.setLabelGauges(policies)
+	return nil
+}
+
+// computeStats builds a stats snapshot from the stored configuration and
+// router heartbeats, with router ages relative to now.
+func computeStats(providers []*models.InternetProvider, policies []*models.RoutingPolicy, groups []*models.PolicyGroup, states []*models.RouterState, now time.Time) *StatsResponse {
+	snapshot := &StatsResponse{
+		Sync: SyncStats{
+			ProvidersCount:      len(providers),
+			PoliciesCount:       len(policies),
+			GroupsCount:         len(groups),
+			PoliciesPerProvider: make(map[string]int),
+		},
+		Routers:    make([]RouterStats, 0, len(states)),
+		Timestamp:  now,
+		ComputedAt: now,
+	}
+
+	for _, p := range policies {
+		snapshot.Sync.PoliciesPerProvider[p.ProviderID]++
+		if p.Enabled {
+			snapshot.Sync.EnabledPoliciesCount++
+		}
+	}
+
+	for _, st := range states {
+		r := RouterStats{
+			Hostname:     st.Hostname,
+			AgentVersion: st.AgentVersion,
+			LogLevel:     st.LogLevel,
+			LastSeen:     st.LastSeen,
+			AgeSeconds:   now.Sub(st.LastSeen).Seconds(),
+			Interfaces:   len(st.Interfaces),
+			Tables:       len(st.Tables),
+			Maintenance:  st.Maintenance,
+		}
+		for _, t := range st.Tables {
+			r.Routes += len(t.Routes)
+		}
+		for _, rule := range st.Rules {
+			if models.IsManagedPriority(rule.Priority) {
+				r.ManagedRules++
+			}
+		}
+		snapshot.Routers = append(snapshot.Routers, r)
+	}
+	sort.Slice(snapshot.Routers, func(i, j int) bool { return snapshot.Routers[i].Hostname < snapshot.Routers[j].Hostname })
+
+	return snapshot
+}
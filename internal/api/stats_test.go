@@ -0,0 +1,91 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	providers := []*models.InternetProvider{{ID: "Telecom"}, {ID: "Starlink"}}
+	policies := []*models.RoutingPolicy{
+		{ID: "192.168.2.25", ProviderID: "Telecom", Enabled: true},
+		{ID: "192.168.2.26", ProviderID: "Telecom"},
+		{ID: "192.168.3.0/24", ProviderID: "Starlink", Enabled: true},
+	}
+	groups := []*models.PolicyGroup{{ID: "IoT"}}
+	states := []*models.RouterState{
+		{
+			Hostname:   "r2",
+			LastSeen:   now.Add(-10 * time.Second),
+			Interfaces: []models.Interface{{Name: "lo"}, {Name: "enp1s0"}},
+			Tables: []models.RoutingTable{
+				{ID: 254, Routes: []models.Route{{Dst: "default"}, {Dst: "192.168.2.0/24"}}},
+				{ID: 99, Routes: []models.Route{{Dst: "default"}}},
+			},
+			Rules: []models.IPRule{{Priority: 0}, {Priority: 10}, {Priority: 2000}, {Priority: 2008}, {Priority: 32766}},
+		},
+		{Hostname: "r1", LastSeen: now},
+	}
+
+	got := computeStats(providers, policies, groups, states, now)
+
+	assert.Equal(t, SyncStats{
+		ProvidersCount:       2,
+		PoliciesCount:        3,
+		EnabledPoliciesCount: 2,
+		GroupsCount:          1,
+		PoliciesPerProvider:  map[string]int{"Telecom": 2, "Starlink": 1},
+	}, got.Sync)
+	if assert.Len(t, got.Routers, 2) {
+		assert.Equal(t, "r1", got.Routers[0].Hostname)
+		assert.Equal(t, RouterStats{
+			Hostname:     "r2",
+			LastSeen:     now.Add(-10 * time.Second),
+			AgeSeconds:   10,
+			Interfaces:   2,
+			Tables:       2,
+			Routes:       3,
+			ManagedRules: 2,
+		}, got.Routers[1])
+	}
+	assert.Equal(t, now, got.ComputedAt)
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	got := computeStats(nil, nil, nil, nil, time.Now())
+	assert.NotNil(t, got.Routers)
+	assert.NotNil(t, got.Sync.PoliciesPerProvider)
+}
+
+func TestAccumulateUsage(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	p := &models.InternetProvider{
+		ID:          "Starlink",
+		Interfaces:  map[string]string{"r1": "eth1", "r2": "eth2"},
+		GatewayV6:   "fe80::1",
+		InterfaceV6: "ppp0",
+		CapResetDay: 15,
+	}
+	heartbeat := func(rx uint64) []*models.RouterState {
+		return []*models.RouterState{
+			{Hostname: "r1", Interfaces: []models.Interface{{Name: "eth0", RxBytes: 10 * rx}, {Name: "eth1", RxBytes: rx, TxBytes: rx / 10}, {Name: "ppp0", RxBytes: rx}}},
+			{Hostname: "r2", Interfaces: []models.Interface{{Name: "eth2", RxBytes: rx}}},
+		}
+	}
+
+	usage := &models.ProviderUsage{}
+	accumulateUsage(usage, p, heartbeat(1000), now)
+	accumulateUsage(usage, p, heartbeat(3000), now)
+
+	assert.Equal(t, "Starlink", usage.ProviderID)
+	assert.Equal(t, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), usage.PeriodStart)
+	assert.Equal(t, uint64(6000), usage.RxBytes) // eth1, ppp0 and r2's eth2; eth0 belongs to another provider
+	assert.Equal(t, uint64(200), usage.TxBytes)
+	assert.Len(t, usage.Counters, 3)
+}
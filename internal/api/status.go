@@ -0,0 +1,135 @@
+package api
+
+import (
+	"time"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// policyStatus builds policy's status from the router heartbeats. providers
+// resolves the policy's provider and the table its installed rule points at.
+func policyStatus(policy *models.RoutingPolicy, providers []*models.InternetProvider, states []*models.RouterState) *models.PolicyStatus {
+	status := &models.PolicyStatus{Routers: make(map[string]string, len(states))}
+	byTable := make(map[int]string, len(providers))
+	var provider *models.InternetProvider
+	for _, p := range providers {
+		byTable[p.TableID] = p.ID
+		if p.ID == policy.ProviderID {
+			provider = p
+		}
+	}
+
+	status.Applied = len(states) > 0 && provider != nil
+	var lastErrorAt time.Time
+	for _, st := range states {
+		routerStatus := diff.StatusMissing
+		if provider != nil {
+			routerStatus = diff.PolicyStatus(st, policy, provider)
+		}
+		status.Routers[st.Hostname] = routerStatus
+		if routerStatus != diff.StatusApplied && routerStatus != diff.StatusFailedOver && routerStatus != diff.StatusRemoved {
+			status.Applied = false
+		} else if !st.AppliedAt.IsZero() && (status.LastAppliedAt == nil || st.AppliedAt.After(*status.LastAppliedAt)) {
+			at := st.AppliedAt
+			status.LastAppliedAt = &at
+		}
+		if msg, ok := st.PolicyErrors[policy.ID]; ok && !st.LastSeen.Before(lastErrorAt) {
+			status.LastError = msg
+			lastErrorAt = st.LastSeen
+		}
+		if table, ok := diff.RuleTable(st, policy); ok && status.CurrentProviderID == "" {
+			status.CurrentProviderID = byTable[table]
+		}
+		if t, ok := st.PolicyTraffic[policy.ID]; ok {
+			if status.Traffic == nil {
+				status.Traffic = &models.PolicyTraffic{}
+			}
+			status.Traffic.Add(t)
+		}
+	}
+	if policy.Schedule != nil {
+		now := time.Now()
+		inSchedule := policy.Schedule.ActiveAt(now)
+		status.InSchedule = &inSchedule
+		if next, ok := policy.Schedule.NextActivation(now); ok {
+			status.NextActivation = &next
+		}
+	}
+	return status
+}
+
+// providerStatus builds provider's status from the router heartbeats of the
+// routers it has an interface on.
+func providerStatus(provider *models.InternetProvider, states []*models.RouterState) *models.ProviderStatus {
+	status := &models.ProviderStatus{Routers: make(map[string]string, len(states))}
+	var lastErrorAt time.Time
+	for _, st := range states {
+		if !provider.HasInterfaceForHost(st.Hostname) {
+			continue
+		}
+		routerStatus := diff.ProviderStatus(st, provider)
+		status.Routers[st.Hostname] = routerStatus
+		if up, ok := st.ProviderHealth[provider.ID]; ok {
+			if status.Health == nil {
+				status.Health = make(map[string]string)
+			}
+			status.Health[st.Hostname] = healthString(up)
+		}
+		if routerStatus == diff.StatusApplied && !st.AppliedAt.IsZero() && (status.LastAppliedAt == nil || st.AppliedAt.After(*status.LastAppliedAt)) {
+			at := st.AppliedAt
+			status.LastAppliedAt = &at
+		}
+		if msg, ok := st.ProviderErrors[provider.ID]; ok && !st.LastSeen.Before(lastErrorAt) {
+			status.LastError = msg
+			lastErrorAt = st.LastSeen
+		}
+	}
+	status.Applied = len(status.Routers) > 0
+	for _, routerStatus := range status.Routers {
+		if routerStatus != diff.StatusApplied {
+			status.Applied = false
+		}
+	}
+	return status
+}
+
+func healthString(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
+// attachPolicyStatus sets the status of each policy. Without router states
+// or providers the statuses are left out rather than failing the read.
+func (s *Server) attachPolicyStatus(policies ...*models.RoutingPolicy) {
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		logrus.Warnf("Failed to list router states for policy status: %v", err)
+		return
+	}
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		logrus.Warnf("Failed to list providers for policy status: %v", err)
+		return
+	}
+	for _, p := range policies {
+		p.Status = policyStatus(p, providers, states)
+	}
+}
+
+// attachProviderStatus sets the status of each provider, like
+// attachPolicyStatus.
+func (s *Server) attachProviderStatus(providers ...*models.InternetProvider) {
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		logrus.Warnf("Failed to list router states for provider status: %v", err)
+		return
+	}
+	for _, p := range providers {
+		p.Status = providerStatus(p, states)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyStatus(t *testing.T) {
+	applied := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	providers := []*models.InternetProvider{
+		{ID: "Telecom", TableID: 100, Gateway: "10.0.0.1", Interface: "eth0"},
+		{ID: "Starlink", TableID: 200, Gateway: "10.0.1.1", Interface: "eth1"},
+	}
+	policy := &models.RoutingPolicy{ID: "kids", SourceIP: "192.168.2.25", ProviderID: "Telecom", Enabled: true}
+	states := []*models.RouterState{
+		{
+			Hostname:  "r1",
+			AppliedAt: applied,
+			Rules:     []models.IPRule{{Priority: 2000, From: "192.168.2.25", Table: 100}},
+			Tables:    []models.RoutingTable{{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}}},
+		},
+		{
+			// failed over to Starlink
+			Hostname:     "r2",
+			AppliedAt:    applied.Add(time.Minute),
+			Rules:        []models.IPRule{{Priority: 2000, From: "192.168.2.25", Table: 200}},
+			PolicyErrors: map[string]string{"kids": "ip rule add failed"},
+		},
+	}
+
+	got := policyStatus(policy, providers, states)
+
+	assert.False(t, got.Applied)
+	assert.Equal(t, map[string]string{"r1": diff.StatusApplied, "r2": diff.StatusMismatch}, got.Routers)
+	assert.Equal(t, "ip rule add failed", got.LastError)
+	if assert.NotNil(t, got.LastAppliedAt) {
+		assert.Equal(t, applied, *got.LastAppliedAt)
+	}
+
+	got = policyStatus(policy, providers, states[1:])
+	assert.Equal(t, "Starlink", got.CurrentProviderID)
+
+	pstatus := providerStatus(providers[0], states)
+	assert.False(t, pstatus.Applied)
+	assert.Equal(t, map[string]string{"r1": diff.StatusApplied, "r2": diff.StatusMissing}, pstatus.Routers)
+}
+
+func TestPolicyStatusFailover(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "Telecom", TableID: 100, Gateway: "10.0.0.1", Interface: "eth0"},
+		{ID: "Starlink", TableID: 200, Gateway: "10.0.1.1", Interface: "eth1"},
+	}
+	policy := &models.RoutingPolicy{ID: "kids", SourceIP: "192.168.2.25", ProviderID: "Telecom", Enabled: true}
+	states := []*models.RouterState{
+		{
+			Hostname:       "r1",
+			Rules:          []models.IPRule{{Priority: 2000, From: "192.168.2.25", Table: 200}},
+			Tables:         []models.RoutingTable{{ID: 200, Routes: []models.Route{{Dst: "default", Gateway: "10.0.1.1"}}}},
+			ProviderHealth: map[string]bool{"Telecom": false, "Starlink": true},
+			Failover:       map[string]string{"kids": "Starlink"},
+		},
+		{
+			Hostname:       "r2",
+			ProviderHealth: map[string]bool{"Telecom": false},
+			Failover:       map[string]string{"kids": ""},
+		},
+	}
+
+	got := policyStatus(policy, providers, states[:1])
+	assert.True(t, got.Applied)
+	assert.Equal(t, map[string]string{"r1": diff.StatusFailedOver}, got.Routers)
+	assert.Equal(t, "Starlink", got.CurrentProviderID)
+
+	got = policyStatus(policy, providers, states)
+	assert.False(t, got.Applied)
+	assert.Equal(t, diff.StatusProviderDown, got.Routers["r2"])
+
+	pstatus := providerStatus(providers[0], states)
+	assert.Equal(t, map[string]string{"r1": "down", "r2": "down"}, pstatus.Health)
+	assert.Equal(t, map[string]string{"r1": "up"}, providerStatus(providers[1], states).Health)
+}
+
+func TestPolicyStatusSumsTraffic(t *testing.T) {
+	providers := []*models.InternetProvider{{ID: "Telecom", TableID: 100}}
+	policy := &models.RoutingPolicy{ID: "kids", SourceIP: "192.168.2.25", ProviderID: "Telecom", Enabled: true}
+	states := []*models.RouterState{
+		{Hostname: "r1", PolicyTraffic: map[string]models.PolicyTraffic{"kids": {TxBytes: 100, TxPackets: 2, RxBytes: 1000, RxPackets: 3}}},
+		{Hostname: "r2", PolicyTraffic: map[string]models.PolicyTraffic{"kids": {TxBytes: 50, TxPackets: 1}}},
+		{Hostname: "r3"},
+	}
+
+	got := policyStatus(policy, providers, states)
+	assert.Equal(t, &models.PolicyTraffic{TxBytes: 150, TxPackets: 3, RxBytes: 1000, RxPackets: 3}, got.Traffic)
+
+	// Without accounting on any router the field is left out
+	assert.Nil(t, policyStatus(policy, providers, states[2:]).Traffic)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// streamKeepAlive is how often an SSE comment is sent to keep proxies from
+	// closing idle connections.
+	streamKeepAlive = 15 * time.Second
+	// subscriberBuffer is the per-client backlog; slow clients drop events
+	// rather than blocking the hub.
+	subscriberBuffer = 64
+)
+
+// eventHub fans NATS events out to connected stream clients.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan *models.Event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan *models.Event]struct{})}
+}
+
+func (h *eventHub) subscribe() chan *models.Event {
+	ch := make(chan *models.Event, subscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan *models.Event) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *eventHub) broadcast(ev *models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			logrus.Debugf("Dropping %s event for slow stream client", ev.Type)
+		}
+	}
+}
+
+// runEventHub relays NATS events into the hub until ctx is done, resubscribing on error.
+func (s *Server) runEventHub(ctx context.Context) {
+	for {
+		if err := s.natsClient.SubscribeEvents(ctx, s.events.broadcast); err != nil {
+			logrus.Warnf("Event subscription failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// streamEvents streams real-time events as server-sent events
+// @Summary Real-time event stream
+// @Description Server-sent events stream of agent events (policy.applied, policy.removed, provider.health, sync.completed, maintenance) and API configuration changes (config.changed). Filter with types (comma-separated) and router. Browsers using EventSource may pass the bearer token as access_token.
+// @Tags events
+// @Produce text/event-stream
+// @Param types query string false "Comma-separated event types"
+// @Param router query string false "Only events from this router hostname"
+// @Success 200 {object} models.Event
+// @Router /api/v1/stream [get]
+// @Router /api/v2/stream [get]
+func (s *Server) streamEvents(c *gin.Context) {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	router := c.Query("router")
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case ev := <-ch:
+			if len(types) > 0 && !types[ev.Type] {
+				continue
+			}
+			if router != "" && ev.Hostname != router {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncPlanTimeout covers listing the store and planning a full sync.
+const syncPlanTimeout = 30 * time.Second
+
+// syncRunTimeout covers a full sync, which may flush conntrack and reload
+// nftables on a busy router.
+const syncRunTimeout = 2 * time.Minute
+
+// SyncPlanResult is one router's answer to a dry-run sync.
+type SyncPlanResult struct {
+	Hostname string           `json:"hostname"`
+	Plan     *models.SyncPlan `json:"plan,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// SyncRunResult is one router's answer to a sync: what the full sync did,
+// or, with async, that it started. Error is set when the router could not
+// be asked; the sync's own errors are in Result.
+type SyncRunResult struct {
+	Hostname string             `json:"hostname"`
+	Result   *models.SyncResult `json:"result,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// SyncStatus is one router's sync status from its last heartbeat: the
+// result of its last full sync, and the current error of each failing
+// policy and provider, which watcher updates since that sync may have
+// changed.
+type SyncStatus struct {
+	Hostname       string             `json:"hostname"`
+	LastSeen       time.Time          `json:"last_seen"`
+	Online         bool               `json:"online"`
+	LastSync       *models.SyncResult `json:"last_sync,omitempty"`
+	PolicyErrors   map[string]string  `json:"policy_errors,omitempty"`
+	ProviderErrors map[string]string  `json:"provider_errors,omitempty"`
+}
+
+func syncStatus(st *models.RouterState, now time.Time) SyncStatus {
+	return SyncStatus{
+		Hostname:       st.Hostname,
+		LastSeen:       st.LastSeen,
+		Online:         now.Sub(st.LastSeen).Seconds() < 30,
+		LastSync:       st.LastSync,
+		PolicyErrors:   st.PolicyErrors,
+		ProviderErrors: st.ProviderErrors,
+	}
+}
+
+// getSyncStatus returns the last full sync of each router
+// @Summary Get sync status
+// @Description Return, per router (router=HOST for one), when its last full sync ran and what triggered it, how long it took, whether it succeeded, the rules and routes it added, removed and skipped, and its errors, along with the current error of each failing policy and provider. Routers report this in their heartbeat, so a sync shows up within a heartbeat interval of finishing; last_sync is missing until an agent's first sync finishes.
+// @Tags sync
+// @Produce json
+// @Param router query string false "Status of this router only"
+// @Success 200 {array} SyncStatus
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/sync/status [get]
+// @Router /api/v2/sync/status [get]
+func (s *Server) getSyncStatus(c *gin.Context) {
+	now := time.Now().UTC()
+	if hostname := c.Query("router"); hostname != "" {
+		state, err := s.natsClient.GetRouterState(hostname)
+		if err != nil {
+			respondError(c, http.StatusNotFound, "Router not found", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, []SyncStatus{syncStatus(state, now)})
+		return
+	}
+
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+	out := make([]SyncStatus, 0, len(states))
+	for _, st := range states {
+		out = append(out, syncStatus(st, now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	c.JSON(http.StatusOK, out)
+}
+
+// triggerSync runs, or plans, a full sync on the agents
+// @Summary Trigger synchronization
+// @Description Run a full reconciliation on one router (router=HOST) or every online router and return, per router, the rules and routes it added and removed, its errors and its duration. The agents sync continuously anyway; this forces a run now, e.g. after fixing a router by hand. With async=true each agent answers as soon as its sync is queued (202), with the sync_id to find it in the journal and logs. With dry_run=true nothing is changed: each router returns the ip rule, route and conntrack changes a full sync would make, as journal entries.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param dry_run query bool false "Return the planned changes instead of syncing"
+// @Param async query bool false "Answer once the syncs are queued"
+// @Param router query string false "Sync this router only"
+// @Success 200 {array} SyncRunResult
+// @Success 202 {array} SyncRunResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/sync [post]
+// @Router /api/v2/sync [post]
+func (s *Server) triggerSync(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid dry_run", err.Error())
+		return
+	}
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid async", err.Error())
+		return
+	}
+
+	hosts, err := s.targetRouters(c.Query("router"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list router states", err.Error())
+		return
+	}
+
+	cmd := &models.AgentCommand{Command: models.CommandSyncRun, Args: map[string]string{"request_id": c.GetString(requestIDKey)}}
+	timeout := syncRunTimeout
+	if dryRun {
+		cmd = &models.AgentCommand{Command: models.CommandSyncPlan}
+		timeout = syncPlanTimeout
+	} else if async {
+		cmd.Args["async"] = "true"
+	}
+	if identity := identityFrom(c); identity != nil {
+		cmd.RequestedBy = identity.Subject
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	replies := make([]*models.AgentCommandResult, len(hosts))
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			replies[i], errs[i] = s.natsClient.SendAgentCommand(ctx, host, cmd)
+		}(i, host)
+	}
+	wg.Wait()
+
+	if dryRun {
+		results := make([]SyncPlanResult, len(hosts))
+		for i, host := range hosts {
+			results[i] = SyncPlanResult{Hostname: host}
+			results[i].Error = decodeReply(replies[i], errs[i], &results[i].Plan)
+		}
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	results := make([]SyncRunResult, len(hosts))
+	for i, host := range hosts {
+		results[i] = SyncRunResult{Hostname: host}
+		results[i].Error = decodeReply(replies[i], errs[i], &results[i].Result)
+	}
+	status := http.StatusOK
+	if async {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, results)
+}
+
+// decodeReply decodes an agent's reply into out and returns why it could
+// not, or "".
+func decodeReply(reply *models.AgentCommandResult, err error, out interface{}) string {
+	if err != nil {
+		return err.Error()
+	}
+	if !reply.OK {
+		return reply.Error
+	}
+	if err := json.Unmarshal(reply.Data, out); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriggerSync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	now := time.Now().UTC()
+	mockNATS.On("ListRouterStates").Return([]*models.RouterState{
+		{Hostname: "r1", LastSeen: now},
+		{Hostname: "r2", LastSeen: now},
+		{Hostname: "gone", LastSeen: now.Add(-time.Hour)},
+	}, nil)
+	data, _ := json.Marshal(&models.SyncResult{SyncID: "5f2b8c1d9e0a4b7c", Trigger: "request", Success: true, RulesAdded: 2})
+	isRun := mock.MatchedBy(func(cmd *models.AgentCommand) bool {
+		return cmd.Command == models.CommandSyncRun && cmd.Args["async"] == ""
+	})
+	mockNATS.On("SendAgentCommand", mock.Anything, "r1", isRun).Return(&models.AgentCommandResult{OK: true, Data: data}, nil)
+	mockNATS.On("SendAgentCommand", mock.Anything, "r2", isRun).Return(nil, errors.New("nats: timeout"))
+
+	router := gin.New()
+	router.POST("/api/v1/sync", server.triggerSync)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var results []SyncRunResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, "r1", results[0].Hostname)
+	require.NotNil(t, results[0].Result)
+	assert.Equal(t, 2, results[0].Result.RulesAdded)
+	assert.True(t, results[0].Result.Success)
+	assert.Equal(t, "r2", results[1].Hostname)
+	assert.Nil(t, results[1].Result)
+	assert.Equal(t, "nats: timeout", results[1].Error)
+}
+
+func TestTriggerSyncAsync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	data, _ := json.Marshal(&models.SyncResult{SyncID: "5f2b8c1d9e0a4b7c", Trigger: "request", Running: true})
+	mockNATS.On("SendAgentCommand", mock.Anything, "r1", mock.MatchedBy(func(cmd *models.AgentCommand) bool {
+		return cmd.Command == models.CommandSyncRun && cmd.Args["async"] == "true"
+	})).Return(&models.AgentCommandResult{OK: true, Data: data}, nil)
+
+	router := gin.New()
+	router.POST("/api/v1/sync", server.triggerSync)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync?router=r1&async=true", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var results []SyncRunResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	require.NotNil(t, results[0].Result)
+	assert.True(t, results[0].Result.Running)
+	assert.Equal(t, "5f2b8c1d9e0a4b7c", results[0].Result.SyncID)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/v1/sync?async=maybe", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetSyncStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	now := time.Now().UTC()
+	last := &models.SyncResult{SyncID: "5f2b8c1d9e0a4b7c", Trigger: "interval", DurationMs: 42, RulesAdded: 1, RulesSkipped: 2,
+		PolicyErrors: map[string]string{"guests": "file exists"}}
+	mockNATS.On("ListRouterStates").Return([]*models.RouterState{
+		{Hostname: "r2", LastSeen: now.Add(-time.Hour)},
+		{Hostname: "r1", LastSeen: now, LastSync: last, PolicyErrors: map[string]string{"guests": "file exists"}},
+	}, nil)
+	mockNATS.On("GetRouterState", "r1").Return(&models.RouterState{Hostname: "r1", LastSeen: now, LastSync: last}, nil)
+	mockNATS.On("GetRouterState", "nope").Return(nil, errors.New("nats: key not found"))
+
+	router := gin.New()
+	router.GET("/api/v1/sync/status", server.getSyncStatus)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/status", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var statuses []SyncStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "r1", statuses[0].Hostname)
+	assert.True(t, statuses[0].Online)
+	require.NotNil(t, statuses[0].LastSync)
+	assert.Equal(t, 2, statuses[0].LastSync.RulesSkipped)
+	assert.Equal(t, "file exists", statuses[0].LastSync.PolicyErrors["guests"])
+	assert.Equal(t, "file exists", statuses[0].PolicyErrors["guests"])
+	assert.Equal(t, "r2", statuses[1].Hostname)
+	assert.False(t, statuses[1].Online)
+	assert.Nil(t, statuses[1].LastSync)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/sync/status?router=r1", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "5f2b8c1d9e0a4b7c", statuses[0].LastSync.SyncID)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/sync/status?router=nope", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
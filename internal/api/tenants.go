@@ -0,0 +1,370 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTenantRequest represents a request to create a tenant. Zero range
+// bounds mean the router's whole managed range.
+type CreateTenantRequest struct {
+	ID          string   `json:"id" binding:"required" example:"acme"`
+	Name        string   `json:"name" binding:"required" example:"ACME Hosting"`
+	Description string   `json:"description" example:"Rack 12"`
+	Sources     []string `json:"sources" binding:"required" example:"203.0.113.0/26"`
+	PriorityMin int      `json:"priority_min" example:"2010"`
+	PriorityMax int      `json:"priority_max" example:"2019"`
+	TableMin    int      `json:"table_min" example:"1000"`
+	TableMax    int      `json:"table_max" example:"1099"`
+	ProviderIDs []string `json:"provider_ids" example:"Telecom"`
+}
+
+// UpdateTenantRequest replaces everything but the tenant ID. Existing
+// policies are not re-checked against the new limits.
+type UpdateTenantRequest struct {
+	Name        string   `json:"name" binding:"required" example:"ACME Hosting"`
+	Description string   `json:"description" example:"Rack 12"`
+	Sources     []string `json:"sources" binding:"required" example:"203.0.113.0/26"`
+	PriorityMin int      `json:"priority_min" example:"2010"`
+	PriorityMax int      `json:"priority_max" example:"2019"`
+	TableMin    int      `json:"table_min" example:"1000"`
+	TableMax    int      `json:"table_max" example:"1099"`
+	ProviderIDs []string `json:"provider_ids" example:"Telecom"`
+}
+
+// tenantRoutes are the routes a caller whose token names a tenant may use,
+// relative to the API version prefix. Everything else describes or changes
+// the shared infrastructure and is refused.
+var tenantRoutes = map[string]bool{
+	"GET /policies":              true,
+	"POST /policies":             true,
+	"GET /policies/:id":          true,
+	"PUT /policies/:id":          true,
+	"DELETE /policies/:id":       true,
+	"POST /policies/:id/enable":  true,
+	"POST /policies/:id/disable": true,
+	"GET /providers":             true,
+	"GET /providers/:id":         true,
+	"GET /tenants/:id":           true,
+	"GET /whoami":                true,
+}
+
+// tenantScope confines tenant callers to tenantRoutes. When auth is
+// disabled, or the token names no tenant, it is a no-op.
+func (s *Server) tenantScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if callerTenant(c) == "" {
+			c.Next()
+			return
+		}
+		path := c.FullPath()
+		for _, prefix := range []string{"/api/v1", "/api/v2"} {
+			path = strings.TrimPrefix(path, prefix)
+		}
+		if !tenantRoutes[c.Request.Method+" "+path] {
+			respondError(c, http.StatusForbidden, "Forbidden", "this operation is not available to tenant "+callerTenant(c))
+			return
+		}
+		c.Next()
+	}
+}
+
+// callerTenant returns the tenant the caller is confined to, or "".
+func callerTenant(c *gin.Context) string {
+	if identity := identityFrom(c); identity != nil {
+		return identity.Tenant
+	}
+	return ""
+}
+
+// policyVisible reports whether the caller may see and manage p.
+func policyVisible(c *gin.Context, p *models.RoutingPolicy) bool {
+	tenant := callerTenant(c)
+	return tenant == "" || p.Tenant == tenant
+}
+
+// visiblePolicies returns the policies the caller may see.
+func visiblePolicies(c *gin.Context, policies []*models.RoutingPolicy) []*models.RoutingPolicy {
+	if callerTenant(c) == "" {
+		return policies
+	}
+	out := make([]*models.RoutingPolicy, 0, len(policies))
+	for _, p := range policies {
+		if policyVisible(c, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// scopedTenant returns the caller's tenant record, or nil for global
+// callers. It writes a 403 response when the token names an unknown tenant.
+func (s *Server) scopedTenant(c *gin.Context) (*models.Tenant, bool) {
+	id := callerTenant(c)
+	if id == "" {
+		return nil, true
+	}
+	tenant, err := s.natsClient.GetTenant(id)
+	if err != nil {
+		respondError(c, http.StatusForbidden, "Forbidden", fmt.Sprintf("tenant '%s' does not exist", id))
+		return nil, false
+	}
+	return tenant, true
+}
+
+// policyTenant resolves the tenant a new policy belongs to: the caller's
+// tenant, or for global callers the one requested (none when empty). It
+// writes a 400 or 403 response when that is not possible.
+func (s *Server) policyTenant(c *gin.Context, requested string) (*models.Tenant, bool) {
+	if own := callerTenant(c); own != "" {
+		if requested != "" && requested != own {
+			respondError(c, http.StatusForbidden, "Forbidden", fmt.Sprintf("policies can only be created for tenant '%s'", own))
+			return nil, false
+		}
+		return s.scopedTenant(c)
+	}
+	if requested == "" {
+		return nil, true
+	}
+	tenant, err := s.natsClient.GetTenant(requested)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Tenant not found", fmt.Sprintf("Tenant '%s' does not exist", requested))
+		return nil, false
+	}
+	return tenant, true
+}
+
+// tenantAdmits reports whether p, including its primary and backup
+// providers, stays within tenant's limits, writing a 400 response when it
+// does not. A nil tenant admits everything.
+func (s *Server) tenantAdmits(c *gin.Context, tenant *models.Tenant, p *models.RoutingPolicy) bool {
+	if tenant == nil {
+		return true
+	}
+	if err := tenant.Admits(p); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return false
+	}
+	for _, id := range p.ProviderChain() {
+		provider, err := s.natsClient.GetProvider(id)
+		if err != nil {
+			continue // reported by the provider existence checks
+		}
+		if err := tenant.AllowsProvider(provider); err != nil {
+			respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// tenantProviders returns the providers tenant's policies may use.
+func tenantProviders(tenant *models.Tenant, providers []*models.InternetProvider) []*models.InternetProvider {
+	out := make([]*models.InternetProvider, 0, len(providers))
+	for _, p := range providers {
+		if tenant.AllowsProvider(p) == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// listTenants lists all tenants
+// @Summary List tenants
+// @Description Get all tenants sharing the routing infrastructure
+// @Tags tenants
+// @Produce json
+// @Success 200 {array} models.Tenant
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tenants [get]
+// @Router /api/v2/tenants [get]
+func (s *Server) listTenants(c *gin.Context) {
+	tenants, err := s.natsClient.ListTenants()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list tenants", err.Error())
+		return
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	c.JSON(http.StatusOK, tenants)
+}
+
+// createTenant creates a new tenant
+// @Summary Create tenant
+// @Description Create a tenant. Callers whose token carries the tenant claim (api.auth.tenant_claim) with this ID may then manage policies for sources inside sources, with rule priorities in priority_min-priority_max, through providers whose table is in table_min-table_max (and listed in provider_ids, when set).
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param tenant body CreateTenantRequest true "Tenant information"
+// @Success 201 {object} models.Tenant
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Tenant already exists"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tenants [post]
+// @Router /api/v2/tenants [post]
+func (s *Server) createTenant(c *gin.Context) {
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if existing, err := s.natsClient.GetTenant(req.ID); err == nil && existing != nil {
+		respondError(c, http.StatusConflict, "Tenant already exists", fmt.Sprintf("A tenant with ID '%s' already exists", req.ID))
+		return
+	}
+
+	now := time.Now()
+	tenant := &models.Tenant{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Sources:     req.Sources,
+		PriorityMin: req.PriorityMin,
+		PriorityMax: req.PriorityMax,
+		TableMin:    req.TableMin,
+		TableMax:    req.TableMax,
+		ProviderIDs: req.ProviderIDs,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := tenant.Validate(); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+
+	if err := s.natsClient.StoreTenant(tenant); err != nil {
+		writeStoreError(c, "Failed to create tenant", err)
+		return
+	}
+	s.recordAudit(c, models.AuditActionCreate, models.AuditEntityTenant, tenant.ID, nil, auditSnapshot(tenant))
+
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// getTenant gets a specific tenant
+// @Summary Get tenant
+// @Description Get a tenant and its limits. Tenant callers may only read their own.
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} models.Tenant
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tenants/{id} [get]
+// @Router /api/v2/tenants/{id} [get]
+func (s *Server) getTenant(c *gin.Context) {
+	id := c.Param("id")
+	if own := callerTenant(c); own != "" && own != id {
+		respondError(c, http.StatusNotFound, "Tenant not found", fmt.Sprintf("tenant '%s' does not exist", id))
+		return
+	}
+
+	tenant, err := s.natsClient.GetTenant(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Tenant not found", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, tenant)
+}
+
+// updateTenant updates an existing tenant
+// @Summary Update tenant
+// @Description Replace a tenant's name, sources and limits. Existing policies are not re-checked; the new limits apply to later changes.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param tenant body UpdateTenantRequest true "Tenant information"
+// @Success 200 {object} models.Tenant
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tenants/{id} [put]
+// @Router /api/v2/tenants/{id} [put]
+func (s *Server) updateTenant(c *gin.Context) {
+	var req UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	existing, err := s.natsClient.GetTenant(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Tenant not found", err.Error())
+		return
+	}
+	before := auditSnapshot(existing)
+
+	existing.Name = req.Name
+	existing.Description = req.Description
+	existing.Sources = req.Sources
+	existing.PriorityMin = req.PriorityMin
+	existing.PriorityMax = req.PriorityMax
+	existing.TableMin = req.TableMin
+	existing.TableMax = req.TableMax
+	existing.ProviderIDs = req.ProviderIDs
+	existing.UpdatedAt = time.Now()
+	if err := existing.Validate(); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+
+	if err := s.natsClient.StoreTenant(existing); err != nil {
+		writeStoreError(c, "Failed to update tenant", err)
+		return
+	}
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityTenant, existing.ID, before, auditSnapshot(existing))
+
+	c.JSON(http.StatusOK, existing)
+}
+
+// deleteTenant deletes a tenant
+// @Summary Delete tenant
+// @Description Delete a tenant. It must have no policies left.
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The tenant still has policies"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tenants/{id} [delete]
+// @Router /api/v2/tenants/{id} [delete]
+func (s *Server) deleteTenant(c *gin.Context) {
+	existing, err := s.natsClient.GetTenant(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Tenant not found", err.Error())
+		return
+	}
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	count := 0
+	for _, p := range policies {
+		if p.Tenant == existing.ID {
+			count++
+		}
+	}
+	if count > 0 {
+		respondError(c, http.StatusConflict, "Tenant has policies",
+			fmt.Sprintf("tenant '%s' still has %d policies; delete them first", existing.ID, count))
+		return
+	}
+
+	if err := s.natsClient.DeleteTenant(existing.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to delete tenant", err.Error())
+		return
+	}
+	s.recordAudit(c, models.AuditActionDelete, models.AuditEntityTenant, existing.ID, auditSnapshot(existing), nil)
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"router-sync/internal/auth"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// tenantRouter serves the versioned API as callers with identity would see it.
+func tenantRouter(server *Server, identity *auth.Identity) *gin.Engine {
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.Use(func(c *gin.Context) { c.Set(identityKey, identity) }, server.tenantScope())
+	server.registerRoutes(v1)
+	return router
+}
+
+func testTenant() *models.Tenant {
+	return &models.Tenant{
+		ID:          "acme",
+		Name:        "ACME",
+		Sources:     []string{"203.0.113.0/26"},
+		PriorityMin: 2010,
+		PriorityMax: 2019,
+		TableMin:    1000,
+		TableMax:    1099,
+	}
+}
+
+func TestTenantScope_RefusesSharedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := &Server{natsClient: &MockNATSClient{}}
+	router := tenantRouter(server, &auth.Identity{Subject: "alice", Role: auth.RoleAdmin, Tenant: "acme"})
+
+	for _, path := range []string{"/api/v1/routers", "/api/v1/tenants", "/api/v1/audit", "/api/v1/export"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/tenants/other", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "another tenant's record")
+}
+
+func TestListPolicies_TenantSeesOwn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{
+		{ID: "shared", SourceIP: "192.168.1.10", ProviderID: "isp1"},
+		{ID: "acme.7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", SourceIP: "203.0.113.5", ProviderID: "isp1", Tenant: "acme"},
+		{ID: "other.7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", SourceIP: "198.51.100.5", ProviderID: "isp1", Tenant: "other"},
+	}, nil)
+	mockNATS.On("ListRouterStates").Return([]*models.RouterState{}, nil)
+	mockNATS.On("ListProviders").Return([]*models.InternetProvider{}, nil)
+	router := tenantRouter(server, &auth.Identity{Subject: "alice", Role: auth.RoleViewer, Tenant: "acme"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/policies", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var policies []models.RoutingPolicy
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &policies))
+	if assert.Len(t, policies, 1) {
+		assert.Equal(t, "acme", policies[0].Tenant)
+	}
+}
+
+func TestCreatePolicy_TenantLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		contains string
+	}{
+		{"within limits", `{"name":"web","source_ip":"203.0.113.5","provider_id":"isp1","priority":2012,"enabled":true}`, http.StatusCreated, `"tenant":"acme"`},
+		{"foreign source", `{"name":"web","source_ip":"192.168.1.10","provider_id":"isp1","priority":2012}`, http.StatusBadRequest, "outside tenant acme's sources"},
+		{"derived priority", `{"name":"web","source_ip":"203.0.113.5","provider_id":"isp1"}`, http.StatusBadRequest, "rule priority 2000"},
+		{"foreign table", `{"name":"web","source_ip":"203.0.113.5","provider_id":"isp2","priority":2012}`, http.StatusBadRequest, "outside tenant acme's range 1000-1099"},
+		{"other tenant", `{"name":"web","source_ip":"203.0.113.5","provider_id":"isp1","priority":2012,"tenant":"other"}`, http.StatusForbidden, "tenant 'acme'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockNATS := &MockNATSClient{}
+			server := &Server{natsClient: mockNATS}
+			mockNATS.On("GetTenant", "acme").Return(testTenant(), nil)
+			mockNATS.On("GetProvider", "isp1").Return(&models.InternetProvider{ID: "isp1", TableID: 1001}, nil)
+			mockNATS.On("GetProvider", "isp2").Return(&models.InternetProvider{ID: "isp2", TableID: 100}, nil)
+			mockNATS.On("ListPolicies").Return([]*models.RoutingPolicy{}, nil)
+			mockNATS.On("StorePolicy", mock.AnythingOfType("*models.RoutingPolicy")).Return(nil)
+			mockNATS.On("RecordAudit", mock.Anything).Return(nil)
+			mockNATS.On("PublishEvent", mock.Anything).Return(nil)
+			router := tenantRouter(server, &auth.Identity{Subject: "alice", Role: auth.RoleOperator, Tenant: "acme"})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/policies", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tt.contains)
+			if tt.status == http.StatusCreated {
+				var policy models.RoutingPolicy
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &policy))
+				assert.True(t, strings.HasPrefix(policy.ID, "acme."), policy.ID)
+				assert.Equal(t, "acme", models.PolicyTenant(policy.ID))
+			} else {
+				mockNATS.AssertNotCalled(t, "StorePolicy", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetPolicy_OtherTenantNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockNATS := &MockNATSClient{}
+	server := &Server{natsClient: mockNATS}
+	id := "other.7c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f"
+	mockNATS.On("GetPolicy", id).Return(&models.RoutingPolicy{ID: id, SourceIP: "198.51.100.5", ProviderID: "isp1", Tenant: "other"}, nil)
+	router := tenantRouter(server, &auth.Identity{Subject: "alice", Role: auth.RoleOperator, Tenant: "acme"})
+
+	for _, method := range []string{"GET", "DELETE"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/v1/policies/"+id, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+	mockNATS.AssertNotCalled(t, "DeletePolicy", mock.Anything)
+}
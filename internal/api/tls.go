@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"router-sync/internal/config"
+)
+
+// buildTLSConfig validates the TLS section and returns the server tls.Config.
+// The certificate itself is loaded by ListenAndServeTLS in Start.
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("both cert_file and key_file must be set")
+	}
+	if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch strings.TrimSpace(cfg.MinVersion) {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported min_version %q (expected 1.2 or 1.3)", cfg.MinVersion)
+	}
+
+	if cfg.ClientCAFile == "" {
+		if cfg.ClientAuth != "" {
+			return nil, errors.New("client_auth requires client_ca_file")
+		}
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+
+	switch strings.ToLower(strings.TrimSpace(cfg.ClientAuth)) {
+	case "", "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unsupported client_auth %q (expected require or optional)", cfg.ClientAuth)
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate and its key under dir and
+// returns their paths. The certificate doubles as a client CA.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "router-sync test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeTestCert(t, dir)
+	notPEM := filepath.Join(dir, "ca.txt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name           string
+		cfg            config.TLSConfig
+		wantErr        string
+		wantMinVersion uint16
+		wantClientAuth tls.ClientAuthType
+	}{
+		{name: "server only", cfg: config.TLSConfig{CertFile: cert, KeyFile: key}, wantMinVersion: tls.VersionTLS12, wantClientAuth: tls.NoClientCert},
+		{name: "tls 1.3", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, MinVersion: "1.3"}, wantMinVersion: tls.VersionTLS13, wantClientAuth: tls.NoClientCert},
+		{name: "client CA defaults to require", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert}, wantMinVersion: tls.VersionTLS12, wantClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "require", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert, ClientAuth: "require"}, wantMinVersion: tls.VersionTLS12, wantClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "optional", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert, ClientAuth: "optional"}, wantMinVersion: tls.VersionTLS12, wantClientAuth: tls.VerifyClientCertIfGiven},
+		{name: "key file unset", cfg: config.TLSConfig{CertFile: cert}, wantErr: "both cert_file and key_file"},
+		{name: "missing key file", cfg: config.TLSConfig{CertFile: cert, KeyFile: filepath.Join(dir, "missing.key")}, wantErr: "failed to load server certificate"},
+		{name: "bad min_version", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, MinVersion: "1.1"}, wantErr: `unsupported min_version "1.1"`},
+		{name: "client_auth without CA", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientAuth: "require"}, wantErr: "client_auth requires client_ca_file"},
+		{name: "bad client_auth", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: cert, ClientAuth: "maybe"}, wantErr: `unsupported client_auth "maybe"`},
+		{name: "missing CA file", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: filepath.Join(dir, "missing.crt")}, wantErr: "failed to read client CA file"},
+		{name: "CA file without PEM", cfg: config.TLSConfig{CertFile: cert, KeyFile: key, ClientCAFile: notPEM}, wantErr: "no PEM certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTLSConfig(tt.cfg)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMinVersion, got.MinVersion)
+			assert.Equal(t, tt.wantClientAuth, got.ClientAuth)
+			if tt.cfg.ClientCAFile != "" {
+				assert.NotNil(t, got.ClientCAs)
+			}
+		})
+	}
+}
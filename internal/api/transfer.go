@@ -0,0 +1,498 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// maxImportBytes caps the size of an import document.
+const maxImportBytes = 8 << 20
+
+// Import modes.
+const (
+	importModeMerge   = "merge"
+	importModeReplace = "replace"
+)
+
+// ImportChanges lists record IDs grouped by the action an import takes on them.
+type ImportChanges struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// ImportResult is returned by POST /api/v1/import.
+type ImportResult struct {
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Providers ImportChanges `json:"providers"`
+	Policies  ImportChanges `json:"policies"`
+	Groups    ImportChanges `json:"groups"`
+	Errors    []string      `json:"errors,omitempty"`
+	// Warnings are imported anyway, see models.PriorityConflicts.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// exportConfig returns all providers, policies and groups as one document
+// @Summary Export configuration
+// @Description Export every provider, policy and policy group as a single document. Use format=yaml (or Accept: application/yaml) for YAML; JSON is the default.
+// @Tags config
+// @Produce json
+// @Produce application/yaml
+// @Param format query string false "json or yaml"
+// @Success 200 {object} models.ConfigDocument
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/export [get]
+// @Router /api/v2/export [get]
+func (s *Server) exportConfig(c *gin.Context) {
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	groups, err := s.natsClient.ListGroups()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list groups", err.Error())
+		return
+	}
+
+	doc := models.ConfigDocument{
+		APIVersion: models.DocumentVersion,
+		ExportedAt: time.Now().UTC(),
+		Providers:  providers,
+		Policies:   policies,
+		Groups:     groups,
+	}
+
+	filename := "router-sync-" + doc.ExportedAt.Format("20060102-150405")
+	if wantsYAML(c) {
+		data, err := yaml.Marshal(&doc)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to encode export", err.Error())
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".yaml"))
+		c.Data(http.StatusOK, "application/yaml", data)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+	c.JSON(http.StatusOK, doc)
+}
+
+// importConfig applies a document produced by export
+// @Summary Import configuration
+// @Description Import providers, policies and policy groups from a YAML or JSON document. mode=merge (default) creates/updates the listed records; mode=replace also deletes records missing from the document. The resulting set is validated as a whole (provider references, table IDs, identical rules) before anything is written; overlapping sources at the same priority are reported as warnings. With dry_run=true nothing is written and the planned changes are returned.
+// @Tags config
+// @Accept json
+// @Accept application/yaml
+// @Produce json
+// @Param mode query string false "merge or replace"
+// @Param dry_run query bool false "Validate and plan only"
+// @Param document body models.ConfigDocument true "Configuration document"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/import [post]
+// @Router /api/v2/import [post]
+func (s *Server) importConfig(c *gin.Context) {
+	mode := strings.ToLower(c.DefaultQuery("mode", importModeMerge))
+	if mode != importModeMerge && mode != importModeReplace {
+		respondError(c, http.StatusBadRequest, "Invalid mode", fmt.Sprintf("mode must be %q or %q", importModeMerge, importModeReplace))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	doc, err := decodeDocument(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid document", err.Error())
+		return
+	}
+
+	existingProviders, err := s.natsClient.ListProviders()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list providers", err.Error())
+		return
+	}
+	existingPolicies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list policies", err.Error())
+		return
+	}
+	existingGroups, err := s.natsClient.ListGroups()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list groups", err.Error())
+		return
+	}
+
+	for _, p := range doc.Policies {
+		if p != nil {
+			p.Tags = models.NormalizeTags(p.Tags)
+			upgradeLegacyPolicy(p, existingPolicies)
+		}
+	}
+
+	known := make(map[string]bool)
+	if mode == importModeMerge {
+		for _, p := range existingProviders {
+			known[p.ID] = true
+		}
+	}
+	problems := doc.Validate(known)
+	providers, policies := doc.Providers, doc.Policies
+	if len(problems) == 0 && mode == importModeMerge {
+		// The records kept from the store must fit with the imported ones
+		providers, policies = mergeImport(doc, existingProviders, existingPolicies)
+		problems = models.ValidateCollection(providers, policies, nil)
+	}
+	if len(problems) > 0 {
+		if isV2(c) {
+			respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", strings.Join(problems, "; "))
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": strings.Join(problems, "; "),
+			"errors":  problems,
+		})
+		return
+	}
+
+	result := ImportResult{Mode: mode, DryRun: dryRun, Warnings: models.PriorityConflicts(policies)}
+	providerPlan := planProviders(doc.Providers, existingProviders, mode, &result.Providers)
+	policyPlan := planPolicies(doc.Policies, existingPolicies, mode, &result.Policies)
+	groupPlan := planGroups(doc.Groups, existingGroups, mode, &result.Groups)
+
+	if !dryRun {
+		s.applyImport(c, &result, providerPlan, policyPlan, groupPlan, existingProviders, existingPolicies, existingGroups)
+		requestLog(c).Infof("Imported configuration (mode=%s): providers %d created/%d updated/%d deleted, policies %d created/%d updated/%d deleted, %d error(s)",
+			mode,
+			len(result.Providers.Created), len(result.Providers.Updated), len(result.Providers.Deleted),
+			len(result.Policies.Created), len(result.Policies.Updated), len(result.Policies.Deleted),
+			len(result.Errors))
+	}
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, result)
+}
+
+// mergeImport returns the providers and policies the store holds after a
+// merge import of doc: the document's records, then the stored ones it does
+// not replace.
+func mergeImport(doc *models.ConfigDocument, existingProviders []*models.InternetProvider, existingPolicies []*models.RoutingPolicy) ([]*models.InternetProvider, []*models.RoutingPolicy) {
+	providers := append([]*models.InternetProvider(nil), doc.Providers...)
+	imported := make(map[string]bool, len(doc.Providers))
+	for _, p := range doc.Providers {
+		imported[p.ID] = true
+	}
+	for _, p := range existingProviders {
+		if !imported[p.ID] {
+			providers = append(providers, p)
+		}
+	}
+
+	policies := append([]*models.RoutingPolicy(nil), doc.Policies...)
+	imported = make(map[string]bool, len(doc.Policies))
+	for _, p := range doc.Policies {
+		imported[p.ID] = true
+	}
+	for _, p := range existingPolicies {
+		if !imported[p.ID] {
+			policies = append(policies, p)
+		}
+	}
+	return providers, policies
+}
+
+// applyImport writes planned changes with their audit entries, appending
+// failures to result.Errors. Providers and groups are stored before policies
+// so a policy never references a missing one; deletions run in reverse order
+// for the same reason.
+func (s *Server) applyImport(c *gin.Context, result *ImportResult, providerPlan providerPlan, policyPlan policyPlan, groupPlan groupPlan,
+	existingProviders []*models.InternetProvider, existingPolicies []*models.RoutingPolicy, existingGroups []*models.PolicyGroup) {
+	prevProviders := make(map[string]json.RawMessage, len(existingProviders))
+	for _, p := range existingProviders {
+		prevProviders[p.ID] = auditSnapshot(p)
+	}
+	prevPolicies := make(map[string]json.RawMessage, len(existingPolicies))
+	for _, p := range existingPolicies {
+		prevPolicies[p.ID] = auditSnapshot(p)
+	}
+	prevGroups := make(map[string]json.RawMessage, len(existingGroups))
+	for _, g := range existingGroups {
+		prevGroups[g.ID] = auditSnapshot(g)
+	}
+
+	for _, p := range providerPlan.store {
+		if err := s.natsClient.StoreProvider(p); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("provider %s: %v", p.ID, err))
+			continue
+		}
+		s.recordAudit(c, auditStoreAction(prevProviders[p.ID]), models.AuditEntityProvider, p.ID, prevProviders[p.ID], auditSnapshot(p))
+	}
+	for _, g := range groupPlan.store {
+		if err := s.natsClient.StoreGroup(g); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("group %s: %v", g.ID, err))
+			continue
+		}
+		s.recordAudit(c, auditStoreAction(prevGroups[g.ID]), models.AuditEntityGroup, g.ID, prevGroups[g.ID], auditSnapshot(g))
+	}
+	for _, p := range policyPlan.store {
+		if err := s.natsClient.StorePolicy(p); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("policy %s: %v", p.ID, err))
+			continue
+		}
+		s.recordAudit(c, auditStoreAction(prevPolicies[p.ID]), models.AuditEntityPolicy, p.ID, prevPolicies[p.ID], auditSnapshot(p))
+	}
+	for _, id := range result.Policies.Deleted {
+		if err := s.natsClient.DeletePolicy(id); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete policy %s: %v", id, err))
+			continue
+		}
+		s.recordAudit(c, models.AuditActionDelete, models.AuditEntityPolicy, id, prevPolicies[id], nil)
+	}
+	for _, id := range result.Groups.Deleted {
+		if err := s.natsClient.DeleteGroup(id); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete group %s: %v", id, err))
+			continue
+		}
+		s.recordAudit(c, models.AuditActionDelete, models.AuditEntityGroup, id, prevGroups[id], nil)
+	}
+	for _, id := range result.Providers.Deleted {
+		if err := s.natsClient.DeleteProvider(id); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("delete provider %s: %v", id, err))
+			continue
+		}
+		s.recordAudit(c, models.AuditActionDelete, models.AuditEntityProvider, id, prevProviders[id], nil)
+	}
+}
+
+// auditStoreAction is create when there was no previous record, else update.
+func auditStoreAction(before json.RawMessage) string {
+	if before == nil {
+		return models.AuditActionCreate
+	}
+	return models.AuditActionUpdate
+}
+
+type providerPlan struct {
+	store []*models.InternetProvider
+}
+
+type policyPlan struct {
+	store []*models.RoutingPolicy
+}
+
+type groupPlan struct {
+	store []*models.PolicyGroup
+}
+
+func planProviders(incoming, existing []*models.InternetProvider, mode string, changes *ImportChanges) providerPlan {
+	current := make(map[string]*models.InternetProvider, len(existing))
+	for _, p := range existing {
+		current[p.ID] = p
+	}
+
+	var plan providerPlan
+	wanted := make(map[string]bool, len(incoming))
+	for _, p := range incoming {
+		wanted[p.ID] = true
+		if p.HealthCheck != nil {
+			p.HealthCheck.ApplyDefaults() // as createProvider stores it
+		}
+		prev, ok := current[p.ID]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, p.ID)
+			plan.store = append(plan.store, p)
+		case sameProvider(prev, p):
+			changes.Unchanged = append(changes.Unchanged, p.ID)
+		default:
+			p.CreatedAt = prev.CreatedAt
+			changes.Updated = append(changes.Updated, p.ID)
+			plan.store = append(plan.store, p)
+		}
+	}
+	if mode == importModeReplace {
+		for _, p := range existing {
+			if !wanted[p.ID] {
+				changes.Deleted = append(changes.Deleted, p.ID)
+			}
+		}
+	}
+	return plan
+}
+
+func planPolicies(incoming, existing []*models.RoutingPolicy, mode string, changes *ImportChanges) policyPlan {
+	current := make(map[string]*models.RoutingPolicy, len(existing))
+	for _, p := range existing {
+		current[p.ID] = p
+	}
+
+	var plan policyPlan
+	wanted := make(map[string]bool, len(incoming))
+	for _, p := range incoming {
+		wanted[p.ID] = true
+		prev, ok := current[p.ID]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, p.ID)
+			plan.store = append(plan.store, p)
+		case samePolicy(prev, p):
+			changes.Unchanged = append(changes.Unchanged, p.ID)
+		default:
+			p.CreatedAt = prev.CreatedAt
+			changes.Updated = append(changes.Updated, p.ID)
+			plan.store = append(plan.store, p)
+		}
+	}
+	if mode == importModeReplace {
+		for _, p := range existing {
+			if !wanted[p.ID] {
+				changes.Deleted = append(changes.Deleted, p.ID)
+			}
+		}
+	}
+	return plan
+}
+
+func planGroups(incoming, existing []*models.PolicyGroup, mode string, changes *ImportChanges) groupPlan {
+	current := make(map[string]*models.PolicyGroup, len(existing))
+	for _, g := range existing {
+		current[g.ID] = g
+	}
+
+	var plan groupPlan
+	wanted := make(map[string]bool, len(incoming))
+	for _, g := range incoming {
+		wanted[g.ID] = true
+		prev, ok := current[g.ID]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, g.ID)
+			plan.store = append(plan.store, g)
+		case prev.Name == g.Name && prev.Description == g.Description:
+			changes.Unchanged = append(changes.Unchanged, g.ID)
+		default:
+			g.CreatedAt = prev.CreatedAt
+			changes.Updated = append(changes.Updated, g.ID)
+			plan.store = append(plan.store, g)
+		}
+	}
+	if mode == importModeReplace {
+		for _, g := range existing {
+			if !wanted[g.ID] {
+				changes.Deleted = append(changes.Deleted, g.ID)
+			}
+		}
+	}
+	return plan
+}
+
+// sameProvider compares user-facing fields, ignoring generation and timestamps.
+func sameProvider(a, b *models.InternetProvider) bool {
+	return a.Name == b.Name &&
+		a.Interface == b.Interface &&
+		a.TableID == b.TableID &&
+		a.Gateway == b.Gateway &&
+		a.GatewayV6 == b.GatewayV6 &&
+		a.InterfaceV6 == b.InterfaceV6 &&
+		a.Description == b.Description &&
+		sameLabels(a.Labels, b.Labels) &&
+		reflect.DeepEqual(a.HealthCheck, b.HealthCheck) &&
+		a.Weight == b.Weight &&
+		a.FailoverPriority == b.FailoverPriority &&
+		a.MonthlyCap == b.MonthlyCap &&
+		a.CapResetDay == b.CapResetDay &&
+		a.CapDrainPercent == b.CapDrainPercent &&
+		a.CapDrainTargetID == b.CapDrainTargetID &&
+		len(a.Interfaces) == len(b.Interfaces) &&
+		(len(a.Interfaces) == 0 || reflect.DeepEqual(a.Interfaces, b.Interfaces))
+}
+
+// samePolicy compares user-facing fields, ignoring generation and timestamps.
+func samePolicy(a, b *models.RoutingPolicy) bool {
+	return a.Name == b.Name &&
+		a.SourceIP == b.SourceIP &&
+		reflect.DeepEqual(a.DelegatedSource, b.DelegatedSource) &&
+		a.Destination == b.Destination &&
+		a.Protocol == b.Protocol &&
+		a.SourcePorts == b.SourcePorts &&
+		a.DestinationPorts == b.DestinationPorts &&
+		a.ProviderID == b.ProviderID &&
+		len(a.BackupProviderIDs) == len(b.BackupProviderIDs) &&
+		(len(a.BackupProviderIDs) == 0 || reflect.DeepEqual(a.BackupProviderIDs, b.BackupProviderIDs)) &&
+		a.Mode == b.Mode &&
+		a.Description == b.Description &&
+		a.Enabled == b.Enabled &&
+		a.Favorite == b.Favorite &&
+		a.Priority == b.Priority &&
+		sameLabels(a.Labels, b.Labels) &&
+		sameTime(a.ExpiresAt, b.ExpiresAt) &&
+		reflect.DeepEqual(a.Schedule, b.Schedule) &&
+		a.GroupID == b.GroupID &&
+		a.Tenant == b.Tenant &&
+		strings.Join(a.Tags, ",") == strings.Join(b.Tags, ",")
+}
+
+// sameLabels compares label maps, treating nil and empty as equal.
+func sameLabels(a, b map[string]string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// sameTime compares optional timestamps.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// decodeDocument parses the request body as YAML or JSON depending on Content-Type.
+func decodeDocument(c *gin.Context) (*models.ConfigDocument, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxImportBytes {
+		return nil, fmt.Errorf("document exceeds %d bytes", maxImportBytes)
+	}
+
+	var doc models.ConfigDocument
+	if strings.Contains(c.ContentType(), "yaml") {
+		err = yaml.Unmarshal(body, &doc)
+	} else {
+		err = json.Unmarshal(body, &doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func wantsYAML(c *gin.Context) bool {
+	if f := strings.ToLower(c.Query("format")); f != "" {
+		return f == "yaml" || f == "yml"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "yaml")
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeImport(t *testing.T) {
+	doc := &models.ConfigDocument{
+		Providers: []*models.InternetProvider{{ID: "isp2", TableID: 100}},
+		Policies:  []*models.RoutingPolicy{{ID: "192.168.2.0/24", SourceIP: "192.168.2.0/24", ProviderID: "isp2", Enabled: true}},
+	}
+	existingProviders := []*models.InternetProvider{{ID: "isp1", TableID: 100}, {ID: "isp2", TableID: 200}}
+	existingPolicies := []*models.RoutingPolicy{{ID: "192.168.2.0/24", SourceIP: "192.168.2.0/24", ProviderID: "isp1", Enabled: true}}
+
+	providers, policies := mergeImport(doc, existingProviders, existingPolicies)
+	assert.Equal(t, []*models.InternetProvider{doc.Providers[0], existingProviders[0]}, providers)
+	assert.Equal(t, doc.Policies, policies, "imported policies replace stored ones with the same ID")
+
+	// isp2 moves onto the table isp1 keeps
+	problems := models.ValidateCollection(providers, policies, nil)
+	assert.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "table 100")
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+	"router-sync/internal/nats"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageStats is one provider's traffic in its current billing period.
+// PercentOfCap is 0 when the provider has no monthly cap.
+type UsageStats struct {
+	ProviderID   string     `json:"provider_id"`
+	PeriodStart  time.Time  `json:"period_start"`
+	RxBytes      uint64     `json:"rx_bytes"`
+	TxBytes      uint64     `json:"tx_bytes"`
+	TotalBytes   uint64     `json:"total_bytes"`
+	MonthlyCap   int64      `json:"monthly_cap,omitempty"`
+	PercentOfCap float64    `json:"percent_of_cap,omitempty"`
+	DrainedAt    *time.Time `json:"drained_at,omitempty"`
+}
+
+// refreshUsage adds the interface counters from the latest router heartbeats
+// to every provider's usage record, drains providers that reached their cap
+// and returns the usage for the stats snapshot. With several API replicas
+// the stored counter baselines keep a heartbeat from being counted twice.
+func (s *Server) refreshUsage(providers []*models.InternetProvider, policies []*models.RoutingPolicy, states []*models.RouterState, now time.Time) []UsageStats {
+	known := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		known[p.ID] = true
+	}
+
+	out := make([]UsageStats, 0, len(providers))
+	for _, p := range providers {
+		log := logging.Provider(p.ID)
+		usage, err := s.natsClient.GetUsage(p.ID)
+		if err != nil {
+			log.Warnf("Failed to get usage: %v", err)
+			continue
+		}
+		accumulateUsage(usage, p, states, now)
+		if threshold := p.CapDrainThreshold(); threshold > 0 && usage.DrainedAt == nil && usage.Total() >= uint64(threshold) && known[p.CapDrainTargetID] {
+			s.drainCappedProvider(p, policies, now)
+			drained := now
+			usage.DrainedAt = &drained
+		}
+		if err := s.natsClient.StoreUsage(usage); err != nil {
+			log.Warnf("Failed to store usage: %v", err)
+		}
+		out = append(out, usageStats(usage, p))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ProviderID < out[j].ProviderID })
+
+	s.providerUsageBytes.Reset()
+	for _, u := range out {
+		s.providerUsageBytes.WithLabelValues(u.ProviderID, "rx").Set(float64(u.RxBytes))
+		s.providerUsageBytes.WithLabelValues(u.ProviderID, "tx").Set(float64(u.TxBytes))
+	}
+	return out
+}
+
+// accumulateUsage rolls usage over into the billing period containing now
+// and adds the counters of p's interfaces on every router.
+func accumulateUsage(usage *models.ProviderUsage, p *models.InternetProvider, states []*models.RouterState, now time.Time) {
+	usage.ProviderID = p.ID
+	usage.Rollover(models.BillingPeriodStart(now, p.CapResetDay))
+
+	for _, st := range states {
+		names := map[string]bool{p.InterfaceForHost(st.Hostname): true}
+		if p.GatewayV6 != "" {
+			names[p.InterfaceV6ForHost(st.Hostname)] = true
+		}
+		for _, iface := range st.Interfaces {
+			if iface.Name == "" || !names[iface.Name] {
+				continue
+			}
+			usage.Accumulate(st.Hostname+"/"+iface.Name, models.InterfaceCounters{RxBytes: iface.RxBytes, TxBytes: iface.TxBytes})
+		}
+	}
+	usage.UpdatedAt = now
+}
+
+// drainCappedProvider moves every policy on p to p.CapDrainTargetID. Writes
+// are conditional on the generation read, like the expiry loop's.
+func (s *Server) drainCappedProvider(p *models.InternetProvider, policies []*models.RoutingPolicy, now time.Time) {
+	selected, _ := selectDrainPolicies(policies, p.ID, nil)
+	moved := 0
+	for _, policy := range selected {
+		log := logging.Policy(policy.ID, policy.ProviderID)
+		before := auditSnapshot(policy)
+		generation := policy.Generation
+		policy.ProviderID = p.CapDrainTargetID
+		policy.UpdatedAt = now
+		if err := s.natsClient.StorePolicyIfMatch(policy, generation); err != nil {
+			if !errors.Is(err, nats.ErrPreconditionFailed) {
+				log.Warnf("Failed to drain policy %s off capped provider: %v", policy.Name, err)
+			}
+			continue
+		}
+		moved++
+		s.recordSystemAudit(models.AuditActionUpdate, models.AuditEntityPolicy, policy.ID, before, auditSnapshot(policy))
+	}
+	logrus.Infof("Provider %s reached its data cap drain threshold; moved %d policies to %s", p.ID, moved, p.CapDrainTargetID)
+}
+
+// usageStats converts a usage record for the stats snapshot.
+func usageStats(u *models.ProviderUsage, p *models.InternetProvider) UsageStats {
+	st := UsageStats{
+		ProviderID:  u.ProviderID,
+		PeriodStart: u.PeriodStart,
+		RxBytes:     u.RxBytes,
+		TxBytes:     u.TxBytes,
+		TotalBytes:  u.Total(),
+		MonthlyCap:  p.MonthlyCap,
+		DrainedAt:   u.DrainedAt,
+	}
+	if p.MonthlyCap > 0 {
+		st.PercentOfCap = float64(u.Total()) / float64(p.MonthlyCap) * 100
+	}
+	return st
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateRequest carries a draft provider or policy. Exactly one must be set.
+type ValidateRequest struct {
+	Provider *models.InternetProvider `json:"provider,omitempty"`
+	Policy   *models.RoutingPolicy    `json:"policy,omitempty"`
+}
+
+// ValidationIssue is one problem found in a draft.
+type ValidationIssue struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationResult is returned by POST /api/v1/validate. Errors block a
+// write; warnings describe side effects worth a second look.
+type ValidationResult struct {
+	Valid    bool              `json:"valid"`
+	Kind     string            `json:"kind"`
+	Errors   []ValidationIssue `json:"errors"`
+	Warnings []ValidationIssue `json:"warnings"`
+}
+
+func (r *ValidationResult) errorf(field, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ValidationResult) warnf(field, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ValidationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateDraft validates a provider or policy without storing it
+// @Summary Validate a draft
+// @Description Run full validation on a provider or policy document, including cross-checks against stored data (duplicate table IDs, unknown providers, overlapping source CIDRs). Nothing is stored.
+// @Tags validation
+// @Accept json
+// @Produce json
+// @Param draft body ValidateRequest true "Provider or policy draft"
+// @Success 200 {object} ValidationResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/validate [post]
+// @Router /api/v2/validate [post]
+func (s *Server) validateDraft(c *gin.Context) {
+	var req ValidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if (req.Provider == nil) == (req.Policy == nil) {
+		respondError(c, http.StatusBadRequest, "Invalid request body", "set exactly one of 'provider' or 'policy'")
+		return
+	}
+
+	var (
+		result *ValidationResult
+		err    error
+	)
+	if req.Provider != nil {
+		result, err = s.validateProviderDraft(req.Provider)
+	} else {
+		result, err = s.validatePolicyDraft(req.Policy)
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to load stored data for cross-checks", err.Error())
+		return
+	}
+
+	result.Valid = len(result.Errors) == 0
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) validateProviderDraft(p *models.InternetProvider) (*ValidationResult, error) {
+	result := &ValidationResult{Kind: "provider", Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+
+	// Providers are keyed by name; mirror createProvider.
+	if p.ID == "" {
+		p.ID = p.Name
+	}
+	if err := p.Validate(); err != nil {
+		result.errorf("", "%v", err)
+	}
+
+	providers, err := s.natsClient.ListProviders()
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range providers {
+		if other.ID == p.ID {
+			result.warnf("name", "provider '%s' already exists; saving will update it", p.ID)
+			continue
+		}
+		if p.TableID > 0 && other.TableID == p.TableID {
+			result.errorf("table_id", "table %d is already used by provider '%s'", p.TableID, other.ID)
+		}
+		if p.FailoverPriority > 0 && other.FailoverPriority == p.FailoverPriority {
+			result.errorf("failover_priority", "failover_priority %d is already used by provider '%s'", p.FailoverPriority, other.ID)
+		}
+	}
+	if p.CapDrainTargetID != "" {
+		if _, err := s.natsClient.GetProvider(p.CapDrainTargetID); err != nil {
+			result.errorf("cap_drain_target_id", "provider '%s' does not exist", p.CapDrainTargetID)
+		}
+	}
+
+	states, err := s.natsClient.ListRouterStates()
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range states {
+		iface := p.InterfaceForHost(st.Hostname)
+		if iface == "" {
+			result.warnf("interfaces", "no interface mapped for router '%s'; policies on this provider will not apply there", st.Hostname)
+			continue
+		}
+		if !routerHasInterface(st, iface) {
+			result.warnf("interfaces", "router '%s' reports no interface named '%s'", st.Hostname, iface)
+		}
+		for _, problem := range models.ValidateCollection([]*models.InternetProvider{p}, nil, []*models.RouterState{st}) {
+			result.errorf("gateway", "%s", problem)
+		}
+		if p.InterfaceV6 != "" && !routerHasInterface(st, p.InterfaceV6) {
+			result.warnf("interface_v6", "router '%s' reports no interface named '%s'", st.Hostname, p.InterfaceV6)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Server) validatePolicyDraft(p *models.RoutingPolicy) (*ValidationResult, error) {
+	result := &ValidationResult{Kind: "policy", Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+
+	// Mirror createPolicy: a draft without an ID is a new policy.
+	if p.ID == "" {
+		p.ID = models.NewPolicyID()
+		if p.Tenant != "" {
+			p.ID = models.NewTenantPolicyID(p.Tenant)
+		}
+	}
+	if err := p.Validate(); err != nil {
+		result.errorf("", "%v", err)
+	}
+
+	if p.ProviderID != "" {
+		if _, err := s.natsClient.GetProvider(p.ProviderID); err != nil {
+			result.errorf("provider_id", "provider '%s' does not exist", p.ProviderID)
+		}
+	}
+	for _, id := range p.BackupProviderIDs {
+		if _, err := s.natsClient.GetProvider(id); err != nil {
+			result.errorf("backup_provider_ids", "backup provider '%s' does not exist", id)
+		}
+	}
+
+	if p.Enabled && p.Expired(time.Now()) {
+		result.warnf("expires_at", "expires_at %s is in the past; the policy will not be applied", p.ExpiresAt.Format(time.RFC3339))
+	}
+
+	srcNet, err := p.SourceNet()
+	if err != nil {
+		return result, nil
+	}
+	dstNet, err := p.DestinationNet()
+	if err != nil {
+		return result, nil
+	}
+	key, _ := p.RuleKey()
+
+	policies, err := s.natsClient.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range policies {
+		otherNet, err := other.SourceNet()
+		if err != nil {
+			continue
+		}
+		otherDst, err := other.DestinationNet()
+		if err != nil {
+			continue
+		}
+		if other.ID == p.ID {
+			continue
+		}
+		otherKey, _ := other.RuleKey()
+		if otherKey == key {
+			if !p.CanOverlap(other, time.Now()) {
+				continue
+			}
+			if p.Enabled && other.Enabled {
+				result.errorf("source_ip", "policy '%s' is already enabled for %s; only one enabled policy may use a source and destination", other.Name, key)
+			} else {
+				result.warnf("source_ip", "policy '%s' also uses %s; only one of them can be enabled at a time", other.Name, key)
+			}
+			continue
+		}
+		if !models.SourcesOverlap(srcNet, otherNet) || !destinationsOverlap(dstNet, otherDst) {
+			continue
+		}
+		winner := p.Name
+		mine, theirs := p.RulePriority(srcNet), other.RulePriority(otherNet)
+		if theirs < mine {
+			winner = other.Name
+		}
+		if mine == theirs {
+			result.warnf("priority", "%s and policy '%s' (%s) share priority %d; the kernel order between them is undefined",
+				key, other.Name, otherKey, mine)
+			continue
+		}
+		result.warnf("source_ip", "%s overlaps policy '%s' (%s); the rule with the lower priority wins for shared traffic ('%s')",
+			key, other.Name, otherKey, winner)
+	}
+
+	if p.Classified() {
+		states, err := s.natsClient.ListRouterStates()
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range states {
+			if !hasFeature(st, "nftables") {
+				result.warnf("protocol", "router '%s' does not have features.nftables enabled; protocol and port policies are not applied there", st.Hostname)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// destinationsOverlap is SourcesOverlap for optional destinations, where nil
+// means every destination.
+func destinationsOverlap(a, b *net.IPNet) bool {
+	return a == nil || b == nil || models.SourcesOverlap(a, b)
+}
+
+func routerHasInterface(st *models.RouterState, name string) bool {
+	for _, iface := range st.Interfaces {
+		if iface.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFeature(st *models.RouterState, name string) bool {
+	for _, f := range st.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom binding tags, usable in any request struct:
+//
+//	ip_or_cidr  an IPv4/IPv6 address or CIDR (policy source_ip)
+//	ifname      a Linux interface name: 1-15 bytes, no '/', ':' or whitespace
+//	table_id    a routing table ID in the configured router table range
+//	            (1..2^32-1 by default), excluding the reserved default (253),
+//	            main (254) and local (255) tables
+const (
+	tagIPOrCIDR = "ip_or_cidr"
+	tagIfName   = "ifname"
+	tagTableID  = "table_id"
+)
+
+// maxIfNameLen is IFNAMSIZ minus the terminating NUL.
+const maxIfNameLen = models.MaxInterfaceNameLen
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Report JSON field names ("source_ip") rather than Go ones ("SourceIP").
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	_ = v.RegisterValidation(tagIPOrCIDR, func(fl validator.FieldLevel) bool {
+		_, err := models.ParseSource(fl.Field().String())
+		return err == nil
+	})
+	_ = v.RegisterValidation(tagIfName, func(fl validator.FieldLevel) bool {
+		return validIfName(fl.Field().String())
+	})
+	_ = v.RegisterValidation(tagTableID, func(fl validator.FieldLevel) bool {
+		return validTableID(fl.Field().Int())
+	})
+}
+
+// validIfName mirrors the kernel's dev_valid_name().
+func validIfName(name string) bool {
+	return models.ValidInterfaceName(name)
+}
+
+func validTableID(id int64) bool {
+	return id >= 1 && id <= models.MaxTableID && models.ValidTableID(int(id))
+}
+
+// FieldViolation is one field that failed request validation.
+type FieldViolation struct {
+	Field   string `json:"field" example:"source_ip"`
+	Rule    string `json:"rule" example:"ip_or_cidr"`
+	Message string `json:"message" example:"must be an IP address or CIDR, got \"10.0.0.300\""`
+}
+
+// fieldViolations converts validator errors into per-field messages; ok is
+// false when err is not a validation error (e.g. malformed JSON).
+func fieldViolations(err error) ([]FieldViolation, bool) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, false
+	}
+	out := make([]FieldViolation, 0, len(verrs))
+	for _, fe := range verrs {
+		out = append(out, FieldViolation{
+			Field:   fieldPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Message: violationMessage(fe.Tag(), fe.Param(), fe.Value()),
+		})
+	}
+	return out, true
+}
+
+// fieldPath drops the struct name from a namespace like
+// "CreateProviderRequest.interfaces[r1]".
+func fieldPath(ns string) string {
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+func violationMessage(tag, param string, value interface{}) string {
+	switch tag {
+	case "required":
+		return "is required"
+	case tagIPOrCIDR:
+		return fmt.Sprintf("must be an IP address or CIDR, got %q", value)
+	case "ip":
+		return fmt.Sprintf("must be an IP address, got %q", value)
+	case tagIfName:
+		return fmt.Sprintf("must be a valid interface name (1-%d characters, no '/', ':' or spaces), got %q", maxIfNameLen, value)
+	case tagTableID:
+		r := models.CurrentRanges()
+		return fmt.Sprintf("must be a routing table ID between %d and %d, excluding reserved tables 253-255, got %v", r.TableMin, r.TableMax, value)
+	case "min":
+		return "must be at least " + param
+	case "max":
+		return "must be at most " + param
+	case "oneof":
+		return "must be one of: " + param
+	default:
+		return fmt.Sprintf("failed %q validation", tag)
+	}
+}
+
+// respondBindError answers a failed ShouldBind*: 400 validation_failed with
+// per-field messages for validation errors, a plain 400 otherwise.
+func respondBindError(c *gin.Context, err error) {
+	violations, ok := fieldViolations(err)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	parts := make([]string, 0, len(violations))
+	for _, v := range violations {
+		parts = append(parts, v.Field+": "+v.Message)
+	}
+	details := strings.Join(parts, "; ")
+
+	if !isV2(c) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": details,
+			"fields":  violations,
+		})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: APIError{
+		Code:      CodeValidationFailed,
+		Message:   "Validation failed",
+		Details:   details,
+		Fields:    violations,
+		RequestID: c.GetString(requestIDKey),
+	}})
+}
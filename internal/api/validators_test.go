@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidIfName(t *testing.T) {
+	for _, name := range []string{"eth0", "enp1s0", "wg-starlink", "br0.100", "abcdefghijklmno"} {
+		assert.True(t, validIfName(name), name)
+	}
+	for _, name := range []string{"", ".", "..", "eth0:1", "a/b", "has space", "abcdefghijklmnop"} {
+		assert.False(t, validIfName(name), name)
+	}
+}
+
+func TestValidTableID(t *testing.T) {
+	assert.True(t, validTableID(1))
+	assert.True(t, validTableID(100))
+	assert.True(t, validTableID(256))
+	assert.True(t, validTableID(1<<32-1))
+	assert.False(t, validTableID(0))
+	assert.False(t, validTableID(253))
+	assert.False(t, validTableID(254))
+	assert.False(t, validTableID(255))
+	assert.False(t, validTableID(1<<32))
+}
+
+func TestFieldPath(t *testing.T) {
+	assert.Equal(t, "source_ip", fieldPath("CreatePolicyRequest.source_ip"))
+	assert.Equal(t, "interfaces[r1]", fieldPath("CreateProviderRequest.interfaces[r1]"))
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VersionResponse identifies the running build.
+type VersionResponse struct {
+	Version   string   `json:"version" example:"1.4.0"`
+	BuildTime string   `json:"build_time" example:"2025-01-31_18:00:00"`
+	GitCommit string   `json:"git_commit" example:"a1b2c3d"`
+	GoVersion string   `json:"go_version" example:"go1.21.5"`
+	Platform  string   `json:"platform" example:"linux/amd64"`
+	Mode      string   `json:"mode" example:"controller"`
+	Features  []string `json:"features" example:"failover,nftables"`
+}
+
+// getVersion returns the build and runtime of this API process
+// @Summary Version
+// @Description Return the version, build time and commit of the API process serving the request, its Go runtime and the enabled feature flags. Unauthenticated, like /health.
+// @Tags health
+// @Produce json
+// @Success 200 {object} VersionResponse
+// @Router /version [get]
+func (s *Server) getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, VersionResponse{
+		Version:   s.version,
+		BuildTime: s.buildTime,
+		GitCommit: s.gitCommit,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Mode:      string(s.effective.Mode),
+		Features:  s.effective.Features.Enabled(),
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &Server{
+		version:   "1.4.0",
+		buildTime: "2025-01-31_18:00:00",
+		gitCommit: "a1b2c3d",
+		effective: &config.Config{
+			Mode:     config.ModeController,
+			Features: config.FeaturesConfig{Failover: true},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/version", nil)
+	server.getVersion(c)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got VersionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, VersionResponse{
+		Version:   "1.4.0",
+		BuildTime: "2025-01-31_18:00:00",
+		GitCommit: "a1b2c3d",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Mode:      "controller",
+		Features:  []string{"failover"},
+	}, got)
+}
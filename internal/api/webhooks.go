@@ -0,0 +1,333 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"router-sync/internal/config"
+	"router-sync/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// webhookQueue is the NATS queue group shared by API replicas so each event
+// is delivered by exactly one of them.
+const webhookQueue = "router-sync-webhooks"
+
+// WebhookRequest creates or updates a webhook subscription. Events lists the
+// event types to deliver (empty = all). On update, omitted fields are kept;
+// secret "" removes the signing secret.
+type WebhookRequest struct {
+	URL         string   `json:"url" example:"https://hooks.example.com/router-sync"`
+	Events      []string `json:"events" example:"config.changed,sync.completed,provider.health"`
+	Secret      *string  `json:"secret" example:"change-me"`
+	Enabled     *bool    `json:"enabled" example:"true"`
+	Description *string  `json:"description" example:"Ops chat relay"`
+}
+
+// WebhookResponse is a webhook subscription; the secret is never returned.
+type WebhookResponse struct {
+	models.Webhook
+	HasSecret bool `json:"has_secret"`
+}
+
+// WebhookTestResult is the outcome of a test delivery.
+type WebhookTestResult struct {
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
+
+func webhookResponse(hook *models.Webhook) WebhookResponse {
+	out := WebhookResponse{Webhook: *hook, HasSecret: hook.Secret != ""}
+	out.Secret = ""
+	return out
+}
+
+// configuredWebhooks turns the api.webhooks entries into subscriptions with
+// the ID "config:<name>", checked like ones created through the API.
+func configuredWebhooks(cfgs []config.WebhookConfig) ([]*models.Webhook, error) {
+	hooks := make([]*models.Webhook, 0, len(cfgs))
+	seen := make(map[string]bool, len(cfgs))
+	for i, c := range cfgs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("webhooks[%d]: name is required", i)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("webhooks[%d]: duplicate name %q", i, c.Name)
+		}
+		seen[c.Name] = true
+		hook := &models.Webhook{
+			ID:          "config:" + c.Name,
+			URL:         c.URL,
+			Events:      c.Events,
+			Secret:      c.Secret,
+			Enabled:     true,
+			Description: "api.webhooks",
+		}
+		if err := hook.Validate(); err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", c.Name, err)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// withConfiguredWebhooks returns a subscription list for the dispatcher:
+// the configured webhooks followed by the ones list loads from NATS.
+func withConfiguredWebhooks(configured []*models.Webhook, list func() ([]*models.Webhook, error)) func() ([]*models.Webhook, error) {
+	return func() ([]*models.Webhook, error) {
+		stored, err := list()
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]*models.Webhook(nil), configured...), stored...), nil
+	}
+}
+
+func newWebhookID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// runWebhooks feeds events from the shared queue group to the dispatcher
+// until ctx is done, resubscribing on error.
+func (s *Server) runWebhooks(ctx context.Context) {
+	go s.webhooks.Run(ctx)
+	for {
+		if err := s.natsClient.SubscribeEventsQueue(ctx, webhookQueue, s.webhooks.Enqueue); err != nil {
+			logrus.Warnf("Webhook event subscription failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// listWebhooks lists webhook subscriptions
+// @Summary List webhooks
+// @Description Get all outbound webhook subscriptions. Secrets are never returned; has_secret tells whether deliveries are signed.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} WebhookResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [get]
+// @Router /api/v2/webhooks [get]
+func (s *Server) listWebhooks(c *gin.Context) {
+	hooks, err := s.natsClient.ListWebhooks()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to list webhooks", err.Error())
+		return
+	}
+
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	out := make([]WebhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		out = append(out, webhookResponse(hook))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// createWebhook creates a webhook subscription
+// @Summary Create webhook
+// @Description Subscribe an HTTP endpoint to events. Each matching event is POSTed as JSON with X-Router-Sync-Event, X-Router-Sync-Delivery and X-Router-Sync-Timestamp headers; with a secret, X-Router-Sync-Signature carries sha256=HMAC-SHA256(secret, timestamp + "." + body). Failed deliveries are retried twice with backoff.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body WebhookRequest true "Webhook subscription"
+// @Success 201 {object} WebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [post]
+// @Router /api/v2/webhooks [post]
+func (s *Server) createWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	id, err := newWebhookID()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to generate webhook ID", err.Error())
+		return
+	}
+	now := time.Now()
+	hook := &models.Webhook{
+		ID:        id,
+		URL:       req.URL,
+		Events:    req.Events,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if err := hook.Validate(); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+
+	if err := s.natsClient.StoreWebhook(hook); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create webhook", err.Error())
+		return
+	}
+	s.webhooks.Invalidate()
+	s.recordAudit(c, models.AuditActionCreate, models.AuditEntityWebhook, hook.ID, nil, auditSnapshot(webhookResponse(hook)))
+
+	c.JSON(http.StatusCreated, webhookResponse(hook))
+}
+
+// getWebhook gets a webhook subscription
+// @Summary Get webhook
+// @Description Get a webhook subscription by ID
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} WebhookResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [get]
+// @Router /api/v2/webhooks/{id} [get]
+func (s *Server) getWebhook(c *gin.Context) {
+	hook, err := s.natsClient.GetWebhook(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Webhook not found", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, webhookResponse(hook))
+}
+
+// updateWebhook updates a webhook subscription
+// @Summary Update webhook
+// @Description Update a webhook subscription. Omitted fields are kept; events replaces the list when present; secret "" removes the signing secret.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param webhook body WebhookRequest true "Fields to change"
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [put]
+// @Router /api/v2/webhooks/{id} [put]
+func (s *Server) updateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	hook, err := s.natsClient.GetWebhook(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Webhook not found", err.Error())
+		return
+	}
+	before := auditSnapshot(webhookResponse(hook))
+
+	if req.URL != "" {
+		hook.URL = req.URL
+	}
+	if req.Events != nil {
+		hook.Events = req.Events
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	hook.UpdatedAt = time.Now()
+	if err := hook.Validate(); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, CodeValidationFailed, "Validation failed", err.Error())
+		return
+	}
+
+	if err := s.natsClient.StoreWebhook(hook); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update webhook", err.Error())
+		return
+	}
+	s.webhooks.Invalidate()
+	s.recordAudit(c, models.AuditActionUpdate, models.AuditEntityWebhook, hook.ID, before, auditSnapshot(webhookResponse(hook)))
+
+	c.JSON(http.StatusOK, webhookResponse(hook))
+}
+
+// deleteWebhook deletes a webhook subscription
+// @Summary Delete webhook
+// @Description Delete a webhook subscription. Deliveries already queued may still be sent.
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [delete]
+// @Router /api/v2/webhooks/{id} [delete]
+func (s *Server) deleteWebhook(c *gin.Context) {
+	hook, err := s.natsClient.GetWebhook(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Webhook not found", err.Error())
+		return
+	}
+
+	if err := s.natsClient.DeleteWebhook(hook.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to delete webhook", err.Error())
+		return
+	}
+	s.webhooks.Invalidate()
+	s.recordAudit(c, models.AuditActionDelete, models.AuditEntityWebhook, hook.ID, auditSnapshot(webhookResponse(hook)), nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// testWebhook sends a ping event to a webhook
+// @Summary Test webhook
+// @Description Synchronously deliver a ping event to the webhook (even when disabled or not subscribed to ping) and report the outcome.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} WebhookTestResult
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/test [post]
+// @Router /api/v2/webhooks/{id}/test [post]
+func (s *Server) testWebhook(c *gin.Context) {
+	hook, err := s.natsClient.GetWebhook(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Webhook not found", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	ev := &models.Event{
+		ID:        "ping-" + now.Format("20060102T150405.000000000"),
+		Type:      models.EventPing,
+		Resource:  hook.ID,
+		Message:   "router-sync webhook test",
+		Timestamp: now,
+	}
+	result := WebhookTestResult{Delivered: true}
+	if err := s.webhooks.Deliver(c.Request.Context(), hook, ev); err != nil {
+		result = WebhookTestResult{Error: err.Error()}
+	}
+	c.JSON(http.StatusOK, result)
+}
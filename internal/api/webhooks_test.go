@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"router-sync/internal/config"
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguredWebhooks(t *testing.T) {
+	hooks, err := configuredWebhooks([]config.WebhookConfig{
+		{Name: "alerts", URL: "https://hooks.example.com/router-sync", Events: []string{"drift.detected"}, Secret: "s"},
+	})
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Equal(t, "config:alerts", hooks[0].ID)
+	assert.True(t, hooks[0].Enabled)
+	assert.True(t, hooks[0].Wants(models.EventDriftDetected))
+	assert.False(t, hooks[0].Wants(models.EventSyncCompleted))
+
+	for _, cfgs := range [][]config.WebhookConfig{
+		{{URL: "https://hooks.example.com"}},
+		{{Name: "a", URL: "https://hooks.example.com"}, {Name: "a", URL: "https://other.example.com"}},
+		{{Name: "a", URL: "hooks.example.com"}},
+	} {
+		_, err := configuredWebhooks(cfgs)
+		assert.Error(t, err, "%+v", cfgs)
+	}
+}
+
+func TestWithConfiguredWebhooks(t *testing.T) {
+	configured := []*models.Webhook{{ID: "config:alerts"}}
+	stored := []*models.Webhook{{ID: "5f2b8c1d9e0a4b7c"}}
+
+	list := withConfiguredWebhooks(configured, func() ([]*models.Webhook, error) { return stored, nil })
+	hooks, err := list()
+	require.NoError(t, err)
+	assert.Equal(t, []*models.Webhook{configured[0], stored[0]}, hooks)
+
+	list = withConfiguredWebhooks(configured, func() ([]*models.Webhook, error) { return nil, errors.New("nats down") })
+	_, err = list()
+	assert.Error(t, err, "a failed load keeps the dispatcher's previous list")
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"router-sync/internal/config"
+)
+
+// minAPIKeyLength keeps API keys long enough not to be guessed.
+const minAPIKeyLength = 16
+
+// apiKey is a configured API key with its role resolved.
+type apiKey struct {
+	name   string
+	sum    [sha256.Size]byte
+	role   Role
+	tenant string
+}
+
+// parseAPIKeys checks the configured API keys: each needs a name, a key of
+// at least minAPIKeyLength characters and a known role, and names and keys
+// must be unique.
+func parseAPIKeys(keys []config.APIKey) ([]apiKey, error) {
+	out := make([]apiKey, 0, len(keys))
+	names := make(map[string]bool, len(keys))
+	sums := make(map[[sha256.Size]byte]bool, len(keys))
+	for i, k := range keys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("api_keys[%d]: name is required", i)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("api_keys[%d]: duplicate name %q", i, k.Name)
+		}
+		if len(k.Key) < minAPIKeyLength {
+			return nil, fmt.Errorf("api_keys[%s]: key must be at least %d characters", k.Name, minAPIKeyLength)
+		}
+		sum := sha256.Sum256([]byte(k.Key))
+		if sums[sum] {
+			return nil, fmt.Errorf("api_keys[%s]: key is already used by another entry", k.Name)
+		}
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api_keys[%s]: %w", k.Name, err)
+		}
+		names[k.Name] = true
+		sums[sum] = true
+		out = append(out, apiKey{name: k.Name, sum: sum, role: role, tenant: k.Tenant})
+	}
+	return out, nil
+}
+
+// errUnknownAPIKey is returned for a key that matches no configured entry.
+var errUnknownAPIKey = errors.New("unknown API key")
+
+// VerifyAPIKey returns the identity of the configured API key equal to key.
+// Every entry is compared, in constant time, so the time taken does not
+// tell which key came close.
+func (v *Verifier) VerifyAPIKey(key string) (*Identity, error) {
+	sum := sha256.Sum256([]byte(key))
+	var found *apiKey
+	for i := range v.apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], v.apiKeys[i].sum[:]) == 1 {
+			found = &v.apiKeys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, errUnknownAPIKey)
+	}
+	return &Identity{Subject: "apikey:" + found.name, Role: found.role, Tenant: found.tenant}, nil
+}
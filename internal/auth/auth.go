@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Role is an API access level. Roles are ordered: every role implies the
+// permissions of the roles below it (admin > operator > viewer).
+type Role string
+
+const (
+	// RoleViewer may call read-only endpoints.
+	RoleViewer Role = "viewer"
+	// RoleOperator may additionally manage routing policies.
+	RoleOperator Role = "operator"
+	// RoleAdmin may additionally manage providers and sync/logging controls.
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+var (
+	// ErrMissingToken is returned when no bearer token was presented.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken is returned when a token cannot be parsed or its signature is wrong.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned when a token is outside its validity window.
+	ErrExpiredToken = errors.New("token expired or not yet valid")
+	// ErrNoRole is returned when a valid token maps to no known role.
+	ErrNoRole = errors.New("token grants no router-sync role")
+)
+
+// ParseRole converts a role name (case-insensitive) into a Role.
+func ParseRole(name string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := roleRank[r]; !ok {
+		return "", fmt.Errorf("unknown role %q (expected viewer, operator or admin)", name)
+	}
+	return r, nil
+}
+
+// Allows reports whether r grants at least the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required] && roleRank[r] > 0
+}
+
+// Identity is the authenticated caller extracted from a verified token.
+// Tenant is set for customers of a shared router: their role only applies
+// to that tenant's policies.
+type Identity struct {
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer,omitempty"`
+	Role    Role   `json:"role"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+// highestRole returns the strongest role among names, translating IdP group
+// names through roleMap first. Unknown names are ignored.
+func highestRole(names []string, roleMap map[string]string) Role {
+	var best Role
+	for _, name := range names {
+		if mapped, ok := roleMap[name]; ok {
+			name = mapped
+		}
+		r, err := ParseRole(name)
+		if err != nil {
+			continue
+		}
+		if roleRank[r] > roleRank[best] {
+			best = r
+		}
+	}
+	return best
+}
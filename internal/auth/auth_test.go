@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"router-sync/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestRoleAllows(t *testing.T) {
+	assert.True(t, RoleAdmin.Allows(RoleOperator))
+	assert.True(t, RoleOperator.Allows(RoleViewer))
+	assert.True(t, RoleViewer.Allows(RoleViewer))
+	assert.False(t, RoleViewer.Allows(RoleOperator))
+	assert.False(t, RoleOperator.Allows(RoleAdmin))
+	assert.False(t, Role("").Allows(RoleViewer))
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v, err := NewVerifier(config.AuthConfig{
+		HMACSecret: "s3cret",
+		Audience:   "router-sync",
+		RolesClaim: "groups",
+		RoleMap:    map[string]string{"netops": "operator"},
+	})
+	require.NoError(t, err)
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	tests := []struct {
+		name    string
+		token   string
+		want    Role
+		wantErr error
+	}{
+		{
+			name:  "mapped group",
+			token: signHS256(t, "s3cret", map[string]interface{}{"sub": "alice", "aud": "router-sync", "exp": exp, "groups": []string{"staff", "netops"}}),
+			want:  RoleOperator,
+		},
+		{
+			name:  "highest role wins",
+			token: signHS256(t, "s3cret", map[string]interface{}{"sub": "bob", "aud": []string{"other", "router-sync"}, "exp": exp, "groups": "viewer admin"}),
+			want:  RoleAdmin,
+		},
+		{
+			name:    "wrong secret",
+			token:   signHS256(t, "other", map[string]interface{}{"aud": "router-sync", "exp": exp, "groups": []string{"admin"}}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "expired",
+			token:   signHS256(t, "s3cret", map[string]interface{}{"aud": "router-sync", "exp": float64(time.Now().Add(-time.Hour).Unix()), "groups": []string{"admin"}}),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "wrong audience",
+			token:   signHS256(t, "s3cret", map[string]interface{}{"aud": "grafana", "exp": exp, "groups": []string{"admin"}}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "no role",
+			token:   signHS256(t, "s3cret", map[string]interface{}{"aud": "router-sync", "exp": exp, "groups": []string{"staff"}}),
+			wantErr: ErrNoRole,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := v.Verify(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, identity.Role)
+		})
+	}
+}
+
+func TestVerifyRS256WithOIDCDiscovery(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var issuer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	issuer = srv.URL
+
+	v, err := NewVerifier(config.AuthConfig{Issuer: issuer, RolesClaim: "roles"})
+	require.NoError(t, err)
+
+	token := signRS256(t, key, "k1", map[string]interface{}{
+		"iss":   issuer,
+		"sub":   "carol",
+		"exp":   float64(time.Now().Add(time.Hour).Unix()),
+		"roles": []string{"viewer"},
+	})
+	identity, err := v.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "carol", identity.Subject)
+	assert.Equal(t, RoleViewer, identity.Role)
+
+	// HS256 tokens must not be accepted when only an issuer is configured.
+	_, err = v.Verify(context.Background(), signHS256(t, "x", map[string]interface{}{"iss": issuer, "exp": float64(time.Now().Add(time.Hour).Unix()), "roles": []string{"admin"}}))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+// signES signs with key under alg, using the hash named by alg and encoding
+// r and s at the key's size, as a forger choosing the header would.
+func signES(t *testing.T, key *ecdsa.PrivateKey, alg string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": "ec"}) + "." + encodeSegment(t, claims)
+	var digest []byte
+	switch alg {
+	case "ES256":
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	case "ES384":
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	require.NoError(t, err)
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyECDSABindsAlgToCurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "ec",
+			"crv": "P-384",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 48))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 48))),
+		}}})
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(config.AuthConfig{JWKSURL: srv.URL})
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{"sub": "dave", "exp": float64(time.Now().Add(time.Hour).Unix()), "roles": []string{"viewer"}}
+	identity, err := v.Verify(context.Background(), signES(t, key, "ES384", claims))
+	require.NoError(t, err)
+	assert.Equal(t, "dave", identity.Subject)
+
+	// A valid P-384 signature over a SHA-256 digest must not pass as ES256.
+	_, err = v.Verify(context.Background(), signES(t, key, "ES256", claims))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewVerifierRejectsBadConfig(t *testing.T) {
+	_, err := NewVerifier(config.AuthConfig{})
+	assert.Error(t, err)
+
+	_, err = NewVerifier(config.AuthConfig{HMACSecret: "x", RoleMap: map[string]string{"g": "root"}})
+	assert.Error(t, err)
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	v, err := NewVerifier(config.AuthConfig{APIKeys: []config.APIKey{
+		{Name: "ci", Key: "0123456789abcdef", Role: "operator"},
+		{Name: "acme", Key: "fedcba9876543210", Role: "Viewer", Tenant: "acme"},
+	}})
+	require.NoError(t, err)
+
+	identity, err := v.VerifyAPIKey("0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "apikey:ci", Role: RoleOperator}, identity)
+
+	// Bearer tokens that are not JWTs are looked up as API keys
+	identity, err = v.Verify(context.Background(), "fedcba9876543210")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "apikey:acme", Role: RoleViewer, Tenant: "acme"}, identity)
+
+	_, err = v.VerifyAPIKey("0123456789abcdeX")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = v.Verify(context.Background(), "")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewVerifierRejectsBadAPIKeys(t *testing.T) {
+	for name, keys := range map[string][]config.APIKey{
+		"no name":        {{Key: "0123456789abcdef", Role: "viewer"}},
+		"short key":      {{Name: "a", Key: "short", Role: "viewer"}},
+		"unknown role":   {{Name: "a", Key: "0123456789abcdef", Role: "root"}},
+		"duplicate name": {{Name: "a", Key: "0123456789abcdef", Role: "viewer"}, {Name: "a", Key: "fedcba9876543210", Role: "viewer"}},
+		"duplicate key":  {{Name: "a", Key: "0123456789abcdef", Role: "viewer"}, {Name: "b", Key: "0123456789abcdef", Role: "admin"}},
+	} {
+		_, err := NewVerifier(config.AuthConfig{APIKeys: keys})
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+
+	"router-sync/internal/config"
+)
+
+// clockSkew is tolerated on exp/nbf/iat checks.
+const clockSkew = 30 * time.Second
+
+// Verifier validates bearer tokens (HS256 shared secret or RS/ES signatures
+// from an OIDC issuer's JWKS) and maps their claims to a Role. It also
+// accepts the static API keys of the configuration, see VerifyAPIKey.
+type Verifier struct {
+	cfg     config.AuthConfig
+	secret  []byte
+	keys    *keySet
+	apiKeys []apiKey
+	now     func() time.Time
+}
+
+// NewVerifier builds a Verifier from config. It does not contact the issuer;
+// signing keys are fetched lazily on first use.
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	if cfg.HMACSecret == "" && cfg.JWKSURL == "" && cfg.Issuer == "" && len(cfg.APIKeys) == 0 {
+		return nil, errors.New("auth enabled but none of hmac_secret, jwks_url, issuer or api_keys is set")
+	}
+	for group, role := range cfg.RoleMap {
+		if _, err := ParseRole(role); err != nil {
+			return nil, fmt.Errorf("role_map[%s]: %w", group, err)
+		}
+	}
+	apiKeys, err := parseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{cfg: cfg, apiKeys: apiKeys, now: time.Now}
+	if cfg.HMACSecret != "" {
+		v.secret = []byte(cfg.HMACSecret)
+	}
+	if cfg.JWKSURL != "" || cfg.Issuer != "" {
+		v.keys = newKeySet(cfg.Issuer, cfg.JWKSURL)
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the token signature and standard claims and returns the
+// caller identity. A token that is not a JWT is looked up as an API key.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		if len(v.apiKeys) > 0 {
+			return v.VerifyAPIKey(token)
+		}
+		return nil, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if err := v.verifySignature(ctx, header, signed, sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkStandardClaims(claims); err != nil {
+		return nil, err
+	}
+
+	role := highestRole(stringClaims(claims[v.rolesClaim()]), v.cfg.RoleMap)
+	if role == "" {
+		return nil, ErrNoRole
+	}
+	sub, _ := claims["sub"].(string)
+	iss, _ := claims["iss"].(string)
+	tenant, _ := claims[v.tenantClaim()].(string)
+	return &Identity{Subject: sub, Issuer: iss, Role: role, Tenant: tenant}, nil
+}
+
+func (v *Verifier) rolesClaim() string {
+	if v.cfg.RolesClaim != "" {
+		return v.cfg.RolesClaim
+	}
+	return "roles"
+}
+
+func (v *Verifier) tenantClaim() string {
+	if v.cfg.TenantClaim != "" {
+		return v.cfg.TenantClaim
+	}
+	return "tenant"
+}
+
+// ecdsaCurves maps each ECDSA alg to the only curve it may be used with.
+var ecdsaCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+func (v *Verifier) verifySignature(ctx context.Context, header jwtHeader, signed, sig []byte) error {
+	switch header.Alg {
+	case "HS256", "HS384", "HS512":
+		if v.secret == nil {
+			return fmt.Errorf("%w: %s not accepted", ErrInvalidToken, header.Alg)
+		}
+		mac := hmac.New(hashFor(header.Alg), v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512":
+		if v.keys == nil {
+			return fmt.Errorf("%w: %s not accepted", ErrInvalidToken, header.Alg)
+		}
+		key, err := v.keys.get(ctx, header.Kid)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+		h := hashFor(header.Alg)()
+		h.Write(signed)
+		digest := h.Sum(nil)
+		switch pub := key.(type) {
+		case *rsa.PublicKey:
+			if header.Alg[0] != 'R' || rsa.VerifyPKCS1v15(pub, cryptoHash(header.Alg), digest, sig) != nil {
+				return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+			}
+		case *ecdsa.PublicKey:
+			// Each ES alg is bound to one curve (RFC 7518 section 3.4), so
+			// a token cannot pick a weaker hash than the key implies.
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if ecdsaCurves[header.Alg] != pub.Curve.Params().Name || len(sig) != 2*size {
+				return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+			}
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if !ecdsa.Verify(pub, digest, r, s) {
+				return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported key type", ErrInvalidToken)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+}
+
+func (v *Verifier) checkStandardClaims(claims map[string]interface{}) error {
+	now := v.now()
+	exp, hasExp := numericClaim(claims["exp"])
+	if !hasExp || now.After(time.Unix(exp, 0).Add(clockSkew)) {
+		return ErrExpiredToken
+	}
+	if nbf, ok := numericClaim(claims["nbf"]); ok && now.Add(clockSkew).Before(time.Unix(nbf, 0)) {
+		return ErrExpiredToken
+	}
+	if v.cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(v.cfg.Issuer, "/") {
+			return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, iss)
+		}
+	}
+	if v.cfg.Audience != "" {
+		found := false
+		for _, aud := range stringClaims(claims["aud"]) {
+			if aud == v.cfg.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+		}
+	}
+	return nil
+}
+
+func decodeSegment(seg string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// stringClaims accepts a claim that is either a string (space or comma
+// separated, as some IdPs emit) or an array of strings.
+func stringClaims(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.FieldsFunc(val, func(r rune) bool { return r == ' ' || r == ',' })
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func numericClaim(v interface{}) (int64, bool) {
+	f, ok := v.(float64)
+	return int64(f), ok
+}
+
+func hashFor(alg string) func() hash.Hash {
+	switch alg[2:] {
+	case "384":
+		return sha512.New384
+	case "512":
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func cryptoHash(alg string) crypto.Hash {
+	switch alg[2:] {
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
@@ -0,0 +1,223 @@
+// Package backup reads and writes configuration backup archives.
+//
+// An archive is a gzipped tar holding config.json (a models.ConfigDocument),
+// webhooks.json and manifest.json. The manifest lists the SHA-256 of every
+// other file; when a signing key is configured it also carries an
+// HMAC-SHA256 of those checksums so a restore can prove the archive came from
+// a server holding the same key and was not edited.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+// FormatVersion is the manifest format written by Write.
+const FormatVersion = "router-sync-backup/v1"
+
+// Archive member names.
+const (
+	fileManifest = "manifest.json"
+	fileConfig   = "config.json"
+	fileWebhooks = "webhooks.json"
+)
+
+// maxMemberBytes caps any single archive member when reading.
+const maxMemberBytes = 16 << 20
+
+var (
+	// ErrUnsigned is returned by Read when a key is required but the archive has no signature.
+	ErrUnsigned = errors.New("archive is not signed")
+	// ErrBadSignature is returned by Read when the signature does not match.
+	ErrBadSignature = errors.New("archive signature does not match")
+)
+
+// Manifest describes an archive. Signature is empty for unsigned archives.
+type Manifest struct {
+	Format    string            `json:"format"`
+	CreatedAt time.Time         `json:"created_at"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Files     map[string]string `json:"files"`
+	Signature string            `json:"signature,omitempty"`
+}
+
+// Contents is what an archive carries.
+type Contents struct {
+	Manifest Manifest               `json:"manifest"`
+	Config   *models.ConfigDocument `json:"config"`
+	Webhooks []*models.Webhook      `json:"webhooks"`
+}
+
+// Write encodes contents as an archive to w, signing it when key is non-empty.
+// contents.Manifest supplies CreatedAt, CreatedBy and Version; Format, Files
+// and Signature are filled in.
+func Write(w io.Writer, contents *Contents, key string) error {
+	configData, err := json.MarshalIndent(contents.Config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	webhooks := contents.Webhooks
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+	webhookData, err := json.MarshalIndent(webhooks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode webhooks: %w", err)
+	}
+
+	manifest := contents.Manifest
+	manifest.Format = FormatVersion
+	manifest.Files = map[string]string{
+		fileConfig:   checksum(configData),
+		fileWebhooks: checksum(webhookData),
+	}
+	manifest.Signature = ""
+	if key != "" {
+		manifest.Signature = sign(key, manifest.Files)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{fileManifest, manifestData},
+		{fileConfig, configData},
+		{fileWebhooks, webhookData},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(f.data)), ModTime: manifest.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// Read decodes and verifies an archive. Checksums are always checked. With a
+// non-empty key the archive must carry a matching signature; without one a
+// signature, if present, cannot be checked and is ignored.
+func Read(r io.Reader, key string) (*Contents, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxMemberBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		if len(data) > maxMemberBytes {
+			return nil, fmt.Errorf("%s exceeds %d bytes", hdr.Name, maxMemberBytes)
+		}
+		files[hdr.Name] = data
+	}
+
+	var manifest Manifest
+	raw, ok := files[fileManifest]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", fileManifest)
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", fileManifest, err)
+	}
+	if manifest.Format != FormatVersion {
+		return nil, fmt.Errorf("unsupported backup format %q (expected %s)", manifest.Format, FormatVersion)
+	}
+
+	for _, name := range []string{fileConfig, fileWebhooks} {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("archive has no %s", name)
+		}
+		if want := manifest.Files[name]; want == "" || checksum(data) != want {
+			return nil, fmt.Errorf("%s does not match its checksum in the manifest", name)
+		}
+	}
+	if key != "" {
+		if manifest.Signature == "" {
+			return nil, ErrUnsigned
+		}
+		if !hmac.Equal([]byte(sign(key, manifest.Files)), []byte(manifest.Signature)) {
+			return nil, ErrBadSignature
+		}
+	}
+
+	contents := &Contents{Manifest: manifest}
+	if err := json.Unmarshal(files[fileConfig], &contents.Config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", fileConfig, err)
+	}
+	if contents.Config == nil {
+		return nil, fmt.Errorf("%s is empty", fileConfig)
+	}
+	if err := json.Unmarshal(files[fileWebhooks], &contents.Webhooks); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", fileWebhooks, err)
+	}
+	return contents, nil
+}
+
+// Encode is Write into a byte slice.
+func Encode(contents *Contents, key string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, contents, key); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign MACs the file checksums in name order.
+func sign(key string, files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mac := hmac.New(sha256.New, []byte(key))
+	for _, name := range names {
+		mac.Write([]byte(name + "\x00" + files[name] + "\n"))
+	}
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+func testContents() *Contents {
+	return &Contents{
+		Manifest: Manifest{CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), CreatedBy: "alice"},
+		Config: &models.ConfigDocument{
+			APIVersion: models.DocumentVersion,
+			Providers:  []*models.InternetProvider{{ID: "Telecom", Name: "Telecom", TableID: 100}},
+			Policies:   []*models.RoutingPolicy{{ID: "192.168.2.25", ProviderID: "Telecom"}},
+		},
+		Webhooks: []*models.Webhook{{ID: "h1", URL: "https://example.com/hook", Secret: "s"}},
+	}
+}
+
+func TestRoundTrip_Signed(t *testing.T) {
+	data, err := Encode(testContents(), "k1")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	got, err := Read(bytes.NewReader(data), "k1")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got.Manifest.CreatedBy != "alice" || got.Manifest.Signature == "" {
+		t.Errorf("manifest = %+v", got.Manifest)
+	}
+	if len(got.Config.Providers) != 1 || len(got.Config.Policies) != 1 || len(got.Webhooks) != 1 {
+		t.Errorf("contents = %+v", got)
+	}
+	if got.Webhooks[0].Secret != "s" {
+		t.Error("webhook secret was not preserved")
+	}
+
+	if _, err := Read(bytes.NewReader(data), "k2"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Read() with another key error = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestRead_UnsignedNeedsNoKey(t *testing.T) {
+	data, err := Encode(testContents(), "")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := Read(bytes.NewReader(data), ""); err != nil {
+		t.Errorf("Read() without key error = %v", err)
+	}
+	if _, err := Read(bytes.NewReader(data), "k1"); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Read() with key error = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestRead_DetectsTampering(t *testing.T) {
+	data, err := Encode(testContents(), "k1")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Rewrite config.json, keeping the original manifest.
+	gz, _ := gzip.NewReader(bytes.NewReader(data))
+	tr := tar.NewReader(gz)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		body, _ := io.ReadAll(tr)
+		if hdr.Name == fileConfig {
+			body = bytes.Replace(body, []byte("Telecom"), []byte("Evilcom"), 1)
+			hdr.Size = int64(len(body))
+		}
+		_ = tw.WriteHeader(hdr)
+		_, _ = tw.Write(body)
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+
+	if _, err := Read(&out, "k1"); err == nil {
+		t.Fatal("Read() accepted an archive whose config.json was edited")
+	}
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,35 +20,329 @@ type Mode string
 const (
 	// ModeAPI runs only the HTTP API on the configured address.
 	ModeAPI Mode = "api"
+	// ModeController is the management plane: the HTTP API over the NATS
+	// store, with no kernel changes. It is the same role as ModeAPI under the
+	// name used for central deployments.
+	ModeController Mode = "controller"
 	// ModeAgent runs the router-local agent (NET_ADMIN) that applies policies
 	// and reports state back to NATS.
 	ModeAgent Mode = "agent"
 )
 
-// Config represents the application configuration
+// Config represents the application configuration. LogLevel is a level name
+// (trace, debug, info, warn, error, fatal, panic); LogLevels overrides it for
+// single components, e.g. {router: debug, nats: info} (see
+// logging.SetComponentLevels). LogFormat is "text" (default) or "json" for
+// one JSON object per line (see logging.SetFormat). Repeats of a log line
+// within LogDedupWindow (default 1m; negative turns it off) are dropped and
+// counted on the next copy (see logging.SetDedupWindow). Version is the layout
+// version of the file; older layouts are migrated on load (see
+// CurrentVersion).
 type Config struct {
-	Mode     Mode         `yaml:"mode"`
-	LogLevel logrus.Level `yaml:"log_level"`
-	NATS     NATSConfig   `yaml:"nats"`
-	API      APIConfig    `yaml:"api"`
-	Sync     SyncConfig   `yaml:"sync"`
-	Agent    AgentConfig  `yaml:"agent"`
+	Version        int               `yaml:"version"`
+	Mode           Mode              `yaml:"mode"`
+	LogLevel       logrus.Level      `yaml:"log_level"`
+	LogLevels      map[string]string `yaml:"log_levels"`
+	LogFormat      string            `yaml:"log_format"`
+	LogDedupWindow time.Duration     `yaml:"log_dedup_window"`
+	LogFile        LogFileConfig     `yaml:"log_file"`
+	NATS           NATSConfig        `yaml:"nats"`
+	API            APIConfig         `yaml:"api"`
+	Sync           SyncConfig        `yaml:"sync"`
+	Agent          AgentConfig       `yaml:"agent"`
+	Router         RouterConfig      `yaml:"router"`
+	Profiling      ProfilingConfig   `yaml:"profiling"`
+	Metrics        MetricsConfig     `yaml:"metrics"`
+	Features       FeaturesConfig    `yaml:"features"`
 }
 
-// NATSConfig represents NATS connection configuration
+// DefaultMetricsNamespace prefixes every exported metric unless
+// metrics.namespace says otherwise.
+const DefaultMetricsNamespace = "router_sync"
+
+// MetricsConfig names the Prometheus metrics of the API and the agent. Every
+// metric except the Go runtime and process ones is prefixed with Namespace
+// and "_" (e.g. router_sync_http_requests_total) and carries the labels
+// node (the agent hostname, or the API host name) and version, plus
+// ConstLabels for site- or fleet-wide tags. ConstLabels must not reuse
+// node, version or a label of a metric (such as provider or table).
+type MetricsConfig struct {
+	Namespace   string            `yaml:"namespace"`
+	ConstLabels map[string]string `yaml:"const_labels"`
+}
+
+// FeaturesConfig gates subsystems that are still being rolled out. All are
+// off by default; a disabled feature starts none of its goroutines, watchers
+// or kernel objects, so it can be enabled router by router.
+//
+//   - Failover: health-driven provider failover in the agent.
+//   - NFTables: nftables-based matching (protocols, ports, sets, counters).
+//   - IPv6: IPv6 policies and provider routes.
+//   - GitOps: reconciling the store from a Git repository.
+type FeaturesConfig struct {
+	Failover bool `yaml:"failover"`
+	NFTables bool `yaml:"nftables"`
+	IPv6     bool `yaml:"ipv6"`
+	GitOps   bool `yaml:"gitops"`
+}
+
+// Enabled lists the enabled features by config key, for logs and status.
+func (f FeaturesConfig) Enabled() []string {
+	out := []string{}
+	for _, feature := range []struct {
+		name string
+		on   bool
+	}{
+		{"failover", f.Failover},
+		{"nftables", f.NFTables},
+		{"ipv6", f.IPv6},
+		{"gitops", f.GitOps},
+	} {
+		if feature.on {
+			out = append(out, feature.name)
+		}
+	}
+	return out
+}
+
+// ProfilingConfig turns on runtime profiling for field diagnosis. Address
+// (e.g. "127.0.0.1:6060") serves net/http/pprof on its own listener, without
+// auth, so bind it to localhost or a management network. BlockProfileRate and
+// MutexProfileFraction enable those profiles (see runtime.SetBlockProfileRate
+// and runtime.SetMutexProfileFraction; 0 leaves them off). With DumpDir and
+// DumpInterval set, heap and goroutine profiles are written there every
+// interval and only the newest DumpKeep of each are kept.
+//
+// AdminListener serves the same /debug/pprof/ endpoints on the API's admin
+// listener (api.admin_address, required) instead of, or as well as, a
+// dedicated one; there they use the API's TLS and require the admin role
+// when auth is enabled.
+type ProfilingConfig struct {
+	Address              string        `yaml:"address"`
+	AdminListener        bool          `yaml:"admin_listener"`
+	BlockProfileRate     int           `yaml:"block_profile_rate"`
+	MutexProfileFraction int           `yaml:"mutex_profile_fraction"`
+	DumpDir              string        `yaml:"dump_dir"`
+	DumpInterval         time.Duration `yaml:"dump_interval"`
+	DumpKeep             int           `yaml:"dump_keep"`
+}
+
+// RouterConfig sets the kernel number spaces router-sync owns. Policy rules
+// use priorities PriorityMin + (32 - prefix length), so the priority range
+// must hold at least 33 values and stay clear of the suppress-default rule
+// (10) and the kernel's main/default rules (32766, 32767). Providers may only
+// use tables in TableMin..TableMax (253-255 are always refused), and routes
+// installed by the agent are tagged with RouteProtocol. Every API and agent
+// must share these values; they are checked at startup (models.SetRanges).
+//
+// DisableConntrack (agent only) turns off every conntrack operation: policy
+// changes no longer flush the affected flows, and the conntrack list/flush
+// endpoints report an error for this router.
+//
+// SourceSets (agent only, needs features.nftables) routes plain source
+// policies through nftables sets instead of one ip rule each: their sources
+// are loaded into one set per rule priority and provider table, which marks
+// packets for a shared fwmark rule. For thousands of source prefixes this
+// keeps the rule count small and a sync down to one nft transaction.
+// Policies with a destination, protocol or ports, and strict ones, are
+// unaffected.
+//
+// DryRun (agent only) never changes the kernel: the agent syncs as usual but
+// only logs each rule, route and conntrack change it would have made. It is
+// meant for trying a new agent or configuration next to the routing it would
+// take over.
+type RouterConfig struct {
+	PriorityMin   int `yaml:"priority_min"`
+	PriorityMax   int `yaml:"priority_max"`
+	TableMin      int `yaml:"table_min"`
+	TableMax      int `yaml:"table_max"`
+	RouteProtocol int `yaml:"route_protocol"`
+
+	DisableConntrack bool `yaml:"disable_conntrack"`
+	SourceSets       bool `yaml:"source_sets"`
+	DryRun           bool `yaml:"dry_run"`
+}
+
+// LogFileConfig sends logs to a file instead of stderr, for hosts without
+// journald. The file is rotated once it exceeds MaxSizeMB; at most MaxBackups
+// rotated files are kept and files older than MaxAge are removed (0 keeps
+// them regardless of age). An empty Path keeps logging on stderr.
+type LogFileConfig struct {
+	Path       string        `yaml:"path"`
+	MaxSizeMB  int           `yaml:"max_size_mb"`
+	MaxBackups int           `yaml:"max_backups"`
+	MaxAge     time.Duration `yaml:"max_age"`
+}
+
+// NATSConfig represents NATS connection configuration.
+//
+// Password and Token may instead be read from a file (PasswordFile,
+// TokenFile; e.g. a mounted Kubernetes secret or
+// "${CREDENTIALS_DIRECTORY}/nats-password" for systemd credentials) or from a
+// named environment variable (PasswordEnv, TokenEnv). Only one source may be
+// set for each; setting several, or a file or variable that cannot be read,
+// fails Load.
 type NATSConfig struct {
-	URLs      []string `yaml:"urls"`
-	Username  string   `yaml:"username"`
-	Password  string   `yaml:"password"`
-	Token     string   `yaml:"token"`
-	ClusterID string   `yaml:"cluster_id"`
-	ClientID  string   `yaml:"client_id"`
-	WriterID  string   `yaml:"writer_id"`
+	URLs         []string `yaml:"urls"`
+	Username     string   `yaml:"username"`
+	Password     string   `yaml:"password"`
+	PasswordFile string   `yaml:"password_file"`
+	PasswordEnv  string   `yaml:"password_env"`
+	Token        string   `yaml:"token"`
+	TokenFile    string   `yaml:"token_file"`
+	TokenEnv     string   `yaml:"token_env"`
+	ClusterID    string   `yaml:"cluster_id"`
+	ClientID     string   `yaml:"client_id"`
+	WriterID     string   `yaml:"writer_id"`
 }
 
-// APIConfig represents API server configuration
+// APIConfig represents API server configuration.
+//
+// StatsInterval is how often /api/v1/stats and the inventory gauges
+// (providers_total, policies_total, routers_known, router_state_age_seconds)
+// are recomputed from NATS. DisableSwagger removes /swagger (UI and spec),
+// e.g. for production deployments that should not advertise the API.
+//
+// AdminAddress, when set, moves /metrics, /swagger, POST /sync and the
+// /admin/* endpoints to a second listener (e.g. "127.0.0.1:18081") so they
+// are not reachable on Address. It uses the same TLS and auth settings.
+//
+// RequireIfMatch makes PUT on providers, policies and groups fail with 428
+// unless the request carries an If-Match header with the ETag from a GET.
+// Off by default, so unconditional PUTs keep working; If-Match is always
+// honored (strong comparison) when sent.
+//
+// BackupSigningKey signs archives from POST /api/v1/backup (HMAC-SHA256) and
+// makes POST /api/v1/restore reject archives without a matching signature.
+// Without it archives only carry checksums.
+//
+// GinMode is "release" (default), "debug" (logs every route and request at
+// startup) or "test". The timeouts and MaxHeaderBytes are applied to both
+// listeners; ReadHeaderTimeout and ReadTimeout bound slow clients
+// (slowloris). WriteTimeout defaults to 0 because it also cuts off the
+// long-lived /stream responses; set it only if nothing uses the event
+// stream.
+//
+// Policies past their expires_at are handled every PolicyExpiryInterval:
+// PolicyExpiryAction "disable" (default) keeps them, disabled, for reuse;
+// "delete" removes them. Either way the change is audited with the actor
+// "system".
+//
+// MetricsLabels lists policy label keys exported by the policies_by_label
+// gauge (one series per key, value and enabled state). Keep it to
+// low-cardinality keys such as "team".
 type APIConfig struct {
-	Address string `yaml:"address"`
+	Address        string        `yaml:"address"`
+	AdminAddress   string        `yaml:"admin_address"`
+	Auth           AuthConfig    `yaml:"auth"`
+	TLS            TLSConfig     `yaml:"tls"`
+	CORS           CORSConfig    `yaml:"cors"`
+	StatsInterval  time.Duration `yaml:"stats_interval"`
+	DisableSwagger bool          `yaml:"disable_swagger"`
+	RequireIfMatch bool          `yaml:"require_if_match"`
+
+	BackupSigningKey string `yaml:"backup_signing_key"`
+
+	GinMode           string        `yaml:"gin_mode"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+
+	PolicyExpiryAction   string        `yaml:"policy_expiry_action"`
+	PolicyExpiryInterval time.Duration `yaml:"policy_expiry_interval"`
+
+	MetricsLabels []string `yaml:"metrics_labels"`
+
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig is a webhook subscription declared in the config file
+// instead of through the API: events whose type is in Events (every event
+// when empty) are POSTed to URL, signed with Secret when set. The API
+// delivers them next to the stored subscriptions, as "config:<name>".
+type WebhookConfig struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Events []string `yaml:"events"`
+	Secret string   `yaml:"secret"`
+}
+
+// Expired policy handling (APIConfig.PolicyExpiryAction).
+const (
+	ExpiryDisable = "disable"
+	ExpiryDelete  = "delete"
+)
+
+// CORSConfig controls which browser origins may call the API directly.
+//
+// AllowedOrigins holds exact origins ("https://dash.example.com"), wildcard
+// subdomains ("https://*.example.com") or "*". When unset every origin is
+// allowed, as before; an explicit empty list disables CORS headers entirely.
+// AllowCredentials lets browsers send cookies/client certs and cannot be
+// combined with "*".
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// TLSConfig enables HTTPS on the API listener when CertFile and KeyFile are set.
+//
+// ClientCAFile turns on mTLS: client certificates must chain to one of its CAs.
+// ClientAuth selects how strictly: "require" (default when a CA is given) or
+// "optional" (verify only if presented, e.g. while rolling out client certs).
+// MinVersion is "1.2" (default) or "1.3".
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+	MinVersion   string `yaml:"min_version"`
+}
+
+// Enabled reports whether the API should serve HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// AuthConfig controls bearer-token authentication on the API.
+//
+// Tokens are either HS256/384/512 signed with HMACSecret or RS/ES signed by an
+// OIDC issuer (keys from JWKSURL, or discovered from Issuer). RolesClaim names
+// the claim holding the caller's roles (viewer, operator, admin); RoleMap
+// translates IdP group names into those roles. TenantClaim names the claim
+// holding the caller's tenant; a token carrying it is confined to that
+// tenant's policies.
+//
+// APIKeys are static credentials for scripts and automation that cannot get
+// tokens from an IdP; they may be the only credentials configured.
+type AuthConfig struct {
+	Enabled    bool              `yaml:"enabled"`
+	Issuer     string            `yaml:"issuer"`
+	Audience   string            `yaml:"audience"`
+	JWKSURL    string            `yaml:"jwks_url"`
+	HMACSecret string            `yaml:"hmac_secret"`
+	RolesClaim string            `yaml:"roles_claim"`
+	RoleMap    map[string]string `yaml:"role_map"`
+	// TenantClaim defaults to "tenant".
+	TenantClaim string   `yaml:"tenant_claim"`
+	APIKeys     []APIKey `yaml:"api_keys"`
+}
+
+// APIKey is one static API credential, sent as "X-API-Key: <key>" or as a
+// bearer token. The caller is identified as "apikey:<name>" with Role
+// (viewer, operator or admin) and, when Tenant is set, confined to that
+// tenant's policies. Keys must be at least 16 characters.
+type APIKey struct {
+	Name   string `yaml:"name"`
+	Key    string `yaml:"key"`
+	Role   string `yaml:"role"`
+	Tenant string `yaml:"tenant,omitempty"`
 }
 
 // SyncConfig represents synchronization configuration
@@ -56,44 +355,303 @@ type SyncConfig struct {
 // Hostname identifies this agent inside NATS (defaults to os.Hostname()).
 // MetricsAddress is the listener for /health and /metrics on the agent.
 // StatePublishInterval is how often the agent publishes RouterState to NATS.
+//
+// OnStart decides what happens to rules already in the managed range when
+// the agent starts: "adopt" (default) keeps the ones that match a policy and
+// lets the first sync remove the rest, so a restart does not touch live
+// traffic; "purge" removes every managed rule before the first sync.
+// OnShutdown is "cleanup" (default, remove every managed rule and the
+// suppress-default rule) or "keep" (leave them, so routing continues while
+// the agent is restarted or upgraded).
+//
+// FRR mirrors provider health into the local FRR daemon, see FRRConfig.
 type AgentConfig struct {
 	Hostname             string        `yaml:"hostname"`
 	MetricsAddress       string        `yaml:"metrics_address"`
 	StatePublishInterval time.Duration `yaml:"state_publish_interval"`
+	OnStart              string        `yaml:"on_start"`
+	OnShutdown           string        `yaml:"on_shutdown"`
+	FRR                  FRRConfig     `yaml:"frr"`
+	// DelegatedPrefixes (needs features.ipv6) tells the agent where to
+	// find the IPv6 prefix each provider delegates, keyed by provider ID,
+	// for policies with a delegated_source.
+	DelegatedPrefixes map[string]DelegatedPrefixConfig `yaml:"delegated_prefixes"`
+}
+
+// DelegatedPrefixConfig locates a provider's delegated IPv6 prefix: the
+// agent takes the global address on Interface that the DHCPv6-PD client or
+// router advertisements assigned from it, and masks it to Length (the
+// delegated prefix length, e.g. 56). Deprecated addresses are ignored, so a
+// renumbered prefix takes over as soon as the old one's preferred lifetime
+// ends.
+type DelegatedPrefixConfig struct {
+	Interface string `yaml:"interface"`
+	Length    int    `yaml:"length"`
 }
 
+// FRRConfig (agent only) signals provider health to dynamic routing: when a
+// provider listed in Providers goes down on this router its Down lines are
+// run through vtysh, and its Up lines when it recovers, e.g. to withdraw a
+// default route from BGP or lower its local-preference. Health comes from
+// the provider's health check, so it needs features.failover; providers
+// without a health check are never signalled.
+//
+// The lines are vtysh configuration commands, run in order after "configure
+// terminal" in one vtysh call. They may use the placeholders {provider},
+// {name}, {hostname}, {interface}, {gateway} and {table}. VtyshPath defaults
+// to "vtysh" and Timeout (per call) to 10s.
+type FRRConfig struct {
+	Enabled   bool                         `yaml:"enabled"`
+	VtyshPath string                       `yaml:"vtysh_path"`
+	Timeout   time.Duration                `yaml:"timeout"`
+	Providers map[string]FRRProviderConfig `yaml:"providers"`
+}
+
+// FRRProviderConfig holds the vtysh lines for one provider's transitions.
+type FRRProviderConfig struct {
+	Down []string `yaml:"down"`
+	Up   []string `yaml:"up"`
+}
+
+// Agent startup and shutdown rule handling (AgentConfig.OnStart/OnShutdown).
+const (
+	StartAdopt      = "adopt"
+	StartPurge      = "purge"
+	ShutdownCleanup = "cleanup"
+	ShutdownKeep    = "keep"
+)
+
 // Load loads configuration from file and applies environment overrides.
 //
+// path may also be a conf.d-style directory: every *.yaml / *.yml file in it
+// (hidden files skipped) is decoded in lexical order into the same Config,
+// so later fragments override the keys they set, maps are merged and lists
+// are replaced. E.g. 10-nats.yaml, 20-api.yaml, 90-local.yaml.
+//
 // Environment variables (optional):
-//   - ROUTER_SYNC_MODE                  (api|agent)
+//   - ROUTER_SYNC_MODE                  (controller|api|agent)
 //   - ROUTER_SYNC_LOG_LEVEL
+//   - ROUTER_SYNC_LOG_LEVELS            (component=level, comma-separated)
+//   - ROUTER_SYNC_LOG_FORMAT            (text|json)
+//   - ROUTER_SYNC_LOG_DEDUP_WINDOW      (Go duration; negative disables)
+//   - ROUTER_SYNC_LOG_FILE              (path; rotation settings are file-only)
 //   - ROUTER_SYNC_API_ADDRESS
+//   - ROUTER_SYNC_API_ADMIN_ADDRESS
+//   - ROUTER_SYNC_API_AUTH_ENABLED      (true|false)
+//   - ROUTER_SYNC_API_AUTH_ISSUER
+//   - ROUTER_SYNC_API_AUTH_AUDIENCE
+//   - ROUTER_SYNC_API_AUTH_JWKS_URL
+//   - ROUTER_SYNC_API_AUTH_HMAC_SECRET
+//   - ROUTER_SYNC_API_TLS_CERT_FILE
+//   - ROUTER_SYNC_API_TLS_KEY_FILE
+//   - ROUTER_SYNC_API_TLS_CLIENT_CA_FILE
+//   - ROUTER_SYNC_API_CORS_ALLOWED_ORIGINS (comma-separated)
+//   - ROUTER_SYNC_API_STATS_INTERVAL    (Go duration: 15s, 1m...)
+//   - ROUTER_SYNC_API_DISABLE_SWAGGER   (true|false)
+//   - ROUTER_SYNC_API_REQUIRE_IF_MATCH  (true|false)
+//   - ROUTER_SYNC_API_BACKUP_SIGNING_KEY
+//   - ROUTER_SYNC_API_POLICY_EXPIRY_ACTION (disable|delete)
+//   - ROUTER_SYNC_API_GIN_MODE          (release|debug|test)
+//   - ROUTER_SYNC_API_READ_TIMEOUT      (Go duration)
+//   - ROUTER_SYNC_API_READ_HEADER_TIMEOUT (Go duration)
+//   - ROUTER_SYNC_API_WRITE_TIMEOUT     (Go duration; 0 disables)
+//   - ROUTER_SYNC_API_IDLE_TIMEOUT      (Go duration)
 //   - ROUTER_SYNC_AGENT_HOSTNAME
 //   - ROUTER_SYNC_AGENT_METRICS_ADDRESS
 //   - ROUTER_SYNC_AGENT_STATE_INTERVAL  (Go duration: 5s, 1m...)
+//   - ROUTER_SYNC_AGENT_ON_START        (adopt|purge)
+//   - ROUTER_SYNC_AGENT_ON_SHUTDOWN     (cleanup|keep)
+//   - ROUTER_SYNC_ROUTER_DISABLE_CONNTRACK (true|false)
+//   - ROUTER_SYNC_ROUTER_SOURCE_SETS    (true|false)
+//   - ROUTER_SYNC_ROUTER_DRY_RUN        (true|false)
+//   - ROUTER_SYNC_PROFILING_ADDRESS
+//   - ROUTER_SYNC_PROFILING_ADMIN_LISTENER (true|false)
+//   - ROUTER_SYNC_FEATURES              (comma-separated features to enable)
 //   - ROUTER_SYNC_NATS_URL              (comma-separated for multiple URLs)
 //   - ROUTER_SYNC_NATS_USERNAME
 //   - ROUTER_SYNC_NATS_PASSWORD
 //   - ROUTER_SYNC_NATS_TOKEN
+//   - ROUTER_SYNC_NATS_PASSWORD_FILE
+//   - ROUTER_SYNC_NATS_TOKEN_FILE
 //   - ROUTER_SYNC_NATS_CLIENT_ID
 //   - ROUTER_SYNC_WRITER_ID
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	files, err := configFiles(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if isSOPS(data) {
+			if data, err = decryptSOPS(file); err != nil {
+				return nil, err
+			}
+		}
+		if data, err = migrate(file, data); err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
 	}
+	config.Version = CurrentVersion
 
 	applyDefaults(&config)
 	applyEnvOverrides(&config)
+	if err := resolveSecrets(&config); err != nil {
+		return nil, err
+	}
+	if err := validate(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
 
+// validate rejects settings that defaults cannot fix.
+func validate(config *Config) error {
+	switch config.Agent.OnStart {
+	case StartAdopt, StartPurge:
+	default:
+		return fmt.Errorf("invalid agent.on_start %q (expected %s or %s)", config.Agent.OnStart, StartAdopt, StartPurge)
+	}
+	switch config.Agent.OnShutdown {
+	case ShutdownCleanup, ShutdownKeep:
+	default:
+		return fmt.Errorf("invalid agent.on_shutdown %q (expected %s or %s)", config.Agent.OnShutdown, ShutdownCleanup, ShutdownKeep)
+	}
+	switch config.API.PolicyExpiryAction {
+	case ExpiryDisable, ExpiryDelete:
+	default:
+		return fmt.Errorf("invalid api.policy_expiry_action %q (expected %s or %s)", config.API.PolicyExpiryAction, ExpiryDisable, ExpiryDelete)
+	}
+	if config.Agent.FRR.Enabled {
+		for id, p := range config.Agent.FRR.Providers {
+			if len(p.Down) == 0 && len(p.Up) == 0 {
+				return fmt.Errorf("agent.frr.providers.%s has neither down nor up lines", id)
+			}
+		}
+	}
+	if len(config.Agent.DelegatedPrefixes) > 0 && !config.Features.IPv6 {
+		return fmt.Errorf("agent.delegated_prefixes needs features.ipv6")
+	}
+	for id, p := range config.Agent.DelegatedPrefixes {
+		if p.Interface == "" {
+			return fmt.Errorf("agent.delegated_prefixes.%s.interface is required", id)
+		}
+		if p.Length < 1 || p.Length > 128 {
+			return fmt.Errorf("invalid agent.delegated_prefixes.%s.length %d (expected 1-128)", id, p.Length)
+		}
+	}
+	if config.Router.SourceSets && !config.Features.NFTables {
+		return fmt.Errorf("router.source_sets needs features.nftables")
+	}
+	if config.API.PolicyExpiryInterval < 0 {
+		return fmt.Errorf("invalid api.policy_expiry_interval %s", config.API.PolicyExpiryInterval)
+	}
+	if !metricNameRE.MatchString(config.Metrics.Namespace) {
+		return fmt.Errorf("invalid metrics.namespace %q", config.Metrics.Namespace)
+	}
+	for name := range config.Metrics.ConstLabels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid metrics.const_labels name %q", name)
+		}
+		if name == "node" || name == "version" {
+			return fmt.Errorf("metrics.const_labels must not set %q, it is added automatically", name)
+		}
+	}
+	return nil
+}
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// configFiles returns path itself, or the YAML fragments of a directory in
+// lexical order.
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path) // sorted by name
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.yaml or *.yml files in config directory %s", path)
+	}
+	return files, nil
+}
+
+// resolveSecrets fills NATS credentials from their _file / _env sources.
+func resolveSecrets(config *Config) error {
+	password, err := readSecret(config.NATS.Password, config.NATS.PasswordFile, config.NATS.PasswordEnv)
+	if err != nil {
+		return fmt.Errorf("nats password: %w", err)
+	}
+	config.NATS.Password = password
+
+	token, err := readSecret(config.NATS.Token, config.NATS.TokenFile, config.NATS.TokenEnv)
+	if err != nil {
+		return fmt.Errorf("nats token: %w", err)
+	}
+	config.NATS.Token = token
+	return nil
+}
+
+// readSecret returns value, the contents of file (environment variables in
+// the path are expanded; one trailing newline is dropped) or the environment
+// variable named env, whichever is set. Setting more than one is an error, so
+// a leftover inline value cannot silently shadow a mounted secret.
+func readSecret(value, file, env string) (string, error) {
+	var set []string
+	for _, s := range []struct{ name, v string }{{"value", value}, {"file", file}, {"env", env}} {
+		if s.v != "" {
+			set = append(set, s.name)
+		}
+	}
+	if len(set) > 1 {
+		return "", fmt.Errorf("only one of the inline value, _file and _env may be set (found %s)", strings.Join(set, ", "))
+	}
+
+	switch {
+	case file != "":
+		data, err := os.ReadFile(os.ExpandEnv(file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	case env != "":
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
 func applyDefaults(config *Config) {
 	if config.Mode == "" {
 		config.Mode = ModeAPI
@@ -101,12 +659,66 @@ func applyDefaults(config *Config) {
 	if config.API.Address == "" {
 		config.API.Address = ":18080"
 	}
+	if config.API.CORS.AllowedOrigins == nil {
+		config.API.CORS.AllowedOrigins = []string{"*"}
+	}
+	if len(config.API.CORS.AllowedMethods) == 0 {
+		config.API.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	}
+	if len(config.API.CORS.AllowedHeaders) == 0 {
+		config.API.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "Idempotency-Key", "If-Match"}
+	}
+	if len(config.API.CORS.ExposedHeaders) == 0 {
+		config.API.CORS.ExposedHeaders = []string{"X-Request-ID", "Idempotent-Replayed", "ETag"}
+	}
+	if config.API.Auth.RolesClaim == "" {
+		config.API.Auth.RolesClaim = "roles"
+	}
+	if config.API.Auth.TenantClaim == "" {
+		config.API.Auth.TenantClaim = "tenant"
+	}
+	if config.API.StatsInterval == 0 {
+		config.API.StatsInterval = 15 * time.Second
+	}
+	if config.API.GinMode == "" {
+		config.API.GinMode = "release"
+	}
+	if config.API.ReadTimeout == 0 {
+		config.API.ReadTimeout = 30 * time.Second
+	}
+	if config.API.ReadHeaderTimeout == 0 {
+		config.API.ReadHeaderTimeout = 10 * time.Second
+	}
+	if config.API.IdleTimeout == 0 {
+		config.API.IdleTimeout = 120 * time.Second
+	}
+	if config.API.MaxHeaderBytes == 0 {
+		config.API.MaxHeaderBytes = 64 << 10
+	}
+	if config.API.PolicyExpiryAction == "" {
+		config.API.PolicyExpiryAction = ExpiryDisable
+	}
+	if config.API.PolicyExpiryInterval == 0 {
+		config.API.PolicyExpiryInterval = 30 * time.Second
+	}
 	if config.Sync.Interval == 0 {
 		config.Sync.Interval = 30 * time.Second
 	}
 	if config.LogLevel == 0 {
 		config.LogLevel = logrus.WarnLevel
 	}
+	if config.LogFormat == "" {
+		config.LogFormat = "text"
+	}
+	if config.LogDedupWindow == 0 {
+		config.LogDedupWindow = time.Minute
+	}
+	if config.LogFile.MaxSizeMB == 0 {
+		config.LogFile.MaxSizeMB = 100
+	}
+	if config.LogFile.MaxBackups == 0 {
+		config.LogFile.MaxBackups = 5
+	}
 	if config.NATS.ClientID == "" {
 		config.NATS.ClientID = "router-sync-client"
 	}
@@ -116,9 +728,44 @@ func applyDefaults(config *Config) {
 	if config.Agent.MetricsAddress == "" {
 		config.Agent.MetricsAddress = ":18082"
 	}
+	if config.Router.PriorityMin == 0 {
+		config.Router.PriorityMin = 2000
+	}
+	if config.Router.PriorityMax == 0 {
+		config.Router.PriorityMax = config.Router.PriorityMin + 32
+	}
+	if config.Router.TableMin == 0 {
+		config.Router.TableMin = 1
+	}
+	if config.Router.TableMax == 0 {
+		// The kernel's 32-bit table IDs, as far as an int holds them
+		// (models.MaxTableID)
+		config.Router.TableMax = min(math.MaxUint32, math.MaxInt)
+	}
+	if config.Router.RouteProtocol == 0 {
+		config.Router.RouteProtocol = 241
+	}
 	if config.Agent.StatePublishInterval == 0 {
 		config.Agent.StatePublishInterval = 5 * time.Second
 	}
+	if config.Profiling.DumpKeep == 0 {
+		config.Profiling.DumpKeep = 24
+	}
+	if config.Metrics.Namespace == "" {
+		config.Metrics.Namespace = DefaultMetricsNamespace
+	}
+	if config.Agent.OnStart == "" {
+		config.Agent.OnStart = StartAdopt
+	}
+	if config.Agent.OnShutdown == "" {
+		config.Agent.OnShutdown = ShutdownCleanup
+	}
+	if config.Agent.FRR.VtyshPath == "" {
+		config.Agent.FRR.VtyshPath = "vtysh"
+	}
+	if config.Agent.FRR.Timeout == 0 {
+		config.Agent.FRR.Timeout = 10 * time.Second
+	}
 	if config.Agent.Hostname == "" {
 		if hn, err := os.Hostname(); err == nil {
 			config.Agent.Hostname = hn
@@ -135,30 +782,160 @@ func applyEnvOverrides(config *Config) {
 			config.LogLevel = level
 		}
 	}
+	if v := os.Getenv("ROUTER_SYNC_LOG_LEVELS"); v != "" {
+		config.LogLevels = make(map[string]string)
+		for _, pair := range splitList(v) {
+			if name, level, ok := strings.Cut(pair, "="); ok {
+				config.LogLevels[strings.TrimSpace(name)] = strings.TrimSpace(level)
+			}
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_LOG_FORMAT"); v != "" {
+		config.LogFormat = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_LOG_DEDUP_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.LogDedupWindow = d
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_LOG_FILE"); v != "" {
+		config.LogFile.Path = v
+	}
 	if v := os.Getenv("ROUTER_SYNC_API_ADDRESS"); v != "" {
 		config.API.Address = v
 	}
+	if v := os.Getenv("ROUTER_SYNC_API_ADMIN_ADDRESS"); v != "" {
+		config.API.AdminAddress = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_AUTH_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.API.Auth.Enabled = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_AUTH_ISSUER"); v != "" {
+		config.API.Auth.Issuer = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_AUTH_AUDIENCE"); v != "" {
+		config.API.Auth.Audience = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_AUTH_JWKS_URL"); v != "" {
+		config.API.Auth.JWKSURL = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_AUTH_HMAC_SECRET"); v != "" {
+		config.API.Auth.HMACSecret = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_TLS_CERT_FILE"); v != "" {
+		config.API.TLS.CertFile = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_TLS_KEY_FILE"); v != "" {
+		config.API.TLS.KeyFile = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_TLS_CLIENT_CA_FILE"); v != "" {
+		config.API.TLS.ClientCAFile = v
+	}
+	if v, ok := os.LookupEnv("ROUTER_SYNC_API_CORS_ALLOWED_ORIGINS"); ok {
+		config.API.CORS.AllowedOrigins = splitList(v)
+	}
 	if v := os.Getenv("ROUTER_SYNC_AGENT_HOSTNAME"); v != "" {
 		config.Agent.Hostname = v
 	}
 	if v := os.Getenv("ROUTER_SYNC_AGENT_METRICS_ADDRESS"); v != "" {
 		config.Agent.MetricsAddress = v
 	}
+	if v := os.Getenv("ROUTER_SYNC_API_DISABLE_SWAGGER"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.API.DisableSwagger = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_REQUIRE_IF_MATCH"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.API.RequireIfMatch = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_BACKUP_SIGNING_KEY"); v != "" {
+		config.API.BackupSigningKey = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_STATS_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.API.StatsInterval = d
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_POLICY_EXPIRY_ACTION"); v != "" {
+		config.API.PolicyExpiryAction = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_GIN_MODE"); v != "" {
+		config.API.GinMode = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_READ_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.API.ReadTimeout = d
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_READ_HEADER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.API.ReadHeaderTimeout = d
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_WRITE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.API.WriteTimeout = d
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_API_IDLE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			config.API.IdleTimeout = d
+		}
+	}
 	if v := os.Getenv("ROUTER_SYNC_AGENT_STATE_INTERVAL"); v != "" {
 		if d, err := time.ParseDuration(v); err == nil {
 			config.Agent.StatePublishInterval = d
 		}
 	}
-	if v := os.Getenv("ROUTER_SYNC_NATS_URL"); v != "" {
-		parts := strings.Split(v, ",")
-		urls := make([]string, 0, len(parts))
-		for _, p := range parts {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				urls = append(urls, p)
+	if v := os.Getenv("ROUTER_SYNC_AGENT_ON_START"); v != "" {
+		config.Agent.OnStart = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("ROUTER_SYNC_AGENT_ON_SHUTDOWN"); v != "" {
+		config.Agent.OnShutdown = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("ROUTER_SYNC_FEATURES"); v != "" {
+		for _, name := range splitList(v) {
+			switch strings.ToLower(name) {
+			case "failover":
+				config.Features.Failover = true
+			case "nftables":
+				config.Features.NFTables = true
+			case "ipv6":
+				config.Features.IPv6 = true
+			case "gitops":
+				config.Features.GitOps = true
 			}
 		}
-		if len(urls) > 0 {
+	}
+	if v := os.Getenv("ROUTER_SYNC_PROFILING_ADDRESS"); v != "" {
+		config.Profiling.Address = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_PROFILING_ADMIN_LISTENER"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.Profiling.AdminListener = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_ROUTER_DISABLE_CONNTRACK"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.Router.DisableConntrack = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_ROUTER_SOURCE_SETS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.Router.SourceSets = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_ROUTER_DRY_RUN"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.Router.DryRun = b
+		}
+	}
+	if v := os.Getenv("ROUTER_SYNC_NATS_URL"); v != "" {
+		if urls := splitList(v); len(urls) > 0 {
 			config.NATS.URLs = urls
 		}
 	}
@@ -171,6 +948,12 @@ func applyEnvOverrides(config *Config) {
 	if v := os.Getenv("ROUTER_SYNC_NATS_TOKEN"); v != "" {
 		config.NATS.Token = v
 	}
+	if v := os.Getenv("ROUTER_SYNC_NATS_PASSWORD_FILE"); v != "" {
+		config.NATS.PasswordFile = v
+	}
+	if v := os.Getenv("ROUTER_SYNC_NATS_TOKEN_FILE"); v != "" {
+		config.NATS.TokenFile = v
+	}
 	if v := os.Getenv("ROUTER_SYNC_NATS_CLIENT_ID"); v != "" {
 		config.NATS.ClientID = v
 	}
@@ -178,3 +961,52 @@ func applyEnvOverrides(config *Config) {
 		config.NATS.WriterID = v
 	}
 }
+
+// Overrides are settings given on the command line. Non-zero fields replace
+// the values from the config file and environment.
+type Overrides struct {
+	APIAddress   string
+	NATSURL      string // comma-separated for multiple URLs
+	LogLevel     string
+	SyncInterval time.Duration
+}
+
+// Apply writes the set overrides into config.
+func (o Overrides) Apply(config *Config) error {
+	if o.APIAddress != "" {
+		config.API.Address = o.APIAddress
+	}
+	if o.NATSURL != "" {
+		urls := splitList(o.NATSURL)
+		if len(urls) == 0 {
+			return fmt.Errorf("invalid --nats-url %q", o.NATSURL)
+		}
+		config.NATS.URLs = urls
+	}
+	if o.LogLevel != "" {
+		level, err := logrus.ParseLevel(o.LogLevel)
+		if err != nil {
+			return fmt.Errorf("invalid --log-level: %w", err)
+		}
+		config.LogLevel = level
+	}
+	if o.SyncInterval < 0 {
+		return fmt.Errorf("invalid --sync-interval %s", o.SyncInterval)
+	}
+	if o.SyncInterval > 0 {
+		config.Sync.Interval = o.SyncInterval
+	}
+	return nil
+}
+
+// splitList splits a comma-separated environment value, dropping blanks.
+func splitList(v string) []string {
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReadSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "nats-password")
+	if err := os.WriteFile(file, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	crlf := filepath.Join(dir, "crlf")
+	if err := os.WriteFile(crlf, []byte("s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ROUTER_SYNC_TEST_SECRET", "from-env")
+	t.Setenv("ROUTER_SYNC_TEST_DIR", dir)
+
+	tests := []struct {
+		name    string
+		value   string
+		file    string
+		env     string
+		want    string
+		wantErr string
+	}{
+		{name: "none", want: ""},
+		{name: "inline", value: "inline", want: "inline"},
+		{name: "file trailing newline trimmed", file: file, want: "s3cret"},
+		{name: "file CRLF trimmed", file: crlf, want: "s3cret"},
+		{name: "file path expands variables", file: "${ROUTER_SYNC_TEST_DIR}/nats-password", want: "s3cret"},
+		{name: "env", env: "ROUTER_SYNC_TEST_SECRET", want: "from-env"},
+		{name: "missing file", file: filepath.Join(dir, "missing"), wantErr: "failed to read secret file"},
+		{name: "env unset", env: "ROUTER_SYNC_TEST_UNSET", wantErr: "ROUTER_SYNC_TEST_UNSET is not set"},
+		{name: "value and file", value: "inline", file: file, wantErr: "found value, file"},
+		{name: "file and env", file: file, env: "ROUTER_SYNC_TEST_SECRET", wantErr: "found file, env"},
+		{name: "all three", value: "inline", file: file, env: "ROUTER_SYNC_TEST_SECRET", wantErr: "found value, file, env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSecret(tt.value, tt.file, tt.env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readSecret() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSecret() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("ROUTER_SYNC_TEST_TOKEN", "tok")
+
+	cfg := &Config{NATS: NATSConfig{Password: "pw", TokenEnv: "ROUTER_SYNC_TEST_TOKEN"}}
+	if err := resolveSecrets(cfg); err != nil {
+		t.Fatalf("resolveSecrets() error = %v", err)
+	}
+	if cfg.NATS.Password != "pw" || cfg.NATS.Token != "tok" {
+		t.Errorf("password = %q, token = %q", cfg.NATS.Password, cfg.NATS.Token)
+	}
+
+	cfg = &Config{NATS: NATSConfig{Token: "tok", TokenEnv: "ROUTER_SYNC_TEST_TOKEN"}}
+	if err := resolveSecrets(cfg); err == nil || !strings.HasPrefix(err.Error(), "nats token:") {
+		t.Errorf("resolveSecrets() error = %v, want a nats token error", err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20-nats.yml", "10-base.yaml", ".30-hidden.yaml", "README.md", "40-backup.yaml~"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("mode: api\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "50-sub.yaml"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := configFiles(dir)
+	if err != nil {
+		t.Fatalf("configFiles() error = %v", err)
+	}
+	want := []string{filepath.Join(dir, "10-base.yaml"), filepath.Join(dir, "20-nats.yml")}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("configFiles() = %v, want %v", files, want)
+	}
+
+	single := filepath.Join(dir, "README.md")
+	if files, err := configFiles(single); err != nil || len(files) != 1 || files[0] != single {
+		t.Errorf("configFiles(file) = %v, %v; want the file itself", files, err)
+	}
+
+	empty := t.TempDir()
+	if _, err := configFiles(empty); err == nil || !strings.Contains(err.Error(), "no *.yaml or *.yml files") {
+		t.Errorf("configFiles(empty dir) error = %v", err)
+	}
+}
+
+func TestLoadMergesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	fragments := map[string]string{
+		"10-base.yaml": `
+mode: agent
+log_level: info
+log_levels:
+  router: debug
+  nats: warn
+nats:
+  urls: ["nats://a:4222", "nats://b:4222"]
+  username: router_sync
+`,
+		"20-site.yaml": `
+log_level: error
+log_levels:
+  router: trace
+  agent: info
+nats:
+  urls: ["nats://c:4222"]
+`,
+	}
+	for name, content := range fragments {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// Later fragments win for scalars...
+	if cfg.Mode != ModeAgent || cfg.LogLevel != logrus.ErrorLevel {
+		t.Errorf("mode = %q, log_level = %q; want agent from 10-, error from 20-", cfg.Mode, cfg.LogLevel)
+	}
+	// ...maps are merged key by key...
+	wantLevels := map[string]string{"router": "trace", "nats": "warn", "agent": "info"}
+	for k, v := range wantLevels {
+		if cfg.LogLevels[k] != v {
+			t.Errorf("log_levels[%s] = %q, want %q (all: %v)", k, cfg.LogLevels[k], v, cfg.LogLevels)
+		}
+	}
+	// ...and lists are replaced, not appended.
+	if strings.Join(cfg.NATS.URLs, ",") != "nats://c:4222" {
+		t.Errorf("nats.urls = %v, want only the later fragment's list", cfg.NATS.URLs)
+	}
+	if cfg.NATS.Username != "router_sync" {
+		t.Errorf("nats.username = %q, want it kept from the earlier fragment", cfg.NATS.Username)
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics MetricsConfig
+		wantErr bool
+	}{
+		{"default", MetricsConfig{Namespace: DefaultMetricsNamespace}, false},
+		{"labels", MetricsConfig{Namespace: "edge", ConstLabels: map[string]string{"site": "ams1"}}, false},
+		{"bad namespace", MetricsConfig{Namespace: "router-sync"}, true},
+		{"bad label", MetricsConfig{Namespace: "edge", ConstLabels: map[string]string{"data center": "x"}}, true},
+		{"reserved label", MetricsConfig{Namespace: "edge", ConstLabels: map[string]string{"__name__": "x"}}, true},
+		{"automatic label", MetricsConfig{Namespace: "edge", ConstLabels: map[string]string{"node": "x"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			applyDefaults(cfg)
+			cfg.Metrics = tt.metrics
+			if err := validate(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
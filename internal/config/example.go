@@ -0,0 +1,41 @@
+package config
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// example is the commented config written by `router-sync init-config`. It
+// lists every setting with its default; TestExampleCoversConfig fails when a
+// field is added to Config without documenting it here.
+//
+//go:embed example.yaml
+var example []byte
+
+// Example returns the commented example configuration.
+func Example() []byte {
+	return append([]byte(nil), example...)
+}
+
+// WriteExample writes the example configuration to path, refusing to replace
+// an existing file unless force is set.
+func WriteExample(path string, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(example); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exampleKeys returns the dotted keys set in the example, from indentation.
+func exampleKeys(t *testing.T) map[string]bool {
+	t.Helper()
+	keys := make(map[string]bool)
+	var stack []struct {
+		indent int
+		name   string
+	}
+	for _, line := range strings.Split(string(example), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "- ") {
+			continue
+		}
+		name, _, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, struct {
+			indent int
+			name   string
+		}{indent, name})
+		parts := make([]string, len(stack))
+		for i, s := range stack {
+			parts[i] = s.name
+		}
+		keys[strings.Join(parts, ".")] = true
+	}
+	return keys
+}
+
+// configKeys returns the dotted yaml keys of every Config field.
+func configKeys(typ reflect.Type, prefix string) []string {
+	var out []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+		out = append(out, key)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			out = append(out, configKeys(field.Type, key+".")...)
+		}
+	}
+	return out
+}
+
+func TestExampleCoversConfig(t *testing.T) {
+	keys := exampleKeys(t)
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		if !keys[key] {
+			t.Errorf("example.yaml does not document %s", key)
+		}
+	}
+}
+
+func TestWriteExample(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := WriteExample(path, false); err != nil {
+		t.Fatalf("WriteExample: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(example) {
+		t.Fatal("written file differs from the example")
+	}
+
+	if err := WriteExample(path, false); err == nil {
+		t.Fatal("expected an error when the file exists")
+	}
+	if err := WriteExample(path, true); err != nil {
+		t.Fatalf("WriteExample with force: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config layout this build reads natively. Files
+// without a version key are version 1.
+//
+// Version history:
+//
+//	1  original layout
+//
+// When a key is renamed or moved, bump CurrentVersion and add a migration
+// from the previous version that uses moveKey.
+const CurrentVersion = 1
+
+// migrations upgrade a decoded document from version from to from+1 and
+// return one warning per change. None are needed yet.
+var migrations []struct {
+	from  int
+	apply func(doc map[string]interface{}) []string
+}
+
+// migrate upgrades one config file to CurrentVersion, logging what changed,
+// and warns about keys no version knows (they would otherwise be silently
+// ignored and leave the setting at its default).
+func migrate(file string, data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc == nil {
+		// Let the typed decode report syntax errors.
+		return data, nil
+	}
+
+	version := 1
+	if v, ok := doc["version"]; ok {
+		n, ok := v.(int)
+		if !ok || n < 1 {
+			return nil, fmt.Errorf("%s: version must be a positive integer, got %v", file, v)
+		}
+		version = n
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("%s: config version %d is newer than this build supports (%d); upgrade router-sync", file, version, CurrentVersion)
+	}
+
+	if version < CurrentVersion {
+		changed := false
+		for _, m := range migrations {
+			if m.from < version {
+				continue
+			}
+			for _, w := range m.apply(doc) {
+				logrus.Warnf("Config %s: %s", file, w)
+				changed = true
+			}
+		}
+		doc["version"] = CurrentVersion
+		if changed {
+			logrus.Warnf("Config %s uses layout version %d and was migrated to version %d in memory; run with --print-config to see the upgraded layout and update the file", file, version, CurrentVersion)
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to migrate: %w", file, err)
+		}
+		data = out
+	}
+
+	warnUnknownKeys(file, data)
+	return data, nil
+}
+
+// warnUnknownKeys logs keys that do not map to any Config field.
+func warnUnknownKeys(file string, data []byte) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var probe Config
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(&probe); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			if strings.Contains(msg, "not found in type") {
+				logrus.Warnf("Config %s: %s; the key is ignored", file, msg)
+			}
+		}
+	}
+}
+
+// moveKey moves the dotted key from to to, converting the value with
+// convert when given. If to is already set, from is dropped and to wins.
+// It returns a warning, or "" when from is absent.
+func moveKey(doc map[string]interface{}, from, to string, convert func(interface{}) interface{}) string {
+	value, ok := popKey(doc, from)
+	if !ok {
+		return ""
+	}
+	if _, exists := lookupKey(doc, to); exists {
+		return fmt.Sprintf("%s is obsolete and ignored because %s is also set", from, to)
+	}
+	if convert != nil {
+		value = convert(value)
+	}
+	setKey(doc, to, value)
+	return fmt.Sprintf("%s was renamed to %s", from, to)
+}
+
+func lookupKey(doc map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	m := doc
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	v, ok := m[parts[len(parts)-1]]
+	return v, ok
+}
+
+func popKey(doc map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	m := doc
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	last := parts[len(parts)-1]
+	v, ok := m[last]
+	delete(m, last)
+	return v, ok
+}
+
+func setKey(doc map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	m := doc
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateCurrentVersionUnchanged(t *testing.T) {
+	for _, data := range []string{
+		"mode: agent\nnats:\n  urls: [nats://a:4222]\n",
+		"version: 1\nmode: agent\n",
+	} {
+		got, err := migrate("config.yaml", []byte(data))
+		if err != nil {
+			t.Fatalf("migrate(%q) error = %v", data, err)
+		}
+		if string(got) != data {
+			t.Errorf("migrate(%q) = %q, want it unchanged", data, got)
+		}
+	}
+}
+
+func TestMigrateRejectsBadVersion(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"version: 99\n", "newer than this build supports"},
+		{"version: 0\n", "positive integer"},
+		{"version: two\n", "positive integer"},
+	}
+	for _, tt := range tests {
+		_, err := migrate("config.yaml", []byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("migrate(%q) error = %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestMoveKey(t *testing.T) {
+	doc := map[string]interface{}{
+		"agent": map[string]interface{}{"old": "5s"},
+	}
+
+	w := moveKey(doc, "agent.old", "router.new", func(v interface{}) interface{} { return v.(string) + "!" })
+	if !strings.Contains(w, "renamed") {
+		t.Errorf("moveKey() warning = %q", w)
+	}
+	want := map[string]interface{}{
+		"agent":  map[string]interface{}{},
+		"router": map[string]interface{}{"new": "5s!"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("document = %#v, want %#v", doc, want)
+	}
+
+	if w := moveKey(doc, "agent.old", "router.new", nil); w != "" {
+		t.Errorf("moveKey() of an absent key warned %q", w)
+	}
+}
+
+func TestMoveKeyKeepsNewKey(t *testing.T) {
+	doc := map[string]interface{}{
+		"agent": map[string]interface{}{"old": "5s", "new": "10s"},
+	}
+
+	if w := moveKey(doc, "agent.old", "agent.new", nil); !strings.Contains(w, "ignored") {
+		t.Errorf("moveKey() warning = %q", w)
+	}
+	agent := doc["agent"].(map[string]interface{})
+	if _, ok := agent["old"]; ok {
+		t.Error("obsolete key was not removed")
+	}
+	if got := agent["new"]; got != "10s" {
+		t.Errorf("new = %v, want 10s", got)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secrets in Redacted output. Empty secrets stay empty
+// so the dump still shows which ones are unset.
+const redactedValue = "REDACTED"
+
+// Redacted returns a copy of c with passwords, tokens and keys replaced, safe
+// to print or return from the API. File and variable names of secrets
+// (password_file, token_env, ...) are kept.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.NATS.Password = redact(c.NATS.Password)
+	out.NATS.Token = redact(c.NATS.Token)
+	out.API.Auth.HMACSecret = redact(c.API.Auth.HMACSecret)
+	if c.API.Auth.APIKeys != nil {
+		out.API.Auth.APIKeys = make([]APIKey, len(c.API.Auth.APIKeys))
+		for i, k := range c.API.Auth.APIKeys {
+			k.Key = redact(k.Key)
+			out.API.Auth.APIKeys[i] = k
+		}
+	}
+	out.API.BackupSigningKey = redact(c.API.BackupSigningKey)
+	if c.API.Webhooks != nil {
+		out.API.Webhooks = make([]WebhookConfig, len(c.API.Webhooks))
+		for i, w := range c.API.Webhooks {
+			w.Secret = redact(w.Secret)
+			out.API.Webhooks[i] = w
+		}
+	}
+	return &out
+}
+
+// RedactedYAML renders the redacted configuration as YAML, in the same layout
+// as the config file.
+func (c *Config) RedactedYAML() ([]byte, error) {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return data, nil
+}
+
+// RedactedMap returns the redacted configuration keyed like the config file,
+// for JSON output.
+func (c *Config) RedactedMap() (map[string]interface{}, error) {
+	data, err := c.RedactedYAML()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	return out, nil
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
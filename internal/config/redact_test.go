@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{}
+	cfg.NATS.Password = "nats-secret"
+	cfg.NATS.PasswordFile = "/run/secrets/nats"
+	cfg.API.Auth.HMACSecret = "jwt-secret"
+	cfg.API.Auth.APIKeys = []APIKey{{Name: "ci", Key: "0123456789abcdef", Role: "operator"}}
+	cfg.API.Webhooks = []WebhookConfig{{Name: "alerts", URL: "https://hooks.example.com", Secret: "hook-secret"}}
+
+	out := cfg.Redacted()
+	if out.NATS.Password != redactedValue || out.API.Auth.HMACSecret != redactedValue {
+		t.Errorf("secrets not redacted: %+v", out)
+	}
+	if out.NATS.Token != "" || out.API.BackupSigningKey != "" {
+		t.Error("unset secrets should stay empty")
+	}
+	if out.NATS.PasswordFile != "/run/secrets/nats" {
+		t.Error("secret file path should be kept")
+	}
+	if k := out.API.Auth.APIKeys[0]; k.Key != redactedValue || k.Name != "ci" {
+		t.Errorf("API key not redacted: %+v", k)
+	}
+	if w := out.API.Webhooks[0]; w.Secret != redactedValue || w.URL != "https://hooks.example.com" {
+		t.Errorf("webhook secret not redacted: %+v", w)
+	}
+	if cfg.NATS.Password != "nats-secret" || cfg.API.Auth.APIKeys[0].Key != "0123456789abcdef" || cfg.API.Webhooks[0].Secret != "hook-secret" {
+		t.Error("Redacted modified the original config")
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sopsCommand is the sops binary used to decrypt encrypted config files;
+// ROUTER_SYNC_SOPS_PATH overrides it.
+var sopsCommand = "sops"
+
+// sopsTimeout bounds one decryption, which may reach a KMS or gpg-agent.
+const sopsTimeout = 30 * time.Second
+
+// isSOPS reports whether data is a SOPS-encrypted YAML document, i.e. has
+// the top-level sops metadata block sops writes on encryption.
+func isSOPS(data []byte) bool {
+	var probe struct {
+		SOPS map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, ok := probe.SOPS["mac"]
+	return ok
+}
+
+// decryptSOPS returns the plaintext of a SOPS-encrypted file by running
+// `sops --decrypt`. Keys come from the environment as sops expects them:
+// SOPS_AGE_KEY_FILE or SOPS_AGE_KEY for age, the gpg-agent / GNUPGHOME for
+// PGP, and the usual cloud credentials for KMS.
+func decryptSOPS(file string) ([]byte, error) {
+	bin := sopsCommand
+	if v := os.Getenv("ROUTER_SYNC_SOPS_PATH"); v != "" {
+		bin = v
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sopsTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", file)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: failed to decrypt with sops: %w: %s", file, err, msg)
+		}
+		return nil, fmt.Errorf("%s: failed to decrypt with sops: %w", file, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSOPS installs a shell script as the sops binary for the test.
+func fakeSOPS(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	bin := filepath.Join(t.TempDir(), "sops")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ROUTER_SYNC_SOPS_PATH", bin)
+}
+
+func TestDecryptSOPS(t *testing.T) {
+	fakeSOPS(t, `[ "$1" = "--decrypt" ] || exit 2
+printf 'nats:\n  password: secret\n'
+`)
+	out, err := decryptSOPS("config.enc.yaml")
+	if err != nil {
+		t.Fatalf("decryptSOPS: %v", err)
+	}
+	if string(out) != "nats:\n  password: secret\n" {
+		t.Fatalf("unexpected plaintext %q", out)
+	}
+}
+
+func TestDecryptSOPSError(t *testing.T) {
+	fakeSOPS(t, `echo "no age identity found" >&2
+exit 128
+`)
+	_, err := decryptSOPS("config.enc.yaml")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "no age identity found") {
+		t.Fatalf("error does not include sops output: %v", err)
+	}
+}
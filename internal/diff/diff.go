@@ -0,0 +1,472 @@
+// Package diff compares the desired configuration stored in NATS with the
+// kernel state an agent reports, producing the changes the agent would make
+// (or that are blocked) on its next sync. It is pure: callers supply both sides.
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+// Change actions.
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+	ActionChange = "change"
+)
+
+// Change kinds.
+const (
+	KindRule  = "rule"
+	KindRoute = "route"
+)
+
+// Change is a single difference between desired and applied state.
+type Change struct {
+	Action      string `json:"action"`
+	Kind        string `json:"kind"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	PolicyID    string `json:"policy_id,omitempty"`
+	FwMark      int    `json:"fwmark,omitempty"`
+	RuleAction  string `json:"rule_action,omitempty"` // "blackhole" for a strict policy's drop rule
+
+	Table            int    `json:"table"`
+	Priority         int    `json:"priority,omitempty"`
+	ExpectedTable    int    `json:"expected_table,omitempty"`
+	ExpectedPriority int    `json:"expected_priority,omitempty"`
+	Gateway          string `json:"gateway,omitempty"`
+	ProviderID       string `json:"provider_id,omitempty"`
+	Message          string `json:"message"`
+}
+
+// RouterDiff is the diff for one router.
+type RouterDiff struct {
+	Hostname string   `json:"hostname"`
+	InSync   bool     `json:"in_sync"`
+	Changes  []Change `json:"changes"`
+}
+
+// markRule identifies a fwmark rule; the mark always equals the table.
+type markRule struct {
+	priority int
+	table    int
+}
+
+type desiredRule struct {
+	source      string
+	destination string
+	priority    int
+	table       int
+	policy      *models.RoutingPolicy
+	// providerDown is set for a policy no provider can take on the router
+	// (RouterState.Failover): it wants no lookup rule, only its blackhole
+	// when strict.
+	providerDown bool
+}
+
+// Compute diffs the desired providers/policies against one router's state.
+//
+// Rules: every enabled policy whose provider exists should have exactly one
+// managed rule (priority from the prefix length, pointing at the provider's
+// table); managed rules without such a policy should be removed.
+// Policies matching on protocol or ports are routed by fwmark instead: each
+// (priority, table) pair they use needs one "fwmark <table> lookup <table>"
+// rule. So are plain source policies on a router with source sets
+// (RouterState.SourceSets). The nftables side of them is not reported. Strict policies also need
+// a blackhole rule with their selector and priority.
+// Routes: every provider table the router uses should hold a default route
+// via each provider gateway (IPv4 and IPv6). Agents install those routes on
+// every sync, so route changes flag a provider whose routes failed (see
+// RouterState.ProviderErrors), e.g. a missing interface.
+// Delegated source policies are resolved against the router's delegated
+// prefixes; those it has no prefix for want no rule.
+// Policies the router moved off a down provider (RouterState.Failover) are
+// diffed against the provider now carrying them; those no provider can take
+// want no lookup rule.
+func Compute(state *models.RouterState, providers []*models.InternetProvider, policies []*models.RoutingPolicy) RouterDiff {
+	result := RouterDiff{Hostname: state.Hostname, Changes: []Change{}}
+	policies = models.ResolvePolicies(policies, state.DelegatedPrefixes)
+
+	providerByID := make(map[string]*models.InternetProvider, len(providers))
+	for _, p := range providers {
+		providerByID[p.ID] = p
+	}
+
+	desired := make(map[string]desiredRule)
+	desiredMarks := make(map[markRule]*models.RoutingPolicy)
+	usedProviders := make(map[string]*models.InternetProvider)
+	now := time.Now()
+	for _, pol := range policies {
+		if !pol.Active(now) {
+			continue
+		}
+		if _, ok := providerByID[pol.ProviderID]; !ok {
+			continue
+		}
+		srcNet, err := pol.SourceNet()
+		if err != nil {
+			continue
+		}
+		dstNet, err := pol.DestinationNet()
+		if err != nil {
+			continue
+		}
+		providerID, routed := pol.FailoverProvider(state.Failover)
+		provider, ok := providerByID[providerID]
+		if !routed {
+			if !pol.MarkRouted(state.SourceSets) {
+				want := desiredRule{
+					source:       srcNet.String(),
+					priority:     pol.RulePriority(srcNet),
+					policy:       pol,
+					providerDown: true,
+				}
+				if dstNet != nil {
+					want.destination = dstNet.String()
+				}
+				desired[models.RuleKeyFor(srcNet, dstNet)] = want
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		usedProviders[provider.ID] = provider
+		if pol.MarkRouted(state.SourceSets) {
+			mark := markRule{priority: pol.RulePriority(srcNet), table: provider.TableID}
+			if _, ok := desiredMarks[mark]; !ok {
+				desiredMarks[mark] = pol
+			}
+			continue
+		}
+		want := desiredRule{
+			source:   srcNet.String(),
+			priority: pol.RulePriority(srcNet),
+			table:    provider.TableID,
+			policy:   pol,
+		}
+		if dstNet != nil {
+			want.destination = dstNet.String()
+		}
+		desired[models.RuleKeyFor(srcNet, dstNet)] = want
+	}
+
+	// Managed rules actually installed, grouped by canonical selector
+	// (source, plus destination when the rule has one).
+	actual := make(map[string][]models.IPRule)
+	haveMarks := make(map[markRule]bool)
+	haveBlackholes := make(map[string]bool)
+	for _, r := range state.Rules {
+		if !models.IsManagedPriority(r.Priority) {
+			continue
+		}
+		if r.Action != "" {
+			key, _ := r.RuleKey()
+			if want, ok := desired[key]; ok && want.policy.Strict() && r.Action == models.RuleActionBlackhole &&
+				r.Priority == want.priority && !haveBlackholes[key] {
+				haveBlackholes[key] = true
+				continue
+			}
+			result.Changes = append(result.Changes, Change{
+				Action: ActionRemove, Kind: KindRule, Source: canonical(r.From), Destination: canonical(r.To), RuleAction: r.Action, Priority: r.Priority,
+				Message: fmt.Sprintf("%s rule for %s has no strict policy", r.Action, r.From),
+			})
+			continue
+		}
+		if r.FwMark != 0 {
+			mark := markRule{priority: r.Priority, table: r.Table}
+			if _, ok := desiredMarks[mark]; ok && r.FwMark == r.Table && !haveMarks[mark] {
+				haveMarks[mark] = true
+				continue
+			}
+			result.Changes = append(result.Changes, Change{
+				Action: ActionRemove, Kind: KindRule, Source: r.From, FwMark: r.FwMark, Table: r.Table, Priority: r.Priority,
+				Message: fmt.Sprintf("mark rule for fwmark %#x table %d has no policy", r.FwMark, r.Table),
+			})
+			continue
+		}
+		key, ok := r.RuleKey()
+		if !ok {
+			result.Changes = append(result.Changes, Change{
+				Action: ActionRemove, Kind: KindRule, Source: r.From, Destination: r.To, Table: r.Table, Priority: r.Priority,
+				Message: fmt.Sprintf("managed rule from %s has no policy", r.From),
+			})
+			continue
+		}
+		actual[key] = append(actual[key], r)
+	}
+
+	for src, want := range desired {
+		have := actual[src]
+		if want.providerDown {
+			for _, r := range have {
+				result.Changes = append(result.Changes, Change{
+					Action: ActionRemove, Kind: KindRule, Source: want.source, Destination: want.destination, PolicyID: want.policy.ID,
+					Table: r.Table, Priority: r.Priority,
+					Message: fmt.Sprintf("rule for %s remains while provider %s is down", src, want.policy.ProviderID),
+				})
+			}
+			continue
+		}
+		matched := false
+		for _, r := range have {
+			if !matched && r.Table == want.table && r.Priority == want.priority {
+				matched = true
+				continue
+			}
+			result.Changes = append(result.Changes, Change{
+				Action: ActionChange, Kind: KindRule, Source: want.source, Destination: want.destination, PolicyID: want.policy.ID,
+				Table: r.Table, Priority: r.Priority,
+				ExpectedTable: want.table, ExpectedPriority: want.priority,
+				ProviderID: want.policy.ProviderID,
+				Message: fmt.Sprintf("rule for %s is priority %d table %d, want priority %d table %d",
+					src, r.Priority, r.Table, want.priority, want.table),
+			})
+		}
+		if !matched && len(have) == 0 {
+			result.Changes = append(result.Changes, Change{
+				Action: ActionAdd, Kind: KindRule, Source: want.source, Destination: want.destination, PolicyID: want.policy.ID,
+				ExpectedTable: want.table, ExpectedPriority: want.priority,
+				ProviderID: want.policy.ProviderID,
+				Message:    fmt.Sprintf("rule for %s (policy %s) is not installed", src, want.policy.Name),
+			})
+		}
+	}
+
+	for key, want := range desired {
+		if !want.policy.Strict() || haveBlackholes[key] {
+			continue
+		}
+		result.Changes = append(result.Changes, Change{
+			Action: ActionAdd, Kind: KindRule, Source: want.source, Destination: want.destination, PolicyID: want.policy.ID,
+			RuleAction: models.RuleActionBlackhole, ExpectedPriority: want.priority,
+			ProviderID: want.policy.ProviderID,
+			Message:    fmt.Sprintf("blackhole rule for %s (strict policy %s) is not installed", key, want.policy.Name),
+		})
+	}
+
+	for mark, pol := range desiredMarks {
+		if haveMarks[mark] {
+			continue
+		}
+		result.Changes = append(result.Changes, Change{
+			Action: ActionAdd, Kind: KindRule, Source: "all", FwMark: mark.table, PolicyID: pol.ID,
+			ExpectedTable: mark.table, ExpectedPriority: mark.priority,
+			ProviderID: pol.ProviderID,
+			Message:    fmt.Sprintf("mark rule for table %d (policy %s) is not installed", mark.table, pol.Name),
+		})
+	}
+
+	for src, rules := range actual {
+		if _, ok := desired[src]; ok {
+			continue
+		}
+		for _, r := range rules {
+			result.Changes = append(result.Changes, Change{
+				Action: ActionRemove, Kind: KindRule, Source: canonical(r.From), Destination: canonical(r.To), Table: r.Table, Priority: r.Priority,
+				Message: fmt.Sprintf("rule for %s has no enabled policy", src),
+			})
+		}
+	}
+
+	tables := make(map[int]models.RoutingTable, len(state.Tables))
+	for _, t := range state.Tables {
+		tables[t.ID] = t
+	}
+	for _, p := range usedProviders {
+		for _, gw := range p.Gateways() {
+			if hasDefaultVia(tables[p.TableID], gw) {
+				continue
+			}
+			result.Changes = append(result.Changes, Change{
+				Action: ActionAdd, Kind: KindRoute, Table: p.TableID, Gateway: gw, ProviderID: p.ID,
+				Message: fmt.Sprintf("table %d has no default route via %s for provider %s", p.TableID, gw, p.Name),
+			})
+		}
+	}
+
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		a, b := result.Changes[i], result.Changes[j]
+		if a.Kind != b.Kind {
+			return a.Kind > b.Kind // rules before routes
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Priority+a.ExpectedPriority < b.Priority+b.ExpectedPriority
+	})
+	result.InSync = len(result.Changes) == 0
+	return result
+}
+
+// Count returns the number of changes with the given action and kind; an
+// empty action or kind matches any.
+func (d RouterDiff) Count(action, kind string) int {
+	n := 0
+	for _, c := range d.Changes {
+		if (action == "" || c.Action == action) && (kind == "" || c.Kind == kind) {
+			n++
+		}
+	}
+	return n
+}
+
+// canonical returns an IP or CIDR selector in CIDR notation ("" stays "").
+func canonical(selector string) string {
+	if n, err := models.ParseSource(selector); err == nil {
+		return n.String()
+	}
+	return selector
+}
+
+func hasDefaultVia(t models.RoutingTable, gateway string) bool {
+	for _, r := range t.Routes {
+		if r.Dst == "default" && (gateway == "" || r.Gateway == gateway) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy application states returned by PolicyStatus.
+const (
+	StatusApplied  = "applied"  // enabled and the expected rule is installed
+	StatusMissing  = "missing"  // enabled but no rule for its source
+	StatusMismatch = "mismatch" // a rule exists with the wrong table or priority, or strict mode's blackhole rule is off
+	StatusRemoved  = "removed"  // disabled and no rule, as intended
+	StatusStale    = "stale"    // disabled but a rule is still installed
+	// StatusFailedOver: enabled, its provider is down on the router and a
+	// rule routes it via the provider the router moved it to.
+	StatusFailedOver = "failed_over"
+	// StatusProviderDown: enabled, its provider is down on the router and
+	// no other provider can take it.
+	StatusProviderDown = "provider_down"
+)
+
+// PolicyStatus reports how policy (routed via provider) is applied on the
+// router described by state. An unparsable source is reported as missing.
+// A mark-routed policy (classified, or in a source set) is judged by its
+// provider's mark rule, which it may share with other policies, so it is
+// never reported stale. A delegated source policy is judged by the subnet it
+// resolves to on the router, and is missing while the router has no prefix.
+// A policy the router moved off its down provider (RouterState.Failover) is
+// failed over once a rule with its priority routes it, whatever the table.
+func PolicyStatus(state *models.RouterState, policy *models.RoutingPolicy, provider *models.InternetProvider) string {
+	resolved, err := policy.Resolve(state.DelegatedPrefixes)
+	if err != nil {
+		if !policy.Active(time.Now()) {
+			return StatusRemoved
+		}
+		return StatusMissing
+	}
+	policy = resolved
+	target, failedOver := state.Failover[policy.ID]
+	if failedOver && policy.Active(time.Now()) && target == "" {
+		return StatusProviderDown
+	}
+	key, ok := policy.RuleKey()
+	if !ok {
+		return StatusMissing
+	}
+	srcNet, _ := policy.SourceNet()
+	priority := policy.RulePriority(srcNet)
+	classified := policy.MarkRouted(state.SourceSets)
+	wantTable := func(table int) bool { return table == provider.TableID || failedOver }
+
+	found, exact, blackholed := false, false, false
+	for _, r := range state.Rules {
+		if !models.IsManagedPriority(r.Priority) {
+			continue
+		}
+		if r.Action != "" {
+			if k, ok := r.RuleKey(); ok && k == key && r.Action == models.RuleActionBlackhole && r.Priority == priority {
+				blackholed = true
+			}
+			continue
+		}
+		if classified {
+			if r.FwMark == 0 || !wantTable(r.FwMark) {
+				continue
+			}
+		} else if k, ok := r.RuleKey(); r.FwMark != 0 || !ok || k != key {
+			continue
+		}
+		found = true
+		if r.Priority == priority && wantTable(r.Table) {
+			exact = true
+		}
+	}
+
+	active := policy.Active(time.Now())
+	if classified && !active {
+		return StatusRemoved
+	}
+	if exact && blackholed != (active && policy.Strict()) {
+		return StatusMismatch
+	}
+	switch {
+	case !active && found:
+		return StatusStale
+	case !active:
+		return StatusRemoved
+	case exact && failedOver:
+		return StatusFailedOver
+	case exact:
+		return StatusApplied
+	case found:
+		return StatusMismatch
+	default:
+		return StatusMissing
+	}
+}
+
+// ProviderStatus reports whether provider's table on the router described by
+// state has a default route via each of its gateways: StatusApplied when it
+// does, StatusMissing otherwise.
+func ProviderStatus(state *models.RouterState, provider *models.InternetProvider) string {
+	for _, t := range state.Tables {
+		if t.ID != provider.TableID {
+			continue
+		}
+		for _, gw := range provider.Gateways() {
+			if !hasDefaultVia(t, gw) {
+				return StatusMissing
+			}
+		}
+		return StatusApplied
+	}
+	return StatusMissing
+}
+
+// RuleTable returns the table the managed lookup rule for policy's source
+// and destination points at on the router described by state. Mark-routed
+// policies share mark rules and are never matched.
+func RuleTable(state *models.RouterState, policy *models.RoutingPolicy) (int, bool) {
+	policy, err := policy.Resolve(state.DelegatedPrefixes)
+	if err != nil {
+		return 0, false
+	}
+	key, ok := policy.RuleKey()
+	if !ok || policy.MarkRouted(state.SourceSets) {
+		return 0, false
+	}
+	for _, r := range state.Rules {
+		if !models.IsManagedPriority(r.Priority) || r.Action != "" || r.FwMark != 0 {
+			continue
+		}
+		if k, ok := r.RuleKey(); ok && k == key {
+			return r.Table, true
+		}
+	}
+	return 0, false
+}
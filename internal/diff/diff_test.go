@@ -0,0 +1,337 @@
+package diff
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+		{ID: "isp2", Name: "isp2", TableID: 200, Gateway: "10.0.1.1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "192.168.1.10", Name: "ok", ProviderID: "isp1", Enabled: true},
+		{ID: "192.168.1.20", Name: "missing", ProviderID: "isp1", Enabled: true},
+		{ID: "192.168.2.0/24", Name: "moved", ProviderID: "isp2", Enabled: true},
+		{ID: "192.168.1.30", Name: "disabled", ProviderID: "isp1", Enabled: false},
+	}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 10, From: "all", Table: 254},
+			{Priority: 2000, From: "192.168.1.10", Table: 100},
+			{Priority: 2008, From: "192.168.2.0/24", Table: 100},
+			{Priority: 2000, From: "192.168.1.30", Table: 100},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}},
+		},
+	}
+
+	got := Compute(state, providers, policies)
+
+	assert.False(t, got.InSync)
+	assert.Equal(t, "r1", got.Hostname)
+
+	byAction := make(map[string][]Change)
+	for _, c := range got.Changes {
+		byAction[c.Action+"/"+c.Kind] = append(byAction[c.Action+"/"+c.Kind], c)
+	}
+	if assert.Len(t, byAction["add/rule"], 1) {
+		assert.Equal(t, "192.168.1.20/32", byAction["add/rule"][0].Source)
+		assert.Equal(t, 100, byAction["add/rule"][0].ExpectedTable)
+	}
+	if assert.Len(t, byAction["change/rule"], 1) {
+		assert.Equal(t, "192.168.2.0/24", byAction["change/rule"][0].Source)
+		assert.Equal(t, 200, byAction["change/rule"][0].ExpectedTable)
+	}
+	if assert.Len(t, byAction["remove/rule"], 1) {
+		assert.Equal(t, "192.168.1.30/32", byAction["remove/rule"][0].Source)
+	}
+	if assert.Len(t, byAction["add/route"], 1) {
+		assert.Equal(t, 200, byAction["add/route"][0].Table)
+	}
+}
+
+func TestComputeInSync(t *testing.T) {
+	providers := []*models.InternetProvider{{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"}}
+	policies := []*models.RoutingPolicy{{ID: "192.168.1.10", Name: "pc", ProviderID: "isp1", Enabled: true}}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules:    []models.IPRule{{Priority: 2000, From: "192.168.1.10", Table: 100}},
+		Tables:   []models.RoutingTable{{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}}},
+	}
+
+	got := Compute(state, providers, policies)
+	assert.True(t, got.InSync)
+	assert.Empty(t, got.Changes)
+}
+
+func TestPolicyStatus(t *testing.T) {
+	provider := &models.InternetProvider{ID: "isp1", TableID: 100}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.1.10", Table: 100},
+			{Priority: 2000, From: "192.168.1.11", Table: 200},
+			{Priority: 2000, From: "192.168.1.30", Table: 100},
+		},
+	}
+
+	tests := []struct {
+		policy *models.RoutingPolicy
+		want   string
+	}{
+		{&models.RoutingPolicy{ID: "192.168.1.10", Enabled: true}, StatusApplied},
+		{&models.RoutingPolicy{ID: "192.168.1.11", Enabled: true}, StatusMismatch},
+		{&models.RoutingPolicy{ID: "192.168.1.20", Enabled: true}, StatusMissing},
+		{&models.RoutingPolicy{ID: "192.168.1.30"}, StatusStale},
+		{&models.RoutingPolicy{ID: "192.168.1.40"}, StatusRemoved},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, PolicyStatus(state, tt.policy, provider), tt.policy.ID)
+	}
+}
+
+func TestComputeDestination(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+		{ID: "isp2", Name: "isp2", TableID: 200, Gateway: "10.0.1.1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.1.10", Name: "default", ProviderID: "isp1", Enabled: true},
+		{ID: "b", SourceIP: "192.168.1.10", Destination: "203.0.113.0/24", Name: "voip", ProviderID: "isp2", Enabled: true},
+	}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.1.10", Table: 100},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}},
+			{ID: 200, Routes: []models.Route{{Dst: "default", Gateway: "10.0.1.1"}}},
+		},
+	}
+
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 1) {
+		assert.Equal(t, ActionAdd, got.Changes[0].Action)
+		assert.Equal(t, "192.168.1.10/32", got.Changes[0].Source)
+		assert.Equal(t, "203.0.113.0/24", got.Changes[0].Destination)
+		assert.Equal(t, "b", got.Changes[0].PolicyID)
+	}
+
+	state.Rules = append(state.Rules, models.IPRule{Priority: 2000, From: "192.168.1.10", To: "203.0.113.0/24", Table: 200})
+	assert.True(t, Compute(state, providers, policies).InSync)
+	assert.Equal(t, StatusApplied, PolicyStatus(state, policies[1], providers[1]))
+}
+
+func TestComputeClassified(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.1.10", Protocol: "tcp", DestinationPorts: "443", Name: "https", ProviderID: "isp1", Enabled: true},
+	}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "all", FwMark: 200, Table: 200},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}},
+		},
+	}
+
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 2) {
+		assert.Equal(t, ActionAdd, got.Changes[0].Action)
+		assert.Equal(t, 100, got.Changes[0].FwMark)
+		assert.Equal(t, "a", got.Changes[0].PolicyID)
+		assert.Equal(t, ActionRemove, got.Changes[1].Action)
+		assert.Equal(t, 200, got.Changes[1].FwMark)
+	}
+	assert.Equal(t, StatusMissing, PolicyStatus(state, policies[0], providers[0]))
+
+	state.Rules = []models.IPRule{{Priority: 2000, From: "all", FwMark: 100, Table: 100}}
+	assert.True(t, Compute(state, providers, policies).InSync)
+	assert.Equal(t, StatusApplied, PolicyStatus(state, policies[0], providers[0]))
+}
+
+func TestComputeSourceSets(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.1.10", Name: "host", ProviderID: "isp1", Enabled: true},
+	}
+	state := &models.RouterState{
+		Hostname:   "r1",
+		SourceSets: true,
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.1.10", Table: 100},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}},
+		},
+	}
+
+	// The per-source rule left from before is removed, the mark rule added
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 2) {
+		assert.Equal(t, ActionRemove, got.Changes[0].Action)
+		assert.Equal(t, "192.168.1.10/32", got.Changes[0].Source)
+		assert.Equal(t, ActionAdd, got.Changes[1].Action)
+		assert.Equal(t, 100, got.Changes[1].FwMark)
+	}
+
+	state.Rules = []models.IPRule{{Priority: 2000, From: "all", FwMark: 100, Table: 100}}
+	assert.True(t, Compute(state, providers, policies).InSync)
+	assert.Equal(t, StatusApplied, PolicyStatus(state, policies[0], providers[0]))
+	_, ok := RuleTable(state, policies[0])
+	assert.False(t, ok)
+}
+
+func TestComputeDualStackRoutes(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1", GatewayV6: "fe80::1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.1.10", Name: "pc", ProviderID: "isp1", Enabled: true},
+	}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules:    []models.IPRule{{Priority: 2000, From: "192.168.1.10", Table: 100}},
+		Tables:   []models.RoutingTable{{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}}},
+	}
+
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 1) {
+		assert.Equal(t, KindRoute, got.Changes[0].Kind)
+		assert.Equal(t, "fe80::1", got.Changes[0].Gateway)
+	}
+
+	state.Tables[0].Routes = append(state.Tables[0].Routes, models.Route{Dst: "default", Gateway: "fe80::1"})
+	assert.True(t, Compute(state, providers, policies).InSync)
+}
+
+func TestComputeStrict(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.1.10", Name: "pc", ProviderID: "isp1", Enabled: true, Mode: models.PolicyModeStrict},
+	}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.1.10", Table: 100},
+			{Priority: 2008, From: "192.168.2.0/24", Action: models.RuleActionBlackhole},
+		},
+		Tables: []models.RoutingTable{{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "10.0.0.1"}}}},
+	}
+
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 2) {
+		assert.Equal(t, ActionAdd, got.Changes[0].Action)
+		assert.Equal(t, models.RuleActionBlackhole, got.Changes[0].RuleAction)
+		assert.Equal(t, ActionRemove, got.Changes[1].Action)
+		assert.Equal(t, "192.168.2.0/24", got.Changes[1].Source)
+	}
+	assert.Equal(t, StatusMismatch, PolicyStatus(state, policies[0], providers[0]))
+
+	state.Rules[1] = models.IPRule{Priority: 2000, From: "192.168.1.10", Action: models.RuleActionBlackhole}
+	assert.True(t, Compute(state, providers, policies).InSync)
+	assert.Equal(t, StatusApplied, PolicyStatus(state, policies[0], providers[0]))
+}
+
+func TestComputeFailover(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", Name: "isp1", TableID: 100, Gateway: "10.0.0.1"},
+		{ID: "isp2", Name: "isp2", TableID: 200, Gateway: "10.0.1.1"},
+	}
+	moved := &models.RoutingPolicy{ID: "a", SourceIP: "192.168.1.10", Name: "moved", ProviderID: "isp1", Enabled: true}
+	down := &models.RoutingPolicy{ID: "b", SourceIP: "192.168.1.20", Name: "down", ProviderID: "isp1", Enabled: true, Mode: models.PolicyModeStrict}
+	policies := []*models.RoutingPolicy{moved, down}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.1.10", Table: 200},
+			{Priority: 2000, From: "192.168.1.20", Action: models.RuleActionBlackhole},
+		},
+		Tables:   []models.RoutingTable{{ID: 200, Routes: []models.Route{{Dst: "default", Gateway: "10.0.1.1"}}}},
+		Failover: map[string]string{"a": "isp2", "b": ""},
+	}
+
+	assert.True(t, Compute(state, providers, policies).InSync)
+	assert.Equal(t, StatusFailedOver, PolicyStatus(state, moved, providers[0]))
+	assert.Equal(t, StatusProviderDown, PolicyStatus(state, down, providers[0]))
+
+	// A stranded policy's lookup rule left behind is removed
+	state.Rules = append(state.Rules, models.IPRule{Priority: 2000, From: "192.168.1.20", Table: 100})
+	got := Compute(state, providers, policies)
+	if assert.Len(t, got.Changes, 1) {
+		assert.Equal(t, ActionRemove, got.Changes[0].Action)
+		assert.Equal(t, "192.168.1.20/32", got.Changes[0].Source)
+		assert.Equal(t, 100, got.Changes[0].Table)
+	}
+
+	// Back up: the moved rule points at the wrong table again
+	state.Failover = nil
+	state.Rules = state.Rules[:1]
+	assert.Equal(t, StatusMismatch, PolicyStatus(state, moved, providers[0]))
+}
+
+func TestComputeDelegatedSource(t *testing.T) {
+	providers := []*models.InternetProvider{{ID: "isp1", Name: "isp1", TableID: 100, GatewayV6: "fe80::1"}}
+	lan := &models.RoutingPolicy{ID: "lan", Name: "lan", ProviderID: "isp1", Enabled: true,
+		DelegatedSource: &models.DelegatedSource{Subnet: 5, Length: 64}}
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2016, From: "2001:db8:1200:5::/64", Table: 100},
+		},
+		Tables: []models.RoutingTable{{ID: 100, Routes: []models.Route{{Dst: "default", Gateway: "fe80::1"}}}},
+	}
+
+	// Without a prefix the rule of the old one is stale
+	got := Compute(state, providers, []*models.RoutingPolicy{lan})
+	if assert.Len(t, got.Changes, 1) {
+		assert.Equal(t, ActionRemove, got.Changes[0].Action)
+	}
+	assert.Equal(t, StatusMissing, PolicyStatus(state, lan, providers[0]))
+
+	state.DelegatedPrefixes = map[string]string{"isp1": "2001:db8:1200::/56"}
+	assert.True(t, Compute(state, providers, []*models.RoutingPolicy{lan}).InSync)
+	assert.Equal(t, StatusApplied, PolicyStatus(state, lan, providers[0]))
+	table, ok := RuleTable(state, lan)
+	assert.True(t, ok)
+	assert.Equal(t, 100, table)
+
+	// Renumbered: the new subnet's rule is wanted instead
+	state.DelegatedPrefixes["isp1"] = "2001:db8:3400::/56"
+	got = Compute(state, providers, []*models.RoutingPolicy{lan})
+	byAction := make(map[string]string)
+	for _, c := range got.Changes {
+		byAction[c.Action] = c.Source
+	}
+	assert.Equal(t, map[string]string{ActionAdd: "2001:db8:3400:5::/64", ActionRemove: "2001:db8:1200:5::/64"}, byAction)
+}
+
+func TestRouterDiffCount(t *testing.T) {
+	d := RouterDiff{Changes: []Change{
+		{Action: ActionRemove, Kind: KindRule},
+		{Action: ActionRemove, Kind: KindRule},
+		{Action: ActionAdd, Kind: KindRule},
+		{Action: ActionAdd, Kind: KindRoute},
+	}}
+	assert.Equal(t, 2, d.Count(ActionRemove, KindRule))
+	assert.Equal(t, 3, d.Count("", KindRule))
+	assert.Equal(t, 2, d.Count(ActionAdd, ""))
+	assert.Equal(t, 4, d.Count("", ""))
+	assert.Equal(t, 0, RouterDiff{}.Count("", ""))
+}
@@ -0,0 +1,161 @@
+// Package frr signals provider health to the local FRR daemon through vtysh,
+// so BGP or OSPF neighbours react to the same health checks as the agent's
+// policy rules: a provider going down can withdraw a default route or lower
+// its local-preference, and coming back up restores it. What to run is
+// configured per provider (config.FRRConfig); this package only decides
+// when to run it.
+package frr
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"router-sync/internal/config"
+	"router-sync/internal/models"
+)
+
+// RunFunc runs vtysh with args and returns its combined output.
+type RunFunc func(ctx context.Context, path string, args []string) ([]byte, error)
+
+// Signaler runs each provider's up or down lines once per state change. It
+// remembers the state last signalled, so repeating a state is a no-op and
+// an agent restart signals every provider's current state once.
+type Signaler struct {
+	cfg      config.FRRConfig
+	hostname string
+	run      RunFunc
+
+	mu      sync.Mutex
+	applied map[string]bool // provider ID -> up, as last signalled
+}
+
+// New creates a Signaler for cfg on hostname.
+func New(cfg config.FRRConfig, hostname string) *Signaler {
+	return &Signaler{
+		cfg:      cfg,
+		hostname: hostname,
+		run:      runVtysh,
+		applied:  make(map[string]bool),
+	}
+}
+
+// Signal runs p's up or down lines unless that state was already signalled.
+// It returns whether vtysh was run. A failed run is not remembered, so the
+// next Signal or Reconcile retries it.
+func (s *Signaler) Signal(p *models.InternetProvider, up bool) (bool, error) {
+	pc, ok := s.cfg.Providers[p.ID]
+	if !ok {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.applied[p.ID]; ok && last == up {
+		return false, nil
+	}
+
+	lines := pc.Down
+	if up {
+		lines = pc.Up
+	}
+	if len(lines) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		defer cancel()
+		out, err := s.run(ctx, s.cfg.VtyshPath, vtyshArgs(lines, s.placeholders(p)))
+		if err == nil {
+			err = outputError(out)
+		}
+		if err != nil {
+			return true, fmt.Errorf("vtysh for provider %s (%s): %w", p.ID, State(up), err)
+		}
+	}
+	s.applied[p.ID] = up
+	return true, nil
+}
+
+// Reconcile signals the state of every configured provider that has a
+// health status, to catch up after a restart, a failed run or maintenance.
+// onResult is called for every provider Signal ran vtysh for.
+func (s *Signaler) Reconcile(providers map[string]*models.InternetProvider, up map[string]bool, onResult func(p *models.InternetProvider, up bool, err error)) {
+	for id := range s.cfg.Providers {
+		p, ok := providers[id]
+		if !ok {
+			continue
+		}
+		isUp, ok := up[id]
+		if !ok {
+			continue
+		}
+		if ran, err := s.Signal(p, isUp); ran {
+			onResult(p, isUp, err)
+		}
+	}
+}
+
+// Forget drops the remembered state of providers not in keep, so a provider
+// that is removed and re-added is signalled again.
+func (s *Signaler) Forget(keep map[string]*models.InternetProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.applied {
+		if _, ok := keep[id]; !ok {
+			delete(s.applied, id)
+		}
+	}
+}
+
+// placeholders returns the replacer for p's lines on this router.
+func (s *Signaler) placeholders(p *models.InternetProvider) *strings.Replacer {
+	return strings.NewReplacer(
+		"{provider}", p.ID,
+		"{name}", p.Name,
+		"{hostname}", s.hostname,
+		"{interface}", p.InterfaceForHost(s.hostname),
+		"{gateway}", p.Gateway,
+		"{table}", strconv.Itoa(p.TableID),
+	)
+}
+
+// vtyshArgs builds the vtysh arguments that run lines in configuration mode.
+func vtyshArgs(lines []string, r *strings.Replacer) []string {
+	args := []string{"-c", "configure terminal"}
+	for _, line := range lines {
+		args = append(args, "-c", r.Replace(line))
+	}
+	return append(args, "-c", "end")
+}
+
+// outputError turns the first "%" message vtysh printed into an error: older
+// releases exit 0 even when a command is rejected.
+func outputError(out []byte) error {
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "%") {
+			return fmt.Errorf("%s", line)
+		}
+	}
+	return nil
+}
+
+// State names a provider state for logs and metrics.
+func State(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
+// runVtysh runs vtysh; a non-zero exit includes its output in the error.
+func runVtysh(ctx context.Context, path string, args []string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return out, ctx.Err()
+		}
+		return out, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package frr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"router-sync/internal/config"
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVtysh records the argument lists it was run with.
+type fakeVtysh struct {
+	calls [][]string
+	out   string
+	err   error
+}
+
+func (f *fakeVtysh) run(_ context.Context, path string, args []string) ([]byte, error) {
+	f.calls = append(f.calls, append([]string{path}, args...))
+	return []byte(f.out), f.err
+}
+
+func testSignaler(f *fakeVtysh) *Signaler {
+	s := New(config.FRRConfig{
+		VtyshPath: "/usr/bin/vtysh",
+		Timeout:   time.Second,
+		Providers: map[string]config.FRRProviderConfig{
+			"isp1": {
+				Down: []string{"router bgp 65001", "address-family ipv4 unicast", "no network 0.0.0.0/0 route-map {provider}-{hostname}"},
+				Up:   []string{"router bgp 65001", "address-family ipv4 unicast", "network 0.0.0.0/0 route-map {provider}-{hostname}"},
+			},
+		},
+	}, "r1")
+	s.run = f.run
+	return s
+}
+
+func TestSignalRunsLinesOncePerState(t *testing.T) {
+	f := &fakeVtysh{}
+	s := testSignaler(f)
+	isp1 := &models.InternetProvider{ID: "isp1", TableID: 100}
+
+	ran, err := s.Signal(isp1, false)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, []string{"/usr/bin/vtysh",
+		"-c", "configure terminal",
+		"-c", "router bgp 65001",
+		"-c", "address-family ipv4 unicast",
+		"-c", "no network 0.0.0.0/0 route-map isp1-r1",
+		"-c", "end",
+	}, f.calls[0])
+
+	ran, err = s.Signal(isp1, false)
+	require.NoError(t, err)
+	assert.False(t, ran, "repeated state must not run vtysh")
+
+	ran, err = s.Signal(isp1, true)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, "network 0.0.0.0/0 route-map isp1-r1", f.calls[1][8])
+
+	ran, _ = s.Signal(&models.InternetProvider{ID: "isp2"}, false)
+	assert.False(t, ran, "unconfigured provider")
+	assert.Len(t, f.calls, 2)
+}
+
+func TestSignalRetriesFailures(t *testing.T) {
+	f := &fakeVtysh{out: "% Unknown command: no network\n"}
+	s := testSignaler(f)
+	isp1 := &models.InternetProvider{ID: "isp1"}
+
+	ran, err := s.Signal(isp1, false)
+	assert.True(t, ran)
+	assert.ErrorContains(t, err, "% Unknown command")
+
+	f.out, f.err = "", errors.New("exit status 1")
+	_, err = s.Signal(isp1, false)
+	assert.Error(t, err)
+
+	f.err = nil
+	ran, err = s.Signal(isp1, false)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Len(t, f.calls, 3)
+}
+
+func TestReconcile(t *testing.T) {
+	f := &fakeVtysh{}
+	s := testSignaler(f)
+	providers := map[string]*models.InternetProvider{"isp1": {ID: "isp1"}}
+
+	var results []string
+	onResult := func(p *models.InternetProvider, up bool, err error) {
+		results = append(results, p.ID+":"+State(up))
+	}
+
+	s.Reconcile(providers, map[string]bool{}, onResult)
+	assert.Empty(t, results, "no health status yet")
+
+	s.Reconcile(providers, map[string]bool{"isp1": true}, onResult)
+	s.Reconcile(providers, map[string]bool{"isp1": true}, onResult)
+	assert.Equal(t, []string{"isp1:up"}, results)
+
+	s.Forget(map[string]*models.InternetProvider{})
+	s.Reconcile(providers, map[string]bool{"isp1": true}, onResult)
+	assert.Equal(t, []string{"isp1:up", "isp1:up"}, results)
+}
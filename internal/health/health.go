@@ -0,0 +1,347 @@
+// Package health probes each provider's uplink from the agent and keeps its
+// up/down state. Probes follow the provider's models.HealthCheck: every
+// Interval all targets are probed through the provider's table (see
+// router.SyncProbeRules); a round succeeds when any target answers within
+// Timeout. FailThreshold failed rounds in a row take a provider down and
+// RiseThreshold successful ones bring it back. Providers start up, so an
+// agent restart does not move traffic before the first rounds complete.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lossWindow is how many recent probes the loss ratio covers.
+const lossWindow = 20
+
+// flapWindow is the period Status.Flaps counts transitions over.
+const flapWindow = time.Hour
+
+// ProbeFunc probes one target of a check, marking its packets with mark,
+// and returns the round-trip time.
+type ProbeFunc func(ctx context.Context, checkType, target string, mark int, timeout time.Duration) (time.Duration, error)
+
+// Status is a provider's health on this router. Downtime adds up the time
+// the provider was down, up to the last probe round, since its checker
+// started; Flaps counts its up/down transitions in the last hour.
+type Status struct {
+	Up          bool          `json:"up"`
+	Since       time.Time     `json:"since"`
+	RTT         time.Duration `json:"rtt_ns,omitempty"`
+	Loss        float64       `json:"loss"`
+	LastError   string        `json:"last_error,omitempty"`
+	LastProbeAt time.Time     `json:"last_probe_at,omitempty"`
+	Downtime    time.Duration `json:"downtime_ns"`
+	Flaps       int           `json:"flaps"`
+}
+
+// Monitor runs one checker per provider that has a health check and an
+// interface on this router.
+type Monitor struct {
+	hostname string
+	probe    ProbeFunc
+	metrics  *metrics
+	onChange func(providerID string, st Status)
+
+	ctx      context.Context
+	mu       sync.Mutex
+	checkers map[string]*checker
+	wg       sync.WaitGroup
+}
+
+// NewMonitor creates a Monitor; probes stop when ctx is done. onChange (if
+// not nil) is called from the checker goroutine on every up/down
+// transition. The probe metrics are registered on reg.
+func NewMonitor(ctx context.Context, hostname string, reg prometheus.Registerer, onChange func(providerID string, st Status)) *Monitor {
+	return &Monitor{
+		hostname: hostname,
+		probe:    Probe,
+		metrics:  newMetrics(reg),
+		onChange: onChange,
+		ctx:      ctx,
+		checkers: make(map[string]*checker),
+	}
+}
+
+// Update starts, restarts or stops checkers so there is one per provider
+// with a health check and an interface here. marks holds each provider's
+// probe mark. A checker whose provider did not change keeps its state.
+func (m *Monitor) Update(providers []*models.InternetProvider, marks map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	want := make(map[string]*models.InternetProvider)
+	for _, p := range providers {
+		if p.HealthCheck != nil && p.InterfaceForHost(m.hostname) != "" {
+			want[p.ID] = p
+		}
+	}
+	for id, c := range m.checkers {
+		p, ok := want[id]
+		if ok && c.matches(p, marks[id]) {
+			continue
+		}
+		c.stop()
+		delete(m.checkers, id)
+		if !ok {
+			m.metrics.forget(id)
+		}
+	}
+	for id, p := range want {
+		if _, ok := m.checkers[id]; ok {
+			continue
+		}
+		c := newChecker(p, marks[id], m)
+		m.checkers[id] = c
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			c.run()
+		}()
+	}
+}
+
+// Statuses returns the state of every checked provider, by provider ID.
+func (m *Monitor) Statuses() map[string]Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]Status, len(m.checkers))
+	for id, c := range m.checkers {
+		out[id] = c.status()
+	}
+	return out
+}
+
+// Stop stops all checkers and waits for them.
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	for id, c := range m.checkers {
+		c.stop()
+		delete(m.checkers, id)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// ProbeMarks assigns each provider with a health check a probe mark
+// (models.ProbeMarkBase + n, in table order so they stay stable) and
+// returns them by provider ID along with the mark -> table map for
+// router.SyncProbeRules.
+func ProbeMarks(providers []*models.InternetProvider, hostname string) (byProvider map[string]int, rules map[int]int) {
+	var checked []*models.InternetProvider
+	for _, p := range providers {
+		if p.HealthCheck != nil && p.InterfaceForHost(hostname) != "" {
+			checked = append(checked, p)
+		}
+	}
+	sort.Slice(checked, func(i, j int) bool {
+		if checked[i].TableID != checked[j].TableID {
+			return checked[i].TableID < checked[j].TableID
+		}
+		return checked[i].ID < checked[j].ID
+	})
+	byProvider = make(map[string]int, len(checked))
+	rules = make(map[int]int, len(checked))
+	for i, p := range checked {
+		mark := models.ProbeMarkBase + i
+		byProvider[p.ID] = mark
+		rules[mark] = p.TableID
+	}
+	return byProvider, rules
+}
+
+// checker probes one provider.
+type checker struct {
+	providerID string
+	check      models.HealthCheck
+	targets    []string
+	mark       int
+	monitor    *Monitor
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	st        Status
+	fails     int
+	successes int
+	results   []bool // last lossWindow probes, true = answered
+	// accountedAt is when downtime was last brought up to date.
+	accountedAt time.Time
+	flaps       []time.Time // transitions within flapWindow
+}
+
+func newChecker(p *models.InternetProvider, mark int, m *Monitor) *checker {
+	check := *p.HealthCheck
+	check.ApplyDefaults()
+	ctx, cancel := context.WithCancel(m.ctx)
+	return &checker{
+		providerID:  p.ID,
+		check:       check,
+		targets:     check.TargetsFor(p),
+		mark:        mark,
+		monitor:     m,
+		ctx:         ctx,
+		cancel:      cancel,
+		st:          Status{Up: true, Since: time.Now()},
+		accountedAt: time.Now(),
+	}
+}
+
+// matches reports whether the checker already probes p as configured.
+func (c *checker) matches(p *models.InternetProvider, mark int) bool {
+	check := *p.HealthCheck
+	check.ApplyDefaults()
+	targets := check.TargetsFor(p)
+	if mark != c.mark || len(targets) != len(c.targets) ||
+		check.Type != c.check.Type || check.Interval != c.check.Interval || check.Timeout != c.check.Timeout ||
+		check.FailThreshold != c.check.FailThreshold || check.RiseThreshold != c.check.RiseThreshold {
+		return false
+	}
+	for i := range targets {
+		if targets[i] != c.targets[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *checker) stop() {
+	c.cancel()
+}
+
+func (c *checker) status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.st
+}
+
+func (c *checker) run() {
+	ticker := time.NewTicker(c.check.IntervalDuration())
+	defer ticker.Stop()
+	for {
+		c.round()
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// round probes every target in parallel and records the outcome.
+func (c *checker) round() {
+	if len(c.targets) == 0 {
+		return
+	}
+	type result struct {
+		target string
+		rtt    time.Duration
+		err    error
+	}
+	timeout := c.check.TimeoutDuration()
+	results := make(chan result, len(c.targets))
+	for _, target := range c.targets {
+		go func(target string) {
+			ctx, cancel := context.WithTimeout(c.ctx, timeout)
+			defer cancel()
+			rtt, err := c.monitor.probe(ctx, c.check.Type, target, c.mark, timeout)
+			results <- result{target: target, rtt: rtt, err: err}
+		}(target)
+	}
+
+	var best time.Duration
+	var lastErr error
+	ok := false
+	answered := make([]bool, 0, len(c.targets))
+	for range c.targets {
+		r := <-results
+		if c.ctx.Err() != nil {
+			return
+		}
+		c.monitor.metrics.observeProbe(c.providerID, r.target, r.rtt, r.err)
+		answered = append(answered, r.err == nil)
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if !ok || r.rtt < best {
+			best = r.rtt
+		}
+		ok = true
+	}
+	c.record(ok, best, lastErr, answered)
+}
+
+// record applies one round to the state and thresholds.
+func (c *checker) record(ok bool, rtt time.Duration, err error, answered []bool) {
+	c.mu.Lock()
+	now := time.Now()
+	c.results = append(c.results, answered...)
+	if len(c.results) > lossWindow {
+		c.results = c.results[len(c.results)-lossWindow:]
+	}
+	lost := 0
+	for _, a := range c.results {
+		if !a {
+			lost++
+		}
+	}
+	c.st.Loss = float64(lost) / float64(len(c.results))
+	c.st.LastProbeAt = now
+
+	// Downtime grows by the time since the last round while down.
+	var down time.Duration
+	if !c.st.Up {
+		down = now.Sub(c.accountedAt)
+		c.st.Downtime += down
+	}
+	c.accountedAt = now
+	if ok {
+		c.st.RTT = rtt
+		c.st.LastError = ""
+		c.successes++
+		c.fails = 0
+	} else {
+		c.st.LastError = err.Error()
+		c.fails++
+		c.successes = 0
+	}
+
+	changed := false
+	switch {
+	case c.st.Up && c.fails >= c.check.FailThreshold:
+		c.st.Up, c.st.Since, changed = false, now, true
+	case !c.st.Up && c.successes >= c.check.RiseThreshold:
+		c.st.Up, c.st.Since, changed = true, now, true
+	}
+	if changed {
+		c.flaps = append(c.flaps, now)
+	}
+	for len(c.flaps) > 0 && now.Sub(c.flaps[0]) > flapWindow {
+		c.flaps = c.flaps[1:]
+	}
+	c.st.Flaps = len(c.flaps)
+	st := c.st
+	c.mu.Unlock()
+
+	c.monitor.metrics.observeState(c.providerID, st, changed, down)
+	if changed {
+		state := "down"
+		if st.Up {
+			state = "up"
+		}
+		logging.Provider(c.providerID).Warnf("Provider %s is %s (health check: %s)", c.providerID, state, st.LastError)
+		if c.monitor.onChange != nil {
+			c.monitor.onChange(c.providerID, st)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProvider(id string, table int) *models.InternetProvider {
+	return &models.InternetProvider{
+		ID:         id,
+		TableID:    table,
+		Gateway:    "10.0.0.1",
+		Interfaces: map[string]string{"r1": "eth0"},
+		HealthCheck: &models.HealthCheck{
+			Type:          models.HealthCheckTCP,
+			Targets:       []string{"a:1", "b:1"},
+			Interval:      "5ms",
+			Timeout:       "5ms",
+			FailThreshold: 2,
+			RiseThreshold: 2,
+		},
+	}
+}
+
+func TestCheckerThresholds(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMonitor(context.Background(), "r1", reg, nil)
+	c := newChecker(testProvider("isp1", 100), models.ProbeMarkBase, m)
+
+	fail := errors.New("timeout")
+	c.record(false, 0, fail, []bool{false, false})
+	assert.True(t, c.status().Up, "one failed round stays up")
+	c.record(false, 0, fail, []bool{false, false})
+	st := c.status()
+	assert.False(t, st.Up)
+	assert.Equal(t, "timeout", st.LastError)
+	assert.Equal(t, 1.0, st.Loss)
+
+	c.record(true, 3*time.Millisecond, nil, []bool{true, false})
+	assert.False(t, c.status().Up, "one good round stays down")
+	c.record(true, 3*time.Millisecond, nil, []bool{true, true})
+	st = c.status()
+	assert.True(t, st.Up)
+	assert.Equal(t, 3*time.Millisecond, st.RTT)
+	assert.InDelta(t, 5.0/8, st.Loss, 1e-9)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.transitions.WithLabelValues("isp1", "down")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.transitions.WithLabelValues("isp1", "up")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.metrics.up.WithLabelValues("isp1")))
+}
+
+func TestCheckerDowntimeAndFlaps(t *testing.T) {
+	m := NewMonitor(context.Background(), "r1", prometheus.NewRegistry(), nil)
+	c := newChecker(testProvider("isp1", 100), models.ProbeMarkBase, m)
+
+	fail := errors.New("timeout")
+	c.record(false, 0, fail, []bool{false})
+	c.record(false, 0, fail, []bool{false})
+	st := c.status()
+	require.False(t, st.Up)
+	assert.Zero(t, st.Downtime, "downtime starts at the transition")
+	assert.Equal(t, 1, st.Flaps)
+
+	// A minute passes before the next round.
+	c.mu.Lock()
+	c.accountedAt = c.accountedAt.Add(-time.Minute)
+	c.mu.Unlock()
+	c.record(false, 0, fail, []bool{false})
+	assert.InDelta(t, time.Minute.Seconds(), c.status().Downtime.Seconds(), 1)
+	assert.InDelta(t, 60, testutil.ToFloat64(m.metrics.downtime.WithLabelValues("isp1")), 1)
+
+	c.record(true, time.Millisecond, nil, []bool{true})
+	c.record(true, time.Millisecond, nil, []bool{true})
+	st = c.status()
+	require.True(t, st.Up)
+	assert.Equal(t, 2, st.Flaps)
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.metrics.flaps.WithLabelValues("isp1")))
+
+	// Transitions older than the flap window no longer count.
+	c.mu.Lock()
+	for i := range c.flaps {
+		c.flaps[i] = c.flaps[i].Add(-2 * flapWindow)
+	}
+	c.mu.Unlock()
+	c.record(true, time.Millisecond, nil, []bool{true})
+	assert.Zero(t, c.status().Flaps)
+	assert.InDelta(t, time.Minute.Seconds(), c.status().Downtime.Seconds(), 1, "up rounds add no downtime")
+}
+
+func TestMonitorProbesAndReportsTransitions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var down atomic.Bool
+	var mu sync.Mutex
+	var changes []bool
+	m := NewMonitor(ctx, "r1", prometheus.NewRegistry(), func(id string, st Status) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, st.Up)
+	})
+	var marks sync.Map
+	m.probe = func(ctx context.Context, checkType, target string, mark int, timeout time.Duration) (time.Duration, error) {
+		marks.Store(mark, true)
+		if down.Load() {
+			return 0, errors.New("no answer")
+		}
+		return time.Millisecond, nil
+	}
+
+	providers := []*models.InternetProvider{testProvider("isp1", 100)}
+	byProvider, rules := ProbeMarks(providers, "r1")
+	m.Update(providers, byProvider)
+	defer m.Stop()
+
+	down.Store(true)
+	require.Eventually(t, func() bool { return !m.Statuses()["isp1"].Up }, time.Second, time.Millisecond)
+	down.Store(false)
+	require.Eventually(t, func() bool { return m.Statuses()["isp1"].Up }, time.Second, time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []bool{false, true}, changes)
+	mu.Unlock()
+	_, ok := marks.Load(models.ProbeMarkBase)
+	assert.True(t, ok, "probes carry the provider's mark")
+	assert.Equal(t, map[int]int{models.ProbeMarkBase: 100}, rules)
+
+	// A provider without a health check is no longer probed
+	m.Update([]*models.InternetProvider{{ID: "isp1", TableID: 100}}, nil)
+	assert.Empty(t, m.Statuses())
+}
+
+func TestProbeMarks(t *testing.T) {
+	providers := []*models.InternetProvider{
+		testProvider("lte", 300),
+		testProvider("fiber", 100),
+		{ID: "unchecked", TableID: 200, Interfaces: map[string]string{"r1": "eth2"}},
+		{ID: "elsewhere", TableID: 400, Interfaces: map[string]string{"r2": "eth0"}, HealthCheck: &models.HealthCheck{}},
+	}
+	byProvider, rules := ProbeMarks(providers, "r1")
+	assert.Equal(t, map[string]int{"fiber": models.ProbeMarkBase, "lte": models.ProbeMarkBase + 1}, byProvider)
+	assert.Equal(t, map[int]int{models.ProbeMarkBase: 100, models.ProbeMarkBase + 1: 300}, rules)
+}
+
+func TestParsePingTime(t *testing.T) {
+	rtt, err := parsePingTime("64 bytes from 1.1.1.1: icmp_seq=1 ttl=57 time=12.4 ms\n")
+	require.NoError(t, err)
+	assert.Equal(t, 12400*time.Microsecond, rtt)
+
+	_, err = parsePingTime("1 packets transmitted, 0 received")
+	assert.Error(t, err)
+}
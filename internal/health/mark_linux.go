@@ -0,0 +1,10 @@
+//go:build linux
+
+package health
+
+import "syscall"
+
+// setMark sets SO_MARK on a socket so the probe rule routes it.
+func setMark(fd uintptr, mark int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark)
+}
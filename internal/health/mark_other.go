@@ -0,0 +1,9 @@
+//go:build !linux
+
+package health
+
+// setMark is a no-op off Linux, where the agent does not run; probes then
+// follow the default route.
+func setMark(fd uintptr, mark int) error {
+	return nil
+}
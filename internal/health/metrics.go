@@ -0,0 +1,104 @@
+package health
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are the probe metrics, labelled by provider (and target).
+type metrics struct {
+	rtt         *prometheus.HistogramVec
+	probes      *prometheus.CounterVec
+	loss        *prometheus.GaugeVec
+	up          *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+	stateAge    *prometheus.GaugeVec
+	downtime    *prometheus.CounterVec
+	flaps       *prometheus.GaugeVec
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		rtt: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_health_probe_rtt_seconds",
+			Help:    "Round-trip time of answered health probes.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"provider", "target"}),
+		probes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_health_probes_total",
+			Help: "Number of health probes sent, by result (success or failure).",
+		}, []string{"provider", "target", "result"}),
+		loss: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "agent_health_probe_loss_ratio",
+			Help: "Share of the provider's last 20 probes that got no answer.",
+		}, []string{"provider"}),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "agent_provider_up",
+			Help: "1 while the provider's health check passes, 0 while it is down.",
+		}, []string{"provider"}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_health_state_transitions_total",
+			Help: "Number of provider up/down transitions, by the state entered.",
+		}, []string{"provider", "state"}),
+		stateAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "agent_health_state_seconds",
+			Help: "Seconds the provider has been in its current up/down state.",
+		}, []string{"provider"}),
+		downtime: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_provider_downtime_seconds_total",
+			Help: "Seconds the provider has been down on this router, updated every probe round.",
+		}, []string{"provider"}),
+		flaps: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "agent_provider_flaps",
+			Help: "Number of up/down transitions of the provider in the last hour.",
+		}, []string{"provider"}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.rtt, m.probes, m.loss, m.up, m.transitions, m.stateAge, m.downtime, m.flaps)
+	}
+	return m
+}
+
+func (m *metrics) observeProbe(provider, target string, rtt time.Duration, err error) {
+	if err != nil {
+		m.probes.WithLabelValues(provider, target, "failure").Inc()
+		return
+	}
+	m.probes.WithLabelValues(provider, target, "success").Inc()
+	m.rtt.WithLabelValues(provider, target).Observe(rtt.Seconds())
+}
+
+// observeState records a probe round that left the provider in st after
+// down more seconds of downtime.
+func (m *metrics) observeState(provider string, st Status, changed bool, down time.Duration) {
+	m.loss.WithLabelValues(provider).Set(st.Loss)
+	m.downtime.WithLabelValues(provider).Add(down.Seconds())
+	m.flaps.WithLabelValues(provider).Set(float64(st.Flaps))
+	m.stateAge.WithLabelValues(provider).Set(time.Since(st.Since).Seconds())
+	up := 0.0
+	if st.Up {
+		up = 1
+	}
+	m.up.WithLabelValues(provider).Set(up)
+	if changed {
+		state := "down"
+		if st.Up {
+			state = "up"
+		}
+		m.transitions.WithLabelValues(provider, state).Inc()
+	}
+}
+
+// forget drops the series of a provider that is no longer checked.
+func (m *metrics) forget(provider string) {
+	labels := prometheus.Labels{"provider": provider}
+	m.rtt.DeletePartialMatch(labels)
+	m.probes.DeletePartialMatch(labels)
+	m.loss.DeletePartialMatch(labels)
+	m.up.DeletePartialMatch(labels)
+	m.transitions.DeletePartialMatch(labels)
+	m.stateAge.DeletePartialMatch(labels)
+	m.downtime.DeletePartialMatch(labels)
+	m.flaps.DeletePartialMatch(labels)
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+// pingCommand is the ping binary used for ICMP probes.
+var pingCommand = "ping"
+
+var pingTimeRe = regexp.MustCompile(`time[=<]([0-9.]+) ms`)
+
+// Probe is the default ProbeFunc: ICMP echo through the ping binary, a TCP
+// connect, or an HTTP GET where any status below 500 counts as up.
+func Probe(ctx context.Context, checkType, target string, mark int, timeout time.Duration) (time.Duration, error) {
+	switch checkType {
+	case models.HealthCheckTCP:
+		start := time.Now()
+		conn, err := dialer(mark, timeout).DialContext(ctx, "tcp", target)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+		return time.Since(start), nil
+	case models.HealthCheckHTTP:
+		return probeHTTP(ctx, target, mark, timeout)
+	default:
+		return probePing(ctx, target, mark, timeout)
+	}
+}
+
+func probePing(ctx context.Context, target string, mark int, timeout time.Duration) (time.Duration, error) {
+	seconds := int(timeout.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	args := []string{"-n", "-c", "1", "-W", strconv.Itoa(seconds)}
+	if mark != 0 {
+		args = append(args, "-m", strconv.Itoa(mark))
+	}
+	output, err := exec.CommandContext(ctx, pingCommand, append(args, target)...).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("no answer from %s", target)
+	}
+	return parsePingTime(string(output))
+}
+
+// parsePingTime reads the RTT from ping output ("time=12.3 ms").
+func parsePingTime(output string) (time.Duration, error) {
+	match := pingTimeRe.FindStringSubmatch(output)
+	if match == nil {
+		return 0, errors.New("ping answered without a round-trip time")
+	}
+	ms, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+func probeHTTP(ctx context.Context, target string, mark int, timeout time.Duration) (time.Duration, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       dialer(mark, timeout).DialContext,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return 0, fmt.Errorf("%s answered %d", target, resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// dialer returns a dialer whose sockets carry mark.
+func dialer(mark int, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if mark != 0 {
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) { sockErr = setMark(fd, mark) }); err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+	return d
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Components are the names accepted by SetComponentLevels: the emitting
+// package, as reported in the component field of JSON logs. "sync" is an
+// alias for "agent", which runs the sync loop.
+var Components = []string{"agent", "api", "auth", "main", "nats", "router", "state", "webhook"}
+
+var componentAliases = map[string]string{"sync": "agent"}
+
+// componentLevels overrides the process level for single components; guarded
+// by mu.
+var componentLevels = map[string]logrus.Level{}
+
+// SetComponentLevels sets per-component levels from names ("router" ->
+// "debug"), replacing any previous ones. Entries from other components keep
+// the process level (SetLevel). Filtering needs the caller of every entry, so
+// non-empty levels turn on logrus caller reporting; the text format still
+// prints no caller.
+func SetComponentLevels(levels map[string]string) error {
+	parsed := make(map[string]logrus.Level, len(levels))
+	for name, value := range levels {
+		component, err := parseComponent(name)
+		if err != nil {
+			return err
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return fmt.Errorf("log_levels.%s: %w", name, err)
+		}
+		parsed[component] = level
+	}
+
+	mu.Lock()
+	componentLevels = parsed
+	mu.Unlock()
+
+	if len(parsed) > 0 {
+		logrus.SetReportCaller(true)
+	}
+	applyLoggerLevel()
+	return nil
+}
+
+// ComponentLevels returns the per-component overrides as names.
+func ComponentLevels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]string, len(componentLevels))
+	for c, l := range componentLevels {
+		out[c] = l.String()
+	}
+	return out
+}
+
+func parseComponent(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := componentAliases[name]; ok {
+		name = alias
+	}
+	i := sort.SearchStrings(Components, name)
+	if i == len(Components) || Components[i] != name {
+		return "", fmt.Errorf("unknown log component %q: use %s or sync", name, strings.Join(Components, ", "))
+	}
+	return name, nil
+}
+
+// applyLoggerLevel sets the logrus level to the most verbose of the process
+// level and the component levels, so entries reach componentFilter, which
+// then drops the ones their component does not want.
+func applyLoggerLevel() {
+	mu.RLock()
+	level := currentLevel
+	for _, l := range componentLevels {
+		if l > level {
+			level = l
+		}
+	}
+	mu.RUnlock()
+	logrus.SetLevel(level)
+}
+
+// componentFilter wraps the active formatter and suppresses entries below
+// their component's level. Returning no bytes makes logrus write nothing.
+type componentFilter struct {
+	logrus.Formatter
+}
+
+func (f componentFilter) Format(e *logrus.Entry) ([]byte, error) {
+	if !enabledFor(e) {
+		return nil, nil
+	}
+	return f.Formatter.Format(e)
+}
+
+func enabledFor(e *logrus.Entry) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(componentLevels) == 0 {
+		return true
+	}
+	threshold := currentLevel
+	if level, ok := componentLevels[entryComponent(e)]; ok {
+		threshold = level
+	}
+	return e.Level <= threshold
+}
+
+func entryComponent(e *logrus.Entry) string {
+	if c, ok := e.Data[FieldComponent].(string); ok {
+		return c
+	}
+	if e.Caller != nil {
+		return componentOf(e.Caller.Function)
+	}
+	return ""
+}
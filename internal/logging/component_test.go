@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentLevels(t *testing.T) {
+	defer func() { _ = SetComponentLevels(nil) }()
+
+	assert.Error(t, SetComponentLevels(map[string]string{"routr": "debug"}))
+	assert.Error(t, SetComponentLevels(map[string]string{"router": "loud"}))
+
+	SetLevel(logrus.WarnLevel)
+	assert.NoError(t, SetComponentLevels(map[string]string{"router": "debug", "sync": "error"}))
+	assert.Equal(t, map[string]string{"router": "debug", "agent": "error"}, ComponentLevels())
+
+	entry := func(function string, level logrus.Level) *logrus.Entry {
+		return &logrus.Entry{Data: logrus.Fields{}, Level: level, Caller: &runtime.Frame{Function: function}}
+	}
+	assert.True(t, enabledFor(entry("router-sync/internal/router.(*Manager).SetupPolicy", logrus.DebugLevel)))
+	assert.False(t, enabledFor(entry("router-sync/internal/agent.(*Service).performFullSync", logrus.WarnLevel)))
+	assert.True(t, enabledFor(entry("router-sync/internal/nats.(*Client).Close", logrus.WarnLevel)))
+	assert.False(t, enabledFor(entry("router-sync/internal/nats.(*Client).Close", logrus.InfoLevel)))
+
+	jsonEntry := entry("", logrus.DebugLevel)
+	jsonEntry.Data[FieldComponent] = "router"
+	assert.True(t, enabledFor(jsonEntry))
+}
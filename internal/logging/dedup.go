@@ -0,0 +1,170 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dedupMaxKeys bounds the remembered messages; past it, those not repeated
+// within the window are forgotten.
+const dedupMaxKeys = 1024
+
+var dedup = &deduper{seen: make(map[string]*dedupState)}
+
+// SetDedupWindow makes the logger drop repeats of an entry (same level,
+// message and fields but sync_id and request_id) for window after it was
+// written. The next copy after
+// the window is written with " (suppressed N identical messages)" appended
+// and a suppressed field. When no copy follows, the last one dropped is
+// written that way instead once the window has passed and any other entry
+// is logged; counts still pending when the process exits are lost. window
+// <= 0 turns deduplication off.
+func SetDedupWindow(window time.Duration) {
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+	dedup.window = window
+	dedup.seen = make(map[string]*dedupState)
+	dedup.flushedAt = time.Time{}
+}
+
+// dedupState is what is known of one message: when it was last written,
+// and the copies dropped since, the last of which is kept for flush.
+type dedupState struct {
+	written    time.Time
+	suppressed int
+	last       *logrus.Entry
+}
+
+// dedupFlushEvery bounds how often admit looks for suppressed counts to
+// flush.
+const dedupFlushEvery = time.Second
+
+type deduper struct {
+	mu        sync.Mutex
+	window    time.Duration
+	seen      map[string]*dedupState
+	flushedAt time.Time
+}
+
+// admit reports whether e should be written and how many copies of it were
+// dropped since it last was. flushed holds the dropped copies of other
+// messages to write first, see flush.
+func (d *deduper) admit(e *logrus.Entry) (ok bool, suppressed int, flushed []*logrus.Entry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.window <= 0 || e.Level <= logrus.FatalLevel {
+		return true, 0, nil
+	}
+	key := dedupKey(e)
+	if since := e.Time.Sub(d.flushedAt); since >= dedupFlushEvery || since < 0 {
+		flushed = d.flush(e.Time, key)
+		d.flushedAt = e.Time
+	}
+	st, ok := d.seen[key]
+	if ok && e.Time.Sub(st.written) < d.window {
+		st.suppressed++
+		st.last = e.Dup()
+		st.last.Level, st.last.Message, st.last.Caller = e.Level, e.Message, e.Caller
+		return false, 0, flushed
+	}
+	if !ok {
+		if len(d.seen) >= dedupMaxKeys {
+			d.prune(e.Time)
+		}
+		st = &dedupState{}
+		d.seen[key] = st
+	}
+	suppressed = st.suppressed
+	st.written, st.suppressed, st.last = e.Time, 0, nil
+	return true, suppressed, flushed
+}
+
+// flush returns the last dropped copy of every message but skip whose
+// window has passed, each with the number of copies dropped before it; the
+// copy then counts as written.
+func (d *deduper) flush(now time.Time, skip string) []*logrus.Entry {
+	var out []*logrus.Entry
+	for key, st := range d.seen {
+		if key == skip || st.suppressed == 0 || now.Sub(st.written) < d.window {
+			continue
+		}
+		out = append(out, withSuppressed(st.last, st.suppressed-1))
+		st.written, st.suppressed, st.last = st.last.Time, 0, nil
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out
+}
+
+// withSuppressed returns e noting that n copies of it were dropped, or e
+// itself when none were.
+func withSuppressed(e *logrus.Entry, n int) *logrus.Entry {
+	if n == 0 {
+		return e
+	}
+	dup := e.Dup()
+	dup.Level, dup.Caller = e.Level, e.Caller
+	dup.Data[FieldSuppressed] = n
+	dup.Message = fmt.Sprintf("%s (suppressed %d identical messages)", e.Message, n)
+	return dup
+}
+
+// prune forgets the messages whose window has passed without repeats.
+func (d *deduper) prune(now time.Time) {
+	for key, st := range d.seen {
+		if now.Sub(st.written) >= d.window && st.suppressed == 0 {
+			delete(d.seen, key)
+		}
+	}
+}
+
+// dedupKey identifies e's repeats. The sync and request IDs are left out:
+// they differ on every run of the same code path.
+func dedupKey(e *logrus.Entry) string {
+	var b strings.Builder
+	b.WriteString(e.Level.String())
+	b.WriteByte('|')
+	b.WriteString(e.Message)
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		if k == FieldSyncID || k == FieldRequestID {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "|%s=%v", k, e.Data[k])
+	}
+	return b.String()
+}
+
+// dedupFilter wraps a formatter and drops repeated entries, see
+// SetDedupWindow.
+type dedupFilter struct {
+	logrus.Formatter
+}
+
+func (f dedupFilter) Format(e *logrus.Entry) ([]byte, error) {
+	ok, suppressed, flushed := dedup.admit(e)
+	var out []byte
+	for _, fe := range flushed {
+		line, err := f.Formatter.Format(fe)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, line...)
+	}
+	if !ok {
+		return out, nil
+	}
+	line, err := f.Formatter.Format(withSuppressed(e, suppressed))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, line...), nil
+}
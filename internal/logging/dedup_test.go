@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	SetDedupWindow(time.Minute)
+	defer SetDedupWindow(0)
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(dedupFilter{&logrus.TextFormatter{DisableTimestamp: true}})
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		logger.WithTime(base.Add(time.Duration(i) * time.Second)).Info("sync finished")
+	}
+	logger.WithTime(base).Warn("sync finished")                              // other level
+	logger.WithTime(base).WithField("policy_id", "p1").Info("sync finished") // other fields
+	logger.WithTime(base.Add(2 * time.Minute)).Info("sync finished")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, `level=info msg="sync finished"`, lines[0])
+		assert.Contains(t, lines[1], "level=warning")
+		assert.Contains(t, lines[2], "policy_id=p1")
+		assert.Equal(t, `level=info msg="sync finished (suppressed 4 identical messages)" suppressed=4`, lines[3])
+	}
+
+	buf.Reset()
+	SetDedupWindow(0)
+	logger.WithTime(base).Info("sync finished")
+	logger.WithTime(base).Info("sync finished")
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"), "off")
+}
+
+func TestDedupIgnoresRunIDs(t *testing.T) {
+	SetDedupWindow(time.Minute)
+	defer SetDedupWindow(0)
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(dedupFilter{&logrus.TextFormatter{DisableTimestamp: true}})
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"5f2b8c1d9e0a4b7c", "0c7e3a9b1d2f4e6a", "9a8b7c6d5e4f3a2b"} {
+		logger.WithTime(base.Add(time.Duration(i)*time.Second)).
+			WithFields(logrus.Fields{FieldSyncID: id, FieldRequestID: "req-" + id, "policy_id": "p1"}).
+			Info("sync finished")
+	}
+	logger.WithTime(base).WithFields(logrus.Fields{FieldSyncID: "5f2b8c1d9e0a4b7c", "policy_id": "p2"}).Info("sync finished")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2, "runs differing only in their IDs are repeats") {
+		assert.Contains(t, lines[0], "sync_id=5f2b8c1d9e0a4b7c")
+		assert.Contains(t, lines[1], "policy_id=p2")
+	}
+}
+
+func TestDedupFlushesSuppressedCount(t *testing.T) {
+	SetDedupWindow(time.Minute)
+	defer SetDedupWindow(0)
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(dedupFilter{&logrus.TextFormatter{DisableTimestamp: true}})
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		logger.WithTime(base.Add(time.Duration(i) * time.Second)).Warn("provider lte down")
+	}
+	logger.WithTime(base).Warn("policy p1 failed")
+	logger.WithTime(base.Add(10 * time.Second)).Warn("policy p1 failed")
+	logger.WithTime(base.Add(30 * time.Second)).Info("sync finished") // within the window
+	logger.WithTime(base.Add(2 * time.Minute)).Info("sync finished")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 6) {
+		assert.Equal(t, `level=warning msg="provider lte down"`, lines[0])
+		assert.Equal(t, `level=warning msg="policy p1 failed"`, lines[1])
+		assert.Equal(t, `level=info msg="sync finished"`, lines[2])
+		assert.Equal(t, `level=warning msg="provider lte down (suppressed 1 identical messages)" suppressed=1`, lines[3])
+		assert.Equal(t, `level=warning msg="policy p1 failed"`, lines[4], "the only dropped copy, written as is")
+		assert.Equal(t, `level=info msg="sync finished"`, lines[5])
+	}
+
+	buf.Reset()
+	logger.WithTime(base.Add(3 * time.Minute)).Warn("provider lte down")
+	assert.Equal(t, "level=warning msg=\"provider lte down\"\n", buf.String(), "nothing left to report once flushed")
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileRotatesAndPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "router-sync.log")
+	r, err := OpenRotatingFile(path, 1, 2, 0)
+	require.NoError(t, err)
+	defer r.Close()
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	chunk := make([]byte, 600*1024)
+	for i := 0; i < 5; i++ {
+		_, err := r.Write(chunk)
+		require.NoError(t, err)
+	}
+
+	backups, err := r.backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(chunk)), info.Size())
+}
+
+func TestRotatingFilePrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "router-sync.log")
+	stale := path + "." + time.Now().Add(-48*time.Hour).UTC().Format(backupTimeFormat)
+	require.NoError(t, os.WriteFile(stale, []byte("old\n"), 0o640))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	r, err := OpenRotatingFile(path, 1, 0, 24*time.Hour)
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = r.Write(make([]byte, 1024*1024))
+	require.NoError(t, err)
+	_, err = r.Write([]byte("x"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+	backups, err := r.backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	r, err := OpenRotatingFile(filepath.Join(t.TempDir(), "a.log"), 1, 1, 0)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	_, err = r.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -57,7 +57,7 @@ func SetLevel(level logrus.Level) {
 	mu.Lock()
 	currentLevel = level
 	mu.Unlock()
-	logrus.SetLevel(level)
+	applyLoggerLevel()
 }
 
 // ParseLevel parses a level name (case-insensitive).
@@ -25,3 +25,15 @@ func TestParseLevel(t *testing.T) {
 	_, err = ParseLevel("nope")
 	assert.Error(t, err)
 }
+
+func TestComponentOf(t *testing.T) {
+	assert.Equal(t, "agent", componentOf("router-sync/internal/agent.(*Service).watchPolicies"))
+	assert.Equal(t, "router", componentOf("router-sync/internal/router.(*Manager).SetupPolicy"))
+	assert.Equal(t, "main", componentOf("main.runAPI"))
+}
+
+func TestSetFormat(t *testing.T) {
+	assert.NoError(t, SetFormat("json"))
+	assert.NoError(t, SetFormat("TEXT"))
+	assert.Error(t, SetFormat("xml"))
+}
@@ -0,0 +1,328 @@
+// Package lookup answers "which provider will this client's traffic use" by
+// replaying the kernel's policy routing decision (ip rule + ip route lookup)
+// against the rules and tables an agent last reported. It is pure: callers
+// supply the router state and the desired configuration.
+package lookup
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"router-sync/internal/models"
+)
+
+// Step outcomes.
+const (
+	OutcomeNoMatch    = "from_mismatch"
+	OutcomeNoRoute    = "no_route"
+	OutcomeSuppressed = "suppressed"
+	OutcomeMatched    = "matched"
+	OutcomeDropped    = "dropped" // blackhole, unreachable or prohibit rule
+)
+
+// Step is one ip rule visited during the lookup.
+type Step struct {
+	Priority int    `json:"priority"`
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	Table    int    `json:"table"`
+	Outcome  string `json:"outcome"`
+}
+
+// Result is the routing decision for one router.
+type Result struct {
+	Hostname    string `json:"hostname"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Matched     bool   `json:"matched"`
+	Dropped     bool   `json:"dropped,omitempty"` // a strict policy's blackhole rule caught the packet
+
+	RulePriority int           `json:"rule_priority,omitempty"`
+	Table        int           `json:"table,omitempty"`
+	TableName    string        `json:"table_name,omitempty"`
+	Route        *models.Route `json:"route,omitempty"`
+	Gateway      string        `json:"gateway,omitempty"`
+	Interface    string        `json:"interface,omitempty"`
+	ProviderID   string        `json:"provider_id,omitempty"`
+
+	// PolicyID is the enabled policy that should steer Source (most specific
+	// match); ExpectedProviderID is its provider. InSync is false when default
+	// route traffic leaves through a different provider (or has no route).
+	PolicyID           string `json:"policy_id,omitempty"`
+	ExpectedProviderID string `json:"expected_provider_id,omitempty"`
+	InSync             bool   `json:"in_sync"`
+
+	Steps   []Step `json:"steps"`
+	Message string `json:"message"`
+}
+
+// Evaluate walks state.Rules in priority order for a packet from src to dst
+// (dst nil means "any internet destination": only default routes match).
+// For each rule whose selector matches src it looks up the longest matching
+// route in the rule's table; a miss, or a route suppressed by
+// suppress_prefixlength, falls through to the next rule, as the kernel does.
+// Mark rules match only on a router with source sets, where the mark a
+// packet gets follows from its source alone (see sourceSetMark); the lookup
+// knows no protocol or ports, so traffic that protocol/port policies
+// classify is not modeled. Delegated source policies are resolved against
+// the router's delegated prefixes.
+func Evaluate(state *models.RouterState, providers []*models.InternetProvider, policies []*models.RoutingPolicy, src, dst net.IP) Result {
+	policies = models.ResolvePolicies(policies, state.DelegatedPrefixes)
+	res := Result{
+		Hostname:    state.Hostname,
+		Source:      src.String(),
+		Destination: "default",
+		Steps:       []Step{},
+	}
+	if dst != nil {
+		res.Destination = dst.String()
+	}
+
+	if p := expectedPolicy(policies, src, dst); p != nil {
+		res.PolicyID = p.ID
+		res.ExpectedProviderID = p.ProviderID
+	}
+
+	tables := make(map[int]models.RoutingTable, len(state.Tables))
+	for _, t := range state.Tables {
+		tables[t.ID] = t
+	}
+
+	mark := 0
+	if state.SourceSets {
+		mark = sourceSetMark(policies, providers, src)
+	}
+
+	rules := append([]models.IPRule(nil), state.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority < rules[j].Priority })
+
+	matchedBits := -1
+	for _, rule := range rules {
+		step := Step{Priority: rule.Priority, From: rule.From, To: rule.To, Table: rule.Table}
+		if (rule.FwMark != 0 && rule.FwMark != mark) || !fromMatches(rule.From, src) || !toMatches(rule.To, dst) {
+			step.Outcome = OutcomeNoMatch
+			// Non-matching selectors are noise in the trace except for managed rules.
+			if models.IsManagedPriority(rule.Priority) {
+				res.Steps = append(res.Steps, step)
+			}
+			continue
+		}
+
+		if rule.Action != "" {
+			step.Outcome = OutcomeDropped
+			res.Steps = append(res.Steps, step)
+			res.Dropped = true
+			res.RulePriority = rule.Priority
+			break
+		}
+
+		route, prefixLen := longestMatch(tables[rule.Table], src, dst)
+		switch {
+		case route == nil:
+			step.Outcome = OutcomeNoRoute
+		case rule.SuppressPrefixLength != nil && prefixLen <= *rule.SuppressPrefixLength:
+			step.Outcome = OutcomeSuppressed
+		default:
+			step.Outcome = OutcomeMatched
+		}
+		res.Steps = append(res.Steps, step)
+		if step.Outcome != OutcomeMatched {
+			continue
+		}
+
+		res.Matched = true
+		matchedBits = prefixLen
+		res.RulePriority = rule.Priority
+		res.Table = rule.Table
+		res.TableName = tables[rule.Table].Name
+		if res.TableName == "" {
+			res.TableName = rule.TableName
+		}
+		res.Route = route
+		res.Gateway = route.Gateway
+		res.Interface = route.Interface
+		res.ProviderID = providerFor(providers, state.Hostname, rule.Table, route)
+		break
+	}
+
+	switch {
+	case res.Dropped:
+		res.Message = fmt.Sprintf("traffic from %s to %s is dropped by rule %d (strict policy whose provider cannot route it)",
+			res.Source, res.Destination, res.RulePriority)
+	case !res.Matched:
+		res.Message = fmt.Sprintf("no route from %s to %s", res.Source, res.Destination)
+	case res.ProviderID != "":
+		res.Message = fmt.Sprintf("%s uses provider %s via %s dev %s (rule %d, table %d)",
+			res.Source, res.ProviderID, orDash(res.Gateway), orDash(res.Interface), res.RulePriority, res.Table)
+	default:
+		res.Message = fmt.Sprintf("%s leaves via %s dev %s (rule %d, table %d), not a known provider",
+			res.Source, orDash(res.Gateway), orDash(res.Interface), res.RulePriority, res.Table)
+	}
+	switch {
+	case res.ExpectedProviderID == "":
+		res.InSync = true
+	case !res.Matched:
+		res.InSync = false
+	case matchedBits > 0:
+		// A more specific route (LAN, VPN) won; policies only steer default-route traffic.
+		res.InSync = true
+	default:
+		res.InSync = res.ExpectedProviderID == res.ProviderID
+	}
+	return res
+}
+
+// sourceSetMark returns the mark the source sets give packets from src: the
+// table of the provider of the first set policy containing src in priority
+// order, as the classification chain evaluates them, or 0.
+func sourceSetMark(policies []*models.RoutingPolicy, providers []*models.InternetProvider, src net.IP) int {
+	var (
+		best         *models.RoutingPolicy
+		bestPriority int
+	)
+	for _, p := range policies {
+		if !p.Enabled || !p.InSourceSet(true) {
+			continue
+		}
+		n, err := p.SourceNet()
+		if err != nil || !n.Contains(src) {
+			continue
+		}
+		if priority := p.RulePriority(n); best == nil || priority < bestPriority {
+			best, bestPriority = p, priority
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	for _, p := range providers {
+		if p.ID == best.ProviderID {
+			return p.TableID
+		}
+	}
+	return 0
+}
+
+// expectedPolicy returns the enabled policy containing src (and dst, for
+// policies with a destination) whose rule the kernel evaluates first: the
+// lowest rule priority, which is the most specific prefix unless a policy
+// sets an explicit priority. Protocol/port policies are ignored, as in Evaluate.
+func expectedPolicy(policies []*models.RoutingPolicy, src, dst net.IP) *models.RoutingPolicy {
+	var (
+		best         *models.RoutingPolicy
+		bestPriority int
+	)
+	for _, p := range policies {
+		if !p.Enabled || p.Classified() {
+			continue
+		}
+		n, err := p.SourceNet()
+		if err != nil || !n.Contains(src) {
+			continue
+		}
+		if d, err := p.DestinationNet(); err != nil || (d != nil && (dst == nil || !d.Contains(dst))) {
+			continue
+		}
+		if priority := p.RulePriority(n); best == nil || priority < bestPriority {
+			best, bestPriority = p, priority
+		}
+	}
+	return best
+}
+
+func fromMatches(from string, src net.IP) bool {
+	if from == "" || from == "all" {
+		return true
+	}
+	n, err := models.ParseSource(from)
+	if err != nil {
+		return false
+	}
+	return n.Contains(src)
+}
+
+// toMatches is fromMatches for a rule's destination selector. With no known
+// destination (dst nil) only rules without one match.
+func toMatches(to string, dst net.IP) bool {
+	if to == "" || to == "all" {
+		return true
+	}
+	if dst == nil {
+		return false
+	}
+	n, err := models.ParseSource(to)
+	if err != nil {
+		return false
+	}
+	return n.Contains(dst)
+}
+
+// longestMatch returns the most specific route in t covering dst, and its
+// prefix length. Routes of the other address family are ignored.
+func longestMatch(t models.RoutingTable, src, dst net.IP) (*models.Route, int) {
+	v4 := src.To4() != nil
+	var (
+		best     *models.Route
+		bestBits = -1
+	)
+	for i := range t.Routes {
+		r := &t.Routes[i]
+		var (
+			n    *net.IPNet
+			bits int
+		)
+		if r.Dst == "default" {
+			if gw := net.ParseIP(r.Gateway); gw != nil && (gw.To4() != nil) != v4 {
+				continue
+			}
+			bits = 0
+		} else {
+			_, parsed, err := net.ParseCIDR(r.Dst)
+			if err != nil || (parsed.IP.To4() != nil) != v4 {
+				continue
+			}
+			n = parsed
+			bits, _ = n.Mask.Size()
+		}
+		if dst == nil {
+			if bits != 0 {
+				continue
+			}
+		} else if n != nil && !n.Contains(dst) {
+			continue
+		}
+		if bits > bestBits {
+			best, bestBits = r, bits
+		}
+	}
+	return best, bestBits
+}
+
+// providerFor identifies the provider carrying the route: the one owning the
+// table, otherwise (main table) the one whose gateway or interface matches.
+func providerFor(providers []*models.InternetProvider, hostname string, table int, route *models.Route) string {
+	for _, p := range providers {
+		if p.TableID == table {
+			return p.ID
+		}
+	}
+	for _, p := range providers {
+		if route.Gateway != "" && (p.Gateway == route.Gateway || p.GatewayV6 == route.Gateway) {
+			return p.ID
+		}
+	}
+	for _, p := range providers {
+		if route.Interface != "" && (p.InterfaceForHost(hostname) == route.Interface || p.InterfaceV6ForHost(hostname) == route.Interface) {
+			return p.ID
+		}
+	}
+	return ""
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
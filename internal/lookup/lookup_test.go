@@ -0,0 +1,139 @@
+package lookup
+
+import (
+	"net"
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testState() *models.RouterState {
+	zero := 0
+	return &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 0, From: "all", Table: 255},
+			{Priority: 10, From: "all", Table: 254, SuppressPrefixLength: &zero},
+			{Priority: 2000, From: "192.168.2.25", Table: 99},
+			{Priority: 2008, From: "192.168.3.0/24", Table: 100},
+			{Priority: 32766, From: "all", Table: 254},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 254, Routes: []models.Route{
+				{Dst: "default", Gateway: "192.168.4.1", Interface: "enp1s0"},
+				{Dst: "192.168.2.0/24", Interface: "br0"},
+				{Dst: "10.8.0.0/16", Gateway: "192.168.2.254", Interface: "br0"},
+			}},
+			{ID: 99, Name: "Telecom", Routes: []models.Route{{Dst: "default", Gateway: "192.168.4.1", Interface: "enp1s0"}}},
+			{ID: 100, Name: "Starlink", Routes: []models.Route{{Dst: "default", Gateway: "100.64.0.1", Interface: "enp2s0"}}},
+			{ID: 255, Routes: []models.Route{{Dst: "192.168.2.1/32", Interface: "br0"}}},
+		},
+	}
+}
+
+var (
+	testProviders = []*models.InternetProvider{
+		{ID: "Telecom", TableID: 99, Gateway: "192.168.4.1", Interface: "enp1s0"},
+		{ID: "Starlink", TableID: 100, Gateway: "100.64.0.1", Interface: "enp2s0"},
+	}
+	testPolicies = []*models.RoutingPolicy{
+		{ID: "192.168.2.25", ProviderID: "Telecom", Enabled: true},
+		{ID: "192.168.3.0/24", ProviderID: "Telecom", Enabled: true},
+	}
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name         string
+		src, dst     string
+		wantRule     int
+		wantTable    int
+		wantProvider string
+		wantInSync   bool
+	}{
+		{name: "policy source to internet", src: "192.168.2.25", wantRule: 2000, wantTable: 99, wantProvider: "Telecom", wantInSync: true},
+		{name: "LAN destination wins over policy via suppress rule", src: "192.168.2.25", dst: "10.8.1.1", wantRule: 10, wantTable: 254, wantInSync: true},
+		{name: "CIDR policy on a different provider than desired", src: "192.168.3.7", dst: "1.1.1.1", wantRule: 2008, wantTable: 100, wantProvider: "Starlink", wantInSync: false},
+		{name: "unmanaged source uses main default", src: "192.168.2.50", dst: "1.1.1.1", wantRule: 32766, wantTable: 254, wantProvider: "Telecom", wantInSync: true},
+		{name: "local address", src: "192.168.2.50", dst: "192.168.2.1", wantRule: 0, wantTable: 255, wantInSync: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst net.IP
+			if tt.dst != "" {
+				dst = net.ParseIP(tt.dst)
+			}
+			got := Evaluate(testState(), testProviders, testPolicies, net.ParseIP(tt.src), dst)
+			assert.True(t, got.Matched, got.Message)
+			assert.Equal(t, tt.wantRule, got.RulePriority)
+			assert.Equal(t, tt.wantTable, got.Table)
+			assert.Equal(t, tt.wantProvider, got.ProviderID)
+			assert.Equal(t, tt.wantInSync, got.InSync)
+		})
+	}
+}
+
+func TestEvaluateNoRoute(t *testing.T) {
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules:    []models.IPRule{{Priority: 2000, From: "192.168.2.25", Table: 99}},
+	}
+	got := Evaluate(state, testProviders, testPolicies, net.ParseIP("192.168.2.25"), nil)
+	assert.False(t, got.Matched)
+	assert.False(t, got.InSync)
+	if assert.Len(t, got.Steps, 1) {
+		assert.Equal(t, OutcomeNoRoute, got.Steps[0].Outcome)
+	}
+}
+
+func TestEvaluateStrictBlackhole(t *testing.T) {
+	state := &models.RouterState{
+		Hostname: "r1",
+		Rules: []models.IPRule{
+			{Priority: 2000, From: "192.168.2.25", Table: 99},
+			{Priority: 2000, From: "192.168.2.25", Action: models.RuleActionBlackhole},
+			{Priority: 32766, From: "all", Table: 254},
+		},
+		Tables: []models.RoutingTable{
+			{ID: 254, Routes: []models.Route{{Dst: "default", Gateway: "192.168.4.1", Interface: "enp1s0"}}},
+		},
+	}
+	got := Evaluate(state, testProviders, testPolicies, net.ParseIP("192.168.2.25"), nil)
+	assert.False(t, got.Matched)
+	assert.True(t, got.Dropped)
+	assert.Equal(t, 2000, got.RulePriority)
+	if assert.Len(t, got.Steps, 2) {
+		assert.Equal(t, OutcomeNoRoute, got.Steps[0].Outcome)
+		assert.Equal(t, OutcomeDropped, got.Steps[1].Outcome)
+	}
+}
+
+func TestEvaluateSourceSets(t *testing.T) {
+	state := testState()
+	state.SourceSets = true
+	state.Rules = []models.IPRule{
+		{Priority: 10, From: "all", Table: 254, SuppressPrefixLength: new(int)},
+		{Priority: 2000, From: "all", FwMark: 99, Table: 99},
+		{Priority: 2008, From: "all", FwMark: 100, Table: 100},
+		{Priority: 32766, From: "all", Table: 254},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "192.168.2.25", ProviderID: "Telecom", Enabled: true},
+		{ID: "192.168.3.0/24", ProviderID: "Starlink", Enabled: true},
+	}
+
+	got := Evaluate(state, testProviders, policies, net.ParseIP("192.168.3.7"), nil)
+	assert.Equal(t, 2008, got.RulePriority)
+	assert.Equal(t, "Starlink", got.ProviderID)
+	assert.True(t, got.InSync)
+
+	got = Evaluate(state, testProviders, policies, net.ParseIP("192.168.2.25"), nil)
+	assert.Equal(t, 2000, got.RulePriority)
+	assert.Equal(t, "Telecom", got.ProviderID)
+
+	got = Evaluate(state, testProviders, policies, net.ParseIP("192.168.2.50"), nil)
+	assert.Equal(t, 32766, got.RulePriority, "no set holds the source, so no mark")
+}
@@ -6,6 +6,8 @@ package metrics
 import (
 	"net/http"
 
+	"router-sync/internal/config"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,3 +26,19 @@ func NewRegistry() *prometheus.Registry {
 func HandlerFor(reg *prometheus.Registry) http.Handler {
 	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
 }
+
+// Registerer returns a Registerer for reg that names metrics per cfg: each
+// one is prefixed with the namespace and labelled with node, version and
+// the configured constant labels. Register everything router-sync defines
+// through it; the Go and process collectors of NewRegistry stay unprefixed.
+func Registerer(reg prometheus.Registerer, cfg config.MetricsConfig, node, version string) prometheus.Registerer {
+	labels := prometheus.Labels{"node": node, "version": version}
+	for k, v := range cfg.ConstLabels {
+		labels[k] = v
+	}
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = config.DefaultMetricsNamespace
+	}
+	return prometheus.WrapRegistererWithPrefix(namespace+"_", prometheus.WrapRegistererWith(labels, reg))
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"router-sync/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := Registerer(reg, config.MetricsConfig{ConstLabels: map[string]string{"site": "ams1"}}, "r1", "1.2.3")
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "sync_total", Help: "Syncs."})
+	r.MustRegister(c)
+	c.Inc()
+
+	want := `
+# HELP router_sync_sync_total Syncs.
+# TYPE router_sync_sync_total counter
+router_sync_sync_total{node="r1",site="ams1",version="1.2.3"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "router_sync_sync_total"); err != nil {
+		t.Error(err)
+	}
+
+	// Two services in one process (or test) each get their own registry
+	other := Registerer(NewRegistry(), config.MetricsConfig{Namespace: "edge"}, "r1", "1.2.3")
+	other.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "sync_total", Help: "Syncs."}))
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionCleanup = "cleanup"
+	AuditActionExpire  = "expire"
+)
+
+// Audited entity types.
+const (
+	AuditEntityProvider    = "provider"
+	AuditEntityPolicy      = "policy"
+	AuditEntityGroup       = "group"
+	AuditEntityLogLevel    = "log_level"
+	AuditEntityRouter      = "router"
+	AuditEntityMaintenance = "maintenance"
+	AuditEntityWebhook     = "webhook"
+	AuditEntityTenant      = "tenant"
+)
+
+// AuditEntry records one configuration change made through the API. Before
+// is empty for creates and After is empty for deletes.
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Actor      string          `json:"actor"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything; Since and
+// Until bound the timestamp (inclusive). Limit caps the number returned.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Matches reports whether e passes the filter (ignoring Limit).
+func (f AuditFilter) Matches(e *AuditEntry) bool {
+	if f.EntityType != "" && e.EntityType != f.EntityType {
+		return false
+	}
+	if f.EntityID != "" && e.EntityID != f.EntityID {
+		return false
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	return f.InRange(e.Timestamp)
+}
+
+// InRange reports whether t falls within Since/Until.
+func (f AuditFilter) InRange(t time.Time) bool {
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && t.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ToJSON converts the AuditEntry to JSON.
+func (e *AuditEntry) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// FromJSON populates AuditEntry from JSON.
+func (e *AuditEntry) FromJSON(data []byte) error {
+	return json.Unmarshal(data, e)
+}
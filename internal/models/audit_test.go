@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditFilter_Matches(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := &AuditEntry{Timestamp: ts, Actor: "alice", EntityType: AuditEntityPolicy, EntityID: "192.168.1.0/24"}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: AuditFilter{}, want: true},
+		{name: "entity and actor", filter: AuditFilter{EntityType: AuditEntityPolicy, Actor: "alice"}, want: true},
+		{name: "other entity", filter: AuditFilter{EntityType: AuditEntityProvider}, want: false},
+		{name: "other entity id", filter: AuditFilter{EntityID: "10.0.0.1"}, want: false},
+		{name: "other actor", filter: AuditFilter{Actor: "bob"}, want: false},
+		{name: "inside range", filter: AuditFilter{Since: ts.Add(-time.Hour), Until: ts}, want: true},
+		{name: "before range", filter: AuditFilter{Since: ts.Add(time.Second)}, want: false},
+		{name: "after range", filter: AuditFilter{Until: ts.Add(-time.Second)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(entry); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import "fmt"
+
+// ProviderChain returns the providers the policy may use, in order: the
+// primary ProviderID followed by BackupProviderIDs.
+func (p *RoutingPolicy) ProviderChain() []string {
+	chain := make([]string, 0, 1+len(p.BackupProviderIDs))
+	chain = append(chain, p.ProviderID)
+	return append(chain, p.BackupProviderIDs...)
+}
+
+// UsesProvider reports whether id is the policy's primary or one of its
+// backup providers.
+func (p *RoutingPolicy) UsesProvider(id string) bool {
+	for _, chained := range p.ProviderChain() {
+		if chained == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBackups checks BackupProviderIDs on its own; whether the providers
+// exist is checked against the store by the API.
+func (p *RoutingPolicy) validateBackups() error {
+	seen := map[string]bool{p.ProviderID: true}
+	for _, id := range p.BackupProviderIDs {
+		if id == "" {
+			return fmt.Errorf("policy backup_provider_ids must not contain empty IDs")
+		}
+		if id == p.ProviderID {
+			return fmt.Errorf("policy backup provider %s is already its primary provider", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("policy backup provider %s is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
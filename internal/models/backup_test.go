@@ -0,0 +1,38 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoutingPolicy_ValidateBackups(t *testing.T) {
+	tests := []struct {
+		name    string
+		backups []string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"ordered list", []string{"isp2", "isp3"}, false},
+		{"empty ID", []string{""}, true},
+		{"primary as backup", []string{"isp1"}, true},
+		{"duplicate", []string{"isp2", "isp2"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &RoutingPolicy{ProviderID: "isp1", BackupProviderIDs: tt.backups}
+			if err := p.validateBackups(); (err != nil) != tt.wantErr {
+				t.Errorf("validateBackups() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutingPolicy_ProviderChain(t *testing.T) {
+	p := &RoutingPolicy{ProviderID: "isp1", BackupProviderIDs: []string{"isp2", "isp3"}}
+	if got, want := p.ProviderChain(), []string{"isp1", "isp2", "isp3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ProviderChain() = %v, want %v", got, want)
+	}
+	if !p.UsesProvider("isp3") || p.UsesProvider("isp4") {
+		t.Error("UsesProvider() does not follow the chain")
+	}
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// MaxInterfaceNameLen is IFNAMSIZ minus the terminating NUL.
+const MaxInterfaceNameLen = 15
+
+// ValidInterfaceName mirrors the kernel's dev_valid_name().
+func ValidInterfaceName(name string) bool {
+	if name == "" || len(name) > MaxInterfaceNameLen || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == ':' || r == 0 || r == ' ' || r == '\t' || r == '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateInterfaceNames checks every interface name the provider maps.
+func (p *InternetProvider) validateInterfaceNames() error {
+	hosts := make([]string, 0, len(p.Interfaces))
+	for host := range p.Interfaces {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		if !ValidInterfaceName(p.Interfaces[host]) {
+			return fmt.Errorf("invalid interface name %q for router %s", p.Interfaces[host], host)
+		}
+	}
+	if p.Interface != "" && !ValidInterfaceName(p.Interface) {
+		return fmt.Errorf("invalid interface name %q", p.Interface)
+	}
+	if p.InterfaceV6 != "" && !ValidInterfaceName(p.InterfaceV6) {
+		return fmt.Errorf("invalid interface_v6 name %q", p.InterfaceV6)
+	}
+	return nil
+}
+
+// TableIDOwner returns the provider other than p that already uses p's
+// routing table, or nil.
+func TableIDOwner(p *InternetProvider, providers []*InternetProvider) *InternetProvider {
+	for _, other := range providers {
+		if other.ID != p.ID && other.TableID == p.TableID {
+			return other
+		}
+	}
+	return nil
+}
+
+// GatewayRoutable reports whether gateway lies inside the subnet of one of
+// the interface addresses, i.e. the router can reach it without another
+// route. IPv6 link-local gateways are reachable on any interface.
+func GatewayRoutable(gateway string, ifaces []Interface) bool {
+	ip := net.ParseIP(gateway)
+	if ip == nil {
+		return false
+	}
+	if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, iface := range ifaces {
+		for _, addr := range iface.Addresses {
+			if _, network, err := net.ParseCIDR(addr); err == nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateCollection checks constraints no single record can: every
+// provider has its own routing table, no two enabled policies install the
+// same rule (identical source and destination CIDRs and selectors) unless
+// their schedules never overlap, and, for each router in states that reports
+// interface addresses, every gateway is on one of its subnets. It returns all
+// problems found.
+func ValidateCollection(providers []*InternetProvider, policies []*RoutingPolicy, states []*RouterState) []string {
+	var problems []string
+
+	for i, p := range providers {
+		if other := TableIDOwner(p, providers[:i]); other != nil {
+			problems = append(problems, fmt.Sprintf("provider %s: table %d is already used by provider %s", p.ID, p.TableID, other.ID))
+		}
+	}
+
+	now := time.Now()
+	enabledRules := make(map[string][]*RoutingPolicy)
+	for _, p := range policies {
+		key, ok := p.RuleKey()
+		if !ok || !p.Enabled {
+			continue
+		}
+		conflict := false
+		for _, other := range enabledRules[key] {
+			if p.CanOverlap(other, now) {
+				problems = append(problems, fmt.Sprintf("policy %s: %s is already used by enabled policy %s", p.ID, key, other.ID))
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			enabledRules[key] = append(enabledRules[key], p)
+		}
+	}
+
+	for _, st := range states {
+		if !hasAddresses(st.Interfaces) {
+			continue
+		}
+		for _, p := range providers {
+			if !p.HasInterfaceForHost(st.Hostname) {
+				continue
+			}
+			for _, gw := range p.Gateways() {
+				if !GatewayRoutable(gw, st.Interfaces) {
+					problems = append(problems, fmt.Sprintf("provider %s: gateway %s is not on any interface subnet of router %s", p.ID, gw, st.Hostname))
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// PriorityConflicts returns a warning for each pair of enabled policies whose
+// sources and destinations overlap at the same rule priority with different
+// rules: the kernel order between the two rules is undefined, so which
+// provider shared traffic takes is too. Overlaps at different priorities
+// are fine, the more specific rule wins.
+func PriorityConflicts(policies []*RoutingPolicy) []string {
+	type rule struct {
+		policy   *RoutingPolicy
+		key      string
+		src, dst *net.IPNet
+		priority int
+	}
+	var rules []rule
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+		src, err := p.SourceNet()
+		if err != nil {
+			continue
+		}
+		dst, err := p.DestinationNet()
+		if err != nil {
+			continue
+		}
+		key, _ := p.RuleKey()
+		rules = append(rules, rule{policy: p, key: key, src: src, dst: dst, priority: p.RulePriority(src)})
+	}
+
+	var warnings []string
+	for i, a := range rules {
+		for _, b := range rules[i+1:] {
+			if a.priority != b.priority || a.key == b.key || !SourcesOverlap(a.src, b.src) {
+				continue
+			}
+			if a.dst != nil && b.dst != nil && !SourcesOverlap(a.dst, b.dst) {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("policy %s: %s overlaps policy %s (%s) at priority %d; the kernel order between them is undefined",
+				a.policy.ID, a.key, b.policy.ID, b.key, a.priority))
+		}
+	}
+	return warnings
+}
+
+func hasAddresses(ifaces []Interface) bool {
+	for _, iface := range ifaces {
+		if len(iface.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidInterfaceName(t *testing.T) {
+	for _, name := range []string{"eth0", "enp1s0", "wg-home", "br0.100"} {
+		if !ValidInterfaceName(name) {
+			t.Errorf("ValidInterfaceName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "eth 0", "eth0:1", "a/b", "averyveryverylongname"} {
+		if ValidInterfaceName(name) {
+			t.Errorf("ValidInterfaceName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestInternetProvider_ValidateInterfaceNamesAndReservedTable(t *testing.T) {
+	p := &InternetProvider{ID: "isp", Name: "isp", Interfaces: map[string]string{"r1": "eth0:1"}, TableID: 100, Gateway: "10.0.0.1"}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "r1") {
+		t.Errorf("Validate() = %v, want an invalid interface name error for r1", err)
+	}
+	p = &InternetProvider{ID: "isp", Name: "isp", Interface: "eth0", TableID: 254, Gateway: "10.0.0.1"}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("Validate() = %v, want a reserved table error", err)
+	}
+}
+
+func TestGatewayRoutable(t *testing.T) {
+	ifaces := []Interface{{Name: "eth0", Addresses: []string{"192.168.1.10/24", "2001:db8::10/64"}}}
+	tests := []struct {
+		gateway string
+		want    bool
+	}{
+		{"192.168.1.1", true},
+		{"192.168.2.1", false},
+		{"2001:db8::1", true},
+		{"fe80::1", true},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := GatewayRoutable(tt.gateway, ifaces); got != tt.want {
+			t.Errorf("GatewayRoutable(%q) = %v, want %v", tt.gateway, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCollection(t *testing.T) {
+	providers := []*InternetProvider{
+		{ID: "isp1", Interfaces: map[string]string{"r1": "eth0"}, TableID: 100, Gateway: "192.168.1.1"},
+		{ID: "isp2", Interfaces: map[string]string{"r1": "eth1"}, TableID: 100, Gateway: "10.9.9.1"},
+	}
+	policies := []*RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.2.0/24", ProviderID: "isp1", Enabled: true},
+		{ID: "b", SourceIP: "192.168.2.0/24", ProviderID: "isp2", Enabled: true},
+		{ID: "c", SourceIP: "192.168.2.0/24", ProviderID: "isp2"},
+		// disjoint schedules may share a source
+		{ID: "day", SourceIP: "192.168.3.0/24", ProviderID: "isp1", Enabled: true,
+			Schedule: &Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "20:00"}}}},
+		{ID: "night", SourceIP: "192.168.3.0/24", ProviderID: "isp2", Enabled: true,
+			Schedule: &Schedule{Windows: []ScheduleWindow{{Start: "20:00", End: "08:00"}}}},
+	}
+	states := []*RouterState{
+		{Hostname: "r1", Interfaces: []Interface{{Name: "eth0", Addresses: []string{"192.168.1.10/24"}}, {Name: "eth1", Addresses: []string{"10.0.0.2/24"}}}},
+		{Hostname: "r2"}, // no addresses reported: not checked
+	}
+
+	problems := ValidateCollection(providers, policies, states)
+	if len(problems) != 3 {
+		t.Fatalf("ValidateCollection() = %v, want 3 problems", problems)
+	}
+	for i, want := range []string{"table 100", "enabled policy a", "gateway 10.9.9.1"} {
+		if !strings.Contains(problems[i], want) {
+			t.Errorf("problem %d = %q, want it to mention %q", i, problems[i], want)
+		}
+	}
+}
+
+func TestPriorityConflicts(t *testing.T) {
+	policies := []*RoutingPolicy{
+		{ID: "a", SourceIP: "192.168.2.0/24", Priority: 2010, Enabled: true},
+		{ID: "b", SourceIP: "192.168.2.128/25", Priority: 2010, Enabled: true},
+		{ID: "c", SourceIP: "192.168.2.0/25", Enabled: true},    // more specific, own priority
+		{ID: "d", SourceIP: "192.168.2.128/25", Priority: 2010}, // disabled
+		{ID: "e", SourceIP: "192.168.2.0/24", Destination: "10.0.0.0/8", Priority: 2010, Enabled: true},
+		{ID: "f", SourceIP: "192.168.2.0/24", Destination: "172.16.0.0/12", Priority: 2010, Enabled: true},
+	}
+
+	warnings := PriorityConflicts(policies)
+	// a/b, a/e, a/f, b/e and b/f overlap at 2010; e and f do not overlap
+	if len(warnings) != 5 {
+		t.Fatalf("PriorityConflicts() = %v, want 5 warnings", warnings)
+	}
+	if !strings.Contains(warnings[0], "policy a") || !strings.Contains(warnings[0], "policy b") {
+		t.Errorf("warning 0 = %q, want it to name policies a and b", warnings[0])
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Agent commands sent by the API over the NATS control channel.
+const (
+	// CommandConntrackList lists tracked flows; Args["src"] is an IP or CIDR.
+	CommandConntrackList = "conntrack.list"
+	// CommandConntrackFlush deletes tracked flows; Args["src"] is an IP or CIDR.
+	CommandConntrackFlush = "conntrack.flush"
+	// CommandGatewaySuggest proposes a gateway for Args["interface"].
+	CommandGatewaySuggest = "gateway.suggest"
+	// CommandRulesCleanup removes every managed ip rule, then re-syncs unless
+	// Args["resync"] is "false". Args["tables"] = "true" also flushes orphaned
+	// routing tables.
+	CommandRulesCleanup = "rules.cleanup"
+	// CommandStateCollect returns a fresh RouterState (interfaces, tables,
+	// rules) read from the kernel, bypassing the heartbeat interval.
+	CommandStateCollect = "state.collect"
+	// CommandSyncPlan returns the kernel changes a full sync would make now,
+	// without making them.
+	CommandSyncPlan = "sync.plan"
+	// CommandSyncRun runs a full sync and returns its SyncResult. With
+	// Args["async"] = "true" the agent answers as soon as the sync is
+	// queued; Args["request_id"] is logged with it.
+	CommandSyncRun = "sync.run"
+)
+
+// AgentCommand is a request addressed to a single agent.
+type AgentCommand struct {
+	Command     string            `json:"command"`
+	Args        map[string]string `json:"args,omitempty"`
+	RequestedBy string            `json:"requested_by,omitempty"`
+	RequestedAt time.Time         `json:"requested_at"`
+}
+
+// AgentCommandResult is the agent's reply. Data holds the command-specific payload.
+type AgentCommandResult struct {
+	Hostname    string          `json:"hostname"`
+	Command     string          `json:"command"`
+	OK          bool            `json:"ok"`
+	Error       string          `json:"error,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	CompletedAt time.Time       `json:"completed_at"`
+}
+
+// ConntrackFlow is one connection tracking entry. Reply* fields describe the
+// return direction; ReplyDst is the (post-NAT) local address the flow leaves
+// from, which identifies the uplink carrying it.
+type ConntrackFlow struct {
+	Protocol   string `json:"protocol"`
+	State      string `json:"state,omitempty"`
+	TTL        int    `json:"ttl"`
+	Src        string `json:"src"`
+	Dst        string `json:"dst"`
+	SrcPort    int    `json:"sport,omitempty"`
+	DstPort    int    `json:"dport,omitempty"`
+	ReplySrc   string `json:"reply_src"`
+	ReplyDst   string `json:"reply_dst"`
+	Mark       int    `json:"mark,omitempty"`
+	Interface  string `json:"interface,omitempty"`
+	ProviderID string `json:"provider_id,omitempty"`
+}
+
+// ConntrackFlushResult is the payload of a conntrack.flush reply.
+type ConntrackFlushResult struct {
+	Source  string `json:"source"`
+	Deleted int    `json:"deleted"`
+}
+
+// Gateway candidate sources, in the order they are trusted.
+const (
+	GatewaySourceDHCP     = "dhcp_lease"
+	GatewaySourceRoute    = "route"
+	GatewaySourceNeighbor = "neighbor"
+)
+
+// GatewayCandidate is one possible gateway found on an interface. OnLink is
+// true when the address lies inside one of the interface's subnets;
+// NeighborState is the ARP/NDP state when the address is in the neighbor table.
+type GatewayCandidate struct {
+	Gateway       string `json:"gateway"`
+	Source        string `json:"source"`
+	Detail        string `json:"detail,omitempty"`
+	OnLink        bool   `json:"on_link"`
+	NeighborState string `json:"neighbor_state,omitempty"`
+}
+
+// GatewaySuggestion is the payload of a gateway.suggest reply. Gateway is the
+// best candidate, empty when nothing was found.
+type GatewaySuggestion struct {
+	Interface  string             `json:"interface"`
+	Gateway    string             `json:"gateway,omitempty"`
+	Candidates []GatewayCandidate `json:"candidates"`
+}
+
+// CleanupResult is the payload of a rules.cleanup reply.
+type CleanupResult struct {
+	RulesRemoved  int    `json:"rules_removed"`
+	TablesFlushed []int  `json:"tables_flushed"`
+	Resynced      bool   `json:"resynced"`
+	ResyncError   string `json:"resync_error,omitempty"`
+}
+
+// SyncPlan is the payload of a sync.plan reply: the rule, route and
+// conntrack changes a full sync would make, in order, as journal entries.
+// Error is the error the sync would report, such as a provider whose
+// interface is missing; the changes it could plan are still listed.
+type SyncPlan struct {
+	Changes []JournalEntry `json:"changes"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// SyncResult is the outcome of one full sync on a router, the payload of a
+// sync.run reply. SyncID matches the sync_id of its log lines and journal
+// entries; Trigger says what started it. Maintenance is set when the router
+// was in maintenance mode, so no kernel change was made. Running is set on
+// the reply to an async request, which only holds SyncID, Trigger and
+// StartedAt. The counts are the kernel changes of this sync alone, not
+// those the agent's watchers made before or after it. RulesSkipped counts policies in effect that got no rule because
+// their provider is unknown or down with none to fail over to;
+// PolicyErrors holds the error of each policy that failed, by ID.
+type SyncResult struct {
+	SyncID        string            `json:"sync_id"`
+	Trigger       string            `json:"trigger"`
+	StartedAt     time.Time         `json:"started_at"`
+	DurationMs    int64             `json:"duration_ms"`
+	Success       bool              `json:"success"`
+	Running       bool              `json:"running,omitempty"`
+	Maintenance   bool              `json:"maintenance,omitempty"`
+	RulesAdded    int               `json:"rules_added"`
+	RulesRemoved  int               `json:"rules_removed"`
+	RuleFailures  int               `json:"rule_failures"`
+	RulesSkipped  int               `json:"rules_skipped"`
+	RoutesAdded   int               `json:"routes_added"`
+	RoutesRemoved int               `json:"routes_removed"`
+	RouteFailures int               `json:"route_failures"`
+	PolicyErrors  map[string]string `json:"policy_errors,omitempty"`
+	Errors        []string          `json:"errors,omitempty"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DocumentVersion is the current api_version written by export.
+const DocumentVersion = "router-sync/v1"
+
+// ConfigDocument is the portable export/import format: every provider, policy
+// and policy group in a single YAML or JSON document.
+type ConfigDocument struct {
+	APIVersion string              `json:"api_version" yaml:"api_version"`
+	ExportedAt time.Time           `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Providers  []*InternetProvider `json:"providers" yaml:"providers"`
+	Policies   []*RoutingPolicy    `json:"policies" yaml:"policies"`
+	Groups     []*PolicyGroup      `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// Validate checks every record plus document-wide consistency: unique IDs,
+// table IDs and failover priorities, at most one enabled policy per source
+// (and destination) (see ValidateCollection), and policies referencing providers (primary and backups) that
+// exist either in the document or in knownProviders (the providers that will
+// remain after a merge). It returns all problems found rather than stopping
+// at the first one.
+func (d *ConfigDocument) Validate(knownProviders map[string]bool) []string {
+	var problems []string
+
+	if d.APIVersion != "" && d.APIVersion != DocumentVersion {
+		problems = append(problems, fmt.Sprintf("unsupported api_version %q (expected %s)", d.APIVersion, DocumentVersion))
+	}
+
+	providers := make(map[string]bool, len(d.Providers)+len(knownProviders))
+	for id := range knownProviders {
+		providers[id] = true
+	}
+	seenProviders := make(map[string]bool, len(d.Providers))
+	failoverPriorities := make(map[int]string)
+	for i, p := range d.Providers {
+		if p == nil {
+			problems = append(problems, fmt.Sprintf("providers[%d]: empty entry", i))
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("providers[%d] (%s): %v", i, p.ID, err))
+		}
+		if seenProviders[p.ID] {
+			problems = append(problems, fmt.Sprintf("providers[%d]: duplicate provider ID %q", i, p.ID))
+		}
+		seenProviders[p.ID] = true
+		providers[p.ID] = true
+		if p.FailoverPriority > 0 {
+			if other, ok := failoverPriorities[p.FailoverPriority]; ok {
+				problems = append(problems, fmt.Sprintf("providers[%d] (%s): failover_priority %d is already used by provider %s", i, p.ID, p.FailoverPriority, other))
+			}
+			failoverPriorities[p.FailoverPriority] = p.ID
+		}
+	}
+	for i, p := range d.Providers {
+		if p != nil && p.CapDrainTargetID != "" && !providers[p.CapDrainTargetID] {
+			problems = append(problems, fmt.Sprintf("providers[%d] (%s): unknown cap drain target provider %s", i, p.ID, p.CapDrainTargetID))
+		}
+	}
+
+	seenGroups := make(map[string]bool, len(d.Groups))
+	for i, g := range d.Groups {
+		if g == nil {
+			problems = append(problems, fmt.Sprintf("groups[%d]: empty entry", i))
+			continue
+		}
+		if err := g.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("groups[%d] (%s): %v", i, g.ID, err))
+		}
+		if seenGroups[g.ID] {
+			problems = append(problems, fmt.Sprintf("groups[%d]: duplicate group ID %q", i, g.ID))
+		}
+		seenGroups[g.ID] = true
+	}
+
+	seenPolicies := make(map[string]bool, len(d.Policies))
+	var policies []*RoutingPolicy
+	for i, p := range d.Policies {
+		if p == nil {
+			problems = append(problems, fmt.Sprintf("policies[%d]: empty entry", i))
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("policies[%d] (%s): %v", i, p.ID, err))
+		}
+		if seenPolicies[p.ID] {
+			problems = append(problems, fmt.Sprintf("policies[%d]: duplicate policy ID %q", i, p.ID))
+		}
+		seenPolicies[p.ID] = true
+		policies = append(policies, p)
+		if p.ProviderID != "" && !providers[p.ProviderID] {
+			problems = append(problems, fmt.Sprintf("policies[%d] (%s): unknown provider %q", i, p.ID, p.ProviderID))
+		}
+		for _, id := range p.BackupProviderIDs {
+			if id != "" && !providers[id] {
+				problems = append(problems, fmt.Sprintf("policies[%d] (%s): unknown backup provider %q", i, p.ID, id))
+			}
+		}
+	}
+
+	var providerList []*InternetProvider
+	for _, p := range d.Providers {
+		if p != nil {
+			providerList = append(providerList, p)
+		}
+	}
+	problems = append(problems, ValidateCollection(providerList, policies, nil)...)
+
+	return problems
+}
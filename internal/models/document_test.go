@@ -0,0 +1,79 @@
+package models
+
+import "testing"
+
+func TestConfigDocument_Validate(t *testing.T) {
+	provider := &InternetProvider{ID: "isp1", Name: "isp1", Interface: "eth0", TableID: 100, Gateway: "10.0.0.1"}
+
+	tests := []struct {
+		name         string
+		doc          ConfigDocument
+		known        map[string]bool
+		wantProblems int
+	}{
+		{
+			name: "valid",
+			doc: ConfigDocument{
+				APIVersion: DocumentVersion,
+				Providers:  []*InternetProvider{provider},
+				Policies:   []*RoutingPolicy{{ID: "192.168.1.10", Name: "pc", ProviderID: "isp1"}},
+			},
+		},
+		{
+			name: "policy references provider only known to the store",
+			doc: ConfigDocument{
+				Policies: []*RoutingPolicy{{ID: "192.168.1.0/24", Name: "lan", ProviderID: "isp2"}},
+			},
+			known: map[string]bool{"isp2": true},
+		},
+		{
+			name: "unknown provider and bad policy ID",
+			doc: ConfigDocument{
+				Policies: []*RoutingPolicy{{ID: "nope", Name: "x", ProviderID: "missing"}},
+			},
+			wantProblems: 2,
+		},
+		{
+			name: "duplicates and wrong version",
+			doc: ConfigDocument{
+				APIVersion: "v0",
+				Providers:  []*InternetProvider{provider, provider},
+				Policies: []*RoutingPolicy{
+					{ID: "10.1.1.1", Name: "a", ProviderID: "isp1"},
+					{ID: "10.1.1.1", Name: "b", ProviderID: "isp1"},
+				},
+			},
+			wantProblems: 3,
+		},
+		{
+			name: "shared failover priority",
+			doc: ConfigDocument{
+				Providers: []*InternetProvider{
+					{ID: "isp1", Name: "isp1", Interface: "eth0", TableID: 100, Gateway: "10.0.0.1", FailoverPriority: 1},
+					{ID: "isp2", Name: "isp2", Interface: "eth1", TableID: 200, Gateway: "10.0.1.1", FailoverPriority: 1},
+				},
+			},
+			wantProblems: 1,
+		},
+		{
+			name: "duplicate and unnamed groups",
+			doc: ConfigDocument{
+				Groups: []*PolicyGroup{
+					{ID: "iot", Name: "iot"},
+					{ID: "iot", Name: "iot"},
+					{ID: "cams"},
+				},
+			},
+			wantProblems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.doc.Validate(tt.known)
+			if len(problems) != tt.wantProblems {
+				t.Errorf("Validate() = %v, want %d problem(s)", problems, tt.wantProblems)
+			}
+		})
+	}
+}
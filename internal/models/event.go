@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types published on the NATS event subjects and relayed by /api/v1/stream.
+const (
+	// EventPolicyApplied: an agent installed (or removed, for disabled policies) a policy's rule.
+	EventPolicyApplied = "policy.applied"
+	// EventPolicyRemoved: an agent removed a deleted policy's rule.
+	EventPolicyRemoved = "policy.removed"
+	// EventPolicyFailed: an agent failed to apply or remove a policy's rule; sent once per new error.
+	EventPolicyFailed = "policy.failed"
+	// EventProviderHealth: a provider's interface on a router went up or down.
+	EventProviderHealth = "provider.health"
+	// EventSyncCompleted: an agent finished a full sync.
+	EventSyncCompleted = "sync.completed"
+	// EventDriftDetected: a router's kernel state stopped matching the providers and policies after a sync.
+	EventDriftDetected = "drift.detected"
+	// EventMaintenance: an agent froze or resumed kernel changes.
+	EventMaintenance = "maintenance"
+	// EventPrefixChanged: the IPv6 prefix a provider delegates to a router changed.
+	EventPrefixChanged = "prefix.changed"
+	// EventPolicyFailover: an agent moved a policy to another provider, or off every provider, because its provider went down, or moved it back.
+	EventPolicyFailover = "policy.failover"
+	// EventConfigChanged: a provider, policy, group or other setting was changed through the API.
+	EventConfigChanged = "config.changed"
+	// EventPing: a test delivery sent by POST /webhooks/{id}/test; never published on NATS.
+	EventPing = "ping"
+)
+
+// Event is a real-time notification. Resource is the provider or policy ID
+// the event is about (if any); Data carries type-specific details.
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Hostname  string                 `json:"hostname,omitempty"`
+	Resource  string                 `json:"resource,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// ToJSON converts the Event to JSON.
+func (e *Event) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// FromJSON populates Event from JSON.
+func (e *Event) FromJSON(data []byte) error {
+	return json.Unmarshal(data, e)
+}
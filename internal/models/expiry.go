@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Expired reports whether the policy has an ExpiresAt at or before now.
+func (p *RoutingPolicy) Expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(now)
+}
+
+// Active reports whether agents should apply the policy at now: it is
+// enabled, has not expired and is inside its schedule, if any. Agents use it
+// rather than Enabled so an expired policy stops routing even before the API
+// has disabled or deleted it.
+func (p *RoutingPolicy) Active(now time.Time) bool {
+	return p.Enabled && !p.Expired(now) && (p.Schedule == nil || p.Schedule.ActiveAt(now))
+}
+
+// CanOverlap reports whether p and other may both be active at some time
+// from now on: always, unless both are scheduled with disjoint windows.
+// Policies for the same source and destination may only share it that way.
+func (p *RoutingPolicy) CanOverlap(other *RoutingPolicy, now time.Time) bool {
+	return SchedulesOverlap(p.Schedule, other.Schedule, now)
+}
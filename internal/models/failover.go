@@ -0,0 +1,85 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// PlanFailover decides which provider carries each active policy whose
+// primary provider is down (down[id]) on a router; providers are the ones
+// the router can use. It returns the provider ID by policy ID for those
+// policies only, "" when no provider can take the policy:
+//
+//  1. the first backup in BackupProviderIDs that is available and up;
+//  2. for best-effort policies, the providers with a failover priority that
+//     are up (FailoverOrder): they share the policies in proportion to their
+//     Weight, filled lowest failover priority first and in policy ID order,
+//     so a policy stays put while the set of healthy providers is unchanged;
+//  3. otherwise none: see router.Manager.ApplyProviderDown.
+//
+// Strict policies only move to their own backups, so their traffic never
+// leaves through a provider they did not name.
+func PlanFailover(policies []*RoutingPolicy, providers []*InternetProvider, down map[string]bool) map[string]string {
+	if len(down) == 0 {
+		return nil
+	}
+	available := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		available[p.ID] = true
+	}
+	up := func(id string) bool { return available[id] && !down[id] }
+
+	plan := make(map[string]string)
+	var pooled []*RoutingPolicy
+	now := time.Now()
+	for _, p := range policies {
+		if !p.Active(now) || !available[p.ProviderID] || !down[p.ProviderID] {
+			continue
+		}
+		plan[p.ID] = ""
+		for _, id := range p.BackupProviderIDs {
+			if up(id) {
+				plan[p.ID] = id
+				break
+			}
+		}
+		if plan[p.ID] == "" && !p.Strict() {
+			pooled = append(pooled, p)
+		}
+	}
+
+	var pool []*InternetProvider
+	total := 0
+	for _, p := range FailoverOrder(providers) {
+		if up(p.ID) {
+			pool = append(pool, p)
+			total += p.EffectiveWeight()
+		}
+	}
+	if len(pool) == 0 || len(pooled) == 0 {
+		return plan
+	}
+	sort.Slice(pooled, func(i, j int) bool { return pooled[i].ID < pooled[j].ID })
+	next := 0
+	for _, p := range pool {
+		// Round up so the shares cover every policy; the last providers
+		// take what is left
+		share := (len(pooled)*p.EffectiveWeight() + total - 1) / total
+		for i := 0; i < share && next < len(pooled); i++ {
+			plan[pooled[next].ID] = p.ID
+			next++
+		}
+	}
+	return plan
+}
+
+// FailoverProvider returns the provider that carries the policy under plan
+// (see PlanFailover): its primary provider unless plan moved it. ok is false
+// when no provider can take it.
+func (p *RoutingPolicy) FailoverProvider(plan map[string]string) (providerID string, ok bool) {
+	target, moved := plan[p.ID]
+	if !moved {
+		return p.ProviderID, true
+	}
+	return target, target != ""
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanFailover(t *testing.T) {
+	providers := []*InternetProvider{
+		{ID: "fiber", FailoverPriority: 1},
+		{ID: "starlink", FailoverPriority: 2, Weight: 3},
+		{ID: "lte", FailoverPriority: 3},
+		{ID: "dsl"},
+	}
+	policies := []*RoutingPolicy{
+		{ID: "a", ProviderID: "fiber", Enabled: true},
+		{ID: "b", ProviderID: "fiber", Enabled: true},
+		{ID: "c", ProviderID: "fiber", Enabled: true},
+		{ID: "d", ProviderID: "fiber", Enabled: true},
+		{ID: "backup", ProviderID: "fiber", Enabled: true, BackupProviderIDs: []string{"fiber2", "dsl"}},
+		{ID: "strict", ProviderID: "fiber", Enabled: true, Mode: PolicyModeStrict},
+		{ID: "strict-backup", ProviderID: "fiber", Enabled: true, Mode: PolicyModeStrict, BackupProviderIDs: []string{"lte"}},
+		{ID: "disabled", ProviderID: "fiber"},
+		{ID: "healthy", ProviderID: "dsl", Enabled: true},
+	}
+
+	if plan := PlanFailover(policies, providers, nil); plan != nil {
+		t.Errorf("PlanFailover() with nothing down = %v, want nil", plan)
+	}
+
+	// starlink (weight 3) and lte (weight 1) share the four pooled
+	// policies 3:1; the backup list and strict mode take precedence.
+	plan := PlanFailover(policies, providers, map[string]bool{"fiber": true})
+	want := map[string]string{
+		"a": "starlink", "b": "starlink", "c": "starlink", "d": "lte",
+		"backup":        "dsl",
+		"strict":        "",
+		"strict-backup": "lte",
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("PlanFailover() = %v, want %v", plan, want)
+	}
+
+	// With every failover provider down only the explicit backups remain.
+	plan = PlanFailover(policies, providers, map[string]bool{"fiber": true, "starlink": true, "lte": true})
+	if plan["a"] != "" || plan["backup"] != "dsl" || plan["strict-backup"] != "" {
+		t.Errorf("PlanFailover() with the pool down = %v", plan)
+	}
+
+	if id, ok := policies[0].FailoverProvider(plan); ok || id != "" {
+		t.Errorf("FailoverProvider() of a stranded policy = %q, %v", id, ok)
+	}
+	if id, ok := policies[8].FailoverProvider(plan); !ok || id != "dsl" {
+		t.Errorf("FailoverProvider() of an unaffected policy = %q, %v", id, ok)
+	}
+}
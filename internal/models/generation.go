@@ -0,0 +1,29 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+)
+
+// ConfigGeneration summarizes a provider/policy configuration as a short
+// digest of every ID and generation. Agents report the value for what they
+// have applied (RouterState.AppliedGeneration); the API computes it from the
+// store, so equal values mean the agent is caught up. Order does not matter.
+func ConfigGeneration(providers []*InternetProvider, policies []*RoutingPolicy) string {
+	lines := make([]string, 0, len(providers)+len(policies))
+	for _, p := range providers {
+		lines = append(lines, "provider\x00"+p.ID+"\x00"+strconv.FormatUint(p.Generation, 10))
+	}
+	for _, p := range policies {
+		lines = append(lines, "policy\x00"+p.ID+"\x00"+strconv.FormatUint(p.Generation, 10))
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, l := range lines {
+		h.Write([]byte(l + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
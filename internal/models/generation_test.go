@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestConfigGeneration(t *testing.T) {
+	providers := []*InternetProvider{{ID: "Telecom", Generation: 3}, {ID: "Starlink", Generation: 1}}
+	policies := []*RoutingPolicy{{ID: "192.168.2.25", Generation: 2}}
+
+	base := ConfigGeneration(providers, policies)
+	if len(base) != 16 {
+		t.Fatalf("ConfigGeneration() = %q, want 16 hex characters", base)
+	}
+
+	reordered := []*InternetProvider{providers[1], providers[0]}
+	if got := ConfigGeneration(reordered, policies); got != base {
+		t.Errorf("order changed the generation: %s != %s", got, base)
+	}
+
+	bumped := []*RoutingPolicy{{ID: "192.168.2.25", Generation: 3}}
+	if got := ConfigGeneration(providers, bumped); got == base {
+		t.Error("a policy update did not change the generation")
+	}
+	if got := ConfigGeneration(providers[:1], policies); got == base {
+		t.Error("removing a provider did not change the generation")
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PolicyGroup bundles related policies (e.g. "IoT VLAN") so they can be
+// enabled, disabled or moved to another provider together. Membership lives on
+// the policy (RoutingPolicy.GroupID); a policy belongs to at most one group.
+// The group ID is its name, as for providers.
+type PolicyGroup struct {
+	ID          string    `json:"id" yaml:"id"`
+	Name        string    `json:"name" yaml:"name"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Generation  uint64    `json:"generation" yaml:"generation"`
+	WriterID    string    `json:"writer_id" yaml:"writer_id"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Validate validates the PolicyGroup
+func (g *PolicyGroup) Validate() error {
+	if g.ID == "" {
+		return fmt.Errorf("group ID is required")
+	}
+	if g.Name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	return nil
+}
+
+// ToJSON converts the model to JSON
+func (g *PolicyGroup) ToJSON() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// FromJSON populates the model from JSON
+func (g *PolicyGroup) FromJSON(data []byte) error {
+	return json.Unmarshal(data, g)
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Health check types.
+const (
+	HealthCheckPing = "ping" // ICMP echo to each target IP
+	HealthCheckTCP  = "tcp"  // TCP connect to each "host:port" target
+	HealthCheckHTTP = "http" // HTTP GET of each target URL; any non-5xx status is up
+)
+
+// Health check defaults filled in by HealthCheck.ApplyDefaults.
+const (
+	DefaultHealthCheckInterval      = 10 * time.Second
+	DefaultHealthCheckTimeout       = 2 * time.Second
+	DefaultHealthCheckFailThreshold = 3
+	DefaultHealthCheckRiseThreshold = 2
+)
+
+// maxHealthCheckThreshold bounds FailThreshold and RiseThreshold.
+const maxHealthCheckThreshold = 100
+
+// HealthCheck describes how agents probe a provider's uplink. Probes leave
+// through the provider's table; a round succeeds when any target answers
+// within Timeout. The provider goes down after FailThreshold failed rounds
+// in a row and comes back after RiseThreshold successful ones.
+//
+// Interval and Timeout are Go durations ("10s", "1m"). Ping checks without
+// targets probe the provider gateway.
+type HealthCheck struct {
+	Type          string   `json:"type" yaml:"type"`
+	Targets       []string `json:"targets,omitempty" yaml:"targets,omitempty"`
+	Interval      string   `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout       string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	FailThreshold int      `json:"fail_threshold,omitempty" yaml:"fail_threshold,omitempty"`
+	RiseThreshold int      `json:"rise_threshold,omitempty" yaml:"rise_threshold,omitempty"`
+}
+
+// ApplyDefaults fills unset fields with the defaults above. Targets are left
+// alone so a ping check keeps following the provider gateway.
+func (h *HealthCheck) ApplyDefaults() {
+	if h.Type == "" {
+		h.Type = HealthCheckPing
+	}
+	if h.Interval == "" {
+		h.Interval = DefaultHealthCheckInterval.String()
+	}
+	if h.Timeout == "" {
+		h.Timeout = DefaultHealthCheckTimeout.String()
+	}
+	if h.FailThreshold == 0 {
+		h.FailThreshold = DefaultHealthCheckFailThreshold
+	}
+	if h.RiseThreshold == 0 {
+		h.RiseThreshold = DefaultHealthCheckRiseThreshold
+	}
+}
+
+// IntervalDuration returns Interval, or the default when unset or invalid.
+func (h *HealthCheck) IntervalDuration() time.Duration {
+	if d, err := time.ParseDuration(h.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHealthCheckInterval
+}
+
+// TimeoutDuration returns Timeout, or the default when unset or invalid.
+func (h *HealthCheck) TimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHealthCheckTimeout
+}
+
+// TargetsFor returns the targets to probe for provider p: Targets, or the
+// provider gateways for a ping check without any.
+func (h *HealthCheck) TargetsFor(p *InternetProvider) []string {
+	if len(h.Targets) == 0 && (h.Type == "" || h.Type == HealthCheckPing) {
+		return p.Gateways()
+	}
+	return h.Targets
+}
+
+// Validate checks the health check; unset fields are valid (see ApplyDefaults).
+func (h *HealthCheck) Validate() error {
+	switch h.Type {
+	case "", HealthCheckPing, HealthCheckTCP, HealthCheckHTTP:
+	default:
+		return fmt.Errorf("health check type must be one of %s, %s or %s: %s", HealthCheckPing, HealthCheckTCP, HealthCheckHTTP, h.Type)
+	}
+	if len(h.Targets) == 0 && h.Type != "" && h.Type != HealthCheckPing {
+		return fmt.Errorf("%s health check requires at least one target", h.Type)
+	}
+	for _, target := range h.Targets {
+		if err := validateHealthTarget(h.Type, target); err != nil {
+			return err
+		}
+	}
+
+	interval, timeout := DefaultHealthCheckInterval, DefaultHealthCheckTimeout
+	if h.Interval != "" {
+		d, err := time.ParseDuration(h.Interval)
+		if err != nil || d < time.Second {
+			return fmt.Errorf("health check interval must be a duration of at least 1s: %s", h.Interval)
+		}
+		interval = d
+	}
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("health check timeout must be a positive duration: %s", h.Timeout)
+		}
+		timeout = d
+	}
+	if timeout >= interval {
+		return fmt.Errorf("health check timeout (%s) must be shorter than its interval (%s)", timeout, interval)
+	}
+
+	if h.FailThreshold < 0 || h.FailThreshold > maxHealthCheckThreshold {
+		return fmt.Errorf("health check fail_threshold must be between 1 and %d", maxHealthCheckThreshold)
+	}
+	if h.RiseThreshold < 0 || h.RiseThreshold > maxHealthCheckThreshold {
+		return fmt.Errorf("health check rise_threshold must be between 1 and %d", maxHealthCheckThreshold)
+	}
+	return nil
+}
+
+func validateHealthTarget(kind, target string) error {
+	switch kind {
+	case "", HealthCheckPing:
+		if net.ParseIP(target) == nil {
+			return fmt.Errorf("ping health check target must be an IP address: %s", target)
+		}
+	case HealthCheckTCP:
+		host, port, err := net.SplitHostPort(target)
+		if err != nil || host == "" {
+			return fmt.Errorf("tcp health check target must be host:port: %s", target)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("tcp health check target has an invalid port: %s", target)
+		}
+	case HealthCheckHTTP:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http health check target must be an http(s) URL: %s", target)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   HealthCheck
+		wantErr bool
+	}{
+		{"empty uses defaults", HealthCheck{}, false},
+		{"ping targets", HealthCheck{Type: HealthCheckPing, Targets: []string{"1.1.1.1", "2606:4700::1111"}}, false},
+		{"tcp target", HealthCheck{Type: HealthCheckTCP, Targets: []string{"1.1.1.1:53"}}, false},
+		{"http target", HealthCheck{Type: HealthCheckHTTP, Targets: []string{"https://example.com/health"}, Interval: "30s", Timeout: "5s"}, false},
+		{"unknown type", HealthCheck{Type: "snmp"}, true},
+		{"tcp without targets", HealthCheck{Type: HealthCheckTCP}, true},
+		{"ping hostname", HealthCheck{Type: HealthCheckPing, Targets: []string{"example.com"}}, true},
+		{"tcp without port", HealthCheck{Type: HealthCheckTCP, Targets: []string{"1.1.1.1"}}, true},
+		{"http without scheme", HealthCheck{Type: HealthCheckHTTP, Targets: []string{"example.com"}}, true},
+		{"interval too short", HealthCheck{Interval: "500ms"}, true},
+		{"bad interval", HealthCheck{Interval: "often"}, true},
+		{"timeout not below interval", HealthCheck{Interval: "5s", Timeout: "5s"}, true},
+		{"negative threshold", HealthCheck{FailThreshold: -1}, true},
+		{"threshold too high", HealthCheck{RiseThreshold: 101}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthCheck_ApplyDefaults(t *testing.T) {
+	h := &HealthCheck{Interval: "30s"}
+	h.ApplyDefaults()
+	want := &HealthCheck{Type: HealthCheckPing, Interval: "30s", Timeout: "2s", FailThreshold: 3, RiseThreshold: 2}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("ApplyDefaults() = %+v, want %+v", h, want)
+	}
+	if h.IntervalDuration() != 30*time.Second || h.TimeoutDuration() != 2*time.Second {
+		t.Errorf("durations = %s, %s", h.IntervalDuration(), h.TimeoutDuration())
+	}
+	if err := h.Validate(); err != nil {
+		t.Errorf("defaults do not validate: %v", err)
+	}
+}
+
+func TestHealthCheck_TargetsFor(t *testing.T) {
+	p := &InternetProvider{Gateway: "192.168.1.1"}
+	if got := (&HealthCheck{}).TargetsFor(p); !reflect.DeepEqual(got, []string{"192.168.1.1"}) {
+		t.Errorf("TargetsFor() = %v, want the gateway", got)
+	}
+	if got := (&HealthCheck{Targets: []string{"1.1.1.1"}}).TargetsFor(p); !reflect.DeepEqual(got, []string{"1.1.1.1"}) {
+		t.Errorf("TargetsFor() = %v, want the targets", got)
+	}
+}
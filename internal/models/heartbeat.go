@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HeartbeatMissedLimit is how many heartbeat intervals may pass without a
+// heartbeat before an agent counts as dead.
+const HeartbeatMissedLimit = 3
+
+// Fleet states of an agent, see Heartbeat.State.
+const (
+	AgentAlive   = "alive"
+	AgentDead    = "dead"
+	AgentStopped = "stopped"
+)
+
+// Heartbeat is the compact liveness record each agent publishes every
+// IntervalSeconds. Unlike RouterState it carries no kernel dump, and its
+// stored copy outlives the agent, so an agent that dies keeps showing up as
+// dead instead of disappearing. Stopped marks the last heartbeat of an
+// agent that shut down cleanly.
+type Heartbeat struct {
+	Hostname            string            `json:"hostname"`
+	Version             string            `json:"version"`
+	Timestamp           time.Time         `json:"timestamp"`
+	StartedAt           time.Time         `json:"started_at"`
+	IntervalSeconds     float64           `json:"interval_seconds"`
+	Stopped             bool              `json:"stopped,omitempty"`
+	Ready               bool              `json:"ready"`
+	Maintenance         bool              `json:"maintenance,omitempty"`
+	AppliedGeneration   string            `json:"applied_generation,omitempty"`
+	LastSyncAt          time.Time         `json:"last_sync_at,omitempty"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	Rules               int               `json:"rules"`
+	ManagedRules        int               `json:"managed_rules"`
+	PolicyErrors        int               `json:"policy_errors"`
+	Providers           []ProviderHealth  `json:"providers,omitempty"`
+	Features            []string          `json:"features,omitempty"`
+	Checks              map[string]string `json:"checks,omitempty"`
+}
+
+// ProviderHealth summarizes a provider's health check on one router.
+type ProviderHealth struct {
+	ProviderID      string    `json:"provider_id"`
+	Up              bool      `json:"up"`
+	Since           time.Time `json:"since"`
+	Loss            float64   `json:"loss"`
+	Flaps           int       `json:"flaps"`
+	DowntimeSeconds float64   `json:"downtime_seconds"`
+}
+
+// State returns AgentStopped after a clean shutdown, AgentDead once
+// HeartbeatMissedLimit intervals passed without a heartbeat, and AgentAlive
+// otherwise.
+func (h *Heartbeat) State(now time.Time) string {
+	if h.Stopped {
+		return AgentStopped
+	}
+	interval := time.Duration(h.IntervalSeconds * float64(time.Second))
+	if interval > 0 && now.Sub(h.Timestamp) > HeartbeatMissedLimit*interval {
+		return AgentDead
+	}
+	return AgentAlive
+}
+
+// ToJSON converts the Heartbeat to JSON.
+func (h *Heartbeat) ToJSON() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+// FromJSON populates Heartbeat from JSON.
+func (h *Heartbeat) FromJSON(data []byte) error {
+	return json.Unmarshal(data, h)
+}
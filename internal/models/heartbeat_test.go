@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeat_State(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		hb   Heartbeat
+		want string
+	}{
+		{name: "recent", hb: Heartbeat{Timestamp: now.Add(-6 * time.Second), IntervalSeconds: 5}, want: AgentAlive},
+		{name: "missed intervals", hb: Heartbeat{Timestamp: now.Add(-20 * time.Second), IntervalSeconds: 5}, want: AgentDead},
+		{name: "clean shutdown", hb: Heartbeat{Timestamp: now.Add(-time.Hour), IntervalSeconds: 5, Stopped: true}, want: AgentStopped},
+		{name: "no interval", hb: Heartbeat{Timestamp: now.Add(-time.Hour)}, want: AgentAlive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hb.State(now); got != tt.want {
+				t.Errorf("State() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+)
+
+var policyIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// NewPolicyID returns a random (version 4) UUID for a new policy.
+func NewPolicyID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// IsPolicyID reports whether id has the NewPolicyID or NewTenantPolicyID
+// format, as opposed to a legacy source-derived ID.
+func IsPolicyID(id string) bool {
+	return policyIDPattern.MatchString(id) || PolicyTenant(id) != ""
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyRecord remembers the outcome of a POST sent with an
+// Idempotency-Key header. Status 0 means the first request is still running.
+// BodyHash is the SHA-256 of the request body; reusing a key with a different
+// method, path or body is rejected instead of replayed.
+type IdempotencyRecord struct {
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	BodyHash    string    `json:"body_hash"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	Response    []byte    `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Completed reports whether the original request has finished.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// ToJSON converts the model to JSON
+func (r *IdempotencyRecord) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromJSON populates the model from JSON
+func (r *IdempotencyRecord) FromJSON(data []byte) error {
+	return json.Unmarshal(data, r)
+}
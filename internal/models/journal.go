@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Journal actions: one per kind of kernel change an agent makes.
+const (
+	JournalRuleAdd        = "rule_add"
+	JournalRuleDelete     = "rule_delete"
+	JournalRouteAdd       = "route_add"
+	JournalRouteDelete    = "route_delete"
+	JournalTableFlush     = "table_flush"
+	JournalConntrackFlush = "conntrack_flush"
+)
+
+// Journal reasons say why the agent made a change.
+const (
+	JournalReasonPolicy          = "policy"
+	JournalReasonStale           = "stale"
+	JournalReasonDuplicate       = "duplicate"
+	JournalReasonStrict          = "strict"
+	JournalReasonClassification  = "classification"
+	JournalReasonProbe           = "probe"
+	JournalReasonSuppressDefault = "suppress_default"
+	JournalReasonCleanup         = "cleanup"
+	JournalReasonOrphanedTable   = "orphaned_table"
+	JournalReasonProviderSync    = "provider_sync"
+	JournalReasonProviderDown    = "provider_down"
+	JournalReasonProviderDeleted = "provider_deleted"
+	JournalReasonRequest         = "request"
+)
+
+// JournalEntry records one change an agent made to its router's kernel
+// routing state (or tried to: Error is set when the change failed). Unlike
+// AuditEntry it is written by the agents, not the API, and says what actually
+// changed on a router rather than what was requested. Seq is the journal
+// stream sequence and is filled in when entries are read back. SyncID names
+// the agent's full sync that made the change, if one did.
+type JournalEntry struct {
+	Seq         uint64    `json:"seq,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Hostname    string    `json:"hostname"`
+	Action      string    `json:"action"`
+	Reason      string    `json:"reason,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Priority    int       `json:"priority,omitempty"`
+	Table       int       `json:"table,omitempty"`
+	FwMark      int       `json:"fwmark,omitempty"`
+	RuleAction  string    `json:"rule_action,omitempty"`
+	Route       string    `json:"route,omitempty"`
+	Flows       int       `json:"flows,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	SyncID      string    `json:"sync_id,omitempty"`
+}
+
+// JournalFilter selects journal entries. Zero fields match everything; Since
+// and Until bound the timestamp (inclusive). Limit caps the number returned.
+type JournalFilter struct {
+	Hostname string
+	Action   string
+	Source   string
+	SyncID   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// Matches reports whether e passes the filter (ignoring Limit).
+func (f JournalFilter) Matches(e *JournalEntry) bool {
+	if f.Hostname != "" && e.Hostname != f.Hostname {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Source != "" && e.Source != f.Source {
+		return false
+	}
+	if f.SyncID != "" && e.SyncID != f.SyncID {
+		return false
+	}
+	return f.InRange(e.Timestamp)
+}
+
+// InRange reports whether t falls within Since/Until.
+func (f JournalFilter) InRange(t time.Time) bool {
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && t.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ToJSON converts the JournalEntry to JSON.
+func (e *JournalEntry) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// FromJSON populates JournalEntry from JSON.
+func (e *JournalEntry) FromJSON(data []byte) error {
+	return json.Unmarshal(data, e)
+}
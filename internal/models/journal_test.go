@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJournalFilter_Matches(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 3, 12, 0, 0, time.UTC)
+	entry := &JournalEntry{Timestamp: ts, Hostname: "router1", Action: JournalRuleAdd, Source: "192.168.1.0/24", Priority: 2008, Table: 100, SyncID: "5f2b8c1d9e0a4b7c"}
+
+	tests := []struct {
+		name   string
+		filter JournalFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: JournalFilter{}, want: true},
+		{name: "host and action", filter: JournalFilter{Hostname: "router1", Action: JournalRuleAdd}, want: true},
+		{name: "other host", filter: JournalFilter{Hostname: "router2"}, want: false},
+		{name: "other action", filter: JournalFilter{Action: JournalRuleDelete}, want: false},
+		{name: "other source", filter: JournalFilter{Source: "10.0.0.1/32"}, want: false},
+		{name: "sync", filter: JournalFilter{SyncID: "5f2b8c1d9e0a4b7c"}, want: true},
+		{name: "other sync", filter: JournalFilter{SyncID: "0000000000000000"}, want: false},
+		{name: "inside range", filter: JournalFilter{Since: ts.Add(-time.Minute), Until: ts.Add(time.Minute)}, want: true},
+		{name: "before range", filter: JournalFilter{Since: ts.Add(time.Second)}, want: false},
+		{name: "after range", filter: JournalFilter{Until: ts.Add(-time.Second)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(entry); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Label keys and values follow Kubernetes conventions so they stay usable as
+// Prometheus label values and in URL selectors: keys are 1-63 characters,
+// optionally prefixed ("example.com/team"), values up to 63 characters.
+var (
+	labelNameRe   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+	labelPrefixRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]{0,251}[a-z0-9])?$`)
+)
+
+// ValidateLabels checks label keys and values.
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if err := validateLabelKey(k); err != nil {
+			return err
+		}
+		if v != "" && !labelNameRe.MatchString(v) {
+			return fmt.Errorf("invalid value %q for label %q: use up to 63 letters, digits, '-', '_' or '.'", v, k)
+		}
+	}
+	return nil
+}
+
+func validateLabelKey(k string) error {
+	name := k
+	if prefix, rest, ok := strings.Cut(k, "/"); ok {
+		if !labelPrefixRe.MatchString(prefix) {
+			return fmt.Errorf("invalid label key %q: prefix must be a DNS subdomain", k)
+		}
+		name = rest
+	}
+	if !labelNameRe.MatchString(name) {
+		return fmt.Errorf("invalid label key %q: use up to 63 letters, digits, '-', '_' or '.'", k)
+	}
+	return nil
+}
+
+// LabelRequirement is one term of a LabelSelector: key=value, key!=value,
+// key (present) or !key (absent).
+type LabelRequirement struct {
+	Key    string
+	Value  string
+	Negate bool
+	Exists bool // match on presence only
+}
+
+// LabelSelector selects records whose labels satisfy every requirement. The
+// empty selector matches everything.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses a comma-separated selector such as
+// "team=voip,env!=lab,critical,!deprecated".
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var req LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			req.Key, req.Value, _ = strings.Cut(term, "!=")
+			req.Negate = true
+		case strings.Contains(term, "="):
+			req.Key, req.Value, _ = strings.Cut(strings.Replace(term, "==", "=", 1), "=")
+		case strings.HasPrefix(term, "!"):
+			req.Key, req.Exists, req.Negate = term[1:], true, true
+		default:
+			req.Key, req.Exists = term, true
+		}
+		req.Key, req.Value = strings.TrimSpace(req.Key), strings.TrimSpace(req.Value)
+		if err := validateLabelKey(req.Key); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", term, err)
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy the selector.
+func (sel LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range sel {
+		v, ok := labels[req.Key]
+		var match bool
+		if req.Exists {
+			match = ok
+		} else {
+			match = ok && v == req.Value
+		}
+		if match == req.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the selector in the syntax ParseLabelSelector accepts.
+func (sel LabelSelector) String() string {
+	terms := make([]string, len(sel))
+	for i, req := range sel {
+		switch {
+		case req.Exists && req.Negate:
+			terms[i] = "!" + req.Key
+		case req.Exists:
+			terms[i] = req.Key
+		case req.Negate:
+			terms[i] = req.Key + "!=" + req.Value
+		default:
+			terms[i] = req.Key + "=" + req.Value
+		}
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,60 @@
+package models
+
+import "testing"
+
+func TestValidateLabels(t *testing.T) {
+	valid := map[string]string{"team": "voip", "example.com/env": "prod", "empty": ""}
+	if err := ValidateLabels(valid); err != nil {
+		t.Errorf("ValidateLabels(%v) = %v", valid, err)
+	}
+	for _, labels := range []map[string]string{
+		{"": "x"},
+		{"bad key": "x"},
+		{"team": "has space"},
+		{"-team": "x"},
+		{"Bad_Prefix/team": "x"},
+	} {
+		if err := ValidateLabels(labels); err == nil {
+			t.Errorf("ValidateLabels(%v) = nil, want error", labels)
+		}
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"team": "voip", "env": "prod", "critical": ""}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"team=voip", true},
+		{"team==voip", true},
+		{"team=data", false},
+		{"team=voip,env=prod", true},
+		{"team=voip,env=lab", false},
+		{"env!=lab", true},
+		{"env!=prod", false},
+		{"critical", true},
+		{"deprecated", false},
+		{"!deprecated", true},
+		{"!critical", false},
+		{"missing!=x", true},
+	}
+	for _, tt := range tests {
+		sel, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q) = %v", tt.selector, err)
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("%q.Matches() = %t, want %t", tt.selector, got, tt.want)
+		}
+	}
+
+	if _, err := ParseLabelSelector("bad key=x"); err == nil {
+		t.Error("ParseLabelSelector accepted an invalid key")
+	}
+	sel, _ := ParseLabelSelector(" team = voip , !old ")
+	if got := sel.String(); got != "team=voip,!old" {
+		t.Errorf("String() = %q", got)
+	}
+}
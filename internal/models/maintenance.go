@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Maintenance is the global maintenance switch. While Enabled, agents keep
+// tracking provider/policy changes but stop modifying the kernel (no syncs,
+// no rule cleanup, no conntrack flushes, no health-driven failover). Lifting
+// it triggers a full sync on every agent.
+type Maintenance struct {
+	Enabled   bool      `json:"enabled"`
+	Reason    string    `json:"reason,omitempty"`
+	Since     time.Time `json:"since"`
+	SetBy     string    `json:"set_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ToJSON converts the model to JSON
+func (m *Maintenance) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// FromJSON populates the model from JSON
+func (m *Maintenance) FromJSON(data []byte) error {
+	return json.Unmarshal(data, m)
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Protocols accepted in RoutingPolicy.Protocol.
+const (
+	ProtocolTCP    = "tcp"
+	ProtocolUDP    = "udp"
+	ProtocolSCTP   = "sctp"
+	ProtocolICMP   = "icmp"
+	ProtocolICMPv6 = "icmpv6"
+)
+
+// PortRange is an inclusive port range; From == To for a single port.
+type PortRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// String formats r as "443" or "8000-8080".
+func (r PortRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+// ParsePorts parses a comma-separated list of ports and ranges, e.g.
+// "443", "8000-8080" or "80,443,8000-8080".
+func ParsePorts(s string) ([]PortRange, error) {
+	var out []PortRange
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty port in %q", s)
+		}
+		lo, hi, isRange := strings.Cut(item, "-")
+		from, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("port range %s is reversed", item)
+			}
+		}
+		out = append(out, PortRange{From: from, To: to})
+	}
+	return out, nil
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q (expected 1-65535)", s)
+	}
+	return n, nil
+}
+
+// Classified reports whether the policy matches on protocol or ports. Such
+// policies cannot be expressed as a plain ip rule: agents enforce them with
+// nftables marks (features.nftables) instead.
+func (p *RoutingPolicy) Classified() bool {
+	return p.Protocol != "" || p.SourcePorts != "" || p.DestinationPorts != ""
+}
+
+// InSourceSet reports whether a router with source sets enabled
+// (router.source_sets, see RouterState.SourceSets) routes the policy through
+// an nftables source set and a shared fwmark rule instead of its own ip
+// rule. That holds for every plain source policy: no destination, protocol
+// or ports, and not strict, since a strict policy's blackhole rule needs a
+// selector of its own.
+func (p *RoutingPolicy) InSourceSet(sourceSets bool) bool {
+	if !sourceSets || p.Classified() || p.Strict() {
+		return false
+	}
+	dstNet, err := p.DestinationNet()
+	return err == nil && dstNet == nil
+}
+
+// MarkRouted reports whether the policy is routed by an nftables mark rather
+// than its own ip rule, on a router with or without source sets.
+func (p *RoutingPolicy) MarkRouted(sourceSets bool) bool {
+	return p.Classified() || p.InSourceSet(sourceSets)
+}
+
+// validateMatch checks the protocol and port selectors.
+func (p *RoutingPolicy) validateMatch() error {
+	switch p.Protocol {
+	case "", ProtocolTCP, ProtocolUDP, ProtocolSCTP, ProtocolICMP, ProtocolICMPv6:
+	default:
+		return fmt.Errorf("policy protocol must be one of %s, %s, %s, %s or %s: %s",
+			ProtocolTCP, ProtocolUDP, ProtocolSCTP, ProtocolICMP, ProtocolICMPv6, p.Protocol)
+	}
+	if p.SourcePorts == "" && p.DestinationPorts == "" {
+		return nil
+	}
+	switch p.Protocol {
+	case ProtocolTCP, ProtocolUDP, ProtocolSCTP:
+	default:
+		return fmt.Errorf("policy ports require protocol %s, %s or %s", ProtocolTCP, ProtocolUDP, ProtocolSCTP)
+	}
+	if p.SourcePorts != "" {
+		if _, err := ParsePorts(p.SourcePorts); err != nil {
+			return fmt.Errorf("policy source_ports: %w", err)
+		}
+	}
+	if p.DestinationPorts != "" {
+		if _, err := ParsePorts(p.DestinationPorts); err != nil {
+			return fmt.Errorf("policy destination_ports: %w", err)
+		}
+	}
+	return nil
+}
+
+// matchKey is the protocol and port part of RuleKey ("" when unclassified).
+func (p *RoutingPolicy) matchKey() string {
+	var b strings.Builder
+	if p.Protocol != "" {
+		b.WriteString(" proto " + p.Protocol)
+	}
+	if p.SourcePorts != "" {
+		b.WriteString(" sport " + canonicalPorts(p.SourcePorts))
+	}
+	if p.DestinationPorts != "" {
+		b.WriteString(" dport " + canonicalPorts(p.DestinationPorts))
+	}
+	return b.String()
+}
+
+func canonicalPorts(s string) string {
+	ranges, err := ParsePorts(s)
+	if err != nil {
+		return s
+	}
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []PortRange
+		wantErr bool
+	}{
+		{"443", []PortRange{{443, 443}}, false},
+		{"80, 443,8000-8080", []PortRange{{80, 80}, {443, 443}, {8000, 8080}}, false},
+		{"", nil, true},
+		{"80,", nil, true},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"9000-8000", nil, true},
+		{"http", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePorts(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePorts(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePorts(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutingPolicy_ValidateMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RoutingPolicy
+		wantErr bool
+	}{
+		{"no selectors", RoutingPolicy{}, false},
+		{"protocol only", RoutingPolicy{Protocol: ProtocolICMP}, false},
+		{"tcp ports", RoutingPolicy{Protocol: ProtocolTCP, SourcePorts: "1024-65535", DestinationPorts: "443"}, false},
+		{"unknown protocol", RoutingPolicy{Protocol: "gre"}, true},
+		{"ports without protocol", RoutingPolicy{DestinationPorts: "443"}, true},
+		{"ports with icmp", RoutingPolicy{Protocol: ProtocolICMP, DestinationPorts: "443"}, true},
+		{"bad port", RoutingPolicy{Protocol: ProtocolUDP, DestinationPorts: "70000"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.validateMatch(); (err != nil) != tt.wantErr {
+				t.Errorf("validateMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutingPolicy_RuleKeyMatch(t *testing.T) {
+	p := &RoutingPolicy{ID: "a", SourceIP: "10.0.0.5", Protocol: ProtocolTCP, DestinationPorts: "443, 80"}
+	key, ok := p.RuleKey()
+	if !ok || key != "10.0.0.5/32 proto tcp dport 443,80" {
+		t.Errorf("RuleKey() = %q, %v", key, ok)
+	}
+	if !p.Classified() {
+		t.Error("Classified() = false, want true")
+	}
+}
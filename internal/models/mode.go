@@ -0,0 +1,21 @@
+package models
+
+// Policy enforcement modes (RoutingPolicy.Mode).
+const (
+	// PolicyModeBestEffort lets traffic fall through to the next rules (and
+	// eventually the main table's default route) while the policy's provider
+	// cannot route it.
+	PolicyModeBestEffort = "best-effort"
+	// PolicyModeStrict drops the traffic instead: agents install a blackhole
+	// rule right behind the policy's lookup rule, so it never leaks through
+	// another uplink.
+	PolicyModeStrict = "strict"
+)
+
+// RuleActionBlackhole is IPRule.Action for the rule a strict policy adds.
+const RuleActionBlackhole = "blackhole"
+
+// Strict reports whether the policy uses PolicyModeStrict.
+func (p *RoutingPolicy) Strict() bool {
+	return p.Mode == PolicyModeStrict
+}
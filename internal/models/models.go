@@ -13,18 +13,51 @@ import (
 // (e.g. {"r1":"enp1s0","r2":"enp2s0"}). All routers use the same TableID and Gateway.
 // Interface is deprecated and kept only for backward compatibility with existing
 // records — it is auto-migrated into Interfaces on the next write.
+//
+// Gateway is the IPv4 next hop and GatewayV6 the IPv6 one; a provider needs
+// at least one of them. InterfaceV6 overrides the interface for IPv6 on every
+// router, for uplinks whose IPv6 runs over a separate link (see
+// InterfaceV6ForHost).
+//
+// Labels are free-form key/value pairs (see ValidateLabels) for selecting
+// providers in list endpoints and metrics.
+//
+// HealthCheck, when set, configures how agents probe the uplink (see
+// HealthCheck); without it only the interface link state counts.
+//
+// Weight (1-100, unset means 1) is the provider's share of traffic when
+// load balancing. FailoverPriority orders providers for failover, lowest
+// first, and is unique among providers when set; 0 leaves the provider out
+// (see FailoverOrder).
+//
+// MonthlyCap, when non-zero, is the provider's data allowance in bytes per
+// billing period, which starts on CapResetDay (1-28, unset means the 1st).
+// With CapDrainTargetID set, the API moves the provider's policies to that
+// provider once usage reaches CapDrainPercent of the cap (see
+// CapDrainThreshold and ProviderUsage).
 type InternetProvider struct {
-	ID          string            `json:"id" yaml:"id"`
-	Name        string            `json:"name" yaml:"name"`
-	Interfaces  map[string]string `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
-	Interface   string            `json:"interface,omitempty" yaml:"interface,omitempty"` // deprecated
-	TableID     int               `json:"table_id" yaml:"table_id"`
-	Gateway     string            `json:"gateway" yaml:"gateway"`
-	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
-	Generation  uint64            `json:"generation" yaml:"generation"`
-	WriterID    string            `json:"writer_id" yaml:"writer_id"`
-	CreatedAt   time.Time         `json:"created_at" yaml:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at" yaml:"updated_at"`
+	ID               string            `json:"id" yaml:"id"`
+	Name             string            `json:"name" yaml:"name"`
+	Interfaces       map[string]string `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
+	Interface        string            `json:"interface,omitempty" yaml:"interface,omitempty"` // deprecated
+	TableID          int               `json:"table_id" yaml:"table_id"`
+	Gateway          string            `json:"gateway" yaml:"gateway"`
+	GatewayV6        string            `json:"gateway_v6,omitempty" yaml:"gateway_v6,omitempty"`
+	InterfaceV6      string            `json:"interface_v6,omitempty" yaml:"interface_v6,omitempty"`
+	Description      string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	HealthCheck      *HealthCheck      `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	Weight           int               `json:"weight,omitempty" yaml:"weight,omitempty"`
+	FailoverPriority int               `json:"failover_priority,omitempty" yaml:"failover_priority,omitempty"`
+	MonthlyCap       int64             `json:"monthly_cap,omitempty" yaml:"monthly_cap,omitempty"`
+	CapResetDay      int               `json:"cap_reset_day,omitempty" yaml:"cap_reset_day,omitempty"`
+	CapDrainPercent  int               `json:"cap_drain_percent,omitempty" yaml:"cap_drain_percent,omitempty"`
+	CapDrainTargetID string            `json:"cap_drain_target_id,omitempty" yaml:"cap_drain_target_id,omitempty"`
+	Generation       uint64            `json:"generation" yaml:"generation"`
+	WriterID         string            `json:"writer_id" yaml:"writer_id"`
+	CreatedAt        time.Time         `json:"created_at" yaml:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" yaml:"updated_at"`
+	Status           *ProviderStatus   `json:"status,omitempty" yaml:"-"` // read-only, see ProviderStatus
 }
 
 // InterfaceForHost returns the interface name to use on the given router.
@@ -38,24 +71,112 @@ func (p *InternetProvider) InterfaceForHost(hostname string) string {
 	return p.Interface
 }
 
+// InterfaceV6ForHost returns the interface carrying IPv6 on the given router:
+// InterfaceV6 when set, otherwise the same interface as IPv4.
+func (p *InternetProvider) InterfaceV6ForHost(hostname string) string {
+	if p.InterfaceV6 != "" {
+		return p.InterfaceV6
+	}
+	return p.InterfaceForHost(hostname)
+}
+
+// Gateways returns the provider's configured gateways, IPv4 first.
+func (p *InternetProvider) Gateways() []string {
+	var out []string
+	for _, gw := range []string{p.Gateway, p.GatewayV6} {
+		if gw != "" {
+			out = append(out, gw)
+		}
+	}
+	return out
+}
+
+// validateGateways checks each address family on its own. Gateway may still
+// hold an IPv6 address on records predating GatewayV6, but not next to one.
+func (p *InternetProvider) validateGateways() error {
+	if p.Gateway != "" {
+		ip := net.ParseIP(p.Gateway)
+		if ip == nil {
+			return fmt.Errorf("invalid gateway IP address: %s", p.Gateway)
+		}
+		if p.GatewayV6 != "" && ip.To4() == nil {
+			return fmt.Errorf("provider gateway must be an IPv4 address when gateway_v6 is set: %s", p.Gateway)
+		}
+	}
+	if p.GatewayV6 != "" {
+		if ip := net.ParseIP(p.GatewayV6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid gateway_v6 IPv6 address: %s", p.GatewayV6)
+		}
+	}
+	if p.InterfaceV6 != "" && p.GatewayV6 == "" {
+		return fmt.Errorf("provider interface_v6 requires gateway_v6")
+	}
+	return nil
+}
+
 // HasInterfaceForHost returns true if the provider has an interface assigned for the host.
 func (p *InternetProvider) HasInterfaceForHost(hostname string) bool {
 	return p.InterfaceForHost(hostname) != ""
 }
 
-// RoutingPolicy represents a routing policy where the policy ID is used as the source IP
+// RoutingPolicy represents a routing policy for the traffic from SourceIP (an
+// IP or CIDR). ID is an opaque UUID (see NewPolicyID); records written before
+// IDs and sources were split have no SourceIP and use their ID as the source
+// (see Source) until MigratePolicyIDs rewrites them.
+//
+// Destination, when set, narrows the policy to traffic from SourceIP to that
+// IP or CIDR; the agent installs a combined from/to rule (see RuleKey).
+//
+// Protocol, SourcePorts and DestinationPorts (e.g. "443" or "80,8000-8080")
+// narrow it further; see Classified.
+//
+// BackupProviderIDs lists, in order, the providers the failover engine moves
+// the policy to while its primary provider is down (see ProviderChain).
+//
+// Mode is PolicyModeStrict or PolicyModeBestEffort (the default, also when
+// empty); see Strict.
+//
+// Priority, when non-zero, replaces the prefix-derived ip rule priority (see
+// RulePriority) so overlapping policies can be ordered explicitly. It must
+// lie inside the managed priority range.
+//
+// Labels are free-form key/value pairs (see ValidateLabels) for selecting
+// policies in list endpoints, bulk operations and metrics.
+//
+// ExpiresAt, when set, ends the policy at that time: agents stop applying it
+// and the API disables or deletes it (api.policy_expiry_action).
+//
+// Schedule, when set, limits the policy to time windows; outside them agents
+// treat it as disabled. Enabled policies for the same source and destination
+// may coexist when their schedules never overlap, e.g. one provider by day
+// and another by night.
 type RoutingPolicy struct {
-	ID          string    `json:"id" yaml:"id"`
-	Name        string    `json:"name" yaml:"name"`
-	ProviderID  string    `json:"provider_id" yaml:"provider_id"`
-	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
-	Tags        []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
-	Enabled     bool      `json:"enabled" yaml:"enabled"`
-	Favorite    bool      `json:"favorite" yaml:"favorite"`
-	Generation  uint64    `json:"generation" yaml:"generation"`
-	WriterID    string    `json:"writer_id" yaml:"writer_id"`
-	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`
+	ID                string            `json:"id" yaml:"id"`
+	SourceIP          string            `json:"source_ip" yaml:"source_ip"`
+	DelegatedSource   *DelegatedSource  `json:"delegated_source,omitempty" yaml:"delegated_source,omitempty"` // instead of SourceIP, see DelegatedSource
+	Destination       string            `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Protocol          string            `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	SourcePorts       string            `json:"source_ports,omitempty" yaml:"source_ports,omitempty"`
+	DestinationPorts  string            `json:"destination_ports,omitempty" yaml:"destination_ports,omitempty"`
+	Name              string            `json:"name" yaml:"name"`
+	ProviderID        string            `json:"provider_id" yaml:"provider_id"`
+	BackupProviderIDs []string          `json:"backup_provider_ids,omitempty" yaml:"backup_provider_ids,omitempty"`
+	Mode              string            `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Description       string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags              []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	GroupID           string            `json:"group_id,omitempty" yaml:"group_id,omitempty"`
+	Tenant            string            `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	Enabled           bool              `json:"enabled" yaml:"enabled"`
+	Favorite          bool              `json:"favorite" yaml:"favorite"`
+	Priority          int               `json:"priority,omitempty" yaml:"priority,omitempty"`
+	ExpiresAt         *time.Time        `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Schedule          *Schedule         `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Generation        uint64            `json:"generation" yaml:"generation"`
+	WriterID          string            `json:"writer_id" yaml:"writer_id"`
+	CreatedAt         time.Time         `json:"created_at" yaml:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" yaml:"updated_at"`
+	Status            *PolicyStatus     `json:"status,omitempty" yaml:"-"` // read-only, see PolicyStatus
 }
 
 // RouterState is the per-router heartbeat snapshot stored in the router-sync-state KV bucket.
@@ -67,15 +188,57 @@ type RouterState struct {
 	Interfaces   []Interface    `json:"interfaces"`
 	Tables       []RoutingTable `json:"tables"`
 	Rules        []IPRule       `json:"rules"`
+	// Maintenance is true while the agent has kernel changes frozen.
+	Maintenance bool `json:"maintenance,omitempty"`
+	// AppliedGeneration is ConfigGeneration of the providers and policies
+	// the agent has applied; it is not advanced during maintenance.
+	AppliedGeneration string `json:"applied_generation,omitempty"`
+	// Features lists the feature flags enabled on this agent.
+	Features []string `json:"features,omitempty"`
+	// AppliedAt is when the agent last applied configuration to the kernel
+	// (a full sync or a single provider or policy change).
+	AppliedAt time.Time `json:"applied_at,omitempty"`
+	// PolicyErrors and ProviderErrors hold the last error applying each
+	// record, keyed by ID; an entry is dropped once the record applies.
+	PolicyErrors   map[string]string `json:"policy_errors,omitempty"`
+	ProviderErrors map[string]string `json:"provider_errors,omitempty"`
+	// LastSync is the outcome of the agent's last full sync, whatever
+	// triggered it; nil until the first one finishes.
+	LastSync *SyncResult `json:"last_sync,omitempty"`
+	// PolicyTraffic holds the policies' traffic counters, keyed by policy
+	// ID (features.nftables only).
+	PolicyTraffic map[string]PolicyTraffic `json:"policy_traffic,omitempty"`
+	// SourceSets is true when the agent routes plain source policies
+	// through nftables sets (router.source_sets), see
+	// RoutingPolicy.InSourceSet.
+	SourceSets bool `json:"source_sets,omitempty"`
+	// DelegatedPrefixes holds the IPv6 prefix each provider currently
+	// delegates to the router (provider ID -> CIDR), which its
+	// DelegatedSource policies are resolved against.
+	DelegatedPrefixes map[string]string `json:"delegated_prefixes,omitempty"`
+	// ProviderHealth holds the health check result of each checked
+	// provider on the router (features.failover), true while up.
+	ProviderHealth map[string]bool `json:"provider_health,omitempty"`
+	// Failover maps each policy moved off its down provider to the provider
+	// now carrying it, "" when none can (see PlanFailover).
+	Failover map[string]string `json:"failover,omitempty"`
 }
 
-// Interface is a snapshot of a single network interface on a router.
+// Interface is a snapshot of a single network interface on a router. Up is
+// the administrative state (IFF_UP); OperState is the kernel operstate (up,
+// down, dormant, ...) and Carrier reports link detection (IFF_LOWER_UP).
+// RxBytes and TxBytes are the kernel's cumulative byte counters.
 type Interface struct {
 	Name      string   `json:"name"`
+	Type      string   `json:"type,omitempty"`
 	MAC       string   `json:"mac,omitempty"`
 	MTU       int      `json:"mtu"`
 	Up        bool     `json:"up"`
+	OperState string   `json:"oper_state,omitempty"`
+	Carrier   bool     `json:"carrier"`
 	Addresses []string `json:"addresses"`
+	RxBytes   uint64   `json:"rx_bytes,omitempty"`
+	TxBytes   uint64   `json:"tx_bytes,omitempty"`
 }
 
 // RoutingTable contains the routes installed in a kernel routing table.
@@ -95,12 +258,18 @@ type Route struct {
 	Metric    int    `json:"metric,omitempty"`
 }
 
-// IPRule is a single `ip rule` entry.
+// IPRule is a single `ip rule` entry. SuppressPrefixLength is set for rules
+// like the agent's "lookup main suppress_prefixlength 0", which ignore routes
+// with a prefix length less than or equal to it.
 type IPRule struct {
 	Priority int    `json:"priority"`
 	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	FwMark   int    `json:"fwmark,omitempty"`
 	Table    int    `json:"table"`
 	TableName string `json:"table_name,omitempty"`
+	Action    string `json:"action,omitempty"` // "blackhole", "unreachable" or "prohibit" instead of a lookup
+	SuppressPrefixLength *int `json:"suppress_prefixlength,omitempty"`
 }
 
 // Validate validates the InternetProvider
@@ -114,15 +283,38 @@ func (p *InternetProvider) Validate() error {
 	if len(p.Interfaces) == 0 && p.Interface == "" {
 		return fmt.Errorf("provider requires at least one interface (interfaces map or legacy interface)")
 	}
+	if err := p.validateInterfaceNames(); err != nil {
+		return err
+	}
 	if p.TableID <= 0 {
 		return fmt.Errorf("provider table ID must be greater than 0")
 	}
-	if p.Gateway == "" {
-		return fmt.Errorf("provider gateway is required")
+	if p.TableID >= 253 && p.TableID <= 255 {
+		return fmt.Errorf("provider table ID %d is reserved (default, main and local use 253-255)", p.TableID)
 	}
-
-	if net.ParseIP(p.Gateway) == nil {
-		return fmt.Errorf("invalid gateway IP address: %s", p.Gateway)
+	if !ValidTableID(p.TableID) {
+		r := CurrentRanges()
+		return fmt.Errorf("provider table ID %d is outside the allowed range %d-%d or reserved", p.TableID, r.TableMin, r.TableMax)
+	}
+	if p.Gateway == "" && p.GatewayV6 == "" {
+		return fmt.Errorf("provider gateway or gateway_v6 is required")
+	}
+	if err := p.validateGateways(); err != nil {
+		return err
+	}
+	if err := ValidateLabels(p.Labels); err != nil {
+		return err
+	}
+	if p.HealthCheck != nil {
+		if err := p.HealthCheck.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.validateBalancing(); err != nil {
+		return err
+	}
+	if err := p.validateCap(); err != nil {
+		return err
 	}
 
 	return nil
@@ -139,11 +331,55 @@ func (p *RoutingPolicy) Validate() error {
 	if p.ProviderID == "" {
 		return fmt.Errorf("provider ID is required")
 	}
+	if p.Tenant != "" && PolicyTenant(p.ID) != p.Tenant {
+		return fmt.Errorf("policy ID %s does not belong to tenant %s", p.ID, p.Tenant)
+	}
 
-	_, _, err := net.ParseCIDR(p.ID)
-	if err != nil {
-		if net.ParseIP(p.ID) == nil {
-			return fmt.Errorf("policy ID must be a valid IP address or CIDR notation: %s", p.ID)
+	if p.DelegatedSource != nil {
+		if p.SourceIP != "" {
+			return fmt.Errorf("policy source_ip and delegated_source are mutually exclusive")
+		}
+		if err := p.DelegatedSource.Validate(); err != nil {
+			return err
+		}
+	} else if _, err := ParseSource(p.Source()); err != nil {
+		return fmt.Errorf("policy source_ip must be a valid IP address or CIDR notation: %s", p.Source())
+	}
+	if p.Destination != "" {
+		dstNet, err := ParseSource(p.Destination)
+		if err != nil {
+			return fmt.Errorf("policy destination must be a valid IP address or CIDR notation: %s", p.Destination)
+		}
+		ipv6 := p.DelegatedSource != nil
+		if srcNet, err := ParseSource(p.Source()); err == nil {
+			ipv6 = srcNet.IP.To4() == nil
+		}
+		if (dstNet.IP.To4() == nil) != ipv6 {
+			return fmt.Errorf("policy destination %s is not in the address family of its source %s", p.Destination, p.Source())
+		}
+	}
+	if err := p.validateMatch(); err != nil {
+		return err
+	}
+	if err := p.validateBackups(); err != nil {
+		return err
+	}
+	switch p.Mode {
+	case "", PolicyModeBestEffort, PolicyModeStrict:
+	default:
+		return fmt.Errorf("policy mode must be %s or %s: %s", PolicyModeStrict, PolicyModeBestEffort, p.Mode)
+	}
+
+	if p.Priority != 0 && !IsManagedPriority(p.Priority) {
+		r := CurrentRanges()
+		return fmt.Errorf("policy priority %d is outside the managed range %d-%d", p.Priority, r.PriorityMin, r.PriorityMax)
+	}
+	if err := ValidateLabels(p.Labels); err != nil {
+		return err
+	}
+	if p.Schedule != nil {
+		if err := p.Schedule.Validate(); err != nil {
+			return err
 		}
 	}
 
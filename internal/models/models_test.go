@@ -85,6 +85,66 @@ func TestInternetProvider_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "dual stack",
+			provider: &InternetProvider{
+				ID:          "test-1",
+				Name:        "Test Provider",
+				Interface:   "eth0",
+				TableID:     100,
+				Gateway:     "192.168.1.1",
+				GatewayV6:   "fe80::1",
+				InterfaceV6: "ppp0",
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPv6 only",
+			provider: &InternetProvider{
+				ID:        "test-1",
+				Name:      "Test Provider",
+				Interface: "eth0",
+				TableID:   100,
+				GatewayV6: "2001:db8::1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPv4 address in gateway_v6",
+			provider: &InternetProvider{
+				ID:        "test-1",
+				Name:      "Test Provider",
+				Interface: "eth0",
+				TableID:   100,
+				Gateway:   "192.168.1.1",
+				GatewayV6: "192.168.1.2",
+			},
+			wantErr: true,
+		},
+		{
+			name: "IPv6 gateway next to gateway_v6",
+			provider: &InternetProvider{
+				ID:        "test-1",
+				Name:      "Test Provider",
+				Interface: "eth0",
+				TableID:   100,
+				Gateway:   "2001:db8::2",
+				GatewayV6: "2001:db8::1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "interface_v6 without gateway_v6",
+			provider: &InternetProvider{
+				ID:          "test-1",
+				Name:        "Test Provider",
+				Interface:   "eth0",
+				TableID:     100,
+				Gateway:     "192.168.1.1",
+				InterfaceV6: "ppp0",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -121,6 +181,72 @@ func TestRoutingPolicy_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid policy with UUID ID and source IP",
+			policy: &RoutingPolicy{
+				ID:         "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:   "192.168.1.0/24",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid policy with destination",
+			policy: &RoutingPolicy{
+				ID:          "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:    "192.168.1.0/24",
+				Destination: "203.0.113.0/24",
+				Name:        "Test Policy",
+				ProviderID:  "provider-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid policy with default route destination and port",
+			policy: &RoutingPolicy{
+				ID:               "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:         "192.168.1.0/24",
+				Destination:      "0.0.0.0/0",
+				Protocol:         ProtocolTCP,
+				DestinationPorts: "443",
+				Name:             "Test Policy",
+				ProviderID:       "provider-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "destination in another address family",
+			policy: &RoutingPolicy{
+				ID:          "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:    "192.168.1.0/24",
+				Destination: "::/0",
+				Name:        "Test Policy",
+				ProviderID:  "provider-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid destination",
+			policy: &RoutingPolicy{
+				ID:          "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:    "192.168.1.0/24",
+				Destination: "example.com",
+				Name:        "Test Policy",
+				ProviderID:  "provider-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "UUID ID with invalid source IP",
+			policy: &RoutingPolicy{
+				ID:         "0b6f1c2e-8d4a-4c3b-9e5f-1a2b3c4d5e6f",
+				SourceIP:   "not-an-ip",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing ID",
 			policy: &RoutingPolicy{
@@ -154,6 +280,46 @@ func TestRoutingPolicy_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "priority inside managed range",
+			policy: &RoutingPolicy{
+				ID:         "192.168.1.0/24",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+				Priority:   2001,
+			},
+			wantErr: false,
+		},
+		{
+			name: "priority outside managed range",
+			policy: &RoutingPolicy{
+				ID:         "192.168.1.0/24",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+				Priority:   100,
+			},
+			wantErr: true,
+		},
+		{
+			name: "strict mode",
+			policy: &RoutingPolicy{
+				ID:         "192.168.1.0/24",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+				Mode:       PolicyModeStrict,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown mode",
+			policy: &RoutingPolicy{
+				ID:         "192.168.1.0/24",
+				Name:       "Test Policy",
+				ProviderID: "provider-1",
+				Mode:       "lenient",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -284,3 +450,93 @@ func TestRoutingPolicy_FavoriteJSON(t *testing.T) {
 		t.Errorf("expected favorite true, got %v", decoded.Favorite)
 	}
 }
+
+func TestRoutingPolicy_Active(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Hour)
+
+	p := &RoutingPolicy{Enabled: true}
+	if !p.Active(now) {
+		t.Error("enabled policy without expiry should be active")
+	}
+	p.ExpiresAt = &future
+	if !p.Active(now) || p.Expired(now) {
+		t.Error("policy expiring later should be active")
+	}
+	p.ExpiresAt = &past
+	if p.Active(now) || !p.Expired(now) {
+		t.Error("expired policy should not be active")
+	}
+	p.ExpiresAt, p.Enabled = nil, false
+	if p.Active(now) {
+		t.Error("disabled policy should not be active")
+	}
+}
+
+func TestRoutingPolicy_Source(t *testing.T) {
+	legacy := &RoutingPolicy{ID: "10.0.0.0/24"}
+	if got := legacy.Source(); got != "10.0.0.0/24" {
+		t.Errorf("legacy Source() = %q, want the ID", got)
+	}
+	p := &RoutingPolicy{ID: NewPolicyID(), SourceIP: "10.0.0.5"}
+	if got := p.Source(); got != "10.0.0.5" {
+		t.Errorf("Source() = %q, want 10.0.0.5", got)
+	}
+	n, err := p.SourceNet()
+	if err != nil || n.String() != "10.0.0.5/32" {
+		t.Errorf("SourceNet() = %v, %v", n, err)
+	}
+	p.SourceIP = "2001:db8::5"
+	if n, err := p.SourceNet(); err != nil || n.String() != "2001:db8::5/128" {
+		t.Errorf("IPv6 SourceNet() = %v, %v", n, err)
+	}
+}
+
+func TestNewPolicyID(t *testing.T) {
+	a, b := NewPolicyID(), NewPolicyID()
+	if a == b {
+		t.Fatal("NewPolicyID returned the same ID twice")
+	}
+	for _, id := range []string{a, b} {
+		if !IsPolicyID(id) {
+			t.Errorf("%q is not a version 4 UUID", id)
+		}
+	}
+	if IsPolicyID("192.168.1.0/24") {
+		t.Error("a source must not look like a policy ID")
+	}
+}
+
+func TestEffectivePolicies(t *testing.T) {
+	now := time.Now()
+	day := &RoutingPolicy{ID: "b", SourceIP: "10.0.0.0/24", Enabled: true}
+	night := &RoutingPolicy{ID: "a", SourceIP: "10.0.0.0/24"}
+	other := &RoutingPolicy{ID: "c", SourceIP: "10.0.1.5"}
+	spare := &RoutingPolicy{ID: "d", SourceIP: "10.0.1.5/32"}
+
+	got := EffectivePolicies([]*RoutingPolicy{night, day, other, spare}, now)
+	if len(got) != 2 || got[0] != day || got[1] != other {
+		t.Fatalf("EffectivePolicies() = %v, want the active day policy and the lowest-ID policy for 10.0.1.5", got)
+	}
+}
+
+func TestRoutingPolicy_RuleKey(t *testing.T) {
+	p := &RoutingPolicy{SourceIP: "10.0.0.5"}
+	if key, ok := p.RuleKey(); !ok || key != "10.0.0.5/32" {
+		t.Errorf("RuleKey() = %q, %v", key, ok)
+	}
+	p.Destination = "203.0.113.0/24"
+	key, ok := p.RuleKey()
+	if !ok || key != "10.0.0.5/32 to 203.0.113.0/24" {
+		t.Errorf("RuleKey() with destination = %q, %v", key, ok)
+	}
+	rule := IPRule{From: "10.0.0.5", To: "203.0.113.0/24"}
+	if got, ok := rule.RuleKey(); !ok || got != key {
+		t.Errorf("IPRule.RuleKey() = %q, want %q", got, key)
+	}
+	// the kernel keeps no destination for "to all"
+	p.Destination = "0.0.0.0/0"
+	if key, ok := p.RuleKey(); !ok || key != "10.0.0.5/32" {
+		t.Errorf("RuleKey() with a default route destination = %q, %v", key, ok)
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// DelegatedSource is a policy source expressed relative to the IPv6 prefix a
+// provider delegates to the router, which may change whenever the provider
+// renumbers: subnet number Subnet of length Length inside the prefix. For
+// example, subnet 5 of length 64 inside 2001:db8:1200::/56 is
+// 2001:db8:1200:5::/64. Agents learn the prefix from a local interface (see
+// config.AgentConfig.DelegatedPrefixes) and re-derive the rule when it
+// changes.
+type DelegatedSource struct {
+	// ProviderID names the provider whose prefix is used; empty means the
+	// policy's own provider.
+	ProviderID string `json:"provider_id,omitempty" yaml:"provider_id,omitempty"`
+	Subnet     uint64 `json:"subnet" yaml:"subnet"`
+	Length     int    `json:"length" yaml:"length"`
+}
+
+// Validate checks the subnet length; whether Subnet fits depends on the
+// prefix and is checked by Resolve.
+func (d *DelegatedSource) Validate() error {
+	if d.Length < 1 || d.Length > 128 {
+		return fmt.Errorf("delegated source length must be 1-128: %d", d.Length)
+	}
+	return nil
+}
+
+// String describes the source for messages and rule keys, e.g.
+// "::/64 subnet 5 of isp1".
+func (d *DelegatedSource) String() string {
+	s := fmt.Sprintf("::/%d subnet %d", d.Length, d.Subnet)
+	if d.ProviderID != "" {
+		s += " of " + d.ProviderID
+	}
+	return s
+}
+
+// Resolve returns the concrete subnet inside prefix.
+func (d *DelegatedSource) Resolve(prefix *net.IPNet) (*net.IPNet, error) {
+	if prefix.IP.To4() != nil || len(prefix.IP) != net.IPv6len {
+		return nil, fmt.Errorf("delegated prefix %s is not IPv6", prefix)
+	}
+	ones, _ := prefix.Mask.Size()
+	if d.Length < ones {
+		return nil, fmt.Errorf("subnet length /%d is shorter than delegated prefix %s", d.Length, prefix)
+	}
+	if bits := d.Length - ones; bits < 64 && d.Subnet >= 1<<uint(bits) {
+		return nil, fmt.Errorf("subnet %d does not fit /%d inside delegated prefix %s", d.Subnet, d.Length, prefix)
+	}
+
+	addr := new(big.Int).SetBytes(prefix.IP.Mask(prefix.Mask))
+	subnet := new(big.Int).Lsh(new(big.Int).SetUint64(d.Subnet), uint(128-d.Length))
+	ip := make(net.IP, net.IPv6len)
+	addr.Add(addr, subnet).FillBytes(ip)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(d.Length, 128)}, nil
+}
+
+// Resolve returns the policy as a router with the given delegated prefixes
+// (provider ID -> prefix in CIDR notation) applies it: a copy with SourceIP
+// set to the concrete subnet for a DelegatedSource policy, the policy itself
+// otherwise. It fails while the router has no prefix for the provider.
+func (p *RoutingPolicy) Resolve(prefixes map[string]string) (*RoutingPolicy, error) {
+	d := p.DelegatedSource
+	if d == nil || p.SourceIP != "" {
+		return p, nil
+	}
+	providerID := d.ProviderID
+	if providerID == "" {
+		providerID = p.ProviderID
+	}
+	prefix, ok := prefixes[providerID]
+	if !ok {
+		return nil, fmt.Errorf("no delegated prefix from provider %s", providerID)
+	}
+	_, prefixNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delegated prefix %q from provider %s", prefix, providerID)
+	}
+	srcNet, err := d.Resolve(prefixNet)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *p
+	resolved.SourceIP = srcNet.String()
+	return &resolved, nil
+}
+
+// ResolvePolicies resolves every policy against prefixes (see
+// RoutingPolicy.Resolve), leaving out the delegated source policies that do
+// not resolve: they have no rule on that router until its prefix is known.
+func ResolvePolicies(policies []*RoutingPolicy, prefixes map[string]string) []*RoutingPolicy {
+	out := make([]*RoutingPolicy, 0, len(policies))
+	for _, p := range policies {
+		if resolved, err := p.Resolve(prefixes); err == nil {
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDelegatedSource_Resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		source  DelegatedSource
+		want    string
+		wantErr bool
+	}{
+		{"subnet 5", "2001:db8:1200::/56", DelegatedSource{Subnet: 5, Length: 64}, "2001:db8:1200:5::/64", false},
+		{"first subnet", "2001:db8:1200::/56", DelegatedSource{Length: 64}, "2001:db8:1200::/64", false},
+		{"last subnet", "2001:db8:1200::/56", DelegatedSource{Subnet: 255, Length: 64}, "2001:db8:1200:ff::/64", false},
+		{"whole prefix", "2001:db8:1200::/56", DelegatedSource{Length: 56}, "2001:db8:1200::/56", false},
+		{"host", "2001:db8:1200::/64", DelegatedSource{Subnet: 0x10, Length: 128}, "2001:db8:1200::10/128", false},
+		{"subnet too large", "2001:db8:1200::/56", DelegatedSource{Subnet: 256, Length: 64}, "", true},
+		{"shorter than prefix", "2001:db8:1200::/56", DelegatedSource{Length: 48}, "", true},
+		{"ipv4 prefix", "192.0.2.0/24", DelegatedSource{Length: 28}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, prefix, _ := net.ParseCIDR(tt.prefix)
+			got, err := tt.source.Resolve(prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("Resolve() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutingPolicy_Resolve(t *testing.T) {
+	static := &RoutingPolicy{ID: "static", SourceIP: "192.168.1.10", ProviderID: "isp1"}
+	delegated := &RoutingPolicy{ID: "lan", ProviderID: "isp1", DelegatedSource: &DelegatedSource{Subnet: 5, Length: 64}}
+	other := &RoutingPolicy{ID: "via-isp2", ProviderID: "isp2", DelegatedSource: &DelegatedSource{ProviderID: "isp1", Subnet: 6, Length: 64}}
+	prefixes := map[string]string{"isp1": "2001:db8:1200::/56"}
+
+	if got, err := static.Resolve(prefixes); err != nil || got != static {
+		t.Errorf("Resolve() of a static policy = %v, %v; want the policy itself", got, err)
+	}
+	got, err := delegated.Resolve(prefixes)
+	if err != nil || got.SourceIP != "2001:db8:1200:5::/64" {
+		t.Fatalf("Resolve() = %v, %v; want source 2001:db8:1200:5::/64", got, err)
+	}
+	if delegated.SourceIP != "" {
+		t.Errorf("Resolve() modified the policy")
+	}
+	if srcNet, err := got.SourceNet(); err != nil || got.RulePriority(srcNet) != CurrentRanges().PriorityMin+16 {
+		t.Errorf("RulePriority() of a /64 = %d, want PriorityMin+16", got.RulePriority(srcNet))
+	}
+	if got, err := other.Resolve(prefixes); err != nil || got.SourceIP != "2001:db8:1200:6::/64" {
+		t.Errorf("Resolve() with another provider's prefix = %v, %v", got, err)
+	}
+	if _, err := delegated.Resolve(map[string]string{}); err == nil {
+		t.Errorf("Resolve() without a prefix should fail")
+	}
+
+	resolved := ResolvePolicies([]*RoutingPolicy{static, delegated, other}, map[string]string{"isp2": "2001:db8:9900::/56"})
+	if len(resolved) != 1 || resolved[0] != static {
+		t.Errorf("ResolvePolicies() = %v, want only the static policy", resolved)
+	}
+}
+
+func TestRoutingPolicy_ValidateDelegatedSource(t *testing.T) {
+	p := &RoutingPolicy{ID: "lan", Name: "LAN", ProviderID: "isp1", DelegatedSource: &DelegatedSource{Subnet: 5, Length: 64}}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if key, ok := p.RuleKey(); !ok || key != "::/64 subnet 5 of isp1" {
+		t.Errorf("RuleKey() = %q, %v", key, ok)
+	}
+	p.SourceIP = "2001:db8::/64"
+	if err := p.Validate(); err == nil {
+		t.Errorf("Validate() should refuse source_ip with delegated_source")
+	}
+	p.SourceIP = ""
+	p.DelegatedSource.Length = 129
+	if err := p.Validate(); err == nil {
+		t.Errorf("Validate() should refuse length 129")
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Kernel rule priorities router-sync must never claim: the local table rule
+// (0), the health probe rules (9) and suppress-default rule (10) the agent
+// installs, and the main and default table rules (32766, 32767).
+const (
+	ProbeRulePriority       = 9
+	SuppressDefaultPriority = 10
+	mainRulePriority        = 32766
+)
+
+// ProbeMarkBase is the first firewall mark of health probe packets; each
+// probed provider gets the next one, routed into its table by a rule at
+// ProbeRulePriority.
+const ProbeMarkBase = 0x52530000
+
+// policyPrioritySlots is how many priorities RulePriority needs: one per IPv4
+// prefix length, /32 through /0.
+const policyPrioritySlots = 33
+
+// reservedRouteProtocols are route protocol numbers owned by the kernel or by
+// common routing daemons (rtnetlink.h, /etc/iproute2/rt_protos, FRR).
+var reservedRouteProtocols = map[int]string{
+	0: "unspec", 1: "redirect", 2: "kernel", 3: "boot", 4: "static",
+	11: "zebra", 12: "bird", 16: "dhcp", 18: "keepalived", 42: "babel",
+	186: "bgp", 187: "isis", 188: "ospf", 189: "rip", 190: "ripng",
+	191: "nhrp", 192: "eigrp", 193: "ldp", 194: "sharp", 195: "pbr",
+	196: "frr-static", 197: "openfabric", 198: "srte",
+}
+
+// MaxTableID is the largest routing table ID: the kernel's are 32-bit,
+// capped at what an int holds so 32-bit targets build.
+const MaxTableID = min(math.MaxUint32, math.MaxInt)
+
+// Ranges are the kernel number spaces router-sync owns on every router: the
+// ip rule priorities for policy rules, the routing table IDs providers may
+// use and the protocol number marking routes the agent installs. API and
+// agents must run with the same ranges.
+type Ranges struct {
+	PriorityMin   int
+	PriorityMax   int
+	TableMin      int
+	TableMax      int
+	RouteProtocol int
+}
+
+// DefaultRanges are used until SetRanges is called.
+var DefaultRanges = Ranges{
+	PriorityMin:   2000,
+	PriorityMax:   2032,
+	TableMin:      1,
+	TableMax:      MaxTableID,
+	RouteProtocol: 241,
+}
+
+var (
+	rangesMu sync.RWMutex
+	ranges   = DefaultRanges
+)
+
+// CurrentRanges returns the ranges in effect.
+func CurrentRanges() Ranges {
+	rangesMu.RLock()
+	defer rangesMu.RUnlock()
+	return ranges
+}
+
+// SetRanges validates r and makes it the process-wide ranges. It is meant to
+// be called once at startup, before any rule is read or written.
+func SetRanges(r Ranges) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	rangesMu.Lock()
+	ranges = r
+	rangesMu.Unlock()
+	return nil
+}
+
+// Validate checks that the ranges are well-formed and do not overlap the
+// kernel's and the agent's own rules, tables and route protocols. A range
+// wide enough to pass cannot hold the probe priority without also holding
+// the suppress-default one, so only the latter is checked.
+func (r Ranges) Validate() error {
+	if r.PriorityMin <= 0 || r.PriorityMax >= mainRulePriority {
+		return fmt.Errorf("router priority range %d-%d must lie within 1-%d", r.PriorityMin, r.PriorityMax, mainRulePriority-1)
+	}
+	if r.PriorityMax-r.PriorityMin+1 < policyPrioritySlots {
+		return fmt.Errorf("router priority range %d-%d must span at least %d priorities (one per prefix length)",
+			r.PriorityMin, r.PriorityMax, policyPrioritySlots)
+	}
+	if SuppressDefaultPriority >= r.PriorityMin && SuppressDefaultPriority <= r.PriorityMax {
+		return fmt.Errorf("router priority range %d-%d overlaps the suppress-default rule at priority %d",
+			r.PriorityMin, r.PriorityMax, SuppressDefaultPriority)
+	}
+	if r.TableMin < 1 || r.TableMax > MaxTableID || r.TableMin > r.TableMax {
+		return fmt.Errorf("router table range %d-%d must lie within 1-%d", r.TableMin, r.TableMax, MaxTableID)
+	}
+	if r.TableMin >= 253 && r.TableMax <= 255 {
+		return fmt.Errorf("router table range %d-%d only covers reserved tables (default, main, local)", r.TableMin, r.TableMax)
+	}
+	if r.RouteProtocol < 1 || r.RouteProtocol > 255 {
+		return fmt.Errorf("router route protocol %d must be between 1 and 255", r.RouteProtocol)
+	}
+	if name, ok := reservedRouteProtocols[r.RouteProtocol]; ok {
+		return fmt.Errorf("router route protocol %d is already used by %q", r.RouteProtocol, name)
+	}
+	return nil
+}
+
+// ValidTableID reports whether id is an allowed provider table: inside the
+// configured table range and not one of the reserved default (253), main
+// (254) and local (255) tables.
+func ValidTableID(id int) bool {
+	r := CurrentRanges()
+	return id >= r.TableMin && id <= r.TableMax && (id < 253 || id > 255)
+}
@@ -0,0 +1,72 @@
+package models
+
+import "testing"
+
+func TestRangesValidate(t *testing.T) {
+	if err := DefaultRanges.Validate(); err != nil {
+		t.Fatalf("DefaultRanges.Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *Ranges)
+	}{
+		{"too narrow", func(r *Ranges) { r.PriorityMax = r.PriorityMin + 31 }},
+		{"overlaps suppress-default", func(r *Ranges) { r.PriorityMin, r.PriorityMax = 1, 100 }},
+		{"overlaps main rule", func(r *Ranges) { r.PriorityMin, r.PriorityMax = 32740, 32766 }},
+		{"inverted tables", func(r *Ranges) { r.TableMin, r.TableMax = 200, 100 }},
+		{"only reserved tables", func(r *Ranges) { r.TableMin, r.TableMax = 253, 255 }},
+		{"protocol out of range", func(r *Ranges) { r.RouteProtocol = 256 }},
+		{"protocol taken", func(r *Ranges) { r.RouteProtocol = 4 }},
+	}
+	for _, tt := range tests {
+		r := DefaultRanges
+		tt.modify(&r)
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestSetRanges(t *testing.T) {
+	defer func() { _ = SetRanges(DefaultRanges) }()
+
+	r := DefaultRanges
+	r.PriorityMin, r.PriorityMax = 5000, 5100
+	r.TableMin, r.TableMax = 100, 199
+	if err := SetRanges(r); err != nil {
+		t.Fatalf("SetRanges() = %v", err)
+	}
+
+	src, _ := ParseSource("192.168.2.0/24")
+	if got := RulePriority(src); got != 5008 {
+		t.Errorf("RulePriority(/24) = %d, want 5008", got)
+	}
+	if IsManagedPriority(2000) || !IsManagedPriority(5100) {
+		t.Error("IsManagedPriority does not follow the configured range")
+	}
+	if ValidTableID(99) || !ValidTableID(100) || ValidTableID(200) {
+		t.Error("ValidTableID does not follow the configured range")
+	}
+
+	bad := r
+	bad.PriorityMax = bad.PriorityMin
+	if err := SetRanges(bad); err == nil {
+		t.Fatal("SetRanges accepted an invalid range")
+	}
+	if CurrentRanges() != r {
+		t.Error("a rejected SetRanges changed the ranges")
+	}
+}
+
+func TestPolicyRulePriority(t *testing.T) {
+	src, _ := ParseSource("10.0.0.0/8")
+	p := &RoutingPolicy{ID: "10.0.0.0/8"}
+	if got := p.RulePriority(src); got != 2024 {
+		t.Errorf("RulePriority() = %d, want derived 2024", got)
+	}
+	p.Priority = 2001
+	if got := p.RulePriority(src); got != 2001 {
+		t.Errorf("RulePriority() = %d, want override 2001", got)
+	}
+}
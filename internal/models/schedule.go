@@ -0,0 +1,235 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Schedules name IANA zones; embed the database so API and agents
+	// resolve them alike, even on routers without /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
+// Schedule limits a policy to time windows: it applies while the current
+// time, in Timezone (an IANA name, UTC when empty), falls inside any of
+// Windows. Outside them agents treat the policy as disabled.
+type Schedule struct {
+	Timezone string           `json:"timezone,omitempty" yaml:"timezone,omitempty" example:"Europe/Madrid"`
+	Windows  []ScheduleWindow `json:"windows" yaml:"windows"`
+}
+
+// ScheduleWindow is a daily window from Start to End ("HH:MM", End may be
+// "24:00"), on Days ("mon".."sun", every day when empty). An End before
+// Start crosses midnight: the window ends on the next day, and Days name
+// the day it starts.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty" yaml:"days,omitempty" example:"mon,tue,wed,thu,fri"`
+	Start string   `json:"start" yaml:"start" example:"08:00"`
+	End   string   `json:"end" yaml:"end" example:"18:00"`
+}
+
+// scheduleHorizon bounds the search for the next schedule change: every
+// window repeats weekly, so a change comes within a week or never.
+const scheduleHorizon = 8
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Validate checks the timezone and every window.
+func (s *Schedule) Validate() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule needs at least one window")
+	}
+	for i, w := range s.Windows {
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("schedule window %d: unknown day %q (expected mon, tue, wed, thu, fri, sat or sun)", i, d)
+			}
+		}
+		start, err := parseClock(w.Start, false)
+		if err != nil {
+			return fmt.Errorf("schedule window %d: start: %w", i, err)
+		}
+		end, err := parseClock(w.End, true)
+		if err != nil {
+			return fmt.Errorf("schedule window %d: end: %w", i, err)
+		}
+		if start == end {
+			return fmt.Errorf("schedule window %d: start and end are both %s", i, w.Start)
+		}
+	}
+	return nil
+}
+
+// ActiveAt reports whether t falls inside one of the windows. An invalid
+// schedule is never active.
+func (s *Schedule) ActiveAt(t time.Time) bool {
+	loc, err := s.location()
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	for _, w := range s.Windows {
+		start, err := parseClock(w.Start, false)
+		if err != nil {
+			continue
+		}
+		end, err := parseClock(w.End, true)
+		if err != nil {
+			continue
+		}
+		if start < end {
+			if w.on(t.Weekday()) && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		if (w.on(t.Weekday()) && minute >= start) || (w.on(yesterday) && minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns the first time after t at which ActiveAt changes; ok is
+// false when it never does (no window, or windows covering every minute).
+func (s *Schedule) NextChange(t time.Time) (next time.Time, ok bool) {
+	active := s.ActiveAt(t)
+	for _, edge := range s.edges(t) {
+		if s.ActiveAt(edge) != active {
+			return edge, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NextActivation returns the start of the next window after t that is not
+// already open at t; ok is false when the schedule never turns on again.
+func (s *Schedule) NextActivation(t time.Time) (next time.Time, ok bool) {
+	next, ok = s.NextChange(t)
+	if ok && s.ActiveAt(t) {
+		return s.NextChange(next)
+	}
+	return next, ok
+}
+
+// SchedulesOverlap reports whether a and b are ever active at the same time
+// within a week of from; a nil schedule is always active.
+func SchedulesOverlap(a, b *Schedule, from time.Time) bool {
+	if a == nil || b == nil {
+		return true
+	}
+	points := append([]time.Time{from}, a.edges(from)...)
+	points = append(points, b.edges(from)...)
+	for _, t := range points {
+		if a.ActiveAt(t) && b.ActiveAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// edges returns the window starts and ends after t, within the horizon and
+// in order.
+func (s *Schedule) edges(t time.Time) []time.Time {
+	loc, err := s.location()
+	if err != nil {
+		return nil
+	}
+	local := t.In(loc)
+	var edges []time.Time
+	for d := -1; d <= scheduleHorizon; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+		for _, w := range s.Windows {
+			if !w.on(day.Weekday()) {
+				continue
+			}
+			start, err := parseClock(w.Start, false)
+			if err != nil {
+				continue
+			}
+			end, err := parseClock(w.End, true)
+			if err != nil {
+				continue
+			}
+			endDay := day
+			if end < start {
+				endDay = day.AddDate(0, 0, 1)
+			}
+			for _, edge := range []time.Time{atClock(day, start), atClock(endDay, end)} {
+				if edge.After(t) {
+					edges = append(edges, edge)
+				}
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Before(edges[j]) })
+	return edges
+}
+
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule timezone %q: %w", s.Timezone, err)
+	}
+	return loc, nil
+}
+
+// on reports whether the window starts on day.
+func (w ScheduleWindow) on(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && wd == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock returns the minute of the day of "HH:MM"; "24:00" only when
+// end is set.
+func parseClock(s string, end bool) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok || len(h) != 2 || len(m) != 2 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	if hour == 24 && minute == 0 && end {
+		return 24 * 60, nil
+	}
+	if hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// atClock returns minute of the day on day, which is midnight in its zone.
+func atClock(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, day.Location())
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 2024-01-01 is a Monday.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestScheduleActiveAt(t *testing.T) {
+	office := &Schedule{Windows: []ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00"}}}
+	night := &Schedule{Windows: []ScheduleWindow{{Days: []string{"fri"}, Start: "22:00", End: "06:00"}}}
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		t        time.Time
+		want     bool
+	}{
+		{"office monday morning", office, at(1, 9, 0), true},
+		{"office at start", office, at(1, 8, 0), true},
+		{"office at end", office, at(1, 18, 0), false},
+		{"office saturday", office, at(6, 9, 0), false},
+		{"night friday evening", night, at(5, 23, 0), true},
+		{"night saturday early", night, at(6, 5, 59), true},
+		{"night saturday morning", night, at(6, 6, 0), false},
+		{"night thursday evening", night, at(4, 23, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.ActiveAt(tt.t); got != tt.want {
+			t.Errorf("%s: ActiveAt(%s) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	s := &Schedule{Timezone: "Europe/Madrid", Windows: []ScheduleWindow{{Start: "08:00", End: "24:00"}}}
+	// Madrid is UTC+1 in January.
+	if !s.ActiveAt(at(1, 7, 0)) {
+		t.Errorf("ActiveAt(07:00 UTC) = false, want true (08:00 in Madrid)")
+	}
+	if s.ActiveAt(at(1, 6, 59)) {
+		t.Errorf("ActiveAt(06:59 UTC) = true, want false")
+	}
+	if next, ok := s.NextChange(at(1, 12, 0)); !ok || !next.Equal(at(1, 23, 0)) {
+		t.Errorf("NextChange() = %s, %v, want %s", next, ok, at(1, 23, 0))
+	}
+}
+
+func TestScheduleNextActivation(t *testing.T) {
+	office := &Schedule{Windows: []ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00"}}}
+
+	if next, ok := office.NextChange(at(1, 9, 0)); !ok || !next.Equal(at(1, 18, 0)) {
+		t.Errorf("NextChange() inside the window = %s, %v, want %s", next, ok, at(1, 18, 0))
+	}
+	// Open now: the next activation is tomorrow's window.
+	if next, ok := office.NextActivation(at(1, 9, 0)); !ok || !next.Equal(at(2, 8, 0)) {
+		t.Errorf("NextActivation() inside the window = %s, %v, want %s", next, ok, at(2, 8, 0))
+	}
+	// Friday evening: the next window opens on Monday.
+	if next, ok := office.NextActivation(at(5, 19, 0)); !ok || !next.Equal(at(8, 8, 0)) {
+		t.Errorf("NextActivation() on friday evening = %s, %v, want %s", next, ok, at(8, 8, 0))
+	}
+
+	always := &Schedule{Windows: []ScheduleWindow{{Start: "00:00", End: "24:00"}}}
+	if next, ok := always.NextChange(at(1, 9, 0)); ok {
+		t.Errorf("NextChange() of an always-on schedule = %s, want none", next)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		want     string
+	}{
+		{Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "18:00"}}}, ""},
+		{Schedule{Windows: []ScheduleWindow{{Start: "22:00", End: "06:00", Days: []string{"Fri"}}}}, ""},
+		{Schedule{}, "at least one window"},
+		{Schedule{Timezone: "Mars/Olympus", Windows: []ScheduleWindow{{Start: "08:00", End: "18:00"}}}, "timezone"},
+		{Schedule{Windows: []ScheduleWindow{{Start: "8:00", End: "18:00"}}}, "not HH:MM"},
+		{Schedule{Windows: []ScheduleWindow{{Start: "24:00", End: "18:00"}}}, "not HH:MM"},
+		{Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "08:00"}}}, "both"},
+		{Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "18:00", Days: []string{"monday"}}}}, "unknown day"},
+	}
+	for i, tt := range tests {
+		err := tt.schedule.Validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("case %d: Validate() = %v, want nil", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("case %d: Validate() = %v, want an error mentioning %q", i, err, tt.want)
+		}
+	}
+}
+
+func TestSchedulesOverlap(t *testing.T) {
+	day := &Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "18:00"}}}
+	night := &Schedule{Windows: []ScheduleWindow{{Start: "18:00", End: "08:00"}}}
+	evening := &Schedule{Windows: []ScheduleWindow{{Start: "17:00", End: "20:00"}}}
+
+	if SchedulesOverlap(day, night, at(1, 0, 0)) {
+		t.Errorf("SchedulesOverlap(day, night) = true, want false")
+	}
+	if !SchedulesOverlap(day, evening, at(1, 0, 0)) {
+		t.Errorf("SchedulesOverlap(day, evening) = false, want true")
+	}
+	if !SchedulesOverlap(nil, night, at(1, 0, 0)) {
+		t.Errorf("SchedulesOverlap(nil, night) = false, want true")
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// IsManagedPriority reports whether an ip rule priority belongs to router-sync
+// (the configured Ranges.PriorityMin..PriorityMax).
+func IsManagedPriority(priority int) bool {
+	r := CurrentRanges()
+	return priority >= r.PriorityMin && priority <= r.PriorityMax
+}
+
+// RulePriority returns the ip rule priority for a source network: more
+// specific prefixes get lower numbers so they are evaluated first. Policy
+// rules are placed at PriorityMin + (32 - prefix length), so with the default
+// range /32 hosts land on 2000 and a /0 catch-all on 2032. IPv6 prefix
+// lengths are scaled to the same 33 priorities, one per 4 bits rounded up:
+// /128 lands on 2000, /64 on 2016 and ::/0 on 2032.
+func RulePriority(srcNet *net.IPNet) int {
+	ones, bits := srcNet.Mask.Size()
+	if bits == 128 {
+		return CurrentRanges().PriorityMin + (128-ones+3)/4
+	}
+	return CurrentRanges().PriorityMin + (32 - ones)
+}
+
+// RulePriority returns the ip rule priority for the policy: its explicit
+// Priority when set, otherwise the prefix-derived RulePriority of srcNet.
+func (p *RoutingPolicy) RulePriority(srcNet *net.IPNet) int {
+	if p.Priority != 0 {
+		return p.Priority
+	}
+	return RulePriority(srcNet)
+}
+
+// ParseSource parses an IP or CIDR string; a bare IP becomes a /32 (or, for
+// IPv6, a /128) network.
+func ParseSource(s string) (*net.IPNet, error) {
+	if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		return ipnet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source IP/CIDR: %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Source returns the policy's source IP or CIDR. Legacy records without
+// SourceIP were keyed by their source, so the ID is used instead. An
+// unresolved DelegatedSource policy has no concrete source: Source describes
+// it, and SourceNet fails until it is resolved (see RoutingPolicy.Resolve).
+func (p *RoutingPolicy) Source() string {
+	if p.SourceIP != "" {
+		return p.SourceIP
+	}
+	if p.DelegatedSource != nil {
+		return p.DelegatedSource.String()
+	}
+	return p.ID
+}
+
+// SourceNet returns the source network the policy matches.
+func (p *RoutingPolicy) SourceNet() (*net.IPNet, error) {
+	return ParseSource(p.Source())
+}
+
+// DestinationNet returns the destination network the policy is limited to,
+// or nil when it applies to every destination. A default route destination
+// (0.0.0.0/0 or ::/0) is nil too: the kernel stores "to all" without one, so
+// only the protocol and port selectors, if any, narrow such a policy.
+func (p *RoutingPolicy) DestinationNet() (*net.IPNet, error) {
+	if p.Destination == "" {
+		return nil, nil
+	}
+	dstNet, err := ParseSource(p.Destination)
+	if err != nil {
+		return nil, err
+	}
+	if ones, _ := dstNet.Mask.Size(); ones == 0 {
+		return nil, nil
+	}
+	return dstNet, nil
+}
+
+// RuleKey identifies the traffic a policy owns: its canonical source, plus
+// " to <destination>" when it has one and its protocol and port selectors
+// (" proto tcp dport 443") when classified. At most one enabled policy may
+// use a key. ok is false when the source or destination does not parse.
+//
+// An unresolved DelegatedSource policy is keyed by its description, so two
+// policies for the same delegated subnet still share a key.
+func (p *RoutingPolicy) RuleKey() (key string, ok bool) {
+	dstNet, err := p.DestinationNet()
+	if err != nil {
+		return "", false
+	}
+	if p.SourceIP == "" && p.DelegatedSource != nil {
+		key = p.DelegatedSource.String()
+		if p.DelegatedSource.ProviderID == "" {
+			key += " of " + p.ProviderID
+		}
+		if dstNet != nil {
+			key += " to " + dstNet.String()
+		}
+		return key + p.matchKey(), true
+	}
+	srcNet, err := p.SourceNet()
+	if err != nil {
+		return "", false
+	}
+	return RuleKeyFor(srcNet, dstNet) + p.matchKey(), true
+}
+
+// RuleKeyFor is RuleKey for a source and optional destination network.
+func RuleKeyFor(srcNet, dstNet *net.IPNet) string {
+	if dstNet == nil {
+		return srcNet.String()
+	}
+	return srcNet.String() + " to " + dstNet.String()
+}
+
+// RuleKey is RoutingPolicy.RuleKey for an installed rule; ok is false when
+// its selectors do not parse (e.g. "from all").
+func (r IPRule) RuleKey() (key string, ok bool) {
+	srcNet, err := ParseSource(r.From)
+	if err != nil {
+		return "", false
+	}
+	var dstNet *net.IPNet
+	if r.To != "" && r.To != "all" {
+		if dstNet, err = ParseSource(r.To); err != nil {
+			return "", false
+		}
+	}
+	return RuleKeyFor(srcNet, dstNet), true
+}
+
+// SourcesOverlap reports whether two source networks share any address.
+func SourcesOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// EffectivePolicies keeps one policy per RuleKey, the one whose state the
+// kernel rule for that key must reflect: the active policy when there is one
+// (the API allows a single enabled policy per key; ties go to the lowest ID),
+// otherwise the lowest ID. Agents install one rule per key, so applying an
+// inactive variant after the active one would remove its rule. Policies with
+// an invalid source or destination are passed through unchanged.
+func EffectivePolicies(policies []*RoutingPolicy, now time.Time) []*RoutingPolicy {
+	sorted := make([]*RoutingPolicy, len(policies))
+	copy(sorted, policies)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	chosen := make(map[string]*RoutingPolicy)
+	var out []*RoutingPolicy
+	for _, p := range sorted {
+		key, ok := p.RuleKey()
+		if !ok {
+			out = append(out, p)
+			continue
+		}
+		if cur, ok := chosen[key]; !ok || (!cur.Active(now) && p.Active(now)) {
+			chosen[key] = p
+		}
+	}
+	for _, p := range policies {
+		if key, ok := p.RuleKey(); ok && chosen[key] == p {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// PolicyStatus is the observed state of a policy, built by the API from
+// router heartbeats on every read. It is never stored: writes drop it, and
+// it is kept apart from the spec fields a client may edit.
+//
+// Applied is true when every reporting router matches the policy's spec
+// (its rule installed while enabled, pointing at the failover provider while
+// its own is down, absent while disabled). Routers holds
+// each router's diff status. CurrentProviderID is the provider whose table
+// the installed rule points at, which differs from ProviderID after a
+// failover. LastError is the most recent error an agent reported for the
+// policy, and LastAppliedAt the latest time a router applied it. Traffic
+// sums the policy's counters over the routers (agents with
+// features.nftables only). InSchedule and NextActivation are set for
+// scheduled policies: whether one of the windows is open, and when the next
+// window opens.
+type PolicyStatus struct {
+	Applied           bool              `json:"applied"`
+	Routers           map[string]string `json:"routers,omitempty"`
+	CurrentProviderID string            `json:"current_provider_id,omitempty"`
+	LastError         string            `json:"last_error,omitempty"`
+	LastAppliedAt     *time.Time        `json:"last_applied_at,omitempty"`
+	Traffic           *PolicyTraffic    `json:"traffic,omitempty"`
+	InSchedule        *bool             `json:"in_schedule,omitempty"`
+	NextActivation    *time.Time        `json:"next_activation,omitempty"`
+}
+
+// PolicyTraffic counts the traffic a policy routed: Tx from its clients,
+// Rx back to them. The counters restart when an agent reloads its
+// accounting table (a policy change) or restarts.
+type PolicyTraffic struct {
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+}
+
+// Add adds other's counters to t.
+func (t *PolicyTraffic) Add(other PolicyTraffic) {
+	t.TxBytes += other.TxBytes
+	t.TxPackets += other.TxPackets
+	t.RxBytes += other.RxBytes
+	t.RxPackets += other.RxPackets
+}
+
+// ProviderStatus is the observed state of a provider, like PolicyStatus.
+// Applied is true when every router the provider has an interface on
+// reports a default route via its gateway in the provider's table. Health
+// is the health check result ("up" or "down") on each router that checks the
+// provider (features.failover).
+type ProviderStatus struct {
+	Applied       bool              `json:"applied"`
+	Routers       map[string]string `json:"routers,omitempty"`
+	Health        map[string]string `json:"health,omitempty"`
+	LastError     string            `json:"last_error,omitempty"`
+	LastAppliedAt *time.Time        `json:"last_applied_at,omitempty"`
+}
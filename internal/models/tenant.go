@@ -0,0 +1,189 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tenantIDPattern keeps tenant IDs usable as a single KV key token and NATS
+// subject token, since they prefix the tenant's policy keys.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Tenant is a customer sharing the routing infrastructure. Its policies are
+// stored under policies.<tenant>.<uuid>, so NATS permissions can scope a
+// tenant's direct KV access to that prefix, and API callers whose token
+// carries the tenant claim only see and manage those policies.
+//
+// A tenant policy must route sources inside Sources, use a rule priority in
+// PriorityMin-PriorityMax and providers whose routing table lies in
+// TableMin-TableMax (and, when set, listed in ProviderIDs). Zero bounds mean
+// the router's whole managed range.
+type Tenant struct {
+	ID          string    `json:"id" yaml:"id"`
+	Name        string    `json:"name" yaml:"name"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Sources     []string  `json:"sources" yaml:"sources"`
+	PriorityMin int       `json:"priority_min,omitempty" yaml:"priority_min,omitempty"`
+	PriorityMax int       `json:"priority_max,omitempty" yaml:"priority_max,omitempty"`
+	TableMin    int       `json:"table_min,omitempty" yaml:"table_min,omitempty"`
+	TableMax    int       `json:"table_max,omitempty" yaml:"table_max,omitempty"`
+	ProviderIDs []string  `json:"provider_ids,omitempty" yaml:"provider_ids,omitempty"`
+	Generation  uint64    `json:"generation" yaml:"generation"`
+	WriterID    string    `json:"writer_id" yaml:"writer_id"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Validate validates the Tenant
+func (t *Tenant) Validate() error {
+	if !tenantIDPattern.MatchString(t.ID) {
+		return fmt.Errorf("tenant ID must be 1-32 lowercase letters, digits or dashes: %q", t.ID)
+	}
+	if t.Name == "" {
+		return fmt.Errorf("tenant name is required")
+	}
+	if len(t.Sources) == 0 {
+		return fmt.Errorf("tenant sources are required")
+	}
+	for _, s := range t.Sources {
+		if _, err := ParseSource(s); err != nil {
+			return fmt.Errorf("tenant source must be a valid IP address or CIDR notation: %s", s)
+		}
+	}
+
+	r := CurrentRanges()
+	if err := validateTenantRange("priority", t.PriorityMin, t.PriorityMax, r.PriorityMin, r.PriorityMax); err != nil {
+		return err
+	}
+	if err := validateTenantRange("table", t.TableMin, t.TableMax, r.TableMin, r.TableMax); err != nil {
+		return err
+	}
+	for _, id := range t.ProviderIDs {
+		if id == "" {
+			return fmt.Errorf("tenant provider_ids must not contain empty IDs")
+		}
+	}
+	return nil
+}
+
+// validateTenantRange checks that min-max is unset or a non-empty range
+// inside the router's lo-hi.
+func validateTenantRange(what string, min, max, lo, hi int) error {
+	if min == 0 && max == 0 {
+		return nil
+	}
+	if min == 0 || max == 0 || min > max {
+		return fmt.Errorf("tenant %s range %d-%d must set both bounds, lowest first", what, min, max)
+	}
+	if min < lo || max > hi {
+		return fmt.Errorf("tenant %s range %d-%d is outside the managed range %d-%d", what, min, max, lo, hi)
+	}
+	return nil
+}
+
+// PriorityRange returns the rule priorities the tenant's policies may use.
+func (t *Tenant) PriorityRange() (int, int) {
+	if t.PriorityMin == 0 {
+		r := CurrentRanges()
+		return r.PriorityMin, r.PriorityMax
+	}
+	return t.PriorityMin, t.PriorityMax
+}
+
+// TableRange returns the routing tables the tenant's providers may use.
+func (t *Tenant) TableRange() (int, int) {
+	if t.TableMin == 0 {
+		r := CurrentRanges()
+		return r.TableMin, r.TableMax
+	}
+	return t.TableMin, t.TableMax
+}
+
+// OwnsSource reports whether srcNet lies entirely inside one of the tenant's
+// sources.
+func (t *Tenant) OwnsSource(srcNet *net.IPNet) bool {
+	ones, bits := srcNet.Mask.Size()
+	for _, s := range t.Sources {
+		owned, err := ParseSource(s)
+		if err != nil {
+			continue
+		}
+		ownedOnes, ownedBits := owned.Mask.Size()
+		if ownedBits == bits && ownedOnes <= ones && owned.Contains(srcNet.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Admits checks that p stays inside the tenant's sources and priority range.
+// Tenant policies cannot join groups, which are shared by every tenant.
+// Whether its providers are allowed is checked with AllowsProvider.
+func (t *Tenant) Admits(p *RoutingPolicy) error {
+	srcNet, err := p.SourceNet()
+	if err != nil {
+		return err
+	}
+	if !t.OwnsSource(srcNet) {
+		return fmt.Errorf("source %s is outside tenant %s's sources %s", srcNet, t.ID, strings.Join(t.Sources, ", "))
+	}
+	lo, hi := t.PriorityRange()
+	if prio := p.RulePriority(srcNet); prio < lo || prio > hi {
+		return fmt.Errorf("rule priority %d is outside tenant %s's range %d-%d; set priority explicitly", prio, t.ID, lo, hi)
+	}
+	if p.GroupID != "" {
+		return fmt.Errorf("tenant policies cannot join groups")
+	}
+	return nil
+}
+
+// AllowsProvider checks that the tenant's policies may route through p.
+func (t *Tenant) AllowsProvider(p *InternetProvider) error {
+	if len(t.ProviderIDs) > 0 {
+		listed := false
+		for _, id := range t.ProviderIDs {
+			if id == p.ID {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			return fmt.Errorf("provider %s is not available to tenant %s", p.ID, t.ID)
+		}
+	}
+	lo, hi := t.TableRange()
+	if p.TableID < lo || p.TableID > hi {
+		return fmt.Errorf("provider %s uses table %d, outside tenant %s's range %d-%d", p.ID, p.TableID, t.ID, lo, hi)
+	}
+	return nil
+}
+
+// ToJSON converts the model to JSON
+func (t *Tenant) ToJSON() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// FromJSON populates the model from JSON
+func (t *Tenant) FromJSON(data []byte) error {
+	return json.Unmarshal(data, t)
+}
+
+// NewTenantPolicyID returns a policy ID for a new policy of tenant: the
+// tenant ID, a dot and a NewPolicyID.
+func NewTenantPolicyID(tenant string) string {
+	return tenant + "." + NewPolicyID()
+}
+
+// PolicyTenant returns the tenant a NewTenantPolicyID belongs to, or "" for
+// any other ID.
+func PolicyTenant(id string) string {
+	tenant, rest, ok := strings.Cut(id, ".")
+	if !ok || !tenantIDPattern.MatchString(tenant) || !policyIDPattern.MatchString(rest) {
+		return ""
+	}
+	return tenant
+}
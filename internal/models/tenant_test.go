@@ -0,0 +1,87 @@
+package models
+
+import "testing"
+
+func validTenant() Tenant {
+	return Tenant{ID: "acme", Name: "ACME", Sources: []string{"203.0.113.0/26"}, PriorityMin: 2010, PriorityMax: 2019}
+}
+
+func TestTenantValidate(t *testing.T) {
+	tenant := validTenant()
+	if err := tenant.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(t *Tenant)
+	}{
+		{"dotted ID", func(t *Tenant) { t.ID = "acme.eu" }},
+		{"upper-case ID", func(t *Tenant) { t.ID = "ACME" }},
+		{"no sources", func(t *Tenant) { t.Sources = nil }},
+		{"bad source", func(t *Tenant) { t.Sources = []string{"203.0.113.0/33"} }},
+		{"half priority range", func(t *Tenant) { t.PriorityMax = 0 }},
+		{"inverted priority range", func(t *Tenant) { t.PriorityMin, t.PriorityMax = 2019, 2010 }},
+		{"priority outside managed range", func(t *Tenant) { t.PriorityMax = 3000 }},
+		{"empty provider ID", func(t *Tenant) { t.ProviderIDs = []string{""} }},
+	}
+	for _, tt := range tests {
+		tenant := validTenant()
+		tt.modify(&tenant)
+		if err := tenant.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestTenantAdmits(t *testing.T) {
+	tenant := validTenant()
+	tests := []struct {
+		name   string
+		policy RoutingPolicy
+		ok     bool
+	}{
+		{"host in sources", RoutingPolicy{SourceIP: "203.0.113.5", Priority: 2012}, true},
+		{"subnet in sources", RoutingPolicy{SourceIP: "203.0.113.32/27", Priority: 2019}, true},
+		{"wider than sources", RoutingPolicy{SourceIP: "203.0.113.0/24", Priority: 2012}, false},
+		{"foreign source", RoutingPolicy{SourceIP: "192.168.1.10", Priority: 2012}, false},
+		{"derived priority outside range", RoutingPolicy{SourceIP: "203.0.113.5"}, false},
+		{"grouped", RoutingPolicy{SourceIP: "203.0.113.5", Priority: 2012, GroupID: "IoT"}, false},
+	}
+	for _, tt := range tests {
+		err := tenant.Admits(&tt.policy)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Admits() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTenantAllowsProvider(t *testing.T) {
+	tenant := validTenant()
+	tenant.TableMin, tenant.TableMax = 1000, 1099
+	if err := tenant.AllowsProvider(&InternetProvider{ID: "isp1", TableID: 1000}); err != nil {
+		t.Errorf("table in range: %v", err)
+	}
+	if err := tenant.AllowsProvider(&InternetProvider{ID: "isp2", TableID: 100}); err == nil {
+		t.Errorf("table out of range: want error")
+	}
+	tenant.ProviderIDs = []string{"isp3"}
+	if err := tenant.AllowsProvider(&InternetProvider{ID: "isp1", TableID: 1000}); err == nil {
+		t.Errorf("unlisted provider: want error")
+	}
+}
+
+func TestTenantPolicyID(t *testing.T) {
+	id := NewTenantPolicyID("acme")
+	if got := PolicyTenant(id); got != "acme" {
+		t.Errorf("PolicyTenant(%s) = %q, want acme", id, got)
+	}
+	if !IsPolicyID(id) {
+		t.Errorf("IsPolicyID(%s) = false", id)
+	}
+	for _, id := range []string{NewPolicyID(), "192.168.1.10", "acme.192.168.1.10", "acme."} {
+		if got := PolicyTenant(id); got != "" {
+			t.Errorf("PolicyTenant(%s) = %q, want none", id, got)
+		}
+	}
+}
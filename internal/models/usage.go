@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultCapDrainPercent is the share of MonthlyCap at which policies are
+// drained to CapDrainTargetID when CapDrainPercent is unset.
+const DefaultCapDrainPercent = 90
+
+// validateCap checks the data-cap fields.
+func (p *InternetProvider) validateCap() error {
+	if p.MonthlyCap < 0 {
+		return fmt.Errorf("provider monthly_cap must not be negative")
+	}
+	if p.CapResetDay < 0 || p.CapResetDay > 28 {
+		return fmt.Errorf("provider cap_reset_day must be between 1 and 28")
+	}
+	if p.CapDrainPercent < 0 || p.CapDrainPercent > 100 {
+		return fmt.Errorf("provider cap_drain_percent must be between 1 and 100")
+	}
+	if p.CapDrainTargetID != "" {
+		if p.MonthlyCap == 0 {
+			return fmt.Errorf("provider cap_drain_target_id requires monthly_cap")
+		}
+		if p.CapDrainTargetID == p.ID {
+			return fmt.Errorf("provider cap_drain_target_id must differ from the provider")
+		}
+	}
+	return nil
+}
+
+// CapDrainThreshold returns the usage in bytes at which the provider's
+// policies are drained, or 0 when it has no cap or no drain target.
+func (p *InternetProvider) CapDrainThreshold() int64 {
+	if p.MonthlyCap == 0 || p.CapDrainTargetID == "" {
+		return 0
+	}
+	percent := p.CapDrainPercent
+	if percent == 0 {
+		percent = DefaultCapDrainPercent
+	}
+	return p.MonthlyCap / 100 * int64(percent)
+}
+
+// BillingPeriodStart returns the start (midnight UTC) of the billing period
+// containing now for a cycle that resets on resetDay; 0 means the 1st.
+func BillingPeriodStart(now time.Time, resetDay int) time.Time {
+	if resetDay == 0 {
+		resetDay = 1
+	}
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), resetDay, 0, 0, 0, 0, time.UTC)
+	if now.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
+
+// InterfaceCounters are the kernel byte counters last seen for one router
+// interface.
+type InterfaceCounters struct {
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+}
+
+// ProviderUsage is a provider's traffic in the current billing period,
+// summed over its interfaces on every router. Counters keeps the last
+// readings per "hostname/interface" so each heartbeat only adds the delta;
+// DrainedAt is set once the period's cap drain has run.
+type ProviderUsage struct {
+	ProviderID  string                       `json:"provider_id"`
+	PeriodStart time.Time                    `json:"period_start"`
+	RxBytes     uint64                       `json:"rx_bytes"`
+	TxBytes     uint64                       `json:"tx_bytes"`
+	Counters    map[string]InterfaceCounters `json:"counters,omitempty"`
+	DrainedAt   *time.Time                   `json:"drained_at,omitempty"`
+	UpdatedAt   time.Time                    `json:"updated_at"`
+}
+
+// Total returns the bytes received and sent in the period.
+func (u *ProviderUsage) Total() uint64 {
+	return u.RxBytes + u.TxBytes
+}
+
+// Rollover starts a new billing period when periodStart is after the
+// recorded one. The counter baselines are kept so traffic from before the
+// reset is not counted again.
+func (u *ProviderUsage) Rollover(periodStart time.Time) {
+	if !u.PeriodStart.Before(periodStart) {
+		return
+	}
+	u.PeriodStart = periodStart
+	u.RxBytes = 0
+	u.TxBytes = 0
+	u.DrainedAt = nil
+}
+
+// Accumulate adds the traffic since the previous reading of key. The first
+// reading only records a baseline; a counter that went backwards (reboot,
+// interface recreated) counts from zero.
+func (u *ProviderUsage) Accumulate(key string, c InterfaceCounters) {
+	if u.Counters == nil {
+		u.Counters = make(map[string]InterfaceCounters)
+	}
+	prev, ok := u.Counters[key]
+	u.Counters[key] = c
+	if !ok {
+		return
+	}
+	u.RxBytes += counterDelta(prev.RxBytes, c.RxBytes)
+	u.TxBytes += counterDelta(prev.TxBytes, c.TxBytes)
+}
+
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// ToJSON converts the model to JSON
+func (u *ProviderUsage) ToJSON() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+// FromJSON populates the model from JSON
+func (u *ProviderUsage) FromJSON(data []byte) error {
+	return json.Unmarshal(data, u)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInternetProvider_ValidateCap(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       InternetProvider
+		wantErr bool
+	}{
+		{"unset", InternetProvider{ID: "a"}, false},
+		{"cap with drain", InternetProvider{ID: "a", MonthlyCap: 1 << 40, CapResetDay: 15, CapDrainPercent: 80, CapDrainTargetID: "b"}, false},
+		{"negative cap", InternetProvider{ID: "a", MonthlyCap: -1}, true},
+		{"reset day too late", InternetProvider{ID: "a", MonthlyCap: 1, CapResetDay: 29}, true},
+		{"percent too high", InternetProvider{ID: "a", MonthlyCap: 1, CapDrainPercent: 101}, true},
+		{"drain without cap", InternetProvider{ID: "a", CapDrainTargetID: "b"}, true},
+		{"drain to itself", InternetProvider{ID: "a", MonthlyCap: 1, CapDrainTargetID: "a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.validateCap(); (err != nil) != tt.wantErr {
+				t.Errorf("validateCap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInternetProvider_CapDrainThreshold(t *testing.T) {
+	if got := (&InternetProvider{MonthlyCap: 1000}).CapDrainThreshold(); got != 0 {
+		t.Errorf("CapDrainThreshold() without target = %d, want 0", got)
+	}
+	if got := (&InternetProvider{MonthlyCap: 1000, CapDrainTargetID: "b"}).CapDrainThreshold(); got != 900 {
+		t.Errorf("CapDrainThreshold() = %d, want 900", got)
+	}
+	if got := (&InternetProvider{MonthlyCap: 1000, CapDrainPercent: 50, CapDrainTargetID: "b"}).CapDrainThreshold(); got != 500 {
+		t.Errorf("CapDrainThreshold() = %d, want 500", got)
+	}
+}
+
+func TestBillingPeriodStart(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	if got, want := BillingPeriodStart(now, 0), time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("BillingPeriodStart(day 0) = %v, want %v", got, want)
+	}
+	if got, want := BillingPeriodStart(now, 15), time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("BillingPeriodStart(day 15) = %v, want %v", got, want)
+	}
+}
+
+func TestProviderUsage_Accumulate(t *testing.T) {
+	u := &ProviderUsage{}
+	u.Accumulate("r1/eth0", InterfaceCounters{RxBytes: 1000, TxBytes: 100})
+	if u.Total() != 0 {
+		t.Fatalf("first reading counted %d bytes, want a baseline only", u.Total())
+	}
+	u.Accumulate("r1/eth0", InterfaceCounters{RxBytes: 1500, TxBytes: 150})
+	if u.RxBytes != 500 || u.TxBytes != 50 {
+		t.Errorf("after delta: rx=%d tx=%d, want 500/50", u.RxBytes, u.TxBytes)
+	}
+	// Counter reset (reboot): the new reading counts from zero.
+	u.Accumulate("r1/eth0", InterfaceCounters{RxBytes: 200, TxBytes: 20})
+	if u.RxBytes != 700 || u.TxBytes != 70 {
+		t.Errorf("after reset: rx=%d tx=%d, want 700/70", u.RxBytes, u.TxBytes)
+	}
+
+	drained := time.Now()
+	u.DrainedAt = &drained
+	u.Rollover(time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC))
+	if u.Total() != 0 || u.DrainedAt != nil {
+		t.Errorf("Rollover() kept usage %d / drained %v", u.Total(), u.DrainedAt)
+	}
+	if u.Counters["r1/eth0"].RxBytes != 200 {
+		t.Errorf("Rollover() dropped the counter baseline")
+	}
+}
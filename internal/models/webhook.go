@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook is an outbound subscription: matching events are POSTed as JSON to
+// URL. Events lists event types (e.g. "config.changed", "sync.completed");
+// empty means every event. When Secret is set each delivery is signed with
+// HMAC-SHA256 (see internal/webhook).
+type Webhook struct {
+	ID          string    `json:"id" yaml:"id"`
+	URL         string    `json:"url" yaml:"url"`
+	Events      []string  `json:"events" yaml:"events"`
+	Secret      string    `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Enabled     bool      `json:"enabled" yaml:"enabled"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// Validate validates the Webhook
+func (w *Webhook) Validate() error {
+	if w.ID == "" {
+		return fmt.Errorf("webhook ID is required")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook URL must be an absolute http(s) URL")
+	}
+	for _, t := range w.Events {
+		if t == "" {
+			return fmt.Errorf("webhook event types must not be empty")
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the webhook subscribes to eventType.
+func (w *Webhook) Wants(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ToJSON converts the model to JSON
+func (w *Webhook) ToJSON() ([]byte, error) {
+	return json.Marshal(w)
+}
+
+// FromJSON populates the model from JSON
+func (w *Webhook) FromJSON(data []byte) error {
+	return json.Unmarshal(data, w)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Provider weight bounds; an unset (0) weight counts as DefaultWeight.
+const (
+	DefaultWeight = 1
+	MaxWeight     = 100
+)
+
+// EffectiveWeight returns Weight, or DefaultWeight when unset.
+func (p *InternetProvider) EffectiveWeight() int {
+	if p.Weight == 0 {
+		return DefaultWeight
+	}
+	return p.Weight
+}
+
+// validateBalancing checks Weight and FailoverPriority.
+func (p *InternetProvider) validateBalancing() error {
+	if p.Weight < 0 || p.Weight > MaxWeight {
+		return fmt.Errorf("provider weight must be between 1 and %d", MaxWeight)
+	}
+	if p.FailoverPriority < 0 {
+		return fmt.Errorf("provider failover_priority must not be negative")
+	}
+	return nil
+}
+
+// FailoverOrder returns the providers with a failover priority, most
+// preferred (lowest priority) first. Ties, which validation normally
+// prevents, are broken by ID.
+func FailoverOrder(providers []*InternetProvider) []*InternetProvider {
+	var out []*InternetProvider
+	for _, p := range providers {
+		if p.FailoverPriority > 0 {
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].FailoverPriority != out[j].FailoverPriority {
+			return out[i].FailoverPriority < out[j].FailoverPriority
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// FailoverPriorityOwner returns the provider other than p that already uses
+// p's failover priority, or nil (also when p has none).
+func FailoverPriorityOwner(p *InternetProvider, providers []*InternetProvider) *InternetProvider {
+	if p.FailoverPriority == 0 {
+		return nil
+	}
+	for _, other := range providers {
+		if other.ID != p.ID && other.FailoverPriority == p.FailoverPriority {
+			return other
+		}
+	}
+	return nil
+}
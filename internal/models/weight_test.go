@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestInternetProvider_ValidateBalancing(t *testing.T) {
+	tests := []struct {
+		name    string
+		weight  int
+		prio    int
+		wantErr bool
+	}{
+		{"unset", 0, 0, false},
+		{"bounds", 100, 1, false},
+		{"weight too high", 101, 0, true},
+		{"negative weight", -1, 0, true},
+		{"negative priority", 1, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &InternetProvider{Weight: tt.weight, FailoverPriority: tt.prio}
+			if err := p.validateBalancing(); (err != nil) != tt.wantErr {
+				t.Errorf("validateBalancing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInternetProvider_EffectiveWeight(t *testing.T) {
+	if w := (&InternetProvider{}).EffectiveWeight(); w != DefaultWeight {
+		t.Errorf("EffectiveWeight() = %d, want %d", w, DefaultWeight)
+	}
+	if w := (&InternetProvider{Weight: 40}).EffectiveWeight(); w != 40 {
+		t.Errorf("EffectiveWeight() = %d, want 40", w)
+	}
+}
+
+func TestFailoverOrder(t *testing.T) {
+	providers := []*InternetProvider{
+		{ID: "backup", FailoverPriority: 2},
+		{ID: "none"},
+		{ID: "primary", FailoverPriority: 1},
+	}
+	got := FailoverOrder(providers)
+	if len(got) != 2 || got[0].ID != "primary" || got[1].ID != "backup" {
+		t.Errorf("FailoverOrder() = %v, want [primary backup]", got)
+	}
+	if owner := FailoverPriorityOwner(&InternetProvider{ID: "new", FailoverPriority: 2}, providers); owner == nil || owner.ID != "backup" {
+		t.Errorf("FailoverPriorityOwner() = %v, want backup", owner)
+	}
+	if owner := FailoverPriorityOwner(&InternetProvider{ID: "backup", FailoverPriority: 2}, providers); owner != nil {
+		t.Errorf("FailoverPriorityOwner() = %v, want nil for the provider itself", owner)
+	}
+}
@@ -0,0 +1,295 @@
+// Package monitoring generates the recommended Prometheus recording and
+// alerting rules and a Grafana dashboard for router-sync. Both are built
+// from the metric names the API and agent register, under the configured
+// metrics.namespace, so they cannot drift from the code.
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"router-sync/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metrics lists the metrics (without namespace) the rules and dashboard
+// use. Tests check each one is registered by the API or the agent.
+var Metrics = []string{
+	"agent_drift_changes",
+	"agent_failbacks_total",
+	"agent_failovers_total",
+	"agent_health_probe_loss_ratio",
+	"agent_last_sync_success_timestamp_seconds",
+	"agent_managed_rules",
+	"agent_orphan_rules",
+	"agent_policy_bytes_total",
+	"agent_provider_downtime_seconds_total",
+	"agent_provider_flaps",
+	"agent_provider_routes",
+	"agent_provider_up",
+	"agent_rule_failures_total",
+	"agent_rules_added_total",
+	"agent_rules_removed_total",
+	"agent_sync_consecutive_failures",
+	"agent_sync_duration_seconds",
+	"agent_sync_failures_total",
+	"agent_sync_total",
+	"agent_watch_events_total",
+	"agent_watch_lag_seconds",
+	"fleet_agents",
+	"http_request_duration_seconds",
+	"http_requests_total",
+	"nats_connected",
+	"nats_last_kv_success_timestamp_seconds",
+	"nats_reconnects_total",
+	"router_state_age_seconds",
+	"routers_known",
+}
+
+// names prefixes metric names with a namespace.
+type names string
+
+func newNames(namespace string) names {
+	if namespace == "" {
+		namespace = config.DefaultMetricsNamespace
+	}
+	return names(namespace)
+}
+
+func (n names) m(metric string) string {
+	return string(n) + "_" + metric
+}
+
+// RuleFile is a Prometheus rule file.
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup is one group of a rule file.
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a recording rule (Record set) or an alerting rule (Alert set).
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Rules returns the recommended recording and alerting rules.
+func Rules(namespace string) RuleFile {
+	n := newNames(namespace)
+	ns := string(n)
+	alert := func(name, expr, forDuration, severity, summary, description string) Rule {
+		return Rule{
+			Alert:  name,
+			Expr:   expr,
+			For:    forDuration,
+			Labels: map[string]string{"severity": severity},
+			Annotations: map[string]string{
+				"summary":     summary,
+				"description": description,
+			},
+		}
+	}
+	return RuleFile{Groups: []RuleGroup{
+		{
+			Name: "router-sync-recording",
+			Rules: []Rule{
+				{Record: ns + ":agent_sync_failures:rate15m", Expr: fmt.Sprintf("sum by (node) (rate(%s[15m]))", n.m("agent_sync_failures_total"))},
+				{Record: ns + ":agent_sync_duration_seconds:p95", Expr: fmt.Sprintf("histogram_quantile(0.95, sum by (node, le) (rate(%s_bucket[10m])))", n.m("agent_sync_duration_seconds"))},
+				{Record: ns + ":agent_watch_lag_seconds:p99", Expr: fmt.Sprintf("histogram_quantile(0.99, sum by (node, le) (rate(%s_bucket[10m])))", n.m("agent_watch_lag_seconds"))},
+				{Record: ns + ":agent_provider_availability:ratio30d", Expr: fmt.Sprintf("1 - increase(%s[30d]) / (30 * 86400)", n.m("agent_provider_downtime_seconds_total"))},
+				{Record: ns + ":agent_rule_changes:rate15m", Expr: fmt.Sprintf("sum by (node) (rate(%s[15m]) + rate(%s[15m]))", n.m("agent_rules_added_total"), n.m("agent_rules_removed_total"))},
+			},
+		},
+		{
+			Name: "router-sync-alerts",
+			Rules: []Rule{
+				alert("RouterSyncProviderDown", n.m("agent_provider_up")+" == 0", "2m", "critical",
+					"Provider {{ $labels.provider }} is down on {{ $labels.node }}",
+					"Health probes for {{ $labels.provider }} have failed on {{ $labels.node }} for 2 minutes."),
+				alert("RouterSyncProviderLoss", n.m("agent_health_probe_loss_ratio")+" > 0.2", "10m", "warning",
+					"Provider {{ $labels.provider }} is losing probes on {{ $labels.node }}",
+					"{{ $value | humanizePercentage }} of the last health probes were lost."),
+				alert("RouterSyncProviderFlapping", n.m("agent_provider_flaps")+" >= 4", "", "warning",
+					"Provider {{ $labels.provider }} is flapping on {{ $labels.node }}",
+					"{{ $value }} up/down transitions in the last hour."),
+				alert("RouterSyncDriftDetected", fmt.Sprintf("sum by (node) (%s) > 0", n.m("agent_drift_changes")), "10m", "warning",
+					"Kernel state on {{ $labels.node }} differs from the store",
+					"{{ $value }} rule or route differences have persisted across syncs; see GET /api/v1/diff."),
+				alert("RouterSyncOrphanRules", n.m("agent_orphan_rules")+" > 0", "15m", "warning",
+					"Managed rules without a policy on {{ $labels.node }}",
+					"{{ $value }} managed ip rules have no enabled policy."),
+				alert("RouterSyncSyncFailing", n.m("agent_sync_consecutive_failures")+" >= 3", "5m", "critical",
+					"Full sync is failing on {{ $labels.node }}",
+					"{{ $value }} full syncs in a row have failed."),
+				alert("RouterSyncSyncStale", fmt.Sprintf("time() - %s > 900", n.m("agent_last_sync_success_timestamp_seconds")), "5m", "warning",
+					"No successful sync on {{ $labels.node }} for 15 minutes",
+					"The agent has not completed a full sync for {{ $value | humanizeDuration }}."),
+				alert("RouterSyncNATSDisconnected", n.m("nats_connected")+" == 0", "2m", "critical",
+					"{{ $labels.node }} lost its NATS connection",
+					"The store connection has been down for 2 minutes; configuration changes are not applied."),
+				alert("RouterSyncStoreUnresponsive", fmt.Sprintf("time() - %s > 300", n.m("nats_last_kv_success_timestamp_seconds")), "5m", "critical",
+					"NATS KV has not answered {{ $labels.node }} for 5 minutes",
+					"No KV operation has succeeded for {{ $value | humanizeDuration }}."),
+				alert("RouterSyncAgentDead", n.m("fleet_agents")+`{state="dead"} > 0`, "2m", "critical",
+					"{{ $value }} router-sync agents stopped sending heartbeats",
+					"See GET /api/v1/fleet for the agents marked dead; DELETE /api/v1/fleet/{hostname} forgets a decommissioned one."),
+				alert("RouterSyncRouterStale", n.m("router_state_age_seconds")+" > 180", "5m", "warning",
+					"Router {{ $labels.hostname }} stopped reporting state",
+					"The last heartbeat from {{ $labels.hostname }} is {{ $value | humanizeDuration }} old."),
+			},
+		},
+	}}
+}
+
+// RulesYAML renders Rules as a Prometheus rule file.
+func RulesYAML(namespace string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(Rules(namespace)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Dashboard returns a Grafana dashboard model (schema 38) with a
+// datasource and a node variable.
+func Dashboard(namespace string) map[string]interface{} {
+	n := newNames(namespace)
+	nodeSel := `{node=~"$node"}`
+	sel := func(metric string) string { return n.m(metric) + nodeSel }
+	type target struct{ expr, legend string }
+	var panels []map[string]interface{}
+	add := func(title, unit string, width int, targets ...target) {
+		x, y := 0, 0
+		if len(panels) > 0 {
+			last := panels[len(panels)-1]["gridPos"].(map[string]int)
+			x, y = last["x"]+last["w"], last["y"]
+			if x+width > 24 {
+				x, y = 0, y+last["h"]
+			}
+		}
+		ts := make([]map[string]interface{}, len(targets))
+		for i, t := range targets {
+			ts[i] = map[string]interface{}{
+				"refId":        string(rune('A' + i)),
+				"expr":         t.expr,
+				"legendFormat": t.legend,
+				"datasource":   map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			}
+		}
+		panels = append(panels, map[string]interface{}{
+			"id":          len(panels) + 1,
+			"type":        "timeseries",
+			"title":       title,
+			"datasource":  map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":     map[string]int{"x": x, "y": y, "w": width, "h": 8},
+			"fieldConfig": map[string]interface{}{"defaults": map[string]interface{}{"unit": unit}, "overrides": []interface{}{}},
+			"targets":     ts,
+		})
+	}
+
+	add("Provider up", "short", 12, target{sel("agent_provider_up"), "{{node}} {{provider}}"})
+	add("Probe loss", "percentunit", 12, target{sel("agent_health_probe_loss_ratio"), "{{node}} {{provider}}"})
+	add("Provider downtime (24h)", "s", 8, target{fmt.Sprintf("increase(%s[24h])", sel("agent_provider_downtime_seconds_total")), "{{node}} {{provider}}"})
+	add("Provider flaps (1h)", "short", 8, target{sel("agent_provider_flaps"), "{{node}} {{provider}}"})
+	add("Failovers and failbacks", "short", 8,
+		target{fmt.Sprintf("sum by (node, provider) (increase(%s[1h]))", sel("agent_failovers_total")), "{{node}} {{provider}} failover"},
+		target{fmt.Sprintf("sum by (node, provider) (increase(%s[1h]))", sel("agent_failbacks_total")), "{{node}} {{provider}} failback"})
+	add("Drift", "short", 8, target{fmt.Sprintf("sum by (node, kind) (%s)", sel("agent_drift_changes")), "{{node}} {{kind}}"})
+	add("Managed and orphan rules", "short", 8,
+		target{sel("agent_managed_rules"), "{{node}} managed"},
+		target{sel("agent_orphan_rules"), "{{node}} orphan"})
+	add("Routes per provider table", "short", 8, target{sel("agent_provider_routes"), "{{node}} {{provider}}"})
+	add("Syncs", "ops", 8,
+		target{fmt.Sprintf("sum by (node) (rate(%s[5m]))", sel("agent_sync_total")), "{{node}} total"},
+		target{fmt.Sprintf("sum by (node) (rate(%s[5m]))", sel("agent_sync_failures_total")), "{{node}} failed"})
+	add("Sync duration p95", "s", 8, target{fmt.Sprintf("histogram_quantile(0.95, sum by (node, le) (rate(%s_bucket%s[5m])))", n.m("agent_sync_duration_seconds"), nodeSel), "{{node}}"})
+	add("Consecutive sync failures", "short", 8, target{sel("agent_sync_consecutive_failures"), "{{node}}"})
+	add("Rule changes", "ops", 8,
+		target{fmt.Sprintf("sum by (node) (rate(%s[5m]))", sel("agent_rules_added_total")), "{{node}} added"},
+		target{fmt.Sprintf("sum by (node) (rate(%s[5m]))", sel("agent_rules_removed_total")), "{{node}} removed"},
+		target{fmt.Sprintf("sum by (node) (rate(%s[5m]))", sel("agent_rule_failures_total")), "{{node}} failed"})
+	add("Watch lag p99", "s", 8, target{fmt.Sprintf("histogram_quantile(0.99, sum by (node, watcher, le) (rate(%s_bucket%s[5m])))", n.m("agent_watch_lag_seconds"), nodeSel), "{{node}} {{watcher}}"})
+	add("Watch events", "ops", 8, target{fmt.Sprintf("sum by (node, watcher) (rate(%s[5m]))", sel("agent_watch_events_total")), "{{node}} {{watcher}}"})
+	add("Policy traffic", "Bps", 24, target{fmt.Sprintf("sum by (policy, direction) (rate(%s[5m]))", sel("agent_policy_bytes_total")), "{{policy}} {{direction}}"})
+	add("NATS connected", "short", 8, target{sel("nats_connected"), "{{node}}"})
+	add("Since last KV success", "s", 8, target{fmt.Sprintf("time() - %s", sel("nats_last_kv_success_timestamp_seconds")), "{{node}}"})
+	add("NATS reconnects", "short", 8, target{fmt.Sprintf("increase(%s[1h])", sel("nats_reconnects_total")), "{{node}}"})
+	add("API requests", "reqps", 8, target{fmt.Sprintf("sum by (status) (rate(%s[5m]))", n.m("http_requests_total")), "{{status}}"})
+	add("API latency p95", "s", 8, target{fmt.Sprintf("histogram_quantile(0.95, sum by (le) (rate(%s_bucket[5m])))", n.m("http_request_duration_seconds")), "p95"})
+	add("Fleet", "short", 8, target{fmt.Sprintf("max by (state) (%s)", n.m("fleet_agents")), "{{state}}"})
+	add("Routers", "short", 8,
+		target{n.m("routers_known"), "known"},
+		target{fmt.Sprintf("max by (hostname) (%s)", n.m("router_state_age_seconds")), "{{hostname}} heartbeat age"})
+
+	return map[string]interface{}{
+		"title":         "router-sync",
+		"uid":           "router-sync",
+		"tags":          []string{"router-sync"},
+		"schemaVersion": 38,
+		"timezone":      "browser",
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{"list": []map[string]interface{}{
+			{"name": "datasource", "type": "datasource", "query": "prometheus", "label": "Data source"},
+			{
+				"name":       "node",
+				"type":       "query",
+				"label":      "Node",
+				"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+				"query":      fmt.Sprintf("label_values(%s, node)", n.m("agent_sync_total")),
+				"refresh":    2,
+				"multi":      true,
+				"includeAll": true,
+				"allValue":   ".*",
+				"current":    map[string]interface{}{"text": "All", "value": "$__all"},
+			},
+		}},
+		"panels": panels,
+	}
+}
+
+// DashboardJSON renders Dashboard as indented JSON, ready for Grafana's
+// dashboard import.
+func DashboardJSON(namespace string) ([]byte, error) {
+	out, err := json.MarshalIndent(Dashboard(namespace), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// usedMetrics returns the namespace-stripped metric names found in s,
+// without histogram suffixes; tests use it to check Metrics is complete.
+func usedMetrics(namespace, s string) map[string]bool {
+	used := make(map[string]bool)
+	prefix := namespace + "_"
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if !strings.HasPrefix(field, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(field, prefix)
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		used[name] = true
+	}
+	return used
+}
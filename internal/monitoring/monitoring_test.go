@@ -0,0 +1,71 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRulesAndDashboardUseListedMetrics(t *testing.T) {
+	listed := make(map[string]bool, len(Metrics))
+	for _, m := range Metrics {
+		listed[m] = true
+	}
+
+	rules, err := RulesYAML("")
+	require.NoError(t, err)
+	dashboard, err := DashboardJSON("")
+	require.NoError(t, err)
+
+	used := usedMetrics("router_sync", string(rules)+string(dashboard))
+	for m := range used {
+		assert.True(t, listed[m], "%s is used but not in Metrics", m)
+	}
+	for m := range listed {
+		assert.True(t, used[m], "%s is in Metrics but unused", m)
+	}
+}
+
+func TestRulesYAML(t *testing.T) {
+	out, err := RulesYAML("edge")
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "router_sync")
+
+	var file RuleFile
+	require.NoError(t, yaml.Unmarshal(out, &file))
+	alerts := map[string]Rule{}
+	for _, g := range file.Groups {
+		for _, r := range g.Rules {
+			if r.Alert != "" {
+				alerts[r.Alert] = r
+			}
+		}
+	}
+	for _, name := range []string{"RouterSyncProviderDown", "RouterSyncDriftDetected", "RouterSyncSyncFailing"} {
+		require.Contains(t, alerts, name)
+		assert.True(t, strings.HasPrefix(alerts[name].Expr, "edge_") || strings.Contains(alerts[name].Expr, "(edge_"), alerts[name].Expr)
+	}
+}
+
+func TestDashboardJSON(t *testing.T) {
+	out, err := DashboardJSON("")
+	require.NoError(t, err)
+	var model struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			ID      int            `json:"id"`
+			GridPos map[string]int `json:"gridPos"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(out, &model))
+	assert.Equal(t, "router-sync", model.UID)
+	require.NotEmpty(t, model.Panels)
+	for i, p := range model.Panels {
+		assert.Equal(t, i+1, p.ID)
+		assert.LessOrEqual(t, p.GridPos["x"]+p.GridPos["w"], 24)
+	}
+}
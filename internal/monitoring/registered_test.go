@@ -0,0 +1,57 @@
+package monitoring_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"router-sync/internal/agent"
+	"router-sync/internal/config"
+	"router-sync/internal/monitoring"
+	"router-sync/internal/nats"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a Registerer that only notes the metric names described.
+type recorder map[string]bool
+
+var fqNameRE = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+func (r recorder) Register(c prometheus.Collector) error {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	for d := range ch {
+		if m := fqNameRE.FindStringSubmatch(d.String()); m != nil {
+			r[m[1]] = true
+		}
+	}
+	return nil
+}
+
+func (r recorder) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		_ = r.Register(c)
+	}
+}
+
+func (r recorder) Unregister(prometheus.Collector) bool { return true }
+
+// The API metrics are checked in the api package, which can reach its
+// registry.
+func TestAgentMetricsRegistered(t *testing.T) {
+	names := recorder{}
+	cfg := config.Config{Features: config.FeaturesConfig{Failover: true}}
+	agent.NewService(nil, nil, cfg, "test", names)
+	names.MustRegister(&nats.Client{})
+
+	for _, m := range monitoring.Metrics {
+		if strings.HasPrefix(m, "agent_") || strings.HasPrefix(m, "nats_") {
+			assert.True(t, names[m], "%s is not registered by the agent", m)
+		}
+	}
+}
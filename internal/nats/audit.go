@@ -0,0 +1,104 @@
+package nats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bucketAudit = "router-sync-audit"
+
+	// auditRetention is how long audit entries are kept (bucket TTL).
+	auditRetention = 90 * 24 * time.Hour
+
+	auditKeyPrefix = "entries."
+)
+
+// RecordAudit appends an audit entry. ID and Timestamp are filled in when
+// empty. Keys embed the zero-padded timestamp so they sort chronologically.
+func (c *Client) RecordAudit(entry *models.AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	if entry.ID == "" {
+		entry.ID = newAuditID(entry.Timestamp)
+	}
+	data, err := entry.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if _, err := c.kvAudit.Create(auditKeyPrefix+entry.ID, data); err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns entries matching filter, newest first.
+func (c *Client) ListAudit(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	keys, err := c.kvAudit.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to list audit keys: %w", err)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	out := make([]*models.AuditEntry, 0)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, auditKeyPrefix) {
+			continue
+		}
+		// Skip entries outside the time range without fetching them.
+		if ts, ok := auditKeyTime(key); ok && !filter.InRange(ts) {
+			continue
+		}
+		kvEntry, err := c.kvAudit.Get(key)
+		if err != nil {
+			logrus.Debugf("Skipping audit entry %s: %v", key, err)
+			continue
+		}
+		var entry models.AuditEntry
+		if err := entry.FromJSON(kvEntry.Value()); err != nil {
+			logrus.Warnf("Skipping malformed audit entry %s: %v", key, err)
+			continue
+		}
+		if !filter.Matches(&entry) {
+			continue
+		}
+		out = append(out, &entry)
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// newAuditID returns "<unix nanos, 19 digits>-<random hex>".
+func newAuditID(ts time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%019d-%s", ts.UnixNano(), hex.EncodeToString(b))
+}
+
+func auditKeyTime(key string) (time.Time, bool) {
+	id := strings.TrimPrefix(key, auditKeyPrefix)
+	nanos, _, ok := strings.Cut(id, "-")
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n).UTC(), true
+}
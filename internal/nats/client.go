@@ -18,15 +18,41 @@ import (
 // This allows for mocking in tests.
 type NATSClient interface {
 	StoreProvider(provider *models.InternetProvider) error
+	StoreProviderIfMatch(provider *models.InternetProvider, generation uint64) error
 	GetProvider(id string) (*models.InternetProvider, error)
 	ListProviders() ([]*models.InternetProvider, error)
 	DeleteProvider(id string) error
 
 	StorePolicy(policy *models.RoutingPolicy) error
+	StorePolicyIfMatch(policy *models.RoutingPolicy, generation uint64) error
 	GetPolicy(id string) (*models.RoutingPolicy, error)
 	ListPolicies() ([]*models.RoutingPolicy, error)
 	DeletePolicy(id string) error
 
+	StoreGroup(group *models.PolicyGroup) error
+	StoreGroupIfMatch(group *models.PolicyGroup, generation uint64) error
+	GetGroup(id string) (*models.PolicyGroup, error)
+	ListGroups() ([]*models.PolicyGroup, error)
+	DeleteGroup(id string) error
+
+	StoreTenant(tenant *models.Tenant) error
+	GetTenant(id string) (*models.Tenant, error)
+	ListTenants() ([]*models.Tenant, error)
+	DeleteTenant(id string) error
+
+	SetMaintenance(m *models.Maintenance) error
+	GetMaintenance() (*models.Maintenance, error)
+
+	StoreWebhook(hook *models.Webhook) error
+	GetWebhook(id string) (*models.Webhook, error)
+	ListWebhooks() ([]*models.Webhook, error)
+	DeleteWebhook(id string) error
+
+	StoreUsage(usage *models.ProviderUsage) error
+	GetUsage(providerID string) (*models.ProviderUsage, error)
+	ListUsage() ([]*models.ProviderUsage, error)
+	DeleteUsage(providerID string) error
+
 	StoreRouterState(state *models.RouterState) error
 	GetRouterState(hostname string) (*models.RouterState, error)
 	ListRouterStates() ([]*models.RouterState, error)
@@ -36,6 +62,24 @@ type NATSClient interface {
 	GetServiceLogLevel(serviceID string) (string, error)
 	ListServiceLogLevels() (map[string]string, error)
 
+	SendAgentCommand(ctx context.Context, hostname string, cmd *models.AgentCommand) (*models.AgentCommandResult, error)
+	PublishEvent(ev *models.Event) error
+	SubscribeEvents(ctx context.Context, callback func(*models.Event)) error
+	SubscribeEventsQueue(ctx context.Context, queue string, callback func(*models.Event)) error
+
+	RecordAudit(entry *models.AuditEntry) error
+	ListAudit(filter models.AuditFilter) ([]*models.AuditEntry, error)
+
+	ListJournal(filter models.JournalFilter) ([]*models.JournalEntry, error)
+
+	ListHeartbeats() ([]*models.Heartbeat, error)
+	DeleteHeartbeat(hostname string) error
+
+	ReserveIdempotencyKey(key string, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(key string, rec *models.IdempotencyRecord) error
+	ReleaseIdempotencyKey(key string) error
+
+	Connected() bool
 	Close()
 }
 
@@ -50,12 +94,22 @@ const (
 
 // Client represents a NATS client with key-value store capabilities
 type Client struct {
-	conn       *nats.Conn
-	js         nats.JetStreamContext
-	kv         nats.KeyValue
-	kvState    nats.KeyValue
-	kvLogging  nats.KeyValue
-	writerID   string
+	conn      *nats.Conn
+	js        nats.JetStreamContext
+	kv        nats.KeyValue
+	kvState   nats.KeyValue
+	kvLogging nats.KeyValue
+	kvAudit   nats.KeyValue
+	writerID  string
+
+	kvIdempotency nats.KeyValue
+	kvHeartbeats  nats.KeyValue
+
+	stats *connStats
+
+	// watchObserver is told about every update the config watchers
+	// handle, see SetWatchObserver.
+	watchObserver func(WatchUpdate)
 }
 
 // sanitizeKey sanitizes a key to be compatible with NATS key-value store
@@ -80,11 +134,26 @@ func sanitizeKey(key string) string {
 
 // NewClient creates a new NATS client
 func NewClient(cfg config.NATSConfig) (*Client, error) {
+	stats := &connStats{}
 	opts := []nats.Option{
 		nats.Name(cfg.ClientID),
 		nats.Timeout(10 * time.Second),
 		nats.ReconnectWait(1 * time.Second),
 		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if nc.IsClosed() {
+				return // Close, not a lost connection
+			}
+			stats.disconnects.Add(1)
+			if err != nil {
+				logrus.Warnf("Disconnected from NATS: %v", err)
+			} else {
+				logrus.Warn("Disconnected from NATS")
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logrus.Infof("Reconnected to NATS at %s", nc.ConnectedUrl())
+		}),
 	}
 
 	if cfg.Username != "" && cfg.Password != "" {
@@ -124,6 +193,29 @@ func NewClient(cfg config.NATSConfig) (*Client, error) {
 		return nil, err
 	}
 
+	kvAudit, err := ensureBucket(js, bucketAudit, auditRetention)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	kvIdempotency, err := ensureBucket(js, bucketIdempotency, idempotencyRetention)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	kvHeartbeats, err := ensureBucket(js, bucketHeartbeats, heartbeatRetention)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ensureJournalStream(js); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	writerID := cfg.WriterID
 	if writerID == "" {
 		writerID = cfg.ClientID
@@ -132,10 +224,15 @@ func NewClient(cfg config.NATSConfig) (*Client, error) {
 	client := &Client{
 		conn:      conn,
 		js:        js,
-		kv:        kv,
-		kvState:   kvState,
-		kvLogging: kvLogging,
+		kv:        &trackedKV{kv, stats},
+		kvState:   &trackedKV{kvState, stats},
+		kvLogging: &trackedKV{kvLogging, stats},
+		kvAudit:   &trackedKV{kvAudit, stats},
 		writerID:  writerID,
+		stats:     stats,
+
+		kvIdempotency: &trackedKV{kvIdempotency, stats},
+		kvHeartbeats:  &trackedKV{kvHeartbeats, stats},
 	}
 
 	if err := client.testKeyValueStore(); err != nil {
@@ -168,6 +265,11 @@ func (c *Client) Close() {
 	}
 }
 
+// Connected reports whether the underlying NATS connection is currently up.
+func (c *Client) Connected() bool {
+	return c.conn != nil && c.conn.IsConnected()
+}
+
 // WriterID returns the writer identity used for active/active conflict resolution.
 func (c *Client) WriterID() string {
 	return c.writerID
@@ -175,6 +277,12 @@ func (c *Client) WriterID() string {
 
 // StoreProvider stores an internet provider in the key-value store using revision CAS.
 func (c *Client) StoreProvider(provider *models.InternetProvider) error {
+	return c.StoreProviderIfMatch(provider, 0)
+}
+
+// StoreProviderIfMatch is StoreProvider that fails with ErrPreconditionFailed
+// unless the stored provider is at generation (0 skips the check).
+func (c *Client) StoreProviderIfMatch(provider *models.InternetProvider, generation uint64) error {
 	key := fmt.Sprintf("providers.%s", sanitizeKey(provider.ID))
 	logrus.Debugf("Storing provider with key: %s (original ID: %s)", key, provider.ID)
 
@@ -187,6 +295,9 @@ func (c *Client) StoreProvider(provider *models.InternetProvider) error {
 			}
 			prev = &parsed
 		}
+		if generation != 0 && (prev == nil || prev.Generation != generation) {
+			return nil, ErrPreconditionFailed
+		}
 		PrepareProviderWrite(provider, prev, c.writerID)
 		return provider.ToJSON()
 	})
@@ -257,6 +368,12 @@ func (c *Client) DeleteProvider(id string) error {
 
 // StorePolicy stores a routing policy in the key-value store using revision CAS.
 func (c *Client) StorePolicy(policy *models.RoutingPolicy) error {
+	return c.StorePolicyIfMatch(policy, 0)
+}
+
+// StorePolicyIfMatch is StorePolicy that fails with ErrPreconditionFailed
+// unless the stored policy is at generation (0 skips the check).
+func (c *Client) StorePolicyIfMatch(policy *models.RoutingPolicy, generation uint64) error {
 	key := fmt.Sprintf("policies.%s", sanitizeKey(policy.ID))
 
 	return c.storeWithCAS(c.kv, key, func(existing []byte) ([]byte, error) {
@@ -268,6 +385,9 @@ func (c *Client) StorePolicy(policy *models.RoutingPolicy) error {
 			}
 			prev = &parsed
 		}
+		if generation != 0 && (prev == nil || prev.Generation != generation) {
+			return nil, ErrPreconditionFailed
+		}
 		PreparePolicyWrite(policy, prev, c.writerID)
 		return policy.ToJSON()
 	})
@@ -391,18 +511,24 @@ func (c *Client) WatchProviders(ctx context.Context, callback func(*models.Inter
 	}
 	defer func() { _ = watcher.Stop() }()
 
+	// Existing keys are replayed first; a nil update marks the end of them
+	replay := true
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case update := <-watcher.Updates():
 			if update == nil {
+				replay = false
 				continue
 			}
 
 			if len(update.Key()) > 10 && update.Key()[:10] == "providers." {
+				started := time.Now()
 				if update.Operation() == nats.KeyValueDelete {
-					callback(nil, update.Operation())
+					// Only the ID is left; the agent knows the rest
+					callback(&models.InternetProvider{ID: update.Key()[10:]}, update.Operation())
+					c.observeWatch("providers", update, replay, started)
 					continue
 				}
 
@@ -412,6 +538,7 @@ func (c *Client) WatchProviders(ctx context.Context, callback func(*models.Inter
 					continue
 				}
 				callback(&provider, update.Operation())
+				c.observeWatch("providers", update, replay, started)
 			}
 		}
 	}
@@ -428,18 +555,23 @@ func (c *Client) WatchPolicies(ctx context.Context, callback func(*models.Routin
 	}
 	defer func() { _ = watcher.Stop() }()
 
+	// Existing keys are replayed first; a nil update marks the end of them
+	replay := true
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case update := <-watcher.Updates():
 			if update == nil {
+				replay = false
 				continue
 			}
 
 			if len(update.Key()) > 9 && update.Key()[:9] == "policies." {
+				started := time.Now()
 				if update.Operation() == nats.KeyValueDelete {
 					callback(nil, update.Operation())
+					c.observeWatch("policies", update, replay, started)
 					continue
 				}
 
@@ -449,6 +581,7 @@ func (c *Client) WatchPolicies(ctx context.Context, callback func(*models.Routin
 					continue
 				}
 				callback(&policy, update.Operation())
+				c.observeWatch("policies", update, replay, started)
 			}
 		}
 	}
@@ -0,0 +1,123 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrAgentUnavailable is returned when no agent answers a command for a host.
+var ErrAgentUnavailable = errors.New("agent did not respond")
+
+// DefaultCommandTimeout bounds how long the API waits for an agent reply.
+const DefaultCommandTimeout = 10 * time.Second
+
+// agentCommandSubject is the request/reply subject an agent listens on.
+func agentCommandSubject(hostname string) string {
+	return fmt.Sprintf("router-sync.agent.%s.cmd", sanitizeKey(hostname))
+}
+
+// SendAgentCommand sends cmd to the agent on hostname and waits for its reply.
+// It returns ErrAgentUnavailable when the agent is offline or times out. cmd
+// is not modified, so one command can be sent to several hosts at once.
+func (c *Client) SendAgentCommand(ctx context.Context, hostname string, cmd *models.AgentCommand) (*models.AgentCommandResult, error) {
+	sent := *cmd
+	if sent.RequestedAt.IsZero() {
+		sent.RequestedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(&sent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent command: %w", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCommandTimeout)
+		defer cancel()
+	}
+
+	msg, err := c.conn.RequestWithContext(ctx, agentCommandSubject(hostname), data)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) || errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s (%v)", ErrAgentUnavailable, hostname, err)
+		}
+		return nil, fmt.Errorf("agent command %s to %s failed: %w", cmd.Command, hostname, err)
+	}
+
+	var result models.AgentCommandResult
+	if err := json.Unmarshal(msg.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent reply: %w", err)
+	}
+	return &result, nil
+}
+
+// ServeAgentCommands answers commands addressed to hostname until ctx is done.
+// handler runs on its own goroutine for each command, so a long one such as
+// a full sync does not hold up the others; ServeAgentCommands returns once
+// the handlers still running have answered.
+func (c *Client) ServeAgentCommands(ctx context.Context, hostname string, handler func(*models.AgentCommand) *models.AgentCommandResult) error {
+	subject := agentCommandSubject(hostname)
+	var (
+		mu      sync.Mutex
+		stopped bool
+		running sync.WaitGroup
+	)
+	sub, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			answerAgentCommand(msg, hostname, handler)
+		}()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	logrus.Infof("Listening for agent commands on %s", subject)
+	<-ctx.Done()
+
+	_ = sub.Unsubscribe()
+	// A callback may still be running after Unsubscribe; it sees stopped
+	// and starts nothing once Wait may have begun.
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	running.Wait()
+	return nil
+}
+
+// answerAgentCommand runs handler on the command in msg and replies with
+// its result.
+func answerAgentCommand(msg *nats.Msg, hostname string, handler func(*models.AgentCommand) *models.AgentCommandResult) {
+	var cmd models.AgentCommand
+	var result *models.AgentCommandResult
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		result = &models.AgentCommandResult{Error: fmt.Sprintf("invalid command: %v", err)}
+	} else {
+		result = handler(&cmd)
+	}
+	result.Hostname = hostname
+	result.CompletedAt = time.Now().UTC()
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		logrus.Errorf("Failed to marshal reply to %s: %v", cmd.Command, err)
+		return
+	}
+	if err := msg.Respond(data); err != nil {
+		logrus.Warnf("Failed to reply to %s: %v", cmd.Command, err)
+	}
+}
@@ -10,6 +10,10 @@ import (
 // ErrConflict is returned when a write loses an active/active conflict.
 var ErrConflict = errors.New("write rejected: remote version is newer")
 
+// ErrPreconditionFailed is returned by the Store*IfMatch methods when the
+// stored generation is not the one the caller based its change on.
+var ErrPreconditionFailed = errors.New("write rejected: entity changed since it was read")
+
 // ShouldAcceptWrite decides whether an incoming record should replace existing state.
 // Active/active semantics: higher generation wins; then newer UpdatedAt; then lexicographic WriterID.
 func ShouldAcceptWrite(existingGen uint64, existingUpdatedAt time.Time, existingWriterID string,
@@ -32,6 +36,7 @@ func ShouldAcceptWrite(existingGen uint64, existingUpdatedAt time.Time, existing
 // PrepareProviderWrite assigns writer metadata and generation for a new revision.
 func PrepareProviderWrite(provider *models.InternetProvider, existing *models.InternetProvider, writerID string) {
 	now := time.Now().UTC()
+	provider.Status = nil // observed, never stored
 	provider.WriterID = writerID
 	provider.UpdatedAt = now
 	if existing == nil {
@@ -49,6 +54,7 @@ func PrepareProviderWrite(provider *models.InternetProvider, existing *models.In
 // PreparePolicyWrite assigns writer metadata and generation for a new revision.
 func PreparePolicyWrite(policy *models.RoutingPolicy, existing *models.RoutingPolicy, writerID string) {
 	now := time.Now().UTC()
+	policy.Status = nil // observed, never stored
 	policy.WriterID = writerID
 	policy.UpdatedAt = now
 	if existing == nil {
@@ -62,3 +68,37 @@ func PreparePolicyWrite(policy *models.RoutingPolicy, existing *models.RoutingPo
 	}
 	policy.Generation = existing.Generation + 1
 }
+
+// PrepareGroupWrite assigns writer metadata and generation for a new revision.
+func PrepareGroupWrite(group *models.PolicyGroup, existing *models.PolicyGroup, writerID string) {
+	now := time.Now().UTC()
+	group.WriterID = writerID
+	group.UpdatedAt = now
+	if existing == nil {
+		if group.Generation == 0 {
+			group.Generation = 1
+		}
+		if group.CreatedAt.IsZero() {
+			group.CreatedAt = now
+		}
+		return
+	}
+	group.Generation = existing.Generation + 1
+}
+
+// PrepareTenantWrite assigns writer metadata and generation for a new revision.
+func PrepareTenantWrite(tenant *models.Tenant, existing *models.Tenant, writerID string) {
+	now := time.Now().UTC()
+	tenant.WriterID = writerID
+	tenant.UpdatedAt = now
+	if existing == nil {
+		if tenant.Generation == 0 {
+			tenant.Generation = 1
+		}
+		if tenant.CreatedAt.IsZero() {
+			tenant.CreatedAt = now
+		}
+		return
+	}
+	tenant.Generation = existing.Generation + 1
+}
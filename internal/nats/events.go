@@ -0,0 +1,61 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// eventSubjectPrefix is the core NATS subject tree for real-time events.
+// Events are fire-and-forget: they are not persisted, late subscribers miss them.
+const eventSubjectPrefix = "router-sync.events"
+
+var eventSeq uint64
+
+// PublishEvent publishes ev on router-sync.events.<type>, filling ID and Timestamp if unset.
+func (c *Client) PublishEvent(ev *models.Event) error {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+	if ev.ID == "" {
+		ev.ID = strconv.FormatInt(ev.Timestamp.UnixNano(), 36) + "-" + strconv.FormatUint(atomic.AddUint64(&eventSeq, 1), 36)
+	}
+	data, err := ev.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return c.conn.Publish(eventSubjectPrefix+"."+ev.Type, data)
+}
+
+// SubscribeEvents delivers every event to callback until ctx is done.
+func (c *Client) SubscribeEvents(ctx context.Context, callback func(*models.Event)) error {
+	return c.SubscribeEventsQueue(ctx, "", callback)
+}
+
+// SubscribeEventsQueue is SubscribeEvents in a queue group: each event goes to
+// one member of queue only, so replicas can share work such as webhook delivery.
+// An empty queue subscribes normally.
+func (c *Client) SubscribeEventsQueue(ctx context.Context, queue string, callback func(*models.Event)) error {
+	sub, err := c.conn.QueueSubscribe(eventSubjectPrefix+".>", queue, func(msg *nats.Msg) {
+		var ev models.Event
+		if err := ev.FromJSON(msg.Data); err != nil {
+			logrus.Warnf("Failed to unmarshal event on %s: %v", msg.Subject, err)
+			return
+		}
+		callback(&ev)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,93 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Policy groups share the core bucket with providers and policies.
+const groupKeyPrefix = "groups."
+
+// StoreGroup stores a policy group in the key-value store using revision CAS.
+func (c *Client) StoreGroup(group *models.PolicyGroup) error {
+	return c.StoreGroupIfMatch(group, 0)
+}
+
+// StoreGroupIfMatch is StoreGroup that fails with ErrPreconditionFailed
+// unless the stored group is at generation (0 skips the check).
+func (c *Client) StoreGroupIfMatch(group *models.PolicyGroup, generation uint64) error {
+	key := groupKeyPrefix + sanitizeKey(group.ID)
+
+	return c.storeWithCAS(c.kv, key, func(existing []byte) ([]byte, error) {
+		var prev *models.PolicyGroup
+		if len(existing) > 0 {
+			var parsed models.PolicyGroup
+			if err := parsed.FromJSON(existing); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal existing group: %w", err)
+			}
+			prev = &parsed
+		}
+		if generation != 0 && (prev == nil || prev.Generation != generation) {
+			return nil, ErrPreconditionFailed
+		}
+		PrepareGroupWrite(group, prev, c.writerID)
+		return group.ToJSON()
+	})
+}
+
+// GetGroup retrieves a policy group from the key-value store
+func (c *Client) GetGroup(id string) (*models.PolicyGroup, error) {
+	entry, err := c.kv.Get(groupKeyPrefix + sanitizeKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	var group models.PolicyGroup
+	if err := group.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group: %w", err)
+	}
+
+	return &group, nil
+}
+
+// ListGroups retrieves all policy groups from the key-value store
+func (c *Client) ListGroups() ([]*models.PolicyGroup, error) {
+	keys, err := c.kv.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.PolicyGroup{}, nil
+		}
+		return nil, fmt.Errorf("failed to list group keys: %w", err)
+	}
+
+	groups := []*models.PolicyGroup{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, groupKeyPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(key, groupKeyPrefix)
+		group, err := c.GetGroup(id)
+		if err != nil {
+			logrus.Warnf("Failed to get group with sanitized ID %s: %v", id, err)
+			continue
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// DeleteGroup deletes a policy group from the key-value store. Member
+// policies are not touched; callers clear or delete them first.
+func (c *Client) DeleteGroup(id string) error {
+	if err := c.kv.Delete(groupKeyPrefix + sanitizeKey(id)); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	logrus.Debugf("Deleted group %s", id)
+	return nil
+}
@@ -0,0 +1,85 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bucketHeartbeats = "router-sync-heartbeats"
+
+	// heartbeatRetention is how long the last heartbeat of an agent that
+	// stopped publishing stays visible (bucket TTL).
+	heartbeatRetention = 24 * time.Hour
+
+	// heartbeatSubjectPrefix is the core NATS subject tree heartbeats are
+	// published on, one subject per hostname.
+	heartbeatSubjectPrefix = "router-sync.heartbeat"
+
+	heartbeatKeyPrefix = "nodes."
+)
+
+// PublishHeartbeat publishes hb on router-sync.heartbeat.<hostname> and
+// stores it as the agent's status key.
+func (c *Client) PublishHeartbeat(hb *models.Heartbeat) error {
+	if hb.Timestamp.IsZero() {
+		hb.Timestamp = time.Now().UTC()
+	}
+	data, err := hb.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+	host := sanitizeKey(hb.Hostname)
+	if err := c.conn.Publish(heartbeatSubjectPrefix+"."+host, data); err != nil {
+		return fmt.Errorf("failed to publish heartbeat: %w", err)
+	}
+	if _, err := c.kvHeartbeats.Put(heartbeatKeyPrefix+host, data); err != nil {
+		return fmt.Errorf("failed to store heartbeat: %w", err)
+	}
+	return nil
+}
+
+// ListHeartbeats returns the last heartbeat of every agent seen within
+// heartbeatRetention, dead and stopped ones included.
+func (c *Client) ListHeartbeats() ([]*models.Heartbeat, error) {
+	keys, err := c.kvHeartbeats.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.Heartbeat{}, nil
+		}
+		return nil, fmt.Errorf("failed to list heartbeat keys: %w", err)
+	}
+
+	out := make([]*models.Heartbeat, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, heartbeatKeyPrefix) {
+			continue
+		}
+		entry, err := c.kvHeartbeats.Get(key)
+		if err != nil {
+			logrus.Debugf("Skipping heartbeat %s: %v", key, err)
+			continue
+		}
+		var hb models.Heartbeat
+		if err := hb.FromJSON(entry.Value()); err != nil {
+			logrus.Warnf("Skipping malformed heartbeat %s: %v", key, err)
+			continue
+		}
+		out = append(out, &hb)
+	}
+	return out, nil
+}
+
+// DeleteHeartbeat forgets an agent's heartbeat, e.g. for a decommissioned
+// router.
+func (c *Client) DeleteHeartbeat(hostname string) error {
+	if err := c.kvHeartbeats.Delete(heartbeatKeyPrefix + sanitizeKey(hostname)); err != nil {
+		return fmt.Errorf("failed to delete heartbeat %s: %w", hostname, err)
+	}
+	return nil
+}
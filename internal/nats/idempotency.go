@@ -0,0 +1,65 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	bucketIdempotency = "router-sync-idempotency"
+
+	// idempotencyRetention is how long a key is remembered (bucket TTL).
+	idempotencyRetention = 24 * time.Hour
+)
+
+// ReserveIdempotencyKey claims key for a new request by storing rec
+// atomically. If the key is already taken the stored record is returned
+// instead and nothing is written.
+func (c *Client) ReserveIdempotencyKey(key string, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	data, err := rec.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	_, err = c.kvIdempotency.Create(key, data)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, nats.ErrKeyExists) {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	entry, err := c.kvIdempotency.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	var existing models.IdempotencyRecord
+	if err := existing.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey stores the final response for a reserved key.
+func (c *Client) CompleteIdempotencyKey(key string, rec *models.IdempotencyRecord) error {
+	data, err := rec.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	if _, err := c.kvIdempotency.Put(key, data); err != nil {
+		return fmt.Errorf("failed to store idempotency record: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key so the request can be retried.
+func (c *Client) ReleaseIdempotencyKey(key string) error {
+	if err := c.kvIdempotency.Delete(key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// streamJournal holds the routing-change journal, one message per
+	// models.JournalEntry on router-sync.journal.<hostname>.
+	streamJournal        = "ROUTER_SYNC_JOURNAL"
+	journalSubjectPrefix = "router-sync.journal"
+
+	// The stream is capped by count and age, dropping the oldest entries.
+	journalMaxEntries = 100000
+	journalRetention  = 30 * 24 * time.Hour
+
+	// journalMaxScan bounds how many entries one ListJournal call reads, so
+	// a filter matching little does not walk the whole stream.
+	journalMaxScan = 10000
+
+	// journalClockSkew is how far an agent's clock may run behind the NATS
+	// server's before ListJournal stops scanning too early for Since.
+	journalClockSkew = time.Minute
+)
+
+// ensureJournalStream creates the journal stream if missing.
+func ensureJournalStream(js nats.JetStreamContext) error {
+	if _, err := js.StreamInfo(streamJournal); err == nil {
+		return nil
+	} else if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("failed to get %s stream: %w", streamJournal, err)
+	}
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:      streamJournal,
+		Subjects:  []string{journalSubjectPrefix + ".>"},
+		Storage:   nats.FileStorage,
+		Retention: nats.LimitsPolicy,
+		Discard:   nats.DiscardOld,
+		MaxMsgs:   journalMaxEntries,
+		MaxAge:    journalRetention,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return fmt.Errorf("failed to create %s stream: %w", streamJournal, err)
+	}
+	return nil
+}
+
+// RecordJournal appends a routing change to the journal.
+func (c *Client) RecordJournal(entry *models.JournalEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	data, err := entry.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	if _, err := c.js.Publish(journalSubjectPrefix+"."+sanitizeKey(entry.Hostname), data); err != nil {
+		return fmt.Errorf("failed to store journal entry: %w", err)
+	}
+	return nil
+}
+
+// ListJournal returns entries matching filter, newest first. It reads the
+// stream backwards from the last entry and stops at Limit matches, at the
+// first entry older than Since or after journalMaxScan entries.
+func (c *Client) ListJournal(filter models.JournalFilter) ([]*models.JournalEntry, error) {
+	out := make([]*models.JournalEntry, 0)
+	info, err := c.js.StreamInfo(streamJournal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal stream: %w", err)
+	}
+	if info.State.Msgs == 0 {
+		return out, nil
+	}
+
+	scanned := 0
+	for seq := info.State.LastSeq; seq >= info.State.FirstSeq && seq > 0 && scanned < journalMaxScan; seq-- {
+		msg, err := c.js.GetMsg(streamJournal, seq)
+		if err != nil {
+			if errors.Is(err, nats.ErrMsgNotFound) {
+				continue // expired or deleted since StreamInfo
+			}
+			return nil, fmt.Errorf("failed to read journal entry %d: %w", seq, err)
+		}
+		scanned++
+		// Sequence order follows the server's clock, not the agents'.
+		if !filter.Since.IsZero() && msg.Time.Before(filter.Since.Add(-journalClockSkew)) {
+			break
+		}
+		var entry models.JournalEntry
+		if err := entry.FromJSON(msg.Data); err != nil {
+			logrus.Warnf("Skipping malformed journal entry %d: %v", seq, err)
+			continue
+		}
+		entry.Seq = seq
+		if !filter.Matches(&entry) {
+			continue
+		}
+		out = append(out, &entry)
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+	}
+	return out, nil
+}
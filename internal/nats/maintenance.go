@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// The maintenance switch is a single key in the core bucket.
+const maintenanceKey = "maintenance"
+
+// SetMaintenance stores the global maintenance switch.
+func (c *Client) SetMaintenance(m *models.Maintenance) error {
+	data, err := m.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance: %w", err)
+	}
+	if _, err := c.kv.Put(maintenanceKey, data); err != nil {
+		return fmt.Errorf("failed to store maintenance: %w", err)
+	}
+	logrus.Debugf("Stored maintenance state (enabled=%t)", m.Enabled)
+	return nil
+}
+
+// GetMaintenance returns the global maintenance switch. A missing key means
+// maintenance has never been enabled and yields a disabled value.
+func (c *Client) GetMaintenance() (*models.Maintenance, error) {
+	entry, err := c.kv.Get(maintenanceKey)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return &models.Maintenance{}, nil
+		}
+		return nil, fmt.Errorf("failed to get maintenance: %w", err)
+	}
+
+	var m models.Maintenance
+	if err := m.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal maintenance: %w", err)
+	}
+	return &m, nil
+}
+
+// WatchMaintenance calls callback with the current maintenance switch and
+// again on every change. A deleted key is reported as disabled.
+func (c *Client) WatchMaintenance(ctx context.Context, callback func(*models.Maintenance)) error {
+	watcher, err := c.kv.Watch(maintenanceKey)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance watcher: %w", err)
+	}
+	defer func() { _ = watcher.Stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-watcher.Updates():
+			if update == nil {
+				continue
+			}
+			if update.Operation() == nats.KeyValueDelete || update.Operation() == nats.KeyValuePurge {
+				callback(&models.Maintenance{})
+				continue
+			}
+			var m models.Maintenance
+			if err := m.FromJSON(update.Value()); err != nil {
+				logrus.Warnf("Failed to unmarshal maintenance update: %v", err)
+				continue
+			}
+			callback(&m)
+		}
+	}
+}
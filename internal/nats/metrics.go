@@ -0,0 +1,117 @@
+package nats
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// connStats tracks the health of the store connection for the metrics
+// below. It is shared by the connection handlers and the KV wrappers.
+type connStats struct {
+	disconnects atomic.Uint64
+	// lastKVSuccess is the Unix time in nanoseconds of the last KV
+	// operation the server answered; 0 until the first one.
+	lastKVSuccess atomic.Int64
+}
+
+// answered reports whether err (from a KV call) still means the server
+// replied: a missing key or a failed revision check is not a broken store.
+func answered(err error) bool {
+	if err == nil || errors.Is(err, nats.ErrKeyNotFound) || errors.Is(err, nats.ErrKeyExists) || errors.Is(err, nats.ErrNoKeysFound) {
+		return true
+	}
+	var jsErr nats.JetStreamError
+	return errors.As(err, &jsErr)
+}
+
+func (s *connStats) record(err error) {
+	if answered(err) {
+		s.lastKVSuccess.Store(time.Now().UnixNano())
+	}
+}
+
+// trackedKV records the outcome of the KV calls the client makes.
+type trackedKV struct {
+	nats.KeyValue
+	stats *connStats
+}
+
+func (t *trackedKV) Get(key string) (nats.KeyValueEntry, error) {
+	entry, err := t.KeyValue.Get(key)
+	t.stats.record(err)
+	return entry, err
+}
+
+func (t *trackedKV) Put(key string, value []byte) (uint64, error) {
+	rev, err := t.KeyValue.Put(key, value)
+	t.stats.record(err)
+	return rev, err
+}
+
+func (t *trackedKV) Create(key string, value []byte) (uint64, error) {
+	rev, err := t.KeyValue.Create(key, value)
+	t.stats.record(err)
+	return rev, err
+}
+
+func (t *trackedKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	rev, err := t.KeyValue.Update(key, value, last)
+	t.stats.record(err)
+	return rev, err
+}
+
+func (t *trackedKV) Delete(key string, opts ...nats.DeleteOpt) error {
+	err := t.KeyValue.Delete(key, opts...)
+	t.stats.record(err)
+	return err
+}
+
+func (t *trackedKV) Keys(opts ...nats.WatchOpt) ([]string, error) {
+	keys, err := t.KeyValue.Keys(opts...)
+	t.stats.record(err)
+	return keys, err
+}
+
+var (
+	natsConnectedDesc = prometheus.NewDesc("nats_connected",
+		"1 while the NATS connection is up, 0 otherwise.", nil, nil)
+	natsReconnectsDesc = prometheus.NewDesc("nats_reconnects_total",
+		"Number of times the NATS connection was re-established.", nil, nil)
+	natsDisconnectsDesc = prometheus.NewDesc("nats_disconnects_total",
+		"Number of times the NATS connection was lost.", nil, nil)
+	natsLastKVSuccessDesc = prometheus.NewDesc("nats_last_kv_success_timestamp_seconds",
+		"Unix time of the last KV operation the NATS server answered; 0 before the first.", nil, nil)
+)
+
+// Describe implements prometheus.Collector, so the client's connection
+// metrics can be registered next to the API or agent metrics.
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	ch <- natsConnectedDesc
+	ch <- natsReconnectsDesc
+	ch <- natsDisconnectsDesc
+	ch <- natsLastKVSuccessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	connected := 0.0
+	if c.Connected() {
+		connected = 1
+	}
+	var reconnects uint64
+	if c.conn != nil {
+		reconnects = c.conn.Stats().Reconnects
+	}
+	var last float64
+	if ns := c.stats.lastKVSuccess.Load(); ns > 0 {
+		last = float64(ns) / float64(time.Second)
+	}
+	ch <- prometheus.MustNewConstMetric(natsConnectedDesc, prometheus.GaugeValue, connected)
+	ch <- prometheus.MustNewConstMetric(natsReconnectsDesc, prometheus.CounterValue, float64(reconnects))
+	ch <- prometheus.MustNewConstMetric(natsDisconnectsDesc, prometheus.CounterValue, float64(c.stats.disconnects.Load()))
+	ch <- prometheus.MustNewConstMetric(natsLastKVSuccessDesc, prometheus.GaugeValue, last)
+}
@@ -0,0 +1,27 @@
+package nats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnswered(t *testing.T) {
+	assert.True(t, answered(nil))
+	assert.True(t, answered(nats.ErrKeyNotFound))
+	assert.True(t, answered(nats.ErrKeyExists))
+	assert.True(t, answered(&nats.APIError{Code: 400, ErrorCode: nats.JSErrCodeStreamWrongLastSequence}), "failed CAS is an answer")
+	assert.False(t, answered(nats.ErrTimeout))
+	assert.False(t, answered(nats.ErrConnectionClosed))
+	assert.False(t, answered(errors.New("boom")))
+}
+
+func TestConnStatsRecord(t *testing.T) {
+	var s connStats
+	s.record(nats.ErrTimeout)
+	assert.Zero(t, s.lastKVSuccess.Load())
+	s.record(nats.ErrKeyNotFound)
+	assert.NotZero(t, s.lastKVSuccess.Load())
+}
@@ -0,0 +1,86 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Tenants share the core bucket with providers and policies. Their policies
+// are keyed policies.<tenant>.<uuid> (models.NewTenantPolicyID), so a NATS
+// account can be limited to $KV.router-sync.policies.<tenant>.>.
+const tenantKeyPrefix = "tenants."
+
+// StoreTenant stores a tenant in the key-value store using revision CAS.
+func (c *Client) StoreTenant(tenant *models.Tenant) error {
+	key := tenantKeyPrefix + sanitizeKey(tenant.ID)
+
+	return c.storeWithCAS(c.kv, key, func(existing []byte) ([]byte, error) {
+		var prev *models.Tenant
+		if len(existing) > 0 {
+			var parsed models.Tenant
+			if err := parsed.FromJSON(existing); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal existing tenant: %w", err)
+			}
+			prev = &parsed
+		}
+		PrepareTenantWrite(tenant, prev, c.writerID)
+		return tenant.ToJSON()
+	})
+}
+
+// GetTenant retrieves a tenant from the key-value store
+func (c *Client) GetTenant(id string) (*models.Tenant, error) {
+	entry, err := c.kv.Get(tenantKeyPrefix + sanitizeKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	var tenant models.Tenant
+	if err := tenant.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tenant: %w", err)
+	}
+
+	return &tenant, nil
+}
+
+// ListTenants retrieves all tenants from the key-value store
+func (c *Client) ListTenants() ([]*models.Tenant, error) {
+	keys, err := c.kv.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.Tenant{}, nil
+		}
+		return nil, fmt.Errorf("failed to list tenant keys: %w", err)
+	}
+
+	tenants := []*models.Tenant{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, tenantKeyPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(key, tenantKeyPrefix)
+		tenant, err := c.GetTenant(id)
+		if err != nil {
+			logrus.Warnf("Failed to get tenant with sanitized ID %s: %v", id, err)
+			continue
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, nil
+}
+
+// DeleteTenant deletes a tenant from the key-value store. Its policies are
+// not touched; callers check that none are left first.
+func (c *Client) DeleteTenant(id string) error {
+	if err := c.kv.Delete(tenantKeyPrefix + sanitizeKey(id)); err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+
+	logrus.Debugf("Deleted tenant %s", id)
+	return nil
+}
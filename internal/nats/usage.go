@@ -0,0 +1,80 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// Provider usage records share the core bucket, one key per provider.
+const usageKeyPrefix = "usage."
+
+// StoreUsage stores a provider's usage record.
+func (c *Client) StoreUsage(usage *models.ProviderUsage) error {
+	data, err := usage.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	if _, err := c.kv.Put(usageKeyPrefix+sanitizeKey(usage.ProviderID), data); err != nil {
+		return fmt.Errorf("failed to store usage: %w", err)
+	}
+	logrus.Debugf("Stored usage for provider %s", usage.ProviderID)
+	return nil
+}
+
+// GetUsage returns a provider's usage record. A missing key yields an empty
+// record for the provider.
+func (c *Client) GetUsage(providerID string) (*models.ProviderUsage, error) {
+	entry, err := c.kv.Get(usageKeyPrefix + sanitizeKey(providerID))
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return &models.ProviderUsage{ProviderID: providerID}, nil
+		}
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+
+	var usage models.ProviderUsage
+	if err := usage.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return &usage, nil
+}
+
+// ListUsage returns every stored usage record.
+func (c *Client) ListUsage() ([]*models.ProviderUsage, error) {
+	keys, err := c.kv.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.ProviderUsage{}, nil
+		}
+		return nil, fmt.Errorf("failed to list usage keys: %w", err)
+	}
+
+	records := []*models.ProviderUsage{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, usageKeyPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(key, usageKeyPrefix)
+		usage, err := c.GetUsage(id)
+		if err != nil {
+			logrus.Warnf("Failed to get usage %s: %v", id, err)
+			continue
+		}
+		records = append(records, usage)
+	}
+	return records, nil
+}
+
+// DeleteUsage removes a provider's usage record.
+func (c *Client) DeleteUsage(providerID string) error {
+	if err := c.kv.Delete(usageKeyPrefix + sanitizeKey(providerID)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("failed to delete usage: %w", err)
+	}
+	return nil
+}
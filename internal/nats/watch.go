@@ -0,0 +1,50 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// WatchUpdate describes a KV update a watcher has handed to its callback.
+type WatchUpdate struct {
+	Watcher string // "providers" or "policies"
+	Op      nats.KeyValueOp
+	// Written is when the entry was stored, by the NATS server's clock.
+	Written time.Time
+	// Handled is how long the callback took to apply the update.
+	Handled time.Duration
+	// Replay marks the existing keys a new watcher delivers before live
+	// updates; their Written times say nothing about convergence.
+	Replay bool
+}
+
+// Lag returns the time from the write to the end of the callback, or 0 when
+// the clocks disagree so much that the write appears to be in the future.
+func (u WatchUpdate) Lag(now time.Time) time.Duration {
+	if lag := now.Sub(u.Written); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// SetWatchObserver registers fn to be called after every update
+// WatchProviders and WatchPolicies deliver. Set it before starting them.
+func (c *Client) SetWatchObserver(fn func(WatchUpdate)) {
+	c.watchObserver = fn
+}
+
+// observeWatch reports entry to the watch observer, if any; started is when
+// the callback was invoked.
+func (c *Client) observeWatch(watcher string, entry nats.KeyValueEntry, replay bool, started time.Time) {
+	if c.watchObserver == nil {
+		return
+	}
+	c.watchObserver(WatchUpdate{
+		Watcher: watcher,
+		Op:      entry.Operation(),
+		Written: entry.Created(),
+		Handled: time.Since(started),
+		Replay:  replay,
+	})
+}
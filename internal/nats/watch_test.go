@@ -0,0 +1,14 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchUpdateLag(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, 2*time.Second, WatchUpdate{Written: now.Add(-2 * time.Second)}.Lag(now))
+	assert.Zero(t, WatchUpdate{Written: now.Add(time.Second)}.Lag(now), "server clock ahead of ours")
+}
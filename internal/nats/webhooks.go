@@ -0,0 +1,78 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Webhook subscriptions share the core bucket with providers and policies.
+const webhookKeyPrefix = "webhooks."
+
+// StoreWebhook stores a webhook subscription in the key-value store
+func (c *Client) StoreWebhook(hook *models.Webhook) error {
+	data, err := hook.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+	if _, err := c.kv.Put(webhookKeyPrefix+sanitizeKey(hook.ID), data); err != nil {
+		return fmt.Errorf("failed to store webhook: %w", err)
+	}
+	logrus.Debugf("Stored webhook %s", hook.ID)
+	return nil
+}
+
+// GetWebhook retrieves a webhook subscription from the key-value store
+func (c *Client) GetWebhook(id string) (*models.Webhook, error) {
+	entry, err := c.kv.Get(webhookKeyPrefix + sanitizeKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	var hook models.Webhook
+	if err := hook.FromJSON(entry.Value()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+
+	return &hook, nil
+}
+
+// ListWebhooks retrieves all webhook subscriptions from the key-value store
+func (c *Client) ListWebhooks() ([]*models.Webhook, error) {
+	keys, err := c.kv.Keys()
+	if err != nil {
+		if strings.Contains(err.Error(), "no keys found") {
+			return []*models.Webhook{}, nil
+		}
+		return nil, fmt.Errorf("failed to list webhook keys: %w", err)
+	}
+
+	hooks := []*models.Webhook{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, webhookKeyPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(key, webhookKeyPrefix)
+		hook, err := c.GetWebhook(id)
+		if err != nil {
+			logrus.Warnf("Failed to get webhook %s: %v", id, err)
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook deletes a webhook subscription from the key-value store
+func (c *Client) DeleteWebhook(id string) error {
+	if err := c.kv.Delete(webhookKeyPrefix + sanitizeKey(id)); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	logrus.Debugf("Deleted webhook %s", id)
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package profiling serves net/http/pprof on a dedicated listener and writes
+// periodic heap and goroutine profiles to disk, for diagnosing routers in the
+// field without exposing pprof on the API or metrics ports.
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"router-sync/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dumpProfiles are the runtime profiles written on every dump tick.
+var dumpProfiles = []string{"heap", "goroutine"}
+
+// dumpTimeFormat names dump files (<profile>-<timestamp>.pb.gz) so they sort
+// in time order.
+const dumpTimeFormat = "20060102T150405"
+
+// Start brings up what cfg enables: the pprof listener on cfg.Address and
+// the dump loop into cfg.DumpDir. The block and mutex rates are applied
+// whenever pprof is served, including on the API admin listener. The
+// returned stop func shuts both down; it is a no-op when profiling is
+// disabled.
+func Start(cfg config.ProfilingConfig) (func(context.Context), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var srv *http.Server
+
+	if cfg.Address != "" || cfg.AdminListener {
+		if cfg.BlockProfileRate > 0 {
+			runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+		}
+		if cfg.MutexProfileFraction > 0 {
+			runtime.SetMutexProfileFraction(cfg.MutexProfileFraction)
+		}
+	}
+
+	if cfg.Address != "" {
+		srv = &http.Server{
+			Addr:              cfg.Address,
+			Handler:           Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			logrus.Infof("Starting pprof listener on %s", cfg.Address)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logrus.Errorf("pprof listener error: %v", err)
+			}
+		}()
+	}
+
+	if cfg.DumpDir != "" && cfg.DumpInterval > 0 {
+		if err := os.MkdirAll(cfg.DumpDir, 0o755); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create profile dump directory: %w", err)
+		}
+		go dumpLoop(ctx, cfg.DumpDir, cfg.DumpInterval, cfg.DumpKeep)
+	}
+
+	return func(shutdownCtx context.Context) {
+		cancel()
+		if srv != nil {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logrus.Errorf("Error during pprof listener shutdown: %v", err)
+			}
+		}
+	}, nil
+}
+
+// Handler serves the standard /debug/pprof/ endpoints.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+func dumpLoop(ctx context.Context, dir string, interval time.Duration, keep int) {
+	logrus.Infof("Writing heap and goroutine profiles to %s every %s", dir, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := dump(dir, now, keep); err != nil {
+				logrus.Warnf("Profile dump failed: %v", err)
+			}
+		}
+	}
+}
+
+// dump writes one file per dumpProfiles entry and prunes each kind to the
+// newest keep files (keep <= 0 keeps everything).
+func dump(dir string, now time.Time, keep int) error {
+	stamp := now.UTC().Format(dumpTimeFormat)
+	for _, name := range dumpProfiles {
+		path := filepath.Join(dir, name+"-"+stamp+".pb.gz")
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		err = rpprof.Lookup(name).WriteTo(f, 0)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s profile: %w", name, err)
+		}
+		if keep > 0 {
+			prune(dir, name+"-", keep)
+		}
+	}
+	return nil
+}
+
+func prune(dir, prefix string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".pb.gz") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > keep {
+		_ = os.Remove(filepath.Join(dir, names[0]))
+		names = names[1:]
+	}
+}
@@ -0,0 +1,48 @@
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDumpPrunes(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if err := dump(dir, start.Add(time.Duration(i)*time.Minute), 2); err != nil {
+			t.Fatalf("dump() = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{
+		"goroutine-20260101T000200.pb.gz", "goroutine-20260101T000300.pb.gz",
+		"heap-20260101T000200.pb.gz", "heap-20260101T000300.pb.gz",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("files[%d] = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
+
+func TestHandlerServesIndex(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /debug/pprof/ = %d, want 200", rec.Code)
+	}
+}
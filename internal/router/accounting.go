@@ -0,0 +1,171 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nftAcctTable is the nftables table (family inet) counting each policy's
+// traffic. Like the classification table it is replaced as a whole, which
+// restarts its counters, so it is only reloaded when the policies change.
+const nftAcctTable = "router_sync_acct"
+
+// Accounting chains: upload counts packets from a policy's clients,
+// download the packets back to them.
+const (
+	acctChainUpload   = "upload"
+	acctChainDownload = "download"
+)
+
+// compileAccounting renders the accounting table for the active policies.
+// Both chains hook forward, where addresses are the clients' own (before
+// SNAT, after de-NAT), and hold one counter rule per policy ordered by ip
+// rule priority; accept ends the chain at the first match, so a packet is
+// counted for the policy that routes it, not for every enclosing prefix.
+func compileAccounting(policies []*models.RoutingPolicy, now time.Time) string {
+	type entry struct {
+		policy   *models.RoutingPolicy
+		priority int
+	}
+	var entries []entry
+	for _, p := range policies {
+		if !p.Active(now) {
+			continue
+		}
+		srcNet, err := p.SourceNet()
+		if err != nil {
+			continue
+		}
+		if _, ok := nftMatch(p); !ok {
+			continue
+		}
+		entries = append(entries, entry{policy: p, priority: p.RulePriority(srcNet)})
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].priority != entries[j].priority {
+			return entries[i].priority < entries[j].priority
+		}
+		return entries[i].policy.ID < entries[j].policy.ID
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s {\n", nftAcctTable)
+	for _, chain := range []struct {
+		name  string
+		reply bool
+	}{{acctChainUpload, false}, {acctChainDownload, true}} {
+		fmt.Fprintf(&b, "\tchain %s {\n", chain.name)
+		b.WriteString("\t\ttype filter hook forward priority filter; policy accept;\n")
+		for _, e := range entries {
+			match, _ := nftMatchDir(e.policy, chain.reply)
+			fmt.Fprintf(&b, "\t\t%s counter accept comment %q\n", match, "policy "+e.policy.ID)
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// SyncAccounting loads the accounting table for policies; see
+// syncAccountingLocked.
+func (m *Manager) SyncAccounting(policies []*models.RoutingPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncAccountingLocked(policies)
+}
+
+// syncAccountingLocked replaces the accounting table when the policies it
+// counts changed. It needs Options.NFTables; without it there is nothing
+// to count with.
+func (m *Manager) syncAccountingLocked(policies []*models.RoutingPolicy) error {
+	if !m.opts.NFTables || m.dryRun() {
+		return nil
+	}
+	ruleset := compileAccounting(policies, time.Now())
+	if m.acctLoaded && ruleset == m.acctRuleset {
+		return nil
+	}
+	script := fmt.Sprintf("table inet %s\ndelete table inet %s\n%s", nftAcctTable, nftAcctTable, ruleset)
+	cmd := exec.Command(nftCommand, "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load nftables accounting: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	m.acctLoaded, m.acctRuleset = true, ruleset
+	logrus.Debugf("Loaded nftables accounting (%d policies)", strings.Count(ruleset, "counter accept")/2)
+	return nil
+}
+
+// PolicyTraffic reads the accounting counters, keyed by policy ID. It
+// returns nil without Options.NFTables or before the table is loaded.
+func (m *Manager) PolicyTraffic() (map[string]models.PolicyTraffic, error) {
+	m.mu.RLock()
+	loaded := m.opts.NFTables && m.acctLoaded && m.acctRuleset != ""
+	m.mu.RUnlock()
+	if !loaded {
+		return nil, nil
+	}
+	output, err := exec.Command(nftCommand, "-j", "list", "table", "inet", nftAcctTable).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nftables accounting: %w", err)
+	}
+	return parseAccounting(output)
+}
+
+// parseAccounting reads the counters out of `nft -j list table` output.
+func parseAccounting(data []byte) (map[string]models.PolicyTraffic, error) {
+	var doc struct {
+		Nftables []struct {
+			Rule *struct {
+				Chain   string `json:"chain"`
+				Comment string `json:"comment"`
+				Expr    []struct {
+					Counter *struct {
+						Packets uint64 `json:"packets"`
+						Bytes   uint64 `json:"bytes"`
+					} `json:"counter"`
+				} `json:"expr"`
+			} `json:"rule"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse nftables accounting: %w", err)
+	}
+	traffic := make(map[string]models.PolicyTraffic)
+	for _, item := range doc.Nftables {
+		if item.Rule == nil {
+			continue
+		}
+		id, ok := strings.CutPrefix(item.Rule.Comment, "policy ")
+		if !ok {
+			continue
+		}
+		for _, expr := range item.Rule.Expr {
+			if expr.Counter == nil {
+				continue
+			}
+			t := traffic[id]
+			switch item.Rule.Chain {
+			case acctChainUpload:
+				t.TxBytes += expr.Counter.Bytes
+				t.TxPackets += expr.Counter.Packets
+			case acctChainDownload:
+				t.RxBytes += expr.Counter.Bytes
+				t.RxPackets += expr.Counter.Packets
+			}
+			traffic[id] = t
+		}
+	}
+	return traffic, nil
+}
@@ -0,0 +1,57 @@
+package router
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileAccounting(t *testing.T) {
+	policies := []*models.RoutingPolicy{
+		{ID: "lan", SourceIP: "10.0.0.0/24", ProviderID: "isp1", Enabled: true},
+		{ID: "tv", SourceIP: "10.0.0.5", ProviderID: "isp2", Enabled: true},
+		{ID: "web", SourceIP: "10.0.0.6", Protocol: "tcp", DestinationPorts: "443", ProviderID: "isp2", Enabled: true},
+		{ID: "off", SourceIP: "10.0.0.7", ProviderID: "isp1", Enabled: false},
+	}
+
+	ruleset := compileAccounting(policies, time.Now())
+
+	assert.Contains(t, ruleset, "table inet router_sync_acct {")
+	assert.Equal(t, 2, strings.Count(ruleset, "type filter hook forward priority filter; policy accept;"))
+	assert.Contains(t, ruleset, `ip saddr 10.0.0.5/32 counter accept comment "policy tv"`)
+	assert.Contains(t, ruleset, `ip daddr 10.0.0.5/32 counter accept comment "policy tv"`)
+	assert.Contains(t, ruleset, `ip saddr 10.0.0.6/32 meta l4proto tcp tcp dport 443 counter accept comment "policy web"`)
+	assert.Contains(t, ruleset, `ip daddr 10.0.0.6/32 meta l4proto tcp tcp sport 443 counter accept comment "policy web"`)
+	assert.NotContains(t, ruleset, "policy off")
+
+	// The /32 is counted before the /24 that contains it
+	upload := ruleset[:strings.Index(ruleset, "chain download")]
+	assert.Less(t, strings.Index(upload, "policy tv"), strings.Index(upload, "policy lan"))
+
+	assert.Empty(t, compileAccounting(nil, time.Now()))
+}
+
+func TestParseAccounting(t *testing.T) {
+	output := `{"nftables": [{"metainfo": {"version": "1.0.6", "json_schema_version": 1}},
+	{"table": {"family": "inet", "name": "router_sync_acct", "handle": 7}},
+	{"chain": {"family": "inet", "table": "router_sync_acct", "name": "upload", "handle": 1}},
+	{"rule": {"family": "inet", "table": "router_sync_acct", "chain": "upload", "handle": 3, "comment": "policy tv",
+	  "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "ip", "field": "saddr"}}, "right": "10.0.0.5"}},
+	           {"counter": {"packets": 12, "bytes": 3400}}, {"accept": null}]}},
+	{"rule": {"family": "inet", "table": "router_sync_acct", "chain": "download", "handle": 5, "comment": "policy tv",
+	  "expr": [{"counter": {"packets": 20, "bytes": 28000}}, {"accept": null}]}}]}`
+
+	traffic, err := parseAccounting([]byte(output))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.PolicyTraffic{
+		"tv": {TxBytes: 3400, TxPackets: 12, RxBytes: 28000, RxPackets: 20},
+	}, traffic)
+
+	_, err = parseAccounting([]byte("not json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var deletedCountRe = regexp.MustCompile(`(\d+) flow entries have been deleted`)
+
+// ErrConntrackDisabled is returned by ListConntrack and FlushConntrack when
+// the manager was created with Options.DisableConntrack.
+var ErrConntrackDisabled = errors.New("conntrack operations are disabled on this router (router.disable_conntrack)")
+
+// ListConntrack returns the tracked flows whose original source is in srcNet.
+func (m *Manager) ListConntrack(srcNet *net.IPNet) ([]models.ConntrackFlow, error) {
+	if m.opts.DisableConntrack {
+		return nil, ErrConntrackDisabled
+	}
+	cmd := exec.Command("conntrack", "-L", "--src", srcNet.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("conntrack -L failed: %w", err)
+	}
+
+	flows := make([]models.ConntrackFlow, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		if flow, ok := parseConntrackLine(line); ok {
+			flows = append(flows, flow)
+		}
+	}
+	return flows, nil
+}
+
+// FlushConntrack deletes tracked flows for srcNet and returns how many were removed.
+func (m *Manager) FlushConntrack(srcNet *net.IPNet) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.flushConntrack(srcNet, models.JournalReasonRequest)
+}
+
+// flushConntrack is FlushConntrack journaling a non-empty flush with reason.
+func (m *Manager) flushConntrack(srcNet *net.IPNet, reason string) (int, error) {
+	if m.opts.DisableConntrack {
+		return 0, ErrConntrackDisabled
+	}
+	if m.dryRun() {
+		m.plan(models.JournalEntry{Action: models.JournalConntrackFlush, Reason: reason, Source: srcNet.String()})
+		return 0, nil
+	}
+	cmd := exec.Command("conntrack", "-D", "--src", srcNet.String())
+	output, err := cmd.CombinedOutput()
+	deleted := parseDeletedCount(string(output))
+	if err != nil && deleted == 0 {
+		// conntrack exits non-zero when nothing matched; treat that as an empty flush.
+		if strings.Contains(string(output), "flow entries have been deleted") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("conntrack -D failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	m.count(func(c *Counters) { c.ConntrackFlushes++; c.ConntrackEntriesFlushed += deleted })
+	if deleted > 0 {
+		m.record(models.JournalEntry{Action: models.JournalConntrackFlush, Reason: reason, Source: srcNet.String(), Flows: deleted}, nil)
+	}
+	// Periodic sync flushes disabled/removed sources every interval; keep the
+	// no-op case out of INFO logs.
+	if deleted > 0 {
+		logrus.Infof("Flushed %d conntrack entries for source %s", deleted, srcNet.String())
+	}
+	return deleted, nil
+}
+
+func parseDeletedCount(output string) int {
+	match := deletedCountRe.FindStringSubmatch(output)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+// parseConntrackLine parses one `conntrack -L` line, e.g.:
+//
+//	"tcp      6 431999 ESTABLISHED src=192.168.2.25 dst=1.1.1.1 sport=50000 dport=443 src=1.1.1.1 dst=203.0.113.5 sport=443 dport=50000 [ASSURED] mark=0 use=1"
+//	"udp      17 29 src=192.168.2.25 dst=8.8.8.8 sport=5353 dport=53 src=8.8.8.8 dst=203.0.113.5 sport=53 dport=5353 mark=0 use=1"
+//
+// The first src/dst/sport/dport group is the original direction, the second the reply.
+func parseConntrackLine(line string) (models.ConntrackFlow, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return models.ConntrackFlow{}, false
+	}
+
+	flow := models.ConntrackFlow{Protocol: fields[0]}
+	if ttl, err := strconv.Atoi(fields[2]); err == nil {
+		flow.TTL = ttl
+	}
+
+	seen := make(map[string]int)
+	for _, f := range fields[3:] {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok {
+			if flow.State == "" && !strings.HasPrefix(f, "[") && strings.ToUpper(f) == f {
+				flow.State = f
+			}
+			continue
+		}
+		reply := seen[key] > 0
+		seen[key]++
+		switch key {
+		case "src":
+			if reply {
+				flow.ReplySrc = value
+			} else {
+				flow.Src = value
+			}
+		case "dst":
+			if reply {
+				flow.ReplyDst = value
+			} else {
+				flow.Dst = value
+			}
+		case "sport":
+			if !reply {
+				flow.SrcPort, _ = strconv.Atoi(value)
+			}
+		case "dport":
+			if !reply {
+				flow.DstPort, _ = strconv.Atoi(value)
+			}
+		case "mark":
+			flow.Mark, _ = strconv.Atoi(value)
+		}
+	}
+
+	if flow.Src == "" || flow.Dst == "" {
+		return models.ConntrackFlow{}, false
+	}
+	return flow, true
+}
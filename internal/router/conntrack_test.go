@@ -0,0 +1,72 @@
+package router
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConntrackLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want models.ConntrackFlow
+		ok   bool
+	}{
+		{
+			name: "tcp established",
+			line: "tcp      6 431999 ESTABLISHED src=192.168.2.25 dst=1.1.1.1 sport=50000 dport=443 src=1.1.1.1 dst=203.0.113.5 sport=443 dport=50000 [ASSURED] mark=0 use=1",
+			want: models.ConntrackFlow{
+				Protocol: "tcp", State: "ESTABLISHED", TTL: 431999,
+				Src: "192.168.2.25", Dst: "1.1.1.1", SrcPort: 50000, DstPort: 443,
+				ReplySrc: "1.1.1.1", ReplyDst: "203.0.113.5",
+			},
+			ok: true,
+		},
+		{
+			name: "udp without state",
+			line: "udp      17 29 src=192.168.2.25 dst=8.8.8.8 sport=5353 dport=53 src=8.8.8.8 dst=100.64.0.2 sport=53 dport=5353 mark=7 use=1",
+			want: models.ConntrackFlow{
+				Protocol: "udp", TTL: 29,
+				Src: "192.168.2.25", Dst: "8.8.8.8", SrcPort: 5353, DstPort: 53,
+				ReplySrc: "8.8.8.8", ReplyDst: "100.64.0.2", Mark: 7,
+			},
+			ok: true,
+		},
+		{
+			name: "summary line",
+			line: "conntrack v1.4.6 (conntrack-tools): 2 flow entries have been shown.",
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseConntrackLine(tt.line)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseDeletedCount(t *testing.T) {
+	assert.Equal(t, 3, parseDeletedCount("conntrack v1.4.6 (conntrack-tools): 3 flow entries have been deleted.\n"))
+	assert.Equal(t, 0, parseDeletedCount("conntrack v1.4.6 (conntrack-tools): 0 flow entries have been deleted.\n"))
+	assert.Equal(t, 0, parseDeletedCount(""))
+}
+
+func TestConntrackDisabled(t *testing.T) {
+	m, err := NewManager("r1", Options{DisableConntrack: true})
+	assert.NoError(t, err)
+	srcNet, _ := models.ParseSource("192.168.2.25")
+
+	_, err = m.ListConntrack(srcNet)
+	assert.ErrorIs(t, err, ErrConntrackDisabled)
+	_, err = m.FlushConntrack(srcNet)
+	assert.ErrorIs(t, err, ErrConntrackDisabled)
+	assert.NoError(t, m.clearConntrack(srcNet))
+}
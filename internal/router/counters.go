@@ -0,0 +1,71 @@
+package router
+
+// Counters tallies the kernel changes a Manager made since the last
+// TakeCounters call, or during one Sync; the agent turns the former into
+// Prometheus counters.
+type Counters struct {
+	// RulesAdded and RulesRemoved count ip rules for policies.
+	RulesAdded   int
+	RulesRemoved int
+	// RuleFailures counts ip rule adds and deletes that failed.
+	RuleFailures int
+	// StaleRulesRemoved counts rules removed because no policy wants them
+	// any more (also in RulesRemoved).
+	StaleRulesRemoved int
+	// RulesSkipped counts policies in effect that a full sync set up no
+	// rule for: their provider is unknown, or down with none to fail
+	// over to.
+	RulesSkipped int
+	// RoutesAdded and RoutesRemoved count provider table routes;
+	// RouteFailures the adds and deletes that failed.
+	RoutesAdded   int
+	RoutesRemoved int
+	RouteFailures int
+	// ConntrackFlushes counts conntrack flushes, automatic or requested;
+	// ConntrackEntriesFlushed the flows they deleted.
+	ConntrackFlushes        int
+	ConntrackEntriesFlushed int
+}
+
+// TakeCounters returns the counters and resets them.
+func (m *Manager) TakeCounters() Counters {
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+	c := m.counters.sub(m.taken)
+	m.taken = m.counters
+	return c
+}
+
+// snapshotCounters returns every change counted so far; the difference of
+// two snapshots is what was counted in between.
+func (m *Manager) snapshotCounters() Counters {
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+	return m.counters
+}
+
+func (c Counters) sub(o Counters) Counters {
+	return Counters{
+		RulesAdded:              c.RulesAdded - o.RulesAdded,
+		RulesRemoved:            c.RulesRemoved - o.RulesRemoved,
+		RuleFailures:            c.RuleFailures - o.RuleFailures,
+		StaleRulesRemoved:       c.StaleRulesRemoved - o.StaleRulesRemoved,
+		RulesSkipped:            c.RulesSkipped - o.RulesSkipped,
+		RoutesAdded:             c.RoutesAdded - o.RoutesAdded,
+		RoutesRemoved:           c.RoutesRemoved - o.RoutesRemoved,
+		RouteFailures:           c.RouteFailures - o.RouteFailures,
+		ConntrackFlushes:        c.ConntrackFlushes - o.ConntrackFlushes,
+		ConntrackEntriesFlushed: c.ConntrackEntriesFlushed - o.ConntrackEntriesFlushed,
+	}
+}
+
+// count applies f to the counters, which m.mu must be held for so Sync can
+// tell its own changes apart; a dry run changes nothing to count.
+func (m *Manager) count(f func(c *Counters)) {
+	if m.dryRun() {
+		return
+	}
+	m.countersMu.Lock()
+	defer m.countersMu.Unlock()
+	f(&m.counters)
+}
@@ -0,0 +1,33 @@
+package router
+
+import "testing"
+
+func TestTakeCountersResets(t *testing.T) {
+	m, _ := NewManager("r1", Options{})
+	m.count(func(c *Counters) { c.RulesAdded++; c.StaleRulesRemoved += 2 })
+	m.count(func(c *Counters) { c.RulesAdded++ })
+
+	got := m.TakeCounters()
+	if got.RulesAdded != 2 || got.StaleRulesRemoved != 2 {
+		t.Errorf("TakeCounters() = %+v, want 2 added and 2 stale", got)
+	}
+	if got := m.TakeCounters(); got != (Counters{}) {
+		t.Errorf("second TakeCounters() = %+v, want zero", got)
+	}
+}
+
+func TestSnapshotCountersSurviveTake(t *testing.T) {
+	m, _ := NewManager("r1", Options{})
+	m.count(func(c *Counters) { c.RuleFailures++ })
+	before := m.snapshotCounters()
+	m.count(func(c *Counters) { c.RulesAdded++ })
+	m.TakeCounters()
+	m.count(func(c *Counters) { c.RoutesAdded++ })
+
+	if got := m.snapshotCounters().sub(before); got != (Counters{RulesAdded: 1, RoutesAdded: 1}) {
+		t.Errorf("counted since snapshot = %+v, want 1 rule and 1 route added", got)
+	}
+	if got := m.TakeCounters(); got != (Counters{RoutesAdded: 1}) {
+		t.Errorf("TakeCounters() = %+v, want 1 route added", got)
+	}
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dryRun reports whether kernel changes are only planned: always with
+// Options.DryRun, and for the duration of a Plan call. m.mu must be held.
+func (m *Manager) dryRun() bool {
+	return m.opts.DryRun || m.planned != nil
+}
+
+// Plan returns the kernel changes a full sync of providers and policies
+// (SyncProviders, then SyncPolicies) would make, in order and as journal
+// entries, without making any of them. The nftables tables are not reloaded
+// and conntrack is not flushed; the flushes a sync would run are listed. The
+// returned error is the one the sync would report, such as a provider
+// whose interface is missing.
+func (m *Manager) Plan(providers []*models.InternetProvider, policies []*models.RoutingPolicy, failover map[string]string) ([]models.JournalEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.planned = []models.JournalEntry{}
+	defer func() { m.planned = nil }()
+
+	providersErr := m.syncProvidersLocked(providers)
+	policiesErr := m.syncPoliciesLocked(policies, providers, failover)
+	planned := m.planned
+	if providersErr != nil {
+		return planned, providersErr
+	}
+	return planned, policiesErr
+}
+
+// plan takes the place of the journal in a dry run: it logs e as a change
+// that was not made and adds it to the changes Plan returns.
+func (m *Manager) plan(e models.JournalEntry) {
+	e.Timestamp = time.Now().UTC()
+	e.Hostname = m.hostname
+	logrus.Infof("Dry run: would %s %s", e.Action, describeChange(e))
+	if m.planned != nil {
+		m.planned = append(m.planned, e)
+	}
+}
+
+// describeChange summarizes a journal entry for the dry run log, e.g.
+// "priority 2000 from 192.168.2.25/32 table 100 (policy)".
+func describeChange(e models.JournalEntry) string {
+	var parts []string
+	if e.Priority != 0 {
+		parts = append(parts, fmt.Sprintf("priority %d", e.Priority))
+	}
+	if e.Source != "" {
+		parts = append(parts, "from "+e.Source)
+	}
+	if e.Destination != "" {
+		parts = append(parts, "to "+e.Destination)
+	}
+	if e.FwMark != 0 {
+		parts = append(parts, fmt.Sprintf("fwmark %#x", e.FwMark))
+	}
+	if e.RuleAction != "" {
+		parts = append(parts, e.RuleAction)
+	}
+	if e.Route != "" {
+		parts = append(parts, e.Route)
+	}
+	if e.Table != 0 {
+		parts = append(parts, fmt.Sprintf("table %d", e.Table))
+	}
+	if e.Reason != "" {
+		parts = append(parts, "("+e.Reason+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// The kernel changes go through these, so that a dry run skips them; the
+// callers journal each change, which a dry run turns into the plan.
+
+func (m *Manager) addRule(r kernelRule) error {
+	if m.dryRun() {
+		return nil
+	}
+	return addRule(r)
+}
+
+func (m *Manager) delRule(r kernelRule) error {
+	if m.dryRun() {
+		return nil
+	}
+	return delRule(r)
+}
+
+func (m *Manager) replaceRoute(r kernelRoute) error {
+	if m.dryRun() {
+		return nil
+	}
+	return replaceRoute(r)
+}
+
+func (m *Manager) delRoute(r kernelRoute) error {
+	if m.dryRun() {
+		return nil
+	}
+	return delRoute(r)
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeChange(t *testing.T) {
+	assert.Equal(t, "priority 2008 from 192.168.2.0/24 to 203.0.113.0/24 table 100 (policy)", describeChange(models.JournalEntry{
+		Action: models.JournalRuleAdd, Reason: models.JournalReasonPolicy,
+		Priority: 2008, Table: 100, Source: "192.168.2.0/24", Destination: "203.0.113.0/24",
+	}))
+	assert.Equal(t, "priority 2008 from all fwmark 0x64 table 100 (classification)", describeChange(models.JournalEntry{
+		Priority: 2008, Table: 100, Source: "all", FwMark: 0x64, Reason: models.JournalReasonClassification,
+	}))
+	assert.Equal(t, "default via 192.0.2.1 dev 3 onlink table 100 (provider_sync)", describeChange(models.JournalEntry{
+		Table: 100, Route: "default via 192.0.2.1 dev 3 onlink", Reason: models.JournalReasonProviderSync,
+	}))
+}
+
+func TestDryRunPlansChanges(t *testing.T) {
+	m := &Manager{hostname: "router1", opts: Options{DryRun: true}}
+	var journaled []models.JournalEntry
+	m.SetJournal(func(e models.JournalEntry) { journaled = append(journaled, e) })
+	m.planned = []models.JournalEntry{}
+
+	src := mustNet(t, "192.168.2.0/24")
+	// Adding the rule also flushes the source's flows
+	require.NoError(t, m.addRoutingRule(src, nil, 100, 2008))
+	require.NoError(t, m.deleteRoute(kernelRoute{Table: 100}, models.JournalReasonStale))
+
+	assert.Empty(t, journaled, "a dry run journals nothing")
+	assert.Equal(t, Counters{}, m.TakeCounters(), "a dry run counts nothing")
+	require.Len(t, m.planned, 3)
+	assert.Equal(t, models.JournalRuleAdd, m.planned[0].Action)
+	assert.Equal(t, "router1", m.planned[0].Hostname)
+	assert.Equal(t, 2008, m.planned[0].Priority)
+	assert.Equal(t, models.JournalConntrackFlush, m.planned[1].Action)
+	assert.Equal(t, "192.168.2.0/24", m.planned[1].Source)
+	assert.Equal(t, models.JournalRouteDelete, m.planned[2].Action)
+}
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"time"
+
+	"router-sync/internal/models"
+)
+
+// SetJournal makes the manager report every kernel change it makes, or tries
+// to make, to fn (see models.JournalEntry). fn runs with the manager's lock
+// held, so it must not block or call back into the manager. Call it before
+// the manager is used.
+func (m *Manager) SetJournal(fn func(models.JournalEntry)) {
+	m.journal = fn
+}
+
+// record stamps e with the time, hostname and sync ID, and err if any, and
+// passes it to the journal; in a dry run, to the plan instead.
+func (m *Manager) record(e models.JournalEntry, err error) {
+	e.SyncID = m.syncID
+	if m.dryRun() {
+		m.plan(e)
+		return
+	}
+	if m.journal == nil {
+		return
+	}
+	e.Timestamp = time.Now().UTC()
+	e.Hostname = m.hostname
+	if err != nil {
+		e.Error = err.Error()
+	}
+	m.journal(e)
+}
+
+// recordRule journals an ip rule add or delete for the from/to selectors.
+func (m *Manager) recordRule(action, reason string, priority, table int, from, to string, err error) {
+	m.record(models.JournalEntry{
+		Action:      action,
+		Reason:      reason,
+		Priority:    priority,
+		Table:       table,
+		Source:      from,
+		Destination: to,
+	}, err)
+}
+
+// recordRoute journals a route add or delete of r.
+func (m *Manager) recordRoute(action, reason string, r kernelRoute, err error) {
+	m.record(models.JournalEntry{
+		Action: action,
+		Reason: reason,
+		Table:  r.Table,
+		Route:  r.String(),
+	}, err)
+}
+
+// recordKernelRule journals an ip rule add or delete of r.
+func (m *Manager) recordKernelRule(action, reason string, r kernelRule, err error) {
+	m.record(models.JournalEntry{
+		Action:      action,
+		Reason:      reason,
+		Priority:    r.Priority,
+		Table:       r.Table,
+		Source:      r.from(),
+		Destination: netString(r.Dst),
+		FwMark:      r.Mark,
+		RuleAction:  r.Action,
+	}, err)
+}
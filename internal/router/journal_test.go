@@ -0,0 +1,50 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordKernelRule(t *testing.T) {
+	m := &Manager{hostname: "router1"}
+	var got []models.JournalEntry
+	m.SetJournal(func(e models.JournalEntry) { got = append(got, e) })
+
+	src, dst := mustNet(t, "192.168.2.0/24"), mustNet(t, "203.0.113.0/24")
+	m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonStale,
+		lookupRule(2008, 100, src, dst), nil)
+	m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonStrict,
+		blackholeRule(2008, src, nil), errors.New("no such file or directory"))
+	m.syncID = "5f2b8c1d9e0a4b7c" // as set by Sync
+	m.recordKernelRule(models.JournalRuleAdd, models.JournalReasonClassification,
+		kernelRule{Priority: 2008, Mark: 0x64, Table: 100}, nil)
+
+	require.Len(t, got, 3)
+	assert.Equal(t, "router1", got[0].Hostname)
+	assert.False(t, got[0].Timestamp.IsZero())
+	assert.Equal(t, 2008, got[0].Priority)
+	assert.Equal(t, 100, got[0].Table)
+	assert.Equal(t, "192.168.2.0/24", got[0].Source)
+	assert.Equal(t, "203.0.113.0/24", got[0].Destination)
+	assert.Empty(t, got[0].Error)
+	assert.Empty(t, got[0].SyncID)
+
+	assert.Equal(t, models.RuleActionBlackhole, got[1].RuleAction)
+	assert.Equal(t, 0, got[1].Table)
+	assert.Equal(t, "no such file or directory", got[1].Error)
+
+	assert.Equal(t, 0x64, got[2].FwMark)
+	assert.Equal(t, "all", got[2].Source)
+	assert.Equal(t, "5f2b8c1d9e0a4b7c", got[2].SyncID)
+}
+
+func TestRecordWithoutJournal(t *testing.T) {
+	m := &Manager{hostname: "router1"}
+	// Must not panic without a journal.
+	m.recordRule(models.JournalRuleAdd, models.JournalReasonPolicy, 2008, 100, "192.168.2.0/24", "", nil)
+}
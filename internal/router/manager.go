@@ -3,11 +3,12 @@ package router
 import (
 	"fmt"
 	"net"
-	"os/exec"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"router-sync/internal/logging"
 	"router-sync/internal/models"
 
 	"github.com/sirupsen/logrus"
@@ -19,12 +20,72 @@ import (
 type Manager struct {
 	mu       sync.RWMutex
 	hostname string
+	opts     Options
+
+	// nftables classification state, see nftables.go. nftSetSources maps
+	// each source in a source set to its table, as last loaded.
+	nftLoaded     bool
+	nftRuleset    string
+	nftWarned     map[string]bool
+	nftSetSources map[string]int
+
+	// nftables accounting state, see accounting.go.
+	acctLoaded  bool
+	acctRuleset string
+
+	// counters only grows; taken is its value at the last TakeCounters.
+	countersMu sync.Mutex
+	counters   Counters
+	taken      Counters
+
+	// journal receives every kernel change, see SetJournal.
+	journal func(models.JournalEntry)
+
+	// planned collects the changes of a dry run while Plan runs, see
+	// dryrun.go.
+	planned []models.JournalEntry
+
+	// syncID stamps the journal entries of the full sync Sync runs.
+	syncID string
+}
+
+// Options tune a Manager; the zero value keeps the default behaviour.
+//
+// DisableConntrack stops the manager from touching conntrack at all: rule
+// changes no longer flush the affected flows (existing connections keep their
+// old path until they end) and ListConntrack / FlushConntrack fail with
+// ErrConntrackDisabled. For hosts without the conntrack tool or module.
+//
+// NFTables enables the nftables classification of policies that match on
+// protocol or ports (features.nftables); without it those policies are not
+// applied.
+//
+// SourceSets (router.source_sets, needs NFTables) moves plain source policies
+// into nftables sets in the classification table, routed by the same mark
+// rules; see RoutingPolicy.InSourceSet.
+//
+// DryRun (router.dry_run) never changes the kernel: every rule and route
+// change is logged as what it would have been instead, see Plan.
+type Options struct {
+	DisableConntrack bool
+	NFTables         bool
+	SourceSets       bool
+	DryRun           bool
 }
 
 // NewManager creates a new router manager pinned to the given hostname so it can
 // resolve provider.Interfaces[hostname] consistently.
-func NewManager(hostname string) (*Manager, error) {
-	return &Manager{hostname: hostname}, nil
+func NewManager(hostname string, opts Options) (*Manager, error) {
+	if opts.DisableConntrack {
+		logrus.Info("Conntrack operations disabled (router.disable_conntrack)")
+	}
+	if opts.SourceSets {
+		logrus.Info("Plain source policies are routed through nftables sets (router.source_sets)")
+	}
+	if opts.DryRun {
+		logrus.Warn("Dry run: the kernel routing state is never changed, only logged (router.dry_run)")
+	}
+	return &Manager{hostname: hostname, opts: opts, nftWarned: make(map[string]bool)}, nil
 }
 
 // Hostname returns the hostname this manager is bound to.
@@ -42,245 +103,292 @@ func (m *Manager) SetupProvider(provider *models.InternetProvider) error {
 }
 
 // setupProviderLocked performs the provider setup assuming m.mu is already held.
+// It reconciles the default routes in the provider's table: a missing one is
+// added, a default route via another gateway or interface is removed. Other
+// routes in the table (e.g. the uplink subnet added by the host networking)
+// are left alone. A provider without an interface here wants no routes.
 func (m *Manager) setupProviderLocked(provider *models.InternetProvider) error {
 	iface := provider.InterfaceForHost(m.hostname)
-	logrus.Infof("Setting up provider %s on interface %s with gateway %s",
-		provider.Name, iface, provider.Gateway)
-
-	// Get the network interface
-	// link, err := netlink.LinkByName(provider.Interface)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to get interface %s: %w", provider.Interface, err)
-	// }
-
-	// Parse gateway IP
-	// gwIP := net.ParseIP(provider.Gateway)
-	// if gwIP == nil {
-	// 	return fmt.Errorf("invalid gateway IP: %s", provider.Gateway)
-	// }
-
-	// Add default route to the routing table
-	// route := &netlink.Route{
-	// 	LinkIndex: link.Attrs().Index,
-	// 	Gw:        gwIP,
-	// 	Table:     provider.TableID,
-	// 	Priority:  100,
-	// }
-
-	// Remove existing route if it exists
-	// netlink.RouteDel(route)
-
-	// Add the new route
-	// if err := netlink.RouteAdd(route); err != nil {
-	// 	return fmt.Errorf("failed to add route for provider %s: %w", provider.Name, err)
-	// }
-
-	logrus.Infof("Successfully set up provider %s (route installation commented out)", provider.Name)
+	if iface == "" {
+		logging.Provider(provider.ID).Debugf("Provider %s has no interface on %s, not setting up routes", provider.Name, m.hostname)
+		return nil
+	}
+	logging.Provider(provider.ID).Debugf("Setting up provider %s on interface %s with gateways %v",
+		provider.Name, iface, provider.Gateways())
+
+	link, err := linkIndex(iface)
+	if err != nil {
+		return err
+	}
+	linkV6 := link
+	if ifaceV6 := provider.InterfaceV6ForHost(m.hostname); ifaceV6 != iface {
+		if linkV6, err = linkIndex(ifaceV6); err != nil {
+			return err
+		}
+	}
+	want, err := providerRoutes(provider, link, linkV6)
+	if err != nil {
+		return err
+	}
+	have, err := listRoutes(provider.TableID)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, r := range have {
+		if !r.isDefault() || containsRoute(want, r) {
+			continue
+		}
+		logging.Provider(provider.ID).Infof("Removing stale route %s from table %d", r, r.Table)
+		if err := m.deleteRoute(r, models.JournalReasonProviderSync); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	for _, r := range want {
+		if containsRoute(have, r) {
+			continue
+		}
+		logging.Provider(provider.ID).Infof("Adding route %s to table %d", r, r.Table)
+		err := m.replaceRoute(r)
+		m.recordRoute(models.JournalRouteAdd, models.JournalReasonProviderSync, r, err)
+		if err != nil {
+			m.count(func(c *Counters) { c.RouteFailures++ })
+			failed = append(failed, fmt.Sprintf("failed to add route %s to table %d: %v", r, r.Table, err))
+			continue
+		}
+		m.count(func(c *Counters) { c.RoutesAdded++ })
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+
+	logging.Provider(provider.ID).Debugf("Successfully set up provider %s", provider.Name)
 	return nil
 }
 
-// RemoveProvider removes routing for an internet provider
+// RemoveProvider removes the default routes from the provider's table, on
+// the routers it has an interface on.
 func (m *Manager) RemoveProvider(provider *models.InternetProvider) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	logrus.Infof("Removing provider %s", provider.Name)
-
-	// Get the network interface
-	// link, err := netlink.LinkByName(provider.Interface)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to get interface %s: %w", provider.Interface, err)
-	// }
-
-	// Parse gateway IP
-	// gwIP := net.ParseIP(provider.Gateway)
-	// if gwIP == nil {
-	// 	return fmt.Errorf("invalid gateway IP: %s", provider.Gateway)
-	// }
-
-	// Remove the route
-	// route := &netlink.Route{
-	// 	LinkIndex: link.Attrs().Index,
-	// 	Gw:        gwIP,
-	// 	Table:     provider.TableID,
-	// }
+	if provider.InterfaceForHost(m.hostname) == "" {
+		return nil
+	}
+	logging.Provider(provider.ID).Infof("Removing provider %s", provider.Name)
 
-	// if err := netlink.RouteDel(route); err != nil {
-	// 	logrus.Warnf("Failed to remove route for provider %s: %v", provider.Name, err)
-	// }
+	have, err := listRoutes(provider.TableID)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, r := range have {
+		if !r.isDefault() {
+			continue
+		}
+		if err := m.deleteRoute(r, models.JournalReasonProviderDeleted); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
 
-	logrus.Infof("Successfully removed provider %s (route removal commented out)", provider.Name)
+	logging.Provider(provider.ID).Infof("Successfully removed provider %s", provider.Name)
 	return nil
 }
 
 // SetupPolicy sets up a routing policy based on source IP
 func (m *Manager) SetupPolicy(policy *models.RoutingPolicy, provider *models.InternetProvider) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setupPolicyLocked(policy, provider)
+}
+
+// setupPolicyLocked is SetupPolicy for callers holding m.mu (SyncPolicies).
+func (m *Manager) setupPolicyLocked(policy *models.RoutingPolicy, provider *models.InternetProvider) error {
 	logrus.Debugf("=== SetupPolicy called for policy: %s ===", policy.Name)
 
-	// Note: This function is called from SyncPolicies which already holds the mutex
-	// so we don't need to lock again here
+	// Protocol/port policies, and source set policies, are routed by
+	// fwmark; see SyncClassification
+	if policy.MarkRouted(m.opts.SourceSets) {
+		logrus.Debugf("Policy %s is applied by the nftables classification", policy.Name)
+		return nil
+	}
 
 	logrus.Debugf("SetupPolicy: Checking if policy is enabled")
-	if !policy.Enabled {
-		logrus.Debugf("Policy %s is disabled, removing existing rules", policy.Name)
-
-		// Parse policy ID as source IP/CIDR
-		var srcNet *net.IPNet
+	if !policy.Active(time.Now()) {
+		logrus.Debugf("Policy %s is disabled or expired, removing existing rules", policy.Name)
 
-		// Try to parse as CIDR first
-		_, ipnet, err := net.ParseCIDR(policy.ID)
+		srcNet, err := policy.SourceNet()
 		if err != nil {
-			// Try as single IP
-			srcIP := net.ParseIP(policy.ID)
-			if srcIP == nil {
-				return fmt.Errorf("invalid policy ID as source IP/CIDR: %s", policy.ID)
-			}
-			// Create a /32 network for single IP
-			srcNet = &net.IPNet{
-				IP:   srcIP,
-				Mask: net.CIDRMask(32, 32),
-			}
-		} else {
-			srcNet = ipnet
+			return err
+		}
+		dstNet, err := policy.DestinationNet()
+		if err != nil {
+			return err
 		}
 
-		// Remove all rules for this source IP and clear conntrack
-		if err := m.removeAllRulesForSource(srcNet); err != nil {
-			logrus.Warnf("Failed to remove rules for disabled policy %s: %v", policy.Name, err)
+		// Remove all rules for this source (and destination) and clear conntrack
+		if err := m.removeAllRulesForSource(srcNet, dstNet, models.JournalReasonPolicy); err != nil {
+			logging.Policy(policy.ID, policy.ProviderID).Warnf("Failed to remove rules for disabled policy %s: %v", policy.Name, err)
 		}
 
 		logrus.Debugf("Successfully disabled policy %s", policy.Name)
 		return nil
 	}
 
-	// Log enabled policy at INFO level
-	logrus.Infof("Policy: %s, Source: %s, Provider: %s", policy.Name, policy.ID, provider.Name)
+	// Runs on every full sync; rule changes below are logged at info
+	logging.Policy(policy.ID, policy.ProviderID).Debugf("Policy: %s, Source: %s, Provider: %s", policy.Name, policy.Source(), provider.Name)
 
 	logrus.Debugf("SetupPolicy: Policy is enabled, proceeding with setup")
 	logrus.Debugf("Setting up policy %s (ID: %s) to use provider %s (TableID: %d)",
 		policy.Name, policy.ID, provider.Name, provider.TableID)
 
-	// Parse policy ID as source IP/CIDR
-	var srcNet *net.IPNet
-
-	// Try to parse as CIDR first
-	_, ipnet, err := net.ParseCIDR(policy.ID)
+	srcNet, err := policy.SourceNet()
 	if err != nil {
-		// Try as single IP
-		srcIP := net.ParseIP(policy.ID)
-		if srcIP == nil {
-			return fmt.Errorf("invalid policy ID as source IP/CIDR: %s", policy.ID)
-		}
-		// Create a /32 network for single IP
-		srcNet = &net.IPNet{
-			IP:   srcIP,
-			Mask: net.CIDRMask(32, 32),
-		}
-	} else {
-		srcNet = ipnet
+		return err
+	}
+	dstNet, err := policy.DestinationNet()
+	if err != nil {
+		return err
 	}
+	selector := models.RuleKeyFor(srcNet, dstNet)
 
-	logrus.Debugf("Parsed source network: %s", srcNet.String())
+	logrus.Debugf("Parsed selector: %s", selector)
 
-	// Check if a rule already exists for this source network
-	exists, existingPriority, existingTable := m.checkRoutingRuleExists(srcNet)
+	priority := policy.RulePriority(srcNet)
+
+	// Check if a rule already exists for this source network (and destination)
+	exists, existingPriority, existingTable := m.checkRoutingRuleExists(srcNet, dstNet)
 
 	if exists {
-		// If the rule exists and points to the correct table, no changes needed
-		if existingTable == provider.TableID {
-			logrus.Debugf("SKIPPING: Routing rule already exists and is correct for policy %s: priority=%d, table=%d, src=%s",
-				policy.Name, existingPriority, existingTable, srcNet.String())
-			return nil
+		// If the rule exists with the correct table and priority, no changes needed
+		if existingTable == provider.TableID && existingPriority == priority {
+			logrus.Debugf("SKIPPING: Routing rule already exists and is correct for policy %s: priority=%d, table=%d, %s",
+				policy.Name, existingPriority, existingTable, selector)
+			return m.syncBlackholeRule(srcNet, dstNet, priority, policy.Strict(), false)
 		}
 
-		// If the rule exists but points to a different table, remove all rules for this source
-		logrus.Debugf("Policy changed: removing all rules for source %s and adding new rule (table: %d)",
-			srcNet.String(), provider.TableID)
-		if err := m.removeAllRulesForSource(srcNet); err != nil {
+		// If the rule exists but points to a different table or priority, remove all rules for this source
+		logrus.Debugf("Policy changed: removing all rules for %s and adding new rule (table: %d, priority: %d)",
+			selector, provider.TableID, priority)
+		if err := m.removeAllRulesForSource(srcNet, dstNet, models.JournalReasonPolicy); err != nil {
 			return fmt.Errorf("failed to remove old routing rules for policy %s: %w", policy.Name, err)
 		}
 	}
 
-	// Add routing rule using ip command
-	logrus.Debugf("ADDING: New routing rule for policy %s: src=%s, table=%d", policy.Name, srcNet.String(), provider.TableID)
-	if err := m.addRoutingRule(srcNet, provider.TableID); err != nil {
+	// Add the policy's rule over netlink
+	logrus.Debugf("ADDING: New routing rule for policy %s: %s, table=%d", policy.Name, selector, provider.TableID)
+	if err := m.addRoutingRule(srcNet, dstNet, provider.TableID, priority); err != nil {
 		return fmt.Errorf("failed to add routing rule for policy %s: %w", policy.Name, err)
 	}
 
+	// A strict policy's blackhole rule must come after the new lookup rule
+	if err := m.syncBlackholeRule(srcNet, dstNet, priority, policy.Strict(), true); err != nil {
+		return fmt.Errorf("failed to apply strict mode for policy %s: %w", policy.Name, err)
+	}
+
 	logrus.Debugf("Successfully set up policy %s", policy.Name)
 	return nil
 }
 
 // RemovePolicy removes a routing policy
 func (m *Manager) RemovePolicy(policy *models.RoutingPolicy, provider *models.InternetProvider) error {
-	logrus.Infof("Removing policy %s (ID: %s)", policy.Name, policy.ID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Note: This function is called from SyncPolicies which already holds the mutex
-	// so we don't need to lock again here
+	logging.Policy(policy.ID, policy.ProviderID).Infof("Removing policy %s (ID: %s)", policy.Name, policy.ID)
 
-	// Parse policy ID as source IP/CIDR
-	var srcNet *net.IPNet
+	if policy.MarkRouted(m.opts.SourceSets) {
+		return nil
+	}
 
-	// Try to parse as CIDR first
-	_, ipnet, err := net.ParseCIDR(policy.ID)
+	srcNet, err := policy.SourceNet()
 	if err != nil {
-		// Try as single IP
-		srcIP := net.ParseIP(policy.ID)
-		if srcIP == nil {
-			return fmt.Errorf("invalid policy ID as source IP/CIDR: %s", policy.ID)
-		}
-		// Create a /32 network for single IP
-		srcNet = &net.IPNet{
-			IP:   srcIP,
-			Mask: net.CIDRMask(32, 32),
-		}
-	} else {
-		srcNet = ipnet
+		return err
+	}
+	dstNet, err := policy.DestinationNet()
+	if err != nil {
+		return err
 	}
 
-	// Remove routing rule using ip command
-	if err := m.removeRoutingRule(srcNet); err != nil {
+	// Remove the policy's rule over netlink
+	if err := m.removeRoutingRule(srcNet, dstNet); err != nil {
 		return fmt.Errorf("failed to remove routing rule for policy %s: %w", policy.Name, err)
 	}
+	if err := m.syncBlackholeRule(srcNet, dstNet, 0, false, false); err != nil {
+		logging.Policy(policy.ID, policy.ProviderID).Warnf("Failed to remove blackhole rule for policy %s: %v", policy.Name, err)
+	}
 
-	logrus.Infof("Successfully removed policy %s", policy.Name)
+	logging.Policy(policy.ID, policy.ProviderID).Infof("Successfully removed policy %s", policy.Name)
 	return nil
 }
 
-// SyncProviders synchronizes all providers with the current routing configuration
+// SyncProviders reconciles the routes of every provider (see
+// setupProviderLocked). The providers that fail are returned as
+// ProviderErrors.
 func (m *Manager) SyncProviders(providers []*models.InternetProvider) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.syncProvidersLocked(providers)
+}
 
-	logrus.Info("Synchronizing providers with routing configuration")
-	logrus.Infof("Processing %d providers", len(providers))
-
-	// Clear existing routes for our tables
-	for _, provider := range providers {
-		logrus.Debugf("Clearing routes for provider: %s", provider.Name)
-		if err := m.clearProviderRoutes(provider); err != nil {
-			logrus.Warnf("Failed to clear routes for provider %s: %v", provider.Name, err)
-		}
-	}
+// syncProvidersLocked is SyncProviders for callers holding m.mu.
+func (m *Manager) syncProvidersLocked(providers []*models.InternetProvider) error {
+	logrus.Debug("Synchronizing providers with routing configuration")
+	logrus.Debugf("Processing %d providers", len(providers))
 
-	// Set up new routes. We already hold m.mu, so call the locked variant.
+	// We already hold m.mu, so call the locked variant.
+	failed := make(ProviderErrors)
 	for _, provider := range providers {
 		logrus.Debugf("Setting up provider: %s", provider.Name)
 		if err := m.setupProviderLocked(provider); err != nil {
-			logrus.Errorf("Failed to set up provider %s: %v", provider.Name, err)
-			continue
+			logging.Provider(provider.ID).Errorf("Failed to set up provider %s: %v", provider.Name, err)
+			failed[provider.ID] = err
 		}
 	}
 
-	logrus.Info("Provider synchronization completed")
+	logrus.Debug("Provider synchronization completed")
+	if len(failed) > 0 {
+		return failed
+	}
 	return nil
 }
 
-// SyncPolicies synchronizes all policies with the current routing configuration
-func (m *Manager) SyncPolicies(policies []*models.RoutingPolicy, providers []*models.InternetProvider) error {
+// SyncPolicies synchronizes all policies with the current routing
+// configuration. failover (see models.PlanFailover) moves policies whose
+// provider is down to another provider, or off every provider. The policies
+// that fail are returned as PolicyErrors.
+func (m *Manager) SyncPolicies(policies []*models.RoutingPolicy, providers []*models.InternetProvider, failover map[string]string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.syncPoliciesLocked(policies, providers, failover)
+}
 
+// Sync runs SyncProviders and then SyncPolicies as one full sync, without
+// other changes in between, and tags every journal entry it records with
+// syncID so the changes can be traced back to what triggered the sync. The
+// returned counters are the changes of this sync alone; TakeCounters still
+// sees them.
+func (m *Manager) Sync(syncID string, providers []*models.InternetProvider, policies []*models.RoutingPolicy, failover map[string]string) (counters Counters, providersErr, policiesErr error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncID = syncID
+	defer func() { m.syncID = "" }()
+
+	// Every change is counted under m.mu, so nothing else is counted
+	// between the two snapshots
+	before := m.snapshotCounters()
+	providersErr = m.syncProvidersLocked(providers)
+	policiesErr = m.syncPoliciesLocked(policies, providers, failover)
+	return m.snapshotCounters().sub(before), providersErr, policiesErr
+}
+
+// syncPoliciesLocked is SyncPolicies for callers holding m.mu.
+func (m *Manager) syncPoliciesLocked(policies []*models.RoutingPolicy, providers []*models.InternetProvider, failover map[string]string) error {
 	logrus.Debug("Synchronizing policies with routing configuration")
 	logrus.Debugf("Found %d policies and %d providers", len(policies), len(providers))
 
@@ -296,18 +404,39 @@ func (m *Manager) SyncPolicies(policies []*models.RoutingPolicy, providers []*mo
 		logrus.Debugf("Provider: %s (ID: %s, TableID: %d)", provider.Name, provider.ID, provider.TableID)
 	}
 
-	// Set up rules for all policies
-	for _, policy := range policies {
+	// Set up one rule per source; see models.EffectivePolicies
+	effective := models.EffectivePolicies(policies, time.Now())
+	routed := make([]*models.RoutingPolicy, 0, len(effective))
+	failed := make(PolicyErrors)
+	for _, policy := range effective {
 		logrus.Debugf("Setting up policy: %s (ID: %s, ProviderID: %s)", policy.Name, policy.ID, policy.ProviderID)
+		providerID, ok := policy.FailoverProvider(failover)
+		if !ok {
+			m.count(func(c *Counters) { c.RulesSkipped++ })
+			if err := m.applyProviderDownLocked(policy); err != nil {
+				logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to take policy %s off its down provider: %v", policy.Name, err)
+				failed[policy.ID] = err
+			}
+			continue
+		}
+		if providerID != policy.ProviderID {
+			logging.Policy(policy.ID, policy.ProviderID).Debugf("Provider %s is down: policy %s uses %s", policy.ProviderID, policy.Name, providerID)
+			moved := *policy
+			moved.ProviderID = providerID
+			policy = &moved
+		}
+		routed = append(routed, policy)
 		if provider, exists := providerMap[policy.ProviderID]; exists {
 			logrus.Debugf("Found provider for policy %s: %s (TableID: %d)", policy.Name, provider.Name, provider.TableID)
-			if err := m.SetupPolicy(policy, provider); err != nil {
-				logrus.Errorf("Failed to set up policy %s: %v", policy.Name, err)
+			if err := m.setupPolicyLocked(policy, provider); err != nil {
+				logging.Policy(policy.ID, policy.ProviderID).Errorf("Failed to set up policy %s: %v", policy.Name, err)
+				failed[policy.ID] = err
 				continue
 			}
 			logrus.Debugf("Successfully set up policy: %s", policy.Name)
 		} else {
-			logrus.Warnf("Provider %s not found for policy %s", policy.ProviderID, policy.Name)
+			m.count(func(c *Counters) { c.RulesSkipped++ })
+			logging.Policy(policy.ID, policy.ProviderID).Warnf("Provider %s not found for policy %s", policy.ProviderID, policy.Name)
 		}
 	}
 
@@ -318,235 +447,175 @@ func (m *Manager) SyncPolicies(policies []*models.RoutingPolicy, providers []*mo
 		logrus.Warnf("Failed to cleanup stale rules: %v", err)
 	}
 
+	// Protocol/port and source set policies go through nftables marks
+	// instead; those without a provider lose their marks
+	if err := m.syncClassificationLocked(routed, providers); err != nil {
+		logrus.Warnf("Failed to sync nftables classification: %v", err)
+	}
+
+	if err := m.syncAccountingLocked(effective); err != nil {
+		logrus.Warnf("Failed to sync nftables accounting: %v", err)
+	}
+
 	// Validate that we have only one rule per source IP
 	if err := m.validateSingleRulePerSource(); err != nil {
 		logrus.Warnf("Failed to validate single rule per source: %v", err)
 	}
 
+	if len(failed) > 0 {
+		return failed
+	}
 	return nil
 }
 
-// clearProviderRoutes clears all routes for a provider
-func (m *Manager) clearProviderRoutes(provider *models.InternetProvider) error {
-	logrus.Debugf("Clearing routes for provider %s (table %d)", provider.Name, provider.TableID)
-
-	// Get all routes for the table
-	// Note: RouteListFiltered is not available, so we'll use RouteList and filter manually
-	routes, err := netlink.RouteList(nil, 0) // 0 for all families
+// deleteRoute removes r from its table, journalling and counting it.
+func (m *Manager) deleteRoute(r kernelRoute, reason string) error {
+	err := m.delRoute(r)
+	m.recordRoute(models.JournalRouteDelete, reason, r, err)
 	if err != nil {
-		logrus.Errorf("Failed to list routes: %v", err)
-		return fmt.Errorf("failed to list routes: %w", err)
+		m.count(func(c *Counters) { c.RouteFailures++ })
+		return fmt.Errorf("failed to remove route %s from table %d: %w", r, r.Table, err)
 	}
+	m.count(func(c *Counters) { c.RoutesRemoved++ })
+	return nil
+}
 
-	logrus.Debugf("Found %d total routes, checking for table %d", len(routes), provider.TableID)
-
-	// Remove all routes in the table
-	for _, route := range routes {
-		if route.Table == provider.TableID {
-			logrus.Debugf("Removing route in table %d: %v", provider.TableID, route)
-			if err := netlink.RouteDel(&route); err != nil {
-				logrus.Warnf("Failed to remove route: %v", err)
-			}
+// containsRoute reports whether routes has a route matching want, see
+// kernelRoute.matches.
+func containsRoute(routes []kernelRoute, want kernelRoute) bool {
+	for _, r := range routes {
+		if r.matches(want) {
+			return true
 		}
 	}
+	return false
+}
 
-	logrus.Debugf("Finished clearing routes for provider %s", provider.Name)
-	return nil
+// RoutingStats summarizes the kernel routing state on this router.
+type RoutingStats struct {
+	TotalRoutes     int `json:"total_routes"`
+	TotalRules      int `json:"total_rules"`
+	ManagedRules    int `json:"managed_rules"`
+	TotalInterfaces int `json:"total_interfaces"`
 }
 
 // GetRoutingStats returns statistics about the current routing configuration
-func (m *Manager) GetRoutingStats() (map[string]interface{}, error) {
+func (m *Manager) GetRoutingStats() (*RoutingStats, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	stats := make(map[string]interface{})
+	stats := &RoutingStats{}
 
-	// Count routes
 	routes, err := netlink.RouteList(nil, 0) // 0 for all families
 	if err != nil {
 		return nil, fmt.Errorf("failed to list routes: %w", err)
 	}
-	stats["total_routes"] = len(routes)
+	stats.TotalRoutes = len(routes)
 
-	// Count rules (not available in current netlink library)
-	stats["total_rules"] = 0
-	stats["rules_note"] = "Rule management not implemented in current netlink library"
+	rules, err := listRules()
+	if err != nil {
+		return nil, err
+	}
+	stats.TotalRules = len(rules)
+	for _, r := range rules {
+		if models.IsManagedPriority(r.Priority) {
+			stats.ManagedRules++
+		}
+	}
 
-	// Count interfaces
 	links, err := netlink.LinkList()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list interfaces: %w", err)
 	}
-	stats["total_interfaces"] = len(links)
+	stats.TotalInterfaces = len(links)
 
 	return stats, nil
 }
 
-// calculatePriority calculates the priority based on CIDR specificity
-// More specific CIDRs get lower priority numbers (higher priority)
-// calculatePriority calculates the priority based on CIDR specificity
-// More specific CIDRs get lower priority numbers (higher priority)
-// /32 = 32 bits = priority 2000
-// /31 = 31 bits = priority 2001
-// /30 = 30 bits = priority 2002
-// /29 = 29 bits = priority 2003
-// /28 = 28 bits = priority 2004
-// /27 = 27 bits = priority 2005
-// /26 = 26 bits = priority 2006
-// /25 = 25 bits = priority 2007
-// /24 = 24 bits = priority 2008
-// /23 = 23 bits = priority 2009
-// /22 = 22 bits = priority 2010
-// /21 = 21 bits = priority 2011
-// /20 = 20 bits = priority 2012
-// /19 = 19 bits = priority 2013
-// /18 = 18 bits = priority 2014
-// /17 = 17 bits = priority 2015
-// /16 = 16 bits = priority 2016
-// /15 = 15 bits = priority 2017
-// /14 = 14 bits = priority 2018
-// /13 = 13 bits = priority 2019
-// /12 = 12 bits = priority 2020
-// /11 = 11 bits = priority 2021
-// /10 = 10 bits = priority 2022
-// /9 = 9 bits = priority 2023
-// /8 = 8 bits = priority 2024
-// /7 = 7 bits = priority 2025
-// /6 = 6 bits = priority 2026
-// /5 = 5 bits = priority 2027
-// /4 = 4 bits = priority 2028
-// /3 = 3 bits = priority 2029
-// /2 = 2 bits = priority 2030
-// /1 = 1 bit = priority 2031
-// /0 = 0 bits = priority 2032
-func calculatePriority(srcNet *net.IPNet) int {
-	ones, _ := srcNet.Mask.Size()
-	specificity := ones // Number of network bits
-	return 2000 + (32 - specificity)
-}
-
-// checkRoutingRuleExists checks if a routing rule already exists for a given source network
-func (m *Manager) checkRoutingRuleExists(srcNet *net.IPNet) (bool, int, int) {
-	cmd := exec.Command("ip", "rule", "show")
-	output, err := cmd.CombinedOutput()
+// checkRoutingRuleExists checks if a routing rule already exists for a given
+// source network and optional destination network, returning its priority and table
+func (m *Manager) checkRoutingRuleExists(srcNet, dstNet *net.IPNet) (bool, int, int) {
+	rules, err := listRules()
 	if err != nil {
 		logrus.Warnf("Failed to check existing rules: %v", err)
 		return false, 0, 0
 	}
 
-	ruleOutput := string(output)
-	logrus.Debugf("Current rules: %s", ruleOutput)
-
-	// Look for any rule with our source network
-	lines := strings.Split(ruleOutput, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	// Look for the lookup rule with our selector; blackhole rules of strict
+	// policies share it, see strict.go
+	key := ruleKey(srcNet, dstNet)
+	for _, r := range rules {
+		if r.Action != "" || r.Mark != 0 || r.Src == nil || r.key() != key {
 			continue
 		}
-
-		// Parse line format: "100: from 192.168.2.25 lookup 99"
-		// The rule output shows IP without CIDR suffix, so we need to match just the IP part
-		srcIP := srcNet.IP.String()
-		if strings.Contains(line, fmt.Sprintf("from %s", srcIP)) {
-			// Extract priority and table from the rule
-			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				priorityStr := strings.TrimSuffix(parts[0], ":")
-				tableStr := parts[len(parts)-1]
-
-				priority, _ := strconv.Atoi(priorityStr)
-				table, _ := strconv.Atoi(tableStr)
-
-				logrus.Debugf("Found existing rule: %s (priority: %d, table: %d)", line, priority, table)
-				return true, priority, table
-			}
-		}
+		logrus.Debugf("Found existing rule for %s (priority: %d, table: %d)", key, r.Priority, r.Table)
+		return true, r.Priority, r.Table
 	}
 
-	logrus.Debugf("No existing rule found for source %s", srcNet.String())
+	logrus.Debugf("No existing rule found for %s", models.RuleKeyFor(srcNet, dstNet))
 	return false, 0, 0
 }
 
 // removeAllRulesForSource removes all routing rules for a given source network
-func (m *Manager) removeAllRulesForSource(srcNet *net.IPNet) error {
-	srcIP := srcNet.IP.String()
-	removedCount := 0
-	maxAttempts := 10 // Prevent infinite loops
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		// Get current rules
-		cmd := exec.Command("ip", "rule", "show")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			logrus.Warnf("Failed to check existing rules: %v", err)
-			return err
-		}
-
-		ruleOutput := string(output)
-		lines := strings.Split(ruleOutput, "\n")
-		foundRule := false
-
-		// Look for rules with our source network
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
+// and optional destination network. Rules for the same source with another
+// destination belong to other policies and are kept. reason is journaled with
+// each removal.
+func (m *Manager) removeAllRulesForSource(srcNet, dstNet *net.IPNet, reason string) error {
+	selector := models.RuleKeyFor(srcNet, dstNet)
+	rules, err := listRules()
+	if err != nil {
+		logrus.Warnf("Failed to check existing rules: %v", err)
+		return err
+	}
 
-			// Check if this rule is for our specific source IP
-			if strings.Contains(line, fmt.Sprintf("from %s", srcIP)) {
-				// Extract priority from the rule
-				parts := strings.Fields(line)
-				if len(parts) >= 4 {
-					priorityStr := strings.TrimSuffix(parts[0], ":")
-					priority, _ := strconv.Atoi(priorityStr)
-
-					logrus.Infof("Removing rule for source %s: %s (priority: %d)", srcIP, line, priority)
-
-					// Remove the rule by source IP/CIDR instead of priority
-					// This is safer as it only removes rules for this specific source
-					cmd := exec.Command("ip", "rule", "del", "from", srcNet.String())
-					if err := cmd.Run(); err != nil {
-						logrus.Warnf("Failed to remove rule: %v", err)
-					} else {
-						removedCount++
-						foundRule = true
-						break // Remove one rule at a time
-					}
-				}
-			}
+	key := ruleKey(srcNet, dstNet)
+	removedCount := 0
+	for _, r := range rules {
+		if r.Mark != 0 || r.Src == nil || r.key() != key {
+			continue
 		}
+		logrus.Infof("Removing rule for %s (priority: %d, table: %d)", selector, r.Priority, r.Table)
 
-		// If no rule was found or removed, we're done
-		if !foundRule {
-			break
+		// The rule is deleted with its priority, selectors, table and action,
+		// so rules with other destinations or actions are never hit
+		err := m.delRule(r)
+		m.recordKernelRule(models.JournalRuleDelete, reason, r, err)
+		if err != nil {
+			logrus.Warnf("Failed to remove rule: %v", err)
+			m.count(func(c *Counters) { c.RuleFailures++ })
+			continue
 		}
+		removedCount++
+		m.count(func(c *Counters) { c.RulesRemoved++ })
 	}
 
 	if removedCount > 0 {
-		logrus.Infof("Removed %d rules for source %s", removedCount, srcIP)
+		logrus.Infof("Removed %d rules for %s", removedCount, selector)
 	}
 
 	return nil
 }
 
-// removeRoutingRule removes a routing rule for a given source network
-func (m *Manager) removeRoutingRule(srcNet *net.IPNet) error {
-	exists, priority, _ := m.checkRoutingRuleExists(srcNet)
+// removeRoutingRule removes a routing rule for a given source network and
+// optional destination network
+func (m *Manager) removeRoutingRule(srcNet, dstNet *net.IPNet) error {
+	selector := models.RuleKeyFor(srcNet, dstNet)
+	exists, priority, table := m.checkRoutingRuleExists(srcNet, dstNet)
 	if !exists {
-		logrus.Debugf("No rule to remove for source %s", srcNet.String())
+		logrus.Debugf("No rule to remove for %s", selector)
 		return nil
 	}
 
-	cmd := exec.Command("ip", "rule", "del", "priority", strconv.Itoa(priority))
-	output, err := cmd.CombinedOutput()
+	err := m.delRule(lookupRule(priority, table, srcNet, dstNet))
+	m.recordRule(models.JournalRuleDelete, models.JournalReasonPolicy, priority, table, srcNet.String(), netString(dstNet), err)
 	if err != nil {
-		logrus.Warnf("Failed to remove routing rule: %v, output: %s", err, string(output))
-		return fmt.Errorf("failed to remove routing rule: %v", err)
+		logrus.Warnf("Failed to remove routing rule: %v", err)
+		m.count(func(c *Counters) { c.RuleFailures++ })
+		return fmt.Errorf("failed to remove routing rule: %w", err)
 	}
+	m.count(func(c *Counters) { c.RulesRemoved++ })
 
-	logrus.Infof("Removed routing rule for source %s (priority: %d)", srcNet.String(), priority)
+	logrus.Infof("Removed routing rule for %s (priority: %d)", selector, priority)
 
 	// Clear conntrack entries for this source network to ensure connections stop using the old routing
 	if err := m.clearConntrack(srcNet); err != nil {
@@ -556,19 +625,19 @@ func (m *Manager) removeRoutingRule(srcNet *net.IPNet) error {
 	return nil
 }
 
-// addRoutingRule adds a routing rule for a given source network and table
-func (m *Manager) addRoutingRule(srcNet *net.IPNet, tableID int) error {
-	priority := calculatePriority(srcNet)
-
-	cmd := exec.Command("ip", "rule", "add", "priority", strconv.Itoa(priority), "table", strconv.Itoa(tableID), "from", srcNet.String())
-	output, err := cmd.CombinedOutput()
+// addRoutingRule adds a routing rule for a given source network, optional
+// destination network and table at priority (see RoutingPolicy.RulePriority).
+func (m *Manager) addRoutingRule(srcNet, dstNet *net.IPNet, tableID, priority int) error {
+	err := m.addRule(lookupRule(priority, tableID, srcNet, dstNet))
+	m.recordRule(models.JournalRuleAdd, models.JournalReasonPolicy, priority, tableID, srcNet.String(), netString(dstNet), err)
 	if err != nil {
-		logrus.Errorf("Command failed: %v", err)
-		logrus.Errorf("Command output: %s", string(output))
-		return fmt.Errorf("failed to add routing rule: %v", err)
+		logrus.Errorf("Failed to add routing rule for %s: %v", models.RuleKeyFor(srcNet, dstNet), err)
+		m.count(func(c *Counters) { c.RuleFailures++ })
+		return fmt.Errorf("failed to add routing rule: %w", err)
 	}
+	m.count(func(c *Counters) { c.RulesAdded++ })
 
-	logrus.Infof("Added routing rule: priority %d, source %s, table %d", priority, srcNet.String(), tableID)
+	logrus.Infof("Added routing rule: priority %d, %s, table %d", priority, models.RuleKeyFor(srcNet, dstNet), tableID)
 
 	// Clear conntrack entries for this source network to ensure new connections use the updated routing
 	if err := m.clearConntrack(srcNet); err != nil {
@@ -580,127 +649,90 @@ func (m *Manager) addRoutingRule(srcNet *net.IPNet, tableID int) error {
 
 // clearConntrack clears conntrack entries for a given source network
 func (m *Manager) clearConntrack(srcNet *net.IPNet) error {
-	cmd := exec.Command("conntrack", "-D", "--src", srcNet.String())
-	output, err := cmd.CombinedOutput()
+	if m.opts.DisableConntrack {
+		return nil
+	}
+	deleted, err := m.flushConntrack(srcNet, models.JournalReasonPolicy)
 	if err != nil {
 		// It's okay if there are no entries to delete
-		logrus.Debugf("Conntrack clear result for %s: %s", srcNet.String(), string(output))
+		logrus.Debugf("Conntrack clear result for %s: %v", srcNet.String(), err)
 		return nil
 	}
-
-	// conntrack exits 0 even when nothing is deleted (e.g. "0 flow entries have been deleted").
-	// Avoid noisy INFO logs during periodic sync when policies are disabled/removed.
-	out := strings.ToLower(string(output))
-	if strings.Contains(out, "0 flow") || strings.Contains(out, "0 entries") {
+	if deleted == 0 {
 		logrus.Debugf("No conntrack entries to clear for source %s", srcNet.String())
-		return nil
 	}
-
-	logrus.Infof("Cleared conntrack entries for source %s", srcNet.String())
 	return nil
 }
 
 // cleanupStaleRules removes routing rules for policies that no longer exist in the configuration
 func (m *Manager) cleanupStaleRules(activePolicies []*models.RoutingPolicy) error {
 	// Get all current routing rules
-	cmd := exec.Command("ip", "rule", "show")
-	output, err := cmd.CombinedOutput()
+	rules, err := listRules()
 	if err != nil {
 		logrus.Warnf("Failed to get current rules for cleanup: %v", err)
 		return err
 	}
 
-	// Create a set of active policy source networks
-	activeSources := make(map[string]bool)
+	// Create a set of active policy selectors (source, plus destination if
+	// any), and of those whose policy is strict
+	activeSelectors := make(map[string]bool)
+	strictSelectors := make(map[string]bool)
 	for _, policy := range activePolicies {
-		// Parse policy ID as source IP/CIDR
-		var srcNet *net.IPNet
-		_, ipnet, err := net.ParseCIDR(policy.ID)
-		if err != nil {
-			// Try as single IP
-			srcIP := net.ParseIP(policy.ID)
-			if srcIP == nil {
-				logrus.Warnf("Invalid policy ID as source IP/CIDR: %s", policy.ID)
-				continue
-			}
-			// Create a /32 network for single IP
-			srcNet = &net.IPNet{
-				IP:   srcIP,
-				Mask: net.CIDRMask(32, 32),
-			}
-		} else {
-			srcNet = ipnet
-		}
-		activeSources[srcNet.IP.String()] = true
-	}
-
-	// Parse rules and remove those that don't correspond to active policies
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+		if policy.MarkRouted(m.opts.SourceSets) {
 			continue
 		}
-
-		// Extract priority to check if it's in our managed range (100-132)
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
+		srcNet, err := policy.SourceNet()
+		if err != nil {
+			logrus.Warnf("%v", err)
 			continue
 		}
-
-		priorityStr := strings.TrimSuffix(parts[0], ":")
-		priority, err := strconv.Atoi(priorityStr)
+		dstNet, err := policy.DestinationNet()
 		if err != nil {
-			continue // Skip lines that don't have valid priority
+			logrus.Warnf("%v", err)
+			continue
 		}
+		activeSelectors[ruleKey(srcNet, dstNet)] = true
+		if policy.Strict() {
+			strictSelectors[ruleKey(srcNet, dstNet)] = true
+		}
+	}
 
-		// Only manage rules in our priority range (2000-2032)
-		if priority < 2000 || priority > 2032 {
+	// Remove the rules that don't correspond to active policies
+	for _, r := range rules {
+		// Only manage rules in our priority range
+		if !models.IsManagedPriority(r.Priority) {
 			continue // Skip rules outside our managed range
 		}
 
-		// Skip default rules that might be in our range
-		if strings.HasPrefix(line, "0:") || strings.HasPrefix(line, "32766:") || strings.HasPrefix(line, "32767:") {
+		// Mark rules are reconciled by syncMarkRules
+		if r.Mark != 0 {
 			continue
 		}
 
-		// Parse line format: "100: from 192.168.2.25 lookup 99"
-		if strings.Contains(line, "from") && strings.Contains(line, "lookup") {
-			// Extract source IP from the rule
-			srcIP := ""
-			for i, part := range parts {
-				if part == "from" && i+1 < len(parts) {
-					srcIP = parts[i+1]
-					break
+		// Blackhole rules stay only for strict policies
+		if r.Action != "" {
+			if r.Src != nil && !strictSelectors[r.key()] {
+				logrus.Infof("Removing stale %s rule for %s (priority: %d)", r.Action, r.key(), r.Priority)
+				err := m.delRule(r)
+				m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonStale, r, err)
+				if err != nil {
+					logrus.Warnf("Failed to remove stale rule: %v", err)
 				}
 			}
+			continue
+		}
 
-			if srcIP != "" {
-				// Check if this source IP matches any active policy
-				// We need to check both the exact match and the IP part (for CIDR rules)
-				found := false
-				if activeSources[srcIP] {
-					found = true
-				} else {
-					// For CIDR rules, also check the IP part without CIDR
-					// e.g., if rule shows "192.168.2.0/25", also check "192.168.2.0"
-					if strings.Contains(srcIP, "/") {
-						ipPart := strings.Split(srcIP, "/")[0]
-						if activeSources[ipPart] {
-							found = true
-						}
-					}
-				}
-
-				if !found {
-					// This rule is for a policy that no longer exists
-					logrus.Infof("Removing stale rule for inactive policy: %s (priority: %d)", line, priority)
+		if !activeSelectors[r.key()] {
+			// This rule is for a policy that no longer exists
+			logrus.Infof("Removing stale rule for inactive policy: from %s, table %d (priority: %d)", r.from(), r.Table, r.Priority)
 
-					cmd := exec.Command("ip", "rule", "del", "priority", strconv.Itoa(priority))
-					if err := cmd.Run(); err != nil {
-						logrus.Warnf("Failed to remove stale rule: %v", err)
-					}
-				}
+			err := m.delRule(r)
+			m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonStale, r, err)
+			if err != nil {
+				logrus.Warnf("Failed to remove stale rule: %v", err)
+				m.count(func(c *Counters) { c.RuleFailures++ })
+			} else {
+				m.count(func(c *Counters) { c.RulesRemoved++; c.StaleRulesRemoved++ })
 			}
 		}
 	}
@@ -710,79 +742,28 @@ func (m *Manager) cleanupStaleRules(activePolicies []*models.RoutingPolicy) erro
 
 // cleanupDuplicateRules removes duplicate rules for the same IP/CIDR, keeping only the first one
 func (m *Manager) cleanupDuplicateRules() error {
-	logrus.Info("Cleaning up duplicate routing rules")
+	logrus.Debug("Cleaning up duplicate routing rules")
 
-	// Get all current routing rules
-	cmd := exec.Command("ip", "rule", "show")
-	output, err := cmd.CombinedOutput()
+	rules, err := listRules()
 	if err != nil {
 		logrus.Warnf("Failed to get current rules for cleanup: %v", err)
 		return err
 	}
 
-	// Track seen source IPs and their rules
-	sourceRules := make(map[string][]string)
-	lines := strings.Split(string(output), "\n")
-
-	// Parse all rules and group by source IP (only for our managed priority range 2000-2032)
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Extract priority to check if it's in our managed range (2000-2032)
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
-			continue
-		}
-
-		priorityStr := strings.TrimSuffix(parts[0], ":")
-		priority, err := strconv.Atoi(priorityStr)
-		if err != nil {
-			continue // Skip lines that don't have valid priority
-		}
-
-		// Only process rules in our managed range (2000-2032)
-		if priority < 2000 || priority > 2032 {
-			continue
-		}
+	// Remove duplicate rules, keeping only the first one for each selector
+	removedCount := 0
+	for key, dups := range duplicateRules(rules, false) {
+		logrus.Infof("Found %d duplicate rules for source %s, keeping first one", len(dups), key)
 
-		// Extract source IP from the rule
-		if strings.Contains(line, "from") && strings.Contains(line, "lookup") {
-			for i, part := range parts {
-				if part == "from" && i+1 < len(parts) {
-					srcIP := parts[i+1]
-					sourceRules[srcIP] = append(sourceRules[srcIP], line)
-					break
-				}
-			}
-		}
-	}
+		for _, r := range dups[1:] {
+			logrus.Infof("Removing duplicate rule: from %s, table %d (priority: %d)", r.from(), r.Table, r.Priority)
 
-	// Remove duplicate rules, keeping only the first one for each source IP
-	removedCount := 0
-	for srcIP, rules := range sourceRules {
-		if len(rules) > 1 {
-			logrus.Infof("Found %d duplicate rules for source %s, keeping first one", len(rules), srcIP)
-
-			// Keep the first rule, remove the rest
-			for i := 1; i < len(rules); i++ {
-				rule := rules[i]
-				parts := strings.Fields(rule)
-				if len(parts) >= 1 {
-					priorityStr := strings.TrimSuffix(parts[0], ":")
-					priority, _ := strconv.Atoi(priorityStr)
-
-					logrus.Infof("Removing duplicate rule: %s (priority: %d)", rule, priority)
-
-					cmd := exec.Command("ip", "rule", "del", "priority", strconv.Itoa(priority))
-					if err := cmd.Run(); err != nil {
-						logrus.Warnf("Failed to remove duplicate rule: %v", err)
-					} else {
-						removedCount++
-					}
-				}
+			err := m.delRule(r)
+			m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonDuplicate, r, err)
+			if err != nil {
+				logrus.Warnf("Failed to remove duplicate rule: %v", err)
+			} else {
+				removedCount++
 			}
 		}
 	}
@@ -790,23 +771,51 @@ func (m *Manager) cleanupDuplicateRules() error {
 	if removedCount > 0 {
 		logrus.Infof("Cleanup completed: removed %d duplicate routing rules", removedCount)
 	} else {
-		logrus.Info("No duplicate rules found")
+		logrus.Debug("No duplicate rules found")
 	}
 
 	return nil
 }
 
+// duplicateRules groups the lookup rules in the managed priority range by
+// selector (source, plus destination if any) and returns the groups with more
+// than one rule, in kernel order. Mark rules share their priority with plain
+// rules by design and are left out, as are "from all" rules when skipAll is
+// set.
+func duplicateRules(rules []kernelRule, skipAll bool) map[string][]kernelRule {
+	bySelector := make(map[string][]kernelRule)
+	for _, r := range rules {
+		if !models.IsManagedPriority(r.Priority) || r.Mark != 0 || r.Action != "" {
+			continue
+		}
+		if skipAll && r.Src == nil {
+			continue
+		}
+		bySelector[r.key()] = append(bySelector[r.key()], r)
+	}
+	for key, group := range bySelector {
+		if len(group) < 2 {
+			delete(bySelector, key)
+		}
+	}
+	return bySelector
+}
+
 // suppressDefaultRulePriority is the priority of the "fall through to main but
 // ignore its default route" rule. It must sit BEFORE the per-policy rules
-// (which live in 2000-2032) so local traffic to other LAN subnets always
-// resolves via the main table, while default-route traffic falls through to
-// the policy rules and out the chosen provider table.
-const suppressDefaultRulePriority = 10
+// (which live in the managed range, 2000-2032 by default) so local traffic
+// to other LAN subnets always resolves via the main table, while
+// default-route traffic falls through to the policy rules and out the chosen
+// provider table.
+const suppressDefaultRulePriority = models.SuppressDefaultPriority
+
+// mainTable is the ID of the kernel's main routing table.
+const mainTable = 254
 
-// suppressDefaultRuleSignature is the substring we look for in `ip rule show`
-// output to detect that the rule is already installed (regardless of who
-// installed it: us on a previous run, an operator, etc.).
-const suppressDefaultRuleSignature = "from all lookup main suppress_prefixlength 0"
+// suppressDefaultRule is the "from all lookup main suppress_prefixlength 0"
+// rule. It is recognised by these fields, regardless of who installed it: us
+// on a previous run, an operator, etc.
+var suppressDefaultRule = kernelRule{Priority: suppressDefaultRulePriority, Table: mainTable, SuppressDefault: true}
 
 // EnsureSuppressDefaultRule installs the global "lookup main with
 // suppress_prefixlength 0" rule at priority 10 if it is not already present.
@@ -830,14 +839,10 @@ func (m *Manager) EnsureSuppressDefaultRule() error {
 	logrus.Infof("Installing suppress-default rule: priority=%d, lookup main, suppress_prefixlength=0",
 		suppressDefaultRulePriority)
 
-	cmd := exec.Command("ip", "rule", "add",
-		"from", "all",
-		"lookup", "main",
-		"suppress_prefixlength", "0",
-		"priority", strconv.Itoa(suppressDefaultRulePriority),
-	)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to install suppress-default rule: %w: %s", err, strings.TrimSpace(string(out)))
+	err = m.addRule(suppressDefaultRule)
+	m.recordKernelRule(models.JournalRuleAdd, models.JournalReasonSuppressDefault, suppressDefaultRule, err)
+	if err != nil {
+		return fmt.Errorf("failed to install suppress-default rule: %w", err)
 	}
 	return nil
 }
@@ -860,155 +865,146 @@ func (m *Manager) RemoveSuppressDefaultRule() error {
 
 	logrus.Infof("Removing suppress-default rule at priority %d", suppressDefaultRulePriority)
 
-	cmd := exec.Command("ip", "rule", "del",
-		"from", "all",
-		"lookup", "main",
-		"suppress_prefixlength", "0",
-		"priority", strconv.Itoa(suppressDefaultRulePriority),
-	)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to remove suppress-default rule: %w: %s", err, strings.TrimSpace(string(out)))
+	err = m.delRule(suppressDefaultRule)
+	m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonSuppressDefault, suppressDefaultRule, err)
+	if err != nil {
+		return fmt.Errorf("failed to remove suppress-default rule: %w", err)
 	}
 	return nil
 }
 
-// hasSuppressDefaultRule returns true if a rule at suppressDefaultRulePriority
-// with the suppress-default signature is currently installed. Caller must hold
-// m.mu.
+// hasSuppressDefaultRule returns true if the IPv4 suppress-default rule is
+// currently installed at suppressDefaultRulePriority. Caller must hold m.mu.
 func (m *Manager) hasSuppressDefaultRule() (bool, error) {
-	cmd := exec.Command("ip", "rule", "show")
-	out, err := cmd.CombinedOutput()
+	rules, err := listRules()
 	if err != nil {
-		return false, fmt.Errorf("ip rule show failed: %w: %s", err, strings.TrimSpace(string(out)))
+		return false, err
 	}
-
-	prefix := strconv.Itoa(suppressDefaultRulePriority) + ":"
-	for _, line := range strings.Split(string(out), "\n") {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, prefix) {
-			continue
-		}
-		if strings.Contains(line, suppressDefaultRuleSignature) {
+	for _, r := range rules {
+		if r == suppressDefaultRule {
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-// CleanupAllRules removes all routing rules managed by this application (priority 2000-2032)
-func (m *Manager) CleanupAllRules() error {
-	logrus.Info("Cleaning up all routing rules (priority 2000-2032)")
+// CleanupAllRules removes all routing rules managed by this application (the
+// managed priority range) and returns how many were removed.
+func (m *Manager) CleanupAllRules() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranges := models.CurrentRanges()
+	logrus.Infof("Cleaning up all routing rules (priority %d-%d)", ranges.PriorityMin, ranges.PriorityMax)
 
 	// Get all current routing rules
-	cmd := exec.Command("ip", "rule", "show")
-	output, err := cmd.CombinedOutput()
+	rules, err := listRules()
 	if err != nil {
 		logrus.Warnf("Failed to get current rules for cleanup: %v", err)
-		return err
+		return 0, err
 	}
 
-	// Parse rules and remove those in our managed range
-	lines := strings.Split(string(output), "\n")
+	// Remove those in our managed range
 	removedCount := 0
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Extract priority to check if it's in our managed range (2000-2032)
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
+	for _, r := range rules {
+		if !models.IsManagedPriority(r.Priority) {
 			continue
 		}
+		logrus.Infof("Removing rule during cleanup: from %s, table %d (priority: %d)", r.from(), r.Table, r.Priority)
 
-		priorityStr := strings.TrimSuffix(parts[0], ":")
-		priority, err := strconv.Atoi(priorityStr)
+		err := m.delRule(r)
+		m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonCleanup, r, err)
 		if err != nil {
-			continue // Skip lines that don't have valid priority
+			logrus.Warnf("Failed to remove rule during cleanup: %v", err)
+		} else {
+			removedCount++
 		}
+	}
 
-		// Only remove rules in our managed range (2000-2032)
-		if priority >= 2000 && priority <= 2032 {
-			logrus.Infof("Removing rule during cleanup: %s (priority: %d)", line, priority)
-
-			cmd := exec.Command("ip", "rule", "del", "priority", strconv.Itoa(priority))
-			if err := cmd.Run(); err != nil {
-				logrus.Warnf("Failed to remove rule during cleanup: %v", err)
-			} else {
-				removedCount++
-			}
-		}
+	if err := m.removeClassification(); err != nil {
+		logrus.Warnf("Failed to remove nftables classification: %v", err)
+	}
+	if err := m.syncAccountingLocked(nil); err != nil {
+		logrus.Warnf("Failed to remove nftables accounting: %v", err)
 	}
 
 	logrus.Infof("Cleanup completed: removed %d routing rules", removedCount)
-	return nil
+	return removedCount, nil
 }
 
-// validateSingleRulePerSource validates that there's only one rule per IP/CIDR in the managed priority range
-func (m *Manager) validateSingleRulePerSource() error {
-	cmd := exec.Command("ip", "rule", "show")
-	output, err := cmd.CombinedOutput()
+// CleanupOrphanedTables flushes every numbered routing table that has routes
+// but is neither in keepTables (the providers' tables) nor referenced by any
+// remaining ip rule. main, local and default are never touched. It returns the
+// flushed table IDs.
+func (m *Manager) CleanupOrphanedTables(keepTables map[int]bool) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules, err := listRules()
 	if err != nil {
-		logrus.Warnf("Failed to get current rules for validation: %v", err)
-		return err
+		return nil, err
+	}
+	routes, err := listRoutes(0)
+	if err != nil {
+		return nil, err
 	}
 
-	// Track source IPs and their rules (only for our managed priority range 2000-2032)
-	sourceRules := make(map[string][]string)
-	lines := strings.Split(string(output), "\n")
-
-	// Parse all rules and group by source IP
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	referenced := make(map[int]bool)
+	for _, r := range rules {
+		referenced[r.Table] = true
+	}
+	byTable := make(map[int][]kernelRoute)
+	var tables []int
+	for _, r := range routes {
+		if isSpecialTable(r.Table) || keepTables[r.Table] || referenced[r.Table] {
 			continue
 		}
-
-		// Extract priority to check if it's in our managed range (2000-2032)
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
-			continue
+		if _, seen := byTable[r.Table]; !seen {
+			tables = append(tables, r.Table)
 		}
+		byTable[r.Table] = append(byTable[r.Table], r)
+	}
+	sort.Ints(tables)
 
-		priorityStr := strings.TrimSuffix(parts[0], ":")
-		priority, err := strconv.Atoi(priorityStr)
-		if err != nil {
-			continue // Skip lines that don't have valid priority
+	var flushed []int
+	for _, table := range tables {
+		logrus.Infof("Flushing orphaned routing table %d", table)
+		var failed []string
+		for _, r := range byTable[table] {
+			if err := m.delRoute(r); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", r, err))
+			}
 		}
-
-		// Only process rules in our managed range (2000-2032)
-		if priority < 2000 || priority > 2032 {
+		var err error
+		if len(failed) > 0 {
+			err = fmt.Errorf("failed to remove routes %s", strings.Join(failed, ", "))
+		}
+		m.record(models.JournalEntry{Action: models.JournalTableFlush, Reason: models.JournalReasonOrphanedTable, Table: table}, err)
+		if err != nil {
+			logrus.Warnf("Failed to flush table %d: %v", table, err)
 			continue
 		}
+		flushed = append(flushed, table)
+	}
+	return flushed, nil
+}
 
-		// Extract source IP from the rule
-		if strings.Contains(line, "from") && strings.Contains(line, "lookup") {
-			for i, part := range parts {
-				if part == "from" && i+1 < len(parts) {
-					srcIP := parts[i+1]
-					// Ignore 'from all' system rules
-					if srcIP == "all" {
-						break
-					}
-					sourceRules[srcIP] = append(sourceRules[srcIP], line)
-					break
-				}
-			}
-		}
+// validateSingleRulePerSource validates that there's only one rule per IP/CIDR in the managed priority range
+func (m *Manager) validateSingleRulePerSource() error {
+	rules, err := listRules()
+	if err != nil {
+		logrus.Warnf("Failed to get current rules for validation: %v", err)
+		return err
 	}
 
-	// Check for violations
+	// Check for violations, ignoring 'from all' system rules
 	violations := 0
-	for srcIP, rules := range sourceRules {
-		if len(rules) > 1 {
-			logrus.Warnf("VALIDATION VIOLATION: Found %d rules for source %s:", len(rules), srcIP)
-			for i, rule := range rules {
-				logrus.Warnf("  Rule %d: %s", i+1, rule)
-			}
-			violations++
+	for key, dups := range duplicateRules(rules, true) {
+		logrus.Warnf("VALIDATION VIOLATION: Found %d rules for source %s:", len(dups), key)
+		for i, r := range dups {
+			logrus.Warnf("  Rule %d: priority %d, table %d", i+1, r.Priority, r.Table)
 		}
+		violations++
 	}
 
 	if violations > 0 {
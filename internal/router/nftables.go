@@ -0,0 +1,409 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nftTable is the nftables table (family inet) holding the classification
+// chain. It is replaced as a whole on every change, so nothing else should
+// add to it.
+const nftTable = "router_sync"
+
+// nftCommand is the nft binary used to load the ruleset.
+var nftCommand = "nft"
+
+// markRule is a "fwmark <table> lookup <table>" ip rule routing the packets
+// the classification chain marked for a provider table.
+type markRule struct {
+	Priority int
+	Table    int
+}
+
+// sourceSet is one nftables set of policy sources sharing a family, rule
+// priority and provider table, see sourceSets.
+type sourceSet struct {
+	family   string // "ip" or "ip6"
+	priority int
+	table    int
+	sources  []string
+}
+
+// name is the set's name in the classification table, e.g. src4_p2008_t100.
+func (s sourceSet) name() string {
+	v := "4"
+	if s.family == "ip6" {
+		v = "6"
+	}
+	return fmt.Sprintf("src%s_p%d_t%d", v, s.priority, s.table)
+}
+
+// sourceSets groups the sources of the active policies routed through
+// source sets (RoutingPolicy.InSourceSet) by family, rule priority and
+// provider table, in priority order. Sources within a set are sorted.
+func sourceSets(policies []*models.RoutingPolicy, tableByProvider map[string]int, now time.Time) []sourceSet {
+	type key struct {
+		family   string
+		priority int
+		table    int
+	}
+	byKey := make(map[key]*sourceSet)
+	for _, p := range policies {
+		if !p.InSourceSet(true) || !p.Active(now) {
+			continue
+		}
+		table, ok := tableByProvider[p.ProviderID]
+		if !ok {
+			continue
+		}
+		srcNet, err := p.SourceNet()
+		if err != nil {
+			continue
+		}
+		k := key{family: "ip", priority: p.RulePriority(srcNet), table: table}
+		if srcNet.IP.To4() == nil {
+			k.family = "ip6"
+		}
+		set, ok := byKey[k]
+		if !ok {
+			set = &sourceSet{family: k.family, priority: k.priority, table: k.table}
+			byKey[k] = set
+		}
+		set.sources = append(set.sources, srcNet.String())
+	}
+
+	out := make([]sourceSet, 0, len(byKey))
+	for _, set := range byKey {
+		sort.Strings(set.sources)
+		out = append(out, *set)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].priority != out[j].priority {
+			return out[i].priority < out[j].priority
+		}
+		return out[i].name() < out[j].name()
+	})
+	return out
+}
+
+// compileClassification renders the nftables table for the active classified
+// policies (see RoutingPolicy.Classified) and the mark rules they need. Each
+// matching packet is marked with its provider's table ID. Rules are ordered
+// by ip rule priority and end with accept, so the first match wins as it
+// would between ip rules. Policies whose provider is unknown, or whose source
+// and destination are of different families, are skipped.
+//
+// With sourceSets the plain source policies are added too, as one set per
+// family, priority and table (see sourceSets) matched by a single rule. At
+// equal priority the classified rules come first, as they are the more
+// specific match. Overlapping prefixes in one set are merged, which is safe
+// since they route to the same table.
+func compileClassification(policies []*models.RoutingPolicy, providers []*models.InternetProvider, now time.Time, withSourceSets bool) (string, []markRule) {
+	tableByProvider := make(map[string]int, len(providers))
+	for _, p := range providers {
+		tableByProvider[p.ID] = p.TableID
+	}
+
+	type entry struct {
+		id       string
+		priority int
+		table    int
+		rule     string
+		comment  string
+		set      bool
+	}
+	var entries []entry
+	for _, p := range policies {
+		if !p.Classified() || !p.Active(now) {
+			continue
+		}
+		table, ok := tableByProvider[p.ProviderID]
+		if !ok {
+			continue
+		}
+		srcNet, err := p.SourceNet()
+		if err != nil {
+			continue
+		}
+		rule, ok := nftMatch(p)
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry{id: p.ID, priority: p.RulePriority(srcNet), table: table, rule: rule, comment: "policy " + p.ID})
+	}
+	var sets []sourceSet
+	if withSourceSets {
+		sets = sourceSets(policies, tableByProvider, now)
+	}
+	for _, set := range sets {
+		entries = append(entries, entry{
+			id:       set.name(),
+			priority: set.priority,
+			table:    set.table,
+			rule:     fmt.Sprintf("%s saddr @%s", set.family, set.name()),
+			comment:  fmt.Sprintf("%d sources", len(set.sources)),
+			set:      true,
+		})
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].priority != entries[j].priority {
+			return entries[i].priority < entries[j].priority
+		}
+		if entries[i].set != entries[j].set {
+			return !entries[i].set
+		}
+		return entries[i].id < entries[j].id
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "table inet %s {\n", nftTable)
+	for _, set := range sets {
+		addrType := "ipv4_addr"
+		if set.family == "ip6" {
+			addrType = "ipv6_addr"
+		}
+		fmt.Fprintf(&b, "\tset %s {\n", set.name())
+		fmt.Fprintf(&b, "\t\ttype %s; flags interval; auto-merge;\n", addrType)
+		fmt.Fprintf(&b, "\t\telements = { %s }\n", strings.Join(set.sources, ", "))
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("\tchain classify {\n")
+	b.WriteString("\t\ttype filter hook prerouting priority mangle; policy accept;\n")
+	seen := make(map[markRule]bool)
+	var marks []markRule
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\t\t%s meta mark set %#08x accept comment %q\n", e.rule, e.table, e.comment)
+		if m := (markRule{Priority: e.priority, Table: e.table}); !seen[m] {
+			seen[m] = true
+			marks = append(marks, m)
+		}
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String(), marks
+}
+
+// nftMatch renders the match part of a policy's classification rule, e.g.
+// "ip saddr 10.0.0.0/24 meta l4proto tcp tcp dport { 80, 443 }".
+func nftMatch(p *models.RoutingPolicy) (string, bool) {
+	return nftMatchDir(p, false)
+}
+
+// nftMatchDir renders the match for the policy's traffic (reply false) or
+// for the traffic coming back to its clients (reply true), which has source
+// and destination swapped.
+func nftMatchDir(p *models.RoutingPolicy, reply bool) (string, bool) {
+	srcNet, err := p.SourceNet()
+	if err != nil {
+		return "", false
+	}
+	dstNet, err := p.DestinationNet()
+	if err != nil {
+		return "", false
+	}
+	family := "ip"
+	if srcNet.IP.To4() == nil {
+		family = "ip6"
+	}
+	saddr, daddr, sport, dport := "saddr", "daddr", "sport", "dport"
+	if reply {
+		saddr, daddr, sport, dport = daddr, saddr, dport, sport
+	}
+	parts := []string{family + " " + saddr + " " + srcNet.String()}
+	if dstNet != nil {
+		if (dstNet.IP.To4() == nil) != (family == "ip6") {
+			return "", false
+		}
+		parts = append(parts, family+" "+daddr+" "+dstNet.String())
+	}
+	switch p.Protocol {
+	case "":
+	case models.ProtocolICMPv6:
+		parts = append(parts, "meta l4proto ipv6-icmp")
+	default:
+		parts = append(parts, "meta l4proto "+p.Protocol)
+	}
+	for _, sel := range []struct{ field, ports string }{{sport, p.SourcePorts}, {dport, p.DestinationPorts}} {
+		if sel.ports == "" {
+			continue
+		}
+		ranges, err := models.ParsePorts(sel.ports)
+		if err != nil {
+			return "", false
+		}
+		parts = append(parts, p.Protocol+" "+sel.field+" "+nftPorts(ranges))
+	}
+	return strings.Join(parts, " "), true
+}
+
+func nftPorts(ranges []models.PortRange) string {
+	if len(ranges) == 1 {
+		return ranges[0].String()
+	}
+	items := make([]string, len(ranges))
+	for i, r := range ranges {
+		items[i] = r.String()
+	}
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+// SyncClassification applies the nftables classification for policies; see
+// syncClassificationLocked.
+func (m *Manager) SyncClassification(policies []*models.RoutingPolicy, providers []*models.InternetProvider) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncClassificationLocked(policies, providers)
+}
+
+// syncClassificationLocked loads the classification table for the classified
+// policies, and the source sets with Options.SourceSets (replacing the
+// previous table atomically), and reconciles the mark rules routing what it
+// marks. Without Options.NFTables classified policies are not applied at all,
+// since a plain source rule would route more traffic than they select; each
+// is reported once.
+func (m *Manager) syncClassificationLocked(policies []*models.RoutingPolicy, providers []*models.InternetProvider) error {
+	now := time.Now()
+	if !m.opts.NFTables {
+		for _, p := range policies {
+			if p.Classified() && p.Active(now) && !m.nftWarned[p.ID] {
+				logging.Policy(p.ID, p.ProviderID).Warnf("Policy %s matches on protocol or ports, which needs features.nftables; not applied", p.Name)
+				m.nftWarned[p.ID] = true
+			}
+		}
+		return nil
+	}
+
+	ruleset, marks := compileClassification(policies, providers, now, m.opts.SourceSets)
+	if m.dryRun() {
+		// The mark rules are all a dry run reports
+		return m.syncMarkRules(marks)
+	}
+	if err := m.applyClassification(ruleset); err != nil {
+		return err
+	}
+	if m.opts.SourceSets {
+		m.flushMovedSources(policies, providers, now)
+	}
+	return m.syncMarkRules(marks)
+}
+
+// flushMovedSources clears the conntrack entries of set sources that were
+// added, removed or moved to another table since the last load, as
+// SetupPolicy does when it changes a rule. The first load after a start
+// flushes nothing, so adopting the running state leaves flows alone.
+func (m *Manager) flushMovedSources(policies []*models.RoutingPolicy, providers []*models.InternetProvider, now time.Time) {
+	tableByProvider := make(map[string]int, len(providers))
+	for _, p := range providers {
+		tableByProvider[p.ID] = p.TableID
+	}
+	current := make(map[string]int)
+	for _, set := range sourceSets(policies, tableByProvider, now) {
+		for _, src := range set.sources {
+			current[src] = set.table
+		}
+	}
+	previous := m.nftSetSources
+	m.nftSetSources = current
+	if previous == nil {
+		return
+	}
+
+	moved := make(map[string]bool)
+	for src, table := range current {
+		if previous[src] != table {
+			moved[src] = true
+		}
+	}
+	for src := range previous {
+		if _, ok := current[src]; !ok {
+			moved[src] = true
+		}
+	}
+	for src := range moved {
+		if _, srcNet, err := net.ParseCIDR(src); err == nil {
+			m.clearConntrack(srcNet)
+		}
+	}
+}
+
+// applyClassification replaces the classification table with ruleset (an
+// empty ruleset removes it). The "table" line makes the delete succeed when
+// the table does not exist yet; nft applies the file as one transaction.
+func (m *Manager) applyClassification(ruleset string) error {
+	if m.nftLoaded && ruleset == m.nftRuleset {
+		return nil
+	}
+	script := fmt.Sprintf("table inet %s\ndelete table inet %s\n%s", nftTable, nftTable, ruleset)
+	cmd := exec.Command(nftCommand, "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load nftables classification: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	m.nftLoaded, m.nftRuleset = true, ruleset
+	logrus.Infof("Loaded nftables classification (%d rules)", strings.Count(ruleset, "meta mark set"))
+	return nil
+}
+
+// removeClassification deletes the classification table, if any.
+func (m *Manager) removeClassification() error {
+	if !m.opts.NFTables || m.dryRun() {
+		return nil
+	}
+	m.nftLoaded = false
+	return m.applyClassification("")
+}
+
+// syncMarkRules makes the managed fwmark ip rules match want.
+func (m *Manager) syncMarkRules(want []markRule) error {
+	rules, err := listRules()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[markRule]bool, len(want))
+	for _, r := range want {
+		wanted[r] = true
+	}
+	have := make(map[markRule]bool)
+	for _, kr := range rules {
+		if !models.IsManagedPriority(kr.Priority) || kr.Mark == 0 || kr.IPv6 {
+			continue
+		}
+		r := markRule{Priority: kr.Priority, Table: kr.Table}
+		if wanted[r] && kr.Mark == kr.Table && !have[r] {
+			have[r] = true
+			continue
+		}
+		logrus.Infof("Removing mark rule: priority %d, fwmark %#x, table %d", kr.Priority, kr.Mark, kr.Table)
+		err := m.delRule(kr)
+		m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonClassification, kr, err)
+		if err != nil {
+			logrus.Warnf("Failed to remove mark rule: %v", err)
+		}
+	}
+
+	for _, r := range want {
+		if have[r] {
+			continue
+		}
+		kr := kernelRule{Priority: r.Priority, Mark: r.Table, Table: r.Table}
+		err := m.addRule(kr)
+		m.recordKernelRule(models.JournalRuleAdd, models.JournalReasonClassification, kr, err)
+		if err != nil {
+			return fmt.Errorf("failed to add mark rule for table %d: %w", r.Table, err)
+		}
+		logrus.Infof("Added mark rule: priority %d, fwmark %#x, table %d", r.Priority, r.Table, r.Table)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileClassification(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", TableID: 100},
+		{ID: "isp2", TableID: 200},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "web", SourceIP: "10.0.0.0/24", Protocol: "tcp", DestinationPorts: "80,443", ProviderID: "isp2", Enabled: true},
+		{ID: "dns", SourceIP: "10.0.0.5", Destination: "9.9.9.9", Protocol: "udp", DestinationPorts: "53", ProviderID: "isp1", Enabled: true},
+		{ID: "ping", SourceIP: "fd00::/64", Protocol: "icmpv6", ProviderID: "isp1", Enabled: true},
+		{ID: "plain", SourceIP: "10.0.0.6", ProviderID: "isp1", Enabled: true},
+		{ID: "off", SourceIP: "10.0.0.7", Protocol: "tcp", ProviderID: "isp1", Enabled: false},
+		{ID: "orphan", SourceIP: "10.0.0.8", Protocol: "tcp", ProviderID: "gone", Enabled: true},
+	}
+
+	ruleset, marks := compileClassification(policies, providers, time.Now(), false)
+
+	assert.Contains(t, ruleset, "table inet router_sync {")
+	assert.Contains(t, ruleset, "type filter hook prerouting priority mangle; policy accept;")
+	assert.Contains(t, ruleset, `ip saddr 10.0.0.0/24 meta l4proto tcp tcp dport { 80, 443 } meta mark set 0x000000c8 accept comment "policy web"`)
+	assert.Contains(t, ruleset, `ip saddr 10.0.0.5/32 ip daddr 9.9.9.9/32 meta l4proto udp udp dport 53 meta mark set 0x00000064 accept comment "policy dns"`)
+	assert.Contains(t, ruleset, `ip6 saddr fd00::/64 meta l4proto ipv6-icmp meta mark set 0x00000064`)
+	assert.NotContains(t, ruleset, "plain")
+	assert.NotContains(t, ruleset, "off")
+	assert.NotContains(t, ruleset, "orphan")
+
+	// The /32 policy sorts before the /24 one, as its ip rule would
+	assert.Less(t, strings.Index(ruleset, "policy dns"), strings.Index(ruleset, "policy web"))
+	assert.Len(t, marks, 3)
+}
+
+func TestCompileClassificationEmpty(t *testing.T) {
+	ruleset, marks := compileClassification([]*models.RoutingPolicy{
+		{ID: "plain", SourceIP: "10.0.0.6", ProviderID: "isp1", Enabled: true},
+	}, []*models.InternetProvider{{ID: "isp1", TableID: 100}}, time.Now(), false)
+	assert.Empty(t, ruleset)
+	assert.Empty(t, marks)
+}
+
+func TestCompileClassificationSourceSets(t *testing.T) {
+	providers := []*models.InternetProvider{
+		{ID: "isp1", TableID: 100},
+		{ID: "isp2", TableID: 200},
+	}
+	policies := []*models.RoutingPolicy{
+		{ID: "a", SourceIP: "10.0.1.0/24", ProviderID: "isp1", Enabled: true},
+		{ID: "b", SourceIP: "10.0.2.0/24", ProviderID: "isp1", Enabled: true},
+		{ID: "c", SourceIP: "10.0.3.0/24", ProviderID: "isp2", Enabled: true},
+		{ID: "host", SourceIP: "10.0.1.5", ProviderID: "isp2", Enabled: true},
+		{ID: "v6", SourceIP: "fd00::/64", ProviderID: "isp1", Enabled: true},
+		{ID: "web", SourceIP: "10.0.1.0/24", Protocol: "tcp", DestinationPorts: "443", ProviderID: "isp2", Enabled: true},
+		{ID: "dst", SourceIP: "10.0.4.0/24", Destination: "9.9.9.9", ProviderID: "isp1", Enabled: true},
+		{ID: "strict", SourceIP: "10.0.5.0/24", Mode: models.PolicyModeStrict, ProviderID: "isp1", Enabled: true},
+		{ID: "off", SourceIP: "10.0.6.0/24", ProviderID: "isp1", Enabled: false},
+	}
+
+	ruleset, marks := compileClassification(policies, providers, time.Now(), true)
+
+	p24, p32 := models.RulePriority(&net.IPNet{Mask: net.CIDRMask(24, 32)}), models.RulePriority(&net.IPNet{Mask: net.CIDRMask(32, 32)})
+	set24 := fmt.Sprintf("src4_p%d_t100", p24)
+	assert.Contains(t, ruleset, "\tset "+set24+" {\n\t\ttype ipv4_addr; flags interval; auto-merge;\n\t\telements = { 10.0.1.0/24, 10.0.2.0/24 }\n")
+	assert.Contains(t, ruleset, "elements = { 10.0.1.5/32 }")
+	assert.Contains(t, ruleset, "type ipv6_addr; flags interval; auto-merge;")
+	assert.Contains(t, ruleset, fmt.Sprintf(`ip saddr @%s meta mark set 0x00000064 accept comment "2 sources"`, set24))
+	for _, skipped := range []string{"10.0.4.0", "10.0.5.0", "10.0.6.0"} {
+		assert.NotContains(t, ruleset, skipped)
+	}
+
+	// Hosts before /24s, and the classified policy before the set at its priority
+	host := strings.Index(ruleset, fmt.Sprintf("@src4_p%d_t200", p32))
+	web := strings.Index(ruleset, "policy web")
+	set := strings.Index(ruleset, "@"+set24)
+	assert.Less(t, host, web)
+	assert.Less(t, web, set)
+
+	assert.Contains(t, marks, markRule{Priority: p24, Table: 100})
+	assert.Contains(t, marks, markRule{Priority: p24, Table: 200})
+	assert.Contains(t, marks, markRule{Priority: p32, Table: 200})
+}
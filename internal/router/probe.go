@@ -0,0 +1,58 @@
+package router
+
+import (
+	"fmt"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Health probes (internal/health) leave through the provider they test, not
+// the main table's default route: each probed provider gets a firewall mark
+// (models.ProbeMarkBase + n) that its probe sockets set, and a rule at
+// models.ProbeRulePriority, ahead of the suppress-default rule, looks marked
+// packets up in the provider's table. Only probe sockets carry these marks.
+
+// SyncProbeRules makes the probe rules match marks (mark -> table); nil
+// removes them all.
+func (m *Manager) SyncProbeRules(marks map[int]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules, err := listRules()
+	if err != nil {
+		return err
+	}
+
+	have := make(map[int]bool)
+	for _, r := range rules {
+		if r.Priority != models.ProbeRulePriority || r.Mark == 0 || r.IPv6 {
+			continue
+		}
+		if want, ok := marks[r.Mark]; ok && want == r.Table && !have[r.Mark] {
+			have[r.Mark] = true
+			continue
+		}
+		logrus.Infof("Removing probe rule: fwmark %#x, table %d", r.Mark, r.Table)
+		err := m.delRule(r)
+		m.recordKernelRule(models.JournalRuleDelete, models.JournalReasonProbe, r, err)
+		if err != nil {
+			logrus.Warnf("Failed to remove probe rule: %v", err)
+		}
+	}
+
+	for mark, table := range marks {
+		if have[mark] {
+			continue
+		}
+		r := kernelRule{Priority: models.ProbeRulePriority, Mark: mark, Table: table}
+		err := m.addRule(r)
+		m.recordKernelRule(models.JournalRuleAdd, models.JournalReasonProbe, r, err)
+		if err != nil {
+			return fmt.Errorf("failed to add probe rule for table %d: %w", table, err)
+		}
+		logrus.Infof("Added probe rule: fwmark %#x, table %d", mark, table)
+	}
+	return nil
+}
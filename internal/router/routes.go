@@ -0,0 +1,120 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"router-sync/internal/models"
+)
+
+// kernelRoute is a route in a provider table as the manager reads and writes
+// it over netlink (see routes_linux.go).
+type kernelRoute struct {
+	Table int
+	// Dst is the destination, nil for the default route.
+	Dst *net.IPNet
+	// Gw is the gateway, nil for routes without one.
+	Gw        net.IP
+	LinkIndex int
+	// Priority is the route metric.
+	Priority int
+	// OnLink makes the gateway reachable on the interface even outside
+	// its subnets (ip route ... onlink), as uplinks often need.
+	OnLink bool
+	// Protocol is the route's proto; the agent tags its own routes with
+	// router.route_protocol.
+	Protocol int
+}
+
+// isDefault reports whether r is a default route of either family.
+func (r kernelRoute) isDefault() bool {
+	if r.Dst == nil {
+		return true
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0
+}
+
+// matches reports whether r is the default route want asks for: same table,
+// gateway and interface. The metric and protocol are ignored, so a route the
+// host networking installed satisfies the provider.
+func (r kernelRoute) matches(want kernelRoute) bool {
+	return r.isDefault() && r.Table == want.Table && r.LinkIndex == want.LinkIndex && r.Gw.Equal(want.Gw)
+}
+
+// String describes the route for the journal, e.g. "default via 192.0.2.1"
+// or "198.51.100.0/24".
+func (r kernelRoute) String() string {
+	s := "default"
+	if !r.isDefault() {
+		s = r.Dst.String()
+	}
+	if r.Gw != nil {
+		s += " via " + r.Gw.String()
+	}
+	return s
+}
+
+// providerRoutes returns the routes provider wants in its table: a default
+// route via each of its gateways out of the interface carrying that family,
+// with index link for IPv4 and linkV6 for IPv6, tagged with the configured
+// route protocol. IPv4 gateways are onlink, as uplink gateways are often
+// outside the addresses DHCP hands out.
+func providerRoutes(provider *models.InternetProvider, link, linkV6 int) ([]kernelRoute, error) {
+	protocol := models.CurrentRanges().RouteProtocol
+	var routes []kernelRoute
+	for _, gw := range provider.Gateways() {
+		ip := net.ParseIP(gw)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid gateway %q for provider %s", gw, provider.Name)
+		}
+		r := kernelRoute{Table: provider.TableID, Gw: ip, LinkIndex: link, OnLink: true, Protocol: protocol}
+		if ip.To4() == nil {
+			r.LinkIndex, r.OnLink = linkV6, false
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// isSpecialTable reports whether table is unspec, default, main or local,
+// which the manager never flushes.
+func isSpecialTable(table int) bool {
+	return table == 0 || (table >= 253 && table <= 255)
+}
+
+// ProviderErrors is returned by SyncProviders when some providers could not
+// be set up, by provider ID.
+type ProviderErrors map[string]error
+
+func (e ProviderErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf("provider %s: %v", id, e[id]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// PolicyErrors is returned by SyncPolicies when some policies could not be
+// set up, or taken off their down provider, by policy ID.
+type PolicyErrors map[string]error
+
+func (e PolicyErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf("policy %s: %v", id, e[id]))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package router
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// listRoutes returns the unicast routes of both families in table, or in
+// every table for 0. netlink.RouteList alone would only return the main
+// table.
+func listRoutes(table int) ([]kernelRoute, error) {
+	routes, err := netlink.RouteListFiltered(unix.AF_UNSPEC, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+	out := make([]kernelRoute, 0, len(routes))
+	for _, r := range routes {
+		if r.Type != unix.RTN_UNICAST {
+			continue
+		}
+		out = append(out, kernelRoute{
+			Table:     r.Table,
+			Dst:       r.Dst,
+			Gw:        r.Gw,
+			LinkIndex: r.LinkIndex,
+			Priority:  r.Priority,
+			OnLink:    r.Flags&int(netlink.FLAG_ONLINK) != 0,
+			Protocol:  r.Protocol,
+		})
+	}
+	return out, nil
+}
+
+// linkIndex returns the index of the interface called name.
+func linkIndex(name string) (int, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find interface %s: %w", name, err)
+	}
+	return link.Attrs().Index, nil
+}
+
+// replaceRoute installs r, replacing the route with the same destination and
+// metric in its table if any.
+func replaceRoute(r kernelRoute) error {
+	return netlink.RouteReplace(netlinkRoute(r))
+}
+
+// delRoute removes r.
+func delRoute(r kernelRoute) error {
+	return netlink.RouteDel(netlinkRoute(r))
+}
+
+func netlinkRoute(r kernelRoute) *netlink.Route {
+	route := &netlink.Route{
+		Table:     r.Table,
+		Dst:       r.Dst,
+		Gw:        r.Gw,
+		LinkIndex: r.LinkIndex,
+		Priority:  r.Priority,
+		Protocol:  r.Protocol,
+	}
+	if r.OnLink {
+		route.Flags = int(netlink.FLAG_ONLINK)
+	}
+	return route
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package router
+
+import "errors"
+
+// errRoutesUnsupported is returned off Linux, which has no provider tables
+// to manage.
+var errRoutesUnsupported = errors.New("provider routes are only supported on Linux")
+
+func listRoutes(int) ([]kernelRoute, error) { return nil, errRoutesUnsupported }
+
+func linkIndex(string) (int, error) { return 0, errRoutesUnsupported }
+
+func replaceRoute(kernelRoute) error { return errRoutesUnsupported }
+
+func delRoute(kernelRoute) error { return errRoutesUnsupported }
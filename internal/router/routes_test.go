@@ -0,0 +1,60 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"router-sync/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRoutes(t *testing.T) {
+	provider := &models.InternetProvider{Name: "isp1", TableID: 100, Gateway: "192.0.2.1", GatewayV6: "fe80::1"}
+	got, err := providerRoutes(provider, 3, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []kernelRoute{
+		{Table: 100, Gw: net.ParseIP("192.0.2.1"), LinkIndex: 3, OnLink: true, Protocol: 241},
+		{Table: 100, Gw: net.ParseIP("fe80::1"), LinkIndex: 4, Protocol: 241},
+	}, got)
+
+	provider.Gateway = "not-an-ip"
+	_, err = providerRoutes(provider, 3, 4)
+	assert.Error(t, err)
+}
+
+func TestKernelRouteMatches(t *testing.T) {
+	want := kernelRoute{Table: 100, Gw: net.ParseIP("192.0.2.1"), LinkIndex: 3}
+
+	assert.True(t, kernelRoute{Table: 100, Gw: net.ParseIP("192.0.2.1").To4(), LinkIndex: 3, Priority: 100, Protocol: 4}.matches(want), "metric and protocol are ignored")
+	assert.True(t, kernelRoute{Table: 100, Dst: mustNet(t, "0.0.0.0/0"), Gw: net.ParseIP("192.0.2.1"), LinkIndex: 3}.matches(want))
+	assert.False(t, kernelRoute{Table: 100, Gw: net.ParseIP("192.0.2.254"), LinkIndex: 3}.matches(want), "other gateway")
+	assert.False(t, kernelRoute{Table: 100, Gw: net.ParseIP("192.0.2.1"), LinkIndex: 4}.matches(want), "other interface")
+	assert.False(t, kernelRoute{Table: 100, Dst: mustNet(t, "192.0.2.0/24"), LinkIndex: 3}.matches(want), "not a default route")
+}
+
+func TestKernelRouteString(t *testing.T) {
+	assert.Equal(t, "default via 192.0.2.1", kernelRoute{Gw: net.ParseIP("192.0.2.1")}.String())
+	assert.Equal(t, "default via fe80::1", kernelRoute{Dst: mustNet(t, "::/0"), Gw: net.ParseIP("fe80::1")}.String())
+	assert.Equal(t, "198.51.100.0/24", kernelRoute{Dst: mustNet(t, "198.51.100.0/24")}.String())
+}
+
+func TestProviderErrors(t *testing.T) {
+	var err error = ProviderErrors{"lte": errors.New("no such interface"), "dsl": errors.New("network is unreachable")}
+	assert.Equal(t, "provider dsl: network is unreachable; provider lte: no such interface", err.Error())
+
+	var failed ProviderErrors
+	require.True(t, errors.As(err, &failed))
+	assert.Len(t, failed, 2)
+}
+
+func TestPolicyErrors(t *testing.T) {
+	var err error = PolicyErrors{"guests": errors.New("file exists")}
+	assert.Equal(t, "policy guests: file exists", err.Error())
+
+	var failed PolicyErrors
+	require.True(t, errors.As(err, &failed))
+	assert.Len(t, failed, 1)
+}
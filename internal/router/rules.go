@@ -0,0 +1,87 @@
+package router
+
+import (
+	"net"
+)
+
+// kernelRule is a policy routing rule as the manager reads and writes it over
+// netlink (see rules_linux.go): only the selectors and actions router-sync
+// uses. Rules are compared field by field rather than through `ip rule show`
+// text, so host selectors, families and actions are never ambiguous.
+type kernelRule struct {
+	Priority int
+	// IPv6 is the rule's family; rules with a source or destination take it
+	// from there, "from all" rules must set it.
+	IPv6 bool
+	// Src and Dst are the from and to selectors, nil for "all".
+	Src, Dst *net.IPNet
+	// Mark is the fwmark selector, 0 for none.
+	Mark int
+	// Table is the table looked up, 0 for action rules.
+	Table int
+	// Action is "blackhole", "unreachable" or "prohibit" for action rules,
+	// "" for lookup rules.
+	Action string
+	// SuppressDefault is set for "suppress_prefixlength 0" rules.
+	SuppressDefault bool
+}
+
+// key identifies the rule of one policy by its selectors, see ruleKey.
+func (r kernelRule) key() string {
+	return ruleKey(r.Src, r.Dst)
+}
+
+// from returns the from selector as ip prints it, "all" for none.
+func (r kernelRule) from() string {
+	if r.Src == nil {
+		return "all"
+	}
+	return r.Src.String()
+}
+
+// family returns the rule's family: the one of its selectors, IPv6 for
+// "from all" rules only when set.
+func (r kernelRule) family() (v6 bool) {
+	for _, n := range []*net.IPNet{r.Src, r.Dst} {
+		if n != nil {
+			return n.IP.To4() == nil
+		}
+	}
+	return r.IPv6
+}
+
+// ruleKey identifies the rule of one policy by its source and optional
+// destination network, e.g. "192.168.2.25/32 to 203.0.113.0/24".
+func ruleKey(srcNet, dstNet *net.IPNet) string {
+	key := netString(srcNet)
+	if dstNet != nil {
+		key += " to " + dstNet.String()
+	}
+	return key
+}
+
+// maskedNet returns n with its host bits cleared, so that selectors read from
+// the kernel compare equal to the policies' networks.
+func maskedNet(n *net.IPNet) *net.IPNet {
+	if n == nil {
+		return nil
+	}
+	ip := n.IP.Mask(n.Mask)
+	if ip == nil {
+		return n
+	}
+	return &net.IPNet{IP: ip, Mask: n.Mask}
+}
+
+// netString returns n in CIDR notation, or "" for nil.
+func netString(n *net.IPNet) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+// lookupRule returns the lookup rule of a policy with the given selectors.
+func lookupRule(priority, table int, srcNet, dstNet *net.IPNet) kernelRule {
+	return kernelRule{Priority: priority, Table: table, Src: srcNet, Dst: dstNet}
+}
@@ -0,0 +1,165 @@
+//go:build linux
+
+package router
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// ruleActions names the rule actions (rtmsg type) router-sync tells apart
+// from lookups.
+var ruleActions = map[uint8]string{
+	nl.FR_ACT_BLACKHOLE:   "blackhole",
+	nl.FR_ACT_UNREACHABLE: "unreachable",
+	nl.FR_ACT_PROHIBIT:    "prohibit",
+}
+
+// listRules returns the IPv4 and, when the kernel has IPv6, the IPv6 rules.
+// The dump is read directly rather than through netlink.RuleList, which
+// drops the rule action and so cannot tell a blackhole rule from a lookup.
+func listRules() ([]kernelRule, error) {
+	rules, err := dumpRules(unix.AF_INET)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+	if v6, err := dumpRules(unix.AF_INET6); err == nil {
+		rules = append(rules, v6...)
+	}
+	return rules, nil
+}
+
+func dumpRules(family int) ([]kernelRule, error) {
+	req := nl.NewNetlinkRequest(unix.RTM_GETRULE, unix.NLM_F_DUMP)
+	req.AddData(nl.NewIfInfomsg(family))
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWRULE)
+	if err != nil {
+		return nil, err
+	}
+
+	native := nl.NativeEndian()
+	rules := make([]kernelRule, 0, len(msgs))
+	for _, m := range msgs {
+		msg := nl.DeserializeRtMsg(m)
+		attrs, err := nl.ParseRouteAttr(m[msg.Len():])
+		if err != nil {
+			return nil, err
+		}
+		r := kernelRule{
+			IPv6:   msg.Family == unix.AF_INET6,
+			Table:  int(msg.Table),
+			Action: ruleActions[msg.Type],
+		}
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case nl.FRA_PRIORITY:
+				r.Priority = int(native.Uint32(a.Value[0:4]))
+			case nl.FRA_TABLE:
+				r.Table = int(native.Uint32(a.Value[0:4]))
+			case nl.FRA_SRC:
+				r.Src = maskedNet(&net.IPNet{IP: a.Value, Mask: net.CIDRMask(int(msg.Src_len), 8*len(a.Value))})
+			case nl.FRA_DST:
+				r.Dst = maskedNet(&net.IPNet{IP: a.Value, Mask: net.CIDRMask(int(msg.Dst_len), 8*len(a.Value))})
+			case nl.FRA_FWMARK:
+				r.Mark = int(native.Uint32(a.Value[0:4]))
+			case nl.FRA_SUPPRESS_PREFIXLEN:
+				r.SuppressDefault = native.Uint32(a.Value[0:4]) == 0
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// addRule installs r. Lookup rules go through netlink.RuleAdd, which can only
+// add those; action rules are built here.
+func addRule(r kernelRule) error {
+	if r.Action != "" {
+		return actionRuleRequest(unix.RTM_NEWRULE, unix.NLM_F_CREATE|unix.NLM_F_EXCL|unix.NLM_F_ACK, r)
+	}
+	return netlink.RuleAdd(netlinkRule(r))
+}
+
+// delRule removes r, matching its selectors, table and action exactly: a
+// lookup rule and a blackhole rule with the same priority and selectors are
+// deleted one at a time.
+func delRule(r kernelRule) error {
+	if r.Action != "" {
+		return actionRuleRequest(unix.RTM_DELRULE, unix.NLM_F_ACK, r)
+	}
+	return netlink.RuleDel(netlinkRule(r))
+}
+
+func netlinkRule(r kernelRule) *netlink.Rule {
+	rule := netlink.NewRule()
+	rule.Family = ruleFamily(r)
+	rule.Priority = r.Priority
+	rule.Table = r.Table
+	rule.Src = r.Src
+	rule.Dst = r.Dst
+	if r.Mark != 0 {
+		rule.Mark = r.Mark
+	}
+	if r.SuppressDefault {
+		rule.SuppressPrefixlen = 0
+	}
+	return rule
+}
+
+func ruleFamily(r kernelRule) int {
+	if r.family() {
+		return unix.AF_INET6
+	}
+	return unix.AF_INET
+}
+
+// actionRuleRequest sends an add or delete of the action rule r.
+func actionRuleRequest(proto, flags int, r kernelRule) error {
+	var action uint8
+	for t, name := range ruleActions {
+		if name == r.Action {
+			action = t
+		}
+	}
+	if action == 0 {
+		return fmt.Errorf("unsupported rule action %q", r.Action)
+	}
+
+	req := nl.NewNetlinkRequest(proto, flags)
+	msg := nl.NewRtMsg()
+	msg.Family = uint8(ruleFamily(r))
+	msg.Protocol = unix.RTPROT_BOOT
+	msg.Scope = unix.RT_SCOPE_UNIVERSE
+	msg.Table = unix.RT_TABLE_UNSPEC
+	msg.Type = action
+
+	addr := func(n *net.IPNet) []byte {
+		if msg.Family == unix.AF_INET6 {
+			return n.IP.To16()
+		}
+		return n.IP.To4()
+	}
+	var attrs []*nl.RtAttr
+	if r.Src != nil {
+		ones, _ := r.Src.Mask.Size()
+		msg.Src_len = uint8(ones)
+		attrs = append(attrs, nl.NewRtAttr(nl.FRA_SRC, addr(r.Src)))
+	}
+	if r.Dst != nil {
+		ones, _ := r.Dst.Mask.Size()
+		msg.Dst_len = uint8(ones)
+		attrs = append(attrs, nl.NewRtAttr(nl.FRA_DST, addr(r.Dst)))
+	}
+	req.AddData(msg)
+	for _, a := range attrs {
+		req.AddData(a)
+	}
+	req.AddData(nl.NewRtAttr(nl.FRA_PRIORITY, nl.Uint32Attr(uint32(r.Priority))))
+
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package router
+
+import "errors"
+
+// errRulesUnsupported is returned off Linux, which has no policy routing
+// rules to manage.
+var errRulesUnsupported = errors.New("ip rules are only supported on Linux")
+
+func listRules() ([]kernelRule, error) { return nil, errRulesUnsupported }
+
+func addRule(kernelRule) error { return errRulesUnsupported }
+
+func delRule(kernelRule) error { return errRulesUnsupported }
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustNet(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRuleKey(t *testing.T) {
+	src := mustNet(t, "192.168.2.25/32")
+	dst := mustNet(t, "203.0.113.0/24")
+
+	assert.Equal(t, "192.168.2.25/32", ruleKey(src, nil))
+	assert.Equal(t, "192.168.2.25/32 to 203.0.113.0/24", ruleKey(src, dst))
+	// Prefixes sharing their address are different selectors.
+	assert.NotEqual(t, ruleKey(mustNet(t, "192.168.2.0/24"), nil), ruleKey(mustNet(t, "192.168.2.0/25"), nil))
+
+	// Selectors read from the kernel may carry host bits and 16-byte IPv4.
+	kernel := &net.IPNet{IP: net.ParseIP("192.168.2.25"), Mask: net.CIDRMask(32, 32)}
+	assert.Equal(t, ruleKey(src, dst), kernelRule{Src: maskedNet(kernel), Dst: dst}.key())
+}
+
+func TestKernelRuleFamily(t *testing.T) {
+	assert.False(t, kernelRule{Src: mustNet(t, "10.0.0.0/24")}.family())
+	assert.True(t, kernelRule{Src: mustNet(t, "2001:db8::/64")}.family())
+	assert.True(t, kernelRule{Dst: mustNet(t, "2001:db8::/64")}.family())
+	assert.False(t, kernelRule{Mark: 100, Table: 100}.family())
+	assert.True(t, kernelRule{Mark: 100, Table: 100, IPv6: true}.family())
+	assert.Equal(t, "all", kernelRule{Mark: 100}.from())
+}
+
+func TestFindBlackholeRules(t *testing.T) {
+	src := mustNet(t, "192.168.2.25/32")
+	dst := mustNet(t, "203.0.113.0/24")
+	rules := []kernelRule{
+		lookupRule(2000, 99, src, nil),
+		blackholeRule(2000, src, nil),
+		blackholeRule(2000, src, dst),
+		blackholeRule(2008, mustNet(t, "192.168.3.0/24"), nil),
+	}
+	assert.Equal(t, []int{2000}, findBlackholeRules(rules, src, nil))
+	assert.Equal(t, []int{2000}, findBlackholeRules(rules, src, dst))
+	assert.Empty(t, findBlackholeRules(rules, mustNet(t, "192.168.4.0/24"), nil))
+}
+
+func TestDuplicateRules(t *testing.T) {
+	src := mustNet(t, "192.168.2.25/32")
+	dst := mustNet(t, "203.0.113.0/24")
+	rules := []kernelRule{
+		lookupRule(2000, 99, src, nil),
+		lookupRule(2000, 100, src, nil),
+		lookupRule(2000, 99, src, dst),
+		blackholeRule(2000, src, nil),
+		{Priority: 2000, Mark: 99, Table: 99},
+		{Priority: 2000, Mark: 100, Table: 100},
+		{Priority: 2010, Table: 99},
+		{Priority: 2012, Table: 100},
+		lookupRule(100, 99, src, nil),
+	}
+
+	dups := duplicateRules(rules, false)
+	assert.Len(t, dups, 2)
+	assert.Equal(t, []kernelRule{rules[0], rules[1]}, dups[ruleKey(src, nil)])
+	assert.Len(t, dups[""], 2, "from all rules")
+
+	dups = duplicateRules(rules, true)
+	assert.Len(t, dups, 1)
+	assert.Contains(t, dups, ruleKey(src, nil))
+}
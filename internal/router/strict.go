@@ -0,0 +1,142 @@
+package router
+
+import (
+	"fmt"
+	"net"
+
+	"router-sync/internal/logging"
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// A strict policy (models.PolicyModeStrict) gets a blackhole rule with the
+// same selector and priority as its lookup rule, added after it: the kernel
+// keeps rules of equal priority in insertion order, so packets the provider
+// table cannot route are dropped there instead of falling through to less
+// specific policies or the main table. Whenever the lookup rule is
+// (re)installed the blackhole rule is re-added behind it.
+
+// findBlackholeRules returns the priorities of the blackhole rules for a
+// selector.
+func findBlackholeRules(rules []kernelRule, srcNet, dstNet *net.IPNet) []int {
+	key := ruleKey(srcNet, dstNet)
+	var priorities []int
+	for _, r := range rules {
+		if r.Action == models.RuleActionBlackhole && r.Src != nil && r.key() == key {
+			priorities = append(priorities, r.Priority)
+		}
+	}
+	return priorities
+}
+
+// syncBlackholeRule makes the blackhole rule for a selector match want: one
+// rule at priority when want is set, none otherwise. reinstall forces an
+// existing rule to be re-added, which keeps it behind a lookup rule that was
+// just added at the same priority.
+func (m *Manager) syncBlackholeRule(srcNet, dstNet *net.IPNet, priority int, want, reinstall bool) error {
+	rules, err := listRules()
+	if err != nil {
+		return err
+	}
+
+	selector := models.RuleKeyFor(srcNet, dstNet)
+	have := false
+	for _, p := range findBlackholeRules(rules, srcNet, dstNet) {
+		if want && p == priority && !have && !reinstall {
+			have = true
+			continue
+		}
+		err := m.delRule(blackholeRule(p, srcNet, dstNet))
+		m.recordBlackhole(models.JournalRuleDelete, p, srcNet, dstNet, err)
+		if err != nil {
+			logrus.Warnf("Failed to remove blackhole rule for %s: %v", selector, err)
+			continue
+		}
+		logrus.Infof("Removed blackhole rule for %s (priority: %d)", selector, p)
+	}
+	if !want || have {
+		return nil
+	}
+
+	err = m.addRule(blackholeRule(priority, srcNet, dstNet))
+	m.recordBlackhole(models.JournalRuleAdd, priority, srcNet, dstNet, err)
+	if err != nil {
+		return fmt.Errorf("failed to add blackhole rule for %s: %w", selector, err)
+	}
+	logrus.Infof("Added blackhole rule: priority %d, %s", priority, selector)
+	return nil
+}
+
+// blackholeRule returns the blackhole rule of a strict policy.
+func blackholeRule(priority int, srcNet, dstNet *net.IPNet) kernelRule {
+	return kernelRule{Priority: priority, Src: srcNet, Dst: dstNet, Action: models.RuleActionBlackhole}
+}
+
+// recordBlackhole journals a blackhole rule add or delete.
+func (m *Manager) recordBlackhole(action string, priority int, srcNet, dstNet *net.IPNet, err error) {
+	m.record(models.JournalEntry{
+		Action:      action,
+		Reason:      models.JournalReasonStrict,
+		Priority:    priority,
+		Source:      srcNet.String(),
+		Destination: netString(dstNet),
+		RuleAction:  models.RuleActionBlackhole,
+	}, err)
+}
+
+// ApplyProviderDown takes policy off its provider while that provider is
+// down and no backup can take it: a best-effort policy loses its rules so
+// traffic falls through, a strict one keeps only its blackhole rule so
+// traffic is dropped. SetupPolicy restores the normal rules.
+func (m *Manager) ApplyProviderDown(policy *models.RoutingPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.applyProviderDownLocked(policy)
+}
+
+// applyProviderDownLocked is ApplyProviderDown for callers holding m.mu.
+func (m *Manager) applyProviderDownLocked(policy *models.RoutingPolicy) error {
+	if policy.MarkRouted(m.opts.SourceSets) {
+		return nil
+	}
+	srcNet, err := policy.SourceNet()
+	if err != nil {
+		return err
+	}
+	dstNet, err := policy.DestinationNet()
+	if err != nil {
+		return err
+	}
+
+	// Full syncs repeat this while the provider stays down; only the first
+	// call finds a lookup rule to remove
+	exists, priority, table := m.checkRoutingRuleExists(srcNet, dstNet)
+	if !policy.Strict() {
+		if !exists {
+			return nil
+		}
+		logging.Policy(policy.ID, policy.ProviderID).Infof("Provider down: policy %s falls through to the default route", policy.Name)
+		if err := m.removeAllRulesForSource(srcNet, dstNet, models.JournalReasonProviderDown); err != nil {
+			return err
+		}
+		if err := m.clearConntrack(srcNet); err != nil {
+			logrus.Warnf("Failed to clear conntrack entries for %s: %v", srcNet.String(), err)
+		}
+		return nil
+	}
+	if exists {
+		logging.Policy(policy.ID, policy.ProviderID).Infof("Provider down: blackholing traffic of strict policy %s", policy.Name)
+		err := m.delRule(lookupRule(priority, table, srcNet, dstNet))
+		m.recordRule(models.JournalRuleDelete, models.JournalReasonProviderDown, priority, table, srcNet.String(), netString(dstNet), err)
+		if err != nil {
+			m.count(func(c *Counters) { c.RuleFailures++ })
+			return fmt.Errorf("failed to remove routing rule for policy %s: %w", policy.Name, err)
+		}
+		m.count(func(c *Counters) { c.RulesRemoved++ })
+		if err := m.clearConntrack(srcNet); err != nil {
+			logrus.Warnf("Failed to clear conntrack entries for %s: %v", srcNet.String(), err)
+		}
+	}
+	return m.syncBlackholeRule(srcNet, dstNet, policy.RulePriority(srcNet), true, false)
+}
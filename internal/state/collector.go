@@ -39,13 +39,17 @@ func (c *Collector) SetTableNames(names map[int]string) {
 
 // collectRules parses `ip rule show` (the same path the manager uses) and is
 // reused on all platforms because exec.Command compiles everywhere even though
-// the binary itself only exists on Linux at runtime.
+// the binary itself only exists on Linux at runtime. IPv6 rules are listed
+// separately and skipped when the kernel has no IPv6.
 func (c *Collector) collectRules() ([]models.IPRule, error) {
 	cmd := exec.Command("ip", "rule", "show")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("ip rule show failed: %w", err)
 	}
+	if v6, err := exec.Command("ip", "-6", "rule", "show").Output(); err == nil {
+		out = append(out, v6...)
+	}
 
 	var rules []models.IPRule
 	for _, line := range strings.Split(string(out), "\n") {
@@ -63,9 +67,13 @@ func (c *Collector) collectRules() ([]models.IPRule, error) {
 	return rules, nil
 }
 
-// parseIPRule extracts priority, source CIDR and table from an `ip rule show` line, e.g.:
+// parseIPRule extracts priority, source and destination CIDR, fwmark, table and action from an `ip rule show` line, e.g.:
 //
 //	"100: from 192.168.2.25 lookup 99"
+//	"100: from 192.168.2.25 to 203.0.113.0/24 lookup 99"
+//	"2000: from all fwmark 0x64 lookup 100"
+//	"2000: from 192.168.2.25 blackhole"
+//	"10: from all lookup main suppress_prefixlength 0"
 //	"32766: from all lookup main"
 func parseIPRule(line string) (models.IPRule, bool) {
 	parts := strings.Fields(line)
@@ -87,10 +95,29 @@ func parseIPRule(line string) (models.IPRule, bool) {
 			if i+1 < len(parts) {
 				rule.From = parts[i+1]
 			}
+		case "to":
+			if i+1 < len(parts) {
+				rule.To = parts[i+1]
+			}
+		case "fwmark":
+			if i+1 < len(parts) {
+				value, _, _ := strings.Cut(parts[i+1], "/")
+				if n, err := strconv.ParseInt(value, 0, 64); err == nil {
+					rule.FwMark = int(n)
+				}
+			}
 		case "lookup":
 			if i+1 < len(parts) {
 				rule.Table = lookupTableID(parts[i+1])
 			}
+		case "blackhole", "unreachable", "prohibit":
+			rule.Action = p
+		case "suppress_prefixlength":
+			if i+1 < len(parts) {
+				if n, err := strconv.Atoi(parts[i+1]); err == nil {
+					rule.SuppressPrefixLength = &n
+				}
+			}
 		}
 	}
 
@@ -55,10 +55,17 @@ func (c *Collector) collectInterfaces() ([]models.Interface, error) {
 		}
 
 		iface := models.Interface{
-			Name: attrs.Name,
-			MTU:  attrs.MTU,
-			Up:   attrs.Flags&unix.IFF_UP != 0,
-			MAC:  attrs.HardwareAddr.String(),
+			Name:      attrs.Name,
+			Type:      link.Type(),
+			MTU:       attrs.MTU,
+			Up:        attrs.Flags&unix.IFF_UP != 0,
+			OperState: attrs.OperState.String(),
+			Carrier:   attrs.RawFlags&unix.IFF_LOWER_UP != 0,
+			MAC:       attrs.HardwareAddr.String(),
+		}
+		if attrs.Statistics != nil {
+			iface.RxBytes = attrs.Statistics.RxBytes
+			iface.TxBytes = attrs.Statistics.TxBytes
 		}
 
 		addrs, err := netlink.AddrList(link, unix.AF_UNSPEC)
@@ -163,6 +170,9 @@ func routeProtoString(p int) string {
 		if p == 0 {
 			return ""
 		}
+		if p == models.CurrentRanges().RouteProtocol {
+			return "router-sync"
+		}
 		return strconv.Itoa(p)
 	}
 }
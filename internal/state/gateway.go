@@ -0,0 +1,265 @@
+package state
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Lease locations checked by SuggestGateway. They only exist when the agent
+// can see the host's /run and /var/lib (host paths mounted into the container).
+var (
+	networkdLeaseDir   = "/run/systemd/netif/leases"
+	dhclientLeaseGlobs = []string{
+		"/var/lib/dhcp/dhclient*.leases",
+		"/var/lib/dhclient/dhclient*.lease*",
+	}
+	nmLeaseGlob = "/var/lib/NetworkManager/internal-*-%s.lease"
+)
+
+// SuggestGateway looks for the likely gateway of iface, in order of trust:
+// the DHCP lease (systemd-networkd, NetworkManager or dhclient), next hops of
+// kernel routes through the interface (any table), then on-link neighbors.
+func SuggestGateway(iface string) (*models.GatewaySuggestion, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("unknown interface %q: %w", iface, err)
+	}
+
+	var subnets []*net.IPNet
+	if addrs, err := ifi.Addrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				subnets = append(subnets, ipnet)
+			}
+		}
+	}
+
+	neighbors := map[string]string{}
+	if out, err := exec.Command("ip", "-4", "neigh", "show", "dev", iface).Output(); err == nil {
+		neighbors = parseNeighbors(string(out))
+	} else {
+		logrus.Debugf("ip neigh show dev %s failed: %v", iface, err)
+	}
+
+	suggestion := &models.GatewaySuggestion{Interface: iface, Candidates: []models.GatewayCandidate{}}
+	seen := make(map[string]bool)
+	add := func(gw, source, detail string) {
+		ip := net.ParseIP(gw)
+		if ip == nil || ip.To4() == nil || seen[ip.String()] {
+			return
+		}
+		seen[ip.String()] = true
+		suggestion.Candidates = append(suggestion.Candidates, models.GatewayCandidate{
+			Gateway:       ip.String(),
+			Source:        source,
+			Detail:        detail,
+			OnLink:        inSubnets(ip, subnets),
+			NeighborState: neighbors[ip.String()],
+		})
+	}
+
+	for _, lease := range leaseGateways(ifi) {
+		add(lease.gateway, models.GatewaySourceDHCP, lease.file)
+	}
+
+	if out, err := exec.Command("ip", "-4", "route", "show", "table", "all", "dev", iface).Output(); err == nil {
+		for _, r := range parseRouteGateways(string(out)) {
+			add(r.gateway, models.GatewaySourceRoute, r.describe())
+		}
+	} else {
+		logrus.Debugf("ip route show dev %s failed: %v", iface, err)
+	}
+
+	neighborIPs := make([]string, 0, len(neighbors))
+	for ip := range neighbors {
+		neighborIPs = append(neighborIPs, ip)
+	}
+	sort.Strings(neighborIPs)
+	for _, ip := range neighborIPs {
+		if state := neighbors[ip]; state == "FAILED" || state == "INCOMPLETE" {
+			continue
+		}
+		if parsed := net.ParseIP(ip); parsed != nil && inSubnets(parsed, subnets) {
+			add(ip, models.GatewaySourceNeighbor, "")
+		}
+	}
+
+	for _, c := range suggestion.Candidates {
+		if c.OnLink || len(subnets) == 0 {
+			suggestion.Gateway = c.Gateway
+			break
+		}
+	}
+	return suggestion, nil
+}
+
+type leaseGateway struct {
+	gateway string
+	file    string
+}
+
+func leaseGateways(ifi *net.Interface) []leaseGateway {
+	var out []leaseGateway
+
+	// systemd-networkd and NetworkManager's internal client share a KEY=VALUE format.
+	files := []string{filepath.Join(networkdLeaseDir, strconv.Itoa(ifi.Index))}
+	if matches, _ := filepath.Glob(fmt.Sprintf(nmLeaseGlob, ifi.Name)); len(matches) > 0 {
+		files = append(files, matches...)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		for _, gw := range parseNetworkdLease(string(data)) {
+			out = append(out, leaseGateway{gateway: gw, file: f})
+		}
+	}
+
+	for _, glob := range dhclientLeaseGlobs {
+		matches, _ := filepath.Glob(glob)
+		for _, f := range matches {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			for _, gw := range parseDhclientLeases(string(data), ifi.Name) {
+				out = append(out, leaseGateway{gateway: gw, file: f})
+			}
+		}
+	}
+	return out
+}
+
+// parseNetworkdLease returns the ROUTER= addresses of a systemd-networkd lease.
+func parseNetworkdLease(data string) []string {
+	for _, line := range strings.Split(data, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "ROUTER="); ok {
+			return strings.Fields(v)
+		}
+	}
+	return nil
+}
+
+// parseDhclientLeases returns the routers of the last lease for iface in a
+// dhclient leases file. Blocks look like:
+//
+//	lease {
+//	  interface "eth0";
+//	  fixed-address 192.168.4.6;
+//	  option routers 192.168.4.1;
+//	}
+func parseDhclientLeases(data, iface string) []string {
+	var (
+		last         []string
+		inLease      bool
+		leaseIface   string
+		leaseRouters []string
+	)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		switch {
+		case line == "lease {":
+			inLease, leaseIface, leaseRouters = true, "", nil
+		case line == "}" && inLease:
+			if leaseIface == "" || leaseIface == iface {
+				if len(leaseRouters) > 0 {
+					last = leaseRouters
+				}
+			}
+			inLease = false
+		case inLease && strings.HasPrefix(line, "interface "):
+			leaseIface = strings.Trim(strings.TrimPrefix(line, "interface "), `"`)
+		case inLease && strings.HasPrefix(line, "option routers "):
+			for _, r := range strings.Split(strings.TrimPrefix(line, "option routers "), ",") {
+				if r = strings.TrimSpace(r); r != "" {
+					leaseRouters = append(leaseRouters, r)
+				}
+			}
+		}
+	}
+	return last
+}
+
+type routeGateway struct {
+	gateway   string
+	dst       string
+	table     string
+	isDefault bool
+}
+
+func (r routeGateway) describe() string {
+	table := r.table
+	if table == "" {
+		table = "main"
+	}
+	return fmt.Sprintf("%s via %s (table %s)", r.dst, r.gateway, table)
+}
+
+// parseRouteGateways extracts next hops from `ip route show table all dev X`,
+// default routes first, e.g.:
+//
+//	"default via 192.168.4.1 table 99 proto static"
+//	"10.8.0.0/16 via 192.168.4.254 proto static"
+func parseRouteGateways(output string) []routeGateway {
+	var defaults, others []routeGateway
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		r := routeGateway{dst: fields[0], isDefault: fields[0] == "default"}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				r.gateway = fields[i+1]
+			case "table":
+				r.table = fields[i+1]
+			}
+		}
+		if r.gateway == "" {
+			continue
+		}
+		if r.isDefault {
+			defaults = append(defaults, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+	return append(defaults, others...)
+}
+
+// parseNeighbors maps neighbor IP to state from `ip -4 neigh show dev X`, e.g.:
+//
+//	"192.168.4.1 lladdr 00:11:22:33:44:55 REACHABLE"
+//	"192.168.4.9 FAILED"
+func parseNeighbors(output string) map[string]string {
+	out := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		out[fields[0]] = fields[len(fields)-1]
+	}
+	return out
+}
+
+func inSubnets(ip net.IP, subnets []*net.IPNet) bool {
+	for _, n := range subnets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
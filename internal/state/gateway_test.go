@@ -0,0 +1,74 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNetworkdLease(t *testing.T) {
+	lease := "# This is private data. Do not parse.\nADDRESS=192.168.4.6\nNETMASK=255.255.255.0\nROUTER=192.168.4.1\nSERVER_ADDRESS=192.168.4.1\n"
+	if got := parseNetworkdLease(lease); !reflect.DeepEqual(got, []string{"192.168.4.1"}) {
+		t.Errorf("parseNetworkdLease() = %v", got)
+	}
+	if got := parseNetworkdLease("ADDRESS=10.0.0.2\n"); got != nil {
+		t.Errorf("parseNetworkdLease() without ROUTER = %v, want nil", got)
+	}
+}
+
+func TestParseDhclientLeases(t *testing.T) {
+	leases := `lease {
+  interface "eth0";
+  fixed-address 192.168.4.6;
+  option routers 192.168.4.1;
+}
+lease {
+  interface "eth1";
+  fixed-address 100.64.0.10;
+  option routers 100.64.0.1;
+}
+lease {
+  interface "eth0";
+  fixed-address 192.168.4.7;
+  option routers 192.168.4.254, 192.168.4.253;
+}
+`
+	tests := []struct {
+		iface string
+		want  []string
+	}{
+		{iface: "eth0", want: []string{"192.168.4.254", "192.168.4.253"}},
+		{iface: "eth1", want: []string{"100.64.0.1"}},
+		{iface: "eth2", want: nil},
+	}
+	for _, tt := range tests {
+		if got := parseDhclientLeases(leases, tt.iface); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDhclientLeases(%s) = %v, want %v", tt.iface, got, tt.want)
+		}
+	}
+}
+
+func TestParseRouteGateways(t *testing.T) {
+	out := `10.8.0.0/16 via 192.168.4.254 proto static
+default via 192.168.4.1 table 99 proto static
+192.168.4.0/24 proto kernel scope link src 192.168.4.6
+default via 192.168.4.1 proto dhcp src 192.168.4.6 metric 100
+`
+	got := parseRouteGateways(out)
+	if len(got) != 3 {
+		t.Fatalf("parseRouteGateways() returned %d routes, want 3: %+v", len(got), got)
+	}
+	if !got[0].isDefault || got[0].table != "99" || got[0].gateway != "192.168.4.1" {
+		t.Errorf("first route = %+v, want default via 192.168.4.1 table 99", got[0])
+	}
+	if got[2].gateway != "192.168.4.254" || got[2].describe() != "10.8.0.0/16 via 192.168.4.254 (table main)" {
+		t.Errorf("last route = %+v (%s)", got[2], got[2].describe())
+	}
+}
+
+func TestParseNeighbors(t *testing.T) {
+	out := "192.168.4.1 lladdr 00:11:22:33:44:55 REACHABLE\n192.168.4.9 FAILED\n"
+	want := map[string]string{"192.168.4.1": "REACHABLE", "192.168.4.9": "FAILED"}
+	if got := parseNeighbors(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNeighbors() = %v, want %v", got, want)
+	}
+}
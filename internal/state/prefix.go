@@ -0,0 +1,57 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// interfaceAddr is an address assigned to an interface, as far as picking a
+// delegated prefix needs it.
+type interfaceAddr struct {
+	ipnet *net.IPNet
+	// deprecated is set once the address's preferred lifetime has ended,
+	// e.g. for the old prefix after the provider renumbered.
+	deprecated bool
+	// preferredLft is the remaining preferred lifetime in seconds; the
+	// newest prefix usually has the longest.
+	preferredLft int
+}
+
+// DelegatedPrefix returns the IPv6 prefix of the given length delegated to
+// the router, as seen from the addresses on iface (typically a LAN interface
+// the DHCPv6-PD client or router advertisements number from the prefix).
+// Deprecated, link-local and unique local addresses are ignored.
+func DelegatedPrefix(iface string, length int) (*net.IPNet, error) {
+	addrs, err := interfaceAddrs(iface)
+	if err != nil {
+		return nil, err
+	}
+	prefix := pickDelegatedPrefix(addrs, length)
+	if prefix == nil {
+		return nil, fmt.Errorf("no global IPv6 address on %s", iface)
+	}
+	return prefix, nil
+}
+
+// pickDelegatedPrefix masks the preferred global IPv6 address in addrs to
+// length: the one with the longest preferred lifetime, then the lowest.
+func pickDelegatedPrefix(addrs []interfaceAddr, length int) *net.IPNet {
+	var best *interfaceAddr
+	for i := range addrs {
+		a := &addrs[i]
+		ip := a.ipnet.IP
+		if a.deprecated || ip.To4() != nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			continue
+		}
+		if best == nil || a.preferredLft > best.preferredLft ||
+			(a.preferredLft == best.preferredLft && bytes.Compare(ip.To16(), best.ipnet.IP.To16()) < 0) {
+			best = a
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	mask := net.CIDRMask(length, 128)
+	return &net.IPNet{IP: best.ipnet.IP.Mask(mask), Mask: mask}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package state
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func interfaceAddrs(iface string) ([]interfaceAddr, error) {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("unknown interface %q: %w", iface, err)
+	}
+	addrs, err := netlink.AddrList(link, unix.AF_INET6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of %s: %w", iface, err)
+	}
+	out := make([]interfaceAddr, 0, len(addrs))
+	for _, a := range addrs {
+		if a.IPNet == nil {
+			continue
+		}
+		out = append(out, interfaceAddr{
+			ipnet:        a.IPNet,
+			deprecated:   a.Flags&unix.IFA_F_DEPRECATED != 0,
+			preferredLft: a.PreferedLft,
+		})
+	}
+	return out, nil
+}
+
+// WatchAddresses calls onChange for every IPv6 address added, removed or
+// deprecated on the router, until ctx is done or the subscription fails.
+func WatchAddresses(ctx context.Context, onChange func()) error {
+	updates := make(chan netlink.AddrUpdate)
+	errs := make(chan error, 1)
+	err := netlink.AddrSubscribeWithOptions(updates, ctx.Done(), netlink.AddrSubscribeOptions{
+		ErrorCallback: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to address updates: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return fmt.Errorf("address updates: %w", err)
+		case u, ok := <-updates:
+			if !ok {
+				return fmt.Errorf("address updates closed")
+			}
+			if u.LinkAddress.IP.To4() == nil {
+				onChange()
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// interfaceAddrs has no lifetimes off Linux, so no address is deprecated.
+func interfaceAddrs(iface string) ([]interfaceAddr, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("unknown interface %q: %w", iface, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of %s: %w", iface, err)
+	}
+	var out []interfaceAddr
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			out = append(out, interfaceAddr{ipnet: ipnet})
+		}
+	}
+	return out, nil
+}
+
+// WatchAddresses is only supported on Linux; agents elsewhere pick up
+// prefix changes at the next full sync.
+func WatchAddresses(ctx context.Context, onChange func()) error {
+	return fmt.Errorf("address updates are only supported on Linux")
+}
@@ -0,0 +1,38 @@
+package state
+
+import (
+	"net"
+	"testing"
+)
+
+func mustAddr(t *testing.T, s string, deprecated bool, lft int) interfaceAddr {
+	t.Helper()
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipnet.IP = ip
+	return interfaceAddr{ipnet: ipnet, deprecated: deprecated, preferredLft: lft}
+}
+
+func TestPickDelegatedPrefix(t *testing.T) {
+	addrs := []interfaceAddr{
+		mustAddr(t, "192.168.1.1/24", false, 0),
+		mustAddr(t, "fe80::1/64", false, 0),
+		mustAddr(t, "fd00:1::1/64", false, 0),
+		mustAddr(t, "2001:db8:1200:1::1/64", true, 0),
+		mustAddr(t, "2001:db8:3400:1::1/64", false, 3600),
+	}
+	if got := pickDelegatedPrefix(addrs, 56); got == nil || got.String() != "2001:db8:3400::/56" {
+		t.Errorf("pickDelegatedPrefix() = %v, want 2001:db8:3400::/56", got)
+	}
+
+	addrs = append(addrs, mustAddr(t, "2001:db8:5600:1::1/64", false, 7200))
+	if got := pickDelegatedPrefix(addrs, 48); got == nil || got.String() != "2001:db8:5600::/48" {
+		t.Errorf("pickDelegatedPrefix() = %v, want the longest preferred lifetime", got)
+	}
+
+	if got := pickDelegatedPrefix(addrs[:4], 56); got != nil {
+		t.Errorf("pickDelegatedPrefix() = %v, want nil without a usable address", got)
+	}
+}
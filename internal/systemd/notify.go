@@ -0,0 +1,84 @@
+// Package systemd implements the sd_notify protocol so router-sync can run
+// as a Type=notify unit: READY=1 once it serves, STATUS= progress lines,
+// STOPPING=1 on shutdown and WATCHDOG=1 keepalives when WatchdogSec is set.
+// Outside systemd (no NOTIFY_SOCKET) every call is a no-op.
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notification states understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the socket in NOTIFY_SOCKET. It reports false
+// without error when the process was not started by systemd.
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Status returns a STATUS= line shown by `systemctl status`.
+func Status(msg string) string {
+	return "STATUS=" + msg
+}
+
+// WatchdogInterval returns how often WATCHDOG=1 must be sent: WATCHDOG_USEC
+// (from WatchdogSec=) for this process, or 0 when the watchdog is off.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// KeepAlive sends WATCHDOG=1 at half the watchdog interval until ctx is
+// done, skipping a beat whenever healthy (if not nil) reports false so that
+// systemd restarts a process that stopped making progress. It returns at
+// once when the watchdog is off.
+func KeepAlive(ctx context.Context, healthy func() bool) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthy != nil && !healthy() {
+				continue
+			}
+			_, _ = Notify(Watchdog)
+		}
+	}
+}
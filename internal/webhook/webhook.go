@@ -0,0 +1,212 @@
+// Package webhook delivers router-sync events to subscribed HTTP endpoints.
+//
+// Each delivery is a POST of the JSON-encoded models.Event. When the
+// subscription has a secret the request carries
+//
+//	X-Router-Sync-Signature: sha256=<hex HMAC-SHA256(secret, timestamp + "." + body)>
+//
+// where timestamp is the X-Router-Sync-Timestamp header (Unix seconds).
+// Receivers should recompute the signature and reject stale timestamps.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"router-sync/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Delivery headers.
+const (
+	HeaderEvent     = "X-Router-Sync-Event"
+	HeaderDelivery  = "X-Router-Sync-Delivery"
+	HeaderTimestamp = "X-Router-Sync-Timestamp"
+	HeaderSignature = "X-Router-Sync-Signature"
+)
+
+const (
+	// queueSize bounds events waiting for delivery; beyond it events are dropped.
+	queueSize = 256
+	// workers is the number of concurrent deliveries.
+	workers = 4
+	// deliveryTimeout bounds a single HTTP attempt.
+	deliveryTimeout = 10 * time.Second
+	// maxAttempts includes the first try.
+	maxAttempts = 3
+	// cacheTTL is how long the subscription list is reused before reloading.
+	cacheTTL = 30 * time.Second
+)
+
+// Sign returns the X-Router-Sync-Signature value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches body sent at timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher queues events and POSTs them to every enabled subscription that
+// wants them, retrying failed deliveries with backoff.
+type Dispatcher struct {
+	list       func() ([]*models.Webhook, error)
+	client     *http.Client
+	userAgent  string
+	queue      chan *models.Event
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	hooks    []*models.Webhook
+	loadedAt time.Time
+}
+
+// NewDispatcher creates a Dispatcher that loads subscriptions with list.
+func NewDispatcher(list func() ([]*models.Webhook, error), userAgent string) *Dispatcher {
+	return &Dispatcher{
+		list:       list,
+		client:     &http.Client{Timeout: deliveryTimeout},
+		userAgent:  userAgent,
+		queue:      make(chan *models.Event, queueSize),
+		retryDelay: time.Second,
+	}
+}
+
+// Enqueue schedules ev for delivery without blocking.
+func (d *Dispatcher) Enqueue(ev *models.Event) {
+	select {
+	case d.queue <- ev:
+	default:
+		logrus.Warnf("Webhook queue full, dropping %s event %s", ev.Type, ev.ID)
+	}
+}
+
+// Invalidate forces the next event to reload the subscription list.
+func (d *Dispatcher) Invalidate() {
+	d.mu.Lock()
+	d.loadedAt = time.Time{}
+	d.mu.Unlock()
+}
+
+// Run delivers queued events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev := <-d.queue:
+					d.dispatch(ctx, ev)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, ev *models.Event) {
+	for _, hook := range d.subscribers(ev.Type) {
+		if err := d.Deliver(ctx, hook, ev); err != nil {
+			logrus.Warnf("Webhook %s: failed to deliver %s event %s: %v", hook.ID, ev.Type, ev.ID, err)
+		}
+	}
+}
+
+// subscribers returns the enabled webhooks that want eventType. If reloading
+// fails the previous list is kept.
+func (d *Dispatcher) subscribers(eventType string) []*models.Webhook {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Since(d.loadedAt) > cacheTTL {
+		hooks, err := d.list()
+		if err != nil {
+			logrus.Warnf("Failed to load webhooks, using cached list: %v", err)
+		} else {
+			d.hooks = hooks
+			d.loadedAt = time.Now()
+		}
+	}
+
+	var out []*models.Webhook
+	for _, hook := range d.hooks {
+		if hook.Enabled && hook.Wants(eventType) {
+			out = append(out, hook)
+		}
+	}
+	return out
+}
+
+// Deliver POSTs ev to hook, retrying network errors, 408, 429 and 5xx
+// responses up to maxAttempts times.
+func (d *Dispatcher) Deliver(ctx context.Context, hook *models.Webhook, ev *models.Event) error {
+	body, err := ev.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	delay := d.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(ctx, hook, ev, body)
+		if err == nil {
+			logrus.Debugf("Webhook %s: delivered %s event %s", hook.ID, ev.Type, ev.ID)
+			return nil
+		}
+		if !retry || attempt == maxAttempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// send makes one delivery attempt and reports whether a failure is retryable.
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, ev *models.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", d.userAgent)
+	req.Header.Set(HeaderEvent, ev.Type)
+	req.Header.Set(HeaderDelivery, ev.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("endpoint returned %s", resp.Status)
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"config.changed"}`)
+	sig := Sign("s3cret", 1700000000, body)
+	if !Verify("s3cret", 1700000000, body, sig) {
+		t.Fatalf("Verify() rejected its own signature %s", sig)
+	}
+	if Verify("other", 1700000000, body, sig) {
+		t.Error("Verify() accepted a signature made with another secret")
+	}
+	if Verify("s3cret", 1700000001, body, sig) {
+		t.Error("Verify() accepted a signature for another timestamp")
+	}
+}
+
+func TestDeliver_SignsAndRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if !Verify("s3cret", ts, body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("bad signature %q", r.Header.Get(HeaderSignature))
+		}
+		if got := r.Header.Get(HeaderEvent); got != models.EventConfigChanged {
+			t.Errorf("%s = %q", HeaderEvent, got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, "router-sync/test")
+	d.retryDelay = time.Millisecond
+	hook := &models.Webhook{ID: "h1", URL: srv.URL, Secret: "s3cret", Enabled: true}
+	ev := &models.Event{ID: "e1", Type: models.EventConfigChanged}
+
+	if err := d.Deliver(context.Background(), hook, ev); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("endpoint called %d times, want 2", calls)
+	}
+}
+
+func TestDeliver_ClientErrorNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, "router-sync/test")
+	d.retryDelay = time.Millisecond
+	hook := &models.Webhook{ID: "h1", URL: srv.URL, Enabled: true}
+
+	if err := d.Deliver(context.Background(), hook, &models.Event{ID: "e1", Type: "ping"}); err == nil {
+		t.Fatal("Deliver() error = nil, want error for 410")
+	}
+	if calls != 1 {
+		t.Errorf("endpoint called %d times, want 1", calls)
+	}
+}
+
+func TestSubscribers_FiltersDisabledAndTypes(t *testing.T) {
+	hooks := []*models.Webhook{
+		{ID: "all", Enabled: true},
+		{ID: "sync", Enabled: true, Events: []string{models.EventSyncCompleted}},
+		{ID: "off", Enabled: false},
+	}
+	d := NewDispatcher(func() ([]*models.Webhook, error) { return hooks, nil }, "")
+
+	got := d.subscribers(models.EventConfigChanged)
+	if len(got) != 1 || got[0].ID != "all" {
+		t.Errorf("subscribers(config.changed) = %v, want [all]", got)
+	}
+	if got := d.subscribers(models.EventSyncCompleted); len(got) != 2 {
+		t.Errorf("subscribers(sync.completed) returned %d hooks, want 2", len(got))
+	}
+}
@@ -0,0 +1,249 @@
+// Package client is the Go client for the router-sync REST API. It talks to
+// /api/v2, returns API failures as *Error and retries requests on network
+// errors and 429/5xx responses. POST requests carry a generated
+// Idempotency-Key, so a retry of one the server already completed replays
+// its response instead of running it twice.
+//
+//	c := client.New("http://192.168.2.252:18080")
+//	c.Token = os.Getenv("ROUTERSYNC_TOKEN")
+//	providers, err := c.ListProviders(ctx, client.ListOptions{})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = 500 * time.Millisecond
+)
+
+// Client calls the router-sync API. Set the exported fields before the first
+// request; a Client is safe for concurrent use after that.
+type Client struct {
+	// Token is sent as a Bearer token when API auth is enabled.
+	Token string
+	// UserAgent is sent with every request.
+	UserAgent string
+	// HTTPClient makes the requests; New sets one with a 30s timeout.
+	HTTPClient *http.Client
+	// MaxAttempts bounds tries of a request; 1 disables retries. POST
+	// requests are retried with the same Idempotency-Key.
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry, doubled after each.
+	RetryDelay time.Duration
+
+	baseURL string
+}
+
+// New creates a Client for the API at server, e.g. "http://localhost:18080".
+func New(server string) *Client {
+	return &Client{
+		UserAgent:   "router-sync-client",
+		HTTPClient:  &http.Client{Timeout: defaultTimeout},
+		MaxAttempts: defaultMaxAttempts,
+		RetryDelay:  defaultRetryDelay,
+		baseURL:     strings.TrimRight(server, "/") + "/api/v2",
+	}
+}
+
+// Error is a non-2xx API response. Code is one of the API's stable error
+// codes (not_found, conflict, validation_failed, ...).
+type Error struct {
+	StatusCode int              `json:"-"`
+	Code       string           `json:"code"`
+	Message    string           `json:"message"`
+	Details    string           `json:"details,omitempty"`
+	Fields     []FieldViolation `json:"fields,omitempty"`
+	RequestID  string           `json:"request_id,omitempty"`
+}
+
+// FieldViolation is one invalid request field.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("\n  %s %s", f.Field, f.Message)
+	}
+	return fmt.Sprintf("%s (%d %s)", msg, e.StatusCode, e.Code)
+}
+
+// IsNotFound reports whether err is a 404 from the API.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a 409 or 412 from the API, i.e. the
+// resource exists already or changed since it was read.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict) || hasStatus(err, http.StatusPreconditionFailed)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// request is one API call; ifMatch, when not zero, is sent as the If-Match
+// generation so the write fails with 412 if the resource changed.
+// idempotencyKey is set by do for POST requests.
+type request struct {
+	method         string
+	path           string
+	query          url.Values
+	body           interface{}
+	ifMatch        uint64
+	idempotencyKey string
+}
+
+// do sends req, retrying failed attempts, and decodes the response into out
+// when out is not nil. Every attempt of a POST carries the same
+// Idempotency-Key, which makes the retry safe.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = data
+	}
+
+	if req.method == http.MethodPost && req.idempotencyKey == "" {
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		req.idempotencyKey = key
+	}
+
+	attempts := c.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := c.RetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := c.send(ctx, req, body, out)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// send makes one attempt and reports whether a failure is retryable.
+func (c *Client) send(ctx context.Context, req request, body []byte, out interface{}) (bool, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if c.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if req.ifMatch != 0 {
+		httpReq.Header.Set("If-Match", `"`+strconv.FormatUint(req.ifMatch, 10)+`"`)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, fmt.Errorf("%s %s failed: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, responseError(resp, data)
+	}
+	if out == nil || len(data) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return false, nil
+}
+
+// newIdempotencyKey returns a random key for one POST and its retries.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate Idempotency-Key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// responseError builds an *Error from the v2 error envelope, falling back to
+// the raw body for responses from something other than the API (a proxy).
+func responseError(resp *http.Response, data []byte) error {
+	var envelope struct {
+		Error Error `json:"error"`
+	}
+	apiErr := &envelope.Error
+	if err := json.Unmarshal(data, &envelope); err != nil || apiErr.Message == "" {
+		apiErr = &Error{Message: http.StatusText(resp.StatusCode), Details: strings.TrimSpace(string(data))}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return apiErr
+}
+
+// escapeID makes a policy or provider ID safe as a path segment; CIDR
+// sources use an underscore for the slash, as the API expects.
+func escapeID(id string) string {
+	return url.PathEscape(strings.ReplaceAll(id, "/", "_"))
+}
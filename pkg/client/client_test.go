@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetProvider_SendsTokenAndDecodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/providers/starlink" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		_ = json.NewEncoder(w).Encode(Provider{ID: "starlink", Name: "Starlink", TableID: 200})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL + "/")
+	c.Token = "s3cret"
+	p, err := c.GetProvider(context.Background(), "starlink")
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	if p.ID != "starlink" || p.TableID != 200 {
+		t.Errorf("GetProvider() = %+v", p)
+	}
+}
+
+func TestDo_ErrorEnvelope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"Policy not found","request_id":"abc"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).GetPolicy(context.Background(), "p1")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetPolicy() error = %v, want *Error", err)
+	}
+	if apiErr.Code != "not_found" || apiErr.RequestID != "abc" || !IsNotFound(err) {
+		t.Errorf("error = %+v", apiErr)
+	}
+}
+
+func TestDo_RetriesIdempotentRequests(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.RetryDelay = time.Millisecond
+	if _, err := c.ListPolicies(context.Background(), ListOptions{}); err != nil {
+		t.Fatalf("ListPolicies() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("server called %d times, want 2", calls)
+	}
+}
+
+func TestDo_PostRetriedWithIdempotencyKey(t *testing.T) {
+	var calls int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"p1","name":"p"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.RetryDelay = time.Millisecond
+	p, err := c.CreatePolicy(context.Background(), &Policy{Name: "p"})
+	if err != nil {
+		t.Fatalf("CreatePolicy() error = %v", err)
+	}
+	if p.ID != "p1" {
+		t.Errorf("CreatePolicy() = %+v", p)
+	}
+	if calls != 2 {
+		t.Fatalf("server called %d times, want 2", calls)
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Key per attempt = %q, want one non-empty key reused", keys)
+	}
+
+	// A new call gets a new key.
+	if _, err := c.CreatePolicy(context.Background(), &Policy{Name: "p"}); err != nil {
+		t.Fatalf("CreatePolicy() error = %v", err)
+	}
+	if keys[2] == keys[0] {
+		t.Errorf("second CreatePolicy reused Idempotency-Key %q", keys[2])
+	}
+}
+
+func TestDo_PostNotRetriedOnClientError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.RetryDelay = time.Millisecond
+	if _, err := c.CreatePolicy(context.Background(), &Policy{Name: "p"}); !IsConflict(err) {
+		t.Fatalf("CreatePolicy() error = %v, want conflict", err)
+	}
+	if calls != 1 {
+		t.Errorf("server called %d times, want 1", calls)
+	}
+}
+
+func TestUpdatePolicy_SendsIfMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-Match"); got != `"7"` {
+			t.Errorf("If-Match = %q", got)
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+		_, _ = w.Write([]byte(`{"error":{"code":"conflict","message":"Resource has changed"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).UpdatePolicy(context.Background(), "p1", &Policy{ID: "p1", Generation: 7})
+	if !IsConflict(err) {
+		t.Errorf("UpdatePolicy() error = %v, want conflict", err)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("types"); got != "provider.health" {
+			t.Errorf("types = %q", got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(": connected\n\n" +
+			"id: e1\nevent: provider.health\ndata: {\"id\":\"e1\",\"type\":\"provider.health\",\"resource\":\"lte\"}\n\n" +
+			": keep-alive\n\n" +
+			"id: e2\nevent: provider.health\ndata: {\"id\":\"e2\",\"type\":\"provider.health\"}\n\n"))
+	}))
+	defer srv.Close()
+
+	var got []string
+	err := New(srv.URL).StreamEvents(context.Background(), StreamOptions{Types: []string{"provider.health"}}, func(ev *Event) {
+		got = append(got, ev.ID)
+	})
+	if err != nil {
+		t.Fatalf("StreamEvents() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != "e1" || got[1] != "e2" {
+		t.Errorf("events = %v, want [e1 e2]", got)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"router-sync/internal/models"
+)
+
+// Document is the export/import format: providers, policies and groups with
+// an api_version header.
+type (
+	Document = models.ConfigDocument
+	Group    = models.PolicyGroup
+)
+
+// DocumentVersion is the api_version of documents this client writes.
+const DocumentVersion = models.DocumentVersion
+
+// Import modes; see ImportOptions.
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// ImportOptions controls Import. Mode ImportMerge (the default) creates and
+// updates the records in the document; ImportReplace also deletes records
+// missing from it. DryRun only validates and plans.
+type ImportOptions struct {
+	Mode   string
+	DryRun bool
+}
+
+// ImportChanges lists record IDs by the action an import takes on them.
+type ImportChanges struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// ImportResult is the plan, or outcome, of an Import.
+type ImportResult struct {
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Providers ImportChanges `json:"providers"`
+	Policies  ImportChanges `json:"policies"`
+	Groups    ImportChanges `json:"groups"`
+	Errors    []string      `json:"errors,omitempty"`
+}
+
+// Export returns every provider, policy and group as one document.
+func (c *Client) Export(ctx context.Context) (*Document, error) {
+	var doc Document
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/export"}, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Import validates doc as a whole and writes only the records that differ
+// from the store. Nothing is written when validation fails.
+func (c *Client) Import(ctx context.Context, doc *Document, opts ImportOptions) (*ImportResult, error) {
+	query := url.Values{}
+	if opts.Mode != "" {
+		query.Set("mode", opts.Mode)
+	}
+	if opts.DryRun {
+		query.Set("dry_run", strconv.FormatBool(true))
+	}
+	var res ImportResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/import", query: query, body: doc}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListGroups returns every policy group.
+func (c *Client) ListGroups(ctx context.Context) ([]*Group, error) {
+	var groups []*Group
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups"}, &groups)
+	return groups, err
+}
+
+// DeleteGroup deletes group id; its member policies are kept.
+func (c *Client) DeleteGroup(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/groups/" + escapeID(id)}, nil)
+}
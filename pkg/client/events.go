@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"router-sync/internal/models"
+)
+
+// Event is one entry of the /stream event feed.
+type Event = models.Event
+
+// Interface is a network interface as reported by an agent.
+type Interface = models.Interface
+
+// Router is a router's latest heartbeat as listed by ListRouters.
+type Router struct {
+	Hostname     string      `json:"hostname"`
+	AgentVersion string      `json:"agent_version"`
+	LogLevel     string      `json:"log_level"`
+	LastSeen     time.Time   `json:"last_seen"`
+	AgeSeconds   float64     `json:"age_seconds"`
+	Online       bool        `json:"online"`
+	Interfaces   []Interface `json:"interfaces"`
+}
+
+// ListRouters returns every router reporting state.
+func (c *Client) ListRouters(ctx context.Context) ([]Router, error) {
+	var routers []Router
+	err := c.do(ctx, request{method: http.MethodGet, path: "/routers"}, &routers)
+	return routers, err
+}
+
+// StreamOptions filters StreamEvents.
+type StreamOptions struct {
+	// Types limits the stream to these event types (see models.Event*).
+	Types []string
+	// Router limits the stream to events from this router hostname.
+	Router string
+}
+
+// StreamEvents reads the server-sent event stream and calls fn for each
+// event until ctx is done or the connection drops. It does not reconnect;
+// callers that want a permanent feed call it in a loop.
+func (c *Client) StreamEvents(ctx context.Context, opts StreamOptions, fn func(*Event)) error {
+	query := url.Values{}
+	if len(opts.Types) > 0 {
+		query.Set("types", strings.Join(opts.Types, ","))
+	}
+	if opts.Router != "" {
+		query.Set("router", opts.Router)
+	}
+	u := c.baseURL + "/stream"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	// The stream stays open indefinitely, so the client timeout must not
+	// apply; ctx ends it instead.
+	stream := *c.HTTPClient
+	stream.Timeout = 0
+	resp, err := stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET /stream failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return responseError(resp, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				var ev Event
+				if err := json.Unmarshal([]byte(data.String()), &ev); err == nil {
+					fn(&ev)
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("event stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"router-sync/internal/diff"
+	"router-sync/internal/models"
+)
+
+// The API's data models, re-exported so importers outside this module can
+// name them.
+type (
+	Provider       = models.InternetProvider
+	Policy         = models.RoutingPolicy
+	ProviderStatus = models.ProviderStatus
+	PolicyStatus   = models.PolicyStatus
+	Maintenance    = models.Maintenance
+	RouterDiff     = diff.RouterDiff
+	Change         = diff.Change
+	SyncResult     = models.SyncResult
+)
+
+// ListOptions filters list calls.
+type ListOptions struct {
+	// Labels is a label selector, e.g. "site=hq,tier!=backup".
+	Labels string
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.Labels != "" {
+		query.Set("labels", o.Labels)
+	}
+	return query
+}
+
+// ListProviders returns the providers matching opts.
+func (c *Client) ListProviders(ctx context.Context, opts ListOptions) ([]*Provider, error) {
+	var providers []*Provider
+	err := c.do(ctx, request{method: http.MethodGet, path: "/providers", query: opts.query()}, &providers)
+	return providers, err
+}
+
+// GetProvider returns the provider with id.
+func (c *Client) GetProvider(ctx context.Context, id string) (*Provider, error) {
+	var p Provider
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/providers/" + escapeID(id)}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateProvider stores p as a new provider; its ID is set from the name.
+func (c *Client) CreateProvider(ctx context.Context, p *Provider) (*Provider, error) {
+	var created Provider
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/providers", body: p}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateProvider replaces provider id with p. When p.Generation is set (p
+// came from a Get) the update fails with a conflict if the provider changed
+// since; see IsConflict.
+func (c *Client) UpdateProvider(ctx context.Context, id string, p *Provider) (*Provider, error) {
+	var updated Provider
+	req := request{method: http.MethodPut, path: "/providers/" + escapeID(id), body: p, ifMatch: p.Generation}
+	if err := c.do(ctx, req, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteProvider deletes provider id.
+func (c *Client) DeleteProvider(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/providers/" + escapeID(id)}, nil)
+}
+
+// ListPolicies returns the policies matching opts.
+func (c *Client) ListPolicies(ctx context.Context, opts ListOptions) ([]*Policy, error) {
+	var policies []*Policy
+	err := c.do(ctx, request{method: http.MethodGet, path: "/policies", query: opts.query()}, &policies)
+	return policies, err
+}
+
+// ListProviderPolicies returns the policies routed through provider id.
+func (c *Client) ListProviderPolicies(ctx context.Context, id string) ([]*Policy, error) {
+	var policies []*Policy
+	err := c.do(ctx, request{method: http.MethodGet, path: "/providers/" + escapeID(id) + "/policies"}, &policies)
+	return policies, err
+}
+
+// GetPolicy returns the policy with id.
+func (c *Client) GetPolicy(ctx context.Context, id string) (*Policy, error) {
+	var p Policy
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/policies/" + escapeID(id)}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePolicy stores p as a new policy; the API assigns its ID.
+func (c *Client) CreatePolicy(ctx context.Context, p *Policy) (*Policy, error) {
+	var created Policy
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/policies", body: p}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdatePolicy replaces policy id with p, guarded by p.Generation like
+// UpdateProvider.
+func (c *Client) UpdatePolicy(ctx context.Context, id string, p *Policy) (*Policy, error) {
+	var updated Policy
+	req := request{method: http.MethodPut, path: "/policies/" + escapeID(id), body: p, ifMatch: p.Generation}
+	if err := c.do(ctx, req, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeletePolicy deletes policy id.
+func (c *Client) DeletePolicy(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/policies/" + escapeID(id)}, nil)
+}
+
+// SyncOptions select the routers a sync runs on and whether to wait for it.
+type SyncOptions struct {
+	// Router limits the sync to one router; empty syncs every online one.
+	Router string
+	// Async returns as soon as the syncs are queued.
+	Async bool
+}
+
+// RouterSync is one router's answer to Sync. Error is set when the router
+// could not be asked; the sync's own errors are in Result.
+type RouterSync struct {
+	Hostname string      `json:"hostname"`
+	Result   *SyncResult `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Sync runs a full sync on the routers opts selects and returns what each
+// did.
+func (c *Client) Sync(ctx context.Context, opts SyncOptions) ([]RouterSync, error) {
+	query := url.Values{}
+	if opts.Router != "" {
+		query.Set("router", opts.Router)
+	}
+	if opts.Async {
+		query.Set("async", "true")
+	}
+	var res []RouterSync
+	err := c.do(ctx, request{method: http.MethodPost, path: "/sync", query: query}, &res)
+	return res, err
+}
+
+// SyncStatus is one router's last full sync and its failing records, as of
+// its last heartbeat.
+type SyncStatus struct {
+	Hostname       string            `json:"hostname"`
+	LastSeen       time.Time         `json:"last_seen"`
+	Online         bool              `json:"online"`
+	LastSync       *SyncResult       `json:"last_sync,omitempty"`
+	PolicyErrors   map[string]string `json:"policy_errors,omitempty"`
+	ProviderErrors map[string]string `json:"provider_errors,omitempty"`
+}
+
+// SyncStatus returns the sync status of router, or of every router when
+// router is empty.
+func (c *Client) SyncStatus(ctx context.Context, router string) ([]SyncStatus, error) {
+	query := url.Values{}
+	if router != "" {
+		query.Set("router", router)
+	}
+	var res []SyncStatus
+	err := c.do(ctx, request{method: http.MethodGet, path: "/sync/status", query: query}, &res)
+	return res, err
+}
+
+// Stats is the GET /stats snapshot.
+type Stats struct {
+	Sync struct {
+		ProvidersCount       int            `json:"providers_count"`
+		PoliciesCount        int            `json:"policies_count"`
+		EnabledPoliciesCount int            `json:"enabled_policies_count"`
+		GroupsCount          int            `json:"groups_count"`
+		PoliciesPerProvider  map[string]int `json:"policies_per_provider"`
+	} `json:"sync"`
+	Routers     []RouterStats `json:"routers"`
+	Usage       []UsageStats  `json:"usage"`
+	Maintenance *Maintenance  `json:"maintenance"`
+	LogLevel    string        `json:"log_level"`
+	Timestamp   time.Time     `json:"timestamp"`
+	ComputedAt  time.Time     `json:"computed_at"`
+	Version     string        `json:"version"`
+	BuildTime   string        `json:"build_time"`
+	GitCommit   string        `json:"git_commit"`
+}
+
+// RouterStats summarizes one router's latest heartbeat.
+type RouterStats struct {
+	Hostname     string    `json:"hostname"`
+	AgentVersion string    `json:"agent_version"`
+	LogLevel     string    `json:"log_level"`
+	LastSeen     time.Time `json:"last_seen"`
+	AgeSeconds   float64   `json:"age_seconds"`
+	Interfaces   int       `json:"interfaces"`
+	Tables       int       `json:"tables"`
+	Routes       int       `json:"routes"`
+	ManagedRules int       `json:"managed_rules"`
+	Maintenance  bool      `json:"maintenance"`
+}
+
+// UsageStats is one provider's data usage in the current billing period.
+type UsageStats struct {
+	ProviderID   string     `json:"provider_id"`
+	PeriodStart  time.Time  `json:"period_start"`
+	RxBytes      uint64     `json:"rx_bytes"`
+	TxBytes      uint64     `json:"tx_bytes"`
+	TotalBytes   uint64     `json:"total_bytes"`
+	MonthlyCap   int64      `json:"monthly_cap,omitempty"`
+	PercentOfCap float64    `json:"percent_of_cap,omitempty"`
+	DrainedAt    *time.Time `json:"drained_at,omitempty"`
+}
+
+// Stats returns the API's cached stats snapshot.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/stats"}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Diff returns the pending changes per router; router limits it to one
+// (empty for all).
+func (c *Client) Diff(ctx context.Context, router string) ([]RouterDiff, error) {
+	query := url.Values{}
+	if router != "" {
+		query.Set("router", router)
+	}
+	var diffs []RouterDiff
+	err := c.do(ctx, request{method: http.MethodGet, path: "/diff", query: query}, &diffs)
+	return diffs, err
+}
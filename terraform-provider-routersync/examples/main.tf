@@ -0,0 +1,44 @@
+terraform {
+  required_providers {
+    routersync = {
+      source = "fcastello/routersync"
+    }
+  }
+}
+
+provider "routersync" {
+  server = "http://192.168.2.252:18080"
+  # token = var.routersync_token  # or ROUTERSYNC_TOKEN
+}
+
+resource "routersync_provider" "fiber" {
+  name     = "Fiber"
+  table_id = 99
+  gateway  = "192.168.100.1"
+  interfaces = {
+    "router-a" = "eth1"
+    "router-b" = "eth1"
+  }
+  failover_priority = 1
+}
+
+resource "routersync_provider" "lte" {
+  name     = "LTE"
+  table_id = 101
+  gateway  = "192.168.8.1"
+  interfaces = {
+    "router-a" = "wwan0"
+  }
+  failover_priority = 2
+}
+
+resource "routersync_policy" "voip" {
+  name                = "VoIP phones"
+  source_ip           = "192.168.2.0/26"
+  provider_id         = routersync_provider.fiber.id
+  backup_provider_ids = [routersync_provider.lte.id]
+  mode                = "strict"
+  labels = {
+    team = "voip"
+  }
+}
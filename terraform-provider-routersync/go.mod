@@ -0,0 +1,12 @@
+module router-sync/terraform-provider-routersync
+
+go 1.21
+
+require (
+	github.com/hashicorp/terraform-plugin-framework v1.4.2
+	github.com/hashicorp/terraform-plugin-framework-validators v0.12.0
+	router-sync v0.0.0
+)
+
+// The provider is built from the same checkout as the API it manages.
+replace router-sync => ../
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"router-sync/pkg/client"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// configureClient returns the client set up by the provider, or nil before
+// the provider is configured (e.g. during validation).
+func configureClient(req resource.ConfigureRequest, resp *resource.ConfigureResponse) *client.Client {
+	if req.ProviderData == nil {
+		return nil
+	}
+	c, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data",
+			fmt.Sprintf("Expected *client.Client, got %T.", req.ProviderData))
+		return nil
+	}
+	return c
+}
+
+// stringMap converts an optional map attribute; null becomes nil.
+func stringMap(ctx context.Context, m types.Map, diags *diag.Diagnostics) map[string]string {
+	if m.IsNull() || m.IsUnknown() {
+		return nil
+	}
+	var out map[string]string
+	diags.Append(m.ElementsAs(ctx, &out, false)...)
+	return out
+}
+
+// mapValue converts back; an empty map is stored as null so that an unset
+// attribute does not show a diff.
+func mapValue(ctx context.Context, m map[string]string, diags *diag.Diagnostics) types.Map {
+	if len(m) == 0 {
+		return types.MapNull(types.StringType)
+	}
+	v, d := types.MapValueFrom(ctx, types.StringType, m)
+	diags.Append(d...)
+	return v
+}
+
+// stringList converts an optional list attribute; null becomes nil.
+func stringList(ctx context.Context, l types.List, diags *diag.Diagnostics) []string {
+	if l.IsNull() || l.IsUnknown() {
+		return nil
+	}
+	var out []string
+	diags.Append(l.ElementsAs(ctx, &out, false)...)
+	return out
+}
+
+// listValue converts back, with the same null rule as mapValue.
+func listValue(ctx context.Context, l []string, diags *diag.Diagnostics) types.List {
+	if len(l) == 0 {
+		return types.ListNull(types.StringType)
+	}
+	v, d := types.ListValueFrom(ctx, types.StringType, l)
+	diags.Append(d...)
+	return v
+}
+
+// optionalString maps "" to null for optional attributes.
+func optionalString(s string) types.String {
+	if s == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(s)
+}
+
+// optionalInt maps 0 to null for optional attributes.
+func optionalInt(i int) types.Int64 {
+	if i == 0 {
+		return types.Int64Null()
+	}
+	return types.Int64Value(int64(i))
+}
+
+// apiError formats a client error with the API's field violations, which
+// is what a user needs to fix their configuration.
+func apiError(err error) string {
+	apiErr, ok := err.(*client.Error)
+	if !ok || len(apiErr.Fields) == 0 {
+		return err.Error()
+	}
+	msg := apiErr.Error()
+	for _, f := range apiErr.Fields {
+		msg += fmt.Sprintf("\n  %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"router-sync/pkg/client"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestPolicyModelRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	var diags diag.Diagnostics
+	in := &client.Policy{
+		ID:                "p1",
+		Name:              "VoIP",
+		SourceIP:          "192.168.2.0/24",
+		ProviderID:        "fiber",
+		BackupProviderIDs: []string{"lte"},
+		Mode:              "strict",
+		Enabled:           true,
+		Labels:            map[string]string{"team": "voip"},
+		Generation:        7,
+	}
+
+	var m policyResourceModel
+	m.fromAPI(ctx, in, &diags)
+	out := m.toAPI(ctx, &diags)
+	if diags.HasError() {
+		t.Fatalf("diagnostics: %v", diags)
+	}
+
+	if out.Name != in.Name || out.SourceIP != in.SourceIP || out.ProviderID != in.ProviderID ||
+		out.Mode != in.Mode || !out.Enabled || out.Labels["team"] != "voip" ||
+		len(out.BackupProviderIDs) != 1 || out.BackupProviderIDs[0] != "lte" {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+	if m.Generation.ValueInt64() != 7 {
+		t.Errorf("generation = %v, want 7", m.Generation)
+	}
+	// Unset optional fields stay null so they do not show a diff
+	if !m.Destination.IsNull() || !m.Tags.IsNull() || !m.Priority.IsNull() {
+		t.Errorf("unset fields not null: destination=%v tags=%v priority=%v", m.Destination, m.Tags, m.Priority)
+	}
+}
+
+func TestProviderModelRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	var diags diag.Diagnostics
+	m := providerResourceModel{
+		Name:       types.StringValue("Fiber"),
+		TableID:    types.Int64Value(99),
+		Gateway:    types.StringValue("192.168.100.1"),
+		Interfaces: mapValue(ctx, map[string]string{"router-a": "eth1"}, &diags),
+		Weight:     types.Int64Value(3),
+	}
+	p := m.toAPI(ctx, &diags)
+	if diags.HasError() {
+		t.Fatalf("diagnostics: %v", diags)
+	}
+	if p.TableID != 99 || p.Interfaces["router-a"] != "eth1" || p.Weight != 3 || p.FailoverPriority != 0 {
+		t.Errorf("toAPI() = %+v", p)
+	}
+}
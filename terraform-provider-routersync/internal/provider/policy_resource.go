@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+
+	"router-sync/pkg/client"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// policyResource is routersync_policy, which routes a source through a
+// provider.
+type policyResource struct {
+	client *client.Client
+}
+
+// policyResourceModel mirrors client.Policy's writable fields.
+type policyResourceModel struct {
+	ID                types.String `tfsdk:"id"`
+	Name              types.String `tfsdk:"name"`
+	SourceIP          types.String `tfsdk:"source_ip"`
+	Destination       types.String `tfsdk:"destination"`
+	Protocol          types.String `tfsdk:"protocol"`
+	SourcePorts       types.String `tfsdk:"source_ports"`
+	DestinationPorts  types.String `tfsdk:"destination_ports"`
+	ProviderID        types.String `tfsdk:"provider_id"`
+	BackupProviderIDs types.List   `tfsdk:"backup_provider_ids"`
+	Mode              types.String `tfsdk:"mode"`
+	Enabled           types.Bool   `tfsdk:"enabled"`
+	Priority          types.Int64  `tfsdk:"priority"`
+	Description       types.String `tfsdk:"description"`
+	Tags              types.List   `tfsdk:"tags"`
+	Labels            types.Map    `tfsdk:"labels"`
+	Generation        types.Int64  `tfsdk:"generation"`
+}
+
+func newPolicyResource() resource.Resource {
+	return &policyResource{}
+}
+
+func (r *policyResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_policy"
+}
+
+func (r *policyResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "A routing policy: traffic from source_ip (optionally to destination) leaves through provider_id.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Description:   "Policy ID, generated by the API.",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name": schema.StringAttribute{
+				Required: true,
+			},
+			"source_ip": schema.StringAttribute{
+				Description: "Client IP or CIDR.",
+				Required:    true,
+			},
+			"destination": schema.StringAttribute{
+				Description: "Only traffic to this IP or CIDR; unset matches every destination.",
+				Optional:    true,
+			},
+			"protocol": schema.StringAttribute{
+				Description: "tcp, udp, sctp, icmp or icmpv6; needs features.nftables on the agents.",
+				Optional:    true,
+			},
+			"source_ports": schema.StringAttribute{
+				Optional: true,
+			},
+			"destination_ports": schema.StringAttribute{
+				Optional: true,
+			},
+			"provider_id": schema.StringAttribute{
+				Description: "Provider the traffic leaves through, e.g. routersync_provider.fiber.id.",
+				Required:    true,
+			},
+			"backup_provider_ids": schema.ListAttribute{
+				Description: "Providers used in order while the primary is down.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"mode": schema.StringAttribute{
+				Description: "strict drops traffic while no provider is up; best-effort (default) falls through to the main table.",
+				Optional:    true,
+				Validators:  []validator.String{stringvalidator.OneOf("strict", "best-effort")},
+			},
+			"enabled": schema.BoolAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  booldefault.StaticBool(true),
+			},
+			"priority": schema.Int64Attribute{
+				Description: "Explicit ip rule priority; unset derives it from the prefix length.",
+				Optional:    true,
+			},
+			"description": schema.StringAttribute{
+				Optional: true,
+			},
+			"tags": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"labels": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"generation": schema.Int64Attribute{
+				Description: "Store generation; updates fail if the policy changed outside Terraform.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *policyResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+func (r *policyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan policyResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p := plan.toAPI(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	created, err := r.client.CreatePolicy(ctx, p)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create policy", apiError(err))
+		return
+	}
+	plan.fromAPI(ctx, created, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *policyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state policyResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p, err := r.client.GetPolicy(ctx, state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read policy", apiError(err))
+		return
+	}
+	state.fromAPI(ctx, p, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *policyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state policyResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p := plan.toAPI(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p.ID = state.ID.ValueString()
+	p.Generation = uint64(state.Generation.ValueInt64())
+	updated, err := r.client.UpdatePolicy(ctx, p.ID, p)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update policy", apiError(err))
+		return
+	}
+	plan.fromAPI(ctx, updated, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *policyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state policyResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.DeletePolicy(ctx, state.ID.ValueString()); err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete policy", apiError(err))
+	}
+}
+
+func (r *policyResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (m *policyResourceModel) toAPI(ctx context.Context, diags *diag.Diagnostics) *client.Policy {
+	return &client.Policy{
+		Name:              m.Name.ValueString(),
+		SourceIP:          m.SourceIP.ValueString(),
+		Destination:       m.Destination.ValueString(),
+		Protocol:          m.Protocol.ValueString(),
+		SourcePorts:       m.SourcePorts.ValueString(),
+		DestinationPorts:  m.DestinationPorts.ValueString(),
+		ProviderID:        m.ProviderID.ValueString(),
+		BackupProviderIDs: stringList(ctx, m.BackupProviderIDs, diags),
+		Mode:              m.Mode.ValueString(),
+		Enabled:           m.Enabled.ValueBool(),
+		Priority:          int(m.Priority.ValueInt64()),
+		Description:       m.Description.ValueString(),
+		Tags:              stringList(ctx, m.Tags, diags),
+		Labels:            stringMap(ctx, m.Labels, diags),
+	}
+}
+
+func (m *policyResourceModel) fromAPI(ctx context.Context, p *client.Policy, diags *diag.Diagnostics) {
+	m.ID = types.StringValue(p.ID)
+	m.Name = types.StringValue(p.Name)
+	m.SourceIP = types.StringValue(p.SourceIP)
+	m.Destination = optionalString(p.Destination)
+	m.Protocol = optionalString(p.Protocol)
+	m.SourcePorts = optionalString(p.SourcePorts)
+	m.DestinationPorts = optionalString(p.DestinationPorts)
+	m.ProviderID = types.StringValue(p.ProviderID)
+	m.BackupProviderIDs = listValue(ctx, p.BackupProviderIDs, diags)
+	m.Mode = optionalString(p.Mode)
+	m.Enabled = types.BoolValue(p.Enabled)
+	m.Priority = optionalInt(p.Priority)
+	m.Description = optionalString(p.Description)
+	m.Tags = listValue(ctx, p.Tags, diags)
+	m.Labels = mapValue(ctx, p.Labels, diags)
+	m.Generation = types.Int64Value(int64(p.Generation))
+}
@@ -0,0 +1,97 @@
+// Package provider implements the routersync Terraform provider: the
+// routersync_provider and routersync_policy resources, backed by the
+// router-sync Go client.
+package provider
+
+import (
+	"context"
+	"os"
+
+	"router-sync/pkg/client"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// routerSyncProvider configures the client shared by all resources.
+type routerSyncProvider struct {
+	version string
+}
+
+// providerModel is the provider block.
+type providerModel struct {
+	Server types.String `tfsdk:"server"`
+	Token  types.String `tfsdk:"token"`
+}
+
+// New returns a constructor for the provider, as providerserver.Serve wants.
+func New(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &routerSyncProvider{version: version}
+	}
+}
+
+func (p *routerSyncProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "routersync"
+	resp.Version = p.version
+}
+
+func (p *routerSyncProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages multi-WAN providers and routing policies through the router-sync API.",
+		Attributes: map[string]schema.Attribute{
+			"server": schema.StringAttribute{
+				Description: "API base URL, e.g. http://192.168.2.252:18080. Defaults to ROUTERSYNC_SERVER, then http://localhost:18080.",
+				Optional:    true,
+			},
+			"token": schema.StringAttribute{
+				Description: "Bearer token when API auth is enabled. Defaults to ROUTERSYNC_TOKEN.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+		},
+	}
+}
+
+func (p *routerSyncProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var config providerModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	server := config.Server.ValueString()
+	if server == "" {
+		server = envOr("ROUTERSYNC_SERVER", "http://localhost:18080")
+	}
+	c := client.New(server)
+	c.Token = config.Token.ValueString()
+	if c.Token == "" {
+		c.Token = os.Getenv("ROUTERSYNC_TOKEN")
+	}
+	c.UserAgent = "terraform-provider-routersync/" + p.version
+
+	resp.ResourceData = c
+	resp.DataSourceData = c
+}
+
+func (p *routerSyncProvider) Resources(_ context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		newProviderResource,
+		newPolicyResource,
+	}
+}
+
+func (p *routerSyncProvider) DataSources(_ context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
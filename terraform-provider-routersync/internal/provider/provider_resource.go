@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"context"
+
+	"router-sync/pkg/client"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// providerResource is routersync_provider, one internet uplink.
+type providerResource struct {
+	client *client.Client
+}
+
+// providerResourceModel mirrors client.Provider's writable fields.
+type providerResourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	Name             types.String `tfsdk:"name"`
+	TableID          types.Int64  `tfsdk:"table_id"`
+	Gateway          types.String `tfsdk:"gateway"`
+	GatewayV6        types.String `tfsdk:"gateway_v6"`
+	Interfaces       types.Map    `tfsdk:"interfaces"`
+	InterfaceV6      types.String `tfsdk:"interface_v6"`
+	Description      types.String `tfsdk:"description"`
+	Labels           types.Map    `tfsdk:"labels"`
+	Weight           types.Int64  `tfsdk:"weight"`
+	FailoverPriority types.Int64  `tfsdk:"failover_priority"`
+	Generation       types.Int64  `tfsdk:"generation"`
+}
+
+func newProviderResource() resource.Resource {
+	return &providerResource{}
+}
+
+func (r *providerResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_provider"
+}
+
+func (r *providerResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "An internet provider (uplink) with its routing table.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Description:   "Provider ID, derived from the name by the API.",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name": schema.StringAttribute{
+				Description:   "Display name. Changing it replaces the provider, since the ID is derived from it.",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"table_id": schema.Int64Attribute{
+				Description: "Kernel routing table of the uplink.",
+				Required:    true,
+			},
+			"gateway": schema.StringAttribute{
+				Description: "IPv4 gateway.",
+				Required:    true,
+			},
+			"gateway_v6": schema.StringAttribute{
+				Description: "IPv6 gateway.",
+				Optional:    true,
+			},
+			"interfaces": schema.MapAttribute{
+				Description: "Interface per router hostname.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"interface_v6": schema.StringAttribute{
+				Description: "Interface carrying IPv6 when it differs from the IPv4 one.",
+				Optional:    true,
+			},
+			"description": schema.StringAttribute{
+				Optional: true,
+			},
+			"labels": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"weight": schema.Int64Attribute{
+				Description: "Share of traffic when policies balance over several providers.",
+				Optional:    true,
+			},
+			"failover_priority": schema.Int64Attribute{
+				Description: "Order in which backups take over; lower goes first.",
+				Optional:    true,
+			},
+			"generation": schema.Int64Attribute{
+				Description: "Store generation; updates fail if the provider changed outside Terraform.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *providerResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+func (r *providerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan providerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p := plan.toAPI(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	created, err := r.client.CreateProvider(ctx, p)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create provider", apiError(err))
+		return
+	}
+	plan.fromAPI(ctx, created, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *providerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state providerResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p, err := r.client.GetProvider(ctx, state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read provider", apiError(err))
+		return
+	}
+	state.fromAPI(ctx, p, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *providerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state providerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p := plan.toAPI(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	p.ID = state.ID.ValueString()
+	p.Generation = uint64(state.Generation.ValueInt64())
+	updated, err := r.client.UpdateProvider(ctx, p.ID, p)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update provider", apiError(err))
+		return
+	}
+	plan.fromAPI(ctx, updated, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *providerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state providerResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.DeleteProvider(ctx, state.ID.ValueString()); err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete provider", apiError(err))
+	}
+}
+
+func (r *providerResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (m *providerResourceModel) toAPI(ctx context.Context, diags *diag.Diagnostics) *client.Provider {
+	return &client.Provider{
+		Name:             m.Name.ValueString(),
+		TableID:          int(m.TableID.ValueInt64()),
+		Gateway:          m.Gateway.ValueString(),
+		GatewayV6:        m.GatewayV6.ValueString(),
+		Interfaces:       stringMap(ctx, m.Interfaces, diags),
+		InterfaceV6:      m.InterfaceV6.ValueString(),
+		Description:      m.Description.ValueString(),
+		Labels:           stringMap(ctx, m.Labels, diags),
+		Weight:           int(m.Weight.ValueInt64()),
+		FailoverPriority: int(m.FailoverPriority.ValueInt64()),
+	}
+}
+
+func (m *providerResourceModel) fromAPI(ctx context.Context, p *client.Provider, diags *diag.Diagnostics) {
+	m.ID = types.StringValue(p.ID)
+	m.Name = types.StringValue(p.Name)
+	m.TableID = types.Int64Value(int64(p.TableID))
+	m.Gateway = types.StringValue(p.Gateway)
+	m.GatewayV6 = optionalString(p.GatewayV6)
+	m.Interfaces = mapValue(ctx, p.Interfaces, diags)
+	m.InterfaceV6 = optionalString(p.InterfaceV6)
+	m.Description = optionalString(p.Description)
+	m.Labels = mapValue(ctx, p.Labels, diags)
+	m.Weight = optionalInt(p.Weight)
+	m.FailoverPriority = optionalInt(p.FailoverPriority)
+	m.Generation = types.Int64Value(int64(p.Generation))
+}
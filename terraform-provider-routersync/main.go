@@ -0,0 +1,30 @@
+// Command terraform-provider-routersync is the Terraform provider for the
+// router-sync API. It manages providers and policies through pkg/client.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"router-sync/terraform-provider-routersync/internal/provider"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+)
+
+// version is set with -ldflags "-X main.version=..." by release builds.
+var version = "dev"
+
+func main() {
+	var debug bool
+	flag.BoolVar(&debug, "debug", false, "Run with support for debuggers like delve")
+	flag.Parse()
+
+	err := providerserver.Serve(context.Background(), provider.New(version), providerserver.ServeOpts{
+		Address: "registry.terraform.io/fcastello/routersync",
+		Debug:   debug,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
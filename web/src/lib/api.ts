@@ -11,6 +11,7 @@ import type {
   RoutingPolicy,
   SetLogLevelRequest,
   StatsResponse,
+  SyncRunResult,
 } from "@/types/api";
 
 export class ApiError extends Error {
@@ -53,7 +54,7 @@ export const api = {
   health: () => request<HealthResponse>("/health"),
   stats: () => request<StatsResponse>("/api/v1/stats"),
   triggerSync: () =>
-    request<{ message: string }>("/api/v1/sync", { method: "POST" }),
+    request<SyncRunResult[]>("/api/v1/sync", { method: "POST" }),
 
   listProviders: () => request<InternetProvider[]>("/api/v1/providers"),
   getProvider: (id: string) =>
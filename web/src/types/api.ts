@@ -40,23 +40,74 @@ export interface RouterInfo {
   log_level: string;
   last_seen: string;
   age_seconds: number;
+  interfaces?: number;
+  tables?: number;
+  routes?: number;
+  managed_rules?: number;
+  maintenance?: boolean;
+}
+
+export interface Maintenance {
+  enabled: boolean;
+  reason?: string;
+  since?: string;
+  set_by?: string;
+  updated_at?: string;
 }
 
 export interface StatsResponse {
   sync: {
     providers_count: number;
     policies_count: number;
+    enabled_policies_count?: number;
+    groups_count?: number;
     sync_interval?: string;
     policies_per_provider?: Record<string, number>;
   };
   routers?: RouterInfo[];
+  maintenance?: Maintenance;
   log_level?: string;
   timestamp: string;
+  computed_at?: string;
   version?: string;
   build_time?: string;
   git_commit?: string;
 }
 
+export interface SyncResult {
+  sync_id: string;
+  trigger: string;
+  started_at: string;
+  duration_ms: number;
+  success: boolean;
+  running?: boolean;
+  maintenance?: boolean;
+  rules_added: number;
+  rules_removed: number;
+  rule_failures: number;
+  rules_skipped: number;
+  routes_added: number;
+  routes_removed: number;
+  route_failures: number;
+  policy_errors?: Record<string, string>;
+  errors?: string[];
+}
+
+export interface SyncRunResult {
+  hostname: string;
+  result?: SyncResult;
+  error?: string;
+}
+
+export interface SyncStatus {
+  hostname: string;
+  last_seen: string;
+  online: boolean;
+  last_sync?: SyncResult;
+  policy_errors?: Record<string, string>;
+  provider_errors?: Record<string, string>;
+}
+
 export interface LogLevelResponse {
   service_id?: string;
   level: string;